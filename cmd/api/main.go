@@ -5,11 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/build"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/encryption"
+	"github.com/anthdm/raptor/internal/export"
+	"github.com/anthdm/raptor/internal/policy"
+	"github.com/anthdm/raptor/internal/rollup"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
@@ -31,6 +38,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := config.Get().Validate(); err != nil {
+		log.Fatalf("invalid config:\n%s", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: config.LogLevel()})))
+	// The reloadable settings of the config are reloaded on SIGHUP.
+	config.ReloadOnSignal(configFile)
 
 	var (
 		user    = config.Get().Storage.User
@@ -40,16 +53,79 @@ func main() {
 		port    = config.Get().Storage.Port
 		sslmode = config.Get().Storage.SSLMode
 	)
-	store, err := storage.NewSQLStore(user, pw, dbname, host, port, sslmode)
+	sqlStore, err := storage.NewSQLStore(user, pw, dbname, host, port, sslmode)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var endpointStore storage.Store = sqlStore
+	if masterKey := config.Get().Encryption.MasterKey; len(masterKey) > 0 {
+		keyring, err := encryption.NewKeyring(masterKey, config.Get().Encryption.PreviousMasterKeys...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		endpointStore = storage.NewEncryptedStore(sqlStore, keyring)
+	}
+	var verifier *signing.Verifier
+	if c := config.Get().Signing; len(c.PublicKeys) > 0 || c.Required {
+		verifier, err = signing.NewVerifier(c.PublicKeys, c.Required)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var (
+		latencies     = storage.NewLatencies()
+		slowThreshold = time.Duration(config.Get().Storage.SlowThreshold)
+		store         = storage.NewInstrumentedStore(endpointStore, latencies, slowThreshold)
+		metricStore   = storage.NewInstrumentedMetricStore(sqlStore, latencies, slowThreshold)
+	)
 
 	if seed {
 		seedEndpoint(store, modCache)
 	}
 
-	server := api.NewServer(store, store, modCache)
+	policyEngine, err := policy.NewFromConfig(config.Get().Policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sink, err := export.NewSink(config.Get().Export)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sink != nil {
+		exporter := export.NewExporter(store, metricStore, sink, export.Interval(config.Get().Export), time.Duration(config.Get().Export.Retention))
+		go exporter.Run(context.Background())
+	}
+	usageTargets, err := export.NewUsageTargets(config.Get().UsageExport, sink)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(usageTargets) > 0 {
+		go export.NewUsageExporter(store, metricStore, usageTargets).Run(context.Background())
+	}
+	go rollup.NewCompactor(metricStore, config.Get().Metrics).Run(context.Background())
+
+	server := api.NewServer(store, metricStore, modCache, policyEngine).
+		WithStoreLatencies(latencies).
+		WithHealthChecks(config.Get().Health).
+		WithStatusPage(config.Get().Status).
+		WithSigning(verifier).
+		WithBackupStore(sqlStore)
+	if c := config.Get().Build; c.Enabled {
+		server.WithBuilder(build.NewBuilder(c))
+	}
+	if keys, ok := endpointStore.(storage.KeyRotator); ok {
+		server.WithKeyRotator(keys)
+	}
+	if interval := time.Duration(config.Get().Deployments.GCInterval); interval > 0 {
+		go server.RunDeploymentGC(context.Background(), interval)
+	}
+	if interval := time.Duration(config.Get().Workflows.WakeInterval); interval > 0 {
+		go server.RunWorkflows(context.Background(), interval)
+	}
+	if interval := time.Duration(config.Get().Async.ScheduleInterval); interval > 0 {
+		go server.RunSchedules(context.Background(), interval)
+	}
 	fmt.Printf("api server running\t%s\n", config.ApiUrl())
 	log.Fatal(server.Listen(config.Get().HTTPAPIAddr))
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// handleExportEndpoint prints the spec of the endpoints as YAML, ready to be
+// applied with raptor apply. The environment variables are left out unless
+// they are revealed with --reveal.
+func (c command) handleExportEndpoint(args []string) {
+	flagset := flag.NewFlagSet("export", flag.ExitOnError)
+	var reveal bool
+	flagset.BoolVar(&reveal, "reveal", false, "Include the values of the environment variables, requires an admin key")
+	_ = flagset.Parse(args)
+	args = flagset.Args()
+	if len(args) == 0 {
+		printErrorAndExit(fmt.Errorf("at least one endpoint to export is required"))
+	}
+	var file api.SpecFile
+	for _, arg := range args {
+		id := c.resolveEndpoint(arg)
+		getEndpoint := c.client.GetEndpoint
+		if reveal {
+			getEndpoint = c.client.RevealEndpoint
+		}
+		endpoint, err := getEndpoint(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		webhooks, err := c.client.GetWebhooks(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		file.Endpoints = append(file.Endpoints, api.NewEndpointSpec(endpoint, webhooks))
+	}
+	b, err := file.YAML()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Print(string(b))
+}
+
+// handleApply creates the endpoints of the spec file that do not exist and
+// updates the others to their spec. Applying the same file again changes
+// nothing.
+func (c command) handleApply(args []string) {
+	flagset := flag.NewFlagSet("apply", flag.ExitOnError)
+	var path string
+	flagset.StringVar(&path, "f", "", "The YAML file with the endpoint specs, - reads it from stdin")
+	var dryRun bool
+	flagset.BoolVar(&dryRun, "dry-run", false, "Only print the changes that would be applied")
+	_ = flagset.Parse(args)
+	if len(path) == 0 {
+		printErrorAndExit(fmt.Errorf("the spec file to apply is required (-f endpoints.yaml)"))
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	file, err := api.ParseSpecFile(b)
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid spec file %s: %s", path, err))
+	}
+	for _, spec := range file.Endpoints {
+		changes, err := c.applySpec(spec, dryRun)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("failed to apply endpoint %s: %s", spec.Name, err))
+		}
+		if len(changes) == 0 {
+			changes = []string{"unchanged"}
+		}
+		fmt.Printf("%s\t%s\n", spec.Name, strings.Join(changes, ", "))
+	}
+}
+
+// applySpec reconciles the endpoint with the given spec and returns the
+// changes it made.
+func (c command) applySpec(spec api.EndpointSpec, dryRun bool) ([]string, error) {
+	existing, err := c.findEndpointByName(spec.Name)
+	if errors.Is(err, errAmbiguousName) {
+		return nil, fmt.Errorf("more than one endpoint is named %s", spec.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var (
+		endpoint = existing
+		changes  []string
+	)
+	if endpoint == nil {
+		changes = append(changes, "created")
+		if dryRun {
+			// The settings of the spec are all applied to the new endpoint.
+			endpoint = &types.Endpoint{Name: spec.Name, Runtime: spec.Runtime}
+		} else {
+			endpoint, err = c.client.CreateEndpoint(api.CreateEndpointParams{
+				Name:        spec.Name,
+				Runtime:     spec.Runtime,
+				Environment: spec.Environment,
+				Owner:       spec.Owner,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// The current values are compared with the spec, so applying the
+		// same file again does not update the environment.
+		if endpoint, err = c.client.RevealEndpoint(existing.ID); err != nil {
+			return nil, err
+		}
+		if len(spec.Owner) > 0 && spec.Owner != endpoint.Owner() {
+			return nil, fmt.Errorf("it is owned by %q instead of %q, transfer it first", endpoint.Owner(), spec.Owner)
+		}
+	}
+
+	var webhooks []types.Webhook
+	if existing != nil {
+		if webhooks, err = c.client.GetWebhooks(endpoint.ID); err != nil {
+			return nil, err
+		}
+	}
+	params, changed, err := spec.Diff(api.NewEndpointSpec(endpoint, webhooks))
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) > 0 {
+		if existing != nil {
+			changes = append(changes, "updated "+strings.Join(changed, ", "))
+		}
+		if !dryRun {
+			if err := c.client.UpdateEndpoint(endpoint.ID, params); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if spec.Webhooks == nil {
+		return changes, nil
+	}
+	// The webhooks are matched by their url and events, the others are
+	// replaced.
+	key := func(url string, events []string) string {
+		events = slices.Clone(events)
+		slices.Sort(events)
+		return url + " " + strings.Join(events, ",")
+	}
+	wanted := make(map[string]bool, len(spec.Webhooks))
+	for _, webhook := range spec.Webhooks {
+		wanted[key(webhook.URL, webhook.Events)] = true
+	}
+	for _, webhook := range webhooks {
+		k := key(webhook.URL, webhook.Events)
+		if wanted[k] {
+			delete(wanted, k)
+			continue
+		}
+		changes = append(changes, "deleted webhook "+webhook.URL)
+		if !dryRun {
+			if err := c.client.DeleteWebhook(endpoint.ID, webhook.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, webhook := range spec.Webhooks {
+		k := key(webhook.URL, webhook.Events)
+		if !wanted[k] {
+			continue
+		}
+		delete(wanted, k)
+		changes = append(changes, "created webhook "+webhook.URL)
+		if !dryRun {
+			_, err := c.client.CreateWebhook(endpoint.ID, api.CreateWebhookParams{URL: webhook.URL, Events: webhook.Events})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
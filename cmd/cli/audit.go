@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// handleAudit prints the audit log of the management API calls, newest
+// first.
+func (c command) handleAudit(args []string) {
+	flagset := flag.NewFlagSet("audit", flag.ExitOnError)
+	var actor string
+	flagset.StringVar(&actor, "actor", "", "Only show the calls of the actor")
+	var endpoint string
+	flagset.StringVar(&endpoint, "endpoint", "", "Only show the calls about the endpoint (id or name)")
+	var since time.Duration
+	flagset.DurationVar(&since, "since", 0, "Only show the calls of the last duration (24h)")
+	var limit int
+	flagset.IntVar(&limit, "limit", 50, "Maximum amount of calls shown, 0 shows all of them")
+	_ = flagset.Parse(args)
+
+	filter := types.AuditFilter{Actor: actor, Limit: limit}
+	if len(endpoint) > 0 {
+		filter.EndpointID = c.resolveEndpoint(endpoint)
+	}
+	if since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+	entries, err := c.client.GetAuditLog(filter)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "id", wide: true},
+		column{header: "created"},
+		column{header: "actor"},
+		column{header: "method"},
+		column{header: "path"},
+		column{header: "status"},
+		column{header: "source", wide: true},
+		column{header: "params", wide: true},
+	)
+	for _, entry := range entries {
+		path := entry.Path
+		if len(entry.Query) > 0 {
+			path += "?" + entry.Query
+		}
+		t.add(entry.ID.String(), entry.CreatedAT.Format(time.RFC3339), entry.Actor, entry.Method, path,
+			strconv.Itoa(entry.StatusCode), entry.SourceIP, entry.Params)
+	}
+	printList(entries, t)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/google/uuid"
+)
+
+// benchTarget is a deployment the load of a benchmark is sent to.
+type benchTarget struct {
+	name string
+	url  string
+}
+
+// benchResult holds the outcome of the requests of a benchmark. Requests
+// that failed or got a status code of 400 or above are errors.
+type benchResult struct {
+	Target            string        `json:"target"`
+	Requests          int           `json:"requests"`
+	Errors            int           `json:"errors"`
+	ColdStarts        int           `json:"cold_starts"`
+	Duration          time.Duration `json:"duration"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	Min               time.Duration `json:"min"`
+	P50               time.Duration `json:"p50"`
+	P90               time.Duration `json:"p90"`
+	P99               time.Duration `json:"p99"`
+	Max               time.Duration `json:"max"`
+}
+
+// ErrorRate returns the share of the requests that were errors.
+func (r benchResult) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// benchOptions describe the load of a benchmark.
+type benchOptions struct {
+	method string
+	header http.Header
+	body   []byte
+	// The amount of requests, or the duration the requests are sent for
+	// when it is zero.
+	requests    int
+	duration    time.Duration
+	concurrency int
+	timeout     time.Duration
+}
+
+// handleBench sends concurrent requests to the LIVE deployment of an
+// endpoint, or to a deployment in PREVIEW, and reports their latencies, cold
+// starts and errors. With --compare the same load is sent to a deployment in
+// PREVIEW afterwards and the results are printed side by side.
+func (c command) handleBench(args []string) {
+	flagset := flag.NewFlagSet("bench", flag.ExitOnError)
+	var deploy string
+	flagset.StringVar(&deploy, "deploy", "", "Benchmark the given deployment in PREVIEW instead of the LIVE deployment")
+	var compare string
+	flagset.StringVar(&compare, "compare", "", "Benchmark the given deployment in PREVIEW as well and compare it side by side")
+	var opts benchOptions
+	flagset.IntVar(&opts.requests, "n", 200, "The amount of requests sent to every deployment")
+	flagset.DurationVar(&opts.duration, "duration", 0, "Send requests for this long instead of a fixed amount, like 30s")
+	flagset.IntVar(&opts.concurrency, "c", 10, "The amount of requests sent concurrently")
+	flagset.DurationVar(&opts.timeout, "timeout", time.Second*30, "The timeout of a request")
+	flagset.StringVar(&opts.method, "method", "GET", "The method of the requests")
+	var path string
+	flagset.StringVar(&path, "path", "/", "The path of the requests, relative to the endpoint")
+	var headers stringList
+	flagset.Var(&headers, "header", "Headers of the requests (--header \"Content-Type: application/json\")")
+	var data string
+	flagset.StringVar(&data, "data", "", "The body of the requests, @file reads the body from a file")
+
+	var endpoint string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		endpoint, args = args[0], args[1:]
+	}
+	_ = flagset.Parse(args)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if opts.concurrency < 1 {
+		printErrorAndExit(fmt.Errorf("the concurrency should be at least 1"))
+	}
+	if opts.duration > 0 {
+		opts.requests = 0
+	} else if opts.requests < 1 {
+		printErrorAndExit(fmt.Errorf("provide the amount of requests (-n) or a duration (--duration)"))
+	}
+
+	var targets []benchTarget
+	switch {
+	case len(deploy) > 0:
+		targets = append(targets, previewTarget(deploy, path))
+	case len(endpoint) > 0:
+		id := c.resolveEndpoint(endpoint)
+		targets = append(targets, benchTarget{
+			name: "live",
+			url:  fmt.Sprintf("%s/live/%s%s", config.IngressUrl(), id, path),
+		})
+	default:
+		printErrorAndExit(fmt.Errorf("provide an endpoint id or a deployment: raptor bench <endpoint> | raptor bench --deploy <deploy-id>"))
+	}
+	if len(compare) > 0 {
+		targets = append(targets, previewTarget(compare, path))
+	}
+
+	opts.header = make(http.Header)
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			printErrorAndExit(fmt.Errorf("headers need to be in the format of --header \"name: value\""))
+		}
+		opts.header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if data == "@-" {
+		printErrorAndExit(fmt.Errorf("the body of the requests cannot be read from stdin"))
+	}
+	body, err := readInvokeBody(data)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	opts.body = body
+
+	results := make([]benchResult, 0, len(targets))
+	for _, target := range targets {
+		if opts.duration > 0 {
+			progressf("benchmarking %s for %s with %d concurrent requests\n", target.name, opts.duration, opts.concurrency)
+		} else {
+			progressf("benchmarking %s with %d requests, %d concurrently\n", target.name, opts.requests, opts.concurrency)
+		}
+		result, err := runBench(target, opts)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		results = append(results, result)
+	}
+	printBenchResults(results)
+}
+
+func previewTarget(deploy, path string) benchTarget {
+	id, err := uuid.Parse(deploy)
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid deploy id given: %s", deploy))
+	}
+	return benchTarget{
+		name: "preview " + shortHash(id.String()),
+		url:  fmt.Sprintf("%s/preview/%s%s", config.IngressUrl(), id, path),
+	}
+}
+
+// runBench sends the requests to the target and collects their outcome.
+func runBench(target benchTarget, opts benchOptions) (benchResult, error) {
+	// The requests are validated once, the workers create them in the same
+	// way.
+	if _, err := http.NewRequest(opts.method, target.url, nil); err != nil {
+		return benchResult{}, err
+	}
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: opts.concurrency,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	ctx := context.Background()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; opts.requests == 0 || i < opts.requests; i++ {
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+		result    = benchResult{Target: target.name}
+		start     = time.Now()
+	)
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				req, _ := http.NewRequest(opts.method, target.url, bytes.NewReader(opts.body))
+				req.Header = opts.header.Clone()
+				began := time.Now()
+				resp, err := client.Do(req)
+				var cold bool
+				if err == nil {
+					_, err = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					cold = resp.Header.Get(shared.ColdStartHeader) == "true"
+				}
+				latency := time.Since(began)
+
+				mu.Lock()
+				result.Requests++
+				if err != nil || resp.StatusCode >= http.StatusBadRequest {
+					result.Errors++
+				}
+				if cold {
+					result.ColdStarts++
+				}
+				latencies = append(latencies, latency)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	if result.Duration > 0 {
+		result.RequestsPerSecond = float64(result.Requests) / result.Duration.Seconds()
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		result.Min = latencies[0]
+		result.P50 = latencyPercentile(latencies, 0.5)
+		result.P90 = latencyPercentile(latencies, 0.9)
+		result.P99 = latencyPercentile(latencies, 0.99)
+		result.Max = latencies[len(latencies)-1]
+	}
+	return result, nil
+}
+
+// latencyPercentile returns the nearest-rank percentile of the sorted
+// latencies.
+func latencyPercentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// printBenchResults prints the results with a column per target.
+func printBenchResults(results []benchResult) {
+	columns := []column{{header: "metric"}}
+	for _, result := range results {
+		columns = append(columns, column{header: result.Target})
+	}
+	t := newTable(columns...)
+	row := func(name string, value func(benchResult) string) {
+		cells := []string{name}
+		for _, result := range results {
+			cells = append(cells, value(result))
+		}
+		t.add(cells...)
+	}
+	latency := func(d time.Duration) string {
+		return d.Round(time.Microsecond * 10).String()
+	}
+	row("requests", func(r benchResult) string { return strconv.Itoa(r.Requests) })
+	row("errors", func(r benchResult) string { return fmt.Sprintf("%d (%.2f%%)", r.Errors, r.ErrorRate()*100) })
+	row("cold starts", func(r benchResult) string { return strconv.Itoa(r.ColdStarts) })
+	row("requests/s", func(r benchResult) string { return strconv.FormatFloat(r.RequestsPerSecond, 'f', 1, 64) })
+	row("min", func(r benchResult) string { return latency(r.Min) })
+	row("p50", func(r benchResult) string { return latency(r.P50) })
+	row("p90", func(r benchResult) string { return latency(r.P90) })
+	row("p99", func(r benchResult) string { return latency(r.P99) })
+	row("max", func(r benchResult) string { return latency(r.Max) })
+	printList(results, t)
+}
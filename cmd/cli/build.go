@@ -0,0 +1,146 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// Interval at which the status of a build is polled.
+const buildPollInterval = time.Second
+
+// Directories that are not uploaded along with the sources.
+var ignoredSourceDirs = map[string]bool{
+	".git":         true,
+	"target":       true,
+	"node_modules": true,
+}
+
+// deploySource uploads the sources to be built by the platform, waits for
+// the build and prints its logs and the id of the created deployment.
+func (c command) deploySource(endpointID uuid.UUID, source string, params api.CreateBuildParams) {
+	info, err := os.Stat(source)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	var archive []byte
+	if info.IsDir() {
+		if len(params.Language) == 0 {
+			params.Language = detectLanguage(source)
+		}
+		archive, err = zipSource(source)
+	} else {
+		archive, err = os.ReadFile(source)
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if !types.ValidBuildLanguage(params.Language) {
+		printErrorAndExit(fmt.Errorf("provide the language of the sources: --language go|tinygo|rust"))
+	}
+
+	build, err := c.client.CreateBuild(endpointID, bytes.NewReader(archive), params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	progressf("build %s %s\n", build.ID, build.Status)
+	status := build.Status
+	for !build.Done() {
+		time.Sleep(buildPollInterval)
+		build, err = c.client.GetBuild(build.ID)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		if build.Status != status {
+			status = build.Status
+			progressf("build %s %s\n", build.ID, build.Status)
+		}
+	}
+	if len(build.Logs) > 0 {
+		progressf("--- logs\n%s\n", build.Logs)
+	}
+	if build.Status == types.BuildFailed {
+		os.Exit(1)
+	}
+	if !humanOutput() {
+		printResult(build, build.DeploymentID.String())
+		return
+	}
+	fmt.Printf("deployment %s created, publish it with: raptor publish --deploy %s\n", build.DeploymentID, build.DeploymentID)
+}
+
+// detectLanguage returns the language of the sources in dir based on their
+// manifest, empty when there is none.
+func detectLanguage(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "Cargo.toml")); err == nil {
+		return types.BuildLanguageRust
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return types.BuildLanguageGo
+	}
+	return ""
+}
+
+// zipSource archives the files of dir, leaving out build output and version
+// control.
+func zipSource(dir string) ([]byte, error) {
+	var (
+		buf = new(bytes.Buffer)
+		zw  = zip.NewWriter(buf)
+	)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && ignoredSourceDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || filepath.Ext(path) == ".wasm" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
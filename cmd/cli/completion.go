@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// completeCommand is the hidden command the completion scripts call with
+// the words of the command line to get the candidates of the last word.
+const completeCommand = "__complete"
+
+// completionCommands are the commands of the cli with their subcommands.
+var completionCommands = map[string][]string{
+	"init":        nil,
+	"login":       nil,
+	"profile":     {"use"},
+	"endpoint":    {"list", "describe", "update", "rollback", "rollout", "transfer", "history", "webhook", "events", "errors", "dead-letters", "export", "disable", "enable"},
+	"apply":       nil,
+	"publish":     nil,
+	"deploy":      {"list", "prune", "keygen"},
+	"recommend":   nil,
+	"snapshot":    nil,
+	"metrics":     nil,
+	"concurrency": nil,
+	"rotate-key":  nil,
+	"env-drift":   nil,
+	"cache":       nil,
+	"cors":        nil,
+	"verify":      nil,
+	"jwt":         nil,
+	"prewarm":     nil,
+	"admin":       {"status", "upgrade", "backup", "restore", "rotate-master-key"},
+	"audit":       nil,
+	"usage":       nil,
+	"project-env": nil,
+	"platform":    {"status", "incident", "resolve"},
+	"dev":         nil,
+	"invoke":      nil,
+	"schedule":    {"create", "list", "cancel"},
+	"workflow":    {"start", "get"},
+	"bench":       nil,
+	"replay":      {"list"},
+	"shell":       nil,
+	"completion":  {"bash", "zsh", "fish"},
+	"config":      {"validate", "show"},
+	"help":        nil,
+}
+
+// endpointCommands are the commands and the subcommands that take an
+// endpoint as their first argument.
+var endpointCommands = map[string]bool{
+	"endpoint describe":     true,
+	"endpoint update":       true,
+	"endpoint rollback":     true,
+	"endpoint rollout":      true,
+	"endpoint transfer":     true,
+	"endpoint history":      true,
+	"endpoint webhook":      true,
+	"endpoint events":       true,
+	"endpoint errors":       true,
+	"endpoint dead-letters": true,
+	"endpoint export":       true,
+	"endpoint disable":      true,
+	"endpoint enable":       true,
+	"deploy":                true,
+	"deploy list":           true,
+	"deploy prune":          true,
+	"recommend":             true,
+	"snapshot":              true,
+	"metrics":               true,
+	"concurrency":           true,
+	"rotate-key":            true,
+	"env-drift":             true,
+	"cache":                 true,
+	"cors":                  true,
+	"verify":                true,
+	"jwt":                   true,
+	"prewarm":               true,
+	"invoke":                true,
+	"schedule create":       true,
+	"schedule list":         true,
+	"schedule cancel":       true,
+	"workflow start":        true,
+	"bench":                 true,
+	"replay list":           true,
+	"shell":                 true,
+}
+
+// Values of the flags that are completed.
+var completionFlagValues = map[string][]string{
+	"o":          {outputTable, outputWide, outputJSON, outputQuiet},
+	"output":     {outputTable, outputWide, outputJSON, outputQuiet},
+	"runtime":    {"go", "js"},
+	"template":   {"go", "rust", "js"},
+	"language":   {"go", "tinygo", "rust"},
+	"resolution": {"1m", "1h"},
+}
+
+var globalFlags = []string{"--config", "--profile", "--output", "--quiet"}
+
+// handleCompletion prints the completion script of the given shell.
+func (c command) handleCompletion(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		printErrorAndExit(fmt.Errorf("invalid shell %s, only bash, zsh and fish are supported", args[0]))
+	}
+}
+
+// handleComplete prints the candidates of the last word of the command line,
+// one per line. Nothing is printed when the word is a file, the completion
+// scripts fall back to the files then.
+func (c command) handleComplete(args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	var (
+		current = args[len(args)-1]
+		words   []string
+		profile = c.profileName
+		// Flag the current word is the value of.
+		flagName string
+	)
+	for i := 0; i < len(args)-1; i++ {
+		w := args[i]
+		if !strings.HasPrefix(w, "-") {
+			words = append(words, w)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+		if hasValue || isBoolFlag(name) {
+			continue
+		}
+		if i+1 == len(args)-1 {
+			flagName = name
+			break
+		}
+		if name == "profile" {
+			profile = args[i+1]
+		}
+		i++
+	}
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(current, "-"):
+		candidates = globalFlags
+	case flagName == "profile":
+		if profiles, err := loadProfileConfig(); err == nil {
+			for name := range profiles.Profiles {
+				candidates = append(candidates, name)
+			}
+		}
+	case len(flagName) > 0:
+		candidates = completionFlagValues[flagName]
+	case len(words) == 0:
+		for name := range completionCommands {
+			candidates = append(candidates, name)
+		}
+	case len(words) == 1:
+		candidates = completionCommands[words[0]]
+		if endpointCommands[words[0]] {
+			candidates = append(candidates, c.endpointNames(profile)...)
+		}
+	case len(words) == 2 && endpointCommands[words[0]+" "+words[1]]:
+		candidates = c.endpointNames(profile)
+	}
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+// isBoolFlag returns true for the flags that do not take a value.
+func isBoolFlag(name string) bool {
+	switch name {
+	case "q", "quiet", "no-dedupe", "dry-run", "replace-env", "default-deny", "disable", "purge", "watch", "guarded", "publish":
+		return true
+	}
+	return false
+}
+
+// endpointNames returns the names of the endpoints, none when the API server
+// can not be reached.
+func (c command) endpointNames(profile string) []string {
+	if err := c.useProfile(profile); err != nil {
+		return nil
+	}
+	endpoints, err := c.client.ListEndpoints("")
+	if err != nil {
+		return nil
+	}
+	var (
+		names = make([]string, 0, len(endpoints))
+		seen  = make(map[string]bool, len(endpoints))
+	)
+	for _, endpoint := range endpoints {
+		if !seen[endpoint.Name] {
+			seen[endpoint.Name] = true
+			names = append(names, endpoint.Name)
+		}
+	}
+	return names
+}
+
+const bashCompletion = `# bash completion for raptor, load it with: source <(raptor completion bash)
+_raptor() {
+    local IFS=$'\n'
+    COMPREPLY=($(raptor __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [[ ${#COMPREPLY[@]} -eq 0 ]]; then
+        compopt -o default
+    fi
+}
+complete -F _raptor raptor
+`
+
+const zshCompletion = `#compdef raptor
+# zsh completion for raptor, load it with: source <(raptor completion zsh)
+_raptor() {
+    local -a candidates
+    candidates=("${(@f)$(raptor __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n ${candidates[1]} ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+if [[ "${funcstack[1]}" == "_raptor" ]]; then
+    _raptor "$@"
+else
+    compdef _raptor raptor
+fi
+`
+
+const fishCompletion = `# fish completion for raptor, load it with: raptor completion fish | source
+function __raptor_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    raptor __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c raptor -a '(__raptor_complete)'
+`
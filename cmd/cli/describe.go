@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+)
+
+// handleDescribeEndpoint prints the state of an endpoint in one view: its
+// settings, its LIVE deployment and health, its recent requests and its
+// recent deployments.
+func (c command) handleDescribeEndpoint(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("describe", flag.ExitOnError)
+	var window time.Duration
+	flagset.DurationVar(&window, "window", time.Hour, "The window the requests are summarized over")
+	_ = flagset.Parse(args[1:])
+
+	d, err := c.client.DescribeEndpoint(id, window)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if !humanOutput() {
+		printResult(d, d.Endpoint.ID.String())
+		return
+	}
+
+	e := d.Endpoint
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", e.Name)
+	fmt.Fprintf(tw, "ID:\t%s\n", e.ID)
+	fmt.Fprintf(tw, "Runtime:\t%s\n", e.Runtime)
+	if e.Ownership != nil && len(e.Ownership.Owner) > 0 {
+		fmt.Fprintf(tw, "Owner:\t%s\n", e.Ownership.Owner)
+	}
+	if len(e.Regions) > 0 {
+		fmt.Fprintf(tw, "Regions:\t%s\n", strings.Join(e.Regions, ", "))
+	}
+	if e.MaxConcurrency > 0 {
+		fmt.Fprintf(tw, "Max concurrency:\t%d\n", e.MaxConcurrency)
+	}
+	fmt.Fprintf(tw, "Created:\t%s\n", e.CreatedAT.Format(time.RFC3339))
+	if d.ActiveDeployment == nil || shared.IsZeroUUID(e.ActiveDeploymentID) {
+		fmt.Fprintf(tw, "LIVE:\tnothing is published\n")
+	} else {
+		fmt.Fprintf(tw, "LIVE:\t%s (%s)\n", d.ActiveDeployment.ID, d.ActiveDeployment.CreatedAT.Format(time.RFC3339))
+	}
+	if e.Maintenance.Active() {
+		fmt.Fprintf(tw, "Maintenance:\tdisabled, %s\n", e.Maintenance.Text())
+	}
+	if n := e.Prewarm.Instances(time.Now()); n > 0 {
+		fmt.Fprintf(tw, "Prewarm:\t%d runtimes per ingress\n", n)
+	}
+	if d.Health != nil {
+		health := d.Health.Status
+		if d.Health.Failures > 0 {
+			health = fmt.Sprintf("%s, %d consecutive failed checks", health, d.Health.Failures)
+		}
+		fmt.Fprintf(tw, "Health:\t%s\n", health)
+	}
+	r := d.Requests
+	fmt.Fprintf(tw, "Requests (%s):\t%d, %.2f%% errors, p50 %s, p95 %s\n",
+		window, r.Requests, r.ErrorRate*100, r.P50Duration, r.P95Duration)
+	fmt.Fprintf(tw, "Invocation errors (%s):\t%d\n", window, d.Errors)
+	if err := tw.Flush(); err != nil {
+		printErrorAndExit(err)
+	}
+
+	fmt.Println()
+	fmt.Println("Recent deployments:")
+	t := newTable(
+		column{header: "id"},
+		column{header: "live"},
+		column{header: "hash"},
+		column{header: "branch"},
+		column{header: "commit"},
+		column{header: "description", wide: true},
+		column{header: "created"},
+	)
+	for _, deploy := range d.Deployments {
+		var live string
+		if deploy.ID == e.ActiveDeploymentID {
+			live = "*"
+		}
+		t.add(deploy.ID.String(), live, shortHash(deploy.Hash), deploy.GitBranch, shortHash(deploy.GitCommit),
+			deploy.Description, deploy.CreatedAT.Format(time.RFC3339))
+	}
+	if err := t.write(os.Stdout, outputFormat == outputWide); err != nil {
+		printErrorAndExit(err)
+	}
+
+	if len(d.ErrorGroups) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("Top errors (see raptor endpoint errors %s):\n", e.ID)
+	t = newTable(
+		column{header: "signature"},
+		column{header: "kind"},
+		column{header: "count"},
+		column{header: "last seen"},
+		column{header: "reason"},
+	)
+	for _, group := range d.ErrorGroups {
+		t.add(group.Signature, group.Kind, strconv.Itoa(group.Count), group.LastSeen.Format(time.RFC3339), group.Reason)
+	}
+	if err := t.write(os.Stdout, outputFormat == outputWide); err != nil {
+		printErrorAndExit(err)
+	}
+}
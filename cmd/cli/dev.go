@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/actrs"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// Interval at which the dev server checks the module for changes.
+const devWatchInterval = time.Millisecond * 500
+
+// handleDev serves a local module through the ingress and the runtimes of a
+// single member cluster backed by a memory store. The module is deployed
+// again whenever it changes on disk.
+func (c command) handleDev(args []string) {
+	flagset := flag.NewFlagSet("dev", flag.ExitOnError)
+
+	var engine string
+	flagset.StringVar(&engine, "runtime", "go", "The runtime of the module (go or js)")
+	var env stringList
+	flagset.Var(&env, "env", "Environment variables of the endpoint")
+	var addr string
+	flagset.StringVar(&addr, "addr", config.Get().HTTPIngressAddr, "The address the ingress listens on")
+	var clusterAddr string
+	flagset.StringVar(&clusterAddr, "cluster-addr", "127.0.0.1:8142", "The address of the local cluster member")
+
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	_ = flagset.Parse(args)
+	if len(file) == 0 {
+		printErrorAndExit(fmt.Errorf("provide the module to serve: raptor dev <path/to/app.wasm>"))
+	}
+	if !types.ValidRuntime(engine) {
+		printErrorAndExit(fmt.Errorf("invalid runtime %s, only go and js are currently supported", engine))
+	}
+
+	// The ingress and the runtimes run in this process, the invocations of
+	// the guests are signed with its own secret when none is configured.
+	async.UseProcessSecret()
+	var (
+		store    = storage.NewMemoryStore()
+		modCache = storage.NewDefaultModCache()
+		tracker  = admin.NewTracker()
+		endpoint = types.NewEndpoint("dev", engine, makeEnvMap(env))
+	)
+	if err := store.CreateEndpoint(endpoint); err != nil {
+		printErrorAndExit(err)
+	}
+	dev := &devServer{store: store, cache: modCache, endpoint: endpoint, file: file}
+	if err := dev.reload(); err != nil {
+		printErrorAndExit(err)
+	}
+
+	clusterConfig := cluster.NewConfig().
+		WithListenAddr(clusterAddr).
+		WithID("dev").
+		WithProvider(cluster.NewSelfManagedProvider(cluster.NewSelfManagedConfig())).
+		WithActivationStrategy(actrs.NewRegionActivationStrategy())
+	cl, err := cluster.New(clusterConfig)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	cl.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, runtime.NewCompiler(modCache), tracker, nil, ""), &cluster.KindConfig{})
+	cl.Engine().Spawn(actrs.NewMetric(store, nil), actrs.KindMetric, actor.WithID("1"))
+	cl.Engine().Spawn(newDevLog, actrs.KindRuntimeLog, actor.WithID("1"))
+	cl.Engine().Spawn(actrs.NewCanceler(), actrs.KindCanceler, actor.WithID("1"))
+	cl.Spawn(actrs.NewRuntimeManager(cl), actrs.KindRuntimeManager, actor.WithID("1"))
+	cl.Start()
+	defer func() {
+		cl.Stop().Wait()
+	}()
+
+	cl.Engine().Spawn(actrs.NewWasmServer(addr, cl, store, store, modCache, tracker), actrs.KindWasmServer)
+	tracker.SetWarm()
+	fmt.Printf("serving %s on http://%s/live/%s (ctrl+c to exit)\n", file, addr, endpoint.ID)
+
+	go dev.watch(devWatchInterval)
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+	<-sigch
+}
+
+// devServer deploys the module of the dev server.
+type devServer struct {
+	store    *storage.MemoryStore
+	cache    storage.ModCacher
+	endpoint *types.Endpoint
+	file     string
+}
+
+// reload deploys the module and makes it the LIVE deployment of the
+// endpoint, the module of the previous deployment is evicted.
+func (d *devServer) reload() error {
+	b, err := os.ReadFile(d.file)
+	if err != nil {
+		return err
+	}
+	previous := d.endpoint.ActiveDeploymentID
+	deploy := types.NewDeployment(d.endpoint, b)
+	if err := d.store.CreateDeployment(deploy); err != nil {
+		return err
+	}
+	if err := d.store.UpdateEndpoint(d.endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: deploy.ID}); err != nil {
+		return err
+	}
+	if previous != uuid.Nil {
+		d.cache.Delete(previous)
+	}
+	return nil
+}
+
+// watch deploys the module again whenever it changes, modules that are
+// still being written are not deployed.
+func (d *devServer) watch(interval time.Duration) {
+	watchPath(d.file, interval, nil, func() {
+		if err := d.reload(); err != nil {
+			fmt.Printf("failed to reload %s: %s\n", d.file, err)
+			return
+		}
+		fmt.Printf("reloaded %s\n", d.file)
+	})
+}
+
+// devLog prints the logs of the invocations to the terminal.
+type devLog struct{}
+
+func newDevLog() actor.Receiver {
+	return devLog{}
+}
+
+func (devLog) Receive(c *actor.Context) {
+	msg, ok := c.Message().(types.RuntimeLogEvent)
+	if !ok || len(msg.Data) == 0 {
+		return
+	}
+	fmt.Printf("--- logs %s\n%s", msg.RequestID, msg.Data)
+	if !bytes.HasSuffix(msg.Data, []byte("\n")) {
+		fmt.Println()
+	}
+}
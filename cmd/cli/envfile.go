@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readEnvFiles parses the given dotenv files, variables of later files take
+// precedence.
+func readEnvFiles(files []string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		vars, err := parseEnvFile(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file %s: %s", file, err)
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+// parseEnvFile parses variables in the dotenv format, one KEY=value per line
+// with an optional "export " prefix. Lines starting with # are comments, so
+// is the rest of an unquoted value after " #". Single quoted values are
+// taken literally, double quoted values may span lines and expand the \n,
+// \r, \t, \" and \\ escapes.
+func parseEnvFile(data string) (map[string]string, error) {
+	env := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineno := i + 1
+		line := strings.TrimSpace(lines[i])
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validEnvKey(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineno)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quoted value", lineno)
+			}
+			if err := checkTrailing(value[end+2:], lineno); err != nil {
+				return nil, err
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// The value continues on the next lines until the closing quote.
+			raw := value[1:]
+			for {
+				if end := closingQuote(raw); end >= 0 {
+					if err := checkTrailing(raw[end+1:], lineno); err != nil {
+						return nil, err
+					}
+					raw = raw[:end]
+					break
+				}
+				i++
+				if i == len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated double quoted value", lineno)
+				}
+				raw += "\n" + lines[i]
+			}
+			value = unescapeEnvValue(raw)
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+func validEnvKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// closingQuote returns the index of the first unescaped double quote.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// checkTrailing only allows a comment after a quoted value.
+func checkTrailing(rest string, lineno int) error {
+	rest = strings.TrimSpace(rest)
+	if len(rest) > 0 && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("line %d: unexpected %q after the quoted value", lineno, rest)
+	}
+	return nil
+}
+
+func unescapeEnvValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// projectTemplate describes how a project of a template is built.
+type projectTemplate struct {
+	runtime string
+	file    string
+}
+
+var projectTemplates = map[string]projectTemplate{
+	"go":   {runtime: "go", file: "app.wasm"},
+	"rust": {runtime: "go", file: "app.wasm"},
+	"js":   {runtime: "js", file: "index.js"},
+}
+
+var projectNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// handleInit generates a function project from a template, with the handler,
+// a build script producing the module and a raptor.toml with the settings of
+// the endpoint.
+func (c command) handleInit(args []string) {
+	flagset := flag.NewFlagSet("init", flag.ExitOnError)
+
+	var tmpl string
+	flagset.StringVar(&tmpl, "template", "go", "The template of the project (go, rust or js)")
+	var name string
+	flagset.StringVar(&name, "name", "", "The name of the project and its endpoint, the name of the directory by default")
+
+	dir := "."
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		dir, args = args[0], args[1:]
+	}
+	_ = flagset.Parse(args)
+
+	t, ok := projectTemplates[tmpl]
+	if !ok {
+		printErrorAndExit(fmt.Errorf("invalid template %s, only go, rust and js are currently supported", tmpl))
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(name) == 0 {
+		name = strings.ToLower(filepath.Base(abs))
+	}
+	if !projectNameRegex.MatchString(name) {
+		printErrorAndExit(fmt.Errorf("invalid project name %s, use lowercase letters, digits, - and _ (--name <name>)", name))
+	}
+	if err := initProject(dir, tmpl, name, t); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("created %s project %s in %s\n", tmpl, name, dir)
+	if tmpl != "js" {
+		fmt.Printf("build it with %s\n", filepath.Join(dir, "build.sh"))
+	}
+	fmt.Printf("create its endpoint and deploy it with \"raptor deploy\" from %s\n", dir)
+}
+
+// initProject renders the files of the template and the raptor.toml into
+// dir. Existing files are never overwritten.
+func initProject(dir, tmpl, name string, t projectTemplate) error {
+	data := struct {
+		Name    string
+		Runtime string
+		File    string
+	}{name, t.runtime, t.file}
+
+	files := map[string]string{
+		projectFile: "templates/raptor.toml.tmpl",
+	}
+	root := path.Join("templates", tmpl)
+	err := fs.WalkDir(templates, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := strings.TrimSuffix(strings.TrimPrefix(p, root+"/"), ".tmpl")
+		files[filepath.FromSlash(rel)] = p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for target := range files {
+		if _, err := os.Stat(filepath.Join(dir, target)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, target))
+		}
+	}
+	for target, source := range files {
+		parsed, err := template.ParseFS(templates, source)
+		if err != nil {
+			return err
+		}
+		target = filepath.Join(dir, target)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(target, ".sh") {
+			mode = 0755
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+		if err := parsed.Execute(f, data); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
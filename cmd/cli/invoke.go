@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+const invokePollInterval = time.Second
+
+// handleInvoke sends a single request to the LIVE deployment of an endpoint,
+// or to a deployment in PREVIEW, and prints the response.
+func (c command) handleInvoke(args []string) {
+	flagset := flag.NewFlagSet("invoke", flag.ExitOnError)
+
+	var deploy string
+	flagset.StringVar(&deploy, "deploy", "", "Invoke the given deployment in PREVIEW instead of the LIVE deployment, the logs are printed")
+	var method string
+	flagset.StringVar(&method, "method", "GET", "The method of the request")
+	var path string
+	flagset.StringVar(&path, "path", "/", "The path of the request, relative to the endpoint")
+	var headers stringList
+	flagset.Var(&headers, "header", "Headers of the request (--header \"Content-Type: application/json\")")
+	var data string
+	flagset.StringVar(&data, "data", "", "The body of the request, @file reads the body from a file and @- from stdin")
+	var async bool
+	flagset.BoolVar(&async, "async", false, "Invoke the LIVE deployment asynchronously through the API and poll for the result, for functions that run longer than the gateways allow")
+
+	var endpoint string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		endpoint, args = args[0], args[1:]
+	}
+	_ = flagset.Parse(args)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	var (
+		url        string
+		endpointID uuid.UUID
+	)
+	switch {
+	case len(deploy) > 0:
+		id, err := uuid.Parse(deploy)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid deploy id given: %s", deploy))
+		}
+		url = fmt.Sprintf("%s/preview/%s%s", config.IngressUrl(), id, path)
+	case len(endpoint) > 0:
+		endpointID = c.resolveEndpoint(endpoint)
+		url = fmt.Sprintf("%s/live/%s%s", config.IngressUrl(), endpointID, path)
+	default:
+		printErrorAndExit(fmt.Errorf("provide an endpoint id or a deployment: raptor invoke <endpoint> | raptor invoke --deploy <deploy-id>"))
+	}
+
+	body, err := readInvokeBody(data)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			printErrorAndExit(fmt.Errorf("headers need to be in the format of --header \"name: value\""))
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if len(deploy) > 0 {
+		req.Header.Set(shared.PreviewLogsHeader, "true")
+	}
+	if async {
+		if len(deploy) > 0 {
+			printErrorAndExit(fmt.Errorf("only the LIVE deployment can be invoked asynchronously"))
+		}
+		c.invokeAsync(endpointID, types.AsyncRequest{
+			Method: method,
+			Path:   path,
+			Header: req.Header,
+			Body:   body,
+		})
+		return
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printInvokeResponse(os.Stdout, resp, b, time.Since(start))
+}
+
+// invokeAsync queues an asynchronous invocation and prints its response once
+// it finished, along with the progress the function reports while it runs.
+func (c command) invokeAsync(endpointID uuid.UUID, r types.AsyncRequest) {
+	invocation, err := c.client.InvokeAsync(endpointID, r)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	progressf("invocation %s %s\n", invocation.ID, invocation.Status)
+	var (
+		status   = invocation.Status
+		progress = invocation.Progress
+		message  = invocation.ProgressMessage
+	)
+	for !invocation.Done() {
+		time.Sleep(invokePollInterval)
+		invocation, err = c.client.GetInvocation(invocation.ID)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		if invocation.Status != status {
+			status = invocation.Status
+			progressf("invocation %s %s\n", invocation.ID, invocation.Status)
+		}
+		if invocation.Progress != progress || invocation.ProgressMessage != message {
+			progress, message = invocation.Progress, invocation.ProgressMessage
+			progressf("invocation %s %d%% %s\n", invocation.ID, progress, message)
+		}
+	}
+	if !humanOutput() {
+		printResult(invocation, invocation.ID.String())
+	} else if invocation.StatusCode > 0 {
+		resp := &http.Response{
+			Status: fmt.Sprintf("%d %s", invocation.StatusCode, http.StatusText(invocation.StatusCode)),
+			Header: invocation.Header,
+		}
+		printInvokeResponse(os.Stdout, resp, invocation.Body, invocation.FinishedAT.Sub(invocation.CreatedAT))
+	}
+	if invocation.Status == types.InvocationFailed {
+		printErrorAndExit(fmt.Errorf("%s, inspect it with: raptor endpoint dead-letters %s", invocation.Error, endpointID))
+	}
+}
+
+func readInvokeBody(data string) ([]byte, error) {
+	switch {
+	case data == "@-":
+		return io.ReadAll(os.Stdin)
+	case strings.HasPrefix(data, "@"):
+		return os.ReadFile(data[1:])
+	}
+	return []byte(data), nil
+}
+
+// printInvokeResponse writes the logs of a PREVIEW invocation, the status,
+// the headers and the body of the response.
+func printInvokeResponse(out io.Writer, resp *http.Response, body []byte, duration time.Duration) {
+	if encoded := resp.Header.Get(shared.PreviewLogsHeader); len(encoded) > 0 {
+		resp.Header.Del(shared.PreviewLogsHeader)
+		if logs, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(logs) > 0 {
+			fmt.Fprintf(out, "--- logs\n%s", logs)
+			if !bytes.HasSuffix(logs, []byte("\n")) {
+				fmt.Fprintln(out)
+			}
+		}
+	}
+	fmt.Fprintf(out, "--- %s in %s\n", resp.Status, duration.Round(time.Microsecond))
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range resp.Header[name] {
+			fmt.Fprintf(out, "%s: %s\n", name, value)
+		}
+	}
+	fmt.Fprintf(out, "\n%s\n", body)
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/user"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anthdm/raptor/internal/admin"
 	"github.com/anthdm/raptor/internal/api"
 	"github.com/anthdm/raptor/internal/client"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/internal/version"
 	"github.com/google/uuid"
@@ -21,14 +28,47 @@ func printUsage() {
 	fmt.Printf(`
 Raptor cli v%s
 
-Usage: raptor COMMAND
+Usage: raptor [--profile name] [-o table|wide|json|quiet] COMMAND
 
 Commands:
-  endpoint			Create a new endpoint
-  publish			Publish a deployment to an endpoint
-  deploy			Create a new deployment
+  login				Store the API url and the API key of a cluster as a named profile (--profile staging)
+  profile			List the profiles or switch the current profile (use)
+  init				Generate a function project from a template (--template go, rust or js)
+  endpoint			Create a new endpoint, list (list), describe (describe), update (update), roll it back (rollback), show or abort its rollout (rollout), transfer it to another owner (transfer), show its history (history), manage its webhooks (webhook), show its events (events), its invocation errors (errors), manage its failed asynchronous invocations (dead-letters), export its spec as YAML (export) or take it offline for maintenance (disable, enable)
+  apply				Create or update the endpoints of a YAML spec file (-f endpoints.yaml, --dry-run)
+  publish			Publish a deployment to an endpoint, gradually with an automatic rollback (--guarded)
+  deploy			Create a new deployment, list (list) or prune the old deployments of an endpoint (prune), generate a signing key (keygen)
+  recommend			Recommend endpoint settings based on its usage
+  snapshot			Export a snapshot of the metrics of an endpoint
+  metrics			Show the request metrics of an endpoint per minute or hour (--resolution 1m or 1h)
+  concurrency			Show the in-flight and queued invocations of an endpoint per ingress, with their peaks (--window 15m)
+  rotate-key			Rotate the data encryption key of an endpoint
+  env-drift			Show the environment changes of an endpoint since its last publish
+  cache				Configure or purge the response cache of an endpoint
+  cors				Set the CORS policy of an endpoint
+  verify			Verify the HMAC signature of the requests of an endpoint at the ingress (--secret-env)
+  jwt				Validate the JSON Web Token of the requests of an endpoint at the ingress (--issuer, --jwks-url)
+  prewarm			Keep runtimes of an endpoint warm, always (--min-instances) or on a schedule (--schedule "mon-fri 08:45-10:00 5")
+  admin				Inspect or upgrade the members of the cluster (status, upgrade), back up the endpoints (backup --out), restore a backup (restore <file>) or re-wrap the data keys after a master key rotation (rotate-master-key)
+  usage				Show the usage of the projects this month against their quotas (--project)
+  project-env			Show or update the default environment the endpoints of a project inherit (--env, --env-file, --unset, --replace-env)
+  audit				Show the audit log of the management API calls (--actor, --endpoint, --since, --limit)
+  platform			Show the platform status (status) or manage its incident banners (incident, resolve)
+  dev				Serve a local module through a local ingress and reload it when it changes
+  invoke			Send a single request to an endpoint, or to a deployment in preview (--deploy), and print the response
+  schedule			Schedule a one-shot invocation of an endpoint (create --at or --in), list its scheduled invocations (list) or cancel a pending one (cancel)
+  workflow			Start a workflow of an endpoint (start), made of steps that checkpoint their state and sleep between them, or show a workflow with its state (get)
+  bench				Load test an endpoint with concurrent requests (-n, -c, --duration) and report latency percentiles, cold starts and errors, compared to a deployment in preview (--compare)
+  replay			Send a captured request again to the LIVE deployment, a deployment in preview (--deploy) or a local module (--local), list the captured requests of an endpoint (list)
+  shell				Send every line of an interactive prompt as a request to an endpoint or a local module
+  completion			Print the completion script of a shell (bash, zsh or fish)
+  config			Validate the config file (validate), with the environment variable overrides applied, or show the effective config of the API server (show)
   help				Show usage
 
+Output:
+  -o, --output			Print lists as aligned tables (table, the default), with more columns (wide), as JSON (json) or only their ids (quiet)
+  -q, --quiet			Only print the ids of the results, like -o quiet
+
 `, version.Version)
 	os.Exit(0)
 }
@@ -49,9 +89,15 @@ func main() {
 
 	var configFile string
 	flagset.StringVar(&configFile, "config", "config.toml", "The location of your raptor config file")
+	var profileName string
+	flagset.StringVar(&profileName, "profile", "", "The profile of ~/.raptor/config to use, the current profile by default")
 
 	flagset.Usage = printUsage
-	flagset.Parse(os.Args[1:])
+	cliArgs, err := parseOutputFlags(os.Args[1:])
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	flagset.Parse(cliArgs)
 
 	if err := config.Parse(configFile); err != nil {
 		printErrorAndExit(err)
@@ -62,23 +108,146 @@ func main() {
 		printUsage()
 	}
 
-	c := client.New(client.NewConfig().WithURL(config.ApiUrl()))
 	command := command{
-		client: c,
+		profileName: profileName,
+	}
+	switch args[0] {
+	case completeCommand:
+		command.handleComplete(args[1:])
+		return
+	case "login", "profile":
+		// The login creates the profile, it does not need to exist yet.
+	default:
+		// Validating a config does not call the API.
+		if args[0] == "config" && len(args) > 1 && args[1] == "validate" {
+			break
+		}
+		if err := command.useProfile(profileName); err != nil {
+			printErrorAndExit(err)
+		}
 	}
 
 	switch args[0] {
+	case "init":
+		command.handleInit(args[1:])
+	case "login":
+		command.handleLogin(args[1:])
+	case "profile":
+		command.handleProfile(args[1:])
 	case "publish":
 		command.handlePublish(args[1:])
 	case "endpoint":
 		command.handleEndpoint(args[1:])
+	case "apply":
+		command.handleApply(args[1:])
 	case "deploy":
 		command.handleDeploy(args[1:])
+	case "recommend":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleRecommend(args[1:])
+	case "snapshot":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleSnapshot(args[1:])
+	case "metrics":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleMetrics(args[1:])
+	case "concurrency":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleConcurrency(args[1:])
+	case "rotate-key":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleRotateKey(args[1:])
+	case "env-drift":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleEnvDrift(args[1:])
+	case "cache":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleCache(args[1:])
+	case "cors":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleCORS(args[1:])
+	case "verify":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleVerify(args[1:])
+	case "jwt":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleJWT(args[1:])
+	case "prewarm":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handlePrewarm(args[1:])
+	case "admin":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleAdmin(args[1:])
+	case "config":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleConfig(args[1:])
+	case "shell":
+		command.handleShell(args[1:])
+	case "invoke":
+		command.handleInvoke(args[1:])
+	case "schedule":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleSchedule(args[1:])
+	case "workflow":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleWorkflow(args[1:])
+	case "bench":
+		command.handleBench(args[1:])
+	case "replay":
+		command.handleReplay(args[1:])
+	case "dev":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handleDev(args[1:])
+	case "audit":
+		command.handleAudit(args[1:])
+	case "usage":
+		command.handleUsage(args[1:])
+	case "project-env":
+		command.handleProjectEnv(args[1:])
+	case "platform":
+		if len(args) < 2 {
+			printUsage()
+		}
+		command.handlePlatform(args[1:])
 	case "serve":
 		if len(args) < 2 {
 			printUsage()
 		}
 		command.handleServeEndpoint(args[1:])
+	case "completion":
+		command.handleCompletion(args[1:])
 	case "help":
 		printUsage()
 	default:
@@ -88,6 +257,10 @@ func main() {
 
 type command struct {
 	client *client.Client
+	// Profile given with --profile, empty for the current profile.
+	profileName string
+	// Owner of the created endpoints, the project of the profile.
+	defaultOwner string
 }
 
 func (c command) handlePublish(args []string) {
@@ -95,6 +268,16 @@ func (c command) handlePublish(args []string) {
 
 	var deployID string
 	flagset.StringVar(&deployID, "deploy", "", "The id of the deployment that you want to publish LIVE")
+	var guarded bool
+	flagset.BoolVar(&guarded, "guarded", false, "Shift the traffic to the deployment gradually and roll it back when it breaches the thresholds")
+	var steps string
+	flagset.StringVar(&steps, "steps", "", "The percentages of the traffic of the guarded steps, like 10,25,50,100")
+	var stepInterval time.Duration
+	flagset.DurationVar(&stepInterval, "step-interval", 0, "How long every guarded step lasts (default 1m)")
+	var maxErrorRate float64
+	flagset.Float64Var(&maxErrorRate, "max-error-rate", 0, "The rate of 5xx responses between 0 and 1 that rolls a guarded publish back (default 0.05)")
+	var maxP95 time.Duration
+	flagset.DurationVar(&maxP95, "max-p95", 0, "The p95 latency that rolls a guarded publish back")
 	_ = flagset.Parse(args)
 
 	id, err := uuid.Parse(deployID)
@@ -103,18 +286,82 @@ func (c command) handlePublish(args []string) {
 	}
 
 	params := api.PublishParams{DeploymentID: id}
+	if guarded {
+		params.Rollout = &types.RolloutPolicy{
+			StepInterval:   int(stepInterval.Milliseconds()),
+			MaxErrorRate:   maxErrorRate,
+			MaxP95Duration: int(maxP95.Milliseconds()),
+		}
+		for _, step := range strings.Split(steps, ",") {
+			if len(step) == 0 {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(step), "%"))
+			if err != nil {
+				printErrorAndExit(fmt.Errorf("invalid step given: %s", step))
+			}
+			params.Rollout.Steps = append(params.Rollout.Steps, n)
+		}
+	}
 	resp, err := c.client.Publish(params)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err := json.MarshalIndent(resp, "", "    ")
-	if err != nil {
-		printErrorAndExit(err)
+	printResult(resp, resp.DeploymentID.String())
+	if resp.Rollout != nil && humanOutput() {
+		fmt.Println()
+		fmt.Printf("rollout %s started at %d%% of the traffic, follow it with: raptor endpoint rollout %s\n",
+			resp.Rollout.ID, resp.Rollout.Weight(), resp.Rollout.EndpointID)
 	}
-	fmt.Println(string(b))
 }
 
 func (c command) handleEndpoint(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "rollback":
+			c.handleRollback(args[1:])
+			return
+		case "history":
+			c.handleHistory(args[1:])
+			return
+		case "transfer":
+			c.handleTransfer(args[1:])
+			return
+		case "update":
+			c.handleUpdateEndpoint(args[1:])
+			return
+		case "list":
+			c.handleListEndpoints(args[1:])
+			return
+		case "describe":
+			c.handleDescribeEndpoint(args[1:])
+			return
+		case "rollout":
+			c.handleRollout(args[1:])
+			return
+		case "webhook":
+			c.handleWebhook(args[1:])
+			return
+		case "events":
+			c.handleEvents(args[1:])
+			return
+		case "errors":
+			c.handleErrors(args[1:])
+			return
+		case "dead-letters":
+			c.handleDeadLetters(args[1:])
+			return
+		case "export":
+			c.handleExportEndpoint(args[1:])
+			return
+		case "disable":
+			c.handleMaintenance(args[1:], true)
+			return
+		case "enable":
+			c.handleMaintenance(args[1:], false)
+			return
+		}
+	}
 	flagset := flag.NewFlagSet("endpoint", flag.ExitOnError)
 
 	var name string
@@ -123,8 +370,37 @@ func (c command) handleEndpoint(args []string) {
 	flagset.StringVar(&runtime, "runtime", "", "The runtime of your endpoint (go or js)")
 	var env stringList
 	flagset.Var(&env, "env", "Environment variables for this endpoint")
+	var envFiles stringList
+	flagset.Var(&envFiles, "env-file", "Dotenv file with environment variables for this endpoint, the --env flags take precedence")
+	var owner string
+	flagset.StringVar(&owner, "owner", c.defaultOwner, "The team or project that owns the endpoint, the project of the profile by default")
 	_ = flagset.Parse(args)
 
+	project, err := loadProject()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(name) == 0 {
+		name = project.Endpoint.Name
+	}
+	if len(runtime) == 0 {
+		runtime = project.Endpoint.Runtime
+	}
+	environment := project.Endpoint.Environment
+	if environment == nil {
+		environment = map[string]string{}
+	}
+	fileEnv, err := readEnvFiles(envFiles)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	for k, v := range fileEnv {
+		environment[k] = v
+	}
+	for k, v := range makeEnvMap(env) {
+		environment[k] = v
+	}
+
 	if len(runtime) == 0 {
 		fmt.Println("please provide a valid runtime [--runtime go, --runtime js]")
 		os.Exit(1)
@@ -140,47 +416,1282 @@ func (c command) handleEndpoint(args []string) {
 	params := api.CreateEndpointParams{
 		Runtime:     runtime,
 		Name:        name,
-		Environment: makeEnvMap(env),
+		Environment: environment,
+		Owner:       owner,
 	}
 	endpoint, err := c.client.CreateEndpoint(params)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err := json.MarshalIndent(endpoint, "", "    ")
+	printResult(endpoint, endpoint.ID.String())
+}
+
+func (c command) handleListEndpoints(args []string) {
+	flagset := flag.NewFlagSet("list", flag.ExitOnError)
+
+	var name string
+	flagset.StringVar(&name, "name", "", "Only list the endpoints with the name")
+	_ = flagset.Parse(args)
+
+	endpoints, err := c.client.ListEndpoints(name)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	fmt.Println(string(b))
+	t := newTable(
+		column{header: "id"},
+		column{header: "name"},
+		column{header: "runtime"},
+		column{header: "live deployment"},
+		column{header: "owner", wide: true},
+		column{header: "regions", wide: true},
+		column{header: "created"},
+	)
+	for _, endpoint := range endpoints {
+		var live, owner string
+		if endpoint.ActiveDeploymentID != uuid.Nil {
+			live = endpoint.ActiveDeploymentID.String()
+		}
+		if endpoint.Ownership != nil {
+			owner = endpoint.Ownership.Owner
+		}
+		t.add(endpoint.ID.String(), endpoint.Name, endpoint.Runtime, live, owner,
+			strings.Join(endpoint.Regions, ","), endpoint.CreatedAT.Format(time.RFC3339))
+	}
+	printList(endpoints, t)
+}
+
+// handleUpdateEndpoint renames the endpoint, changes its runtime or updates
+// its environment.
+func (c command) handleUpdateEndpoint(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("update", flag.ExitOnError)
+	var name string
+	flagset.StringVar(&name, "name", "", "The new name of the endpoint")
+	var runtime string
+	flagset.StringVar(&runtime, "runtime", "", "The new runtime of the endpoint (go or js), only without a LIVE deployment")
+	var env stringList
+	flagset.Var(&env, "env", "Environment variables to set (--env foo=bar)")
+	var envFiles stringList
+	flagset.Var(&envFiles, "env-file", "Dotenv file with environment variables to set, the --env flags take precedence")
+	var unset stringList
+	flagset.Var(&unset, "unset", "Environment variables to delete (--unset foo)")
+	var replace bool
+	flagset.BoolVar(&replace, "replace-env", false, "Replace the whole environment instead of merging the given variables")
+	_ = flagset.Parse(args[1:])
+
+	params := api.UpdateEndpointParams{
+		Name:    name,
+		Runtime: runtime,
+	}
+	fileEnv, err := readEnvFiles(envFiles)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(env) > 0 || len(unset) > 0 || len(fileEnv) > 0 {
+		params.Environment = make(map[string]*string, len(fileEnv)+len(env)+len(unset))
+		for k, v := range fileEnv {
+			v := v
+			params.Environment[k] = &v
+		}
+		for k, v := range makeEnvMap(env) {
+			v := v
+			params.Environment[k] = &v
+		}
+		for _, k := range unset {
+			params.Environment[k] = nil
+		}
+	}
+	if replace {
+		params.EnvironmentMode = api.EnvironmentReplace
+	}
+	if err := c.client.UpdateEndpoint(id, params); err != nil {
+		printErrorAndExit(err)
+	}
+	endpoint, err := c.client.GetEndpoint(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(endpoint, endpoint.ID.String())
+}
+
+// handleRollback rolls the endpoint back to the given deployment, or to the
+// deployment that was LIVE before the current one.
+func (c command) handleRollback(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	var params api.RollbackParams
+	if len(args) > 1 {
+		var err error
+		params.DeploymentID, err = uuid.Parse(args[1])
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid deploy id given: %s", args[1]))
+		}
+	}
+	resp, err := c.client.Rollback(id, params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(resp, resp.DeploymentID.String())
+}
+
+// handleRollout shows the latest rollout of the endpoint, or aborts the
+// running rollout.
+func (c command) handleRollout(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	var (
+		rollout *types.Rollout
+		err     error
+	)
+	if len(args) > 1 && args[1] == "abort" {
+		rollout, err = c.client.AbortRollout(id)
+	} else {
+		rollout, err = c.client.GetRollout(id)
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if !humanOutput() {
+		printResult(rollout, rollout.ID.String())
+		return
+	}
+	fmt.Printf("rollout %s of deployment %s: %s\n", rollout.ID, rollout.DeploymentID, rollout.Status)
+	for i, step := range rollout.Steps {
+		marker := " "
+		if i == rollout.Step && rollout.Running() {
+			marker = ">"
+		}
+		fmt.Printf("  %s %d%%\n", marker, step)
+	}
+	if len(rollout.Reason) > 0 {
+		fmt.Printf("reason: %s\n", rollout.Reason)
+	}
+}
+
+// handleTransfer requests the transfer of the endpoint to another owner,
+// or accepts the pending transfer on behalf of the receiving owner.
+func (c command) handleTransfer(args []string) {
+	accept := len(args) > 0 && args[0] == "accept"
+	if accept {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	var (
+		endpoint *types.Endpoint
+		err      error
+	)
+	if accept {
+		endpoint, err = c.client.AcceptTransfer(id, api.AcceptTransferParams{Owner: args[1]})
+	} else {
+		endpoint, err = c.client.Transfer(id, api.TransferParams{To: args[1]})
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(endpoint.Ownership, endpoint.ID.String())
+}
+
+// handleMaintenance takes the endpoint offline, the ingress answers its
+// LIVE requests with a 503 until it is enabled again.
+func (c command) handleMaintenance(args []string, disable bool) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("disable", flag.ExitOnError)
+	var (
+		message    string
+		retryAfter time.Duration
+	)
+	flagset.StringVar(&message, "message", "", "Message the LIVE requests are answered with, defaults to \""+types.DefaultMaintenanceMessage+"\"")
+	flagset.DurationVar(&retryAfter, "retry-after", 0, "Time the clients are asked to wait before retrying")
+	_ = flagset.Parse(args[1:])
+
+	maintenance := &types.Maintenance{}
+	if disable {
+		maintenance = &types.Maintenance{
+			Disabled:   true,
+			Message:    message,
+			RetryAfter: int(retryAfter / time.Second),
+		}
+	}
+	if err := maintenance.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{Maintenance: maintenance}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(maintenance, id.String())
+}
+
+func (c command) handleHistory(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	history, err := c.client.GetHistory(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "id"},
+		column{header: "created"},
+		column{header: "kind"},
+		column{header: "change"},
+		column{header: "previous", wide: true},
+		column{header: "actor"},
+		column{header: "reason", wide: true},
+	)
+	for _, event := range history {
+		change, previous := event.DeploymentID.String(), event.PreviousDeploymentID.String()
+		if len(event.To) > 0 {
+			change, previous = fmt.Sprintf("%q -> %q", event.From, event.To), ""
+		} else if event.PreviousDeploymentID == uuid.Nil {
+			previous = ""
+		}
+		if event.Weight > 0 {
+			change = fmt.Sprintf("%s (%d%%)", change, event.Weight)
+		}
+		t.add(event.ID.String(), event.CreatedAT.Format(time.RFC3339), event.Kind, change, previous, event.Actor, event.Reason)
+	}
+	printList(history, t)
+}
+
+// handleWebhook lists the webhooks of the endpoint, registers a webhook
+// (add <url>) or deletes one (delete <webhook id>).
+func (c command) handleWebhook(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	if len(args) == 1 || args[1] == "list" {
+		webhooks, err := c.client.GetWebhooks(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		t := newTable(
+			column{header: "id"},
+			column{header: "url"},
+			column{header: "events"},
+			column{header: "created", wide: true},
+		)
+		for _, webhook := range webhooks {
+			events := "all"
+			if len(webhook.Events) > 0 {
+				events = strings.Join(webhook.Events, ",")
+			}
+			t.add(webhook.ID.String(), webhook.URL, events, webhook.CreatedAT.Format(time.RFC3339))
+		}
+		printList(webhooks, t)
+		return
+	}
+	switch args[1] {
+	case "add":
+		if len(args) < 3 {
+			printUsage()
+		}
+		flagset := flag.NewFlagSet("webhook", flag.ExitOnError)
+		var events stringList
+		flagset.Var(&events, "event", fmt.Sprintf("Type of the events posted to the webhook, all of them by default (%s)", strings.Join(types.EventTypes, ", ")))
+		var secret string
+		flagset.StringVar(&secret, "secret", "", "The key the deliveries are signed with, generated by default")
+		_ = flagset.Parse(args[3:])
+
+		webhook, err := c.client.CreateWebhook(id, api.CreateWebhookParams{
+			URL:    args[2],
+			Events: events,
+			Secret: secret,
+		})
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(webhook, webhook.ID.String())
+		if humanOutput() {
+			fmt.Println()
+			fmt.Println("keep the secret, the signatures of the deliveries are verified with it and it is not shown again")
+		}
+	case "delete":
+		if len(args) < 3 {
+			printUsage()
+		}
+		webhookID, err := uuid.Parse(args[2])
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid webhook id given: %s", args[2]))
+		}
+		if err := c.client.DeleteWebhook(id, webhookID); err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(map[string]string{"deleted": webhookID.String()}, webhookID.String())
+	default:
+		printUsage()
+	}
+}
+
+func (c command) handleEvents(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	events, err := c.client.GetEvents(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "id", wide: true},
+		column{header: "created"},
+		column{header: "type"},
+		column{header: "deployment"},
+		column{header: "actor", wide: true},
+		column{header: "message"},
+	)
+	for _, event := range events {
+		deployment := event.DeploymentID.String()
+		if event.DeploymentID == uuid.Nil {
+			deployment = ""
+		}
+		t.add(event.ID.String(), event.CreatedAT.Format(time.RFC3339), event.Type, deployment, event.Actor, event.Message)
+	}
+	printList(events, t)
+}
+
+func (c command) handleErrors(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("errors", flag.ExitOnError)
+	var deployment string
+	flagset.StringVar(&deployment, "deployment", "", "Only show the errors of this deployment")
+	var window time.Duration
+	flagset.DurationVar(&window, "window", 0, "Only show the errors of this window, like 24h")
+	_ = flagset.Parse(args[1:])
+
+	var deploymentID uuid.UUID
+	if len(deployment) > 0 {
+		var err error
+		deploymentID, err = uuid.Parse(deployment)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid deployment id given: %s", deployment))
+		}
+	}
+	groups, err := c.client.GetErrors(id, deploymentID, window)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "signature"},
+		column{header: "kind"},
+		column{header: "count"},
+		column{header: "deployments", wide: true},
+		column{header: "first seen", wide: true},
+		column{header: "last seen"},
+		column{header: "reason"},
+	)
+	for _, group := range groups {
+		t.add(group.Signature, group.Kind, strconv.Itoa(group.Count), strconv.Itoa(len(group.Deployments)),
+			group.FirstSeen.Format(time.RFC3339), group.LastSeen.Format(time.RFC3339), group.Reason)
+	}
+	printList(groups, t)
+}
+
+func (c command) handleDeadLetters(args []string) {
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	if len(args) == 1 || args[1] == "list" {
+		letters, err := c.client.GetDeadLetters(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		t := newTable(
+			column{header: "id"},
+			column{header: "source", wide: true},
+			column{header: "request"},
+			column{header: "attempts"},
+			column{header: "status"},
+			column{header: "created", wide: true},
+			column{header: "error"},
+		)
+		for _, letter := range letters {
+			t.add(letter.ID.String(), letter.Source, letter.Request.Method+" "+letter.Request.Path,
+				strconv.Itoa(letter.Attempts), strconv.Itoa(letter.StatusCode),
+				letter.CreatedAT.Format(time.RFC3339), letter.Error)
+		}
+		printList(letters, t)
+		return
+	}
+	if len(args) < 3 {
+		printUsage()
+	}
+	letterID, err := uuid.Parse(args[2])
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid dead letter id given: %s", args[2]))
+	}
+	switch args[1] {
+	case "redrive":
+		if err := c.client.RedriveDeadLetter(id, letterID); err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(map[string]string{"redriven": letterID.String()}, letterID.String())
+	case "delete":
+		if err := c.client.DeleteDeadLetter(id, letterID); err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(map[string]string{"deleted": letterID.String()}, letterID.String())
+	default:
+		printUsage()
+	}
 }
 
 func (c command) handleDeploy(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "prune":
+			c.handlePrune(args[1:])
+			return
+		case "list":
+			c.handleListDeployments(args[1:])
+			return
+		case "keygen":
+			c.handleKeygen(args[1:])
+			return
+		}
+	}
 	flagset := flag.NewFlagSet("deploy", flag.ExitOnError)
 
-	var endpointID string
-	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint to where you want to deploy")
+	var endpoint string
+	flagset.StringVar(&endpoint, "endpoint", "", "The id or the name of the endpoint to where you want to deploy, the endpoint of the raptor.toml by default")
 	var file string
 	flagset.StringVar(&file, "file", "", "The file location of your code that you want to deploy")
+	var assetsFile string
+	flagset.StringVar(&assetsFile, "assets", "", "The file location of a zip archive with static assets served along with your code")
+	var noDedupe bool
+	flagset.BoolVar(&noDedupe, "no-dedupe", false, "Create a new deployment even when the endpoint already has an identical one")
+	var labels stringList
+	flagset.Var(&labels, "label", "Labels of the deployment (--label env=staging)")
+	var message string
+	flagset.StringVar(&message, "message", "", "Description of the deployment")
+	var commit string
+	flagset.StringVar(&commit, "commit", "", "Git commit the deployment was built from")
+	var branch string
+	flagset.StringVar(&branch, "branch", "", "Git branch the deployment was built from")
+	var signKey string
+	flagset.StringVar(&signKey, "sign-key", "", "The file location of the private key the deployment is signed with")
+	var source string
+	flagset.StringVar(&source, "source", "", "Directory or zip archive with the sources that are built to WASM by the platform")
+	var language string
+	flagset.StringVar(&language, "language", "", "The language of the sources (go, tinygo or rust), detected from the sources by default")
+	var digest string
+	flagset.StringVar(&digest, "digest", "", "The sha256:<hex> digest the pulled module needs to match")
+	var watch bool
+	flagset.BoolVar(&watch, "watch", false, "Deploy and publish the file again whenever it changes")
+	var publish bool
+	flagset.BoolVar(&publish, "publish", false, "Publish the deployment LIVE in the same call, unless it is LIVE already")
+	var opts watchOptions
+	flagset.StringVar(&opts.build, "build", "", "Command building the file, with --watch the sources in the current directory are watched and built")
+	flagset.DurationVar(&opts.debounce, "debounce", time.Millisecond*500, "Time a change needs to be stable for before it is deployed with --watch")
+
+	// raptor deploy [endpoint] [file | oci://registry/repository:tag | https://url]
+	var artifact string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch {
+		case isArtifactRef(args[0]):
+			artifact = args[0]
+		case len(endpoint) == 0:
+			endpoint = args[0]
+		default:
+			file = args[0]
+		}
+		args = args[1:]
+	}
 	_ = flagset.Parse(args)
 
-	id, err := uuid.Parse(endpointID)
-	if err != nil {
-		printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", args[0]))
+	// Without an endpoint the endpoint of the raptor.toml is created or
+	// updated first.
+	if len(endpoint) == 0 {
+		project, err := loadProject()
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		if len(project.Endpoint.Name) == 0 {
+			printErrorAndExit(fmt.Errorf("provide the id or the name of the endpoint: raptor deploy <endpoint> <file>"))
+		}
+		endpoint = c.syncProject(project).ID.String()
+	}
+	if publish && (len(artifact) > 0 || len(source) > 0 || watch) {
+		printErrorAndExit(fmt.Errorf("--publish only publishes deployments of a file"))
+	}
+	id := c.resolveEndpoint(endpoint)
+	var err error
+	metadata := types.DeploymentMetadata{
+		Labels:      makeLabelMap(labels),
+		GitCommit:   strings.ToLower(commit),
+		GitBranch:   branch,
+		Description: message,
+	}
+	if len(artifact) > 0 {
+		c.pullDeployment(id, api.PullDeploymentParams{
+			Source:   artifact,
+			Digest:   digest,
+			Metadata: metadata,
+			NoDedupe: noDedupe,
+		}, signKey)
+		return
+	}
+	if len(source) > 0 {
+		params := api.CreateBuildParams{Language: language, Metadata: metadata}
+		if len(assetsFile) > 0 {
+			params.Assets, err = os.ReadFile(assetsFile)
+			if err != nil {
+				printErrorAndExit(err)
+			}
+		}
+		c.deploySource(id, source, params)
+		return
+	}
+	if len(file) == 0 {
+		project, err := loadProject()
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		file = project.Endpoint.File
+	}
+	params := api.CreateDeploymentParams{
+		NoDedupe: noDedupe,
+		Metadata: metadata,
+	}
+	if len(assetsFile) > 0 {
+		params.Assets, err = os.ReadFile(assetsFile)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+	}
+	if watch {
+		c.watchDeploy(id, file, params, signKey, opts)
+		return
+	}
+	if len(opts.build) > 0 {
+		cmd := exec.Command("sh", "-c", opts.build)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			printErrorAndExit(fmt.Errorf("build failed: %s", err))
+		}
 	}
 	b, err := os.ReadFile(file)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	deploy, err := c.client.CreateDeployment(id, bytes.NewReader(b), api.CreateDeploymentParams{})
+	if publish {
+		c.release(id, b, params, signKey)
+		return
+	}
+	deploy, err := c.createDeployment(id, b, params, signKey)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err = json.MarshalIndent(deploy, "", "    ")
+	printResult(deploy, deploy.ID.String())
+	if humanOutput() {
+		fmt.Println()
+		fmt.Printf("deploy preview: %s\n", deploy.PreviewURL)
+	}
+}
+
+// handleKeygen writes a new private key deployments can be signed with and
+// prints the public key the operator trusts in the signing config.
+func (c command) handleKeygen(args []string) {
+	flagset := flag.NewFlagSet("keygen", flag.ExitOnError)
+
+	var out string
+	flagset.StringVar(&out, "out", "raptor.key", "The file location the private key is written to")
+	_ = flagset.Parse(args)
+
+	publicKey, privateKey, err := signing.GenerateKey()
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	fmt.Println(string(b))
-	fmt.Println()
-	fmt.Printf("deploy preview: %s/preview/%s\n", config.IngressUrl(), deploy.ID)
+	if err := os.WriteFile(out, []byte(privateKey+"\n"), 0600); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("private key written to %s\n", out)
+	fmt.Printf("public key: %s\n", publicKey)
+}
+
+func (c command) handleListDeployments(args []string) {
+	flagset := flag.NewFlagSet("list", flag.ExitOnError)
+
+	var labels stringList
+	flagset.Var(&labels, "label", "Only list the deployments with the label (--label env=staging)")
+	var commit string
+	flagset.StringVar(&commit, "commit", "", "Only list the deployments built from the commit")
+	var branch string
+	flagset.StringVar(&branch, "branch", "", "Only list the deployments built from the branch")
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	_ = flagset.Parse(args[1:])
+
+	filter := types.DeploymentFilter{
+		Labels:    makeLabelMap(labels),
+		GitCommit: commit,
+		GitBranch: branch,
+	}
+	deploys, err := c.client.GetDeployments(id, filter)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "id"},
+		column{header: "hash"},
+		column{header: "branch"},
+		column{header: "commit"},
+		column{header: "description", wide: true},
+		column{header: "labels", wide: true},
+		column{header: "preview", wide: true},
+		column{header: "created"},
+	)
+	for _, deploy := range deploys {
+		t.add(deploy.ID.String(), shortHash(deploy.Hash), deploy.GitBranch, shortHash(deploy.GitCommit),
+			deploy.Description, formatLabels(deploy.Labels), deploy.PreviewURL, deploy.CreatedAT.Format(time.RFC3339))
+	}
+	printList(deploys, t)
+}
+
+// handlePrune deletes the deployments of the endpoint that are not kept by
+// its retention policy, or by the retention given with the flags.
+func (c command) handlePrune(args []string) {
+	flagset := flag.NewFlagSet("prune", flag.ExitOnError)
+
+	var keepLast int
+	flagset.IntVar(&keepLast, "keep-last", 0, "Keep the given amount of most recent deployments")
+	var keepDays int
+	flagset.IntVar(&keepDays, "keep-days", 0, "Keep the deployments created within the given amount of days")
+	var dryRun bool
+	flagset.BoolVar(&dryRun, "dry-run", false, "Only show the deployments that would be deleted")
+	if len(args) == 0 {
+		printUsage()
+	}
+	id := c.resolveEndpoint(args[0])
+	_ = flagset.Parse(args[1:])
+
+	params := api.PruneParams{DryRun: dryRun}
+	if keepLast > 0 || keepDays > 0 {
+		params.Retention = &types.RetentionPolicy{KeepLast: keepLast, KeepDays: keepDays}
+	}
+	resp, err := c.client.PruneDeployments(id, params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	status := "deleted"
+	if resp.DryRun {
+		status = "would delete"
+	}
+	t := newTable(
+		column{header: "id"},
+		column{header: "status"},
+		column{header: "hash", wide: true},
+		column{header: "created"},
+	)
+	for _, deploy := range resp.Pruned {
+		t.add(deploy.ID.String(), status, shortHash(deploy.Hash), deploy.CreatedAT.Format(time.RFC3339))
+	}
+	printList(resp, t)
+}
+
+func (c command) handleRecommend(args []string) {
+	id := c.resolveEndpoint(args[0])
+	rec, err := c.client.GetRecommendation(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(rec, id.String())
+}
+
+func (c command) handleSnapshot(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	var from, to, out string
+	flagset.StringVar(&from, "from", "", "The start of the window in RFC 3339 format, defaults to 24 hours before the end")
+	flagset.StringVar(&to, "to", "", "The end of the window in RFC 3339 format, defaults to now")
+	flagset.StringVar(&out, "out", "", "The file the snapshot is written to, defaults to stdout")
+	_ = flagset.Parse(args[1:])
+
+	var err error
+	end := time.Now()
+	if len(to) > 0 {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			printErrorAndExit(fmt.Errorf("invalid end given: %s", to))
+		}
+	}
+	start := end.Add(-time.Hour * 24)
+	if len(from) > 0 {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			printErrorAndExit(fmt.Errorf("invalid start given: %s", from))
+		}
+	}
+	snapshot, err := c.client.GetSnapshot(id, start, end)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	b, err := json.MarshalIndent(snapshot, "", "    ")
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(out) == 0 {
+		fmt.Println(string(b))
+		return
+	}
+	if err := os.WriteFile(out, b, 0644); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("snapshot of %d requests and %d probe results written to %s\n",
+		len(snapshot.RequestMetrics), len(snapshot.ProbeResults), out)
+}
+
+func (c command) handleMetrics(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("metrics", flag.ExitOnError)
+	var from, to, resolution string
+	flagset.StringVar(&from, "from", "", "The start of the window in RFC 3339 format, defaults to 24 hours before the end")
+	flagset.StringVar(&to, "to", "", "The end of the window in RFC 3339 format, defaults to now")
+	flagset.StringVar(&resolution, "resolution", "", "The resolution of the metrics (1m or 1h), defaults to 1h for windows longer than a day")
+	_ = flagset.Parse(args[1:])
+
+	var err error
+	end := time.Now()
+	if len(to) > 0 {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			printErrorAndExit(fmt.Errorf("invalid end given: %s", to))
+		}
+	}
+	start := end.Add(-time.Hour * 24)
+	if len(from) > 0 {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			printErrorAndExit(fmt.Errorf("invalid start given: %s", from))
+		}
+	}
+	rollups, err := c.client.GetMetricRollups(id, resolution, start, end)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "start"},
+		column{header: "requests"},
+		column{header: "errors"},
+		column{header: "avg"},
+		column{header: "p50", wide: true},
+		column{header: "p99"},
+		column{header: "max", wide: true},
+	)
+	for _, rollup := range rollups {
+		var avg time.Duration
+		if rollup.Requests > 0 {
+			avg = rollup.DurationSum / time.Duration(rollup.Requests)
+		}
+		t.add(rollup.Start.Format(time.RFC3339), strconv.FormatInt(rollup.Requests, 10), strconv.FormatInt(rollup.Errors, 10),
+			avg.String(), rollup.Percentile(0.5).String(), rollup.Percentile(0.99).String(), rollup.DurationMax.String())
+	}
+	printList(rollups, t)
+}
+
+func (c command) handleConcurrency(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("concurrency", flag.ExitOnError)
+	var window time.Duration
+	flagset.DurationVar(&window, "window", 0, "The window of the peaks, like 1h, defaults to 5m")
+	_ = flagset.Parse(args[1:])
+
+	stats, err := c.client.GetConcurrency(id, window)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "member"},
+		column{header: "inflight"},
+		column{header: "queued"},
+		column{header: "limit"},
+		column{header: "sampled", wide: true},
+	)
+	var limit int
+	for _, m := range stats.Members {
+		limit += m.Limit
+		t.add(m.Member, strconv.Itoa(m.Inflight), strconv.Itoa(m.Queued), strconv.Itoa(m.Limit), m.CreatedAT.Format(time.RFC3339))
+	}
+	t.add("total", strconv.Itoa(stats.Inflight), strconv.Itoa(stats.Queued), strconv.Itoa(limit), "")
+	t.add("peak", strconv.Itoa(stats.PeakInflight), strconv.Itoa(stats.PeakQueued), "", stats.Since.Format(time.RFC3339))
+	printList(stats, t)
+}
+
+func (c command) handleRotateKey(args []string) {
+	id := c.resolveEndpoint(args[0])
+	resp, err := c.client.RotateEndpointKey(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("endpoint %s is now encrypted with data key version %d\n", id, resp.Version)
+}
+
+func (c command) handleEnvDrift(args []string) {
+	id := c.resolveEndpoint(args[0])
+	drift, err := c.client.GetEnvironmentDrift(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if !drift.Drifted {
+		fmt.Printf("environment of endpoint %s did not change since deployment %s was published\n", id, drift.DeploymentID)
+		return
+	}
+	fmt.Printf("environment of endpoint %s changed since deployment %s was published (%s)\n", id, drift.DeploymentID, drift.PublishedAT.Format(time.RFC3339))
+	fmt.Println("the changes take effect on the next invocation:")
+	for _, k := range drift.Added {
+		fmt.Printf("  + %s\n", k)
+	}
+	for _, k := range drift.Removed {
+		fmt.Printf("  - %s\n", k)
+	}
+	for _, k := range drift.Changed {
+		fmt.Printf("  ~ %s\n", k)
+	}
+}
+
+func (c command) handleCache(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("cache", flag.ExitOnError)
+	var vary stringList
+	flagset.Var(&vary, "vary", "Request header the cached responses vary on, like --vary Accept-Language")
+	var (
+		defaultTTL int
+		disable    bool
+		purge      bool
+	)
+	flagset.IntVar(&defaultTTL, "default-ttl", 0, "Seconds responses without a Cache-Control are cached")
+	flagset.BoolVar(&disable, "disable", false, "Disable the response cache")
+	flagset.BoolVar(&purge, "purge", false, "Purge all the cached responses instead")
+	_ = flagset.Parse(args[1:])
+
+	if purge {
+		resp, err := c.client.PurgeEndpointCache(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("purged the response cache of endpoint %s (generation %d)\n", id, resp.Generation)
+		return
+	}
+	cache := &types.ResponseCache{
+		Enabled:    !disable,
+		Vary:       vary,
+		DefaultTTL: defaultTTL,
+	}
+	if err := cache.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{Cache: cache}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(cache, id.String())
+}
+
+func (c command) handleCORS(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("cors", flag.ExitOnError)
+	var origins, methods, headers, exposed stringList
+	flagset.Var(&origins, "origin", "Origin allowed to call the endpoint from a browser, like --origin https://example.com --origin https://*.example.com or --origin '*'")
+	flagset.Var(&methods, "method", "Allowed method, like --method GET --method POST, defaults to the methods of the installation")
+	flagset.Var(&headers, "header", "Allowed request header, like --header Content-Type, defaults to the headers of the installation")
+	flagset.Var(&exposed, "expose", "Response header the scripts may read, like --expose X-Request-Id")
+	var (
+		maxAge      time.Duration
+		credentials bool
+		disable     bool
+	)
+	flagset.DurationVar(&maxAge, "max-age", 0, "Time the browsers may cache a preflight response, defaults to the max age of the installation")
+	flagset.BoolVar(&credentials, "credentials", false, "Allow the requests with cookies or an authorization")
+	flagset.BoolVar(&disable, "disable", false, "Disable the CORS policy, the preflight requests reach the function again")
+	_ = flagset.Parse(args[1:])
+
+	cors := &types.CORS{}
+	if !disable {
+		if len(origins) == 0 {
+			printErrorAndExit(fmt.Errorf("at least one --origin is required"))
+		}
+		cors = &types.CORS{
+			AllowedOrigins:   origins,
+			AllowedMethods:   methods,
+			AllowedHeaders:   headers,
+			ExposedHeaders:   exposed,
+			MaxAge:           int(maxAge / time.Second),
+			AllowCredentials: credentials,
+		}
+	}
+	if err := cors.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{CORS: cors}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(cors, id.String())
+}
+
+func (c command) handleVerify(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("verify", flag.ExitOnError)
+	var (
+		secretEnv string
+		header    string
+		tolerance time.Duration
+		disable   bool
+	)
+	flagset.StringVar(&secretEnv, "secret-env", "", "Environment variable of the endpoint holding the shared secret, set with raptor endpoint update --env")
+	flagset.StringVar(&header, "header", "", "Header holding the signature, defaults to "+types.DefaultVerificationHeader)
+	flagset.DurationVar(&tolerance, "tolerance", 0, "Maximum age of the timestamp of a signed request, defaults to 5m")
+	flagset.BoolVar(&disable, "disable", false, "Disable the verification, the requests reach the function unverified again")
+	_ = flagset.Parse(args[1:])
+
+	verification := &types.RequestVerification{}
+	if !disable {
+		if len(secretEnv) == 0 {
+			printErrorAndExit(fmt.Errorf("--secret-env is required"))
+		}
+		verification = &types.RequestVerification{
+			SecretEnv: secretEnv,
+			Header:    header,
+			Tolerance: int(tolerance / time.Second),
+		}
+	}
+	if err := verification.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{Verification: verification}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(verification, id.String())
+}
+
+func (c command) handleJWT(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("jwt", flag.ExitOnError)
+	var (
+		issuer    string
+		audiences stringList
+		jwksURL   string
+		header    string
+		disable   bool
+	)
+	flagset.StringVar(&issuer, "issuer", "", "The iss claim the tokens need to have")
+	flagset.Var(&audiences, "audience", "Audience the tokens can be issued for, can be repeated")
+	flagset.StringVar(&jwksURL, "jwks-url", "", "URL of the JSON Web Key Set of the issuer")
+	flagset.StringVar(&header, "header", "", "Header carrying the token, defaults to the bearer token of the Authorization header")
+	flagset.BoolVar(&disable, "disable", false, "Disable the validation, the requests reach the function unauthenticated again")
+	_ = flagset.Parse(args[1:])
+
+	policy := &types.JWTPolicy{}
+	if !disable {
+		if len(jwksURL) == 0 {
+			printErrorAndExit(fmt.Errorf("--jwks-url is required"))
+		}
+		policy = &types.JWTPolicy{
+			Issuer:    issuer,
+			Audiences: audiences,
+			JWKSURL:   jwksURL,
+			Header:    header,
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{JWT: policy}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(policy, id.String())
+}
+
+func (c command) handlePrewarm(args []string) {
+	id := c.resolveEndpoint(args[0])
+	flagset := flag.NewFlagSet("prewarm", flag.ExitOnError)
+	var (
+		minInstances int
+		schedules    stringList
+		timeZone     string
+		disable      bool
+	)
+	flagset.IntVar(&minInstances, "min-instances", 0, "Runtimes kept warm at all times on every ingress")
+	flagset.Var(&schedules, "schedule", "Window more runtimes are kept warm in, as \"<days> <start>-<end> <instances>\" (--schedule \"mon-fri 08:45-10:00 5\"), can be repeated")
+	flagset.StringVar(&timeZone, "timezone", "", "IANA time zone of the schedules, defaults to UTC")
+	flagset.BoolVar(&disable, "disable", false, "Stop keeping the runtimes warm")
+	_ = flagset.Parse(args[1:])
+
+	prewarm := &types.Prewarm{}
+	if !disable {
+		if minInstances == 0 && len(schedules) == 0 {
+			printErrorAndExit(fmt.Errorf("--min-instances or --schedule is required"))
+		}
+		prewarm.MinInstances = minInstances
+		for _, s := range schedules {
+			schedule, err := parsePrewarmSchedule(s, timeZone)
+			if err != nil {
+				printErrorAndExit(err)
+			}
+			prewarm.Schedules = append(prewarm.Schedules, schedule)
+		}
+	}
+	if err := prewarm.Validate(); err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.UpdateEndpoint(id, api.UpdateEndpointParams{Prewarm: prewarm}); err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(prewarm, id.String())
+}
+
+var weekdayNames = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// parsePrewarmSchedule parses a schedule given as "<days> <start>-<end>
+// <instances>". The days are a comma separated list of days and ranges of
+// days, like "mon-fri,sun", or "*" for every day.
+func parsePrewarmSchedule(s, timeZone string) (types.PrewarmSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return types.PrewarmSchedule{}, fmt.Errorf("invalid schedule %q, expected \"<days> <start>-<end> <instances>\"", s)
+	}
+	start, end, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return types.PrewarmSchedule{}, fmt.Errorf("invalid schedule window %q, expected <start>-<end>", fields[1])
+	}
+	instances, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return types.PrewarmSchedule{}, fmt.Errorf("invalid schedule instances %q", fields[2])
+	}
+	schedule := types.PrewarmSchedule{
+		Start:     start,
+		End:       end,
+		TimeZone:  timeZone,
+		Instances: instances,
+	}
+	if fields[0] == "*" {
+		return schedule, nil
+	}
+	for _, days := range strings.Split(fields[0], ",") {
+		from, to, isRange := strings.Cut(strings.ToLower(days), "-")
+		if !isRange {
+			schedule.Days = append(schedule.Days, from)
+			continue
+		}
+		i, j := slices.Index(weekdayNames, from), slices.Index(weekdayNames, to)
+		if i < 0 || j < 0 || j < i {
+			return types.PrewarmSchedule{}, fmt.Errorf("invalid schedule days %q", days)
+		}
+		schedule.Days = append(schedule.Days, weekdayNames[i:j+1]...)
+	}
+	return schedule, nil
+}
+
+// handleConfig validates the config given with --config, so a config can
+// be checked before the servers are rolled out with it, or shows the config
+// the API server runs with.
+func (c command) handleConfig(args []string) {
+	switch args[0] {
+	case "validate":
+		if err := config.Get().Validate(); err != nil {
+			printErrorAndExit(fmt.Errorf("invalid config:\n%s", err))
+		}
+		fmt.Println("config is valid")
+	case "show":
+		effective, err := c.client.GetConfig()
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printJSON(effective)
+	default:
+		printUsage()
+	}
+}
+
+// handleBackup writes the backup archive of all the endpoints to a file.
+func (c command) handleBackup(args []string) {
+	flagset := flag.NewFlagSet("backup", flag.ExitOnError)
+	var out string
+	flagset.StringVar(&out, "out", fmt.Sprintf("raptor-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405")), "The file the backup archive is written to")
+	_ = flagset.Parse(args)
+
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if err := c.client.Backup(f); err != nil {
+		f.Close()
+		os.Remove(out)
+		printErrorAndExit(err)
+	}
+	if err := f.Close(); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("backup written to %s\n", out)
+}
+
+// handleRestore imports the endpoints of a backup archive.
+func (c command) handleRestore(args []string) {
+	if len(args) == 0 {
+		printErrorAndExit(fmt.Errorf("the backup archive to restore is required"))
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	defer f.Close()
+	result, err := c.client.Restore(f)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printJSON(result)
+}
+
+// handleRotateMasterKey re-wraps the data keys with the current master key
+// of the API server, once it runs with the new masterKey and the old one in
+// previousMasterKeys.
+func (c command) handleRotateMasterKey() {
+	result, err := c.client.RewrapDataKeys()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("re-wrapped the data keys of %d endpoints and %d projects, the previous master keys can be removed\n", result.Endpoints, result.Projects)
+}
+
+func (c command) handleAdmin(args []string) {
+	// The backups and the keys go through the API server instead of the
+	// members.
+	switch args[0] {
+	case "backup":
+		c.handleBackup(args[1:])
+		return
+	case "restore":
+		c.handleRestore(args[1:])
+		return
+	case "rotate-master-key":
+		c.handleRotateMasterKey()
+		return
+	}
+	flagset := flag.NewFlagSet("admin", flag.ExitOnError)
+	var members stringList
+	flagset.Var(&members, "member", "Admin address of a cluster member, like --member 10.0.0.1:8133 --member 10.0.0.2:8133")
+	var timeout, poll time.Duration
+	flagset.DurationVar(&timeout, "timeout", time.Minute*10, "Maximum time a member gets to drain and its replacement gets to warm up")
+	flagset.DurationVar(&poll, "poll", time.Second, "Interval between two status checks of a member")
+	_ = flagset.Parse(args[1:])
+	if len(members) == 0 {
+		printErrorAndExit(fmt.Errorf("at least one --member is required"))
+	}
+	clients := make([]*admin.Client, len(members))
+	for i, addr := range members {
+		clients[i] = admin.NewClient(addr, config.Get().APIToken)
+	}
+
+	switch args[0] {
+	case "status":
+		t := newTable(
+			column{header: "member"},
+			column{header: "id"},
+			column{header: "region"},
+			column{header: "version"},
+			column{header: "status"},
+			column{header: "inflight"},
+			column{header: "warm", wide: true},
+			column{header: "members", wide: true},
+		)
+		statuses := make([]*admin.Status, len(clients))
+		for i, client := range clients {
+			status, err := client.Status()
+			if err != nil {
+				t.add(client.Addr(), "", "", "", "unreachable: "+err.Error(), "", "", "")
+				continue
+			}
+			state := "serving"
+			if status.Draining {
+				state = "draining"
+			}
+			statuses[i] = status
+			t.add(client.Addr(), status.ID, status.Region, status.Version, state,
+				strconv.FormatInt(status.Inflight, 10), strconv.FormatBool(status.Warm), strconv.Itoa(status.Members))
+		}
+		printList(statuses, t)
+	case "upgrade":
+		err := admin.Upgrade(context.Background(), clients, admin.UpgradeOptions{
+			Timeout:      timeout,
+			PollInterval: poll,
+			Progress: func(member, step string) {
+				fmt.Printf("%s\t%s\n", member, step)
+			},
+		})
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("upgraded %d members\n", len(clients))
+	default:
+		printUsage()
+	}
+}
+
+func (c command) handlePlatform(args []string) {
+	switch args[0] {
+	case "status":
+		status, err := c.client.GetPlatformStatus()
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("platform: %s\n", status.Status)
+		for _, incident := range status.Incidents {
+			state := "active"
+			if !incident.Active() {
+				state = "resolved"
+			}
+			fmt.Printf("incident %s\t%s\t%s\t%s\n", incident.ID, incident.Severity, state, incident.Title)
+		}
+		for _, region := range status.Regions {
+			fmt.Printf("region %s\t%s\t%d ingresses\n", region.Region, region.Status, len(region.Ingresses))
+		}
+		for _, requests := range status.Requests {
+			fmt.Printf("%s\trequests=%d errors=%.2f%%\n", requests.Start.Format(time.RFC3339), requests.Requests, requests.ErrorRate()*100)
+		}
+	case "incident":
+		flagset := flag.NewFlagSet("incident", flag.ExitOnError)
+		var params api.CreateIncidentParams
+		flagset.StringVar(&params.Title, "title", "", "The title of the incident banner")
+		flagset.StringVar(&params.Message, "message", "", "The details of the incident")
+		flagset.StringVar(&params.Severity, "severity", types.IncidentMinor, "The severity of the incident (minor, major or critical)")
+		var regions stringList
+		flagset.Var(&regions, "region", "A region affected by the incident, all the regions when omitted")
+		_ = flagset.Parse(args[1:])
+		params.Regions = regions
+		incident, err := c.client.CreateIncident(params)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("created incident %s\n", incident.ID)
+	case "resolve":
+		if len(args) < 2 {
+			printUsage()
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid incident id given: %s", args[1]))
+		}
+		if _, err := c.client.ResolveIncident(id); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("resolved incident %s\n", id)
+	default:
+		printUsage()
+	}
 }
 
 func (c command) handleServeEndpoint(args []string) {
@@ -199,6 +1710,29 @@ func makeEnvMap(list []string) map[string]string {
 	return m
 }
 
+func makeLabelMap(list []string) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(list))
+	for _, value := range list {
+		k, v, ok := strings.Cut(value, "=")
+		if !ok {
+			printErrorAndExit(fmt.Errorf("labels need to be in the format of --label env=staging --label team=payments"))
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// currentUser returns the name of the user running the cli.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
 func printErrorAndExit(err error) {
 	fmt.Println()
 	fmt.Println("Error:")
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats the results of the commands are printed in.
+const (
+	// Aligned columns for lists, indented JSON for single objects.
+	outputTable = "table"
+	// Like table with the additional columns of the lists.
+	outputWide = "wide"
+	// Indented JSON only, for tools like jq.
+	outputJSON = "json"
+	// Only the ids, one per line, for scripting.
+	outputQuiet = "quiet"
+)
+
+// outputFormat is the format set with -o or --quiet.
+var outputFormat = outputTable
+
+func validOutputFormat(format string) bool {
+	switch format {
+	case outputTable, outputWide, outputJSON, outputQuiet:
+		return true
+	}
+	return false
+}
+
+// parseOutputFlags removes the output flags from the arguments and sets the
+// output format. The flags are accepted anywhere in the command line so every
+// command supports them without declaring them in its own flagset.
+func parseOutputFlags(args []string) ([]string, error) {
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// The words of the command line that is completed are passed on
+		// as they are.
+		if arg == "--" || arg == completeCommand {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		switch name {
+		case "q", "quiet":
+			outputFormat = outputQuiet
+		case "o", "output":
+			if !hasValue {
+				if i+1 == len(args) {
+					return nil, fmt.Errorf("the output flag needs a format (-o table, wide, json or quiet)")
+				}
+				i++
+				value = args[i]
+			}
+			if !validOutputFormat(value) {
+				return nil, fmt.Errorf("invalid output format %s, only table, wide, json and quiet are supported", value)
+			}
+			outputFormat = value
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, nil
+}
+
+// humanOutput returns true if the output is read by a person, hints and
+// progress messages are only printed to stdout then.
+func humanOutput() bool {
+	return outputFormat == outputTable || outputFormat == outputWide
+}
+
+// progressf prints a progress message. The messages go to stderr when the
+// output is parsed by a script.
+func progressf(format string, a ...any) {
+	var w io.Writer = os.Stdout
+	if !humanOutput() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, a...)
+}
+
+// printResult prints a single object as indented JSON, or only its id with
+// --quiet.
+func printResult(v any, id string) {
+	if outputFormat == outputQuiet {
+		fmt.Println(id)
+		return
+	}
+	printJSON(v)
+}
+
+func printJSON(v any) {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Println(string(b))
+}
+
+// column is a column of a table.
+type column struct {
+	header string
+	// Only shown with -o wide.
+	wide bool
+}
+
+// table is a list printed with aligned columns. The first column holds the
+// ids the list is reduced to with --quiet.
+type table struct {
+	columns []column
+	rows    [][]string
+}
+
+func newTable(columns ...column) *table {
+	return &table{columns: columns}
+}
+
+// add appends a row, with a cell for every column.
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) write(w io.Writer, wide bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	line := func(cells []string) {
+		var shown []string
+		for i, cell := range cells {
+			if t.columns[i].wide && !wide {
+				continue
+			}
+			if len(cell) == 0 {
+				cell = "-"
+			}
+			shown = append(shown, cell)
+		}
+		fmt.Fprintln(tw, strings.Join(shown, "\t"))
+	}
+	headers := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		headers[i] = strings.ToUpper(c.header)
+	}
+	line(headers)
+	for _, row := range t.rows {
+		line(row)
+	}
+	return tw.Flush()
+}
+
+// printList prints a list in the output format, v is the list that is
+// encoded with -o json.
+func printList(v any, t *table) {
+	switch outputFormat {
+	case outputJSON:
+		printJSON(v)
+	case outputQuiet:
+		for _, row := range t.rows {
+			fmt.Println(row[0])
+		}
+	default:
+		if err := t.write(os.Stdout, outputFormat == outputWide); err != nil {
+			printErrorAndExit(err)
+		}
+	}
+}
+
+// shortHash abbreviates a hash or a git commit for the table columns.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// formatLabels returns the labels as sorted key=value pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/client"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// profile holds the settings to target a cluster, like its staging or its
+// production API server.
+type profile struct {
+	URL    string `toml:"url"`
+	APIKey string `toml:"api_key"`
+	// Owner of the endpoints created with the profile, a team or a project.
+	Project string `toml:"project,omitempty"`
+}
+
+// profileConfig is the ~/.raptor/config of the user.
+type profileConfig struct {
+	// Profile used when no --profile is given.
+	Current  string             `toml:"current"`
+	Profiles map[string]profile `toml:"profiles"`
+}
+
+// profileConfigPath returns the location of the profile config.
+func profileConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".raptor", "config"), nil
+}
+
+// loadProfileConfig reads the profile config, a missing file yields a
+// config without profiles.
+func loadProfileConfig() (*profileConfig, error) {
+	c := &profileConfig{Profiles: map[string]profile{}}
+	path, err := profileConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := toml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]profile{}
+	}
+	return c, nil
+}
+
+// save writes the profile config, only readable by the user as it holds the
+// API keys.
+func (c *profileConfig) save() error {
+	path, err := profileConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := toml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// resolveProfile returns the profile with the given name, or the current
+// profile when name is empty. Without a name nor a current profile it
+// returns nil and the config.toml is used.
+func resolveProfile(name string) (*profile, error) {
+	c, err := loadProfileConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(name) == 0 {
+		name = c.Current
+	}
+	if len(name) == 0 {
+		return nil, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s does not exist, create it with: raptor login --profile %s", name, name)
+	}
+	return &p, nil
+}
+
+// useProfile targets the API server of the profile, of the current profile
+// when name is empty. The profiles take precedence over the config.toml,
+// which is used when there is no profile.
+func (c *command) useProfile(name string) error {
+	apiURL, apiKey := config.ApiUrl(), config.Get().APIToken
+	p, err := resolveProfile(name)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		apiURL, apiKey = p.URL, p.APIKey
+		c.defaultOwner = p.Project
+	}
+	c.client = client.New(client.NewConfig().WithURL(apiURL).WithActor(currentUser()).WithAPIKey(apiKey))
+	return nil
+}
+
+// handleLogin stores the API server and the API key of a cluster as a named
+// profile and makes it the current profile. The key is checked against the
+// API server first.
+func (c command) handleLogin(args []string) {
+	flagset := flag.NewFlagSet("login", flag.ExitOnError)
+
+	var name string
+	flagset.StringVar(&name, "profile", c.profileName, "The name of the profile")
+	var p profile
+	flagset.StringVar(&p.URL, "url", "", "The url of the API server, like https://api.staging.example.com")
+	flagset.StringVar(&p.APIKey, "api-key", "", "The API key, read from stdin when not given")
+	flagset.StringVar(&p.Project, "project", "", "The team or project that owns the endpoints created with the profile")
+	_ = flagset.Parse(args)
+
+	if len(name) == 0 {
+		name = "default"
+	}
+	reader := bufio.NewReader(os.Stdin)
+	if len(p.URL) == 0 {
+		p.URL = prompt(reader, "API url: ")
+	}
+	if len(p.APIKey) == 0 {
+		p.APIKey = prompt(reader, "API key: ")
+	}
+	p.URL = strings.TrimSuffix(p.URL, "/")
+	if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		printErrorAndExit(fmt.Errorf("invalid API url given: %s", p.URL))
+	}
+
+	cl := client.New(client.NewConfig().WithURL(p.URL).WithAPIKey(p.APIKey))
+	if _, err := cl.ListEndpoints(""); err != nil {
+		printErrorAndExit(fmt.Errorf("failed to log in to %s: %s", p.URL, err))
+	}
+	profiles, err := loadProfileConfig()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	profiles.Profiles[name] = p
+	profiles.Current = name
+	if err := profiles.save(); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("logged in to %s, profile %s is now the current profile\n", p.URL, name)
+}
+
+// handleProfile lists the profiles or switches the current profile.
+func (c command) handleProfile(args []string) {
+	profiles, err := loadProfileConfig()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(args) > 0 && args[0] == "use" {
+		if len(args) < 2 {
+			printUsage()
+		}
+		if _, ok := profiles.Profiles[args[1]]; !ok {
+			printErrorAndExit(fmt.Errorf("profile %s does not exist, create it with: raptor login --profile %s", args[1], args[1]))
+		}
+		profiles.Current = args[1]
+		if err := profiles.save(); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("profile %s is now the current profile\n", args[1])
+		return
+	}
+
+	names := make([]string, 0, len(profiles.Profiles))
+	for name := range profiles.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	t := newTable(
+		column{header: "name"},
+		column{header: "current"},
+		column{header: "url"},
+		column{header: "project"},
+	)
+	list := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		p := profiles.Profiles[name]
+		var current string
+		if name == profiles.Current {
+			current = "*"
+		}
+		t.add(name, current, p.URL, p.Project)
+		// The API keys are never printed.
+		list = append(list, map[string]string{"name": name, "url": p.URL, "project": p.Project})
+	}
+	printList(list, t)
+}
+
+// prompt prints the message and reads a line from the reader.
+func prompt(reader *bufio.Reader, message string) string {
+	fmt.Print(message)
+	line, err := reader.ReadString('\n')
+	if err != nil && len(line) == 0 {
+		printErrorAndExit(fmt.Errorf("failed to read %s", strings.TrimSuffix(message, ": ")))
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// projectFile is the manifest holding the endpoint settings of a project.
+const projectFile = "raptor.toml"
+
+// project is the raptor.toml manifest of a project. Run from the project
+// directory, "raptor deploy" creates or updates the endpoint to match the
+// manifest and deploys its file.
+type project struct {
+	Endpoint projectEndpoint `toml:"endpoint"`
+}
+
+type projectEndpoint struct {
+	// Optional id of the endpoint, the endpoint is looked up by its name
+	// when empty.
+	ID      string `toml:"id"`
+	Name    string `toml:"name"`
+	Runtime string `toml:"runtime"`
+	// Module or script that is deployed.
+	File string `toml:"file"`
+	// Environment variables set on the endpoint, variables that are not in
+	// the manifest, like secrets set with "raptor endpoint update", are
+	// kept.
+	Environment    map[string]string `toml:"environment"`
+	Regions        []string          `toml:"regions"`
+	MaxConcurrency *int              `toml:"max_concurrency"`
+	Limits         *projectLimits    `toml:"limits"`
+}
+
+type projectLimits struct {
+	// Maximum wall time of an invocation, like "10s".
+	Timeout         string `toml:"timeout"`
+	MaxResponseSize int    `toml:"max_response_size"`
+	CacheQuota      int    `toml:"cache_quota"`
+}
+
+// loadProject reads the raptor.toml of the current directory, a missing file
+// yields an empty project. Settings the manifest does not support are
+// rejected rather than silently ignored.
+func loadProject() (*project, error) {
+	var p project
+	b, err := os.ReadFile(projectFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := toml.NewDecoder(bytes.NewReader(b)).DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			return nil, fmt.Errorf("unsupported settings in %s:\n%s", projectFile, strict.String())
+		}
+		return nil, fmt.Errorf("failed to parse %s: %s", projectFile, err)
+	}
+	return &p, nil
+}
+
+// updateParams returns the endpoint settings of the manifest.
+func (e projectEndpoint) updateParams() (api.UpdateEndpointParams, error) {
+	params := api.UpdateEndpointParams{
+		Regions:        e.Regions,
+		MaxConcurrency: e.MaxConcurrency,
+	}
+	if len(e.Environment) > 0 {
+		params.Environment = make(map[string]*string, len(e.Environment))
+		for k, v := range e.Environment {
+			v := v
+			params.Environment[k] = &v
+		}
+	}
+	if e.Limits != nil {
+		params.Limits = &types.Limits{
+			MaxResponseSize: e.Limits.MaxResponseSize,
+			CacheQuota:      e.Limits.CacheQuota,
+		}
+		if len(e.Limits.Timeout) > 0 {
+			timeout, err := time.ParseDuration(e.Limits.Timeout)
+			if err != nil {
+				return params, fmt.Errorf("invalid timeout in %s: %s", projectFile, err)
+			}
+			params.Limits.Timeout = int(timeout.Milliseconds())
+		}
+	}
+	return params, nil
+}
+
+// syncProject creates the endpoint of the manifest, or updates the existing
+// endpoint to match the manifest, and returns it. Running it again without
+// changes to the manifest changes nothing.
+func (c command) syncProject(p *project) *types.Endpoint {
+	e := p.Endpoint
+	if len(e.Name) == 0 || len(e.Runtime) == 0 {
+		printErrorAndExit(fmt.Errorf("%s needs the name and the runtime of the endpoint", projectFile))
+	}
+	params, err := e.updateParams()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	endpoint, err := c.findProjectEndpoint(e)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if endpoint == nil {
+		endpoint, err = c.client.CreateEndpoint(api.CreateEndpointParams{
+			Name:        e.Name,
+			Runtime:     e.Runtime,
+			Environment: e.Environment,
+			Owner:       c.defaultOwner,
+		})
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		progressf("created endpoint %s (%s)\n", endpoint.Name, endpoint.ID)
+		// The environment is already set.
+		params.Environment = nil
+	} else {
+		if endpoint.Name != e.Name {
+			params.Name = e.Name
+		}
+		if endpoint.Runtime != e.Runtime {
+			params.Runtime = e.Runtime
+		}
+		progressf("updating endpoint %s (%s)\n", endpoint.Name, endpoint.ID)
+	}
+	if err := c.client.UpdateEndpoint(endpoint.ID, params); err != nil {
+		printErrorAndExit(err)
+	}
+	return endpoint
+}
+
+// findProjectEndpoint returns the endpoint of the manifest, by id when the
+// manifest pins one and by name otherwise, nil when there is none yet.
+func (c command) findProjectEndpoint(e projectEndpoint) (*types.Endpoint, error) {
+	if len(e.ID) > 0 {
+		id, err := uuid.Parse(e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint id in %s: %s", projectFile, e.ID)
+		}
+		return c.client.GetEndpoint(id)
+	}
+	endpoint, err := c.findEndpointByName(e.Name)
+	if errors.Is(err, errAmbiguousName) {
+		return nil, fmt.Errorf("more than one endpoint is named %s, pin the endpoint with its id in %s", e.Name, projectFile)
+	}
+	return endpoint, err
+}
+
+var errAmbiguousName = errors.New("more than one endpoint has the name")
+
+// findEndpointByName returns the endpoint with the given name, nil when
+// there is none.
+func (c command) findEndpointByName(name string) (*types.Endpoint, error) {
+	endpoints, err := c.client.ListEndpoints(name)
+	if err != nil {
+		return nil, err
+	}
+	switch len(endpoints) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &endpoints[0], nil
+	}
+	return nil, errAmbiguousName
+}
+
+// resolveEndpoint returns the id of the endpoint given by its id or by its
+// name.
+func (c command) resolveEndpoint(arg string) uuid.UUID {
+	if id, err := uuid.Parse(arg); err == nil {
+		return id
+	}
+	endpoint, err := c.findEndpointByName(arg)
+	if errors.Is(err, errAmbiguousName) {
+		printErrorAndExit(fmt.Errorf("more than one endpoint is named %s, use the id of the endpoint instead", arg))
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if endpoint == nil {
+		printErrorAndExit(fmt.Errorf("invalid endpoint given: %s is neither the id nor the name of an endpoint", arg))
+	}
+	return endpoint.ID
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/api"
+)
+
+// handleProjectEnv shows the default environment of a project or, given
+// variables to set or delete, updates it. The endpoints of the project
+// inherit the variables they do not set themselves.
+func (c command) handleProjectEnv(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		printErrorAndExit(fmt.Errorf("provide a project: raptor project-env <project>"))
+	}
+	project := args[0]
+	flagset := flag.NewFlagSet("project-env", flag.ExitOnError)
+	var env stringList
+	flagset.Var(&env, "env", "Default environment variables to set (--env foo=bar)")
+	var envFiles stringList
+	flagset.Var(&envFiles, "env-file", "Dotenv file with default environment variables to set, the --env flags take precedence")
+	var unset stringList
+	flagset.Var(&unset, "unset", "Default environment variables to delete (--unset foo)")
+	var replace bool
+	flagset.BoolVar(&replace, "replace-env", false, "Replace the whole default environment instead of merging the given variables")
+	_ = flagset.Parse(args[1:])
+
+	fileEnv, err := readEnvFiles(envFiles)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(env) == 0 && len(unset) == 0 && len(fileEnv) == 0 && !replace {
+		projectEnv, err := c.client.GetProjectEnvironment(project)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(projectEnv, projectEnv.Project)
+		return
+	}
+	params := api.UpdateProjectEnvironmentParams{
+		Environment: make(map[string]*string, len(fileEnv)+len(env)+len(unset)),
+	}
+	for k, v := range fileEnv {
+		v := v
+		params.Environment[k] = &v
+	}
+	for k, v := range makeEnvMap(env) {
+		v := v
+		params.Environment[k] = &v
+	}
+	for _, k := range unset {
+		params.Environment[k] = nil
+	}
+	if replace {
+		params.EnvironmentMode = api.EnvironmentReplace
+	}
+	projectEnv, err := c.client.UpdateProjectEnvironment(project, params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(projectEnv, projectEnv.Project)
+}
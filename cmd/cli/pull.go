@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/artifact"
+	"github.com/anthdm/raptor/internal/signing"
+	"github.com/google/uuid"
+)
+
+// isArtifactRef returns true if the argument is a registry reference or a
+// URL a deployment can be pulled from.
+func isArtifactRef(arg string) bool {
+	return strings.HasPrefix(arg, "oci://") || strings.HasPrefix(arg, "https://")
+}
+
+// pullDeployment lets the API server pull the artifact and create the
+// deployment. Signed deployments are pulled locally first, the signature is
+// made over the module with the digest the server verifies.
+func (c command) pullDeployment(endpointID uuid.UUID, params api.PullDeploymentParams, signKey string) {
+	if len(signKey) > 0 {
+		s, err := os.ReadFile(signKey)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		key, err := signing.ParsePrivateKey(string(s))
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		pulled, err := artifact.NewFetcher(0).Fetch(context.Background(), params.Source, params.Digest)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		params.Digest = pulled.Digest
+		params.Signature = signing.Sign(key, pulled.Blob)
+	}
+	deploy, err := c.client.PullDeployment(endpointID, params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(deploy, deploy.ID.String())
+	if humanOutput() {
+		fmt.Println()
+		fmt.Printf("deploy preview: %s\n", deploy.PreviewURL)
+	}
+}
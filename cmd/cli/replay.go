@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// handleReplay sends a captured request again, to a deployment in PREVIEW,
+// to the LIVE deployment of its endpoint or to a local module served by
+// raptor dev, and prints the response.
+func (c command) handleReplay(args []string) {
+	if len(args) > 0 && args[0] == "list" {
+		c.handleListCaptures(args[1:])
+		return
+	}
+	flagset := flag.NewFlagSet("replay", flag.ExitOnError)
+
+	var deploy string
+	flagset.StringVar(&deploy, "deploy", "", "Replay the request against the given deployment in PREVIEW instead of the LIVE deployment, the logs are printed")
+	var local string
+	flagset.StringVar(&local, "local", "", "Replay the request against a local module, the url printed by raptor dev")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		printErrorAndExit(fmt.Errorf("provide the id of the invocation: raptor replay <invocation-id> [--deploy <deploy-id> | --local <url>]"))
+	}
+	invocationID, err := uuid.Parse(args[0])
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid invocation id given: %s", args[0]))
+	}
+	_ = flagset.Parse(args[1:])
+
+	if len(local) > 0 {
+		c.replayLocal(invocationID, local)
+		return
+	}
+	var deployID uuid.UUID
+	if len(deploy) > 0 {
+		deployID, err = uuid.Parse(deploy)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid deploy id given: %s", deploy))
+		}
+	}
+	replay, err := c.client.Replay(invocationID, deployID)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if !humanOutput() {
+		printResult(replay, invocationID.String())
+		return
+	}
+	if len(replay.Logs) > 0 {
+		fmt.Printf("--- logs\n%s", replay.Logs)
+		if !strings.HasSuffix(replay.Logs, "\n") {
+			fmt.Println()
+		}
+	}
+	resp := &http.Response{
+		Status: fmt.Sprintf("%d %s", replay.StatusCode, http.StatusText(replay.StatusCode)),
+		Header: replay.Header,
+	}
+	printInvokeResponse(os.Stdout, resp, replay.Body, replay.Duration)
+}
+
+// replayLocal sends the captured request to the module served at the given
+// url by raptor dev.
+func (c command) replayLocal(invocationID uuid.UUID, url string) {
+	capture, err := c.client.GetCapturedRequest(invocationID)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	req, err := http.NewRequest(capture.Method, strings.TrimSuffix(url, "/")+capture.Path, bytes.NewReader(capture.Body))
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	for name, values := range capture.Header {
+		if len(values) == 1 && values[0] == types.RedactedHeader {
+			continue
+		}
+		req.Header[name] = values
+	}
+	req.Header.Set(shared.ReplayHeader, capture.ID.String())
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printInvokeResponse(os.Stdout, resp, b, time.Since(start))
+}
+
+func (c command) handleListCaptures(args []string) {
+	if len(args) == 0 {
+		printErrorAndExit(fmt.Errorf("provide an endpoint: raptor replay list <endpoint>"))
+	}
+	id := c.resolveEndpoint(args[0])
+	captures, err := c.client.GetCaptures(id)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "invocation"},
+		column{header: "request"},
+		column{header: "deployment", wide: true},
+		column{header: "size"},
+		column{header: "created"},
+	)
+	for _, capture := range captures {
+		t.add(capture.ID.String(), capture.Method+" "+capture.Path, capture.DeploymentID.String(),
+			fmt.Sprintf("%dB", len(capture.Body)), capture.CreatedAT.Format(time.RFC3339))
+	}
+	printList(captures, t)
+}
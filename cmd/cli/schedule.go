@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// handleSchedule schedules a one-shot invocation of an endpoint (create),
+// lists its scheduled invocations (list) or cancels a pending one (cancel).
+func (c command) handleSchedule(args []string) {
+	switch args[0] {
+	case "create":
+		flagset := flag.NewFlagSet("schedule", flag.ExitOnError)
+		var at string
+		flagset.StringVar(&at, "at", "", "The time of the invocation in RFC 3339 (2024-05-01T09:00:00Z)")
+		var in time.Duration
+		flagset.DurationVar(&in, "in", 0, "The delay of the invocation from now (10m)")
+		var method string
+		flagset.StringVar(&method, "method", "POST", "The method of the request")
+		var path string
+		flagset.StringVar(&path, "path", "/", "The path of the request, relative to the endpoint")
+		var headers stringList
+		flagset.Var(&headers, "header", "Headers of the request (--header \"Content-Type: application/json\")")
+		var data string
+		flagset.StringVar(&data, "data", "", "The body of the request, @file reads the body from a file and @- from stdin")
+
+		args = args[1:]
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			printErrorAndExit(fmt.Errorf("provide an endpoint: raptor schedule create <endpoint> --at <time> | --in <delay>"))
+		}
+		endpointID := c.resolveEndpoint(args[0])
+		_ = flagset.Parse(args[1:])
+
+		var runAT time.Time
+		switch {
+		case len(at) > 0 && in > 0:
+			printErrorAndExit(fmt.Errorf("provide either --at or --in, not both"))
+		case len(at) > 0:
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				printErrorAndExit(fmt.Errorf("invalid --at %q, expected an RFC 3339 time", at))
+			}
+			runAT = t
+		case in > 0:
+			runAT = time.Now().Add(in)
+		default:
+			printErrorAndExit(fmt.Errorf("provide the time of the invocation with --at or --in"))
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		body, err := readInvokeBody(data)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		req := types.AsyncRequest{Method: method, Path: path, Header: make(http.Header), Body: body}
+		for _, header := range headers {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				printErrorAndExit(fmt.Errorf("headers need to be in the format of --header \"name: value\""))
+			}
+			req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		scheduled, err := c.client.ScheduleInvocation(endpointID, req, runAT)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(scheduled, scheduled.ID.String())
+	case "list":
+		if len(args) < 2 {
+			printErrorAndExit(fmt.Errorf("provide an endpoint: raptor schedule list <endpoint>"))
+		}
+		scheduled, err := c.client.GetScheduledInvocations(c.resolveEndpoint(args[1]))
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		t := newTable(
+			column{header: "id"},
+			column{header: "request"},
+			column{header: "run at"},
+			column{header: "status"},
+			column{header: "invocation", wide: true},
+			column{header: "created", wide: true},
+			column{header: "error"},
+		)
+		for _, s := range scheduled {
+			invocation := ""
+			if s.InvocationID != uuid.Nil {
+				invocation = s.InvocationID.String()
+			}
+			t.add(s.ID.String(), s.Request.Method+" "+s.Request.Path, s.RunAT.Format(time.RFC3339),
+				s.Status, invocation, s.CreatedAT.Format(time.RFC3339), s.Error)
+		}
+		printList(scheduled, t)
+	case "cancel":
+		if len(args) < 3 {
+			printErrorAndExit(fmt.Errorf("provide an endpoint and a scheduled invocation: raptor schedule cancel <endpoint> <id>"))
+		}
+		endpointID := c.resolveEndpoint(args[1])
+		id, err := uuid.Parse(args[2])
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid scheduled invocation id given: %s", args[2]))
+		}
+		scheduled, err := c.client.CancelScheduledInvocation(endpointID, id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(scheduled, scheduled.ID.String())
+	default:
+		printUsage()
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/spidermonkey"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/tetratelabs/wazero"
+
+	prot "google.golang.org/protobuf/proto"
+)
+
+// Maximum size of a line read by the shell.
+const maxShellLineSize = 1 << 20
+
+// shellResult holds the outcome of a request sent by the shell.
+type shellResult struct {
+	status   int
+	body     []byte
+	logs     []byte
+	duration time.Duration
+}
+
+// shellInvoker sends the lines of the shell as request bodies.
+type shellInvoker interface {
+	invoke(body []byte) (*shellResult, error)
+	close()
+}
+
+// handleShell opens an interactive prompt that sends every line as the body
+// of a request to the LIVE deployment of an endpoint, or to a local module.
+func (c command) handleShell(args []string) {
+	flagset := flag.NewFlagSet("shell", flag.ExitOnError)
+
+	var method string
+	flagset.StringVar(&method, "method", "POST", "The method of the requests")
+	var path string
+	flagset.StringVar(&path, "path", "/", "The path of the requests, relative to the endpoint")
+	var file string
+	flagset.StringVar(&file, "file", "", "Invoke the given WASM module or script locally instead of an endpoint")
+	var engine string
+	flagset.StringVar(&engine, "runtime", "go", "The runtime of the local module (go or js)")
+	var env stringList
+	flagset.Var(&env, "env", "Environment variables of the local module")
+
+	var endpoint string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		endpoint, args = args[0], args[1:]
+	}
+	_ = flagset.Parse(args)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	var (
+		invoker shellInvoker
+		target  string
+		err     error
+	)
+	switch {
+	case len(file) > 0:
+		invoker, err = newLocalInvoker(file, engine, method, path, makeEnvMap(env))
+		target = file
+	case len(endpoint) > 0:
+		id := c.resolveEndpoint(endpoint)
+		target = fmt.Sprintf("%s/live/%s%s", config.IngressUrl(), id, path)
+		invoker = &remoteInvoker{method: method, url: target, client: &http.Client{}}
+	default:
+		printErrorAndExit(fmt.Errorf("provide an endpoint id or a local module: raptor shell <endpoint> | raptor shell --file <module>"))
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	defer invoker.close()
+
+	fmt.Printf("sending %s requests to %s, every line is a request body (ctrl+d to exit)\n", method, target)
+	runShell(os.Stdin, os.Stdout, invoker)
+}
+
+// runShell reads the lines from in until it is closed and writes the results
+// of the requests to out.
+func runShell(in io.Reader, out io.Writer, invoker shellInvoker) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 4096), maxShellLineSize)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+		result, err := invoker.invoke(scanner.Bytes())
+		if err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+			continue
+		}
+		if len(result.logs) > 0 {
+			fmt.Fprintf(out, "--- logs\n%s", result.logs)
+			if !bytes.HasSuffix(result.logs, []byte("\n")) {
+				fmt.Fprintln(out)
+			}
+		}
+		fmt.Fprintf(out, "--- %d %s in %s\n", result.status, http.StatusText(result.status), result.duration.Round(time.Microsecond))
+		fmt.Fprintf(out, "%s\n", result.body)
+	}
+	fmt.Fprintln(out)
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(out, "error: %s\n", err)
+	}
+}
+
+// remoteInvoker sends the requests to the ingress. The logs of the
+// invocations stay on the cluster.
+type remoteInvoker struct {
+	method string
+	url    string
+	client *http.Client
+}
+
+func (i *remoteInvoker) invoke(body []byte) (*shellResult, error) {
+	req, err := http.NewRequest(i.method, i.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &shellResult{
+		status:   resp.StatusCode,
+		body:     b,
+		duration: time.Since(start),
+	}, nil
+}
+
+func (i *remoteInvoker) close() {}
+
+// localInvoker invokes a module in process, the module is compiled once for
+// the whole session.
+type localInvoker struct {
+	runtime *runtime.Runtime
+	stdout  *bytes.Buffer
+	engine  string
+	script  []byte
+	method  string
+	path    string
+	env     map[string]string
+}
+
+func newLocalInvoker(file, engine, method, path string, env map[string]string) (*localInvoker, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	i := &localInvoker{
+		stdout: &bytes.Buffer{},
+		engine: engine,
+		method: method,
+		path:   path,
+		env:    env,
+	}
+	blob := b
+	if engine == "js" {
+		i.script, blob = b, spidermonkey.WasmBlob
+	}
+	i.runtime, err = runtime.New(context.Background(), runtime.Args{
+		Stdout:       i.stdout,
+		DeploymentID: uuid.New(),
+		Engine:       engine,
+		Blob:         blob,
+		Cache:        wazero.NewCompilationCache(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (i *localInvoker) invoke(body []byte) (*shellResult, error) {
+	req := &proto.HTTPRequest{
+		ID:      uuid.NewString(),
+		Runtime: i.engine,
+		Method:  i.method,
+		URL:     i.path,
+		Body:    body,
+		Env:     i.env,
+	}
+	b, err := prot.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	if i.engine == "js" {
+		script, err := spidermonkey.Script(i.script, req)
+		if err != nil {
+			return nil, err
+		}
+		args = []string{"", "-e", script}
+	}
+	i.stdout.Reset()
+	start := time.Now()
+	if err := i.runtime.Invoke(bytes.NewReader(b), i.env, args...); err != nil {
+		return nil, fmt.Errorf("invocation failed: %s\n%s", err, i.stdout.Bytes())
+	}
+	duration := time.Since(start)
+	logs, resp, _, status, err := shared.ParseStdoutWithHeader(i.stdout)
+	if err != nil {
+		return nil, err
+	}
+	return &shellResult{
+		status:   status,
+		body:     resp,
+		logs:     logs,
+		duration: duration,
+	}, nil
+}
+
+func (i *localInvoker) close() {
+	i.runtime.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// handleUsage prints the usage of the projects in the current month against
+// their quotas.
+func (c command) handleUsage(args []string) {
+	flagset := flag.NewFlagSet("usage", flag.ExitOnError)
+	var project string
+	flagset.StringVar(&project, "project", "", "Only show the usage of the project")
+	_ = flagset.Parse(args)
+
+	usage, err := c.client.GetUsage(project)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	t := newTable(
+		column{header: "project"},
+		column{header: "period", wide: true},
+		column{header: "endpoints"},
+		column{header: "deployments"},
+		column{header: "invocations"},
+		column{header: "gb-seconds"},
+	)
+	for _, u := range usage {
+		name := u.Usage.Project
+		if len(name) == 0 {
+			name = "-"
+		}
+		t.add(name,
+			u.Usage.Period.Format("2006-01"),
+			ofQuota(strconv.Itoa(u.Usage.Endpoints), u.Quota.MaxEndpoints > 0, strconv.Itoa(u.Quota.MaxEndpoints)),
+			ofQuota(strconv.Itoa(u.Usage.Deployments), u.Quota.MaxDeployments > 0, strconv.Itoa(u.Quota.MaxDeployments)),
+			ofQuota(strconv.FormatInt(u.Usage.Invocations, 10), u.Quota.MaxInvocations > 0, strconv.FormatInt(u.Quota.MaxInvocations, 10)),
+			ofQuota(fmt.Sprintf("%.2f", u.Usage.GBSeconds), u.Quota.MaxGBSeconds > 0, fmt.Sprintf("%g", u.Quota.MaxGBSeconds)),
+		)
+	}
+	printList(usage, t)
+}
+
+// ofQuota returns the used amount followed by the quota when there is one.
+func ofQuota(used string, limited bool, max string) string {
+	if !limited {
+		return used
+	}
+	return used + "/" + max
+}
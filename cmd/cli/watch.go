@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/signing"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// pathState is the state of a watched file or directory, a change of any
+// of its fields is a change of the path.
+type pathState struct {
+	modTime time.Time
+	size    int64
+	files   int
+}
+
+// statPath returns the state of the file, or of the files in the directory
+// that are not skipped.
+func statPath(path string, skip func(string) bool) (pathState, error) {
+	var state pathState
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != path && skip != nil && skip(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(state.modTime) {
+			state.modTime = info.ModTime()
+		}
+		state.size += info.Size()
+		state.files++
+		return nil
+	})
+	return state, err
+}
+
+// watchPath calls changed whenever the path changes. A change is picked up
+// once the path is stable for an interval, so files that are still being
+// written are not picked up halfway.
+func watchPath(path string, interval time.Duration, skip func(string) bool, changed func()) {
+	current, _ := statPath(path, skip)
+	var pending *pathState
+	for range time.Tick(interval) {
+		state, err := statPath(path, skip)
+		if err != nil || state == current {
+			pending = nil
+			continue
+		}
+		if pending == nil || state != *pending {
+			pending = &state
+			continue
+		}
+		pending, current = nil, state
+		changed()
+	}
+}
+
+// watchOptions are the settings of "raptor deploy --watch".
+type watchOptions struct {
+	// Command building the file, the sources are watched instead of the
+	// file when set.
+	build    string
+	debounce time.Duration
+}
+
+// watchDeploy creates and publishes a deployment of the file whenever it
+// changes, or whenever the sources in the current directory change and the
+// build command changed the file. It runs until it is interrupted.
+func (c command) watchDeploy(endpointID uuid.UUID, file string, params api.CreateDeploymentParams, signKey string, opts watchOptions) {
+	var last [sha256.Size]byte
+	deploy := func() {
+		if len(opts.build) > 0 {
+			fmt.Printf("building: %s\n", opts.build)
+			cmd := exec.Command("sh", "-c", opts.build)
+			cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				fmt.Printf("build failed: %s\n", err)
+				return
+			}
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("failed to read %s: %s\n", file, err)
+			return
+		}
+		if sum := sha256.Sum256(b); sum != last {
+			last = sum
+		} else {
+			fmt.Printf("%s did not change\n", file)
+			return
+		}
+		deploy, err := c.createDeployment(endpointID, b, params, signKey)
+		if err != nil {
+			fmt.Printf("failed to deploy %s: %s\n", file, err)
+			return
+		}
+		resp, err := c.client.Publish(api.PublishParams{DeploymentID: deploy.ID})
+		if err != nil {
+			fmt.Printf("failed to publish deployment %s: %s\n", deploy.ID, err)
+			return
+		}
+		fmt.Printf("%s deployment %s is LIVE at %s\n", time.Now().Format(time.TimeOnly), deploy.ID, resp.URL)
+		fmt.Printf("deploy preview: %s\n", deploy.PreviewURL)
+	}
+	deploy()
+
+	path, skip := file, func(string) bool { return false }
+	if len(opts.build) > 0 {
+		abs, _ := filepath.Abs(file)
+		path, skip = ".", func(p string) bool {
+			if a, _ := filepath.Abs(p); a == abs {
+				return true
+			}
+			return ignoredSourceDirs[filepath.Base(p)] || filepath.Ext(p) == ".wasm"
+		}
+	}
+	fmt.Printf("watching %s for changes (ctrl+c to exit)\n", path)
+	go watchPath(path, opts.debounce, skip, deploy)
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+	<-sigch
+}
+
+// createDeployment creates a deployment of the module, signed with the
+// private key in the signKey file when given.
+func (c command) createDeployment(endpointID uuid.UUID, b []byte, params api.CreateDeploymentParams, signKey string) (*types.Deployment, error) {
+	signature, err := sign(b, signKey)
+	if err != nil {
+		return nil, err
+	}
+	params.Signature = signature
+	return c.client.CreateDeployment(endpointID, bytes.NewReader(b), params)
+}
+
+// release deploys and publishes the blob in one call and prints the result.
+func (c command) release(endpointID uuid.UUID, b []byte, params api.CreateDeploymentParams, signKey string) {
+	signature, err := sign(b, signKey)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	resp, err := c.client.Release(endpointID, bytes.NewReader(b), api.ReleaseParams{
+		Assets:    params.Assets,
+		Signature: signature,
+		Manifest:  api.ReleaseManifest{DeploymentMetadata: params.Metadata},
+	})
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printResult(resp, resp.DeploymentID.String())
+	if humanOutput() {
+		fmt.Println()
+		fmt.Printf("deploy preview: %s\n", resp.PreviewURL)
+		if resp.Rollout != nil {
+			fmt.Printf("rollout %s is at %d%% of the traffic, follow it with: raptor endpoint rollout %s\n",
+				resp.Rollout.ID, resp.Rollout.Weight(), resp.EndpointID)
+			return
+		}
+		fmt.Printf("LIVE: %s\n", resp.URL)
+	}
+}
+
+// sign returns the signature of the blob with the private key in the given
+// file, nil without a key file.
+func sign(b []byte, keyFile string) ([]byte, error) {
+	if len(keyFile) == 0 {
+		return nil, nil
+	}
+	s, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := signing.ParsePrivateKey(string(s))
+	if err != nil {
+		return nil, err
+	}
+	return signing.Sign(key, b), nil
+}
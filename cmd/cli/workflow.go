@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// handleWorkflow starts a workflow of an endpoint (start) or shows a
+// workflow with its state (get).
+func (c command) handleWorkflow(args []string) {
+	switch args[0] {
+	case "start":
+		flagset := flag.NewFlagSet("workflow", flag.ExitOnError)
+		var method string
+		flagset.StringVar(&method, "method", "POST", "The method of the request of the steps")
+		var path string
+		flagset.StringVar(&path, "path", "/", "The path of the request of the steps, relative to the endpoint")
+		var headers stringList
+		flagset.Var(&headers, "header", "Headers of the request of the steps (--header \"Content-Type: application/json\")")
+		var data string
+		flagset.StringVar(&data, "data", "", "The body of the request of the steps, @file reads the body from a file and @- from stdin")
+
+		args = args[1:]
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			printErrorAndExit(fmt.Errorf("provide an endpoint: raptor workflow start <endpoint>"))
+		}
+		endpointID := c.resolveEndpoint(args[0])
+		_ = flagset.Parse(args[1:])
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		body, err := readInvokeBody(data)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		req := types.AsyncRequest{Method: method, Path: path, Header: make(http.Header), Body: body}
+		for _, header := range headers {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				printErrorAndExit(fmt.Errorf("headers need to be in the format of --header \"name: value\""))
+			}
+			req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		workflow, err := c.client.StartWorkflow(endpointID, req)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(workflow, workflow.ID.String())
+	case "get":
+		if len(args) < 2 {
+			printErrorAndExit(fmt.Errorf("provide a workflow id: raptor workflow get <workflow-id>"))
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid workflow id given: %s", args[1]))
+		}
+		workflow, err := c.client.GetWorkflow(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printResult(workflow, workflow.ID.String())
+	default:
+		printUsage()
+	}
+}
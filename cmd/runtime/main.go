@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"flag"
 	"log"
 	"os"
@@ -10,7 +14,14 @@ import (
 	"github.com/anthdm/hollywood/actor"
 	"github.com/anthdm/hollywood/cluster"
 	"github.com/anthdm/raptor/internal/actrs"
+	"github.com/anthdm/raptor/internal/admin"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/encryption"
+	"github.com/anthdm/raptor/internal/export"
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/provider"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/storage"
 )
 
@@ -20,18 +31,26 @@ func main() {
 		address    string
 		id         string
 		region     string
+		adminAddr  string
 	)
 
 	flagSet := flag.NewFlagSet("runtime", flag.ExitOnError)
 	flagSet.StringVar(&configFile, "config", "config.toml", "")
-	flagSet.StringVar(&address, "cluster-addr", "127.0.0.1:8134", "")
-	flagSet.StringVar(&id, "id", "runtime", "")
-	flagSet.StringVar(&region, "region", "default", "")
+	flagSet.StringVar(&address, "cluster-addr", "", "")
+	flagSet.StringVar(&id, "id", "", "")
+	flagSet.StringVar(&region, "region", "", "")
+	flagSet.StringVar(&adminAddr, "admin-addr", "127.0.0.1:8135", "")
 	flagSet.Parse(os.Args[1:])
 
 	if err := config.Parse(configFile); err != nil {
 		log.Fatal(err)
 	}
+	if err := config.Get().Validate(); err != nil {
+		log.Fatalf("invalid config:\n%s", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: config.LogLevel()})))
+	// The reloadable settings of the config are reloaded on SIGHUP.
+	config.ReloadOnSignal(configFile)
 
 	var (
 		user    = config.Get().Storage.User
@@ -41,28 +60,115 @@ func main() {
 		port    = config.Get().Storage.Port
 		sslmode = config.Get().Storage.SSLMode
 	)
-	store, err := storage.NewSQLStore(user, pw, dbname, host, port, sslmode)
+	sqlStore, err := storage.NewSQLStore(user, pw, dbname, host, port, sslmode)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var endpointStore storage.Store = sqlStore
+	if masterKey := config.Get().Encryption.MasterKey; len(masterKey) > 0 {
+		keyring, err := encryption.NewKeyring(masterKey, config.Get().Encryption.PreviousMasterKeys...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		endpointStore = storage.NewEncryptedStore(sqlStore, keyring)
+	}
+	var verifier *signing.Verifier
+	if c := config.Get().Signing; len(c.PublicKeys) > 0 || c.Required {
+		verifier, err = signing.NewVerifier(c.PublicKeys, c.Required)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 	var (
-		modCache = storage.NewDefaultModCache()
-		// metricStore = store
+		modCache      = storage.NewDefaultModCache()
+		compiler      = runtime.NewCompiler(modCache)
+		latencies     = storage.NewLatencies()
+		slowThreshold = time.Duration(config.Get().Storage.SlowThreshold)
+		metricStore   = storage.NewInstrumentedMetricStore(sqlStore, latencies, slowThreshold)
 	)
+	var store storage.Store = storage.NewInstrumentedStore(endpointStore, latencies, slowThreshold)
+	// The endpoints and the deployments are looked up for every request,
+	// the changes made by the API server are published to the cache.
+	if ttl := time.Duration(config.Get().Storage.CacheTTL); ttl > 0 {
+		cache := storage.NewCachedStore(store, ttl)
+		if err := sqlStore.ListenInvalidations(cache); err != nil {
+			log.Fatal(err)
+		}
+		store = cache
+	}
+	// The flags take precedence over the cluster section of the config.
+	if len(region) == 0 {
+		region = config.Get().Cluster.Region
+	}
+	if len(address) == 0 {
+		address = config.Get().Cluster.RuntimeListenAddr
+	}
+	if len(address) == 0 {
+		address = "127.0.0.1:8134"
+	}
+	if len(id) == 0 {
+		id = provider.MemberID(config.Get().Cluster, "runtime")
+	}
+	if config.Get().Cluster.Provider == provider.ProviderKubernetes {
+		id, address, err = provider.KubernetesMember(config.Get().Cluster.Kubernetes.Port)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	clusterProvider, err := provider.New(config.Get().Cluster)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The runtimes are activated by the ingress, only its strategy uses
+	// the loads of the members.
+	activation, err := actrs.NewActivationStrategy(config.Get().Cluster.Activation, actrs.NewMemberLoads())
+	if err != nil {
+		log.Fatal(err)
+	}
 	clusterConfig := cluster.NewConfig().
 		WithListenAddr(address).
 		WithRegion(region).
-		WithID(id)
+		WithID(id).
+		WithProvider(clusterProvider).
+		WithActivationStrategy(activation)
 	c, err := cluster.New(clusterConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
-	c.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, modCache), &cluster.KindConfig{})
-	c.Engine().Spawn(actrs.NewMetric, actrs.KindMetric, actor.WithID("1"))
-	c.Engine().Spawn(actrs.NewRuntimeLog, actrs.KindRuntimeLog, actor.WithID("1"))
+	tracker := admin.NewTracker()
+	c.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, compiler, tracker, verifier, region), &cluster.KindConfig{})
+	metricPID := c.Engine().Spawn(actrs.NewMetric(metricStore, notify.New(store)), actrs.KindMetric, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewLoadReporter(id, tracker), actrs.KindLoadReporter, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewCanceler(), actrs.KindCanceler, actor.WithID("1"))
+	sink, err := export.NewSink(config.Get().Export)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Engine().Spawn(actrs.NewRuntimeLog(sink, export.Interval(config.Get().Export), id), actrs.KindRuntimeLog, actor.WithID("1"))
 	c.Start()
 
+	go func() {
+		if err := actrs.WarmModCache(context.Background(), store, compiler); err != nil {
+			slog.Warn("failed to warm up the mod cache", "err", err)
+		}
+		tracker.SetWarm()
+	}()
+	if interval := time.Duration(config.Get().Deployments.GCInterval); interval > 0 {
+		go actrs.SweepModCache(context.Background(), store, modCache, interval)
+	}
+	adminServer := admin.NewServer(id, tracker, func() int { return len(c.Members()) }).WithRegion(region)
+	go func() {
+		log.Fatal(adminServer.Listen(adminAddr))
+	}()
+
 	sigch := make(chan os.Signal, 1)
 	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
-	<-sigch
+	select {
+	case <-sigch:
+	case <-adminServer.Shutdown():
+		// Leave the cluster gracefully, the member is drained already.
+		c.Stop().Wait()
+	}
+	// The buffered metrics are written before exiting.
+	c.Engine().Poison(metricPID).Wait()
 }
@@ -1,19 +1,5 @@
-// This should come from the official SDK.
-// But there is no official SDK yet, so we keep it here.
-function respond(res, status) {
-    var buffer = new ArrayBuffer(8);
-    var view = new DataView(buffer);
-    view.setUint32(0, status, true);
-    view.setUint32(4, res.length, true);
+// The request is exposed as the global "request" object and the response
+// is written with respond(body, status).
+console.log("handling request", request.method, request.url);
 
-    putstr(res);
-    writebytes(view)
-}
-
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-
-respond("Hello world!", 200)
\ No newline at end of file
+respond("Hello world! You requested " + request.url, 200)
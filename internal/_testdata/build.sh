@@ -1 +1,14 @@
-GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/helloworld.wasm internal/_testdata/helloworld.go 
\ No newline at end of file
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/helloworld.wasm internal/_testdata/helloworld.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/conformance.wasm internal/_testdata/conformance.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/websocket.wasm internal/_testdata/websocket.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/sse.wasm internal/_testdata/sse.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/progress.wasm internal/_testdata/progress.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/scratch.wasm internal/_testdata/scratch.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/platform.wasm internal/_testdata/platform.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/cache.wasm internal/_testdata/cache.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/invoke.wasm internal/_testdata/invoke.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/workflow.wasm internal/_testdata/workflow.go
+if rustup target list --installed 2>/dev/null | grep -q wasm32-wasip1; then
+	cargo build --manifest-path sdk/rust/Cargo.toml --example conformance --target wasm32-wasip1 --release
+	cp sdk/rust/target/wasm32-wasip1/release/examples/conformance.wasm internal/_testdata/conformance_rust.wasm
+fi
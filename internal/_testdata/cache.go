@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Counts its invocations in the cache and responds with the count. DELETE
+// resets the count.
+func handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		if err := raptor.CacheDelete("count"); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+		}
+		return
+	}
+	var count int
+	if b, ok := raptor.CacheGet("count"); ok {
+		count, _ = strconv.Atoi(string(b))
+	}
+	count++
+	if err := raptor.CacheSet("count", []byte(strconv.Itoa(count)), time.Minute); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
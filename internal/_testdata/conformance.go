@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// The conformance guest echoes the request so that the runtime can verify
+// the request made it through the ABI untouched.
+func handle(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if v, err := strconv.Atoi(r.Header.Get("X-Status")); err == nil {
+		status = v
+	}
+	fmt.Println("conformance guest log line")
+	w.Header().Set("X-Conformance", "ok")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s %s %s %s %s", r.Method, r.URL, r.Header.Get("X-Echo"), b, os.Getenv("FOO"))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
@@ -0,0 +1,6 @@
+// The conformance guest echoes the request so that the runtime can verify
+// the request made it through the ABI untouched.
+var status = request.header["X-Status"] ? parseInt(request.header["X-Status"][0]) : 200;
+
+console.log("conformance guest log line");
+respond([request.method, request.url, request.header["X-Echo"][0], request.body, request.env["FOO"]].join(" "), status, {"X-Conformance": "ok"});
@@ -3,7 +3,7 @@ package main
 import (
 	"net/http"
 
-	raptor "github.com/anthdm/raptor/sdk"
+	raptor "github.com/anthdm/raptor/sdk/go"
 )
 
 func handle(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Invokes the endpoint of the endpoint query parameter with its body and
+// responds with the id of the invocation.
+func handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	id, err := raptor.InvokeAsync(r.URL.Query().Get("endpoint"), raptor.InvokeRequest{
+		Path: "/jobs",
+		Body: body,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte(id))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
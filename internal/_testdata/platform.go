@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Responds with its invocation context and the milliseconds left before it
+// times out, -1 without a timeout.
+func handle(w http.ResponseWriter, r *http.Request) {
+	invocation, err := raptor.CurrentInvocation()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	remaining := int64(-1)
+	if left, ok := raptor.RemainingTime(); ok {
+		remaining = left.Milliseconds()
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"invocation": invocation,
+		"remaining":  remaining,
+	})
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
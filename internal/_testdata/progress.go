@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Reports its progress before responding.
+func handle(w http.ResponseWriter, r *http.Request) {
+	raptor.Progress(50, "halfway")
+	raptor.Progress(100, "done")
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Writes the amount of bytes given in the body to a file in /tmp and
+// responds with the files that were in /tmp before.
+func handle(w http.ResponseWriter, r *http.Request) {
+	entries, err := os.ReadDir("/tmp")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	b, _ := io.ReadAll(r.Body)
+	size, _ := strconv.Atoi(string(b))
+	if err := os.WriteFile("/tmp/data", make([]byte, size), 0644); err != nil {
+		w.WriteHeader(http.StatusInsufficientStorage)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	fmt.Fprintf(w, "[%s]", strings.Join(names, ","))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Streams three Server-Sent Events.
+func handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(w, "data: %d\n\n", i)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
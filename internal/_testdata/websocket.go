@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Echoes the messages of the client prefixed with the path of the upgrade
+// request.
+func handle(r *http.Request, conn *raptor.Conn) {
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(append([]byte(r.URL.Path+": "), msg...)); err != nil {
+			return
+		}
+	}
+}
+
+func main() {
+	raptor.HandleWebSocket(handle)
+}
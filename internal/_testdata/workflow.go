@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	raptor "github.com/anthdm/raptor/sdk/go"
+)
+
+// Counts its steps in the state of the workflow and sleeps between them
+// until it counted to 3.
+func handle(w http.ResponseWriter, r *http.Request) {
+	state, err := raptor.WorkflowState()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	count, _ := strconv.Atoi(string(state))
+	count++
+	if err := raptor.SetWorkflowState([]byte(strconv.Itoa(count))); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if count < 3 {
+		raptor.WorkflowSleep(time.Millisecond * 10)
+	}
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
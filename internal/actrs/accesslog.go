@@ -0,0 +1,117 @@
+package actrs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// accessLog writes a JSON line for every request served by the ingress.
+type accessLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// accessLogEntry is a line of the access log.
+type accessLogEntry struct {
+	Time         time.Time `json:"time"`
+	RequestID    string    `json:"request_id"`
+	RemoteAddr   string    `json:"remote_addr"`
+	ClientIP     string    `json:"client_ip,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Protocol     string    `json:"protocol"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	EndpointID   string    `json:"endpoint_id,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	Preview      bool      `json:"preview,omitempty"`
+	Status       int       `json:"status"`
+	// Latency in milliseconds, until the response was written.
+	Latency  float64 `json:"latency_ms"`
+	BytesIn  int     `json:"bytes_in"`
+	BytesOut int     `json:"bytes_out"`
+}
+
+// newAccessLog returns the access log written to the destination, stdout,
+// stderr or the path of a file the lines are appended to.
+func newAccessLog(dest string) (*accessLog, error) {
+	var w io.Writer
+	switch dest {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		w = f
+	}
+	return &accessLog{enc: json.NewEncoder(w)}, nil
+}
+
+func (l *accessLog) write(entry accessLogEntry) {
+	if entry.Status == 0 {
+		// Nothing was written, the server answers with 200.
+		entry.Status = http.StatusOK
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(entry)
+}
+
+// accessRecorder records the status and the size of the response written
+// to the client for the access log.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *accessRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush lets the streamed responses through, the response controllers
+// unwrap the recorder.
+func (r *accessRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection of a WebSocket upgrade over, the status of
+// the upgrade is recorded.
+func (r *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (r *accessRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
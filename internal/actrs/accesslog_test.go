@@ -0,0 +1,50 @@
+package actrs
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	accessLog, err := newAccessLog(path)
+	require.Nil(t, err)
+	s := &WasmServer{tracker: admin.NewTracker(), accessLog: accessLog}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/invalid", nil)
+	r.Header.Set("User-Agent", "raptor-test")
+	s.ServeHTTP(w, r)
+	requestID := w.Header().Get(shared.RequestIDHeader)
+	require.NotEmpty(t, requestID)
+	require.Equal(t, requestID, r.Header.Get(shared.RequestIDHeader))
+
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	var entry accessLogEntry
+	require.Nil(t, json.Unmarshal(b, &entry))
+	require.Equal(t, requestID, entry.RequestID)
+	require.Equal(t, "GET", entry.Method)
+	require.Equal(t, "/invalid", entry.Path)
+	require.Equal(t, "raptor-test", entry.UserAgent)
+	require.Equal(t, w.Code, entry.Status)
+	require.Equal(t, w.Body.Len(), entry.BytesOut)
+	require.GreaterOrEqual(t, entry.Latency, 0.0)
+}
+
+func TestInvocationEnv(t *testing.T) {
+	msg := &proto.HTTPRequest{ID: "a8f3c1e2-1b2c-4d5e-8f90-123456789abc", Env: map[string]string{"FOO": "BAR"}}
+	env := invocationEnv(msg)
+	require.Equal(t, map[string]string{"FOO": "BAR", shared.RequestIDEnv: msg.ID}, env)
+	// The environment of the endpoint is shared by the invocations.
+	require.Len(t, msg.Env, 1)
+	require.Equal(t, msg.ID, invocationID(msg).String())
+}
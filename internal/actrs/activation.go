@@ -0,0 +1,70 @@
+package actrs
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/anthdm/hollywood/cluster"
+)
+
+// NewActivationStrategy returns the activation strategy with the given name,
+// region, random or load. The region strategy is the default. The load
+// strategy places the runtimes by the given loads of the members.
+func NewActivationStrategy(name string, loads *MemberLoads) (cluster.ActivationStrategy, error) {
+	switch name {
+	case "region", "":
+		return NewRegionActivationStrategy(), nil
+	case "random":
+		return cluster.NewDefaultActivationStrategy(), nil
+	case "load":
+		return NewLoadActivationStrategy(loads), nil
+	default:
+		return nil, fmt.Errorf("invalid activation strategy: %s", name)
+	}
+}
+
+type regionActivationStrategy struct{}
+
+// NewRegionActivationStrategy returns an activation strategy that selects a
+// random member in the requested region, falling back to a random member of
+// the whole cluster when there are no members available in that region.
+func NewRegionActivationStrategy() cluster.ActivationStrategy {
+	return regionActivationStrategy{}
+}
+
+func (regionActivationStrategy) ActivateOnMember(details cluster.ActivationDetails) *cluster.Member {
+	members := regionMembers(details)
+	return members[rand.Intn(len(members))]
+}
+
+type loadActivationStrategy struct {
+	loads *MemberLoads
+}
+
+// NewLoadActivationStrategy returns an activation strategy that selects the
+// least loaded member in the requested region by the in-flight invocations
+// and the CPU usage the members reported, falling back to the least loaded
+// member of the whole cluster when there are no members available in that
+// region. Members without a recent report count as idle.
+func NewLoadActivationStrategy(loads *MemberLoads) cluster.ActivationStrategy {
+	return loadActivationStrategy{loads: loads}
+}
+
+func (s loadActivationStrategy) ActivateOnMember(details cluster.ActivationDetails) *cluster.Member {
+	return s.loads.leastLoaded(regionMembers(details))
+}
+
+// regionMembers returns the members in the requested region, or all the
+// members when there are none in that region.
+func regionMembers(details cluster.ActivationDetails) []*cluster.Member {
+	members := make([]*cluster.Member, 0, len(details.Members))
+	for _, member := range details.Members {
+		if member.Region == details.Region {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return details.Members
+	}
+	return members
+}
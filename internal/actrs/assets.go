@@ -0,0 +1,65 @@
+package actrs
+
+import (
+	"sync"
+
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/google/uuid"
+)
+
+// maxCachedBundles is the maximum amount of deployments of which the asset
+// bundle is kept in memory by the ingress.
+const maxCachedBundles = 128
+
+// assetCache holds the opened asset bundles of the deployments served by the
+// ingress. Deployments are immutable, hence bundles never need to be
+// invalidated, only evicted. Deployments without assets are cached as well
+// so their deployment is only loaded once.
+type assetCache struct {
+	store   storage.Store
+	mu      sync.Mutex
+	bundles map[uuid.UUID]*assets.Bundle
+	order   []uuid.UUID
+}
+
+func newAssetCache(store storage.Store) *assetCache {
+	return &assetCache{
+		store:   store,
+		bundles: make(map[uuid.UUID]*assets.Bundle),
+	}
+}
+
+// get returns the asset bundle of the given deployment, nil is returned when
+// the deployment does not have any assets.
+func (c *assetCache) get(deploymentID uuid.UUID) (*assets.Bundle, error) {
+	c.mu.Lock()
+	bundle, ok := c.bundles[deploymentID]
+	c.mu.Unlock()
+	if ok {
+		return bundle, nil
+	}
+
+	deploy, err := c.store.GetDeployment(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deploy.Assets) > 0 {
+		bundle, err = assets.Open(deploy.Assets, deploy.CreatedAT)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.bundles[deploymentID]; !ok {
+		if len(c.order) >= maxCachedBundles {
+			delete(c.bundles, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, deploymentID)
+	}
+	c.bundles[deploymentID] = bundle
+	return bundle, nil
+}
@@ -0,0 +1,99 @@
+package actrs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/proto"
+)
+
+const KindCanceler = "canceler"
+
+// canceledTTL is how long the cancellation of a request that is not running
+// yet is remembered, the request can still be in the mailbox of its runtime.
+const canceledTTL = time.Minute
+
+// invocationSet holds the cancel functions of the invocations running on
+// this member. A runtime is busy while its guest runs, so the invocations
+// are canceled from outside of the runtime actors.
+type invocationSet struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	// The requests that were canceled before they started running.
+	canceled map[string]time.Time
+}
+
+func newInvocationSet() *invocationSet {
+	return &invocationSet{
+		running:  make(map[string]context.CancelFunc),
+		canceled: make(map[string]time.Time),
+	}
+}
+
+// invocations are the invocations of the runtimes of this member.
+var invocations = newInvocationSet()
+
+// start registers the invocation of the request, false is returned when the
+// request was canceled already.
+func (s *invocationSet) start(requestID string, cancel context.CancelFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.canceled[requestID]; ok {
+		delete(s.canceled, requestID)
+		return false
+	}
+	s.running[requestID] = cancel
+	return true
+}
+
+func (s *invocationSet) done(requestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, requestID)
+}
+
+// cancel cancels the invocation of the request, or the invocation once it
+// starts when the request is not running yet.
+func (s *invocationSet) cancel(requestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[requestID]; ok {
+		cancel()
+		return
+	}
+	now := time.Now()
+	for id, at := range s.canceled {
+		if now.Sub(at) > canceledTTL {
+			delete(s.canceled, id)
+		}
+	}
+	s.canceled[requestID] = now
+}
+
+// Canceler cancels the invocations of this member whose clients went away.
+type Canceler struct {
+	invocations *invocationSet
+}
+
+// NewCanceler returns a new canceler producer, every member hosting runtimes
+// spawns one.
+func NewCanceler() actor.Producer {
+	return func() actor.Receiver {
+		return &Canceler{invocations: invocations}
+	}
+}
+
+func (c *Canceler) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case *proto.CancelRequest:
+		c.invocations.cancel(msg.RequestID)
+	}
+}
+
+// cancelerPID returns the PID of the canceler of the member hosting the
+// runtime.
+func cancelerPID(runtime *actor.PID) *actor.PID {
+	return actor.NewPID(runtime.Address, KindCanceler+"/1")
+}
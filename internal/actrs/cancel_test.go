@@ -0,0 +1,52 @@
+package actrs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvocationSetCancel(t *testing.T) {
+	s := newInvocationSet()
+	ctx, cancel := context.WithCancel(context.Background())
+	require.True(t, s.start("1", cancel))
+	s.cancel("1")
+	require.Equal(t, context.Canceled, ctx.Err())
+	s.done("1")
+
+	// Requests canceled before they run are not started.
+	s.cancel("2")
+	require.False(t, s.start("2", func() {}))
+	require.True(t, s.start("2", func() {}))
+}
+
+func TestAwaitResponseClientGone(t *testing.T) {
+	reqres := newRequestWithResponse(&proto.HTTPRequest{ID: "1"}, "", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	resp, _, err := awaitResponse(ctx, w, reqres, 0)
+	require.Equal(t, context.Canceled, err)
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestAwaitResponseTimeout(t *testing.T) {
+	reqres := newRequestWithResponse(&proto.HTTPRequest{ID: "1"}, "", 0)
+	w := httptest.NewRecorder()
+	resp, streamed, err := awaitResponse(context.Background(), w, reqres, time.Millisecond*10)
+	require.Equal(t, errNoResponse, err)
+	require.Nil(t, resp)
+	require.False(t, streamed)
+	require.Empty(t, w.Body.String())
+
+	// The wait covers the timeout of the invocation, unless it has none.
+	require.Zero(t, responseTimeout(&proto.HTTPRequest{}))
+	require.GreaterOrEqual(t, responseTimeout(&proto.HTTPRequest{Timeout: 1000}), time.Second+inflightGrace)
+}
@@ -0,0 +1,94 @@
+package actrs
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies are the proxies in front of the ingress whose forwarding
+// headers are trusted.
+type trustedProxies []netip.Prefix
+
+func (t trustedProxies) trusts(addr netip.Addr) bool {
+	for _, prefix := range t {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client of the request. The
+// X-Forwarded-For header is walked from the right, the address the nearest
+// trusted proxy received the request from is the client. X-Real-IP is used
+// when the trusted proxy does not set X-Forwarded-For.
+func (t trustedProxies) clientIP(r *http.Request) netip.Addr {
+	peer := remoteAddr(r)
+	if !peer.IsValid() || !t.trusts(peer) {
+		return peer
+	}
+	forwarded := forwardedFor(r.Header)
+	if len(forwarded) == 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); err == nil {
+			return addr.Unmap()
+		}
+		return peer
+	}
+	client := peer
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(forwarded[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !t.trusts(client) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardClientIP sets the forwarding headers the guest receives. The
+// forwarded addresses are only kept when the peer is a trusted proxy, so the
+// clients can not spoof them.
+func (t trustedProxies) forwardClientIP(r *http.Request, client netip.Addr) {
+	peer := remoteAddr(r)
+	if !peer.IsValid() {
+		return
+	}
+	forwarded := []string{peer.String()}
+	if t.trusts(peer) {
+		forwarded = append(forwardedFor(r.Header), forwarded...)
+	}
+	r.Header.Set("X-Forwarded-For", strings.Join(forwarded, ", "))
+	r.Header.Set("X-Real-Ip", client.String())
+}
+
+// remoteAddr returns the address of the peer of the request.
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// forwardedFor returns the addresses of the X-Forwarded-For headers, from
+// the client to the nearest proxy.
+func forwardedFor(h http.Header) []string {
+	var addrs []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); len(addr) > 0 {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
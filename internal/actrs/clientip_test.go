@@ -0,0 +1,47 @@
+package actrs
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	prefixes, err := config.ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	require.Nil(t, err)
+	proxies := trustedProxies(prefixes)
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		client     string
+		header     string
+	}{
+		// The headers of untrusted peers are replaced.
+		{"203.0.113.7:4000", "1.2.3.4", "1.2.3.4", "203.0.113.7", "203.0.113.7"},
+		// The first untrusted address from the right is the client.
+		{"10.0.0.2:4000", "1.2.3.4, 198.51.100.1, 10.0.0.3", "", "198.51.100.1", "1.2.3.4, 198.51.100.1, 10.0.0.3, 10.0.0.2"},
+		{"192.0.2.1:4000", "", "198.51.100.1", "198.51.100.1", "192.0.2.1"},
+		{"10.0.0.2:4000", "", "", "10.0.0.2", "10.0.0.2"},
+		{"[::ffff:10.0.0.2]:4000", "198.51.100.1", "", "198.51.100.1", "198.51.100.1, 10.0.0.2"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/live/id", nil)
+		r.RemoteAddr = test.remoteAddr
+		if len(test.forwarded) > 0 {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if len(test.realIP) > 0 {
+			r.Header.Set("X-Real-Ip", test.realIP)
+		}
+		client := proxies.clientIP(r)
+		require.Equal(t, netip.MustParseAddr(test.client), client, test.remoteAddr)
+		proxies.forwardClientIP(r, client)
+		require.Equal(t, test.header, r.Header.Get("X-Forwarded-For"))
+		require.Equal(t, test.client, r.Header.Get("X-Real-Ip"))
+	}
+}
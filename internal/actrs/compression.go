@@ -0,0 +1,94 @@
+package actrs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// compressibleTypes are the media types that are compressed next to the
+// text/* ones. Images, video and archives are already compressed.
+var compressibleTypes = map[string]bool{
+	"application/javascript": true,
+	"application/json":       true,
+	"application/wasm":       true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+// writeBody writes the status and the body of a response of which the
+// headers are already set. The body is gzip compressed when the endpoint
+// has compression enabled and the client accepts it.
+func writeBody(w http.ResponseWriter, r *http.Request, compression *types.Compression, status int, body []byte) {
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(body)
+	}
+	if !compressible(compression, header, contentType, status, body) {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+	if r.Method == http.MethodHead || !shared.AcceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	level := compression.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	buf := new(bytes.Buffer)
+	gz, err := gzip.NewWriterLevel(buf, level)
+	if err == nil {
+		_, err = gz.Write(body)
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	// The content type would be sniffed from the compressed body otherwise.
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func compressible(compression *types.Compression, header http.Header, contentType string, status int, body []byte) bool {
+	if compression == nil || !compression.Enabled {
+		return false
+	}
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		return false
+	}
+	if len(header.Get("Content-Encoding")) > 0 {
+		return false
+	}
+	minSize := compression.MinSize
+	if minSize == 0 {
+		minSize = types.DefaultCompressionMinSize
+	}
+	if len(body) < minSize {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		compressibleTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
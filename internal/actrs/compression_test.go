@@ -0,0 +1,61 @@
+package actrs
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBodyCompression(t *testing.T) {
+	var (
+		compression = &types.Compression{Enabled: true}
+		body        = []byte(`{"users":[` + strings.Repeat(`{"name":"raptor"},`, 100) + `{}]}`)
+	)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("Accept-Encoding", "gzip, br")
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, r, compression, 200, body)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Less(t, w.Body.Len(), len(body))
+	gz, err := gzip.NewReader(w.Body)
+	require.Nil(t, err)
+	b, err := io.ReadAll(gz)
+	require.Nil(t, err)
+	require.Equal(t, body, b)
+
+	// Clients that do not accept gzip get the plain body.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users", nil)
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, r, compression, 200, body)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Equal(t, body, w.Body.Bytes())
+
+	for _, test := range []struct {
+		compression *types.Compression
+		contentType string
+		body        []byte
+	}{
+		{compression: nil, contentType: "application/json", body: body},
+		{compression: compression, contentType: "image/png", body: body},
+		{compression: compression, contentType: "application/json", body: []byte(`{}`)},
+		{compression: &types.Compression{Enabled: true, MinSize: len(body) + 1}, contentType: "text/html", body: body},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w.Header().Set("Content-Type", test.contentType)
+		writeBody(w, r, test.compression, 200, test.body)
+		require.Empty(t, w.Header().Get("Content-Encoding"))
+		require.Equal(t, test.body, w.Body.Bytes())
+	}
+}
@@ -0,0 +1,82 @@
+package actrs
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// concurrencyInterval is the interval the concurrency of the endpoints is
+// sampled at.
+const concurrencyInterval = 5 * time.Second
+
+type sampleConcurrency struct{}
+
+// concurrency returns the in-flight and queued invocations of the endpoints
+// with invocations at the ingress.
+func (s *WasmServer) concurrency() map[string]admin.EndpointConcurrency {
+	endpoints := make(map[string]admin.EndpointConcurrency)
+	for _, inflight := range s.inflight {
+		e := endpoints[inflight.endpointID]
+		e.Inflight++
+		endpoints[inflight.endpointID] = e
+	}
+	for endpointID, queue := range s.queues {
+		if len(queue) == 0 {
+			continue
+		}
+		e := endpoints[endpointID]
+		e.Queued = len(queue)
+		endpoints[endpointID] = e
+	}
+	for endpointID, e := range endpoints {
+		e.Limit = s.limits[endpointID]
+		endpoints[endpointID] = e
+	}
+	return endpoints
+}
+
+// sampleConcurrency publishes the concurrency of the endpoints to the admin
+// server of the member for autoscalers, stores it through the metric actor
+// and broadcasts it on the event stream of the engine. The endpoints whose
+// invocations all finished since the previous sample are reported once more
+// with zeros.
+func (s *WasmServer) sampleConcurrency(c *actor.Context) {
+	endpoints := s.concurrency()
+	s.tracker.SetConcurrency(endpoints)
+
+	var (
+		now       = time.Now()
+		metricPID = c.Engine().Registry.GetPID(KindMetric, "1")
+		sampled   = make(map[string]bool, len(endpoints))
+	)
+	for endpointID := range s.sampled {
+		if _, ok := endpoints[endpointID]; !ok {
+			endpoints[endpointID] = admin.EndpointConcurrency{Limit: s.limits[endpointID]}
+		}
+	}
+	for endpointID, e := range endpoints {
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			continue
+		}
+		if e.Inflight > 0 || e.Queued > 0 {
+			sampled[endpointID] = true
+		}
+		metric := types.ConcurrencyMetric{
+			ID:         uuid.New(),
+			EndpointID: id,
+			Member:     s.cluster.ID(),
+			Inflight:   e.Inflight,
+			Queued:     e.Queued,
+			Limit:      e.Limit,
+			CreatedAT:  now,
+		}
+		c.Send(metricPID, metric)
+		c.Engine().BroadcastEvent(metric)
+	}
+	s.sampled = sampled
+}
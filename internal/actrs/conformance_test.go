@@ -0,0 +1,92 @@
+package actrs
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// The ABI conformance suite runs the conformance guest of every SDK against
+// the Runtime actor. The guests are compiled by internal/_testdata/build.sh,
+// guests that are not compiled are skipped.
+var conformanceGuests = []struct {
+	name    string
+	file    string
+	runtime string
+}{
+	{name: "go", file: "../_testdata/conformance.wasm", runtime: "go"},
+	{name: "rust", file: "../_testdata/conformance_rust.wasm", runtime: "go"},
+	{name: "js", file: "../_testdata/conformance.js", runtime: "js"},
+}
+
+func TestABIConformance(t *testing.T) {
+	for _, guest := range conformanceGuests {
+		t.Run(guest.name, func(t *testing.T) {
+			blob, err := os.ReadFile(guest.file)
+			if os.IsNotExist(err) {
+				t.Skipf("conformance guest %s is not compiled", guest.file)
+			}
+			require.Nil(t, err)
+
+			var (
+				store    = storage.NewMemoryStore()
+				endpoint = types.NewEndpoint("conformance", guest.runtime, map[string]string{"FOO": "BAR"})
+				deploy   = types.NewDeployment(endpoint, blob)
+			)
+			require.Nil(t, store.CreateEndpoint(endpoint))
+			require.Nil(t, store.CreateDeployment(deploy))
+
+			e, err := actor.NewEngine(nil)
+			require.Nil(t, err)
+			producer := NewRuntime(store, runtime.NewCompiler(storage.NewDefaultModCache()), admin.NewTracker(), nil, "")
+
+			cases := []struct {
+				status int
+				body   string
+			}{
+				{status: http.StatusOK, body: "hello"},
+				{status: http.StatusCreated, body: "héllo wörld"},
+				{status: http.StatusTeapot, body: ""},
+			}
+			for _, c := range cases {
+				req := &proto.HTTPRequest{
+					ID:           uuid.NewString(),
+					Method:       "POST",
+					URL:          "/echo?x=1",
+					Body:         []byte(c.body),
+					EndpointID:   endpoint.ID.String(),
+					DeploymentID: deploy.ID.String(),
+					Runtime:      guest.runtime,
+					Env:          endpoint.Environment,
+					Preview:      true,
+					Header: map[string]*proto.HeaderFields{
+						"X-Echo":   {Fields: []string{"echo"}},
+						"X-Status": {Fields: []string{strconv.Itoa(c.status)}},
+					},
+				}
+				// Runtimes shut down shortly after serving a request, hence every
+				// request gets its own runtime like the runtime manager would do.
+				pid := e.Spawn(producer, KindRuntime)
+				res, err := e.Request(pid, req, time.Second*10).Result()
+				require.Nil(t, err)
+				resp, ok := res.(*proto.HTTPResponse)
+				require.True(t, ok)
+				require.Equal(t, req.ID, resp.RequestID)
+				require.Equal(t, int32(c.status), resp.StatusCode)
+				require.Equal(t, "POST /echo?x=1 echo "+c.body+" BAR", string(resp.Response))
+				require.Equal(t, []string{"ok"}, resp.Header["X-Conformance"].GetFields())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package actrs
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// serveCORS applies the CORS policy of the endpoint to the request. The
+// headers of the policy are set on the response of an allowed origin and
+// the preflight requests are answered right away, the function is not
+// invoked. Returns true if the request was answered.
+func serveCORS(w http.ResponseWriter, r *http.Request, cors *types.CORS) bool {
+	if !cors.Enabled() {
+		return false
+	}
+	policy := cors.Bound(shared.GlobalCORS())
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) > 0
+	h := w.Header()
+	if !policy.AnyOrigin() || policy.AllowCredentials {
+		h.Add("Vary", "Origin")
+	}
+	if !policy.AllowOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("origin is not allowed"))
+		}
+		return preflight
+	}
+	if policy.AnyOrigin() && !policy.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(policy.ExposedHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+		}
+		return false
+	}
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	// Browsers only treat * as a wildcard for the requests without
+	// credentials, what was requested is allowed instead.
+	if methods := policy.AllowedMethods; len(methods) > 0 {
+		if slices.Contains(methods, "*") {
+			methods = []string{r.Header.Get("Access-Control-Request-Method")}
+		}
+		h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	}
+	if headers := policy.AllowedHeaders; len(headers) > 0 {
+		if slices.Contains(headers, "*") {
+			headers = []string{r.Header.Get("Access-Control-Request-Headers")}
+		}
+		h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if policy.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// isCORSHeader returns true if the header belongs to a CORS policy, the
+// headers of the policy of the endpoint take precedence over the ones the
+// function writes.
+func isCORSHeader(name string) bool {
+	return strings.HasPrefix(http.CanonicalHeaderKey(name), "Access-Control-")
+}
@@ -0,0 +1,59 @@
+package actrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeCORS(t *testing.T) {
+	cors := &types.CORS{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		MaxAge:           600,
+		AllowCredentials: true,
+	}
+
+	preflight := httptest.NewRequest("OPTIONS", "/live/id/orders", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "PUT")
+	preflight.Header.Set("Access-Control-Request-Headers", "content-type")
+	w := httptest.NewRecorder()
+	require.True(t, serveCORS(w, preflight, cors))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "content-type", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	preflight.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	require.True(t, serveCORS(w, preflight, cors))
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// The actual requests reach the function with the headers of the
+	// policy, which take precedence over the headers of the function.
+	r := httptest.NewRequest("GET", "/live/id/orders", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	require.False(t, serveCORS(w, r, cors))
+	require.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	copyHeader(w.Header(), http.Header{"Access-Control-Allow-Origin": {"*"}, "Content-Type": {"text/plain"}})
+	require.Equal(t, []string{"https://app.example.com"}, w.Header().Values("Access-Control-Allow-Origin"))
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+
+	// Without a policy the request is left to the function.
+	w = httptest.NewRecorder()
+	require.False(t, serveCORS(w, preflight, nil))
+	require.Empty(t, w.Header())
+	copyHeader(w.Header(), http.Header{"Access-Control-Allow-Origin": {"*"}})
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,26 @@
+package actrs
+
+import (
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// inheritEnvironment returns a copy of the endpoint with the defaults of its
+// project added to its environment, the variables of the endpoint override
+// them. The endpoints without a project are returned as they are.
+func inheritEnvironment(store storage.Store, endpoint *types.Endpoint) (*types.Endpoint, error) {
+	project := endpoint.Owner()
+	if len(project) == 0 {
+		return endpoint, nil
+	}
+	defaults, err := store.GetProjectEnvironment(project)
+	if err != nil {
+		return nil, err
+	}
+	if len(defaults.Environment) == 0 {
+		return endpoint, nil
+	}
+	e := *endpoint
+	e.Environment = types.InheritEnvironment(defaults.Environment, endpoint.Environment)
+	return &e, nil
+}
@@ -0,0 +1,31 @@
+package actrs
+
+import (
+	"testing"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInheritEnvironment(t *testing.T) {
+	store := storage.NewMemoryStore()
+	require.Nil(t, store.UpdateProjectEnvironment(types.NewProjectEnvironment("payments", map[string]string{
+		"DATABASE_URL":   "postgres://db",
+		"WEBHOOK_SECRET": "shared",
+	})))
+
+	endpoint := types.NewEndpoint("checkout", "go", map[string]string{"WEBHOOK_SECRET": "own"})
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+	inherited, err := inheritEnvironment(store, endpoint)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"DATABASE_URL": "postgres://db", "WEBHOOK_SECRET": "own"}, inherited.Environment)
+	// The endpoint of the store is left as it is.
+	require.Equal(t, map[string]string{"WEBHOOK_SECRET": "own"}, endpoint.Environment)
+
+	// Endpoints without a project have no defaults.
+	endpoint.Ownership = nil
+	inherited, err = inheritEnvironment(store, endpoint)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.Environment, inherited.Environment)
+}
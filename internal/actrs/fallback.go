@@ -0,0 +1,44 @@
+package actrs
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/anthdm/raptor/proto"
+)
+
+// respondUnavailable responds with 503 when the cluster can not serve the
+// request, unless the endpoint has a fallback origin the ingress proxies the
+// request to instead.
+func respondUnavailable(msg requestWithResponse, text string) {
+	if msg.unavailable != nil {
+		msg.unavailable <- struct{}{}
+		return
+	}
+	respondWithStatus(msg, http.StatusServiceUnavailable, text)
+}
+
+// serveFallback proxies the request to the fallback origin of the endpoint.
+// The body of the request was already read into the proto request.
+func serveFallback(w http.ResponseWriter, r *http.Request, origin *url.URL, req *proto.HTTPRequest) {
+	r.URL.Path = req.URL
+	r.URL.RawPath = ""
+	r.Body = io.NopCloser(bytes.NewReader(req.Body))
+	r.ContentLength = int64(len(req.Body))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(origin)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("fallback origin failed", "origin", origin.Host, "err", err)
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("fallback origin unavailable"))
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
@@ -0,0 +1,50 @@
+package actrs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondUnavailable(t *testing.T) {
+	msg := newRequestWithResponse(&proto.HTTPRequest{ID: "1"}, "", 0)
+	respondUnavailable(msg, "no runtime available")
+	resp, _, _ := awaitResponse(context.Background(), httptest.NewRecorder(), msg, 0)
+	require.Equal(t, int32(http.StatusServiceUnavailable), resp.StatusCode)
+
+	msg.unavailable = make(chan struct{}, 1)
+	respondUnavailable(msg, "no runtime available")
+	resp, _, _ = awaitResponse(context.Background(), httptest.NewRecorder(), msg, 0)
+	require.Nil(t, resp)
+}
+
+func TestServeFallback(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(b)))
+	}))
+	defer origin.Close()
+	u, err := url.Parse(origin.URL + "/legacy")
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("POST", "/live/09248ef6-c401-4601-8928-5964d61f2c61/users?page=2", strings.NewReader("body"))
+	req, err := shared.MakeProtoRequest("1", r)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	serveFallback(w, r, u, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "POST /legacy/users?page=2 body", w.Body.String())
+
+	origin.Close()
+	w = httptest.NewRecorder()
+	serveFallback(w, r, u, req)
+	require.Equal(t, http.StatusBadGateway, w.Code)
+}
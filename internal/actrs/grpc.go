@@ -0,0 +1,48 @@
+package actrs
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+)
+
+// grpcEndpointHeader is the request header (gRPC metadata) that selects the
+// LIVE endpoint of gRPC calls, gRPC clients can not prefix the path of their
+// calls with /live/<endpoint>.
+const grpcEndpointHeader = "Raptor-Endpoint"
+
+// isGRPC returns true if the request is a gRPC call.
+func isGRPC(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+")
+}
+
+// routeGRPC prefixes the path of a gRPC call with the LIVE endpoint of its
+// endpoint header, so the call is served like any other LIVE request.
+func routeGRPC(r *http.Request) {
+	if !isGRPC(r) {
+		return
+	}
+	id := r.Header.Get(grpcEndpointHeader)
+	if len(id) == 0 {
+		return
+	}
+	r.URL.Path = "/live/" + id + r.URL.Path
+}
+
+// declareTrailer announces the trailers of the response, it needs to be
+// called before the header is written.
+func declareTrailer(h http.Header, trailer map[string]*proto.HeaderFields) {
+	for k := range trailer {
+		h.Add("Trailer", k)
+	}
+}
+
+// writeTrailer sets the trailers of the response once its body is written.
+func writeTrailer(h http.Header, trailer map[string]*proto.HeaderFields) {
+	for k, values := range shared.HeaderFromProto(trailer) {
+		h[http.TrailerPrefix+k] = values
+	}
+}
@@ -0,0 +1,39 @@
+package actrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteGRPC(t *testing.T) {
+	r := httptest.NewRequest("POST", "/helloworld.Greeter/SayHello", nil)
+	r.Header.Set(grpcEndpointHeader, "09248ef6-c401-4601-8928-5964d61f2c61")
+	routeGRPC(r)
+	require.Equal(t, "/helloworld.Greeter/SayHello", r.URL.Path)
+
+	r.Header.Set("Content-Type", "application/grpc+proto")
+	routeGRPC(r)
+	require.Equal(t, "/live/09248ef6-c401-4601-8928-5964d61f2c61/helloworld.Greeter/SayHello", r.URL.Path)
+}
+
+func TestWriteTrailer(t *testing.T) {
+	trailer := shared.MakeProtoHeader(http.Header{
+		"Grpc-Status":  []string{"0"},
+		"Grpc-Message": []string{""},
+	})
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/grpc")
+	declareTrailer(w.Header(), trailer)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte{0, 0, 0, 0, 0})
+	writeTrailer(w.Header(), trailer)
+
+	res := w.Result()
+	require.Equal(t, "application/grpc", res.Header.Get("Content-Type"))
+	require.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	require.Contains(t, res.Trailer, "Grpc-Message")
+}
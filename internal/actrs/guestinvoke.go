@@ -0,0 +1,109 @@
+package actrs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+var errInvocationDepth = errors.New("maximum invocation depth exceeded")
+
+// guestInvoker queues the asynchronous invocations a guest requests of the
+// LIVE deployments of the other endpoints of its owner. The invocations carry
+// the depth of the request of the guest plus one, so chains of guests that
+// invoke each other end once they reach the maximum depth.
+type guestInvoker struct {
+	store    storage.Store
+	invoker  *async.Invoker
+	caller   string
+	depth    int
+	maxDepth int
+}
+
+// newGuestInvoker returns the invoker of the guest serving the request.
+func newGuestInvoker(store storage.Store, invoker *async.Invoker, msg *proto.HTTPRequest, maxDepth int) *guestInvoker {
+	return &guestInvoker{
+		store:    store,
+		invoker:  invoker,
+		caller:   msg.EndpointID,
+		depth:    invocationDepth(msg),
+		maxDepth: maxDepth,
+	}
+}
+
+func (g *guestInvoker) Invoke(endpointID string, b []byte) (string, error) {
+	if g.depth >= g.maxDepth {
+		return "", errInvocationDepth
+	}
+	id, err := uuid.Parse(endpointID)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint id: %s", endpointID)
+	}
+	var req types.AsyncRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return "", fmt.Errorf("invalid request: %w", err)
+	}
+	if len(req.Body) > async.MaxBodySize {
+		return "", fmt.Errorf("body exceeds the maximum of %d bytes", async.MaxBodySize)
+	}
+	callerID, err := uuid.Parse(g.caller)
+	if err != nil {
+		return "", err
+	}
+	caller, err := g.store.GetEndpoint(callerID)
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := g.store.GetEndpoint(id)
+	if err != nil {
+		return "", err
+	}
+	// The guests can only invoke the endpoints of their owner.
+	if endpoint.Owner() != caller.Owner() {
+		return "", fmt.Errorf("endpoint (%s) not found", id)
+	}
+	if !endpoint.HasActiveDeploy() {
+		return "", fmt.Errorf("endpoint (%s) has no LIVE deployment", id)
+	}
+	if len(req.Method) == 0 {
+		req.Method = http.MethodPost
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		req.Path = "/" + req.Path
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set(shared.InvocationDepthHeader, strconv.Itoa(g.depth+1))
+
+	invocation := types.NewInvocation(endpoint.ID)
+	if err := g.store.CreateInvocation(invocation); err != nil {
+		return "", err
+	}
+	go g.invoker.Run(endpoint, invocation, async.SourceGuest, req)
+	return invocation.ID.String(), nil
+}
+
+// invocationDepth returns the depth of the request, 0 when it was not sent by
+// a guest.
+func invocationDepth(msg *proto.HTTPRequest) int {
+	fields, ok := msg.Header[shared.InvocationDepthHeader]
+	if !ok || len(fields.Fields) == 0 {
+		return 0
+	}
+	depth, err := strconv.Atoi(fields.Fields[0])
+	if err != nil || depth < 0 {
+		return 0
+	}
+	return depth
+}
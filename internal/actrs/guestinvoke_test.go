@@ -0,0 +1,73 @@
+package actrs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestInvoker(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		received <- r
+	}))
+	defer ingress.Close()
+
+	store := storage.NewMemoryStore()
+	caller := types.NewEndpoint("caller", "go", nil)
+	require.Nil(t, store.CreateEndpoint(caller))
+	target := types.NewEndpoint("target", "go", nil)
+	target.ActiveDeploymentID = uuid.New()
+	require.Nil(t, store.CreateEndpoint(target))
+	other := types.NewEndpoint("other", "go", nil)
+	other.ActiveDeploymentID = uuid.New()
+	other.Ownership = &types.Ownership{Owner: "payments"}
+	require.Nil(t, store.CreateEndpoint(other))
+
+	invoker := async.New(store, ingress.URL, config.Async{MaxAttempts: 1})
+	msg := &proto.HTTPRequest{
+		EndpointID: caller.ID.String(),
+		Header: map[string]*proto.HeaderFields{
+			shared.InvocationDepthHeader: {Fields: []string{"1"}},
+		},
+	}
+	g := newGuestInvoker(store, invoker, msg, 3)
+	id, err := g.Invoke(target.ID.String(), []byte(`{"path":"jobs","body":"aW1hZ2U="}`))
+	require.Nil(t, err)
+	select {
+	case r := <-received:
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/live/"+target.ID.String()+"/jobs", r.URL.Path)
+		require.Equal(t, "2", r.Header.Get(shared.InvocationDepthHeader))
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the endpoint to be invoked")
+	}
+	require.Eventually(t, func() bool {
+		invocation, err := store.GetInvocation(uuid.MustParse(id))
+		return err == nil && invocation.Status == types.InvocationSucceeded
+	}, time.Second*5, time.Millisecond*10)
+
+	// The endpoints of other owners and those without a LIVE deployment can
+	// not be invoked.
+	_, err = g.Invoke(other.ID.String(), []byte(`{}`))
+	require.NotNil(t, err)
+	_, err = g.Invoke(caller.ID.String(), []byte(`{}`))
+	require.NotNil(t, err)
+
+	// The chain ends at the maximum depth.
+	msg.Header[shared.InvocationDepthHeader] = &proto.HeaderFields{Fields: []string{"3"}}
+	_, err = newGuestInvoker(store, invoker, msg, 3).Invoke(target.ID.String(), []byte(`{}`))
+	require.Equal(t, errInvocationDepth, err)
+}
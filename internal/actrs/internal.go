@@ -0,0 +1,38 @@
+package actrs
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/google/uuid"
+)
+
+// isInternal returns true if the request is an invocation of the platform
+// signed with the internal secret. The internal, the workflow and the
+// invocation headers are dropped from every request, the workflow and the
+// invocation headers are only set again for the steps and the attempts the
+// signature was made for.
+func (s *WasmServer) isInternal(r *http.Request) bool {
+	header := r.Header.Get(async.InternalHeader)
+	r.Header.Del(async.InternalHeader)
+	r.Header.Del(shared.WorkflowIDHeader)
+	r.Header.Del(shared.InvocationIDHeader)
+	if len(header) == 0 {
+		return false
+	}
+	workflowID, invocationID, err := async.VerifyInternal(s.secret, header, r.Method, r.URL.Path, time.Now())
+	if err != nil {
+		slog.Warn("rejected internal signature", "path", r.URL.Path, "err", err)
+		return false
+	}
+	if workflowID != uuid.Nil {
+		r.Header.Set(shared.WorkflowIDHeader, workflowID.String())
+	}
+	if invocationID != uuid.Nil {
+		r.Header.Set(shared.InvocationIDHeader, invocationID.String())
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package actrs
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/jwt"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+)
+
+// jwksTimeout bounds fetching the key set of an issuer, the request waits on
+// it.
+const jwksTimeout = 5 * time.Second
+
+// authorize validates the token of the request when the endpoint has a JWT
+// policy, and hands its claims to the guest. The request is answered and
+// false is returned when the token is missing or invalid.
+func (s *WasmServer) authorize(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint, req *proto.HTTPRequest) bool {
+	policy := endpoint.JWT
+	if !policy.Enabled() {
+		return true
+	}
+	token := bearerToken(r.Header, policy.Header)
+	if len(token) == 0 {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("token is missing"))
+		return false
+	}
+	expect := jwt.Expectation{
+		Issuer:    policy.Issuer,
+		Audiences: policy.Audiences,
+	}
+	claims, err := s.jwks.Verify(r.Context(), policy.JWKSURL, token, expect)
+	if errors.Is(err, jwt.ErrKeySetUnavailable) {
+		slog.Warn("failed to fetch jwt key set", "endpoint", endpoint.ID, "url", policy.JWKSURL, "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error()))
+		return false
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return false
+	}
+	b, err := json.Marshal(claims)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	req.Header[shared.JWTClaimsHeader] = &proto.HeaderFields{Fields: []string{string(b)}}
+	if sub := claims.Subject(); len(sub) > 0 {
+		req.Header[shared.JWTSubjectHeader] = &proto.HeaderFields{Fields: []string{sub}}
+	}
+	return true
+}
+
+// bearerToken returns the token of the request. The token is the bearer
+// token of the Authorization header, or the value of the header of the
+// policy, with or without the Bearer scheme.
+func bearerToken(h http.Header, header string) string {
+	if len(header) == 0 {
+		header = "Authorization"
+	}
+	value := strings.TrimSpace(h.Get(header))
+	if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if strings.EqualFold(header, "Authorization") {
+		return ""
+	}
+	return value
+}
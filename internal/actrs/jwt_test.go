@@ -0,0 +1,100 @@
+package actrs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/jwt"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	h, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "1"})
+	require.Nil(t, err)
+	c, err := json.Marshal(claims)
+	require.Nil(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.Nil(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthorize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"1","n":%q,"e":"AQAB"}]}`, base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+	}))
+	defer jwks.Close()
+
+	var (
+		s        = &WasmServer{jwks: jwt.NewCache(jwks.Client())}
+		endpoint = &types.Endpoint{
+			JWT: &types.JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: jwks.URL},
+		}
+		claims = map[string]any{
+			"iss": "https://issuer.example.com",
+			"sub": "user-1",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	)
+	newRequest := func(authorization string) (*httptest.ResponseRecorder, map[string]string, bool) {
+		r := httptest.NewRequest("GET", "/live/7d3f1d2c-4c5e-4a4e-9a3b-2f1e0d9c8b7a/me", nil)
+		if len(authorization) > 0 {
+			r.Header.Set("Authorization", authorization)
+		}
+		req, err := shared.MakeProtoRequest("1", r)
+		require.Nil(t, err)
+		w := httptest.NewRecorder()
+		ok := s.authorize(w, r, endpoint, req)
+		return w, invocationEnv(req), ok
+	}
+
+	_, env, ok := newRequest("Bearer " + signToken(t, key, claims))
+	require.True(t, ok)
+	require.Equal(t, "user-1", env[shared.JWTSubjectEnv])
+	var got map[string]any
+	require.Nil(t, json.Unmarshal([]byte(env[shared.JWTClaimsEnv]), &got))
+	require.Equal(t, "https://issuer.example.com", got["iss"])
+
+	w, _, ok := newRequest("")
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	claims["iss"] = "https://other.example.com"
+	w, _, ok = newRequest("Bearer " + signToken(t, key, claims))
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, jwt.ErrInvalidIssuer.Error(), w.Body.String())
+
+	// The requests are not checked without a policy.
+	endpoint.JWT = nil
+	_, _, ok = newRequest("")
+	require.True(t, ok)
+}
+
+func TestBearerToken(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "bearer abc")
+	h.Set("X-Token", "Bearer def")
+	h.Set("X-Raw-Token", "ghi")
+	require.Equal(t, "abc", bearerToken(h, ""))
+	require.Equal(t, "def", bearerToken(h, "X-Token"))
+	require.Equal(t, "ghi", bearerToken(h, "X-Raw-Token"))
+
+	h.Set("Authorization", "Basic dXNlcjpwYXNz")
+	require.Equal(t, "", bearerToken(h, ""))
+}
@@ -0,0 +1,209 @@
+package actrs
+
+import (
+	"math/rand"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/proto"
+)
+
+const (
+	KindLoadReporter = "load_reporter"
+	KindLoadMonitor  = "load_monitor"
+)
+
+// loadReportInterval is the interval the members hosting runtimes are asked
+// for their load.
+const loadReportInterval = 2 * time.Second
+
+// The load of a member is unknown when its last report is older than
+// loadReportTTL, like the load of a member that did not report yet.
+const loadReportTTL = 3 * loadReportInterval
+
+type pollLoads struct{}
+
+// LoadReporter reports the load of this member to the load monitors of the
+// cluster.
+type LoadReporter struct {
+	member  string
+	tracker *admin.Tracker
+	cpu     cpuSampler
+}
+
+// NewLoadReporter returns a new load reporter producer of the member with
+// the given id, the in-flight invocations are those of the tracker.
+func NewLoadReporter(member string, tracker *admin.Tracker) actor.Producer {
+	return func() actor.Receiver {
+		return &LoadReporter{
+			member:  member,
+			tracker: tracker,
+		}
+	}
+}
+
+func (lr *LoadReporter) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		lr.cpu.usage()
+	case *proto.LoadRequest:
+		c.Respond(&proto.LoadReport{
+			MemberID: lr.member,
+			Inflight: lr.tracker.Inflight(),
+			Cpu:      lr.cpu.usage(),
+		})
+	}
+}
+
+// cpuSampler samples the CPU usage of the process.
+type cpuSampler struct {
+	samples []metrics.Sample
+	// CPU seconds of the previous sample.
+	total, idle float64
+}
+
+// usage returns the share of the available CPU time the process used since
+// the previous call.
+func (s *cpuSampler) usage() float64 {
+	if s.samples == nil {
+		s.samples = []metrics.Sample{
+			{Name: "/cpu/classes/total:cpu-seconds"},
+			{Name: "/cpu/classes/idle:cpu-seconds"},
+		}
+	}
+	metrics.Read(s.samples)
+	if s.samples[0].Value.Kind() != metrics.KindFloat64 || s.samples[1].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	total, idle := s.samples[0].Value.Float64(), s.samples[1].Value.Float64()
+	elapsed, idled := total-s.total, idle-s.idle
+	s.total, s.idle = total, idle
+	if elapsed <= 0 {
+		return 0
+	}
+	return min(max(1-idled/elapsed, 0), 1)
+}
+
+// LoadMonitor asks the members hosting runtimes for their load once per
+// interval and keeps their reports.
+type LoadMonitor struct {
+	cluster *cluster.Cluster
+	loads   *MemberLoads
+	poller  actor.SendRepeater
+}
+
+// NewLoadMonitor returns a new load monitor producer, the reports of the
+// members are kept in loads.
+func NewLoadMonitor(c *cluster.Cluster, loads *MemberLoads) actor.Producer {
+	return func() actor.Receiver {
+		return &LoadMonitor{
+			cluster: c,
+			loads:   loads,
+		}
+	}
+}
+
+func (lm *LoadMonitor) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		lm.poller = c.SendRepeat(c.PID(), pollLoads{}, loadReportInterval)
+	case actor.Stopped:
+		lm.poller.Stop()
+	case pollLoads:
+		members := lm.cluster.Members()
+		lm.loads.prune(members)
+		for _, member := range members {
+			if member.HasKind(KindRuntime) {
+				c.Send(actor.NewPID(member.Host, KindLoadReporter+"/1"), &proto.LoadRequest{})
+			}
+		}
+	case *proto.LoadReport:
+		lm.loads.Report(msg)
+	}
+}
+
+// memberLoad is the last reported load of a member.
+type memberLoad struct {
+	inflight int64
+	cpu      float64
+	// Runtimes activated on the member since its last report, so a burst
+	// of activations between two reports is spread over the members.
+	activated  int
+	reportedAT time.Time
+}
+
+// score returns the load of the member, the lower the better. A member
+// running at full CPU counts twice the invocations of an idle one.
+func (l memberLoad) score() float64 {
+	inflight, cpu := l.inflight, l.cpu
+	if time.Since(l.reportedAT) > loadReportTTL {
+		inflight, cpu = 0, 0
+	}
+	return float64(inflight+int64(l.activated)+1) * (1 + cpu)
+}
+
+// MemberLoads are the loads the members of the cluster reported, safe for
+// concurrent use.
+type MemberLoads struct {
+	mu    sync.Mutex
+	loads map[string]memberLoad
+}
+
+// NewMemberLoads returns new member loads without any reports.
+func NewMemberLoads() *MemberLoads {
+	return &MemberLoads{
+		loads: make(map[string]memberLoad),
+	}
+}
+
+// Report sets the load of the member of the report.
+func (l *MemberLoads) Report(report *proto.LoadReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads[report.MemberID] = memberLoad{
+		inflight:   report.Inflight,
+		cpu:        report.Cpu,
+		reportedAT: time.Now(),
+	}
+}
+
+// leastLoaded returns the least loaded of the given members and counts the
+// activation on it, the ties are broken at random.
+func (l *MemberLoads) leastLoaded(members []*cluster.Member) *cluster.Member {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var (
+		least *cluster.Member
+		score float64
+	)
+	offset := rand.Intn(len(members))
+	for i := range members {
+		member := members[(offset+i)%len(members)]
+		if s := l.loads[member.ID].score(); least == nil || s < score {
+			least, score = member, s
+		}
+	}
+	load := l.loads[least.ID]
+	load.activated++
+	l.loads[least.ID] = load
+	return least
+}
+
+// prune forgets the loads of the members that left the cluster.
+func (l *MemberLoads) prune(members []*cluster.Member) {
+	ids := make(map[string]bool, len(members))
+	for _, member := range members {
+		ids[member.ID] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id := range l.loads {
+		if !ids[id] {
+			delete(l.loads, id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package actrs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadActivationStrategy(t *testing.T) {
+	members := []*cluster.Member{
+		{ID: "a", Region: "eu-west"},
+		{ID: "b", Region: "eu-west"},
+		{ID: "c", Region: "us-east"},
+	}
+	loads := NewMemberLoads()
+	loads.Report(&proto.LoadReport{MemberID: "a", Inflight: 10})
+	loads.Report(&proto.LoadReport{MemberID: "b", Inflight: 2, Cpu: 0.5})
+	strategy := NewLoadActivationStrategy(loads)
+
+	details := cluster.ActivationDetails{Members: members, Region: "eu-west", Kind: KindRuntime}
+	require.Equal(t, "b", strategy.ActivateOnMember(details).ID)
+	// The unknown member of another region is only used without members in
+	// the region of the request.
+	details.Region = "ap-south"
+	require.Equal(t, "c", strategy.ActivateOnMember(details).ID)
+
+	// The activations count until the next report.
+	details.Region = "eu-west"
+	loads.Report(&proto.LoadReport{MemberID: "a", Inflight: 3})
+	loads.Report(&proto.LoadReport{MemberID: "b", Inflight: 3})
+	first := strategy.ActivateOnMember(details).ID
+	second := strategy.ActivateOnMember(details).ID
+	require.NotEqual(t, first, second)
+
+	// Stale reports count as idle.
+	loads.Report(&proto.LoadReport{MemberID: "a", Inflight: 100})
+	loads.mu.Lock()
+	load := loads.loads["a"]
+	load.reportedAT = time.Now().Add(-loadReportTTL - time.Second)
+	loads.loads["a"] = load
+	loads.mu.Unlock()
+	require.Equal(t, "a", strategy.ActivateOnMember(details).ID)
+
+	loads.prune(members[1:])
+	require.NotContains(t, loads.loads, "a")
+}
+
+func TestLoadReporter(t *testing.T) {
+	e, err := actor.NewEngine(nil)
+	require.NoError(t, err)
+	tracker := admin.NewTracker()
+	tracker.Begin()
+	pid := e.Spawn(NewLoadReporter("edge-1-runtime", tracker), KindLoadReporter)
+
+	resp, err := e.Request(pid, &proto.LoadRequest{}, time.Second).Result()
+	require.NoError(t, err)
+	report := resp.(*proto.LoadReport)
+	require.Equal(t, "edge-1-runtime", report.MemberID)
+	require.Equal(t, int64(1), report.Inflight)
+	require.True(t, report.Cpu >= 0 && report.Cpu <= 1)
+}
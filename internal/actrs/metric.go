@@ -1,8 +1,17 @@
 package actrs
 
 import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
 )
 
 // The metric actor is responsible for handling metrics that are being
@@ -10,18 +19,230 @@ import (
 
 const KindMetric = "runtime_metric"
 
-type Metric struct{}
+// eventInterval is the minimum time between two events of the same type of
+// an endpoint emitted by the runtimes of a member, so a failing endpoint does
+// not flood its webhooks.
+const eventInterval = time.Minute
+
+const (
+	// Interval the buffered request metrics and usage are written to the
+	// store at.
+	metricFlushInterval = time.Second
+	// Maximum amount of request metrics written with one statement, a full
+	// batch is written right away.
+	metricBatchSize = 500
+	// Maximum amount of request metrics buffered while the store is slow,
+	// the metrics are dropped when the buffer is full. The usage is never
+	// dropped.
+	metricBufferSize = 10000
+)
+
+type flushMetrics struct{}
+
+type usageKey struct {
+	endpointID uuid.UUID
+	period     time.Time
+}
+
+type Metric struct {
+	store  storage.MetricStore
+	client *http.Client
+	// Consecutive failed health checks of the deployments checked on this
+	// member and whether they are unhealthy.
+	failures  map[uuid.UUID]int
+	unhealthy map[uuid.UUID]bool
+	notifier  *notify.Notifier
+	// When the last event of every type was emitted per endpoint.
+	emitted map[eventKey]time.Time
+	flusher actor.SendRepeater
+	// Request metrics and usage buffered until the next flush, the usage
+	// is summed per endpoint and period.
+	requests []types.RequestMetric
+	usage    map[usageKey]types.UsageRecord
+	dropped  int
+	// Holds a token while a flush writes to the store, the metrics are
+	// buffered in the meantime.
+	flushing chan struct{}
+}
+
+type eventKey struct {
+	endpointID uuid.UUID
+	eventType  string
+}
+
+// healthAlert is posted to the alert webhook when a deployment becomes
+// unhealthy or recovers.
+type healthAlert struct {
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
 
-func NewMetric() actor.Receiver {
-	return &Metric{}
+// NewMetric returns the producer of the metric actor. The platform events of
+// the invocations are emitted with the notifier, a nil notifier drops them.
+func NewMetric(store storage.MetricStore, notifier *notify.Notifier) actor.Producer {
+	return func() actor.Receiver {
+		return &Metric{
+			store:     store,
+			client:    &http.Client{Timeout: probeTimeout},
+			failures:  make(map[uuid.UUID]int),
+			unhealthy: make(map[uuid.UUID]bool),
+			notifier:  notifier,
+			emitted:   make(map[eventKey]time.Time),
+			usage:     make(map[usageKey]types.UsageRecord),
+			flushing:  make(chan struct{}, 1),
+		}
+	}
 }
 
 // TODO: Store metrics where they belong
 func (m *Metric) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
+		m.flusher = c.SendRepeat(c.PID(), flushMetrics{}, metricFlushInterval)
 	case actor.Stopped:
+		m.flusher.Stop()
+		m.flush(true)
+	case flushMetrics:
+		m.flush(false)
 	case types.RuntimeMetric:
 		_ = msg
+	case types.RequestMetric:
+		m.buffer(msg)
+		if len(m.requests) >= metricBatchSize {
+			m.flush(false)
+		}
+		if msg.StatusCode >= http.StatusInternalServerError {
+			message := fmt.Sprintf("%s responded with status code %d", msg.RequestURL, msg.StatusCode)
+			m.emit(types.NewEvent(types.EventInvocationFailed, msg.EndpointID, msg.DeploymentID, "", message))
+		}
+	case types.InvocationError:
+		if err := m.store.CreateInvocationError(&msg); err != nil {
+			slog.Warn("failed to store invocation error", "err", err)
+		}
+	case types.CapturedRequest:
+		if err := m.store.CreateCapturedRequest(&msg); err != nil {
+			slog.Warn("failed to store captured request", "err", err)
+		}
+	case types.Event:
+		m.emit(&msg)
+	case types.CacheMetric:
+		if err := m.store.CreateCacheMetric(&msg); err != nil {
+			slog.Warn("failed to store cache metric", "err", err)
+		}
+	case types.ConcurrencyMetric:
+		if err := m.store.CreateConcurrencyMetric(&msg); err != nil {
+			slog.Warn("failed to store concurrency metric", "err", err)
+		}
+	case types.HealthCheck:
+		if err := m.store.CreateHealthCheck(&msg); err != nil {
+			slog.Warn("failed to store health check", "err", err)
+		}
+		m.handleHealthCheck(msg)
+	}
+}
+
+// buffer buffers the request metric and adds its usage, the metric is
+// dropped when the buffer is full.
+func (m *Metric) buffer(metric types.RequestMetric) {
+	usage := types.NewUsageRecord(metric)
+	key := usageKey{endpointID: usage.EndpointID, period: usage.Period}
+	if record, ok := m.usage[key]; ok {
+		usage.Invocations += record.Invocations
+		usage.GBSeconds += record.GBSeconds
 	}
+	m.usage[key] = usage
+	if len(m.requests) >= metricBufferSize {
+		m.dropped++
+		return
+	}
+	m.requests = append(m.requests, metric)
+}
+
+// flush writes the buffered request metrics in batches and the usage to the
+// store in the background. A flush is skipped while the previous one is
+// still writing, unless the actor is stopping and waits for it.
+func (m *Metric) flush(stopping bool) {
+	if stopping {
+		m.flushing <- struct{}{}
+	} else {
+		select {
+		case m.flushing <- struct{}{}:
+		default:
+			return
+		}
+	}
+	if m.dropped > 0 {
+		slog.Warn("dropped request metrics, the metric buffer is full", "count", m.dropped)
+		m.dropped = 0
+	}
+	requests, usage := m.requests, m.usage
+	m.requests, m.usage = nil, make(map[usageKey]types.UsageRecord)
+	write := func() {
+		defer func() { <-m.flushing }()
+		for i := 0; i < len(requests); i += metricBatchSize {
+			batch := requests[i:min(i+metricBatchSize, len(requests))]
+			if err := m.store.CreateRequestMetrics(batch); err != nil {
+				slog.Warn("failed to store request metrics", "count", len(batch), "err", err)
+			}
+		}
+		for _, record := range usage {
+			record := record
+			if err := m.store.AddUsage(&record); err != nil {
+				slog.Warn("failed to add usage", "endpoint", record.EndpointID, "err", err)
+			}
+		}
+	}
+	if stopping {
+		write()
+		return
+	}
+	go write()
+}
+
+// emit emits the event unless an event of the same type of the endpoint was
+// emitted less than the event interval ago.
+func (m *Metric) emit(event *types.Event) {
+	key := eventKey{endpointID: event.EndpointID, eventType: event.Type}
+	if last, ok := m.emitted[key]; ok && event.CreatedAT.Sub(last) < eventInterval {
+		return
+	}
+	m.emitted[key] = event.CreatedAT
+	m.notifier.Emit(event)
+}
+
+// handleHealthCheck marks the deployment unhealthy once its consecutive
+// failed checks reach the threshold and alerts when its health changes.
+func (m *Metric) handleHealthCheck(check types.HealthCheck) {
+	id := check.DeploymentID
+	if check.Healthy {
+		delete(m.failures, id)
+		if m.unhealthy[id] {
+			delete(m.unhealthy, id)
+			m.alertHealth(check, types.HealthHealthy)
+		}
+		return
+	}
+	m.failures[id]++
+	if !m.unhealthy[id] && m.failures[id] >= config.Get().Health.FailureThreshold {
+		m.unhealthy[id] = true
+		m.alertHealth(check, types.HealthUnhealthy)
+	}
+}
+
+func (m *Metric) alertHealth(check types.HealthCheck, status string) {
+	slog.Error("deployment health changed",
+		"endpoint", check.EndpointID,
+		"deployment", check.DeploymentID,
+		"status", status,
+		"err", check.Error)
+	postAlert(m.client, healthAlert{
+		EndpointID:   check.EndpointID,
+		DeploymentID: check.DeploymentID,
+		Status:       status,
+		Error:        check.Error,
+		Time:         check.CreatedAT,
+	})
 }
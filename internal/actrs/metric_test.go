@@ -0,0 +1,52 @@
+package actrs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricBuffersRequestMetrics(t *testing.T) {
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	store := storage.NewMemoryStore()
+	pid := e.Spawn(NewMetric(store, nil), KindMetric)
+
+	endpointID := uuid.New()
+	now := time.Now()
+	for i := 0; i < metricBatchSize+10; i++ {
+		e.Send(pid, types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpointID,
+			StatusCode: http.StatusOK,
+			CreatedAT:  now,
+		})
+	}
+	// Stopping the actor writes the metrics that are still buffered.
+	e.Poison(pid).Wait()
+
+	metrics, err := store.GetRequestMetrics(endpointID)
+	require.Nil(t, err)
+	require.Len(t, metrics, metricBatchSize+10)
+	usage, err := store.GetUsage(types.UsagePeriod(now))
+	require.Nil(t, err)
+	require.Len(t, usage, 1)
+	require.Equal(t, int64(metricBatchSize+10), usage[0].Invocations)
+}
+
+func TestMetricDropsWhenBufferIsFull(t *testing.T) {
+	m := NewMetric(storage.NewMemoryStore(), nil)().(*Metric)
+	for i := 0; i < metricBufferSize+5; i++ {
+		m.buffer(types.RequestMetric{ID: uuid.New(), EndpointID: uuid.New()})
+	}
+	require.Len(t, m.requests, metricBufferSize)
+	require.Equal(t, 5, m.dropped)
+	// The usage of the dropped metrics is still counted.
+	require.Len(t, m.usage, metricBufferSize+5)
+}
@@ -0,0 +1,58 @@
+package actrs
+
+import "fmt"
+
+// runtimePool tracks the in-flight requests of the runtimes serving an
+// endpoint with a concurrency limit. Each slot of the pool maps to a runtime
+// that handles a single request at a time.
+type runtimePool struct {
+	inflight []int
+	total    int
+}
+
+func newRuntimePool(size int) *runtimePool {
+	return &runtimePool{
+		inflight: make([]int, size),
+	}
+}
+
+// resize grows or shrinks the pool, keeping track of the in-flight requests
+// of the slots that remain.
+func (p *runtimePool) resize(size int) {
+	if size == len(p.inflight) {
+		return
+	}
+	inflight := make([]int, size)
+	copy(inflight, p.inflight)
+	p.inflight = inflight
+}
+
+// acquire returns the least loaded slot of the pool. False is returned when
+// the endpoint reached its maximum concurrency.
+func (p *runtimePool) acquire() (int, bool) {
+	if p.total >= len(p.inflight) {
+		return 0, false
+	}
+	slot := 0
+	for i, n := range p.inflight {
+		if n < p.inflight[slot] {
+			slot = i
+		}
+	}
+	p.inflight[slot]++
+	p.total++
+	return slot, true
+}
+
+func (p *runtimePool) release(slot int) {
+	if slot < len(p.inflight) && p.inflight[slot] > 0 {
+		p.inflight[slot]--
+	}
+	if p.total > 0 {
+		p.total--
+	}
+}
+
+func poolRuntimeKey(deploymentID string, slot int) string {
+	return fmt.Sprintf("%s/pool/%d", deploymentID, slot)
+}
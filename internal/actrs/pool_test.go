@@ -0,0 +1,74 @@
+package actrs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimePoolReleasesLostRequests(t *testing.T) {
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	c, err := cluster.New(cluster.NewConfig().WithEngine(e))
+	require.Nil(t, err)
+	// The runtime manager hands out a runtime that was stopped and never
+	// responds.
+	var (
+		gone     = actor.NewPID(e.Address(), KindRuntime+"/gone")
+		requests = make(chan string, 8)
+	)
+	e.SpawnFunc(func(c *actor.Context) {
+		if msg, ok := c.Message().(requestRuntime); ok {
+			requests <- msg.key
+			c.Respond(gone)
+		}
+	}, KindRuntimeManager, actor.WithID("1"))
+	e.SpawnFunc(func(c *actor.Context) {}, KindMetric, actor.WithID("1"))
+	store := storage.NewMemoryStore()
+	server := e.Spawn(NewWasmServer("127.0.0.1:0", c, store, store, storage.NewDefaultModCache(), admin.NewTracker()), KindWasmServer)
+
+	endpointID := uuid.NewString()
+	newRequest := func() requestWithResponse {
+		return newRequestWithResponse(&proto.HTTPRequest{
+			ID:           uuid.NewString(),
+			EndpointID:   endpointID,
+			DeploymentID: endpointID,
+			RuntimeKey:   endpointID,
+		}, "", 1)
+	}
+	first := newRequest()
+	e.Send(server, first)
+	select {
+	case <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("expected the first request to be dispatched")
+	}
+	// The endpoint is at its maximum concurrency, without a queue.
+	second := newRequest()
+	e.Send(server, second)
+	require.Equal(t, int32(http.StatusTooManyRequests), (<-second.response).StatusCode)
+
+	// The client of the first request went away and the runtime never
+	// responds to the cancellation, the slot is released.
+	e.Send(server, cancelRequest{requestID: first.request.ID, endpointID: endpointID})
+	select {
+	case resp := <-first.response:
+		require.Equal(t, int32(http.StatusGatewayTimeout), resp.StatusCode)
+	case <-time.After(canceledGrace + time.Second):
+		t.Fatal("expected the first request to be released")
+	}
+	e.Send(server, newRequest())
+	select {
+	case <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("expected the slot to be available again")
+	}
+}
@@ -0,0 +1,131 @@
+package actrs
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+)
+
+const KindPrewarmer = "prewarmer"
+
+const (
+	// prewarmInterval is the interval the prewarms are renewed at.
+	prewarmInterval = 30 * time.Second
+	// prewarmRequestTimeout bounds waiting on the runtime manager.
+	prewarmRequestTimeout = 100 * time.Millisecond
+)
+
+type renewPrewarms struct{}
+
+// Prewarmer is an actor that keeps the runtimes the endpoints ask for warm
+// on the ingress it runs on, following their prewarm schedules. The runtimes
+// are requested from the runtime manager of the ingress under the keys the
+// requests are dispatched with, so the requests land on the warm runtimes.
+type Prewarmer struct {
+	store   storage.Store
+	cluster *cluster.Cluster
+	repeat  actor.SendRepeater
+}
+
+func NewPrewarmer(store storage.Store, cluster *cluster.Cluster) actor.Producer {
+	return func() actor.Receiver {
+		return &Prewarmer{
+			store:   store,
+			cluster: cluster,
+		}
+	}
+}
+
+func (p *Prewarmer) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		p.repeat = c.SendRepeat(c.PID(), renewPrewarms{}, prewarmInterval)
+		c.Send(c.PID(), renewPrewarms{})
+	case actor.Stopped:
+		p.repeat.Stop()
+	case renewPrewarms:
+		p.renew(c, time.Now())
+	}
+}
+
+func (p *Prewarmer) renew(c *actor.Context, now time.Time) {
+	endpoints, err := p.store.GetEndpoints()
+	if err != nil {
+		slog.Warn("failed to load the endpoints to prewarm", "err", err)
+		return
+	}
+	managerPID := c.Engine().Registry.GetPID(KindRuntimeManager, "1")
+	// A prewarm outlives two renewals, so a late renewal does not let the
+	// runtimes go cold.
+	until := now.Add(2*prewarmInterval + runtimeKeepAlive).UnixMilli()
+	for _, endpoint := range endpoints {
+		if !endpoint.HasActiveDeploy() || endpoint.Maintenance.Active() {
+			continue
+		}
+		n := endpoint.Prewarm.Instances(now)
+		if n == 0 {
+			continue
+		}
+		warm, err := inheritEnvironment(p.store, &endpoint)
+		if err != nil {
+			slog.Warn("failed to load the environment of the endpoint to prewarm", "err", err, "endpoint", endpoint.ID)
+			continue
+		}
+		region := endpoint.PreferredRegion(p.cluster.Region())
+		for _, key := range prewarmKeys(&endpoint, n) {
+			res, err := c.Request(managerPID, requestRuntime{key: key, region: region}, prewarmRequestTimeout).Result()
+			if err != nil {
+				slog.Warn("runtime manager response failed", "err", err)
+				return
+			}
+			pid, ok := res.(*actor.PID)
+			if !ok || pid == nil {
+				continue
+			}
+			c.Send(pid, &proto.WarmRuntime{
+				DeploymentID: endpoint.ActiveDeploymentID.String(),
+				EndpointID:   endpoint.ID.String(),
+				Runtime:      endpoint.Runtime,
+				RuntimeKey:   key,
+				ManagerPID:   managerPID,
+				Env:          warm.Environment,
+				Until:        until,
+			})
+		}
+	}
+}
+
+// prewarmKeys returns the keys of the runtimes to keep warm. Endpoints with
+// session affinity or a concurrency limit spread their requests over a
+// runtime per slot, the other endpoints are served by a single runtime per
+// deployment.
+func prewarmKeys(endpoint *types.Endpoint, n int) []string {
+	var (
+		deploymentID = endpoint.ActiveDeploymentID.String()
+		key          func(string, int) string
+	)
+	switch {
+	case endpoint.SessionAffinity != nil:
+		slots := endpoint.SessionAffinity.Slots
+		if slots == 0 {
+			slots = types.DefaultAffinitySlots
+		}
+		n = min(n, slots)
+		key = affinityRuntimeKey
+	case endpoint.MaxConcurrency > 0:
+		n = min(n, endpoint.MaxConcurrency)
+		key = poolRuntimeKey
+	default:
+		return []string{deploymentID}
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = key(deploymentID, i)
+	}
+	return keys
+}
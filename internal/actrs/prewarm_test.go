@@ -0,0 +1,23 @@
+package actrs
+
+import (
+	"testing"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrewarmKeys(t *testing.T) {
+	endpoint := &types.Endpoint{ActiveDeploymentID: uuid.MustParse("7d3f1d2c-4c5e-4a4e-9a3b-2f1e0d9c8b7a")}
+	deploymentID := endpoint.ActiveDeploymentID.String()
+
+	// A single runtime serves the endpoints without slots.
+	require.Equal(t, []string{deploymentID}, prewarmKeys(endpoint, 4))
+
+	endpoint.MaxConcurrency = 2
+	require.Equal(t, []string{poolRuntimeKey(deploymentID, 0), poolRuntimeKey(deploymentID, 1)}, prewarmKeys(endpoint, 4))
+
+	endpoint.SessionAffinity = &types.SessionAffinity{Header: "X-Session", Slots: 8}
+	require.Equal(t, []string{deploymentID + "/0", deploymentID + "/1", deploymentID + "/2"}, prewarmKeys(endpoint, 3))
+}
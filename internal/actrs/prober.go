@@ -0,0 +1,237 @@
+package actrs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+const KindProber = "prober"
+
+const (
+	probeTickInterval    = time.Second
+	probeRefreshInterval = time.Second * 30
+	probeTimeout         = time.Second * 10
+	maxProbeBodySize     = 1 << 20
+)
+
+type (
+	runProbes     struct{}
+	refreshProbes struct{}
+)
+
+type probeKey struct {
+	endpointID uuid.UUID
+	path       string
+}
+
+type probeState struct {
+	probe    types.Probe
+	next     time.Time
+	running  bool
+	failures int
+	down     bool
+}
+
+// probeAlert is posted to the alert webhook when a probe goes down or
+// recovers.
+type probeAlert struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Path       string    `json:"path"`
+	Region     string    `json:"region"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Prober is an actor that executes the synthetic probes of all the endpoints
+// against the LIVE deployments through the ingress of its own region.
+type Prober struct {
+	store       storage.Store
+	metricStore storage.MetricStore
+	region      string
+	client      *http.Client
+	probes      map[probeKey]*probeState
+	ticker      actor.SendRepeater
+	refresher   actor.SendRepeater
+}
+
+func NewProber(store storage.Store, metricStore storage.MetricStore, region string) actor.Producer {
+	return func() actor.Receiver {
+		return &Prober{
+			store:       store,
+			metricStore: metricStore,
+			region:      region,
+			client:      &http.Client{Timeout: probeTimeout},
+			probes:      make(map[probeKey]*probeState),
+		}
+	}
+}
+
+func (p *Prober) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		p.refresh()
+		p.ticker = c.SendRepeat(c.PID(), runProbes{}, probeTickInterval)
+		p.refresher = c.SendRepeat(c.PID(), refreshProbes{}, probeRefreshInterval)
+	case actor.Stopped:
+		p.ticker.Stop()
+		p.refresher.Stop()
+	case refreshProbes:
+		p.refresh()
+	case runProbes:
+		now := time.Now()
+		for key, state := range p.probes {
+			if state.running || now.Before(state.next) {
+				continue
+			}
+			state.running = true
+			state.next = now.Add(time.Duration(state.probe.Interval) * time.Second)
+			go p.check(c.Engine(), c.PID(), key, state.probe)
+		}
+	case types.ProbeResult:
+		p.handleResult(msg)
+	}
+}
+
+// refresh loads the probes of all the endpoints with a LIVE deployment,
+// keeping the state of the probes that did not change.
+func (p *Prober) refresh() {
+	endpoints, err := p.store.GetEndpoints()
+	if err != nil {
+		slog.Warn("failed to load endpoints for probing", "err", err)
+		return
+	}
+	probes := make(map[probeKey]*probeState)
+	for _, endpoint := range endpoints {
+		if !endpoint.HasActiveDeploy() && len(endpoint.Routes) == 0 {
+			continue
+		}
+		for _, probe := range endpoint.Probes {
+			key := probeKey{endpointID: endpoint.ID, path: probe.Path}
+			if state, ok := p.probes[key]; ok && state.probe == probe {
+				probes[key] = state
+				continue
+			}
+			probes[key] = &probeState{probe: probe}
+		}
+	}
+	p.probes = probes
+}
+
+func (p *Prober) check(engine *actor.Engine, pid *actor.PID, key probeKey, probe types.Probe) {
+	var (
+		start  = time.Now()
+		url    = fmt.Sprintf("%s/live/%s%s", config.IngressUrl(), key.endpointID, probe.Path)
+		result = types.ProbeResult{
+			ID:         uuid.New(),
+			EndpointID: key.endpointID,
+			Path:       probe.Path,
+			Region:     p.region,
+			CreatedAT:  start,
+		}
+	)
+	defer func() {
+		result.Duration = time.Since(start)
+		engine.Send(pid, result)
+	}()
+
+	resp, err := p.client.Get(url)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode != probe.ExpectedStatus {
+		result.Error = fmt.Sprintf("expected status %d got %d", probe.ExpectedStatus, resp.StatusCode)
+		return
+	}
+	if len(probe.ExpectedBody) > 0 {
+		b, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+		if !strings.Contains(string(b), probe.ExpectedBody) {
+			result.Error = fmt.Sprintf("response body does not contain %q", probe.ExpectedBody)
+			return
+		}
+	}
+	result.Success = true
+}
+
+func (p *Prober) handleResult(result types.ProbeResult) {
+	if err := p.metricStore.CreateProbeResult(&result); err != nil {
+		slog.Warn("failed to store probe result", "err", err)
+	}
+	state, ok := p.probes[probeKey{endpointID: result.EndpointID, path: result.Path}]
+	if !ok {
+		return
+	}
+	state.running = false
+	if result.Success {
+		state.failures = 0
+		if state.down {
+			state.down = false
+			p.alert(result, "up")
+		}
+		return
+	}
+	state.failures++
+	if !state.down && state.failures >= config.Get().Probes.FailureThreshold {
+		state.down = true
+		p.alert(result, "down")
+	}
+}
+
+func (p *Prober) alert(result types.ProbeResult, status string) {
+	slog.Error("probe status changed",
+		"endpoint", result.EndpointID,
+		"path", result.Path,
+		"region", result.Region,
+		"status", status,
+		"err", result.Error)
+
+	postAlert(p.client, probeAlert{
+		EndpointID: result.EndpointID,
+		Path:       result.Path,
+		Region:     result.Region,
+		Status:     status,
+		Error:      result.Error,
+		Time:       result.CreatedAT,
+	})
+}
+
+// postAlert posts the given alert to the alert webhook in the background, if
+// one is configured.
+func postAlert(client *http.Client, alert any) {
+	webhook := config.Get().Probes.AlertWebhook
+	if len(webhook) == 0 {
+		return
+	}
+	b, err := json.Marshal(alert)
+	if err != nil {
+		slog.Warn("failed to encode alert", "err", err)
+		return
+	}
+	go func() {
+		resp, err := client.Post(webhook, "application/json", bytes.NewReader(b))
+		if err != nil {
+			slog.Warn("failed to send alert", "err", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,78 @@
+package actrs
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+// Minimum interval between two writes of the progress of an invocation to
+// the store, the reports in between are coalesced.
+const progressInterval = time.Second
+
+// invocationProgress receives the progress reported by the guest. It is
+// logged, and stored on the invocation when the request is an attempt of a
+// polled asynchronous invocation, so GET /invocation/{id} returns it.
+type invocationProgress struct {
+	store     storage.Store
+	requestID string
+	// Invocation the request is an attempt of, nil for the other requests.
+	invocationID uuid.UUID
+	endpointID   string
+
+	percent int
+	message string
+	pending bool
+	written time.Time
+}
+
+// newInvocationProgress returns the progress of the request. The ingress
+// only sets the invocation header for the attempts signed by the invoker.
+func newInvocationProgress(store storage.Store, msg *proto.HTTPRequest) *invocationProgress {
+	p := &invocationProgress{
+		store:      store,
+		requestID:  msg.ID,
+		endpointID: msg.EndpointID,
+	}
+	if fields, ok := msg.Header[shared.InvocationIDHeader]; ok && len(fields.Fields) > 0 && !msg.Preview {
+		if id, err := uuid.Parse(fields.Fields[0]); err == nil {
+			p.invocationID = id
+		}
+	}
+	return p
+}
+
+func (p *invocationProgress) Report(percent int, message string) {
+	slog.Info("invocation progress", "request_id", p.requestID, "percent", percent, "message", message)
+	if p.invocationID == uuid.Nil {
+		return
+	}
+	p.percent, p.message, p.pending = percent, message, true
+	if time.Since(p.written) >= progressInterval {
+		p.flush()
+	}
+}
+
+// flush stores the last reported progress that was not stored yet. The
+// invocation has to belong to the endpoint of the request and still be
+// running.
+func (p *invocationProgress) flush() {
+	if !p.pending {
+		return
+	}
+	p.pending = false
+	p.written = time.Now()
+	invocation, err := p.store.GetInvocation(p.invocationID)
+	if err != nil || invocation.EndpointID.String() != p.endpointID || invocation.Done() {
+		return
+	}
+	invocation.Progress = p.percent
+	invocation.ProgressMessage = p.message
+	if err := p.store.UpdateInvocation(invocation); err != nil {
+		slog.Warn("failed to store invocation progress", "err", err, "id", p.invocationID)
+	}
+}
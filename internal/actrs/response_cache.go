@@ -0,0 +1,153 @@
+package actrs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/httpcache"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+// cacheMetricInterval is the interval the cache hits and misses of the
+// endpoints are reported to the metric actor.
+const cacheMetricInterval = time.Second * 10
+
+type flushCacheMetrics struct{}
+
+// hopHeaders are the headers of the guest responses that only apply to the
+// connection between the ingress and the client and are never forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// cacheCounters counts the cache hits and misses per endpoint. Requests are
+// served by the goroutines of the http server, hence the mutex.
+type cacheCounters struct {
+	mu     sync.Mutex
+	counts map[uuid.UUID]*types.CacheMetric
+}
+
+func newCacheCounters() *cacheCounters {
+	return &cacheCounters{
+		counts: make(map[uuid.UUID]*types.CacheMetric),
+	}
+}
+
+func (c *cacheCounters) record(endpointID uuid.UUID, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metric, ok := c.counts[endpointID]
+	if !ok {
+		metric = &types.CacheMetric{EndpointID: endpointID}
+		c.counts[endpointID] = metric
+	}
+	if hit {
+		metric.Hits++
+	} else {
+		metric.Misses++
+	}
+}
+
+// flush returns the metrics counted since the previous flush.
+func (c *cacheCounters) flush() []types.CacheMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metrics := make([]types.CacheMetric, 0, len(c.counts))
+	for _, metric := range c.counts {
+		metric.ID = uuid.New()
+		metric.CreatedAT = time.Now()
+		metrics = append(metrics, *metric)
+	}
+	clear(c.counts)
+	return metrics
+}
+
+// cacheRequest is a request to an endpoint with response caching enabled
+// that missed the cache.
+type cacheRequest struct {
+	key        string
+	store      bool
+	defaultTTL time.Duration
+}
+
+// serveCached answers the request with the cached response of the endpoint,
+// if any. Otherwise the returned request is used to cache the response of
+// the deployment, it is nil when the request bypasses the cache.
+func (s *WasmServer) serveCached(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint) (*cacheRequest, bool) {
+	lookup, store := httpcache.RequestPolicy(r)
+	if !lookup && !store {
+		return nil, false
+	}
+	// Purges bump the generation and publishes change the deployment, both
+	// leave the previous entries unreachable until they are evicted.
+	prefix := fmt.Sprintf("%s/%s/%d", endpoint.ID, endpoint.ActiveDeploymentID, endpoint.Cache.Generation)
+	key := httpcache.Key(prefix, r, endpoint.Cache.Vary)
+	if lookup {
+		if entry, ok := s.responseCache.Get(key); ok && entry.Matches(r) {
+			s.cacheCounters.record(endpoint.ID, true)
+			copyHeader(w.Header(), entry.Header)
+			w.Header().Set("Age", strconv.Itoa(entry.Age(time.Now())))
+			w.Header().Set("X-Cache", "HIT")
+			writeBody(w, r, endpoint.Compression, entry.StatusCode, entry.Body)
+			return nil, true
+		}
+	}
+	s.cacheCounters.record(endpoint.ID, false)
+	w.Header().Set("X-Cache", "MISS")
+	return &cacheRequest{
+		key:        key,
+		store:      store,
+		defaultTTL: time.Duration(endpoint.Cache.DefaultTTL) * time.Second,
+	}, false
+}
+
+// cacheResponse caches the response of the deployment for as long as its
+// Cache-Control allows shared caches to.
+func (s *WasmServer) cacheResponse(r *http.Request, req *cacheRequest, resp *proto.HTTPResponse, header http.Header) {
+	if !req.store {
+		return
+	}
+	ttl := httpcache.TTL(int(resp.StatusCode), header, req.defaultTTL)
+	if ttl <= 0 {
+		return
+	}
+	ttl = min(ttl, types.MaxCacheTTL*time.Second)
+	// The hits do not start a runtime.
+	header = header.Clone()
+	header.Del(shared.ColdStartHeader)
+	s.responseCache.Add(req.key, httpcache.NewEntry(r, int(resp.StatusCode), header, resp.Response, ttl))
+}
+
+// responseHeader returns the headers of the guest response that are
+// forwarded to the client.
+func responseHeader(h map[string]*proto.HeaderFields) http.Header {
+	header := shared.HeaderFromProto(h)
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, values := range src {
+		if isCORSHeader(k) && len(dst.Values(k)) > 0 {
+			continue
+		}
+		for _, v := range values {
+			dst.Add(k, v)
+		}
+	}
+}
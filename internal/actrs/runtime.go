@@ -4,20 +4,28 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/guestcache"
 	"github.com/anthdm/raptor/internal/runtime"
 	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/spidermonkey"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/proto"
 	"github.com/google/uuid"
-	"github.com/tetratelabs/wazero"
 
 	prot "google.golang.org/protobuf/proto"
 )
@@ -28,27 +36,148 @@ var (
 	runtimeKeepAlive = time.Second
 )
 
-type shutdown struct{}
+type (
+	shutdown    struct{}
+	checkHealth struct{}
+)
 
 // Runtime is an actor that can execute compiled WASM blobs in a distributed cluster.
 type Runtime struct {
 	store        storage.Store
-	cache        storage.ModCacher
+	compiler     *runtime.Compiler
+	tracker      *admin.Tracker
+	verifier     *signing.Verifier
+	region       string
+	memo         *guestcache.Cache
+	invoker      *async.Invoker
 	started      time.Time
 	deploymentID uuid.UUID
+	endpointID   uuid.UUID
+	// Environment of the last invocation, the health function is called
+	// with it.
+	env          map[string]string
+	healthRepeat *actor.SendRepeater
+	runtimeKey   string
 	managerPID   *actor.PID
 	runtime      *runtime.Runtime
 	repeat       actor.SendRepeater
-	stdout       *bytes.Buffer
+	stdout       *limitedBuffer
+	stderr       *tailBuffer
 	script       []byte
+	// The WebSocket connection served by the runtime, if any.
+	socket *webSocketSession
+	// The runtime is kept alive until then while it is prewarmed.
+	warmUntil time.Time
+}
+
+var errResponseTooLarge = errors.New("response exceeds the maximum size")
+
+// statusClientClosedRequest is the status of the invocations canceled because
+// their client went away, nobody reads it but it shows up in the logs.
+const statusClientClosedRequest = 499
+
+// limitedBuffer is the stdout of a runtime that fails the writes of the
+// guest beyond the maximum response size of the invocation.
+type limitedBuffer struct {
+	bytes.Buffer
+	// Maximum size in bytes, 0 is unlimited.
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return 0, errResponseTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// reset empties the buffer and sets the limit of the next invocation.
+func (b *limitedBuffer) reset(limit int) {
+	b.Buffer.Reset()
+	b.limit = limit
+	b.exceeded = false
+}
+
+const (
+	// maxStderrTail is the amount of the stderr of an invocation in bytes
+	// that is kept to describe its failure.
+	maxStderrTail = 8 * 1024
+	// maxStderrLines is the maximum amount of lines of the stderr recorded
+	// with an invocation error.
+	maxStderrLines = 50
+)
+
+// tailBuffer is the stderr of a runtime, it forwards the writes of the guest
+// to the stderr of the process and keeps the end of the last invocation.
+type tailBuffer struct {
+	b         []byte
+	truncated bool
 }
 
-func NewRuntime(store storage.Store, cache storage.ModCacher) actor.Producer {
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	os.Stderr.Write(p)
+	t.b = append(t.b, p...)
+	if over := len(t.b) - maxStderrTail; over > 0 {
+		t.b = t.b[over:]
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) reset() {
+	t.b = t.b[:0]
+	t.truncated = false
+}
+
+// lines returns the last lines that were kept, without the first line when
+// it was cut.
+func (t *tailBuffer) lines() []string {
+	s := strings.TrimRight(string(t.b), "\n")
+	if len(s) == 0 {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if t.truncated {
+		lines = lines[1:]
+	}
+	if len(lines) > maxStderrLines {
+		lines = lines[len(lines)-maxStderrLines:]
+	}
+	return lines
+}
+
+// NewRuntime returns a new runtime producer. The deployments are compiled by
+// the compiler of the member, shared with its warm-up, so concurrent cold
+// starts of the same deployment only compile once. The invocations in flight
+// are tracked by the given tracker so the member can be drained. Deployments are
+// only executed when their signature passes the verifier, a nil verifier
+// executes every deployment. The region of the member is exposed to the
+// guests in the context of their invocations.
+func NewRuntime(store storage.Store, compiler *runtime.Compiler, tracker *admin.Tracker, verifier *signing.Verifier, region string) actor.Producer {
+	// The cache the guests memoize values in is shared by all the runtimes
+	// on this member.
+	var guestCache *guestcache.Cache
+	if size := config.Get().Limits.CacheSize; size > 0 {
+		guestCache = guestcache.New(size)
+	}
+	// And the invoker of the endpoints the guests invoke.
+	var invoker *async.Invoker
+	if config.Get().Async.MaxDepth > 0 {
+		invoker = async.New(store, config.IngressUrl(), config.Get().Async)
+	}
 	return func() actor.Receiver {
 		return &Runtime{
-			store:  store,
-			cache:  cache,
-			stdout: &bytes.Buffer{},
+			store:    store,
+			compiler: compiler,
+			tracker:  tracker,
+			verifier: verifier,
+			region:   region,
+			memo:     guestCache,
+			invoker:  invoker,
+			stdout:   &limitedBuffer{},
+			stderr:   &tailBuffer{},
 		}
 	}
 }
@@ -60,71 +189,172 @@ func (r *Runtime) Receive(c *actor.Context) {
 		r.repeat = c.SendRepeat(c.PID(), shutdown{}, runtimeKeepAlive)
 	case actor.Stopped:
 		r.repeat.Stop()
+		if r.healthRepeat != nil {
+			r.healthRepeat.Stop()
+		}
+		if r.socket != nil {
+			r.socket.cancel()
+		}
 		// TODO: send metrics about the runtime to the metric actor.
 		_ = time.Since(r.started)
-		c.Send(r.managerPID, &proto.RemoveRuntime{Key: r.deploymentID.String()})
-		r.runtime.Close()
+		c.Send(r.managerPID, &proto.RemoveRuntime{Key: r.runtimeKey})
+		if r.runtime != nil {
+			r.runtime.Close()
+		}
 		// Releasing this mod will invalidate the cache for some reason.
 		// r.mod.Close(context.TODO())
 	case *proto.HTTPRequest:
 		slog.Info("runtime handling request", "request_id", msg.ID, "pid", c.PID())
 		// Refresh the keepAlive timer
+		r.repeat.Stop()
 		r.repeat = c.SendRepeat(c.PID(), shutdown{}, runtimeKeepAlive)
-		if r.runtime == nil {
-			r.initialize(msg)
+		cold := r.runtime == nil
+		if cold {
+			if err := r.initialize(c, msg.DeploymentID, msg.EndpointID, msg.Runtime); err != nil {
+				slog.Warn("runtime failed to initialize", "err", err)
+				r.sendError(c, msg, err)
+				message := "internal server error"
+				if errors.Is(err, signing.ErrInvalidSignature) || errors.Is(err, signing.ErrUnsigned) {
+					message = "deployment failed signature verification"
+				}
+				respondError(c, http.StatusInternalServerError, message, msg.ID)
+				c.Engine().Poison(c.PID())
+				return
+			}
 		}
 		// In the ideal world we should ask the cluster for the PID of the manager we
 		// need to notify we are done invoking. Hollywood does not have that functionality
 		// yet. To fix this we have the PID of the manager in the request messsage.
 		r.managerPID = msg.ManagerPID
+		r.runtimeKey = msg.RuntimeKey
+		r.env = msg.Env
 		// Handle the HTTP request that is forwarded from the WASM server actor.
-		r.handleHTTPRequest(c, msg)
+		r.handleHTTPRequest(c, msg, cold)
+	case *proto.WebSocketOpen:
+		cold := r.runtime == nil
+		if cold {
+			if err := r.initialize(c, msg.Request.DeploymentID, msg.Request.EndpointID, msg.Request.Runtime); err != nil {
+				slog.Warn("runtime failed to initialize", "err", err)
+			}
+		}
+		r.managerPID = msg.Request.ManagerPID
+		r.runtimeKey = msg.Request.RuntimeKey
+		r.env = msg.Request.Env
+		r.openWebSocket(c, msg, cold)
+	case *proto.WebSocketMessage:
+		if r.socket != nil && r.socket.id == msg.ConnectionID {
+			r.socket.deliver(msg)
+		}
+	case webSocketDone:
+		r.closeWebSocket(c)
+		c.Engine().Poison(c.PID())
+	case *proto.WarmRuntime:
+		r.warm(c, msg)
+	case checkHealth:
+		r.checkHealth(c)
 	case shutdown:
+		// The runtime lives as long as the connection it serves, and for as
+		// long as it is prewarmed.
+		if r.socket != nil || time.Now().Before(r.warmUntil) {
+			return
+		}
 		c.Engine().Poison(c.PID())
 	}
 }
 
-func (r *Runtime) initialize(msg *proto.HTTPRequest) error {
-	r.deploymentID = uuid.MustParse(msg.DeploymentID)
+func (r *Runtime) initialize(c *actor.Context, deploymentID, endpointID, engine string) error {
+	r.deploymentID = uuid.MustParse(deploymentID)
+	r.endpointID, _ = uuid.Parse(endpointID)
 	// TODO: this could be coming from a Redis cache instead of Postgres.
 	// Maybe only the blob. Not sure...
 	deploy, err := r.store.GetDeployment(r.deploymentID)
 	if err != nil {
 		return fmt.Errorf("runtime: could not find deployment (%s)", r.deploymentID)
 	}
-
-	modCache, ok := r.cache.Get(r.deploymentID)
-	if !ok {
-		slog.Warn("no cache hit", "endpoint", r.deploymentID)
-		modCache = wazero.NewCompilationCache()
+	// Tampered blobs are never executed.
+	if err := r.verifier.Verify(deploy.Blob, deploy.Signature); err != nil {
+		return fmt.Errorf("runtime: deployment (%s) rejected: %w", deploy.ID, err)
 	}
 
 	args := runtime.Args{
-		Cache:        modCache,
 		DeploymentID: deploy.ID,
-		Engine:       msg.Runtime,
+		Engine:       engine,
 		Stdout:       r.stdout,
+		Stderr:       r.stderr,
+		ScratchSize:  config.Get().Limits.ScratchSize,
 	}
 
-	switch args.Engine {
-	case "js":
+	args.Blob = moduleBlob(args.Engine, deploy)
+	if args.Engine == "js" {
 		r.script = deploy.Blob
-		args.Blob = spidermonkey.WasmBlob
-	default:
-		args.Blob = deploy.Blob
 	}
 
+	start := time.Now()
+	modCache, coalesced, err := r.compiler.Compile(context.Background(), deploy.ID, args.Blob)
+	if err != nil {
+		return err
+	}
+	if coalesced {
+		slog.Info("coalesced compilation", "deployment", deploy.ID, "stats", r.compiler.Stats())
+	}
+	r.tracker.ObserveCompile(types.CompileMetric{
+		DeploymentID: deploy.ID,
+		Duration:     time.Since(start),
+		Coalesced:    coalesced,
+	})
+	args.Cache = modCache
+
 	run, err := runtime.New(context.Background(), args)
 	if err != nil {
 		return err
 	}
 	r.runtime = run
-	r.cache.Put(deploy.ID, modCache)
+	// Warm runtimes periodically call the health function of the guest.
+	if interval := time.Duration(config.Get().Health.Interval); interval > 0 && run.HasHealthCheck() {
+		repeat := c.SendRepeat(c.PID(), checkHealth{}, interval)
+		r.healthRepeat = &repeat
+	}
 
 	return nil
 }
 
-func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest) {
+// warm instantiates the deployment ahead of its requests and keeps the
+// runtime alive until the prewarm expires. The prewarmer renews it for as
+// long as the endpoint asks for it.
+func (r *Runtime) warm(c *actor.Context, msg *proto.WarmRuntime) {
+	if r.runtime == nil {
+		if err := r.initialize(c, msg.DeploymentID, msg.EndpointID, msg.Runtime); err != nil {
+			slog.Warn("runtime failed to prewarm", "deployment", msg.DeploymentID, "err", err)
+			c.Engine().Poison(c.PID())
+			return
+		}
+	}
+	r.managerPID = msg.ManagerPID
+	r.runtimeKey = msg.RuntimeKey
+	if r.env == nil {
+		r.env = msg.Env
+	}
+	r.warmUntil = time.UnixMilli(msg.Until)
+}
+
+// checkHealth calls the health function of the guest and reports the outcome
+// to the metric actor.
+func (r *Runtime) checkHealth(c *actor.Context) {
+	ctx := context.Background()
+	if timeout := time.Duration(config.Get().Health.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := r.runtime.Health(ctx, r.env)
+	metricPID := c.Engine().Registry.GetPID(KindMetric, "1")
+	c.Send(metricPID, *types.NewHealthCheck(r.endpointID, r.deploymentID, start, err))
+}
+
+func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest, cold bool) {
+	r.tracker.Begin()
+	defer r.tracker.End()
 	start := time.Now()
 	b, err := prot.Marshal(msg)
 	if err != nil {
@@ -135,17 +365,78 @@ func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest)
 
 	args := []string{}
 	if msg.Runtime == "js" {
-		args = []string{"", "-e", string(r.script)}
+		script, err := spidermonkey.Script(r.script, msg)
+		if err != nil {
+			slog.Warn("failed to build js script", "err", err)
+			respondError(ctx, http.StatusInternalServerError, "internal server error", msg.ID)
+			return
+		}
+		args = []string{"", "-e", script}
 	}
 
+	// The ingress cancels the invocation when its client goes away.
+	invokeCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !invocations.start(msg.ID, cancel) {
+		respondError(ctx, statusClientClosedRequest, "client closed request", msg.ID)
+		return
+	}
+	defer invocations.done(msg.ID)
+	if msg.Timeout > 0 {
+		invokeCtx, cancel = context.WithTimeout(invokeCtx, time.Duration(msg.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+	// Guests can stream text/event-stream responses to the ingress while
+	// they are running.
+	invokeCtx = runtime.WithStream(invokeCtx, &responseStream{
+		engine:    ctx.Engine(),
+		ingress:   ctx.Sender(),
+		requestID: msg.ID,
+	})
+	progress := newInvocationProgress(r.store, msg)
+	invokeCtx = runtime.WithProgress(invokeCtx, progress)
+	invokeCtx = runtime.WithInvocation(invokeCtx, r.invocation(msg, cold))
+	if r.memo != nil {
+		invokeCtx = runtime.WithCache(invokeCtx, r.cacheScope(msg))
+	}
+	if r.invoker != nil {
+		invokeCtx = runtime.WithInvoker(invokeCtx, newGuestInvoker(r.store, r.invoker, msg, config.Get().Async.MaxDepth))
+	}
+	if workflow, ok := stepWorkflow(r.store, msg); ok {
+		invokeCtx = runtime.WithWorkflow(invokeCtx, workflow)
+	}
+	r.stdout.reset(int(msg.MaxResponseSize))
+	r.stderr.reset()
 	req := bytes.NewReader(b)
-	if err := r.runtime.Invoke(req, msg.Env, args...); err != nil {
+	err = r.runtime.InvokeContext(invokeCtx, req, invocationEnv(msg), args...)
+	// The last progress is stored before the invoker sees the response.
+	progress.flush()
+	if err != nil {
 		slog.Warn("runtime invoke error", "err", err)
-		respondError(ctx, http.StatusInternalServerError, "internal server error", msg.ID)
+		switch {
+		case errors.Is(invokeCtx.Err(), context.Canceled):
+			slog.Info("invocation canceled by the client", "request_id", msg.ID)
+			respondError(ctx, statusClientClosedRequest, "client closed request", msg.ID)
+		case invokeCtx.Err() != nil:
+			respondError(ctx, http.StatusGatewayTimeout, "invocation timed out", msg.ID)
+			r.sendEvent(ctx, msg, types.EventQuotaExceeded, "invocation timed out")
+		case r.stdout.exceeded:
+			respondError(ctx, http.StatusInternalServerError, errResponseTooLarge.Error(), msg.ID)
+			r.sendEvent(ctx, msg, types.EventQuotaExceeded, errResponseTooLarge.Error())
+		default:
+			respondError(ctx, http.StatusInternalServerError, "internal server error", msg.ID)
+			r.sendEvent(ctx, msg, types.EventInvocationFailed, err.Error())
+			r.sendError(ctx, msg, err)
+		}
+		return
+	}
+	if r.stdout.exceeded {
+		respondError(ctx, http.StatusInternalServerError, errResponseTooLarge.Error(), msg.ID)
+		r.sendEvent(ctx, msg, types.EventQuotaExceeded, errResponseTooLarge.Error())
 		return
 	}
 
-	logs, res, status, err := shared.ParseStdout(r.stdout)
+	logs, res, header, status, err := shared.ParseStdoutWithHeader(r.stdout)
 	if err != nil {
 		respondError(ctx, http.StatusInternalServerError, "invalid response", msg.ID)
 		return
@@ -155,31 +446,166 @@ func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest)
 		RequestID:  msg.ID,
 		StatusCode: int32(status),
 	}
+	if header != nil {
+		if trailer := shared.SplitTrailer(header); trailer != nil {
+			resp.Trailer = shared.MakeProtoHeader(trailer)
+		}
+		resp.Header = shared.MakeProtoHeader(header)
+	}
+	if msg.Preview && wantsPreviewLogs(msg) {
+		setPreviewLogs(resp, logs)
+	}
+	if cold {
+		if resp.Header == nil {
+			resp.Header = make(map[string]*proto.HeaderFields)
+		}
+		resp.Header[shared.ColdStartHeader] = &proto.HeaderFields{Fields: []string{"true"}}
+	}
 
 	ctx.Respond(resp)
 	r.stdout.Reset()
 
 	// only send metrics and logs when its a request on LIVE
 	if !msg.Preview {
+		budget := r.runtime.TimeBudget()
+		endpointID, _ := uuid.Parse(msg.EndpointID)
 		metric := types.RequestMetric{
-			ID:           uuid.New(),
-			Duration:     time.Since(start),
-			DeploymentID: r.deploymentID,
-			// EndpointID:   deploy.EndpointID,
-			RequestURL: msg.URL,
-			StatusCode: status,
+			ID:              invocationID(msg),
+			Duration:        time.Since(start),
+			DeploymentID:    r.deploymentID,
+			EndpointID:      endpointID,
+			RequestURL:      msg.URL,
+			Route:           msg.Route,
+			Protocol:        msg.Protocol,
+			StatusCode:      status,
+			ComputeDuration: budget.Compute,
+			WaitDuration:    budget.Wait,
+			MemoryUsage:     r.runtime.MemoryUsage(),
+			BytesIn:         int64(len(msg.Body)),
+			BytesOut:        int64(len(res)),
+			CreatedAT:       start,
 		}
 		metricPID := ctx.Engine().Registry.GetPID(KindMetric, "1")
 		ctx.Send(metricPID, metric)
 
 		runtimeLogPID := ctx.Engine().Registry.GetPID(KindRuntimeLog, "1")
 		runtimeLog := types.RuntimeLogEvent{
-			Data: logs,
+			EndpointID:   endpointID,
+			DeploymentID: r.deploymentID,
+			RequestID:    msg.ID,
+			Data:         logs,
+			CreatedAT:    start,
 		}
 		ctx.Send(runtimeLogPID, runtimeLog)
 	}
 }
 
+// sendEvent sends a platform event of a failed LIVE invocation to the metric
+// actor, which emits it.
+func (r *Runtime) sendEvent(ctx *actor.Context, msg *proto.HTTPRequest, eventType, message string) {
+	if msg.Preview {
+		return
+	}
+	endpointID, _ := uuid.Parse(msg.EndpointID)
+	metricPID := ctx.Engine().Registry.GetPID(KindMetric, "1")
+	ctx.Send(metricPID, *types.NewEvent(eventType, endpointID, r.deploymentID, "", message))
+}
+
+// sendError records the failure of a LIVE invocation together with the last
+// lines the guest wrote to stderr.
+func (r *Runtime) sendError(ctx *actor.Context, msg *proto.HTTPRequest, err error) {
+	if msg.Preview {
+		return
+	}
+	e := runtime.DescribeError(err, r.stderr.lines())
+	e.ID = invocationID(msg)
+	e.EndpointID, _ = uuid.Parse(msg.EndpointID)
+	e.DeploymentID = r.deploymentID
+	e.CreatedAT = time.Now()
+	metricPID := ctx.Engine().Registry.GetPID(KindMetric, "1")
+	ctx.Send(metricPID, e)
+}
+
+// invocationID returns the id of the invocation of the request, the id the
+// ingress gave the request.
+func invocationID(msg *proto.HTTPRequest) uuid.UUID {
+	id, err := uuid.Parse(msg.ID)
+	if err != nil {
+		return uuid.New()
+	}
+	return id
+}
+
+// invocationEnv returns the environment of the invocation of the request,
+// the environment of the endpoint along with the id of the request and the
+// claims of its validated token.
+func invocationEnv(msg *proto.HTTPRequest) map[string]string {
+	env := make(map[string]string, len(msg.Env)+3)
+	for k, v := range msg.Env {
+		env[k] = v
+	}
+	env[shared.RequestIDEnv] = msg.ID
+	if fields, ok := msg.Header[shared.JWTClaimsHeader]; ok && len(fields.Fields) > 0 {
+		env[shared.JWTClaimsEnv] = fields.Fields[0]
+		if fields, ok := msg.Header[shared.JWTSubjectHeader]; ok && len(fields.Fields) > 0 {
+			env[shared.JWTSubjectEnv] = fields.Fields[0]
+		}
+	}
+	return env
+}
+
+// cacheScope returns the view of the guest cache of the endpoint of the
+// request. The deployments in PREVIEW do not share the entries of the LIVE
+// deployment, so they can not corrupt them.
+func (r *Runtime) cacheScope(msg *proto.HTTPRequest) guestcache.Scope {
+	scope := msg.EndpointID
+	if msg.Preview {
+		scope = "preview/" + scope
+	}
+	return r.memo.Scope(scope, int(msg.CacheQuota))
+}
+
+// invocation returns the platform context of the invocation of the request
+// for the guest.
+func (r *Runtime) invocation(msg *proto.HTTPRequest, cold bool) runtime.Invocation {
+	return runtime.Invocation{
+		RequestID:    msg.ID,
+		DeploymentID: r.deploymentID.String(),
+		EndpointID:   msg.EndpointID,
+		Region:       r.region,
+		ColdStart:    cold,
+		Preview:      msg.Preview,
+	}
+}
+
+func wantsPreviewLogs(req *proto.HTTPRequest) bool {
+	fields, ok := req.Header[shared.PreviewLogsHeader]
+	return ok && len(fields.Fields) > 0 && fields.Fields[0] == "true"
+}
+
+// setPreviewLogs sends the logs of a PREVIEW invocation back in a response
+// header, so they can be inspected without access to the log sink.
+func setPreviewLogs(resp *proto.HTTPResponse, logs []byte) {
+	if len(logs) > shared.MaxPreviewLogs {
+		logs = logs[len(logs)-shared.MaxPreviewLogs:]
+	}
+	if resp.Header == nil {
+		resp.Header = make(map[string]*proto.HeaderFields)
+	}
+	resp.Header[shared.PreviewLogsHeader] = &proto.HeaderFields{
+		Fields: []string{base64.StdEncoding.EncodeToString(logs)},
+	}
+}
+
+// moduleBlob returns the WASM module that runs the deployment on the given
+// engine. JS deployments are scripts interpreted by spidermonkey.
+func moduleBlob(engine string, deploy *types.Deployment) []byte {
+	if engine == "js" {
+		return spidermonkey.WasmBlob
+	}
+	return deploy.Blob
+}
+
 func respondError(ctx *actor.Context, code int32, msg string, id string) {
 	ctx.Respond(&proto.HTTPResponse{
 		Response:   []byte(msg),
@@ -1,23 +1,118 @@
 package actrs
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/export"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
 )
 
 const KindRuntimeLog = "runtime_log"
 
-type RuntimeLog struct{}
+// Maximum amount of log events buffered between two exports, events are
+// dropped when the buffer is full.
+const runtimeLogBufferSize = 10000
+
+type flushRuntimeLogs struct{}
+
+// runtimeLogRecord is an exported log event.
+type runtimeLogRecord struct {
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	RequestID    string    `json:"request_id"`
+	Logs         string    `json:"logs"`
+	CreatedAT    time.Time `json:"created_at"`
+}
 
-func NewRuntimeLog() actor.Receiver {
-	return &RuntimeLog{}
+// RuntimeLog collects the logs of the invocations on this member and
+// exports them to the sink once per interval. The logs are dropped when
+// there is no sink.
+type RuntimeLog struct {
+	sink     export.Sink
+	interval time.Duration
+	member   string
+	flusher  actor.SendRepeater
+	records  []runtimeLogRecord
+	// Start of the window of the buffered records.
+	window  time.Time
+	dropped int
+}
+
+// NewRuntimeLog returns a new runtime log producer. The files of the member
+// are named after the given member id.
+func NewRuntimeLog(sink export.Sink, interval time.Duration, member string) actor.Producer {
+	return func() actor.Receiver {
+		return &RuntimeLog{
+			sink:     sink,
+			interval: interval,
+			member:   member,
+		}
+	}
 }
 
 func (rl *RuntimeLog) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
+		rl.window = time.Now()
+		if rl.sink != nil {
+			rl.flusher = c.SendRepeat(c.PID(), flushRuntimeLogs{}, rl.interval)
+		}
 	case actor.Stopped:
+		if rl.sink != nil {
+			rl.flusher.Stop()
+			rl.flush(false)
+		}
+	case flushRuntimeLogs:
+		rl.flush(true)
 	case types.RuntimeLogEvent:
-		_ = msg
+		if rl.sink == nil {
+			return
+		}
+		if len(rl.records) >= runtimeLogBufferSize {
+			rl.dropped++
+			return
+		}
+		rl.records = append(rl.records, runtimeLogRecord{
+			EndpointID:   msg.EndpointID,
+			DeploymentID: msg.DeploymentID,
+			RequestID:    msg.RequestID,
+			Logs:         string(msg.Data),
+			CreatedAT:    msg.CreatedAT,
+		})
+	}
+}
+
+// flush exports the buffered records. The export runs in the background
+// unless the actor is stopping.
+func (rl *RuntimeLog) flush(async bool) {
+	if rl.dropped > 0 {
+		slog.Warn("dropped runtime logs, the export buffer is full", "count", rl.dropped)
+		rl.dropped = 0
+	}
+	records, window := rl.records, rl.window
+	rl.records, rl.window = nil, time.Now()
+	if len(records) == 0 {
+		return
+	}
+	put := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		b, err := export.EncodeJSONL(records)
+		if err == nil {
+			name := rl.member + "-" + window.UTC().Format("20060102T150405Z")
+			err = rl.sink.Put(ctx, export.Key(export.DatasetLogs, window, name), b)
+		}
+		if err != nil {
+			slog.Warn("failed to export runtime logs", "count", len(records), "err", err)
+		}
+	}
+	if async {
+		go put()
+		return
 	}
+	put()
 }
@@ -10,7 +10,8 @@ const KindRuntimeManager = "runtime_manager"
 
 type (
 	requestRuntime struct {
-		key string
+		key    string
+		region string
 	}
 )
 
@@ -35,7 +36,8 @@ func (rm *RuntimeManager) Receive(c *actor.Context) {
 	case requestRuntime:
 		pid := rm.runtimes[msg.key]
 		if pid == nil {
-			pid = rm.cluster.Activate(KindRuntime, cluster.NewActivationConfig())
+			config := cluster.NewActivationConfig().WithRegion(msg.region)
+			pid = rm.cluster.Activate(KindRuntime, config)
 			rm.runtimes[msg.key] = pid
 		}
 		c.Respond(pid)
@@ -0,0 +1,182 @@
+package actrs
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/signing"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeVerifiesSignature(t *testing.T) {
+	blob, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+	publicKey, privateKey, err := signing.GenerateKey()
+	require.Nil(t, err)
+	key, err := signing.ParsePrivateKey(privateKey)
+	require.Nil(t, err)
+	verifier, err := signing.NewVerifier([]string{publicKey}, false)
+	require.Nil(t, err)
+
+	modified := append([]byte{}, blob...)
+	modified[len(modified)-1] ^= 0xff
+
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("signed", "go", nil)
+		signed   = types.NewDeployment(endpoint, blob)
+		tampered = types.NewDeployment(endpoint, modified)
+	)
+	signed.Signature = signing.Sign(key, blob)
+	// The signature of the original blob does not match the tampered one.
+	tampered.Signature = signed.Signature
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.Nil(t, store.CreateDeployment(signed))
+	require.Nil(t, store.CreateDeployment(tampered))
+
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	producer := NewRuntime(store, runtime.NewCompiler(storage.NewDefaultModCache()), admin.NewTracker(), verifier, "")
+	invoke := func(deploy *types.Deployment) *proto.HTTPResponse {
+		req := &proto.HTTPRequest{
+			ID:           uuid.NewString(),
+			Method:       "GET",
+			URL:          "/",
+			EndpointID:   endpoint.ID.String(),
+			DeploymentID: deploy.ID.String(),
+			Runtime:      "go",
+			Preview:      true,
+		}
+		pid := e.Spawn(producer, KindRuntime)
+		res, err := e.Request(pid, req, time.Second*10).Result()
+		require.Nil(t, err)
+		resp, ok := res.(*proto.HTTPResponse)
+		require.True(t, ok)
+		return resp
+	}
+
+	require.Equal(t, int32(http.StatusOK), invoke(signed).StatusCode)
+	resp := invoke(tampered)
+	require.Equal(t, int32(http.StatusInternalServerError), resp.StatusCode)
+	require.Equal(t, "deployment failed signature verification", string(resp.Response))
+}
+
+func TestRuntimePreviewLogs(t *testing.T) {
+	blob, err := os.ReadFile("../_testdata/conformance.wasm")
+	require.Nil(t, err)
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("logs", "go", nil)
+		deploy   = types.NewDeployment(endpoint, blob)
+	)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.Nil(t, store.CreateDeployment(deploy))
+
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	producer := NewRuntime(store, runtime.NewCompiler(storage.NewDefaultModCache()), admin.NewTracker(), nil, "")
+	invoke := func(header map[string]*proto.HeaderFields) *proto.HTTPResponse {
+		req := &proto.HTTPRequest{
+			ID:           uuid.NewString(),
+			Method:       "GET",
+			URL:          "/",
+			EndpointID:   endpoint.ID.String(),
+			DeploymentID: deploy.ID.String(),
+			Runtime:      "go",
+			Preview:      true,
+			Header:       header,
+		}
+		pid := e.Spawn(producer, KindRuntime)
+		res, err := e.Request(pid, req, time.Second*10).Result()
+		require.Nil(t, err)
+		resp, ok := res.(*proto.HTTPResponse)
+		require.True(t, ok)
+		return resp
+	}
+
+	// The logs are only sent back when asked for.
+	require.Nil(t, invoke(nil).Header[shared.PreviewLogsHeader])
+	resp := invoke(map[string]*proto.HeaderFields{
+		shared.PreviewLogsHeader: {Fields: []string{"true"}},
+	})
+	fields := resp.Header[shared.PreviewLogsHeader].GetFields()
+	require.Len(t, fields, 1)
+	logs, err := base64.StdEncoding.DecodeString(fields[0])
+	require.Nil(t, err)
+	require.Equal(t, "conformance guest log line\n", string(logs))
+}
+
+func TestRuntimeColdStartHeader(t *testing.T) {
+	blob, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("cold", "go", nil)
+		deploy   = types.NewDeployment(endpoint, blob)
+	)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.Nil(t, store.CreateDeployment(deploy))
+
+	// The module is compiled ahead, the runtime shuts down when a request
+	// takes longer than its keep alive.
+	compiler := runtime.NewCompiler(storage.NewDefaultModCache())
+	_, _, err = compiler.Compile(context.Background(), deploy.ID, blob)
+	require.Nil(t, err)
+
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	pid := e.Spawn(NewRuntime(store, compiler, admin.NewTracker(), nil, ""), KindRuntime)
+	invoke := func() *proto.HTTPResponse {
+		req := &proto.HTTPRequest{
+			ID:           uuid.NewString(),
+			Method:       "GET",
+			URL:          "/",
+			EndpointID:   endpoint.ID.String(),
+			DeploymentID: deploy.ID.String(),
+			Runtime:      "go",
+			Preview:      true,
+		}
+		res, err := e.Request(pid, req, time.Second*10).Result()
+		require.Nil(t, err)
+		resp, ok := res.(*proto.HTTPResponse)
+		require.True(t, ok)
+		return resp
+	}
+
+	// Only the invocation that instantiated the runtime is a cold start.
+	resp := invoke()
+	require.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	require.Contains(t, resp.Header, shared.ColdStartHeader)
+	require.NotContains(t, invoke().Header, shared.ColdStartHeader)
+	// The cold start used the compilation of the member's compiler.
+	require.Equal(t, int64(1), compiler.Stats().Compiles)
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{}
+	tail.Write([]byte("panic: boom\n\ngoroutine 1 [running]:\n"))
+	require.Equal(t, []string{"panic: boom", "", "goroutine 1 [running]:"}, tail.lines())
+
+	// Only the end of long output is kept, without the line that was cut.
+	tail.reset()
+	require.Nil(t, tail.lines())
+	for i := 0; i < 500; i++ {
+		tail.Write([]byte("a line of stderr output\n"))
+	}
+	lines := tail.lines()
+	require.Len(t, lines, maxStderrLines)
+	require.Equal(t, "a line of stderr output", lines[0])
+	require.LessOrEqual(t, len(tail.b), maxStderrTail)
+}
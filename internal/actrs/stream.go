@@ -0,0 +1,167 @@
+package actrs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+)
+
+// Amount of chunks of a streamed response buffered by the ingress. Chunks
+// beyond are dropped when the client does not keep up.
+const streamQueueSize = 256
+
+var errNotEventStream = errors.New("only text/event-stream responses can be streamed")
+
+// errNoResponse is returned when the runtime did not respond within the
+// timeout of the invocation and its grace, like when its member is gone.
+var errNoResponse = errors.New("runtime did not respond in time")
+
+// isEventStream returns true if the content type is the content type of
+// Server-Sent Events.
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// responseStream streams the chunks of a response from the runtime to the
+// ingress that forwarded the request.
+type responseStream struct {
+	engine    *actor.Engine
+	ingress   *actor.PID
+	requestID string
+}
+
+func (s *responseStream) Start(status int, header []byte) error {
+	if s.ingress == nil {
+		return errors.New("request has no ingress to stream to")
+	}
+	h := shared.ParseHeader(header)
+	if !isEventStream(h.Get("Content-Type")) {
+		return errNotEventStream
+	}
+	if status < 100 || status > 999 {
+		return errors.New("invalid status code")
+	}
+	s.engine.Send(s.ingress, &proto.HTTPResponseChunk{
+		RequestID:  s.requestID,
+		StatusCode: int32(status),
+		Header:     shared.MakeProtoHeader(h),
+	})
+	return nil
+}
+
+func (s *responseStream) Write(b []byte) error {
+	s.engine.Send(s.ingress, &proto.HTTPResponseChunk{
+		RequestID: s.requestID,
+		Data:      b,
+	})
+	return nil
+}
+
+// handleResponseChunk hands a chunk of a streamed response to the goroutine
+// serving the request.
+func (s *WasmServer) handleResponseChunk(msg *proto.HTTPResponseChunk) {
+	chunks, ok := s.streams[msg.RequestID]
+	if !ok {
+		return
+	}
+	select {
+	case chunks <- msg:
+	default:
+		slog.Warn("dropping streamed response of slow client", "request_id", msg.RequestID)
+		delete(s.streams, msg.RequestID)
+	}
+}
+
+// awaitResponse waits for the response of the runtime. Streamed responses are
+// written to the client chunk by chunk as they arrive, true is returned when
+// the response was streamed. A nil response is returned when the request
+// needs to be proxied to the fallback origin of the endpoint. The error of
+// the context is returned when the client goes away first, errNoResponse when
+// there is no response within the timeout, 0 waits for as long as it takes.
+func awaitResponse(ctx context.Context, w http.ResponseWriter, reqres requestWithResponse, timeout time.Duration) (*proto.HTTPResponse, bool, error) {
+	var (
+		rc       = http.NewResponseController(w)
+		streamed bool
+		expired  <-chan time.Time
+	)
+	// The invocation is bounded by its own timeout, the write timeout only
+	// bounds the writes to the client.
+	rc.SetWriteDeadline(time.Time{})
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, streamed, ctx.Err()
+		case <-expired:
+			return nil, streamed, errNoResponse
+		case <-reqres.unavailable:
+			extendWriteDeadline(rc)
+			return nil, false, nil
+		case chunk := <-reqres.chunks:
+			extendWriteDeadline(rc)
+			streamed = writeChunk(w, rc, chunk, streamed)
+		case resp := <-reqres.response:
+			extendWriteDeadline(rc)
+			// Chunks that arrived before the end of the response.
+			for len(reqres.chunks) > 0 {
+				streamed = writeChunk(w, rc, <-reqres.chunks, streamed)
+			}
+			if streamed {
+				if resp.StatusCode >= http.StatusInternalServerError {
+					slog.Warn("streamed response failed", "request_id", resp.RequestID, "status", resp.StatusCode)
+				} else if len(resp.Response) > 0 {
+					w.Write(resp.Response)
+				}
+			}
+			return resp, streamed, nil
+		}
+	}
+}
+
+// responseTimeout returns how long the ingress waits for the response of the
+// request: the time it may wait in the queue, the timeout of the invocation
+// and the grace the runtimes have to respond past it. 0 when the invocation
+// has no timeout.
+func responseTimeout(req *proto.HTTPRequest) time.Duration {
+	if req.Timeout <= 0 {
+		return 0
+	}
+	return time.Duration(config.Get().Ingress.QueueTimeout) + time.Duration(req.Timeout)*time.Millisecond + inflightGrace
+}
+
+// extendWriteDeadline gives the client the write timeout of the ingress to
+// read what is written next.
+func extendWriteDeadline(rc *http.ResponseController) {
+	if d := time.Duration(config.Get().Ingress.WriteTimeout); d > 0 {
+		rc.SetWriteDeadline(time.Now().Add(d))
+	}
+}
+
+// writeChunk writes the chunk to the client and flushes it right away.
+func writeChunk(w http.ResponseWriter, rc *http.ResponseController, chunk *proto.HTTPResponseChunk, started bool) bool {
+	if !started {
+		if chunk.StatusCode == 0 {
+			return false
+		}
+		copyHeader(w.Header(), responseHeader(chunk.Header))
+		w.WriteHeader(int(chunk.StatusCode))
+	}
+	if len(chunk.Data) > 0 {
+		w.Write(chunk.Data)
+	}
+	rc.Flush()
+	return true
+}
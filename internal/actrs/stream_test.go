@@ -0,0 +1,54 @@
+package actrs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseStreamOnlyStreamsEventStreams(t *testing.T) {
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	stream := &responseStream{engine: e, ingress: actor.NewPID("local", "ingress"), requestID: "1"}
+	require.Equal(t, errNotEventStream, stream.Start(http.StatusOK, []byte("Content-Type: text/html\r\n")))
+	require.Nil(t, stream.Start(http.StatusOK, []byte("Content-Type: text/event-stream; charset=utf-8\r\n")))
+}
+
+func TestAwaitStreamedResponse(t *testing.T) {
+	reqres := newRequestWithResponse(&proto.HTTPRequest{ID: "1"}, "", 0)
+	reqres.chunks <- &proto.HTTPResponseChunk{
+		RequestID:  "1",
+		StatusCode: http.StatusOK,
+		Header: shared.MakeProtoHeader(http.Header{
+			"Content-Type":   {"text/event-stream"},
+			"Content-Length": {"100"},
+		}),
+	}
+	reqres.chunks <- &proto.HTTPResponseChunk{RequestID: "1", Data: []byte("data: 0\n\n")}
+	reqres.chunks <- &proto.HTTPResponseChunk{RequestID: "1", Data: []byte("data: 1\n\n")}
+	reqres.response <- &proto.HTTPResponse{RequestID: "1", StatusCode: http.StatusOK}
+
+	w := httptest.NewRecorder()
+	_, streamed, _ := awaitResponse(context.Background(), w, reqres, 0)
+	require.True(t, streamed)
+	require.True(t, w.Flushed)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.Empty(t, w.Header().Get("Content-Length"))
+	require.Equal(t, "data: 0\n\ndata: 1\n\n", w.Body.String())
+
+	// Buffered responses are left to the caller.
+	reqres = newRequestWithResponse(&proto.HTTPRequest{ID: "2"}, "", 0)
+	reqres.response <- &proto.HTTPResponse{RequestID: "2", StatusCode: http.StatusOK, Response: []byte("hello")}
+	w = httptest.NewRecorder()
+	resp, streamed, _ := awaitResponse(context.Background(), w, reqres, 0)
+	require.False(t, streamed)
+	require.Equal(t, "hello", string(resp.Response))
+	require.Empty(t, w.Body.String())
+}
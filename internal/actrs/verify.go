@@ -0,0 +1,38 @@
+package actrs
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+)
+
+// verifyRequest checks the signature of the request when the endpoint
+// verifies its requests. The request is answered and false is returned when
+// it can not be verified.
+func verifyRequest(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint, req *proto.HTTPRequest) bool {
+	verification := endpoint.Verification
+	if !verification.Enabled() {
+		return true
+	}
+	secret, ok := endpoint.Environment[verification.SecretEnv]
+	if !ok || len(secret) == 0 {
+		slog.Warn("request verification secret is not set", "endpoint", endpoint.ID, "env", verification.SecretEnv)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("request verification secret is not set"))
+		return false
+	}
+	path := req.URL
+	if len(r.URL.RawQuery) > 0 {
+		path += "?" + r.URL.RawQuery
+	}
+	header := r.Header.Get(verification.SignatureHeader())
+	if err := verification.Verify(secret, header, req.Method, path, req.Body, time.Now()); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package actrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	endpoint := &types.Endpoint{
+		Environment:  map[string]string{"WEBHOOK_SECRET": "secret"},
+		Verification: &types.RequestVerification{SecretEnv: "WEBHOOK_SECRET", Header: "X-Signature"},
+	}
+	body := `{"event":"paid"}`
+	newRequest := func(signature string) (*httptest.ResponseRecorder, bool) {
+		r := httptest.NewRequest("POST", "/live/7d3f1d2c-4c5e-4a4e-9a3b-2f1e0d9c8b7a/hooks?source=stripe", strings.NewReader(body))
+		if len(signature) > 0 {
+			r.Header.Set("X-Signature", signature)
+		}
+		req, err := shared.MakeProtoRequest("1", r)
+		require.Nil(t, err)
+		w := httptest.NewRecorder()
+		return w, verifyRequest(w, r, endpoint, req)
+	}
+
+	_, ok := newRequest(types.SignRequest("secret", time.Now(), "POST", "/hooks?source=stripe", []byte(body)))
+	require.True(t, ok)
+	w, ok := newRequest(types.SignRequest("other", time.Now(), "POST", "/hooks?source=stripe", []byte(body)))
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w, ok = newRequest("")
+	require.False(t, ok)
+	require.Equal(t, types.ErrMissingSignature.Error(), w.Body.String())
+
+	// The requests are rejected while the secret is not set.
+	endpoint.Environment = nil
+	w, ok = newRequest(types.SignRequest("secret", time.Now(), "POST", "/hooks?source=stripe", []byte(body)))
+	require.False(t, ok)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,80 @@
+package actrs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/google/uuid"
+)
+
+// WarmModCache compiles the LIVE deployments of all the endpoints with the
+// compiler of the member, so a member that just joined the cluster does not
+// pay the cold starts of the member it replaces. The cold starts during the
+// warm-up wait for its compilations. Deployments that fail to compile are
+// skipped.
+func WarmModCache(ctx context.Context, store storage.Store, compiler *runtime.Compiler) error {
+	endpoints, err := store.GetEndpoints()
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range endpoints {
+		if !endpoint.HasActiveDeploy() {
+			continue
+		}
+		deploy, err := store.GetDeployment(endpoint.ActiveDeploymentID)
+		if err != nil {
+			slog.Warn("failed to load deployment to warm up", "deployment", endpoint.ActiveDeploymentID, "err", err)
+			continue
+		}
+		if _, _, err := compiler.Compile(ctx, deploy.ID, moduleBlob(endpoint.Runtime, deploy)); err != nil {
+			slog.Warn("failed to warm up deployment", "deployment", deploy.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// SweepModCache evicts the deployments that were pruned from the store from
+// the given mod cache once per interval, until the context is done.
+func SweepModCache(ctx context.Context, store storage.Store, cache storage.ModCacher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := sweepModCache(store, cache); err != nil {
+			slog.Warn("failed to sweep the mod cache", "err", err)
+		}
+	}
+}
+
+func sweepModCache(store storage.Store, cache storage.ModCacher) error {
+	// The keys are read before the deployments so a deployment compiled in
+	// between is never evicted.
+	keys := cache.Keys()
+	endpoints, err := store.GetEndpoints()
+	if err != nil {
+		return err
+	}
+	exists := make(map[uuid.UUID]bool)
+	for _, endpoint := range endpoints {
+		deploys, err := store.GetDeployments(endpoint.ID)
+		if err != nil {
+			return err
+		}
+		for _, deploy := range deploys {
+			exists[deploy.ID] = true
+		}
+	}
+	for _, id := range keys {
+		if !exists[id] {
+			cache.Delete(id)
+		}
+	}
+	return nil
+}
@@ -1,18 +1,32 @@
 package actrs
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/httpcache"
+	"github.com/anthdm/raptor/internal/jwt"
+	"github.com/anthdm/raptor/internal/quota"
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/proto"
 	"github.com/google/uuid"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const KindWasmServer = "wasm_server"
@@ -20,15 +34,66 @@ const KindWasmServer = "wasm_server"
 type requestWithResponse struct {
 	request  *proto.HTTPRequest
 	response chan *proto.HTTPResponse
+	// chunks of the response when the guest streams it.
+	chunks chan *proto.HTTPResponseChunk
+	// region where the runtime preferably needs to be activated.
+	region string
+	// maximum amount of concurrent requests of the endpoint, 0 is unlimited.
+	maxConcurrency int
+	// id of the endpoint the concurrency limit and queue belong to. This is
+	// the target endpoint when the request is routed by a composite endpoint.
+	endpointID string
+	// Signaled instead of a 503 response when the cluster can not serve the
+	// request and the endpoint has a fallback origin, nil otherwise.
+	unavailable chan struct{}
 }
 
-func newRequestWithResponse(request *proto.HTTPRequest) requestWithResponse {
+func newRequestWithResponse(request *proto.HTTPRequest, region string, maxConcurrency int) requestWithResponse {
 	return requestWithResponse{
-		request:  request,
-		response: make(chan *proto.HTTPResponse, 1),
+		request:        request,
+		response:       make(chan *proto.HTTPResponse, 1),
+		chunks:         make(chan *proto.HTTPResponseChunk, streamQueueSize),
+		region:         region,
+		maxConcurrency: maxConcurrency,
+		endpointID:     request.EndpointID,
 	}
 }
 
+type inflightRequest struct {
+	endpointID string
+	slot       int
+	pooled     bool
+	// The runtime serving the request.
+	runtime *actor.PID
+	// The request is answered with a 504 and released when the runtime did
+	// not respond by then, zero when the invocation has no timeout.
+	deadline time.Time
+}
+
+// cancelRequest cancels the request whose client went away.
+type cancelRequest struct {
+	requestID  string
+	endpointID string
+}
+
+type queuedRequest struct {
+	msg      requestWithResponse
+	deadline time.Time
+}
+
+type sweepQueues struct{}
+
+const queueSweepInterval = time.Millisecond * 100
+
+// inflightGrace is the time a runtime has past the timeout of an invocation
+// to respond, which covers its cold start. A runtime that was stopped or
+// whose member is gone never responds.
+const inflightGrace = time.Second * 30
+
+// canceledGrace is the time a runtime has to respond to an invocation that
+// was canceled.
+const canceledGrace = time.Second * 2
+
 // WasmServer is an HTTP server that will proxy and route the request to the corresponding function.
 type WasmServer struct {
 	server            *http.Server
@@ -36,27 +101,77 @@ type WasmServer struct {
 	store             storage.Store
 	metricStore       storage.MetricStore
 	cache             storage.ModCacher
+	assets            *assetCache
+	responseCache     *httpcache.LRU
+	cacheCounters     *cacheCounters
 	cluster           *cluster.Cluster
 	responses         map[string]chan *proto.HTTPResponse
+	streams           map[string]chan *proto.HTTPResponseChunk
+	pools             map[string]*runtimePool
+	inflight          map[string]inflightRequest
+	queues            map[string][]queuedRequest
+	queueDepths       map[string]int
+	altSvc            string
+	tracker           *admin.Tracker
+	sockets           map[string]*webSocketConn
+	sweeper           actor.SendRepeater
+	cacheFlusher      actor.SendRepeater
 	runtimeManagerPID *actor.PID
+	quotas            *quota.Checker
+	// Access log of the served requests, nil when it is disabled.
+	accessLog *accessLog
+	proxies   trustedProxies
+	jwks      *jwt.Cache
+	// Secret the invocations of the platform are signed with.
+	secret []byte
+	// Concurrency limits of the endpoints with one, and the endpoints with
+	// invocations in the previous concurrency sample.
+	limits  map[string]int
+	sampled map[string]bool
+	sampler actor.SendRepeater
 }
 
-// NewWasmServer return a new wasm server given a storage and a mod cache.
-func NewWasmServer(addr string, cluster *cluster.Cluster, store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher) actor.Producer {
+// NewWasmServer return a new wasm server given a storage and a mod cache. The
+// server refuses new requests once the tracker is draining.
+func NewWasmServer(addr string, cluster *cluster.Cluster, store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher, tracker *admin.Tracker) actor.Producer {
 	return func() actor.Receiver {
 		s := &WasmServer{
 			store:             store,
 			metricStore:       metricStore,
 			cache:             cache,
+			assets:            newAssetCache(store),
+			responseCache:     httpcache.NewLRU(config.Get().Ingress.ResponseCacheSize),
+			cacheCounters:     newCacheCounters(),
 			cluster:           cluster,
 			responses:         make(map[string]chan *proto.HTTPResponse),
+			streams:           make(map[string]chan *proto.HTTPResponseChunk),
+			pools:             make(map[string]*runtimePool),
+			inflight:          make(map[string]inflightRequest),
+			queues:            make(map[string][]queuedRequest),
+			queueDepths:       make(map[string]int),
+			altSvc:            config.Get().Ingress.AltSvc,
+			tracker:           tracker,
+			sockets:           make(map[string]*webSocketConn),
 			runtimeManagerPID: cluster.Engine().Registry.GetPID(KindRuntimeManager, "1"),
+			quotas:            quota.New(config.Get().Quotas, store, metricStore),
+			jwks:              jwt.NewCache(&http.Client{Timeout: jwksTimeout}),
+			secret:            async.Secret(config.Get().Async),
+			limits:            make(map[string]int),
+			sampled:           make(map[string]bool),
+		}
+		proxies, err := config.ParseTrustedProxies(config.Get().Ingress.TrustedProxies)
+		if err != nil {
+			log.Fatal(err)
 		}
-		server := &http.Server{
-			Handler: s,
-			Addr:    addr,
+		s.proxies = proxies
+		if dest := config.Get().Ingress.AccessLog; len(dest) > 0 {
+			accessLog, err := newAccessLog(dest)
+			if err != nil {
+				log.Fatal(err)
+			}
+			s.accessLog = accessLog
 		}
-		s.server = server
+		s.server = newIngressServer(addr, s, config.Get().Ingress)
 		return s
 	}
 }
@@ -66,38 +181,287 @@ func (s *WasmServer) Receive(c *actor.Context) {
 	case actor.Started:
 		s.initialize(c)
 	case actor.Stopped:
+		s.sweeper.Stop()
+		s.cacheFlusher.Stop()
+		s.sampler.Stop()
+		s.server.Close()
 	case requestWithResponse:
-		// TODO: let's say the manager is not able to respond in time for some reason
-		// I think we might need to spawn a new runtime right here.
-		pid := s.requestRuntime(c, msg.request.DeploymentID)
-		if pid == nil {
-			slog.Error("failed to request a runtime PID")
+		inflight, ok := s.acquire(msg)
+		if !ok {
+			s.enqueue(msg)
 			return
 		}
-		s.responses[msg.request.ID] = msg.response
-		msg.request.ManagerPID = s.runtimeManagerPID
-		s.cluster.Engine().SendWithSender(pid, msg.request, s.self)
+		s.dispatch(c, msg, inflight)
 	case *proto.HTTPResponse:
 		if resp, ok := s.responses[msg.RequestID]; ok {
 			resp <- msg
 			delete(s.responses, msg.RequestID)
 		}
+		delete(s.streams, msg.RequestID)
+		if inflight, ok := s.inflight[msg.RequestID]; ok {
+			s.release(inflight)
+			delete(s.inflight, msg.RequestID)
+			s.dequeue(c, inflight.endpointID)
+		}
+	case *proto.HTTPResponseChunk:
+		s.handleResponseChunk(msg)
+	case cancelRequest:
+		s.cancel(c, msg)
+	case openWebSocket:
+		s.openWebSocket(c, msg)
+	case *proto.WebSocketMessage:
+		s.handleWebSocketMessage(msg)
+	case closeWebSocket:
+		s.closeWebSocket(msg)
+	case sweepQueues:
+		s.sweepQueues(c)
+	case sampleConcurrency:
+		s.sampleConcurrency(c)
+	case flushCacheMetrics:
+		metricPID := c.Engine().Registry.GetPID(KindMetric, "1")
+		for _, metric := range s.cacheCounters.flush() {
+			c.Send(metricPID, metric)
+		}
+	}
+}
+
+func (s *WasmServer) dispatch(c *actor.Context, msg requestWithResponse, inflight inflightRequest) {
+	// TODO: let's say the manager is not able to respond in time for some reason
+	// I think we might need to spawn a new runtime right here.
+	pid := s.requestRuntime(c, msg.request.RuntimeKey, msg.region)
+	if pid == nil {
+		slog.Error("failed to request a runtime PID")
+		s.release(inflight)
+		respondUnavailable(msg, "no runtime available")
+		return
+	}
+	s.responses[msg.request.ID] = msg.response
+	s.streams[msg.request.ID] = msg.chunks
+	inflight.runtime = pid
+	if msg.request.Timeout > 0 {
+		inflight.deadline = time.Now().Add(time.Duration(msg.request.Timeout)*time.Millisecond + inflightGrace)
+	}
+	s.inflight[msg.request.ID] = inflight
+	msg.request.ManagerPID = s.runtimeManagerPID
+	s.cluster.Engine().SendWithSender(pid, msg.request, s.self)
+}
+
+// cancel drops the request from the queue of its endpoint, or cancels its
+// invocation on the runtime it was dispatched to. The runtime still
+// responds, which releases the request. When it does not within the
+// canceledGrace, the runtime is gone and the request is released by the
+// sweep.
+func (s *WasmServer) cancel(c *actor.Context, msg cancelRequest) {
+	if inflight, ok := s.inflight[msg.requestID]; ok {
+		c.Send(cancelerPID(inflight.runtime), &proto.CancelRequest{RequestID: msg.requestID})
+		deadline := time.Now().Add(canceledGrace)
+		if inflight.deadline.IsZero() || deadline.Before(inflight.deadline) {
+			inflight.deadline = deadline
+			s.inflight[msg.requestID] = inflight
+		}
+		return
+	}
+	queue := s.queues[msg.endpointID]
+	for i, queued := range queue {
+		if queued.msg.request.ID == msg.requestID {
+			s.queues[msg.endpointID] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// enqueue queues the request until one of the runtimes of the endpoint is
+// available. When the queue of the endpoint is full the request is shed.
+func (s *WasmServer) enqueue(msg requestWithResponse) {
+	// The queue follows the reloads of the config.
+	c := config.Get().Ingress
+	queue := s.queues[msg.endpointID]
+	if len(queue) >= c.QueueSize {
+		respondWithStatus(msg, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+	s.queues[msg.endpointID] = append(queue, queuedRequest{
+		msg:      msg,
+		deadline: time.Now().Add(time.Duration(c.QueueTimeout)),
+	})
+}
+
+// dequeue dispatches the queued requests of the endpoint for as long as there
+// are runtimes available.
+func (s *WasmServer) dequeue(c *actor.Context, endpointID string) {
+	s.expire(endpointID)
+	for len(s.queues[endpointID]) > 0 {
+		queued := s.queues[endpointID][0]
+		inflight, ok := s.acquire(queued.msg)
+		if !ok {
+			return
+		}
+		s.queues[endpointID] = s.queues[endpointID][1:]
+		s.dispatch(c, queued.msg, inflight)
 	}
+	delete(s.queues, endpointID)
+}
+
+// expire responds with 503 to all the queued requests of the endpoint that
+// waited longer than the queue timeout.
+func (s *WasmServer) expire(endpointID string) {
+	var (
+		now   = time.Now()
+		queue = s.queues[endpointID]
+		i     = 0
+	)
+	for _, queued := range queue {
+		if now.After(queued.deadline) {
+			respondUnavailable(queued.msg, "request timed out in queue")
+			continue
+		}
+		queue[i] = queued
+		i++
+	}
+	s.queues[endpointID] = queue[:i]
+}
+
+// expireInflight responds with 504 to the dispatched requests whose runtime
+// did not respond by their deadline, and hands their slots to the queued
+// requests of the endpoint.
+func (s *WasmServer) expireInflight(c *actor.Context) {
+	now := time.Now()
+	for requestID, inflight := range s.inflight {
+		if inflight.deadline.IsZero() || now.Before(inflight.deadline) {
+			continue
+		}
+		slog.Warn("runtime did not respond in time, releasing the request", "request_id", requestID, "runtime", inflight.runtime)
+		if resp, ok := s.responses[requestID]; ok {
+			resp <- &proto.HTTPResponse{
+				Response:   []byte("runtime did not respond in time"),
+				StatusCode: http.StatusGatewayTimeout,
+				RequestID:  requestID,
+			}
+		}
+		delete(s.responses, requestID)
+		delete(s.streams, requestID)
+		delete(s.inflight, requestID)
+		s.release(inflight)
+		s.dequeue(c, inflight.endpointID)
+	}
+}
+
+// sweepQueues expires the queued and the in-flight requests and reports the
+// queue depth of every endpoint whose depth changed since the last sweep.
+func (s *WasmServer) sweepQueues(c *actor.Context) {
+	metricPID := c.Engine().Registry.GetPID(KindMetric, "1")
+	s.expireInflight(c)
+	for endpointID := range s.queues {
+		s.expire(endpointID)
+	}
+	for endpointID, depth := range s.queueDepths {
+		if _, ok := s.queues[endpointID]; !ok && depth > 0 {
+			s.queues[endpointID] = nil
+		}
+	}
+	for endpointID, queue := range s.queues {
+		if s.queueDepths[endpointID] == len(queue) {
+			continue
+		}
+		s.queueDepths[endpointID] = len(queue)
+		c.Send(metricPID, types.QueueMetric{
+			EndpointID: uuid.MustParse(endpointID),
+			Depth:      len(queue),
+		})
+		if len(queue) == 0 {
+			delete(s.queues, endpointID)
+			delete(s.queueDepths, endpointID)
+		}
+	}
+}
+
+func respondWithStatus(msg requestWithResponse, code int, text string) {
+	msg.response <- &proto.HTTPResponse{
+		Response:   []byte(text),
+		StatusCode: int32(code),
+		RequestID:  msg.request.ID,
+	}
+}
+
+// acquire reserves a runtime of the endpoint's pool for the given request
+// when the endpoint has a concurrency limit. False is returned when the
+// endpoint reached its limit and the request needs to be shed.
+func (s *WasmServer) acquire(msg requestWithResponse) (inflightRequest, bool) {
+	inflight := inflightRequest{endpointID: msg.endpointID}
+	if msg.maxConcurrency <= 0 {
+		delete(s.limits, msg.endpointID)
+		return inflight, true
+	}
+	s.limits[msg.endpointID] = msg.maxConcurrency
+	pool, ok := s.pools[msg.endpointID]
+	if !ok {
+		pool = newRuntimePool(msg.maxConcurrency)
+		s.pools[msg.endpointID] = pool
+	}
+	pool.resize(msg.maxConcurrency)
+	slot, ok := pool.acquire()
+	if !ok {
+		return inflight, false
+	}
+	inflight.slot = slot
+	inflight.pooled = true
+	// Session affinity already picked the runtime for this request.
+	if msg.request.RuntimeKey == msg.request.DeploymentID {
+		msg.request.RuntimeKey = poolRuntimeKey(msg.request.DeploymentID, slot)
+	}
+	return inflight, true
+}
+
+func (s *WasmServer) release(inflight inflightRequest) {
+	if !inflight.pooled {
+		return
+	}
+	if pool, ok := s.pools[inflight.endpointID]; ok {
+		pool.release(inflight.slot)
+	}
+}
+
+// newIngressServer returns the HTTP server of the ingress. The timeouts
+// protect the ingress from slow and idle clients.
+func newIngressServer(addr string, handler http.Handler, c config.Ingress) *http.Server {
+	server := &http.Server{
+		Handler:           handler,
+		Addr:              addr,
+		ReadHeaderTimeout: time.Duration(c.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(c.ReadTimeout),
+		WriteTimeout:      time.Duration(c.WriteTimeout),
+		IdleTimeout:       time.Duration(c.IdleTimeout),
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+	if c.H2C {
+		// Plaintext HTTP/2, gRPC clients connect with prior knowledge.
+		server.Handler = h2c.NewHandler(handler, &http2.Server{
+			MaxConcurrentStreams: uint32(c.MaxConcurrentStreams),
+			IdleTimeout:          time.Duration(c.IdleTimeout),
+		})
+	}
+	return server
 }
 
 func (s *WasmServer) initialize(c *actor.Context) {
 	s.self = c.PID()
+	s.sweeper = c.SendRepeat(c.PID(), sweepQueues{}, queueSweepInterval)
+	s.cacheFlusher = c.SendRepeat(c.PID(), flushCacheMetrics{}, cacheMetricInterval)
+	s.sampler = c.SendRepeat(c.PID(), sampleConcurrency{}, concurrencyInterval)
 	go func() {
-		log.Fatal(s.server.ListenAndServe())
+		if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
 	}()
 }
 
 // NOTE: There could be a case where we do not get a response in time, hence
 // the PID will be nil. This case is handled where we should spawn the runtime
 // ourselves.
-func (s *WasmServer) requestRuntime(c *actor.Context, key string) *actor.PID {
+func (s *WasmServer) requestRuntime(c *actor.Context, key, region string) *actor.PID {
 	res, err := c.Request(s.runtimeManagerPID, requestRuntime{
-		key: key,
+		key:    key,
+		region: region,
 	}, time.Millisecond*5).Result()
 	if err != nil {
 		slog.Warn("runtime manager response failed", "err", err)
@@ -110,8 +474,64 @@ func (s *WasmServer) requestRuntime(c *actor.Context, key string) *actor.PID {
 	return pid
 }
 
-// TODO(anthdm): Handle the favicon.ico
 func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		requestID = uuid.NewString()
+		req       *proto.HTTPRequest
+	)
+	// The id of the request identifies the invocation in the metrics, the
+	// logs and the errors, the guest receives it as a header and in its
+	// environment.
+	r.Header.Set(shared.RequestIDHeader, requestID)
+	w.Header().Set(shared.RequestIDHeader, requestID)
+	r.Header.Del(shared.JWTSubjectHeader)
+	r.Header.Del(shared.JWTClaimsHeader)
+	// The asynchronous invocations, the workflows and the schedules of the
+	// platform carry no token or signature of their own.
+	internal := s.isInternal(r)
+	clientIP := s.proxies.clientIP(r)
+	s.proxies.forwardClientIP(r, clientIP)
+	if s.accessLog != nil {
+		rec := &accessRecorder{ResponseWriter: w}
+		w = rec
+		defer func(start time.Time) {
+			entry := accessLogEntry{
+				Time:       start,
+				RequestID:  requestID,
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Protocol:   r.Proto,
+				UserAgent:  r.UserAgent(),
+				Status:     rec.status,
+				Latency:    float64(time.Since(start).Microseconds()) / 1000,
+				BytesOut:   rec.bytes,
+			}
+			if clientIP.IsValid() {
+				entry.ClientIP = clientIP.String()
+			}
+			if req != nil {
+				entry.EndpointID = req.EndpointID
+				entry.DeploymentID = req.DeploymentID
+				entry.Preview = req.Preview
+				entry.BytesIn = len(req.Body)
+			}
+			s.accessLog.write(entry)
+		}(time.Now())
+	}
+	if s.tracker.Draining() {
+		// Let the load balancer move the client to another ingress.
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("ingress is draining"))
+		return
+	}
+	s.tracker.Begin()
+	defer s.tracker.End()
+	if len(s.altSvc) > 0 {
+		w.Header().Set("Alt-Svc", s.altSvc)
+	}
+	routeGRPC(r)
 	path := strings.TrimPrefix(r.URL.Path, "/")
 	path = strings.TrimSuffix(path, "/")
 	pathParts := strings.Split(path, "/")
@@ -125,18 +545,28 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	requestID := uuid.NewString()
-	r.Header.Set("x-request-id", requestID)
+	var (
+		region         = s.cluster.Region()
+		maxConcurrency int
+		poolEndpointID string
+		cached         *cacheRequest
+		compression    *types.Compression
+		fallback       *url.URL
+		webSocket      *types.WebSocket
+		upgrade        = isWebSocketUpgrade(r)
+	)
 	req, err := shared.MakeProtoRequest(requestID, r)
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
 		return
 	}
-
-	if err != nil {
-		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
-		return
+	if clientIP.IsValid() {
+		req.ClientIP = clientIP.String()
 	}
+	// The read timeout bounds reading the request. Once it is read, the
+	// connection is only watched for the client going away.
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
 	if pathParts[0] == "live" {
 		endpointID, err := uuid.Parse(pathParts[1])
 		if err != nil {
@@ -148,16 +578,109 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
 			return
 		}
-		if !endpoint.HasActiveDeploy() {
+		if endpoint, err = inheritEnvironment(s.store, endpoint); err != nil {
+			writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+			return
+		}
+		writeDeprecationHeaders(w.Header(), endpoint.Deprecation)
+		if serveMaintenance(w, endpoint.Maintenance) {
+			return
+		}
+		if serveCORS(w, r, endpoint.CORS) {
+			return
+		}
+		if !internal && !verifyRequest(w, r, endpoint, req) {
+			return
+		}
+		if !internal && !s.authorize(w, r, endpoint, req) {
+			return
+		}
+		if serveStatic(w, r, endpoint, req.URL) {
+			return
+		}
+		// Composite endpoints route path prefixes to the LIVE deployment of
+		// other endpoints of their project. The request is still accounted
+		// to the composite endpoint, split per route.
+		target := endpoint
+		if route := endpoint.Route(req.URL); route != nil {
+			target, err = s.store.GetEndpoint(route.EndpointID)
+			if err != nil {
+				writeResponse(w, http.StatusNotFound, []byte(err.Error()))
+				return
+			}
+			// Routes are validated when they are set, the owner of the
+			// target can still change afterwards.
+			if target.Owner() != endpoint.Owner() {
+				writeResponse(w, http.StatusNotFound, []byte("route target is not an endpoint of the project"))
+				return
+			}
+			if target, err = inheritEnvironment(s.store, target); err != nil {
+				writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+				return
+			}
+			if serveMaintenance(w, target.Maintenance) {
+				return
+			}
+			// The target still verifies and authorizes the requests it
+			// serves, whichever endpoint routed them.
+			if !internal && !verifyRequest(w, r, target, req) {
+				return
+			}
+			if !internal && !s.authorize(w, r, target, req) {
+				return
+			}
+			req.Route = route.Prefix
+		}
+		if !target.HasActiveDeploy() {
 			writeResponse(w, http.StatusNotFound, []byte("endpoint does not have any published deploy"))
 			return
 		}
-		req.Runtime = endpoint.Runtime
+		// A running rollout serves part of the traffic with its new
+		// deployment.
+		deployID := target.Rollout.Pick(target.ActiveDeploymentID, rand.Intn(100))
+		if s.serveAssets(w, r, deployID, req.URL) {
+			return
+		}
+		if upgrade && target.WebSocketEnabled() {
+			webSocket = target.WebSocket
+		}
+		if target.CacheEnabled() && webSocket == nil {
+			var served bool
+			if cached, served = s.serveCached(w, r, target); served {
+				return
+			}
+		}
+		// The invocations are accounted to the project of the endpoint
+		// that was called.
+		if !s.checkInvocations(w, endpoint) {
+			return
+		}
+		// Replays are not captured again, the bodies that are too large to
+		// be replayed are not captured at all.
+		if endpoint.Capture.Sample(rand.Float64()) && len(req.Body) <= types.MaxCapturedBody && len(r.Header.Get(shared.ReplayHeader)) == 0 {
+			// The credentials of the endpoint and of the target of its route
+			// are never recorded.
+			redact := append(endpoint.CredentialHeaders(), target.CredentialHeaders()...)
+			capture := types.NewCapturedRequest(endpoint.Capture, redact, uuid.MustParse(requestID), endpoint.ID, deployID, req.Method, req.URL, r.Header, req.Body)
+			metricPID := s.cluster.Engine().Registry.GetPID(KindMetric, "1")
+			s.cluster.Engine().Send(metricPID, capture)
+		}
+		req.Runtime = target.Runtime
 		req.EndpointID = endpointID.String()
 		// When serving LIVE endpoints we use the active deployment id.
-		req.DeploymentID = endpoint.ActiveDeploymentID.String()
-		req.Env = endpoint.Environment
+		req.DeploymentID = deployID.String()
+		req.Env = target.Environment
 		req.Preview = false
+		req.RuntimeKey = runtimeKey(req.DeploymentID, target.SessionAffinity, r)
+		region = target.PreferredRegion(region)
+		maxConcurrency = target.MaxConcurrency
+		poolEndpointID = target.ID.String()
+		compression = target.Compression
+		fallback = endpoint.FallbackURL()
+		limits := target.Limits.Bound(shared.GlobalLimits())
+		req.Timeout = int64(limits.Timeout)
+		req.MaxResponseSize = int64(limits.MaxResponseSize)
+		req.CacheQuota = int64(limits.CacheQuota)
 	}
 	if pathParts[0] == "preview" {
 		deployID, err := uuid.Parse(pathParts[1])
@@ -167,12 +690,37 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		deploy, err := s.store.GetDeployment(deployID)
 		if err != nil {
-			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
+			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
 			return
 		}
 		endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
 		if err != nil {
-			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
+			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		if endpoint, err = inheritEnvironment(s.store, endpoint); err != nil {
+			writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+			return
+		}
+		// The maintenance of the endpoint only takes its LIVE requests
+		// offline, the deployments are still previewed so a fix can be
+		// checked before the endpoint is enabled again.
+		if serveCORS(w, r, endpoint.CORS) {
+			return
+		}
+		if !verifyRequest(w, r, endpoint, req) {
+			return
+		}
+		if !s.authorize(w, r, endpoint, req) {
+			return
+		}
+		if serveStatic(w, r, endpoint, req.URL) {
+			return
+		}
+		if s.serveAssets(w, r, deploy.ID, req.URL) {
+			return
+		}
+		if !s.checkInvocations(w, endpoint) {
 			return
 		}
 		req.Runtime = endpoint.Runtime
@@ -182,18 +730,186 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		req.DeploymentID = deploy.ID.String()
 		req.Env = endpoint.Environment
 		req.Preview = true
+		req.RuntimeKey = req.DeploymentID
+		region = endpoint.PreferredRegion(region)
+		maxConcurrency = endpoint.MaxConcurrency
+		compression = endpoint.Compression
+		limits := endpoint.Limits.Bound(shared.GlobalLimits())
+		req.Timeout = int64(limits.Timeout)
+		req.MaxResponseSize = int64(limits.MaxResponseSize)
+		req.CacheQuota = int64(limits.CacheQuota)
+		if upgrade && endpoint.WebSocketEnabled() {
+			webSocket = endpoint.WebSocket
+		}
+	}
+	if webSocket != nil {
+		s.serveWebSocket(w, r, req, region, webSocket)
+		return
 	}
 
-	reqres := newRequestWithResponse(req)
+	reqres := newRequestWithResponse(req, region, maxConcurrency)
+	if len(poolEndpointID) > 0 {
+		reqres.endpointID = poolEndpointID
+	}
+	if fallback != nil {
+		reqres.unavailable = make(chan struct{}, 1)
+	}
 	s.cluster.Engine().Send(s.self, reqres)
 
-	resp := <-reqres.response
+	resp, streamed, err := awaitResponse(r.Context(), w, reqres, responseTimeout(req))
+	if err != nil {
+		if errors.Is(err, errNoResponse) {
+			slog.Warn("runtime did not respond in time, canceling the request", "request_id", requestID)
+			if !streamed {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				w.Write([]byte(err.Error()))
+			}
+		} else {
+			slog.Info("client went away, canceling the request", "request_id", requestID)
+		}
+		s.cluster.Engine().Send(s.self, cancelRequest{
+			requestID:  requestID,
+			endpointID: reqres.endpointID,
+		})
+		return
+	}
+	if resp == nil {
+		serveFallback(w, r, fallback, req)
+		return
+	}
+	if streamed {
+		return
+	}
+
+	header := responseHeader(resp.Header)
+	if cached != nil {
+		s.cacheResponse(r, cached, resp, header)
+	}
+	copyHeader(w.Header(), header)
+	declareTrailer(w.Header(), resp.Trailer)
+	writeBody(w, r, compression, int(resp.StatusCode), resp.Response)
+	writeTrailer(w.Header(), resp.Trailer)
+}
+
+// checkInvocations answers the request with a 429 and emits a
+// quota.exceeded event when the project of the endpoint used up its
+// invocations of the month. It is checked before the LIVE, the PREVIEW and
+// the WebSocket invocations alike.
+func (s *WasmServer) checkInvocations(w http.ResponseWriter, endpoint *types.Endpoint) bool {
+	err := s.quotas.CheckInvocations(endpoint.Owner())
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, quota.ErrExceeded) {
+		slog.Warn("failed to check the invocation quota", "err", err, "endpoint", endpoint.ID)
+		return true
+	}
+	metricPID := s.cluster.Engine().Registry.GetPID(KindMetric, "1")
+	s.cluster.Engine().Send(metricPID, *types.NewEvent(types.EventQuotaExceeded, endpoint.ID, uuid.Nil, "", err.Error()))
+	writeResponse(w, http.StatusTooManyRequests, []byte(err.Error()))
+	return false
+}
+
+// writeDeprecationHeaders sets the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers when the endpoint has a deprecation notice.
+func writeDeprecationHeaders(h http.Header, d *types.Deprecation) {
+	if d.IsZero() {
+		return
+	}
+	if !d.Date.IsZero() {
+		h.Set("Deprecation", fmt.Sprintf("@%d", d.Date.Unix()))
+		if len(d.Link) > 0 {
+			h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+		}
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		if len(d.Link) > 0 {
+			h.Add("Link", fmt.Sprintf("<%s>; rel=\"sunset\"", d.Link))
+		}
+	}
+}
+
+// serveMaintenance answers the request with a 503 and the maintenance
+// message while the endpoint is disabled.
+func serveMaintenance(w http.ResponseWriter, m *types.Maintenance) bool {
+	if !m.Active() {
+		return false
+	}
+	if m.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(m.Text()))
+	return true
+}
+
+// serveStatic answers the request with the static response configured for
+// the path, if any, without invoking the deployment. Requests that do not
+// accept the content type of the static response fall through to the
+// deployment.
+func serveStatic(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint, path string) bool {
+	resp := endpoint.StaticResponse(path)
+	if resp == nil {
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if !shared.Accepts(r.Header.Get("Accept"), resp.ContentType) {
+		return false
+	}
+	w.Header().Set("Content-Type", resp.ContentType)
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodGet {
+		w.Write(resp.Body)
+	}
+	return true
+}
+
+// serveAssets answers the request with the matching asset of the asset
+// bundle of the deployment, if any, without invoking the deployment.
+func (s *WasmServer) serveAssets(w http.ResponseWriter, r *http.Request, deploymentID uuid.UUID, path string) bool {
+	bundle, err := s.assets.get(deploymentID)
+	if err != nil {
+		slog.Warn("failed to load asset bundle", "deployment", deploymentID, "err", err)
+		return false
+	}
+	if bundle == nil {
+		return false
+	}
+	return bundle.Serve(w, r, path)
+}
+
+// runtimeKey returns the key of the runtime that needs to handle the request.
+// Requests of the same session are consistently routed to the same runtime
+// when the endpoint has session affinity configured.
+func runtimeKey(deploymentID string, affinity *types.SessionAffinity, r *http.Request) string {
+	if affinity == nil {
+		return deploymentID
+	}
+	session := r.Header.Get(affinity.Header)
+	if len(session) == 0 && len(affinity.Cookie) > 0 {
+		if cookie, err := r.Cookie(affinity.Cookie); err == nil {
+			session = cookie.Value
+		}
+	}
+	if len(session) == 0 {
+		return deploymentID
+	}
+	slots := affinity.Slots
+	if slots == 0 {
+		slots = types.DefaultAffinitySlots
+	}
+	return affinityRuntimeKey(deploymentID, shared.SessionSlot(session, slots))
+}
 
-	w.WriteHeader(int(resp.StatusCode))
-	w.Write(resp.Response)
+func affinityRuntimeKey(deploymentID string, slot int) string {
+	return fmt.Sprintf("%s/%d", deploymentID, slot)
 }
 
 func writeResponse(w http.ResponseWriter, code int, b []byte) {
-	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(code)
 	w.Write(b)
 }
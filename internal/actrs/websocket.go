@@ -0,0 +1,363 @@
+package actrs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/spidermonkey"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+
+	prot "google.golang.org/protobuf/proto"
+)
+
+const (
+	// Amount of messages buffered for each direction of a connection. The
+	// connection is closed when its peer does not keep up.
+	webSocketQueueSize = 64
+	// Time the guest gets to return after the client closed the connection
+	// before it is interrupted.
+	webSocketCloseGrace = time.Second * 5
+	// Maximum size in bytes of the logs of a connection.
+	webSocketMaxLogSize = 1 << 20
+)
+
+var errMessageTooLarge = errors.New("websocket message exceeds the maximum size")
+
+// isWebSocketUpgrade returns true if the client requests to upgrade the
+// connection to a WebSocket connection.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, token := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// webSocketRuntimeKey returns the key of the runtime serving the connection.
+// Every connection gets its own runtime that lives as long as the connection.
+func webSocketRuntimeKey(deploymentID, connectionID string) string {
+	return fmt.Sprintf("%s/ws/%s", deploymentID, connectionID)
+}
+
+// webSocketConn is a client connection held by the ingress.
+type webSocketConn struct {
+	id string
+	ws *websocket.Conn
+	// Receives the PID of the runtime serving the connection, nil when no
+	// runtime is available.
+	runtime  chan *actor.PID
+	outbound chan []byte
+	once     sync.Once
+}
+
+func newWebSocketConn(id string, ws *websocket.Conn) *webSocketConn {
+	return &webSocketConn{
+		id:       id,
+		ws:       ws,
+		runtime:  make(chan *actor.PID, 1),
+		outbound: make(chan []byte, webSocketQueueSize),
+	}
+}
+
+// send queues a message of the guest. False is returned when the client
+// does not keep up.
+func (c *webSocketConn) send(b []byte) bool {
+	select {
+	case c.outbound <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection once the queued messages are written.
+func (c *webSocketConn) close() {
+	c.once.Do(func() { close(c.outbound) })
+}
+
+// writeLoop writes the messages of the guest to the client. Valid UTF-8
+// messages are written as text messages, other messages as binary messages.
+func (c *webSocketConn) writeLoop() {
+	defer c.ws.Close()
+	for b := range c.outbound {
+		var err error
+		if utf8.Valid(b) {
+			err = websocket.Message.Send(c.ws, string(b))
+		} else {
+			err = websocket.Message.Send(c.ws, b)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type openWebSocket struct {
+	conn    *webSocketConn
+	request *proto.HTTPRequest
+	region  string
+}
+
+type closeWebSocket struct {
+	id string
+}
+
+// serveWebSocket upgrades the request to a WebSocket connection and relays
+// its messages to and from the runtime serving the connection.
+func (s *WasmServer) serveWebSocket(w http.ResponseWriter, r *http.Request, req *proto.HTTPRequest, region string, config *types.WebSocket) {
+	req.RuntimeKey = webSocketRuntimeKey(req.DeploymentID, req.ID)
+	maxMessageSize := config.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = types.DefaultWebSocketMaxMessageSize
+	}
+	// The connection outlives the timeouts of the request.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	engine := s.cluster.Engine()
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		ws.MaxPayloadBytes = maxMessageSize
+		conn := newWebSocketConn(req.ID, ws)
+		engine.Send(s.self, openWebSocket{
+			conn:    conn,
+			request: req,
+			region:  region,
+		})
+		pid := <-conn.runtime
+		if pid == nil {
+			return
+		}
+		go conn.writeLoop()
+		for {
+			var b []byte
+			if err := websocket.Message.Receive(ws, &b); err != nil {
+				break
+			}
+			engine.Send(pid, &proto.WebSocketMessage{ConnectionID: conn.id, Data: b})
+		}
+		engine.Send(pid, &proto.WebSocketMessage{ConnectionID: conn.id, Close: true})
+		engine.Send(s.self, closeWebSocket{id: conn.id})
+	}}
+	server.ServeHTTP(w, r)
+}
+
+func (s *WasmServer) openWebSocket(c *actor.Context, msg openWebSocket) {
+	pid := s.requestRuntime(c, msg.request.RuntimeKey, msg.region)
+	if pid == nil {
+		slog.Error("failed to request a runtime PID for websocket connection")
+		msg.conn.runtime <- nil
+		return
+	}
+	s.sockets[msg.conn.id] = msg.conn
+	msg.request.ManagerPID = s.runtimeManagerPID
+	s.cluster.Engine().SendWithSender(pid, &proto.WebSocketOpen{
+		Request:        msg.request,
+		MaxMessageSize: msg.request.MaxResponseSize,
+	}, s.self)
+	msg.conn.runtime <- pid
+}
+
+// handleWebSocketMessage relays a message of the guest to its client.
+func (s *WasmServer) handleWebSocketMessage(msg *proto.WebSocketMessage) {
+	conn, ok := s.sockets[msg.ConnectionID]
+	if !ok {
+		return
+	}
+	if msg.Close {
+		conn.close()
+		delete(s.sockets, msg.ConnectionID)
+		return
+	}
+	if !conn.send(msg.Data) {
+		slog.Warn("closing websocket connection of slow client", "connection", msg.ConnectionID)
+		conn.close()
+		delete(s.sockets, msg.ConnectionID)
+	}
+}
+
+func (s *WasmServer) closeWebSocket(msg closeWebSocket) {
+	if conn, ok := s.sockets[msg.id]; ok {
+		conn.close()
+		delete(s.sockets, msg.id)
+	}
+}
+
+type webSocketDone struct{}
+
+// webSocketSession is the socket of the guest serving a connection on a
+// runtime.
+type webSocketSession struct {
+	id      string
+	engine  *actor.Engine
+	server  *actor.PID
+	inbound chan []byte
+	closed  chan struct{}
+	once    sync.Once
+	cancel  context.CancelFunc
+	preview bool
+	// Endpoint of the connection and the time it was opened, reported with
+	// the logs of the connection.
+	endpointID uuid.UUID
+	openedAT   time.Time
+	// Maximum size in bytes of the messages of the guest, 0 is unlimited.
+	maxMessageSize int
+}
+
+func (s *webSocketSession) Read(ctx context.Context) ([]byte, error) {
+	// Messages that arrived before the connection was closed are still
+	// delivered.
+	select {
+	case msg := <-s.inbound:
+		return msg, nil
+	default:
+	}
+	select {
+	case msg := <-s.inbound:
+		return msg, nil
+	case <-s.closed:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *webSocketSession) Write(ctx context.Context, b []byte) error {
+	select {
+	case <-s.closed:
+		return io.EOF
+	default:
+	}
+	if s.maxMessageSize > 0 && len(b) > s.maxMessageSize {
+		return errMessageTooLarge
+	}
+	s.engine.Send(s.server, &proto.WebSocketMessage{ConnectionID: s.id, Data: b})
+	return nil
+}
+
+// deliver hands a message of the client to the guest.
+func (s *webSocketSession) deliver(msg *proto.WebSocketMessage) {
+	if msg.Close {
+		s.close()
+		return
+	}
+	select {
+	case s.inbound <- msg.Data:
+	default:
+		slog.Warn("closing websocket connection of slow guest", "connection", s.id)
+		s.engine.Send(s.server, &proto.WebSocketMessage{ConnectionID: s.id, Close: true})
+		s.close()
+	}
+}
+
+// close closes the connection for the guest, the guest is interrupted if it
+// did not return within the grace period.
+func (s *webSocketSession) close() {
+	s.once.Do(func() {
+		close(s.closed)
+		time.AfterFunc(webSocketCloseGrace, s.cancel)
+	})
+}
+
+// openWebSocket invokes the guest for the connection. The invocation lasts
+// as long as the connection, the runtime stops when the guest returns.
+func (r *Runtime) openWebSocket(c *actor.Context, msg *proto.WebSocketOpen, cold bool) {
+	req := msg.Request
+	if r.runtime == nil {
+		slog.Warn("websocket runtime failed to initialize", "deployment", req.DeploymentID)
+		c.Send(c.Sender(), &proto.WebSocketMessage{ConnectionID: req.ID, Close: true})
+		c.Engine().Poison(c.PID())
+		return
+	}
+	b, err := prot.Marshal(req)
+	if err != nil {
+		slog.Warn("failed to marshal websocket upgrade request", "err", err)
+		c.Send(c.Sender(), &proto.WebSocketMessage{ConnectionID: req.ID, Close: true})
+		c.Engine().Poison(c.PID())
+		return
+	}
+	args := []string{}
+	if req.Runtime == "js" {
+		script, err := spidermonkey.Script(r.script, req)
+		if err != nil {
+			slog.Warn("failed to build js script", "err", err)
+			c.Send(c.Sender(), &proto.WebSocketMessage{ConnectionID: req.ID, Close: true})
+			c.Engine().Poison(c.PID())
+			return
+		}
+		args = []string{"", "-e", script}
+	}
+
+	endpointID, _ := uuid.Parse(req.EndpointID)
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &webSocketSession{
+		id:             req.ID,
+		engine:         c.Engine(),
+		server:         c.Sender(),
+		inbound:        make(chan []byte, webSocketQueueSize),
+		closed:         make(chan struct{}),
+		cancel:         cancel,
+		preview:        req.Preview,
+		endpointID:     endpointID,
+		openedAT:       time.Now(),
+		maxMessageSize: int(msg.MaxMessageSize),
+	}
+	r.socket = session
+	r.stdout.reset(webSocketMaxLogSize)
+	r.tracker.Begin()
+	self := c.PID()
+	go func() {
+		defer r.tracker.End()
+		defer cancel()
+		ctx := runtime.WithSocket(ctx, session)
+		ctx = runtime.WithInvocation(ctx, r.invocation(req, cold))
+		if r.memo != nil {
+			ctx = runtime.WithCache(ctx, r.cacheScope(req))
+		}
+		if r.invoker != nil {
+			ctx = runtime.WithInvoker(ctx, newGuestInvoker(r.store, r.invoker, req, config.Get().Async.MaxDepth))
+		}
+		if err := r.runtime.InvokeContext(ctx, bytes.NewReader(b), req.Env, args...); err != nil {
+			slog.Warn("websocket invoke error", "connection", session.id, "err", err)
+		}
+		session.engine.Send(session.server, &proto.WebSocketMessage{ConnectionID: session.id, Close: true})
+		session.engine.Send(self, webSocketDone{})
+	}()
+}
+
+// closeWebSocket ships the logs of the connection once the guest returned.
+func (r *Runtime) closeWebSocket(c *actor.Context) {
+	if r.socket == nil {
+		return
+	}
+	if !r.socket.preview && r.stdout.Len() > 0 {
+		runtimeLogPID := c.Engine().Registry.GetPID(KindRuntimeLog, "1")
+		c.Send(runtimeLogPID, types.RuntimeLogEvent{
+			EndpointID:   r.socket.endpointID,
+			DeploymentID: r.deploymentID,
+			RequestID:    r.socket.id,
+			Data:         bytes.Clone(r.stdout.Bytes()),
+			CreatedAT:    r.socket.openedAT,
+		})
+	}
+	r.stdout.Reset()
+	r.socket = nil
+}
@@ -0,0 +1,94 @@
+package actrs
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	require.False(t, isWebSocketUpgrade(r))
+	r.Header.Set("Upgrade", "WebSocket")
+	require.False(t, isWebSocketUpgrade(r))
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	require.True(t, isWebSocketUpgrade(r))
+}
+
+func TestRuntimeWebSocket(t *testing.T) {
+	blob, err := os.ReadFile("../_testdata/websocket.wasm")
+	if os.IsNotExist(err) {
+		t.Skip("websocket guest is not compiled")
+	}
+	require.Nil(t, err)
+
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("websocket", "go", map[string]string{})
+		deploy   = types.NewDeployment(endpoint, blob)
+		tracker  = admin.NewTracker()
+		messages = make(chan *proto.WebSocketMessage, 8)
+	)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.Nil(t, store.CreateDeployment(deploy))
+
+	e, err := actor.NewEngine(nil)
+	require.Nil(t, err)
+	// The ingress side of the connection.
+	server := e.SpawnFunc(func(c *actor.Context) {
+		if msg, ok := c.Message().(*proto.WebSocketMessage); ok {
+			messages <- msg
+		}
+	}, KindWasmServer)
+	pid := e.Spawn(NewRuntime(store, runtime.NewCompiler(storage.NewDefaultModCache()), tracker, nil, ""), KindRuntime)
+
+	id := uuid.NewString()
+	e.SendWithSender(pid, &proto.WebSocketOpen{
+		Request: &proto.HTTPRequest{
+			ID:           id,
+			Method:       "GET",
+			URL:          "/chat",
+			EndpointID:   endpoint.ID.String(),
+			DeploymentID: deploy.ID.String(),
+			Runtime:      "go",
+			Preview:      true,
+		},
+	}, server)
+	e.Send(pid, &proto.WebSocketMessage{ConnectionID: id, Data: []byte("hello")})
+
+	// The connection keeps the runtime alive past its keep alive.
+	time.Sleep(runtimeKeepAlive * 2)
+	e.Send(pid, &proto.WebSocketMessage{ConnectionID: id, Data: []byte("world")})
+	for _, want := range []string{"/chat: hello", "/chat: world"} {
+		select {
+		case msg := <-messages:
+			require.Equal(t, id, msg.ConnectionID)
+			require.Equal(t, want, string(msg.Data))
+		case <-time.After(time.Second * 10):
+			t.Fatalf("expected message %q", want)
+		}
+	}
+	require.Equal(t, int64(1), tracker.Inflight())
+
+	// The guest returns once the client closed the connection.
+	e.Send(pid, &proto.WebSocketMessage{ConnectionID: id, Close: true})
+	select {
+	case msg := <-messages:
+		require.True(t, msg.Close)
+	case <-time.After(time.Second * 10):
+		t.Fatal("expected the connection to be closed")
+	}
+	require.Eventually(t, func() bool {
+		return tracker.Inflight() == 0
+	}, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,74 @@
+package actrs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+// workflowStep is the workflow of the step a guest is invoked for. The state
+// and the wake up are written to the store right away, so they survive the
+// member and are seen by the runner once the step is done.
+type workflowStep struct {
+	store storage.Store
+	id    uuid.UUID
+}
+
+// stepWorkflow returns the workflow the request is a step of, false when it
+// is not one. The ingress only sets the workflow header for the steps signed
+// by the runner, the request still has to belong to the endpoint of the
+// workflow and the workflow has to be running.
+func stepWorkflow(store storage.Store, msg *proto.HTTPRequest) (*workflowStep, bool) {
+	fields, ok := msg.Header[shared.WorkflowIDHeader]
+	if !ok || len(fields.Fields) == 0 || msg.Preview {
+		return nil, false
+	}
+	id, err := uuid.Parse(fields.Fields[0])
+	if err != nil {
+		return nil, false
+	}
+	workflow, err := store.GetWorkflow(id)
+	if err != nil || workflow.EndpointID.String() != msg.EndpointID || workflow.Status != types.WorkflowRunning {
+		return nil, false
+	}
+	return &workflowStep{store: store, id: id}, true
+}
+
+func (w *workflowStep) State() ([]byte, error) {
+	workflow, err := w.store.GetWorkflow(w.id)
+	if err != nil {
+		return nil, err
+	}
+	return workflow.State, nil
+}
+
+func (w *workflowStep) SetState(state []byte) error {
+	return w.update(func(workflow *types.Workflow) {
+		workflow.State = append([]byte(nil), state...)
+	})
+}
+
+func (w *workflowStep) Sleep(d time.Duration) error {
+	return w.update(func(workflow *types.Workflow) {
+		wake := time.Now().Add(d)
+		workflow.WakeAT = &wake
+	})
+}
+
+func (w *workflowStep) update(fn func(*types.Workflow)) error {
+	workflow, err := w.store.GetWorkflow(w.id)
+	if err != nil {
+		return err
+	}
+	if workflow.Status != types.WorkflowRunning {
+		return fmt.Errorf("workflow (%s) is %s", w.id, workflow.Status)
+	}
+	fn(workflow)
+	workflow.UpdatedAT = time.Now()
+	return w.store.UpdateWorkflow(workflow)
+}
@@ -0,0 +1,247 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/internal/version"
+	"github.com/go-chi/chi/v5"
+)
+
+// Tracker tracks the in-flight work of a cluster member, whether the member
+// is draining and whether its caches are warm. It is shared by the admin
+// server and the actors doing the work.
+type Tracker struct {
+	draining atomic.Bool
+	warm     atomic.Bool
+	inflight atomic.Int64
+	// Concurrency of the endpoints served by the ingress of the member.
+	endpoints atomic.Pointer[map[string]EndpointConcurrency]
+	// Compilations of the cold starts of the runtimes of the member.
+	compiles     atomic.Int64
+	coalesced    atomic.Int64
+	compileNanos atomic.Int64
+}
+
+// CompileStats holds the compilations of the cold starts of the runtimes of
+// a member.
+type CompileStats struct {
+	// Cold starts that compiled their deployment or found it compiled.
+	Compiles int64
+	// Cold starts that waited on the compilation of another cold start of
+	// the same deployment.
+	Coalesced int64
+	// Time the cold starts spent on the compilations.
+	Duration time.Duration
+}
+
+// EndpointConcurrency holds the concurrent invocations of an endpoint at the
+// ingress of the member.
+type EndpointConcurrency struct {
+	Inflight int
+	Queued   int
+	// Concurrency limit of the endpoint, 0 is unlimited.
+	Limit int
+}
+
+// NewTracker returns a new tracker of a member that is not draining.
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Begin registers the start of a unit of work, like a request.
+func (t *Tracker) Begin() {
+	t.inflight.Add(1)
+}
+
+// End registers the end of a unit of work started with Begin.
+func (t *Tracker) End() {
+	t.inflight.Add(-1)
+}
+
+// Inflight returns the amount of units of work in progress.
+func (t *Tracker) Inflight() int64 {
+	return t.inflight.Load()
+}
+
+// SetConcurrency replaces the concurrency of the endpoints served by the
+// ingress of the member, keyed by endpoint id.
+func (t *Tracker) SetConcurrency(endpoints map[string]EndpointConcurrency) {
+	t.endpoints.Store(&endpoints)
+}
+
+// Concurrency returns the concurrency of the endpoints served by the ingress
+// of the member, nil on members without an ingress.
+func (t *Tracker) Concurrency() map[string]EndpointConcurrency {
+	if endpoints := t.endpoints.Load(); endpoints != nil {
+		return *endpoints
+	}
+	return nil
+}
+
+// ObserveCompile records the compilation of a cold start.
+func (t *Tracker) ObserveCompile(metric types.CompileMetric) {
+	t.compiles.Add(1)
+	if metric.Coalesced {
+		t.coalesced.Add(1)
+	}
+	t.compileNanos.Add(int64(metric.Duration))
+}
+
+// Compiles returns the compilations of the cold starts so far.
+func (t *Tracker) Compiles() CompileStats {
+	return CompileStats{
+		Compiles:  t.compiles.Load(),
+		Coalesced: t.coalesced.Load(),
+		Duration:  time.Duration(t.compileNanos.Load()),
+	}
+}
+
+// Drain marks the member as draining, it stops accepting new work.
+func (t *Tracker) Drain() {
+	t.draining.Store(true)
+}
+
+func (t *Tracker) Draining() bool {
+	return t.draining.Load()
+}
+
+// SetWarm marks the caches of the member as warm.
+func (t *Tracker) SetWarm() {
+	t.warm.Store(true)
+}
+
+func (t *Tracker) Warm() bool {
+	return t.warm.Load()
+}
+
+// Status holds the state of a cluster member as reported by its admin
+// server.
+type Status struct {
+	ID        string    `json:"id"`
+	Region    string    `json:"region,omitempty"`
+	Version   string    `json:"version"`
+	StartedAT time.Time `json:"started_at"`
+	Draining  bool      `json:"draining"`
+	Inflight  int64     `json:"inflight"`
+	Warm      bool      `json:"warm"`
+	// Amount of cluster members, this member included, the member knows of.
+	Members int `json:"members"`
+}
+
+// Server is the admin server of a cluster member. It is used by the rolling
+// upgrade of the cluster to drain and restart members one by one.
+type Server struct {
+	router   *chi.Mux
+	id       string
+	region   string
+	tracker  *Tracker
+	members  func() int
+	started  time.Time
+	once     sync.Once
+	shutdown chan struct{}
+}
+
+// NewServer returns a new admin server of the member with the given id.
+// members returns the amount of members of the cluster the member knows of.
+func NewServer(id string, tracker *Tracker, members func() int) *Server {
+	s := &Server{
+		id:       id,
+		tracker:  tracker,
+		members:  members,
+		started:  time.Now(),
+		shutdown: make(chan struct{}),
+	}
+	s.router = chi.NewRouter()
+	if config.Get().Authorization {
+		s.router.Use(withAPIToken)
+	}
+	s.router.Get("/status", s.handleStatus)
+	s.router.Get("/config", s.handleConfig)
+	s.router.Get("/metrics", s.handleMetrics)
+	s.router.Post("/drain", s.handleDrain)
+	s.router.Post("/shutdown", s.handleShutdown)
+	return s
+}
+
+// WithRegion reports the region of the member in its status.
+func (s *Server) WithRegion(region string) *Server {
+	s.region = region
+	return s
+}
+
+// Listen starts listening on the given address.
+func (s *Server) Listen(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Shutdown returns a channel that is closed when the member is requested to
+// shut down. The member is drained by then.
+func (s *Server) Shutdown() <-chan struct{} {
+	return s.shutdown
+}
+
+func (s *Server) status() Status {
+	return Status{
+		ID:        s.id,
+		Region:    s.region,
+		Version:   version.Version,
+		StartedAT: s.started,
+		Draining:  s.tracker.Draining(),
+		Inflight:  s.tracker.Inflight(),
+		Warm:      s.tracker.Warm(),
+		Members:   s.members(),
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+// handleConfig returns the effective config of the member, with the reloads
+// applied and without its secrets.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.GetEffective())
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.tracker.Drain()
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+// handleShutdown shuts the member down once it is drained. Members that
+// still have work in flight respond with 409.
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	status := s.status()
+	if !status.Draining || status.Inflight > 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "member is not drained"})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+	s.once.Do(func() { close(s.shutdown) })
+}
+
+func withAPIToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+config.Get().APIToken {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerShutdownRequiresDrain(t *testing.T) {
+	tracker := NewTracker()
+	s := NewServer("ingress", tracker, func() int { return 1 })
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	client := NewClient(strings.TrimPrefix(ts.URL, "http://"), "")
+
+	_, err := client.Shutdown()
+	require.NotNil(t, err)
+
+	tracker.Begin()
+	status, err := client.Drain()
+	require.Nil(t, err)
+	require.True(t, status.Draining)
+	require.Equal(t, int64(1), status.Inflight)
+	_, err = client.Shutdown()
+	require.NotNil(t, err)
+
+	tracker.End()
+	_, err = client.Shutdown()
+	require.Nil(t, err)
+	select {
+	case <-s.Shutdown():
+	default:
+		t.Fatal("expected the shutdown channel to be closed")
+	}
+}
+
+// restartingMember is a member that is replaced by a new member, like its
+// supervisor would do, when it shuts down.
+type restartingMember struct {
+	mu       sync.Mutex
+	server   *Server
+	restarts int
+}
+
+func newRestartingMember(t *testing.T, inflight int64) *restartingMember {
+	m := &restartingMember{}
+	tracker := NewTracker()
+	tracker.SetWarm()
+	tracker.inflight.Store(inflight)
+	m.start(tracker)
+	// The in-flight work finishes a while after the drain.
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		tracker.inflight.Store(0)
+	}()
+	return m
+}
+
+func (m *restartingMember) start(tracker *Tracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.server = NewServer("member", tracker, func() int { return 2 })
+	go func(s *Server) {
+		<-s.Shutdown()
+		m.mu.Lock()
+		m.server = nil
+		m.restarts++
+		m.mu.Unlock()
+		time.Sleep(time.Millisecond * 20)
+		replacement := NewTracker()
+		m.start(replacement)
+		time.Sleep(time.Millisecond * 20)
+		replacement.SetWarm()
+	}(m.server)
+}
+
+func (m *restartingMember) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	s := m.server
+	m.mu.Unlock()
+	if s == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.ServeHTTP(w, r)
+}
+
+func TestUpgrade(t *testing.T) {
+	var (
+		members = []*restartingMember{newRestartingMember(t, 2), newRestartingMember(t, 0)}
+		clients []*Client
+		steps   []string
+	)
+	for _, m := range members {
+		ts := httptest.NewServer(m)
+		defer ts.Close()
+		clients = append(clients, NewClient(strings.TrimPrefix(ts.URL, "http://"), ""))
+	}
+	err := Upgrade(context.Background(), clients, UpgradeOptions{
+		Timeout:      time.Second * 5,
+		PollInterval: time.Millisecond * 10,
+		Progress: func(member, step string) {
+			steps = append(steps, step)
+		},
+	})
+	require.Nil(t, err)
+	for _, m := range members {
+		require.Equal(t, 1, m.restarts)
+	}
+	require.Len(t, steps, 8)
+}
+
+func TestUpgradeTimeout(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Begin()
+	ts := httptest.NewServer(NewServer("stuck", tracker, func() int { return 1 }))
+	defer ts.Close()
+
+	err := Upgrade(context.Background(), []*Client{NewClient(strings.TrimPrefix(ts.URL, "http://"), "")}, UpgradeOptions{
+		Timeout:      time.Millisecond * 50,
+		PollInterval: time.Millisecond * 10,
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerMetrics(t *testing.T) {
+	tracker := NewTracker()
+	s := NewServer("ingress-1", tracker, func() int { return 1 }).WithRegion("eu")
+	tracker.Begin()
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	body := get()
+	require.Contains(t, body, "# TYPE raptor_member_inflight_invocations gauge\n")
+	require.Contains(t, body, `raptor_member_inflight_invocations{member="ingress-1",region="eu"} 1`+"\n")
+	require.NotContains(t, body, "raptor_endpoint_")
+
+	tracker.ObserveCompile(types.CompileMetric{Duration: time.Second})
+	tracker.ObserveCompile(types.CompileMetric{Duration: time.Second / 2, Coalesced: true})
+	body = get()
+	require.Contains(t, body, "# TYPE raptor_member_cold_start_compiles_total counter\n")
+	require.Contains(t, body, `raptor_member_cold_start_compiles_total{member="ingress-1",region="eu"} 2`+"\n")
+	require.Contains(t, body, `raptor_member_coalesced_compiles_total{member="ingress-1",region="eu"} 1`+"\n")
+	require.Contains(t, body, `raptor_member_cold_start_compile_seconds_total{member="ingress-1",region="eu"} 1.5`+"\n")
+
+	tracker.SetConcurrency(map[string]EndpointConcurrency{
+		"b": {Inflight: 3, Queued: 3, Limit: 4},
+		"a": {Inflight: 2},
+	})
+	body = get()
+	require.Contains(t, body, `raptor_endpoint_inflight_invocations{endpoint_id="a",member="ingress-1",region="eu"} 2`+"\n"+
+		`raptor_endpoint_inflight_invocations{endpoint_id="b",member="ingress-1",region="eu"} 3`+"\n")
+	require.Contains(t, body, `raptor_endpoint_queued_requests{endpoint_id="b",member="ingress-1",region="eu"} 3`+"\n")
+	require.Contains(t, body, `raptor_endpoint_concurrency_utilization{endpoint_id="b",member="ingress-1",region="eu"} 1.5`+"\n")
+	require.NotContains(t, body, `raptor_endpoint_concurrency_limit{endpoint_id="a"`)
+}
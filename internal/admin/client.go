@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Client talks to the admin server of a single cluster member.
+type Client struct {
+	http  *http.Client
+	addr  string
+	token string
+}
+
+// NewClient returns a new client of the admin server listening on the given
+// address. The token is sent along when it is not empty.
+func NewClient(addr string, token string) *Client {
+	return &Client{
+		http:  &http.Client{Timeout: time.Second * 5},
+		addr:  addr,
+		token: token,
+	}
+}
+
+// Addr returns the address of the admin server.
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+func (c *Client) Status() (*Status, error) {
+	return c.do("GET", "/status")
+}
+
+func (c *Client) Drain() (*Status, error) {
+	return c.do("POST", "/drain")
+}
+
+func (c *Client) Shutdown() (*Status, error) {
+	return c.do("POST", "/shutdown")
+}
+
+func (c *Client) do(method, path string) (*Status, error) {
+	req, err := http.NewRequest(method, "http://"+c.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("member %s responded with a non 200 status code: %d", c.addr, resp.StatusCode)
+	}
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// handleMetrics exposes the load of the member in the Prometheus text
+// format, so autoscalers like the HPA through the Prometheus adapter or KEDA
+// can scale the members on the invocations in flight instead of their CPU.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	s.writeMetrics(w)
+}
+
+func (s *Server) writeMetrics(w io.Writer) {
+	member := labels("member", s.id, "region", s.region)
+	gauge(w, "raptor_member_inflight_invocations", "Invocations in progress on the runtimes of the member.")
+	fmt.Fprintf(w, "raptor_member_inflight_invocations%s %d\n", member, s.tracker.Inflight())
+	gauge(w, "raptor_member_draining", "1 while the member is draining.")
+	fmt.Fprintf(w, "raptor_member_draining%s %d\n", member, boolValue(s.tracker.Draining()))
+	compiles := s.tracker.Compiles()
+	counter(w, "raptor_member_cold_start_compiles_total", "Cold starts of the runtimes of the member that compiled their deployment or found it compiled.")
+	fmt.Fprintf(w, "raptor_member_cold_start_compiles_total%s %d\n", member, compiles.Compiles)
+	counter(w, "raptor_member_coalesced_compiles_total", "Cold starts that waited on the compilation of the same deployment by another cold start.")
+	fmt.Fprintf(w, "raptor_member_coalesced_compiles_total%s %d\n", member, compiles.Coalesced)
+	counter(w, "raptor_member_cold_start_compile_seconds_total", "Time the cold starts spent on the compilations.")
+	fmt.Fprintf(w, "raptor_member_cold_start_compile_seconds_total%s %g\n", member, compiles.Duration.Seconds())
+
+	endpoints := s.tracker.Concurrency()
+	if endpoints == nil {
+		return
+	}
+	ids := make([]string, 0, len(endpoints))
+	for id := range endpoints {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	metrics := []struct {
+		name, help string
+		value      func(EndpointConcurrency) (float64, bool)
+	}{
+		{"raptor_endpoint_inflight_invocations", "Invocations of the endpoint dispatched by the ingress of the member that did not respond yet.", func(c EndpointConcurrency) (float64, bool) {
+			return float64(c.Inflight), true
+		}},
+		{"raptor_endpoint_queued_requests", "Requests of the endpoint waiting for a runtime at the ingress of the member.", func(c EndpointConcurrency) (float64, bool) {
+			return float64(c.Queued), true
+		}},
+		{"raptor_endpoint_concurrency_limit", "Concurrency limit of the endpoint, only for endpoints with a limit.", func(c EndpointConcurrency) (float64, bool) {
+			return float64(c.Limit), c.Limit > 0
+		}},
+		{"raptor_endpoint_concurrency_utilization", "In-flight and queued invocations relative to the concurrency limit of the endpoint, above 1 the demand exceeds the capacity.", func(c EndpointConcurrency) (float64, bool) {
+			if c.Limit == 0 {
+				return 0, false
+			}
+			return float64(c.Inflight+c.Queued) / float64(c.Limit), true
+		}},
+	}
+	for _, metric := range metrics {
+		gauge(w, metric.name, metric.help)
+		for _, id := range ids {
+			if value, ok := metric.value(endpoints[id]); ok {
+				fmt.Fprintf(w, "%s%s %g\n", metric.name, labels("endpoint_id", id, "member", s.id, "region", s.region), value)
+			}
+		}
+	}
+}
+
+func gauge(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func counter(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+// labels formats the label pairs, the labels with empty values are left
+// out.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if len(pairs[i+1]) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", pairs[i], pairs[i+1])
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "{" + b.String() + "}"
+}
+
+func boolValue(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
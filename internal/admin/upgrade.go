@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UpgradeOptions configure a rolling upgrade of the cluster.
+type UpgradeOptions struct {
+	// Maximum time a member gets to drain, and its replacement gets to
+	// join the cluster and warm its caches.
+	Timeout time.Duration
+	// Interval between two status checks of a member.
+	PollInterval time.Duration
+	// Optional callback that is notified of every step of the upgrade.
+	Progress func(member string, step string)
+}
+
+// Upgrade upgrades the given members one at a time. Every member is drained,
+// shut down once its in-flight work is done, and its replacement (the member
+// restarted with the new version by its supervisor) needs to have joined the
+// cluster and warmed its caches before the next member is upgraded. The
+// upgrade stops at the first member that fails.
+func Upgrade(ctx context.Context, members []*Client, opts UpgradeOptions) error {
+	if opts.Progress == nil {
+		opts.Progress = func(string, string) {}
+	}
+	for _, member := range members {
+		if err := upgradeMember(ctx, member, opts); err != nil {
+			return fmt.Errorf("upgrade of member %s failed: %w", member.Addr(), err)
+		}
+	}
+	return nil
+}
+
+func upgradeMember(ctx context.Context, member *Client, opts UpgradeOptions) error {
+	before, err := member.Status()
+	if err != nil {
+		return err
+	}
+	opts.Progress(member.Addr(), fmt.Sprintf("draining %s (version %s)", before.ID, before.Version))
+	if _, err := member.Drain(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	err = poll(ctx, opts.PollInterval, func() (bool, error) {
+		status, err := member.Status()
+		if err != nil {
+			return false, err
+		}
+		return status.Inflight == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for in-flight work: %w", err)
+	}
+
+	opts.Progress(member.Addr(), "shutting down")
+	if _, err := member.Shutdown(); err != nil {
+		return err
+	}
+
+	opts.Progress(member.Addr(), "waiting for the replacement to join and warm up")
+	var replacement *Status
+	err = poll(ctx, opts.PollInterval, func() (bool, error) {
+		status, err := member.Status()
+		if err != nil {
+			// The member is restarting.
+			return false, nil
+		}
+		if !status.StartedAT.After(before.StartedAT) {
+			return false, nil
+		}
+		replacement = status
+		return status.Warm && status.Members >= before.Members, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for the replacement: %w", err)
+	}
+	opts.Progress(member.Addr(), fmt.Sprintf("upgraded %s to version %s", replacement.ID, replacement.Version))
+	return nil
+}
+
+// poll calls fn every interval until it returns true, an error or the
+// context is done.
+func poll(ctx context.Context, interval time.Duration, fn func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := fn()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxAuditBody is the maximum size of a JSON body recorded in the audit log,
+// larger bodies are summarized.
+const maxAuditBody = 64 * 1024
+
+const redacted = "[redacted]"
+
+// statusRecorder records the status code a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// withAudit records the mutating calls of the management API in the audit
+// log, after they were handled.
+func (s *Server) withAudit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+		params := auditBody(r)
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+
+		entry := &types.AuditEntry{
+			ID:         uuid.New(),
+			Actor:      actor(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Params:     params,
+			SourceIP:   sourceIP(r),
+			StatusCode: rec.status,
+			CreatedAT:  time.Now(),
+		}
+		if key := apiKey(r); key != nil {
+			entry.Key = key.Name
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.Route = routePattern(r)
+			if strings.HasPrefix(entry.Route, "/endpoint/{id}") {
+				entry.EndpointID, _ = uuid.Parse(rctx.URLParam("id"))
+			}
+		}
+		if err := s.store.CreateAuditEntry(entry); err != nil {
+			slog.Error("failed to store audit entry", "err", err, "method", entry.Method, "path", entry.Path)
+		}
+	})
+}
+
+// auditBody returns the parameters of the request for the audit log and
+// leaves the body readable for the handler. JSON bodies are recorded with
+// their secrets redacted, other bodies, like the blobs of deployments, are
+// only summarized.
+func auditBody(r *http.Request) string {
+	if r.Body == nil || r.ContentLength == 0 {
+		return ""
+	}
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") || r.ContentLength > maxAuditBody {
+		return fmt.Sprintf("%d bytes of %s", r.ContentLength, contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBody+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || len(body) > maxAuditBody {
+		return fmt.Sprintf("%d bytes of %s", len(body), contentType)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "invalid json"
+	}
+	b, err := json.Marshal(redact(v))
+	if err != nil {
+		return "invalid json"
+	}
+	return string(b)
+}
+
+// redact replaces the secrets of the decoded JSON value. The values of the
+// environment variables are secrets, their names are kept.
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			key := strings.ToLower(k)
+			switch {
+			case strings.Contains(key, "secret"), strings.Contains(key, "token"),
+				strings.Contains(key, "password"), strings.Contains(key, "key"):
+				v[k] = redacted
+			case key == "environment" || key == "env":
+				if env, ok := value.(map[string]any); ok {
+					for name := range env {
+						env[name] = redacted
+					}
+				}
+			default:
+				v[k] = redact(value)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return v
+}
+
+// sourceIP returns the address of the client, the first address of the
+// X-Forwarded-For header when the API server runs behind a proxy.
+func sourceIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleGetAuditLog returns the entries of the audit log, newest first. The
+// entries can be selected with the actor, endpoint, since (RFC 3339) and
+// limit query parameters.
+func (s *Server) handleGetAuditLog(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := types.AuditFilter{Actor: query.Get("actor")}
+	if v := query.Get("endpoint"); len(v) > 0 {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid endpoint given: %s", v)))
+		}
+		filter.EndpointID = id
+	}
+	if v := query.Get("since"); len(v) > 0 {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid since given: %s", v)))
+		}
+		filter.Since = since
+	}
+	if v := query.Get("limit"); len(v) > 0 {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid limit given: %s", v)))
+		}
+		filter.Limit = limit
+	}
+	entries, err := s.store.GetAuditEntries(filter)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Roles of the API keys, every role is allowed what the roles before it are
+// allowed.
+const (
+	// Read the endpoints, deployments and metrics, without the values of
+	// the environment variables.
+	RoleReadOnly = "read-only"
+	// Create, publish and roll back deployments, like a CI system does.
+	RoleDeployer = "deployer"
+	// Everything, including the changes of the endpoints and the secrets.
+	RoleAdmin = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleReadOnly: 1,
+	RoleDeployer: 2,
+	RoleAdmin:    3,
+}
+
+// deployerRoutes are the mutating routes the deployer role can call, the
+// other mutating routes require the admin role.
+var deployerRoutes = map[string]bool{
+	"POST /endpoint/{id}/deployment":              true,
+	"POST /endpoint/{id}/deployment/pull":         true,
+	"POST /endpoint/{id}/build":                   true,
+	"POST /endpoint/{id}/release":                 true,
+	"POST /endpoint/{id}/rollback":                true,
+	"POST /endpoint/{id}/rollout/abort":           true,
+	"POST /endpoint/{id}/cache/purge":             true,
+	"POST /endpoint/{id}/invoke":                  true,
+	"POST /endpoint/{id}/workflow":                true,
+	"POST /endpoint/{id}/schedule":                true,
+	"DELETE /endpoint/{id}/schedule/{scheduleID}": true,
+	"POST /invocation/{id}/replay":                true,
+	"POST /publish":                               true,
+}
+
+// adminReads are the reads that expose secrets or the activity of all the
+// projects, they require the admin role.
+var adminReads = map[string]bool{
+	"GET /audit":                           true,
+	"GET /config":                          true,
+	"GET /backup":                          true,
+	"GET /endpoint/{id}/environment/drift": true,
+	"GET /endpoint/{id}/dead-letters":      true,
+	"GET /endpoint/{id}/captures":          true,
+	"GET /invocation/{id}/request":         true,
+}
+
+var errForbidden = errors.New("forbidden")
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// WithAuthorization requires an API key on every call of the API. The token
+// is an admin key of all the projects, the keys grant their role.
+func (s *Server) WithAuthorization(token string, keys []config.APIKey) *Server {
+	s.apiKeys = make(map[string]config.APIKey, len(keys)+1)
+	for _, key := range keys {
+		if _, ok := roleRanks[key.Role]; !ok {
+			slog.Warn("api key has an unknown role and is denied everything", "key", key.Name, "role", key.Role)
+		}
+		s.apiKeys[key.Key] = key
+	}
+	if len(token) > 0 {
+		s.apiKeys[token] = config.APIKey{Name: "api token", Key: token, Role: RoleAdmin}
+	}
+	return s
+}
+
+// withAPIToken authenticates the API key of the call and checks that its
+// role and project allow the call.
+func (s *Server) withAPIToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		key, found := s.apiKeys[token]
+		if !ok || len(token) == 0 || !found {
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse(errUnauthorized))
+			return
+		}
+		route := routePattern(r)
+		if roleRanks[key.Role] < roleRanks[requiredRole(r.Method, route)] {
+			err := fmt.Errorf("%w: the %s role cannot call %s %s", errForbidden, key.Role, r.Method, route)
+			writeJSON(w, http.StatusForbidden, ErrorResponse(err))
+			return
+		}
+		if revealRequested(r) && key.Role != RoleAdmin {
+			err := fmt.Errorf("%w: the %s role cannot reveal the environment variables", errForbidden, key.Role)
+			writeJSON(w, http.StatusForbidden, ErrorResponse(err))
+			return
+		}
+		if len(key.Project) > 0 {
+			if status, err := s.checkProject(r, route, key.Project); err != nil {
+				writeJSON(w, status, ErrorResponse(err))
+				return
+			}
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+	})
+}
+
+// requiredRole returns the role the call of the route requires.
+func requiredRole(method, route string) string {
+	call := method + " " + route
+	switch {
+	case adminReads[call]:
+		return RoleAdmin
+	case method == http.MethodGet || method == http.MethodHead:
+		return RoleReadOnly
+	case deployerRoutes[call]:
+		return RoleDeployer
+	default:
+		return RoleAdmin
+	}
+}
+
+// checkProject returns an error if the route is about an endpoint outside
+// of the project. The endpoints of other projects are reported as not found.
+// The routes that are not about a single endpoint check the project in their
+// handler.
+func (s *Server) checkProject(r *http.Request, route, project string) (int, error) {
+	var endpointID uuid.UUID
+	switch {
+	case route == "/audit" || route == "/metrics/store" || route == "/config" || route == "/backup" || route == "/restore" || route == "/keys/rewrap" || strings.HasPrefix(route, "/platform/"):
+		return http.StatusForbidden, fmt.Errorf("%w: keys of a project cannot call %s", errForbidden, route)
+	case strings.HasPrefix(route, "/project/{project}"):
+		if chi.URLParam(r, "project") != project {
+			return http.StatusForbidden, fmt.Errorf("%w: keys of a project cannot access other projects", errForbidden)
+		}
+		return http.StatusOK, nil
+	case route == "/endpoint/{id}/transfer/accept":
+		// The receiving project accepts the transfer of an endpoint it
+		// does not own yet, the handler checks the side of the key.
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		endpoint, err := s.store.GetEndpoint(id)
+		if err == nil && endpoint.Ownership != nil && endpoint.Ownership.Transfer != nil && endpoint.Ownership.Transfer.To == project {
+			return http.StatusOK, nil
+		}
+		endpointID = id
+	case strings.HasPrefix(route, "/endpoint/{id}"):
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		endpointID = id
+	case route == "/build/{id}":
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		build, err := s.store.GetBuild(id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		endpointID = build.EndpointID
+	case route == "/invocation/{id}":
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		invocation, err := s.store.GetInvocation(id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		endpointID = invocation.EndpointID
+	case route == "/workflow/{id}":
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		workflow, err := s.store.GetWorkflow(id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		endpointID = workflow.EndpointID
+	case strings.HasPrefix(route, "/invocation/{id}/"):
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		capture, err := s.metricStore.GetCapturedRequest(id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		endpointID = capture.EndpointID
+	default:
+		return http.StatusOK, nil
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil || endpoint.Owner() != project {
+		return http.StatusNotFound, fmt.Errorf("could not find endpoint with id (%s)", endpointID)
+	}
+	return http.StatusOK, nil
+}
+
+// apiKey returns the API key the call was authenticated with, nil when the
+// API does not require keys.
+func apiKey(r *http.Request) *config.APIKey {
+	key, ok := r.Context().Value(apiKeyContextKey).(config.APIKey)
+	if !ok {
+		return nil
+	}
+	return &key
+}
+
+// canAccess returns true if the API key of the call can access the
+// endpoint.
+func canAccess(r *http.Request, endpoint *types.Endpoint) bool {
+	key := apiKey(r)
+	return key == nil || len(key.Project) == 0 || endpoint.Owner() == key.Project
+}
+
+// canReadSecrets returns true if the API key of the call can read the
+// values of the environment variables.
+func canReadSecrets(r *http.Request) bool {
+	key := apiKey(r)
+	return key == nil || key.Role == RoleAdmin
+}
+
+// revealRequested returns true if the call asks for the values of the
+// environment variables with ?reveal=true.
+func revealRequested(r *http.Request) bool {
+	return r.URL.Query().Get("reveal") == "true"
+}
+
+// revealSecrets returns true if the values of the environment variables are
+// returned to the call. They are only revealed on request, to the keys that
+// can read the secrets.
+func revealSecrets(r *http.Request) bool {
+	return revealRequested(r) && canReadSecrets(r)
+}
+
+// withoutSecrets returns the endpoint with the values of its environment
+// variables redacted, unless the call reveals them.
+func withoutSecrets(r *http.Request, endpoint *types.Endpoint) *types.Endpoint {
+	if revealSecrets(r) || len(endpoint.Environment) == 0 {
+		return endpoint
+	}
+	e := *endpoint
+	e.Environment = make(map[string]string, len(endpoint.Environment))
+	for name := range endpoint.Environment {
+		e.Environment[name] = redacted
+	}
+	return &e
+}
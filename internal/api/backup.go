@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/backup"
+	"github.com/anthdm/raptor/internal/storage"
+)
+
+// WithBackupStore backs up and restores the endpoints through the given
+// store instead of the store of the server. It should be the store beneath
+// the storage.EncryptedStore, so the environments stay encrypted in the
+// archives.
+func (s *Server) WithBackupStore(store storage.Store) *Server {
+	s.backupStore = store
+	return s
+}
+
+// handleGetBackup streams a backup archive of all the endpoints. An error
+// after the archive started streaming can only be logged, the archive is
+// then truncated and fails to restore.
+func (s *Server) handleGetBackup(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=raptor-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405")))
+	result, err := backup.Write(w, s.backupStore)
+	if err != nil {
+		return err
+	}
+	slog.Info("backup written", "endpoints", result.Endpoints, "deployments", result.Deployments)
+	return nil
+}
+
+// handleRestore imports the endpoints of the backup archive of the request
+// body, the endpoints that exist already are skipped.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) error {
+	result, err := backup.Restore(r.Body, s.backupStore)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
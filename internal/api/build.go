@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateBuildParams holds all the necessary fields to build and deploy the
+// sources of a function.
+type CreateBuildParams struct {
+	// Language of the sources (go, tinygo or rust). Sent as the language
+	// query parameter.
+	Language string `json:"-"`
+	// Optional zip archive with static assets served along with the function.
+	Assets []byte `json:"-"`
+	// Labels, git commit and description of the deployment. Sent as the
+	// JSON encoded "metadata" part of the multipart form.
+	Metadata types.DeploymentMetadata `json:"-"`
+}
+
+// handleCreateBuild queues the build of an uploaded source archive and
+// returns the build right away. The deployment is created once the build
+// succeeded, its status and logs are polled with the build.
+func (s *Server) handleCreateBuild(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if s.builder == nil {
+		err := fmt.Errorf("builds are not enabled")
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.quotas.CheckDeployments(endpoint.Owner()); err != nil {
+		return writeJSON(w, quotaStatus(err), ErrorResponse(err))
+	}
+	language := r.URL.Query().Get("language")
+	if !types.ValidBuildLanguage(language) {
+		err := fmt.Errorf("invalid build language %q, expected go, tinygo or rust", language)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	body, err := readDeploymentBody(r)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := body.metadata.Validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	// A raw body is the source archive.
+	source := body.source
+	if len(source) == 0 {
+		source = body.blob
+	}
+	if len(source) == 0 {
+		err := fmt.Errorf("no source archive")
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if max := config.Get().Build.MaxSourceSize; max > 0 && len(source) > max {
+		err := fmt.Errorf("source archive exceeds the maximum of %d bytes", max)
+		return writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse(err))
+	}
+	if len(body.assets) > 0 {
+		if err := assets.Validate(body.assets); err != nil {
+			return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		}
+	}
+	build := types.NewBuild(endpoint, language, body.metadata)
+	if err := s.store.CreateBuild(build); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	queued := *build
+	go s.runBuild(endpoint, build, source, body.assets)
+	return writeJSON(w, http.StatusAccepted, queued)
+}
+
+func (s *Server) handleGetBuild(w http.ResponseWriter, r *http.Request) error {
+	buildID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	build, err := s.store.GetBuild(buildID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, build)
+}
+
+// runBuild compiles the sources and creates the deployment of the build.
+// The deployment goes through the same checks as an uploaded blob, the
+// reason of a failure is appended to the logs of the build.
+func (s *Server) runBuild(endpoint *types.Endpoint, build *types.Build, source, assetBundle []byte) {
+	build.Status = types.BuildRunning
+	if err := s.store.UpdateBuild(build); err != nil {
+		slog.Error("failed to update build", "err", err, "id", build.ID)
+	}
+	deploy, logs, err := s.buildDeployment(endpoint, build, source, assetBundle)
+	build.Logs = string(logs)
+	if err != nil {
+		build.Status = types.BuildFailed
+		build.Logs += fmt.Sprintf("\nbuild failed: %s\n", err)
+	} else {
+		build.Status = types.BuildSucceeded
+		build.DeploymentID = deploy.ID
+	}
+	now := time.Now()
+	build.FinishedAT = &now
+	if err := s.store.UpdateBuild(build); err != nil {
+		slog.Error("failed to update build", "err", err, "id", build.ID)
+	}
+}
+
+func (s *Server) buildDeployment(endpoint *types.Endpoint, build *types.Build, source, assetBundle []byte) (*types.Deployment, []byte, error) {
+	module, logs, err := s.builder.Build(context.Background(), build.Language, source)
+	if err != nil {
+		return nil, logs, err
+	}
+	deploy := types.NewDeployment(endpoint, module)
+	deploy.SetAssets(assetBundle)
+	deploy.DeploymentMetadata = build.DeploymentMetadata
+	buildID := build.ID
+	deploy.BuildID = &buildID
+	deploy.BuildStatus = types.BuildSucceeded
+	if _, err := s.checkDeployment(endpoint, deploy); err != nil {
+		return nil, logs, err
+	}
+	if err := s.quotas.CheckDeployments(endpoint.Owner()); err != nil {
+		return nil, logs, err
+	}
+	if err := s.store.CreateDeployment(deploy); err != nil {
+		return nil, logs, err
+	}
+	s.notifier.Emit(types.NewEvent(types.EventDeploymentCreated, endpoint.ID, deploy.ID, "", fmt.Sprintf("built by %s", build.ID)))
+	return deploy, logs, nil
+}
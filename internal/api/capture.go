@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReplayResponse is the response of the replay of a captured request.
+type ReplayResponse struct {
+	// Deployment the request was replayed against, the LIVE deployment
+	// when none was given.
+	DeploymentID uuid.UUID   `json:"deployment_id"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	// Logs of the invocation, only for the replays against a deployment.
+	Logs     string        `json:"logs,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// handleGetCaptures returns the captured requests of the endpoint, oldest
+// first. The requests hold their headers and bodies.
+func (s *Server) handleGetCaptures(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	captures, err := s.metricStore.GetCapturedRequests(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, captures)
+}
+
+func (s *Server) handleGetCapturedRequest(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	capture, err := s.metricStore.GetCapturedRequest(id)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, capture)
+}
+
+// handleReplay sends the captured request of the invocation again through
+// the ingress and returns the response. The request goes to the deployment
+// of the deployment query parameter in PREVIEW, along with its logs, or to
+// the LIVE deployment of the endpoint. Redacted headers are left out.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	capture, err := s.metricStore.GetCapturedRequest(id)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var (
+		url      = fmt.Sprintf("%s/live/%s%s", s.ingressURL, capture.EndpointID, capture.Path)
+		deployID uuid.UUID
+	)
+	if v := r.URL.Query().Get("deployment"); len(v) > 0 {
+		deployID, err = uuid.Parse(v)
+		if err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid deployment given: %s", v)))
+		}
+		deploy, err := s.store.GetDeployment(deployID)
+		if err != nil || deploy.EndpointID != capture.EndpointID {
+			err := fmt.Errorf("could not find deployment with id (%s)", deployID)
+			return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+		}
+		url = fmt.Sprintf("%s/preview/%s%s", s.ingressURL, deployID, capture.Path)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), capture.Method, url, bytes.NewReader(capture.Body))
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for name, values := range capture.Header {
+		if len(values) == 1 && values[0] == types.RedactedHeader {
+			continue
+		}
+		req.Header[name] = values
+	}
+	req.Header.Set(shared.ReplayHeader, capture.ID.String())
+	if deployID != uuid.Nil {
+		req.Header.Set(shared.PreviewLogsHeader, "true")
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return writeJSON(w, http.StatusBadGateway, ErrorResponse(err))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return writeJSON(w, http.StatusBadGateway, ErrorResponse(err))
+	}
+	replay := ReplayResponse{
+		DeploymentID: deployID,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header,
+		Body:         body,
+		Duration:     time.Since(start),
+	}
+	if encoded := resp.Header.Get(shared.PreviewLogsHeader); len(encoded) > 0 {
+		resp.Header.Del(shared.PreviewLogsHeader)
+		if logs, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			replay.Logs = string(logs)
+		}
+	}
+	return writeJSON(w, http.StatusOK, replay)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultConcurrencyWindow is the window of the concurrency peaks when no
+	// window is given.
+	defaultConcurrencyWindow = time.Minute * 5
+	// concurrencyStale is the age after which the concurrency sample of an
+	// ingress no longer counts, the ingresses sample every 5 seconds while
+	// the endpoint has invocations.
+	concurrencyStale = time.Second * 15
+)
+
+// handleGetEndpointConcurrency returns the in-flight and queued invocations
+// of the endpoint over the ingresses, with their peaks over the window (like
+// 15m) query parameter.
+func (s *Server) handleGetEndpointConcurrency(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	window := defaultConcurrencyWindow
+	if v := r.URL.Query().Get("window"); len(v) > 0 {
+		window, err = time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid window given: %s", v)))
+		}
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	now := time.Now()
+	since := now.Add(-window)
+	metrics, err := s.metricStore.GetConcurrencyMetrics(endpointID, since)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, types.AggregateConcurrency(metrics, since, now, concurrencyStale))
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// handleGetDeadLetters returns the dead letters of the endpoint, oldest
+// first. The letters hold the requests, including their headers and bodies.
+func (s *Server) handleGetDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	letters, err := s.store.GetDeadLetters(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, letters)
+}
+
+// handleRedriveDeadLetter removes the dead letter and invokes its request
+// again in the background. The request is dead-lettered again when it still
+// fails.
+func (s *Server) handleRedriveDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	endpoint, letter, status, err := s.deadLetter(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	if err := s.store.DeleteDeadLetter(letter.ID); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	go func() {
+		if _, err := s.invoker.Invoke(context.Background(), endpoint, letter.Source, letter.Request); err != nil {
+			slog.Warn("re-drive of dead letter failed", "err", err, "letter", letter.ID)
+		}
+	}()
+	return writeJSON(w, http.StatusAccepted, map[string]string{"status": "OK"})
+}
+
+func (s *Server) handleDeleteDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	_, letter, status, err := s.deadLetter(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	if err := s.store.DeleteDeadLetter(letter.ID); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+// deadLetter returns the endpoint and the dead letter of the request, with
+// the status code of the error when either does not exist.
+func (s *Server) deadLetter(r *http.Request) (*types.Endpoint, *types.DeadLetter, int, error) {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	letterID, err := uuid.Parse(chi.URLParam(r, "letterID"))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, err
+	}
+	letter, err := s.store.GetDeadLetter(letterID)
+	if err != nil || letter.EndpointID != endpointID {
+		return nil, nil, http.StatusNotFound, fmt.Errorf("could not find dead letter with id (%s)", letterID)
+	}
+	return endpoint, letter, 0, nil
+}
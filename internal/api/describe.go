@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultDescribeWindow is the window the requests of a description are
+	// summarized over when no window is given.
+	defaultDescribeWindow = time.Hour
+	// describeDeployments is the amount of recent deployments a
+	// description holds.
+	describeDeployments = 5
+	// describeErrorGroups is the amount of the most frequent error groups a
+	// description holds.
+	describeErrorGroups = 3
+)
+
+// DescribeResponse aggregates the state of an endpoint into one view.
+type DescribeResponse struct {
+	Endpoint *types.Endpoint `json:"endpoint"`
+	// The LIVE deployment, nil when nothing is published.
+	ActiveDeployment *types.Deployment       `json:"active_deployment,omitempty"`
+	Health           *types.DeploymentHealth `json:"health,omitempty"`
+	Requests         types.RequestSummary    `json:"requests"`
+	// The invocation errors in the window and their most frequent groups.
+	Errors      int                `json:"errors"`
+	ErrorGroups []types.ErrorGroup `json:"error_groups"`
+	// The most recent deployments, newest first.
+	Deployments []types.Deployment `json:"deployments"`
+}
+
+// handleDescribeEndpoint returns the endpoint together with its LIVE
+// deployment, the health of the deployment, a summary of the recent requests
+// and errors and the most recent deployments. The requests and the errors are
+// summarized over the optional "window" query parameter, like 24h.
+func (s *Server) handleDescribeEndpoint(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	window := defaultDescribeWindow
+	if v := r.URL.Query().Get("window"); len(v) > 0 {
+		window, err = time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			err := fmt.Errorf("invalid window given: %s", v)
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	deploys, err := s.store.GetDeployments(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	metrics, err := s.metricStore.GetRequestMetrics(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+
+	errs, err := s.metricStore.GetInvocationErrors(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+
+	since := time.Now().Add(-window)
+	resp := DescribeResponse{
+		Endpoint:    withoutSecrets(r, endpoint),
+		Requests:    types.SummarizeRequests(metrics, since),
+		ErrorGroups: types.GroupErrors(errs, since),
+		Deployments: []types.Deployment{},
+	}
+	for _, group := range resp.ErrorGroups {
+		resp.Errors += group.Count
+	}
+	if len(resp.ErrorGroups) > describeErrorGroups {
+		resp.ErrorGroups = resp.ErrorGroups[:describeErrorGroups]
+	}
+	for i := len(deploys) - 1; i >= 0; i-- {
+		deploys[i].PreviewURL = previewURL(deploys[i].ID)
+		if deploys[i].ID == endpoint.ActiveDeploymentID {
+			deploy := deploys[i]
+			resp.ActiveDeployment = &deploy
+		}
+		if len(resp.Deployments) < describeDeployments {
+			resp.Deployments = append(resp.Deployments, deploys[i])
+		}
+	}
+	if !shared.IsZeroUUID(endpoint.ActiveDeploymentID) {
+		health, err := s.deploymentHealth(endpoint.ActiveDeploymentID)
+		if err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		resp.Health = &health
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
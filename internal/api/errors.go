@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// handleGetErrors returns the invocation errors of the endpoint grouped by
+// their signature, the most frequent group first. The errors can be selected
+// with the deployment and the window (like 24h) query parameters.
+func (s *Server) handleGetErrors(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	query := r.URL.Query()
+	var since time.Time
+	if v := query.Get("window"); len(v) > 0 {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid window given: %s", v)))
+		}
+		since = time.Now().Add(-window)
+	}
+	var deploymentID uuid.UUID
+	if v := query.Get("deployment"); len(v) > 0 {
+		deploymentID, err = uuid.Parse(v)
+		if err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid deployment given: %s", v)))
+		}
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	errs, err := s.metricStore.GetInvocationErrors(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if deploymentID != uuid.Nil {
+		selected := errs[:0]
+		for _, e := range errs {
+			if e.DeploymentID == deploymentID {
+				selected = append(selected, e)
+			}
+		}
+		errs = selected
+	}
+	return writeJSON(w, http.StatusOK, types.GroupErrors(errs, since))
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// PruneParams holds the options of a manual prune of the deployments of an
+// endpoint.
+type PruneParams struct {
+	// Retention used instead of the retention policy of the endpoint.
+	Retention *types.RetentionPolicy `json:"retention"`
+	// Only report the deployments that would be deleted.
+	DryRun bool `json:"dry_run"`
+}
+
+// PruneResponse holds the deployments that were deleted by a prune.
+type PruneResponse struct {
+	Pruned []types.Deployment `json:"pruned"`
+	DryRun bool               `json:"dry_run"`
+}
+
+func (s *Server) handlePruneDeployments(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var params PruneParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	retention := endpoint.Retention
+	if params.Retention != nil {
+		if err := params.Retention.Validate(); err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+		retention = params.Retention
+	}
+	if retention.IsZero() {
+		err := fmt.Errorf("endpoint (%s) does not have a retention policy", endpointID)
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	pruned, err := s.pruneDeployments(endpoint, retention, params.DryRun)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if pruned == nil {
+		pruned = []types.Deployment{}
+	}
+	return writeJSON(w, http.StatusOK, PruneResponse{Pruned: pruned, DryRun: params.DryRun})
+}
+
+// pruneDeployments deletes the deployments of the endpoint that are not
+// kept by the given retention and returns them. The compiled modules of the
+// deployments are evicted before their blobs are deleted, the members evict
+// theirs when they sweep their mod cache.
+func (s *Server) pruneDeployments(endpoint *types.Endpoint, retention *types.RetentionPolicy, dryRun bool) ([]types.Deployment, error) {
+	deploys, err := s.store.GetDeployments(endpoint.ID)
+	if err != nil {
+		return nil, err
+	}
+	expired := retention.Expired(deploys, endpoint.ActiveDeploymentID, time.Now())
+	if dryRun {
+		return expired, nil
+	}
+	pruned := make([]types.Deployment, 0, len(expired))
+	for _, deploy := range expired {
+		s.cache.Delete(deploy.ID)
+		if err := s.store.DeleteDeployment(deploy.ID); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, deploy)
+	}
+	return pruned, nil
+}
+
+// RunDeploymentGC prunes the deployments of all the endpoints with a
+// retention policy once per interval, until the context is done.
+func (s *Server) RunDeploymentGC(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.collectDeployments()
+	}
+}
+
+func (s *Server) collectDeployments() {
+	endpoints, err := s.store.GetEndpoints()
+	if err != nil {
+		slog.Warn("deployment gc failed to load the endpoints", "err", err)
+		return
+	}
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		if endpoint.Retention.IsZero() {
+			continue
+		}
+		pruned, err := s.pruneDeployments(endpoint, endpoint.Retention, false)
+		if err != nil {
+			slog.Warn("deployment gc failed", "endpoint", endpoint.ID, "err", err)
+		}
+		if len(pruned) > 0 {
+			slog.Info("pruned deployments", "endpoint", endpoint.ID, "count", len(pruned))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// WithHealthChecks enables the calls of the health function of the guests
+// after a publish, or before it when the publishes are gated.
+func (s *Server) WithHealthChecks(c config.Health) *Server {
+	s.health = c
+	return s
+}
+
+func (s *Server) handleGetEndpointHealth(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	deployID := endpoint.ActiveDeploymentID
+	if id := r.URL.Query().Get("deployment"); len(id) > 0 {
+		if deployID, err = uuid.Parse(id); err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+	}
+	if shared.IsZeroUUID(deployID) {
+		err := fmt.Errorf("endpoint (%s) does not have an active deployment", endpointID)
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	health, err := s.deploymentHealth(deployID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, health)
+}
+
+func (s *Server) deploymentHealth(deployID uuid.UUID) (types.DeploymentHealth, error) {
+	checks, err := s.metricStore.GetHealthChecks(deployID)
+	if err != nil {
+		return types.DeploymentHealth{}, err
+	}
+	return types.NewDeploymentHealth(deployID, checks, s.health.FailureThreshold), nil
+}
+
+// gateHealth returns an error if the deployment is unhealthy or fails the
+// health check that is run before it is published.
+func (s *Server) gateHealth(endpoint *types.Endpoint, deploy *types.Deployment) error {
+	health, err := s.deploymentHealth(deploy.ID)
+	if err != nil {
+		return err
+	}
+	if health.Status == types.HealthUnhealthy {
+		return fmt.Errorf("deploy %s is unhealthy: %s", deploy.ID, health.LastCheck.Error)
+	}
+	check, err := s.checkHealth(endpoint, deploy)
+	if err != nil {
+		return err
+	}
+	if check != nil && !check.Healthy {
+		return fmt.Errorf("deploy %s failed its health check: %s", deploy.ID, check.Error)
+	}
+	return nil
+}
+
+// checkHealth calls the health function of the deployment with the
+// environment of the endpoint and stores the outcome. It returns nil if the
+// deployment does not export a health function.
+func (s *Server) checkHealth(endpoint *types.Endpoint, deploy *types.Deployment) (*types.HealthCheck, error) {
+	// Scripts run on the interpreter, which has no health function.
+	if endpoint.Runtime == "js" {
+		return nil, nil
+	}
+	if err := s.verifier.Verify(deploy.Blob, deploy.Signature); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if timeout := time.Duration(s.health.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cache, _, err := s.compiler.Compile(ctx, deploy.ID, deploy.Blob)
+	if err != nil {
+		return nil, err
+	}
+	run, err := runtime.New(ctx, runtime.Args{
+		DeploymentID: deploy.ID,
+		Engine:       endpoint.Runtime,
+		Blob:         deploy.Blob,
+		Cache:        cache,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer run.Close()
+
+	env, err := s.environment(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = run.Health(ctx, env)
+	if errors.Is(err, runtime.ErrNoHealthCheck) {
+		return nil, nil
+	}
+	check := types.NewHealthCheck(endpoint.ID, deploy.ID, start, err)
+	if err := s.metricStore.CreateHealthCheck(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// checkPublishedHealth checks the health of a deployment after it was
+// published, failures are picked up by the alerts of the warm runtimes.
+func (s *Server) checkPublishedHealth(endpoint *types.Endpoint, deploy *types.Deployment) {
+	check, err := s.checkHealth(endpoint, deploy)
+	if err != nil {
+		slog.Warn("failed to check the health of a published deployment", "deployment", deploy.ID, "err", err)
+		return
+	}
+	if check != nil && !check.Healthy {
+		slog.Warn("published deployment failed its health check", "deployment", deploy.ID, "err", check.Error)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// handleInvoke queues an asynchronous invocation of the LIVE deployment of
+// the endpoint and returns the invocation right away, its status and result
+// are polled with GET /invocation/{id}. The mode query parameter has to be
+// async, the method and path query parameters select the request the
+// function receives (POST / by default). The body and the headers of the
+// call are passed along, except for its authorization.
+func (s *Server) handleInvoke(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	query := r.URL.Query()
+	if mode := query.Get("mode"); mode != "async" {
+		err := fmt.Errorf("invalid invocation mode %q, expected async, synchronous invocations go through the ingress", mode)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if !endpoint.HasActiveDeploy() {
+		err := fmt.Errorf("endpoint (%s) has no LIVE deployment", endpoint.ID)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	req, status, err := readAsyncRequest(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	invocation := types.NewInvocation(endpoint.ID)
+	if err := s.store.CreateInvocation(invocation); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	queued := *invocation
+	go s.invoker.Run(endpoint, invocation, async.SourceAsync, req)
+	w.Header().Set("Location", "/invocation/"+invocation.ID.String())
+	return writeJSON(w, http.StatusAccepted, queued)
+}
+
+func (s *Server) handleGetInvocation(w http.ResponseWriter, r *http.Request) error {
+	invocationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	invocation, err := s.store.GetInvocation(invocationID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, invocation)
+}
+
+// readAsyncRequest returns the request of an asynchronous invocation given by
+// the call: its method and path query parameters (POST / by default), its
+// headers except for its authorization and its body. It returns the status
+// code of the error.
+func readAsyncRequest(r *http.Request) (types.AsyncRequest, int, error) {
+	query := r.URL.Query()
+	req := types.AsyncRequest{
+		Method: query.Get("method"),
+		Path:   query.Get("path"),
+		Header: r.Header.Clone(),
+	}
+	if len(req.Method) == 0 {
+		req.Method = http.MethodPost
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		req.Path = "/" + req.Path
+	}
+	req.Header.Del("Authorization")
+	req.Header.Del("Content-Length")
+	body, err := io.ReadAll(io.LimitReader(r.Body, async.MaxBodySize+1))
+	if err != nil {
+		return req, http.StatusBadRequest, err
+	}
+	defer r.Body.Close()
+	if len(body) > async.MaxBodySize {
+		return req, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds the maximum of %d bytes", async.MaxBodySize)
+	}
+	req.Body = body
+	return req, http.StatusOK, nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+)
+
+// UpdateProjectEnvironmentParams updates the default environment of a
+// project, like the environment of an endpoint is updated.
+type UpdateProjectEnvironmentParams struct {
+	// Environment variables to set, a null value deletes the variable.
+	Environment map[string]*string `json:"environment"`
+	// How the environment is applied, merge (the default) or replace.
+	EnvironmentMode string `json:"environment_mode"`
+}
+
+func (p UpdateProjectEnvironmentParams) validate() error {
+	switch p.EnvironmentMode {
+	case "", EnvironmentMerge, EnvironmentReplace:
+	default:
+		return fmt.Errorf("invalid environment mode %q, expected merge or replace", p.EnvironmentMode)
+	}
+	return nil
+}
+
+// handleGetProjectEnvironment returns the default environment of the project,
+// the values are redacted unless the call reveals them.
+func (s *Server) handleGetProjectEnvironment(w http.ResponseWriter, r *http.Request) error {
+	project := chi.URLParam(r, "project")
+	env, err := s.store.GetProjectEnvironment(project)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, projectWithoutSecrets(r, env))
+}
+
+// handleUpdateProjectEnvironment updates the default environment of the
+// project. The endpoints of the project inherit the variables they do not
+// set themselves the next time they are instantiated.
+func (s *Server) handleUpdateProjectEnvironment(w http.ResponseWriter, r *http.Request) error {
+	project := chi.URLParam(r, "project")
+	if err := types.ValidateOwner(project); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var params UpdateProjectEnvironmentParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	defer r.Body.Close()
+	if err := params.validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	// Merged updates read the current environment first, concurrent
+	// updates would lose each other's variables.
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	current, err := s.store.GetProjectEnvironment(project)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	env := types.NewProjectEnvironment(project, updateEnvironment(current.Environment, params.Environment, params.EnvironmentMode))
+	if err := s.store.UpdateProjectEnvironment(env); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, projectWithoutSecrets(r, env))
+}
+
+// environment returns the environment the endpoint is instantiated with, its
+// own variables on top of the defaults of its project.
+func (s *Server) environment(endpoint *types.Endpoint) (map[string]string, error) {
+	project := endpoint.Owner()
+	if len(project) == 0 {
+		return endpoint.Environment, nil
+	}
+	defaults, err := s.store.GetProjectEnvironment(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the environment of project %s: %w", project, err)
+	}
+	return types.InheritEnvironment(defaults.Environment, endpoint.Environment), nil
+}
+
+// projectWithoutSecrets returns the project environment with the values of
+// its variables redacted, unless the call reveals them.
+func projectWithoutSecrets(r *http.Request, env *types.ProjectEnvironment) *types.ProjectEnvironment {
+	if revealSecrets(r) || len(env.Environment) == 0 {
+		return env
+	}
+	e := *env
+	e.Environment = make(map[string]string, len(env.Environment))
+	for name := range env.Environment {
+		e.Environment[name] = redacted
+	}
+	return &e
+}
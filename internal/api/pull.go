@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/signing"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// PullDeploymentParams holds the artifact a deployment is pulled from.
+type PullDeploymentParams struct {
+	// Either oci://registry/repository[:tag|@digest] or an https:// URL.
+	Source string `json:"source"`
+	// Optional sha256:<hex> digest the WASM module needs to match.
+	Digest    string                   `json:"digest,omitempty"`
+	Metadata  types.DeploymentMetadata `json:"metadata"`
+	Signature []byte                   `json:"signature,omitempty"`
+	// Always create a new deployment, even when the endpoint already has an
+	// identical one.
+	NoDedupe bool `json:"no_dedupe,omitempty"`
+}
+
+// WithArtifactClient replaces the client the artifacts of the pulled deployments
+// are fetched with.
+func (s *Server) WithArtifactClient(client *http.Client) *Server {
+	s.fetcher.WithClient(client)
+	return s
+}
+
+// handlePullDeployment creates a deployment from a WASM module the server
+// pulls from an OCI registry or an https URL. The digest of the module is
+// verified before the deployment goes through the same checks as an
+// uploaded blob.
+func (s *Server) handlePullDeployment(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params PullDeploymentParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := params.Metadata.Validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := signing.ValidateSignature(params.Signature); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	artifact, err := s.fetcher.Fetch(r.Context(), params.Source, params.Digest)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	deploy := types.NewDeployment(endpoint, artifact.Blob)
+	deploy.DeploymentMetadata = params.Metadata
+	deploy.Signature = params.Signature
+	deploy.Source = params.Source
+	if status, err := s.checkDeployment(endpoint, deploy); err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	if !params.NoDedupe {
+		existing, err := s.findIdenticalDeployment(deploy)
+		if err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		if existing != nil {
+			existing.PreviewURL = previewURL(existing.ID)
+			return writeJSON(w, http.StatusOK, existing)
+		}
+	}
+	if err := s.quotas.CheckDeployments(endpoint.Owner()); err != nil {
+		return writeJSON(w, quotaStatus(err), ErrorResponse(err))
+	}
+	if err := s.store.CreateDeployment(deploy); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	s.notifier.Emit(types.NewEvent(types.EventDeploymentCreated, endpoint.ID, deploy.ID, actor(r), ""))
+	deploy.PreviewURL = previewURL(deploy.ID)
+	return writeJSON(w, http.StatusOK, deploy)
+}
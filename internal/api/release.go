@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReleaseManifest is the "manifest" part of a release, it describes the
+// deployment and how it is published.
+type ReleaseManifest struct {
+	types.DeploymentMetadata
+	// Only create the deployment, its preview URL can be checked before it
+	// is published.
+	PreviewOnly bool `json:"preview_only,omitempty"`
+	// Shift the traffic to the deployment gradually instead of all at once.
+	Rollout *types.RolloutPolicy `json:"rollout,omitempty"`
+}
+
+// ReleaseParams holds the parts of a release besides its blob.
+type ReleaseParams struct {
+	// Optional zip archive with static assets served along with the function.
+	Assets []byte `json:"-"`
+	// Ed25519 signature of the blob. Sent as the base64 encoded "signature"
+	// part of the multipart form.
+	Signature []byte `json:"-"`
+	// Sent as the JSON encoded "manifest" part of the multipart form.
+	Manifest ReleaseManifest `json:"-"`
+}
+
+// ReleaseResponse is the result of a release.
+type ReleaseResponse struct {
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	PreviewURL   string    `json:"preview_url"`
+	// URL of the LIVE endpoint, empty when the deployment was not published.
+	URL string `json:"url,omitempty"`
+	// Whether the deployment was created, false when the endpoint already
+	// had an identical deployment.
+	Created bool `json:"created"`
+	// Whether the deployment serves all the LIVE traffic.
+	Published bool `json:"published"`
+	// The rollout of the deployment, when it is published gradually.
+	Rollout *types.Rollout `json:"rollout,omitempty"`
+}
+
+// handleRelease deploys and publishes in one call, for the CI pipelines. The
+// body is the multipart form of a deployment with a "manifest" part. A
+// release is idempotent: releasing an identical deployment again returns
+// the existing deployment and does not publish it again when it is already
+// LIVE or rolling out.
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	body, err := readDeploymentBody(r)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var manifest ReleaseManifest
+	if len(body.manifest) > 0 {
+		if err := json.Unmarshal(body.manifest, &manifest); err != nil {
+			err := fmt.Errorf("invalid release manifest: %s", err)
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+		body.metadata = manifest.DeploymentMetadata
+	}
+	if manifest.Rollout != nil {
+		if err := manifest.Rollout.Validate(); err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+	}
+	deploy, created, status, err := s.createDeployment(r, endpoint, body, true)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	resp := ReleaseResponse{
+		EndpointID:   endpoint.ID,
+		DeploymentID: deploy.ID,
+		PreviewURL:   deploy.PreviewURL,
+		Created:      created,
+	}
+	if manifest.PreviewOnly {
+		return writeJSON(w, http.StatusOK, resp)
+	}
+	liveURL := fmt.Sprintf("%s/live/%s", config.IngressUrl(), endpoint.ID)
+	switch {
+	case endpoint.Rollout.Running() && endpoint.Rollout.DeploymentID == deploy.ID:
+		resp.URL, resp.Rollout = liveURL, endpoint.Rollout
+	case endpoint.ActiveDeploymentID == deploy.ID && !endpoint.Rollout.Running():
+		resp.URL, resp.Published = liveURL, true
+	case manifest.Rollout != nil:
+		rollout, status, err := s.beginRollout(r, endpoint, deploy, *manifest.Rollout)
+		if err != nil {
+			return writeJSON(w, status, ErrorResponse(err))
+		}
+		resp.URL, resp.Rollout = liveURL, rollout
+	default:
+		if status, err := s.checkPublish(endpoint, deploy); err != nil {
+			return writeJSON(w, status, ErrorResponse(err))
+		}
+		if status, err := s.activate(types.DeploymentEventPublish, endpoint, deploy, actor(r)); err != nil {
+			return writeJSON(w, status, ErrorResponse(err))
+		}
+		resp.URL, resp.Published = liveURL, true
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
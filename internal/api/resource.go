@@ -0,0 +1,185 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// APIVersion is the version of the management API mounted under /v1. The
+// routes under /v1 only change in backward compatible ways, the routes
+// without prefix are the same routes kept for the existing clients.
+const APIVersion = "v1"
+
+// routePattern returns the pattern of the route of the request without the
+// version prefix, so the routes are authorized and audited the same way
+// with and without it.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return strings.TrimPrefix(rctx.RoutePattern(), "/"+APIVersion)
+}
+
+// endpointETag returns the entity tag of the endpoint, it changes with
+// every change of the endpoint.
+func endpointETag(endpoint *types.Endpoint) (string, error) {
+	b, err := json.Marshal(endpoint)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// checkIfMatch returns the status code and an error if the request has an
+// If-Match header that does not match the current entity tag of the
+// endpoint.
+func checkIfMatch(r *http.Request, endpoint *types.Endpoint) (int, error) {
+	header := r.Header.Get("If-Match")
+	if len(header) == 0 {
+		return http.StatusOK, nil
+	}
+	etag, err := endpointETag(endpoint)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return http.StatusOK, nil
+		}
+	}
+	return http.StatusPreconditionFailed, fmt.Errorf("endpoint (%s) was changed, its current version is %s", endpoint.ID, etag)
+}
+
+// setETag sets the ETag header of the response to the entity tag of the
+// endpoint. The tag is derived from the environment, it is only sent to the
+// keys that can read the secrets so it cannot be used to guess them.
+func setETag(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint) error {
+	if !canReadSecrets(r) {
+		return nil
+	}
+	etag, err := endpointETag(endpoint)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	return nil
+}
+
+// handleDeleteEndpoint deletes the endpoint with its deployments and
+// webhooks. The endpoints the routes of composite endpoints target cannot
+// be deleted.
+func (s *Server) handleDeleteEndpoint(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if status, err := checkIfMatch(r, endpoint); err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	endpoints, err := s.store.GetEndpoints()
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for _, other := range endpoints {
+		for _, route := range other.Routes {
+			if route.EndpointID == endpointID {
+				err := fmt.Errorf("endpoint (%s) is the target of route %s of endpoint (%s)", endpointID, route.Prefix, other.ID)
+				return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+			}
+		}
+	}
+	deploys, err := s.store.GetDeployments(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for _, deploy := range deploys {
+		s.cache.Delete(deploy.ID)
+		if err := s.store.DeleteDeployment(deploy.ID); err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+	}
+	if err := s.store.DeleteEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+// handleGetDeployment returns a deployment of the endpoint, without its
+// blob.
+func (s *Server) handleGetDeployment(w http.ResponseWriter, r *http.Request) error {
+	deploy, status, err := s.endpointDeployment(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	deploy.PreviewURL = previewURL(deploy.ID)
+	return writeJSON(w, http.StatusOK, deploy)
+}
+
+// handleDeleteDeployment deletes a deployment of the endpoint. The active
+// deployment and the deployments of a running rollout cannot be deleted.
+func (s *Server) handleDeleteDeployment(w http.ResponseWriter, r *http.Request) error {
+	deploy, status, err := s.endpointDeployment(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	inUse := deploy.ID == endpoint.ActiveDeploymentID
+	if endpoint.Rollout.Running() {
+		inUse = inUse || deploy.ID == endpoint.Rollout.DeploymentID || deploy.ID == endpoint.Rollout.PreviousDeploymentID
+	}
+	if inUse {
+		err := fmt.Errorf("deployment (%s) serves the traffic of endpoint (%s)", deploy.ID, endpoint.ID)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	s.cache.Delete(deploy.ID)
+	if err := s.store.DeleteDeployment(deploy.ID); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+// endpointDeployment returns the deployment of the request, as listed by the
+// store, and the status code of the failure.
+func (s *Server) endpointDeployment(r *http.Request) (*types.Deployment, int, error) {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	deployID, err := uuid.Parse(chi.URLParam(r, "deployID"))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	deploys, err := s.store.GetDeployments(endpointID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	for _, deploy := range deploys {
+		if deploy.ID == deployID {
+			return &deploy, http.StatusOK, nil
+		}
+	}
+	return nil, http.StatusNotFound, fmt.Errorf("could not find deployment with id (%s)", deployID)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// startRollout starts shifting the LIVE traffic of the endpoint to the
+// deployment step by step, the shift runs in the background.
+func (s *Server) startRollout(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint, deploy *types.Deployment, policy types.RolloutPolicy) error {
+	rollout, status, err := s.beginRollout(r, endpoint, deploy, policy)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	resp := PublishResponse{
+		DeploymentID: deploy.ID,
+		URL:          fmt.Sprintf("%s/live/%s", config.IngressUrl(), endpoint.ID),
+		Rollout:      rollout,
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// beginRollout starts the rollout of the deployment to the endpoint and
+// returns it with the status code of the failure.
+func (s *Server) beginRollout(r *http.Request, endpoint *types.Endpoint, deploy *types.Deployment, policy types.RolloutPolicy) (*types.Rollout, int, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if !endpoint.HasActiveDeploy() {
+		err := fmt.Errorf("endpoint (%s) does not have a LIVE deployment to roll out from, publish the deployment instead", endpoint.ID)
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	if status, err := s.checkPublish(endpoint, deploy); err != nil {
+		return nil, status, err
+	}
+	rollout := types.NewRollout(endpoint, deploy.ID, policy, actor(r))
+	if err := s.storeRolloutStep(endpoint, rollout); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	go s.runRollout(endpoint.ID, rollout.ID)
+	return rollout, http.StatusOK, nil
+}
+
+// runRollout advances the rollout at the end of every step until it is
+// completed or rolled back, or until another rollout replaced it.
+func (s *Server) runRollout(endpointID, rolloutID uuid.UUID) {
+	for {
+		endpoint, err := s.store.GetEndpoint(endpointID)
+		if err != nil {
+			slog.Error("failed to get the endpoint of a rollout", "err", err, "endpoint", endpointID)
+			return
+		}
+		rollout := endpoint.Rollout
+		if !rollout.Running() || rollout.ID != rolloutID {
+			return
+		}
+		if wait := time.Until(rollout.StepEnds()); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+		if err := s.advanceRollout(endpoint, rollout); err != nil {
+			slog.Error("failed to advance a rollout", "err", err, "endpoint", endpointID, "rollout", rolloutID)
+			return
+		}
+	}
+}
+
+// advanceRollout checks the requests the new deployment served during the
+// current step against the thresholds of the rollout. The rollout is rolled
+// back when they are breached, it moves to the next step otherwise. After
+// the last step the new deployment becomes the active deployment.
+func (s *Server) advanceRollout(endpoint *types.Endpoint, rollout *types.Rollout) error {
+	metrics, err := s.metricStore.GetRequestMetrics(endpoint.ID)
+	if err != nil {
+		return err
+	}
+	var served []types.RequestMetric
+	for _, metric := range metrics {
+		if metric.DeploymentID == rollout.DeploymentID {
+			served = append(served, metric)
+		}
+	}
+	summary := types.SummarizeRequests(served, rollout.UpdatedAT)
+	if err := rollout.Check(summary); err != nil {
+		return s.rollBackRollout(endpoint, rollout.Finish(types.RolloutRolledBack, err.Error()), rollout.Actor)
+	}
+	if !rollout.LastStep() {
+		return s.storeRolloutStep(endpoint, rollout.Next())
+	}
+	deploy, err := s.store.GetDeployment(rollout.DeploymentID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.activate(types.DeploymentEventPublish, endpoint, deploy, rollout.Actor); err != nil {
+		return err
+	}
+	return s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Rollout: rollout.Finish(types.RolloutCompleted, ""),
+	})
+}
+
+// storeRolloutStep stores the rollout and records its current step in the
+// history of the endpoint.
+func (s *Server) storeRolloutStep(endpoint *types.Endpoint, rollout *types.Rollout) error {
+	if err := s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{Rollout: rollout}); err != nil {
+		return err
+	}
+	event := types.NewDeploymentEvent(types.DeploymentEventRolloutStep, endpoint, rollout.DeploymentID, rollout.Actor)
+	event.Weight = rollout.Weight()
+	return s.store.CreateDeploymentEvent(event)
+}
+
+// rollBackRollout stores the rolled back rollout, which shifts all the
+// traffic back to the active deployment, and records the rollback in the
+// history of the endpoint.
+func (s *Server) rollBackRollout(endpoint *types.Endpoint, rollout *types.Rollout, actor string) error {
+	if err := s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{Rollout: rollout}); err != nil {
+		return err
+	}
+	slog.Warn("rolled back a rollout", "endpoint", endpoint.ID, "deployment", rollout.DeploymentID, "reason", rollout.Reason)
+	event := types.NewDeploymentEvent(types.DeploymentEventRolloutRollback, endpoint, rollout.DeploymentID, actor)
+	event.Reason = rollout.Reason
+	if err := s.store.CreateDeploymentEvent(event); err != nil {
+		return err
+	}
+	s.notifier.Emit(types.NewEvent(types.EventRollback, endpoint.ID, rollout.DeploymentID, actor, rollout.Reason))
+	return nil
+}
+
+// resumeRollouts continues the rollouts that were running when the server
+// stopped.
+func (s *Server) resumeRollouts() {
+	endpoints, err := s.store.GetEndpoints()
+	if err != nil {
+		slog.Error("failed to resume the rollouts", "err", err)
+		return
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.Rollout.Running() {
+			go s.runRollout(endpoint.ID, endpoint.Rollout.ID)
+		}
+	}
+}
+
+func (s *Server) handleGetRollout(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if endpoint.Rollout == nil {
+		err := fmt.Errorf("endpoint (%s) does not have a rollout", endpointID)
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, endpoint.Rollout)
+}
+
+// handleAbortRollout shifts all the traffic of the running rollout back to
+// the active deployment of the endpoint.
+func (s *Server) handleAbortRollout(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if !endpoint.Rollout.Running() {
+		err := fmt.Errorf("endpoint (%s) does not have a rollout in progress", endpointID)
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	rollout := endpoint.Rollout.Finish(types.RolloutRolledBack, "aborted")
+	if err := s.rollBackRollout(endpoint, rollout, actor(r)); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, rollout)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxScheduleDelay is how far ahead an invocation can be scheduled.
+const maxScheduleDelay = time.Hour * 24 * 365
+
+// handleScheduleInvocation schedules an invocation of the LIVE deployment of
+// the endpoint at the time of the at query parameter (RFC 3339) or after the
+// delay of the delay query parameter, like 10m. The request is given like the
+// asynchronous invocations, the invocation is fired once and its result is
+// polled with GET /invocation/{id} of the invocation_id.
+func (s *Server) handleScheduleInvocation(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	runAT, err := scheduleTime(r, time.Now())
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	req, status, err := readAsyncRequest(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	scheduled := types.NewScheduledInvocation(endpoint.ID, req, runAT)
+	if err := s.store.CreateScheduledInvocation(scheduled); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusCreated, scheduled)
+}
+
+// scheduleTime returns the time of the scheduled invocation given by the at
+// or the delay query parameter of the call.
+func scheduleTime(r *http.Request, now time.Time) (time.Time, error) {
+	query := r.URL.Query()
+	at, delay := query.Get("at"), query.Get("delay")
+	var runAT time.Time
+	switch {
+	case len(at) > 0 && len(delay) > 0:
+		return runAT, fmt.Errorf("provide either at or delay, not both")
+	case len(at) > 0:
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return runAT, fmt.Errorf("invalid at %q, expected an RFC 3339 time", at)
+		}
+		if t.Before(now) {
+			return runAT, fmt.Errorf("at %s is in the past", at)
+		}
+		runAT = t
+	case len(delay) > 0:
+		d, err := time.ParseDuration(delay)
+		if err != nil || d <= 0 {
+			return runAT, fmt.Errorf("invalid delay %q, expected a positive duration like 10m", delay)
+		}
+		runAT = now.Add(d)
+	default:
+		return runAT, fmt.Errorf("provide the time of the invocation with at or delay")
+	}
+	if runAT.Sub(now) > maxScheduleDelay {
+		return runAT, fmt.Errorf("invocations can be scheduled at most %s ahead", maxScheduleDelay)
+	}
+	return runAT, nil
+}
+
+func (s *Server) handleGetScheduledInvocations(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	scheduled, err := s.store.GetScheduledInvocations(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, scheduled)
+}
+
+// handleCancelScheduledInvocation cancels a pending scheduled invocation of
+// the endpoint, the invocations that were fired can not be canceled.
+func (s *Server) handleCancelScheduledInvocation(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	scheduleID, err := uuid.Parse(chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	scheduled, err := s.store.GetScheduledInvocation(scheduleID)
+	if err != nil || scheduled.EndpointID != endpointID {
+		err := fmt.Errorf("could not find scheduled invocation with id (%s)", scheduleID)
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if scheduled.Status != types.SchedulePending {
+		err := fmt.Errorf("scheduled invocation (%s) is %s", scheduleID, scheduled.Status)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	// The scheduler can fire the invocation in the meantime, it is only
+	// canceled while it is still pending.
+	scheduled.Status = types.ScheduleCanceled
+	canceled, err := s.store.UpdateScheduledInvocationStatus(scheduled, types.SchedulePending)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if !canceled {
+		current, err := s.store.GetScheduledInvocation(scheduleID)
+		if err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		err = fmt.Errorf("scheduled invocation (%s) is %s", scheduleID, current.Status)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, scheduled)
+}
+
+// RunSchedules fires the scheduled invocations that are due once per
+// interval, until the context is done.
+func (s *Server) RunSchedules(ctx context.Context, interval time.Duration) {
+	s.scheduler.Run(ctx, interval)
+}
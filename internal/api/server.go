@@ -1,15 +1,34 @@
 package api
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/anthdm/raptor/internal/artifact"
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/build"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/policy"
+	"github.com/anthdm/raptor/internal/quota"
+	"github.com/anthdm/raptor/internal/recommend"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/internal/workflow"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -20,36 +39,211 @@ type Server struct {
 	store       storage.Store
 	metricStore storage.MetricStore
 	cache       storage.ModCacher
+	compiler    *runtime.Compiler
+	policy      *policy.Engine
+	health      config.Health
+	latencies   *storage.Latencies
+	keys        storage.KeyRotator
+	verifier    *signing.Verifier
+	builder     *build.Builder
+	fetcher     *artifact.Fetcher
+	status      *statusPage
+	notifier    *notify.Notifier
+	// API keys by key, nil when the API does not require keys.
+	apiKeys map[string]config.APIKey
+	quotas  *quota.Checker
+	invoker *async.Invoker
+	// Runs the workflows and fires the scheduled invocations with the
+	// invoker.
+	workflows *workflow.Runner
+	scheduler *async.Scheduler
+	// URL of the ingress the captured requests are replayed through.
+	ingressURL string
+	// Store the endpoints are backed up from and restored into.
+	backupStore storage.Store
+	// Serializes the conditional updates and the deletes of the endpoints,
+	// so two of them cannot both match the same version.
+	updateMu sync.Mutex
 }
 
 // NewServer returns a new server given a Store interface.
-func NewServer(store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher) *Server {
-	return &Server{
+func NewServer(store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher, policy *policy.Engine) *Server {
+	s := &Server{
 		store:       store,
 		cache:       cache,
+		compiler:    runtime.NewCompiler(cache),
 		metricStore: metricStore,
+		policy:      policy,
+		fetcher:     artifact.NewFetcher(config.Get().Limits.MaxBlobSize),
+		status:      newStatusPage(config.Status{}),
+		notifier:    notify.New(store),
+		quotas:      quota.New(config.Get().Quotas, store, metricStore),
+		invoker:     async.New(store, config.IngressUrl(), config.Get().Async),
+		ingressURL:  config.IngressUrl(),
+		backupStore: store,
 	}
+	s.workflows = workflow.NewRunner(store, s.invoker, config.Get().Workflows)
+	s.scheduler = async.NewScheduler(store, s.invoker)
+	if config.Get().Authorization {
+		s.WithAuthorization(config.Get().APIToken, config.Get().APIKeys)
+	}
+	return s
+}
+
+// WithStoreLatencies exposes the latencies of the store operations recorded
+// by the instrumented stores of the server.
+func (s *Server) WithStoreLatencies(latencies *storage.Latencies) *Server {
+	s.latencies = latencies
+	return s
+}
+
+// WithCompiler compiles the deployments the API server checks with the
+// compiler of the member it runs on, so they are not compiled twice.
+func (s *Server) WithCompiler(compiler *runtime.Compiler) *Server {
+	s.compiler = compiler
+	return s
+}
+
+// WithKeyRotator enables the rotation of the data keys of the endpoints
+// through the given store.
+func (s *Server) WithKeyRotator(keys storage.KeyRotator) *Server {
+	s.keys = keys
+	return s
+}
+
+// WithSigning rejects the deployments with a signature that is not made by
+// one of the keys trusted by the verifier, and the unsigned deployments when
+// signatures are required.
+func (s *Server) WithSigning(verifier *signing.Verifier) *Server {
+	s.verifier = verifier
+	return s
+}
+
+// WithBuilder accepts source archives that are compiled to WASM by the
+// given builder before they are deployed.
+func (s *Server) WithBuilder(builder *build.Builder) *Server {
+	s.builder = builder
+	return s
+}
+
+// WithQuotas limits the resources of the projects to the given quotas
+// instead of the quotas of the config.
+func (s *Server) WithQuotas(quotas []config.Quota) *Server {
+	s.quotas = quota.New(quotas, s.store, s.metricStore)
+	return s
+}
+
+// WithInvoker performs the asynchronous invocations, like the re-drives of
+// the dead letters, with the given invoker.
+func (s *Server) WithInvoker(invoker *async.Invoker) *Server {
+	s.invoker = invoker
+	s.workflows = workflow.NewRunner(s.store, invoker, config.Get().Workflows)
+	s.scheduler = async.NewScheduler(s.store, invoker)
+	return s
+}
+
+// WithIngressURL replays the captured requests through the ingress at the
+// given URL instead of the ingress of the config.
+func (s *Server) WithIngressURL(url string) *Server {
+	s.ingressURL = url
+	return s
 }
 
 // Listen starts listening on the given address.
 func (s *Server) Listen(addr string) error {
 	s.initRouter()
+	s.resumeRollouts()
 	return http.ListenAndServe(addr, s.router)
 }
 
+// Handler returns the handler of the API, for serving it with a server of
+// the caller like an httptest.Server.
+func (s *Server) Handler() http.Handler {
+	s.initRouter()
+	return s.router
+}
+
 func (s *Server) initRouter() {
 	s.router = chi.NewRouter()
-	if config.Get().Authorization {
-		s.router.Use(s.withAPIToken)
+	// The platform status is public so that users can tell whether an issue
+	// is theirs or the platform's.
+	s.router.Get("/platform", makeAPIHandler(s.handlePlatformStatusPage))
+	s.router.Get("/platform/status", makeAPIHandler(s.handleGetPlatformStatus))
+	s.router.Group(s.initAPIRoutes)
+	s.router.Route("/"+APIVersion, func(r chi.Router) {
+		// The middlewares of a group see the full pattern of the route.
+		r.Group(s.initAPIRoutes)
+	})
+}
+
+func (s *Server) initAPIRoutes(r chi.Router) {
+	if s.apiKeys != nil {
+		r.Use(s.withAPIToken)
 	}
-	s.router.Get("/status", handleStatus)
-	s.router.Get("/endpoint/{id}", makeAPIHandler(s.handleGetEndpoint))
-	s.router.Get("/endpoint", makeAPIHandler(s.handleGetEndpoints))
-	s.router.Get("/endpoint/{id}/metrics", makeAPIHandler(s.handleGetEndpointMetrics))
-	s.router.Post("/endpoint", makeAPIHandler(s.handleCreateEndpoint))
-	s.router.Post("/endpoint/{id}/deployment", makeAPIHandler(s.handleCreateDeployment))
-	s.router.Put("/endpoint/{id}", makeAPIHandler(s.handleUpdateEndpoint))
-	s.router.Post("/publish", makeAPIHandler(s.handlePublish))
+	r.Use(s.withAudit)
+	r.Get("/status", handleStatus)
+	r.Get("/audit", makeAPIHandler(s.handleGetAuditLog))
+	r.Get("/usage", makeAPIHandler(s.handleGetUsage))
+	r.Get("/metrics/store", makeAPIHandler(s.handleGetStoreMetrics))
+	r.Get("/config", makeAPIHandler(s.handleGetConfig))
+	r.Get("/backup", makeAPIHandler(s.handleGetBackup))
+	r.Post("/restore", makeAPIHandler(s.handleRestore))
+	r.Get("/endpoint/{id}", makeAPIHandler(s.handleGetEndpoint))
+	r.Get("/endpoint", makeAPIHandler(s.handleGetEndpoints))
+	r.Get("/endpoint/{id}/metrics", makeAPIHandler(s.handleGetEndpointMetrics))
+	r.Get("/endpoint/{id}/metrics/rollups", makeAPIHandler(s.handleGetEndpointRollups))
+	r.Get("/endpoint/{id}/recommendation", makeAPIHandler(s.handleGetEndpointRecommendation))
+	r.Get("/endpoint/{id}/probes", makeAPIHandler(s.handleGetEndpointProbes))
+	r.Get("/endpoint/{id}/concurrency", makeAPIHandler(s.handleGetEndpointConcurrency))
+	r.Get("/endpoint/{id}/snapshot", makeAPIHandler(s.handleGetEndpointSnapshot))
+	r.Post("/endpoint", makeAPIHandler(s.handleCreateEndpoint))
+	r.Get("/endpoint/{id}/deployment", makeAPIHandler(s.handleGetDeployments))
+	r.Post("/endpoint/{id}/deployment", makeAPIHandler(s.handleCreateDeployment))
+	r.Post("/endpoint/{id}/deployment/prune", makeAPIHandler(s.handlePruneDeployments))
+	r.Post("/endpoint/{id}/deployment/pull", makeAPIHandler(s.handlePullDeployment))
+	r.Post("/endpoint/{id}/release", makeAPIHandler(s.handleRelease))
+	r.Get("/endpoint/{id}/deployment/{deployID}", makeAPIHandler(s.handleGetDeployment))
+	r.Delete("/endpoint/{id}/deployment/{deployID}", makeAPIHandler(s.handleDeleteDeployment))
+	r.Post("/endpoint/{id}/build", makeAPIHandler(s.handleCreateBuild))
+	r.Get("/build/{id}", makeAPIHandler(s.handleGetBuild))
+	r.Post("/endpoint/{id}/invoke", makeAPIHandler(s.handleInvoke))
+	r.Get("/invocation/{id}", makeAPIHandler(s.handleGetInvocation))
+	r.Post("/endpoint/{id}/workflow", makeAPIHandler(s.handleStartWorkflow))
+	r.Post("/endpoint/{id}/schedule", makeAPIHandler(s.handleScheduleInvocation))
+	r.Get("/endpoint/{id}/schedule", makeAPIHandler(s.handleGetScheduledInvocations))
+	r.Delete("/endpoint/{id}/schedule/{scheduleID}", makeAPIHandler(s.handleCancelScheduledInvocation))
+	r.Get("/workflow/{id}", makeAPIHandler(s.handleGetWorkflow))
+	r.Get("/project/{project}/environment", makeAPIHandler(s.handleGetProjectEnvironment))
+	r.Put("/project/{project}/environment", makeAPIHandler(s.handleUpdateProjectEnvironment))
+	r.Get("/invocation/{id}/request", makeAPIHandler(s.handleGetCapturedRequest))
+	r.Post("/invocation/{id}/replay", makeAPIHandler(s.handleReplay))
+	r.Get("/endpoint/{id}/captures", makeAPIHandler(s.handleGetCaptures))
+	r.Put("/endpoint/{id}", makeAPIHandler(s.handleUpdateEndpoint))
+	r.Delete("/endpoint/{id}", makeAPIHandler(s.handleDeleteEndpoint))
+	r.Post("/endpoint/{id}/keys/rotate", makeAPIHandler(s.handleRotateEndpointKey))
+	r.Post("/keys/rewrap", makeAPIHandler(s.handleRewrapDataKeys))
+	r.Get("/endpoint/{id}/environment/drift", makeAPIHandler(s.handleGetEnvironmentDrift))
+	r.Get("/endpoint/{id}/cache", makeAPIHandler(s.handleGetEndpointCache))
+	r.Post("/endpoint/{id}/cache/purge", makeAPIHandler(s.handlePurgeEndpointCache))
+	r.Get("/endpoint/{id}/history", makeAPIHandler(s.handleGetEndpointHistory))
+	r.Get("/endpoint/{id}/describe", makeAPIHandler(s.handleDescribeEndpoint))
+	r.Get("/endpoint/{id}/health", makeAPIHandler(s.handleGetEndpointHealth))
+	r.Post("/endpoint/{id}/rollback", makeAPIHandler(s.handleRollback))
+	r.Get("/endpoint/{id}/rollout", makeAPIHandler(s.handleGetRollout))
+	r.Get("/endpoint/{id}/events", makeAPIHandler(s.handleGetEvents))
+	r.Get("/endpoint/{id}/errors", makeAPIHandler(s.handleGetErrors))
+	r.Get("/endpoint/{id}/dead-letters", makeAPIHandler(s.handleGetDeadLetters))
+	r.Post("/endpoint/{id}/dead-letters/{letterID}/redrive", makeAPIHandler(s.handleRedriveDeadLetter))
+	r.Delete("/endpoint/{id}/dead-letters/{letterID}", makeAPIHandler(s.handleDeleteDeadLetter))
+	r.Get("/endpoint/{id}/webhook", makeAPIHandler(s.handleGetWebhooks))
+	r.Post("/endpoint/{id}/webhook", makeAPIHandler(s.handleCreateWebhook))
+	r.Delete("/endpoint/{id}/webhook/{webhookID}", makeAPIHandler(s.handleDeleteWebhook))
+	r.Post("/endpoint/{id}/rollout/abort", makeAPIHandler(s.handleAbortRollout))
+	r.Post("/endpoint/{id}/transfer", makeAPIHandler(s.handleTransfer))
+	r.Post("/endpoint/{id}/transfer/accept", makeAPIHandler(s.handleAcceptTransfer))
+	r.Post("/publish", makeAPIHandler(s.handlePublish))
+	r.Post("/platform/incident", makeAPIHandler(s.handleCreateIncident))
+	r.Post("/platform/incident/{id}/resolve", makeAPIHandler(s.handleResolveIncident))
 }
 
 func handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -63,12 +257,17 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 
 // CreateEndpointParams holds all the necessary fields to create a new run application.
 type CreateEndpointParams struct {
+	// Optional ID of the endpoint, chosen by the client so that a retried
+	// create does not create the endpoint twice.
+	ID uuid.UUID `json:"id"`
 	// Name of the endpoint
 	Name string `json:"name"`
 	// Runtime on which the code will be invoked. (go or js for now)
 	Runtime string `json:"runtime"`
 	// A map of environment variables
 	Environment map[string]string `json:"environment"`
+	// Optional owner of the endpoint, a team or a project.
+	Owner string `json:"owner"`
 }
 
 func (p CreateEndpointParams) validate() error {
@@ -82,41 +281,367 @@ func (p CreateEndpointParams) validate() error {
 	if _, ok := types.Runtimes[p.Runtime]; !ok {
 		return fmt.Errorf("invalid runtime given: %s", p.Runtime)
 	}
+	if len(p.Owner) > 0 {
+		return types.ValidateOwner(p.Owner)
+	}
 	return nil
 }
 
+// Modes of the environment of an endpoint update.
+const (
+	// The given variables are added to the environment, the others are kept.
+	EnvironmentMerge = "merge"
+	// The given variables replace the environment as a whole.
+	EnvironmentReplace = "replace"
+)
+
 type UpdateEndpointParams struct {
-	Environment map[string]string `json:"environment"`
+	// New name of the endpoint, the name is kept when empty.
+	Name string `json:"name"`
+	// New runtime of the endpoint, the runtime can only be changed while
+	// the endpoint has no LIVE deployment.
+	Runtime string `json:"runtime"`
+	// Environment variables to set, a null value deletes the variable.
+	Environment map[string]*string `json:"environment"`
+	// How the environment is applied, merge (the default) or replace.
+	EnvironmentMode string                 `json:"environment_mode"`
+	SessionAffinity *types.SessionAffinity `json:"session_affinity"`
+	// Regions the runtimes of the endpoint are preferably activated in.
+	Regions []string `json:"regions"`
+	// Maximum amount of concurrent requests, 0 means unlimited.
+	MaxConcurrency *int `json:"max_concurrency"`
+	// Synthetic checks executed against the LIVE deployment.
+	Probes []types.Probe `json:"probes"`
+	// Responses for specific paths answered at the ingress, like /favicon.ico.
+	StaticResponses []types.StaticResponse `json:"static_responses"`
+	// Path prefixes routed to the LIVE deployment of other endpoints.
+	Routes []types.Route `json:"routes"`
+	// Deprecation notice sent along with the LIVE responses. An empty
+	// deprecation removes the notice.
+	Deprecation *types.Deprecation `json:"deprecation"`
+	// Caching of the LIVE responses at the ingress. The generation of the
+	// cache is managed by the purge API and cannot be set.
+	Cache *types.ResponseCache `json:"cache"`
+	// Compression of the responses for the clients that accept it.
+	Compression *types.Compression `json:"compression"`
+	// Resource limits of the invocations, bounded by the limits of the
+	// installation.
+	Limits *types.Limits `json:"limits"`
+	// WebSocket upgrades of the requests to the endpoint.
+	WebSocket *types.WebSocket `json:"websocket"`
+	// Origin the LIVE requests are proxied to when the cluster can not serve
+	// them. An empty url removes the fallback.
+	Fallback *types.Fallback `json:"fallback"`
+	// Deployments kept by the garbage collection. The zero policy keeps
+	// all the deployments.
+	Retention *types.RetentionPolicy `json:"retention"`
+	// Environment variables required to publish a deployment. The schema
+	// replaces the current one as a whole, an empty schema removes it.
+	EnvironmentSchema *types.EnvironmentSchema `json:"environment_schema"`
+	// Retries of the asynchronous invocations before their request is
+	// moved to the dead-letter queue.
+	Retry *types.RetryPolicy `json:"retry"`
+	// Recording of a sample of the LIVE requests so they can be replayed, a
+	// zero sample rate stops the recording.
+	Capture *types.Capture `json:"capture"`
+	// CORS policy of the endpoint, a policy without allowed origins
+	// disables it.
+	CORS *types.CORS `json:"cors"`
+	// Verification of the signature of the requests at the ingress, a
+	// verification without a secret disables it.
+	Verification *types.RequestVerification `json:"verification"`
+	// Validation of the JSON Web Token of the requests at the ingress, a
+	// policy without a JWKS URL disables it.
+	JWT *types.JWTPolicy `json:"jwt"`
+	// Maintenance of the endpoint, the ingress answers its LIVE requests
+	// with a 503 while it is disabled.
+	Maintenance *types.Maintenance `json:"maintenance"`
+	// Runtimes kept warm on every ingress, ahead of the requests. Settings
+	// without instances and schedules turn it off.
+	Prewarm *types.Prewarm `json:"prewarm"`
 }
 
+const maxStaticResponseSize = 64 << 10
+
+func (p UpdateEndpointParams) validate() error {
+	if len(p.Name) > 0 {
+		if err := (CreateEndpointParams{Name: p.Name, Runtime: "go"}).validate(); err != nil {
+			return err
+		}
+	}
+	if len(p.Runtime) > 0 && !types.ValidRuntime(p.Runtime) {
+		return fmt.Errorf("invalid runtime given: %s", p.Runtime)
+	}
+	switch p.EnvironmentMode {
+	case "", EnvironmentMerge, EnvironmentReplace:
+	default:
+		return fmt.Errorf("invalid environment mode %q, expected merge or replace", p.EnvironmentMode)
+	}
+	if p.SessionAffinity != nil {
+		if len(p.SessionAffinity.Header) == 0 && len(p.SessionAffinity.Cookie) == 0 {
+			return fmt.Errorf("session affinity requires a header or a cookie")
+		}
+		if p.SessionAffinity.Slots < 0 {
+			return fmt.Errorf("session affinity slots cannot be negative")
+		}
+	}
+	for _, region := range p.Regions {
+		if len(region) == 0 {
+			return fmt.Errorf("invalid empty region")
+		}
+	}
+	if p.MaxConcurrency != nil && *p.MaxConcurrency < 0 {
+		return fmt.Errorf("max concurrency cannot be negative")
+	}
+	paths := make(map[string]bool, len(p.Probes))
+	for _, probe := range p.Probes {
+		if !strings.HasPrefix(probe.Path, "/") {
+			return fmt.Errorf("probe path should start with a slash: %s", probe.Path)
+		}
+		if paths[probe.Path] {
+			return fmt.Errorf("duplicate probe path: %s", probe.Path)
+		}
+		paths[probe.Path] = true
+		if probe.Interval < types.MinProbeInterval {
+			return fmt.Errorf("probe interval should be at least %d seconds", types.MinProbeInterval)
+		}
+		if probe.ExpectedStatus < 100 || probe.ExpectedStatus > 599 {
+			return fmt.Errorf("invalid probe expected status: %d", probe.ExpectedStatus)
+		}
+	}
+	paths = make(map[string]bool, len(p.StaticResponses))
+	for _, resp := range p.StaticResponses {
+		if !strings.HasPrefix(resp.Path, "/") {
+			return fmt.Errorf("static response path should start with a slash: %s", resp.Path)
+		}
+		if paths[resp.Path] {
+			return fmt.Errorf("duplicate static response path: %s", resp.Path)
+		}
+		paths[resp.Path] = true
+		if resp.StatusCode < 100 || resp.StatusCode > 599 {
+			return fmt.Errorf("invalid static response status code: %d", resp.StatusCode)
+		}
+		if len(resp.ContentType) == 0 {
+			return fmt.Errorf("static response for %s requires a content type", resp.Path)
+		}
+		if len(resp.Body) > maxStaticResponseSize {
+			return fmt.Errorf("static response body can be maximum %d bytes", maxStaticResponseSize)
+		}
+	}
+	prefixes := make(map[string]bool, len(p.Routes))
+	for _, route := range p.Routes {
+		if !strings.HasPrefix(route.Prefix, "/") || len(route.Prefix) < 2 || strings.HasSuffix(route.Prefix, "/") {
+			return fmt.Errorf("invalid route prefix: %s", route.Prefix)
+		}
+		if prefixes[route.Prefix] {
+			return fmt.Errorf("duplicate route prefix: %s", route.Prefix)
+		}
+		prefixes[route.Prefix] = true
+	}
+	if d := p.Deprecation; d != nil {
+		if !d.Date.IsZero() && !d.Sunset.IsZero() && d.Sunset.Before(d.Date) {
+			return fmt.Errorf("deprecation sunset cannot be before the deprecation date")
+		}
+		if len(d.Link) > 0 {
+			if u, err := url.Parse(d.Link); err != nil || !u.IsAbs() {
+				return fmt.Errorf("invalid deprecation link: %s", d.Link)
+			}
+		}
+	}
+	if p.Cache != nil {
+		if err := p.Cache.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Compression != nil {
+		if err := p.Compression.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Limits != nil {
+		if err := p.Limits.Validate(shared.GlobalLimits()); err != nil {
+			return err
+		}
+	}
+	if p.WebSocket != nil {
+		if err := p.WebSocket.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Fallback != nil {
+		if err := p.Fallback.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Retention != nil {
+		if err := p.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.EnvironmentSchema != nil {
+		if err := p.EnvironmentSchema.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Retry != nil {
+		if err := p.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Capture != nil {
+		if err := p.Capture.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.CORS != nil {
+		if err := p.CORS.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Verification != nil {
+		if err := p.Verification.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.JWT != nil {
+		if err := p.JWT.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Maintenance != nil {
+		if err := p.Maintenance.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Prewarm != nil {
+		if err := p.Prewarm.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleUpdateEndpoint updates the settings of the endpoint. With an
+// If-Match header the endpoint is only updated if it was not changed since
+// it was read.
 func (s *Server) handleUpdateEndpoint(w http.ResponseWriter, r *http.Request) error {
 	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	if len(r.Header.Get("If-Match")) > 0 {
+		s.updateMu.Lock()
+		defer s.updateMu.Unlock()
+	}
 	endpoint, err := s.store.GetEndpoint(endpointID)
 	if err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
+	if status, err := checkIfMatch(r, endpoint); err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
 	var params UpdateEndpointParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
 	defer r.Body.Close()
-	if len(params.Environment) > 0 {
-		for k, v := range params.Environment {
-			endpoint.Environment[k] = v
+	if err := params.validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.validateRoutes(endpoint, params.Routes); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if len(params.Runtime) > 0 && params.Runtime != endpoint.Runtime && endpoint.HasActiveDeploy() {
+		err := fmt.Errorf("cannot change the runtime of endpoint (%s) while it has a LIVE deployment", endpoint.ID)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	var env map[string]string
+	if len(params.Environment) > 0 || params.EnvironmentMode == EnvironmentReplace {
+		env = updateEnvironment(endpoint.Environment, params.Environment, params.EnvironmentMode)
+	}
+	if params.Cache != nil {
+		params.Cache.Generation = 0
+		if endpoint.Cache != nil {
+			params.Cache.Generation = endpoint.Cache.Generation
 		}
 	}
 	updateParams := storage.UpdateEndpointParams{
-		Environment: endpoint.Environment,
+		Name:              params.Name,
+		Runtime:           params.Runtime,
+		Environment:       env,
+		SessionAffinity:   params.SessionAffinity,
+		Regions:           params.Regions,
+		MaxConcurrency:    params.MaxConcurrency,
+		Probes:            params.Probes,
+		StaticResponses:   params.StaticResponses,
+		Routes:            params.Routes,
+		Deprecation:       params.Deprecation,
+		Cache:             params.Cache,
+		Compression:       params.Compression,
+		Limits:            params.Limits,
+		WebSocket:         params.WebSocket,
+		Fallback:          params.Fallback,
+		Retention:         params.Retention,
+		EnvironmentSchema: params.EnvironmentSchema,
+		Retry:             params.Retry,
+		Capture:           params.Capture,
+		CORS:              params.CORS,
+		Verification:      params.Verification,
+		JWT:               params.JWT,
+		Maintenance:       params.Maintenance,
+		Prewarm:           params.Prewarm,
 	}
 	if err := s.store.UpdateEndpoint(endpointID, updateParams); err != nil {
 		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
 	}
+	if updated, err := s.store.GetEndpoint(endpointID); err == nil {
+		if err := setETag(w, r, updated); err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+	}
 	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
 }
 
+// updateEnvironment returns the environment after the update, the current
+// environment is never modified.
+func updateEnvironment(current map[string]string, update map[string]*string, mode string) map[string]string {
+	env := make(map[string]string, len(current)+len(update))
+	if mode != EnvironmentReplace {
+		for k, v := range current {
+			env[k] = v
+		}
+	}
+	for k, v := range update {
+		if v == nil {
+			delete(env, k)
+			continue
+		}
+		env[k] = *v
+	}
+	return env
+}
+
+// validateRoutes makes sure all the routes of the composite endpoint target
+// existing endpoints of the same project that are not composite themselves.
+func (s *Server) validateRoutes(endpoint *types.Endpoint, routes []types.Route) error {
+	for _, route := range routes {
+		if route.EndpointID == endpoint.ID {
+			return fmt.Errorf("route %s cannot target the endpoint itself", route.Prefix)
+		}
+		target, err := s.store.GetEndpoint(route.EndpointID)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", route.Prefix, err)
+		}
+		if len(target.Routes) > 0 {
+			return fmt.Errorf("route %s cannot target the composite endpoint (%s)", route.Prefix, target.ID)
+		}
+		if target.Owner() != endpoint.Owner() {
+			return fmt.Errorf("route %s cannot target endpoint (%s) of another project", route.Prefix, target.ID)
+		}
+	}
+	return nil
+}
+
 func (s *Server) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) error {
 	var params CreateEndpointParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
@@ -128,15 +653,59 @@ func (s *Server) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) er
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
 
+	// Keys of a project create the endpoints of their project.
+	if key := apiKey(r); key != nil && len(key.Project) > 0 {
+		if len(params.Owner) > 0 && params.Owner != key.Project {
+			err := fmt.Errorf("%w: the key of project %q cannot create endpoints of %q", errForbidden, key.Project, params.Owner)
+			return writeJSON(w, http.StatusForbidden, ErrorResponse(err))
+		}
+		params.Owner = key.Project
+	}
+	if err := s.quotas.CheckEndpoints(params.Owner); err != nil {
+		return writeJSON(w, quotaStatus(err), ErrorResponse(err))
+	}
 	endpoint := types.NewEndpoint(params.Name, params.Runtime, params.Environment)
+	if len(params.Owner) > 0 {
+		endpoint.Ownership = &types.Ownership{Owner: params.Owner}
+	}
+	if params.ID != uuid.Nil {
+		// Creating the same endpoint again returns it, so the create can be
+		// retried.
+		if existing, err := s.store.GetEndpoint(params.ID); err == nil {
+			if existing.Name != params.Name || existing.Runtime != params.Runtime || existing.Owner() != params.Owner {
+				err := fmt.Errorf("endpoint (%s) already exists", params.ID)
+				return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+			}
+			if err := setETag(w, r, existing); err != nil {
+				return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+			}
+			return writeJSON(w, http.StatusOK, withoutSecrets(r, existing))
+		}
+		endpoint.ID = params.ID
+	}
 	if err := s.store.CreateEndpoint(endpoint); err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	if err := setETag(w, r, endpoint); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
 	return writeJSON(w, http.StatusOK, endpoint)
 }
 
 // CreateDeploymentParams holds all the necessary fields to deploy a new function.
-type CreateDeploymentParams struct{}
+type CreateDeploymentParams struct {
+	// Optional zip archive with static assets served along with the function.
+	Assets []byte `json:"-"`
+	// Always create a new deployment, even when the endpoint already has an
+	// identical one. Sent as the dedupe=false query parameter.
+	NoDedupe bool `json:"-"`
+	// Labels, git commit and description of the deployment. Sent as the
+	// JSON encoded "metadata" part of the multipart form.
+	Metadata types.DeploymentMetadata `json:"-"`
+	// Ed25519 signature of the blob. Sent as the base64 encoded "signature"
+	// part of the multipart form.
+	Signature []byte `json:"-"`
+}
 
 func (s *Server) handleCreateDeployment(w http.ResponseWriter, r *http.Request) error {
 	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -151,19 +720,235 @@ func (s *Server) handleCreateDeployment(w http.ResponseWriter, r *http.Request)
 	// TODO:
 	// 1. validate the contents of the blob.
 	// 2. make sure we have a limit on the maximum blob size.
-	b, err := io.ReadAll(r.Body)
+	body, err := readDeploymentBody(r)
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	deploy, _, status, err := s.createDeployment(r, endpoint, body, r.URL.Query().Get("dedupe") != "false")
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, deploy)
+}
+
+// createDeployment creates the deployment of the body after it passed the
+// checks and returns it with its preview URL. With dedupe the identical
+// deployment of the endpoint is returned instead, if there is one. It
+// reports whether the deployment was created and the status code of the
+// failure.
+func (s *Server) createDeployment(r *http.Request, endpoint *types.Endpoint, body deploymentBody, dedupe bool) (*types.Deployment, bool, int, error) {
+	b, assetBundle := body.blob, body.assets
+	if err := body.metadata.Validate(); err != nil {
+		return nil, false, http.StatusBadRequest, err
+	}
 	if len(b) == 0 {
-		err := fmt.Errorf("no blob")
-		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		return nil, false, http.StatusBadRequest, fmt.Errorf("no blob")
+	}
+	if err := signing.ValidateSignature(body.signature); err != nil {
+		return nil, false, http.StatusBadRequest, err
+	}
+	if len(assetBundle) > 0 {
+		if err := assets.Validate(assetBundle); err != nil {
+			return nil, false, http.StatusUnprocessableEntity, err
+		}
 	}
 	deploy := types.NewDeployment(endpoint, b)
+	deploy.SetAssets(assetBundle)
+	deploy.DeploymentMetadata = body.metadata
+	deploy.Signature = body.signature
+	if status, err := s.checkDeployment(endpoint, deploy); err != nil {
+		return nil, false, status, err
+	}
+	// Identical re-deploys, common in CI, return the existing deployment.
+	if dedupe {
+		existing, err := s.findIdenticalDeployment(deploy)
+		if err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+		if existing != nil {
+			existing.PreviewURL = previewURL(existing.ID)
+			return existing, false, http.StatusOK, nil
+		}
+	}
+	if err := s.quotas.CheckDeployments(endpoint.Owner()); err != nil {
+		return nil, false, quotaStatus(err), err
+	}
 	if err := s.store.CreateDeployment(deploy); err != nil {
-		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		return nil, false, http.StatusUnprocessableEntity, err
 	}
-	return writeJSON(w, http.StatusOK, deploy)
+	s.notifier.Emit(types.NewEvent(types.EventDeploymentCreated, endpoint.ID, deploy.ID, actor(r), ""))
+	deploy.PreviewURL = previewURL(deploy.ID)
+	return deploy, true, http.StatusOK, nil
+}
+
+// previewURL returns the URL the deployment is served at on the ingress,
+// whether it is published or not.
+func previewURL(deployID uuid.UUID) string {
+	return fmt.Sprintf("%s/preview/%s", config.IngressUrl(), deployID)
+}
+
+// checkDeployment checks the blob of a new deployment against the limits,
+// the trusted signing keys and the policy of the platform. It returns the
+// status code of the failed check.
+func (s *Server) checkDeployment(endpoint *types.Endpoint, deploy *types.Deployment) (int, error) {
+	if max := config.Get().Limits.MaxBlobSize; max > 0 && len(deploy.Blob) > max {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("blob exceeds the maximum of %d bytes", max)
+	}
+	if err := s.verifier.Verify(deploy.Blob, deploy.Signature); err != nil {
+		return http.StatusForbidden, err
+	}
+	if runtime.IsComponent(deploy.Blob) {
+		return http.StatusUnprocessableEntity, runtime.ErrComponentNotSupported
+	}
+	err := s.policy.Evaluate(policy.Input{
+		Action:     policy.ActionDeploy,
+		Endpoint:   endpoint,
+		Deployment: deploy,
+	})
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	return http.StatusOK, nil
+}
+
+// findIdenticalDeployment returns the most recent deployment of the endpoint
+// with the same blob, assets, source, metadata and signature as the given deployment, nil if
+// there is none.
+func (s *Server) findIdenticalDeployment(deploy *types.Deployment) (*types.Deployment, error) {
+	deploys, err := s.store.GetDeployments(deploy.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(deploys) - 1; i >= 0; i-- {
+		if deploys[i].Hash != deploy.Hash || deploys[i].HasAssets != deploy.HasAssets {
+			continue
+		}
+		if deploys[i].Source != deploy.Source {
+			continue
+		}
+		if !deploys[i].DeploymentMetadata.Equal(deploy.DeploymentMetadata) || !bytes.Equal(deploys[i].Signature, deploy.Signature) {
+			continue
+		}
+		existing, err := s.store.GetDeployment(deploys[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(existing.Assets, deploy.Assets) {
+			return existing, nil
+		}
+	}
+	return nil, nil
+}
+
+// deploymentBody holds the parts of the body of a new deployment.
+type deploymentBody struct {
+	blob      []byte
+	source    []byte
+	assets    []byte
+	metadata  types.DeploymentMetadata
+	signature []byte
+	// manifest of a release, see ReleaseManifest.
+	manifest []byte
+}
+
+// readDeploymentBody returns the blob, the optional asset bundle and the
+// optional metadata and signature of a new deployment. The body is either
+// the raw blob or a multipart form with a "blob" (or a "source" archive for
+// builds) and an "assets" file, a
+// "metadata" JSON document and a base64 encoded "signature".
+func readDeploymentBody(r *http.Request) (deploymentBody, error) {
+	var body deploymentBody
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		b, err := io.ReadAll(r.Body)
+		body.blob = b
+		return body, err
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return body, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return body, err
+		}
+		b, err := io.ReadAll(io.LimitReader(part, assets.MaxBundleSize+1))
+		if err != nil {
+			return body, err
+		}
+		if len(b) > assets.MaxBundleSize {
+			return body, fmt.Errorf("%s exceeds the maximum of %d bytes", part.FormName(), assets.MaxBundleSize)
+		}
+		switch part.FormName() {
+		case "blob":
+			body.blob = b
+		case "source":
+			body.source = b
+		case "assets":
+			body.assets = b
+		case "metadata":
+			if err := json.Unmarshal(b, &body.metadata); err != nil {
+				return body, fmt.Errorf("invalid deployment metadata: %s", err)
+			}
+		case "manifest":
+			body.manifest = b
+		case "signature":
+			if body.signature, err = base64.StdEncoding.DecodeString(string(b)); err != nil {
+				return body, fmt.Errorf("invalid deployment signature: %s", err)
+			}
+		}
+	}
+	return body, nil
+}
+
+func (s *Server) handleGetDeployments(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	filter, err := parseDeploymentFilter(r.URL.Query())
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	deploys, err := s.store.GetDeployments(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	matched := []types.Deployment{}
+	for _, deploy := range deploys {
+		if filter.Match(deploy) {
+			deploy.PreviewURL = previewURL(deploy.ID)
+			matched = append(matched, deploy)
+		}
+	}
+	return writeJSON(w, http.StatusOK, matched)
+}
+
+// parseDeploymentFilter parses the label (key=value, repeatable), commit and
+// branch query parameters of the deployment list.
+func parseDeploymentFilter(query url.Values) (types.DeploymentFilter, error) {
+	filter := types.DeploymentFilter{
+		GitCommit: query.Get("commit"),
+		GitBranch: query.Get("branch"),
+	}
+	for _, label := range query["label"] {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok {
+			return filter, fmt.Errorf("invalid label filter %q, expected key=value", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[k] = v
+	}
+	return filter, nil
 }
 
 func (s *Server) handleGetEndpoint(w http.ResponseWriter, r *http.Request) error {
@@ -175,27 +960,43 @@ func (s *Server) handleGetEndpoint(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
-	return writeJSON(w, http.StatusOK, endpoint)
+	if err := setETag(w, r, endpoint); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, withoutSecrets(r, endpoint))
 }
 
+// handleGetEndpoints lists the endpoints the API key can access, only the
+// endpoints with the given name when the name query parameter is set.
 func (s *Server) handleGetEndpoints(w http.ResponseWriter, r *http.Request) error {
-	return nil
-	// endpoints, err := s.store.GetEndpoints()
-	// if err != nil {
-	// 	return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
-	// }
-	// return writeJSON(w, http.StatusOK, endpoints)
+	endpoints, err := s.store.GetEndpoints()
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	name := r.URL.Query().Get("name")
+	matched := []types.Endpoint{}
+	for _, endpoint := range endpoints {
+		if (len(name) == 0 || endpoint.Name == name) && canAccess(r, &endpoint) {
+			matched = append(matched, *withoutSecrets(r, &endpoint))
+		}
+	}
+	return writeJSON(w, http.StatusOK, matched)
 }
 
 // PublishParams holds all the necessary fields to publish a specific
 // deployment LIVE to your application.
 type PublishParams struct {
 	DeploymentID uuid.UUID `json:"deployment_id"`
+	// Shift the traffic to the deployment gradually instead of all at once,
+	// see the rollout endpoints.
+	Rollout *types.RolloutPolicy `json:"rollout,omitempty"`
 }
 
 type PublishResponse struct {
 	DeploymentID uuid.UUID `json:"deployment_id"`
 	URL          string    `json:"url"`
+	// The started rollout of a guarded publish.
+	Rollout *types.Rollout `json:"rollout,omitempty"`
 }
 
 func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) error {
@@ -212,23 +1013,69 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	if !canAccess(r, endpoint) {
+		err := fmt.Errorf("could not find deployment with id (%s)", deploy.ID)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if params.Rollout != nil {
+		return s.startRollout(w, r, endpoint, deploy, *params.Rollout)
+	}
+	return s.publish(w, r, types.DeploymentEventPublish, endpoint, deploy)
+}
 
-	currentDeploymentID := endpoint.ActiveDeploymentID
+// RollbackParams holds the deployment an endpoint is rolled back to. The
+// deployment that was LIVE before the current one is used when empty.
+type RollbackParams struct {
+	DeploymentID uuid.UUID `json:"deployment_id"`
+}
 
-	if currentDeploymentID.String() == deploy.ID.String() {
-		err := fmt.Errorf("deploy %s already active", deploy.ID)
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
-
-	updateParams := storage.UpdateEndpointParams{
-		ActiveDeployID: deploy.ID,
+	var params RollbackParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
-	if err := s.store.UpdateEndpoint(deploy.EndpointID, updateParams); err != nil {
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	deployID := params.DeploymentID
+	if shared.IsZeroUUID(deployID) {
+		history, err := s.store.GetDeploymentEvents(endpointID)
+		if err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		var ok bool
+		if deployID, ok = types.PreviousDeployment(history); !ok {
+			err := fmt.Errorf("endpoint (%s) does not have a previous deployment to roll back to", endpointID)
+			return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		}
+	}
+	deploy, err := s.store.GetDeployment(deployID)
+	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	if deploy.EndpointID != endpoint.ID {
+		err := fmt.Errorf("deploy %s does not belong to endpoint %s", deploy.ID, endpoint.ID)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	return s.publish(w, r, types.DeploymentEventRollback, endpoint, deploy)
+}
 
-	s.cache.Delete(currentDeploymentID)
-
+// publish makes the given deployment the LIVE deployment of the endpoint
+// and records the change in the publish history of the endpoint. Rollbacks
+// are subject to the same policies as publishes.
+func (s *Server) publish(w http.ResponseWriter, r *http.Request, kind string, endpoint *types.Endpoint, deploy *types.Deployment) error {
+	if status, err := s.checkPublish(endpoint, deploy); err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	if status, err := s.activate(kind, endpoint, deploy, actor(r)); err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
 	resp := PublishResponse{
 		DeploymentID: deploy.ID,
 		URL:          fmt.Sprintf("%s/live/%s", config.IngressUrl(), endpoint.ID),
@@ -236,6 +1083,107 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) error {
 	return writeJSON(w, http.StatusOK, resp)
 }
 
+// checkPublish checks the deployment against the environment schema, the
+// policy and the health gate before it serves LIVE traffic. It returns the
+// status code of the failed check.
+func (s *Server) checkPublish(endpoint *types.Endpoint, deploy *types.Deployment) (int, error) {
+	if endpoint.ActiveDeploymentID == deploy.ID {
+		return http.StatusBadRequest, fmt.Errorf("deploy %s already active", deploy.ID)
+	}
+	if endpoint.Rollout.Running() {
+		return http.StatusConflict, fmt.Errorf("endpoint (%s) has a rollout in progress, abort it first", endpoint.ID)
+	}
+
+	// Broken configurations are caught before the deployment serves any
+	// traffic.
+	env, err := s.environment(endpoint)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := endpoint.EnvironmentSchema.Check(env); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+
+	if err := s.policy.Evaluate(policy.Input{
+		Action:     policy.ActionPublish,
+		Endpoint:   endpoint,
+		Deployment: deploy,
+	}); err != nil {
+		return http.StatusForbidden, err
+	}
+	if s.health.GatePublish {
+		if err := s.gateHealth(endpoint, deploy); err != nil {
+			return http.StatusUnprocessableEntity, err
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// activate makes the deployment the active deployment of the endpoint and
+// records the change. It returns the status code of the failed update.
+func (s *Server) activate(kind string, endpoint *types.Endpoint, deploy *types.Deployment, actor string) (int, error) {
+	currentDeploymentID := endpoint.ActiveDeploymentID
+
+	// The event is created before the update changes the active deployment
+	// of the endpoint.
+	event := types.NewDeploymentEvent(kind, endpoint, deploy.ID, actor)
+	env, err := s.environment(endpoint)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	updateParams := storage.UpdateEndpointParams{
+		ActiveDeployID:       deploy.ID,
+		PublishedEnvironment: types.NewEnvironmentSnapshot(deploy.ID, env),
+	}
+	if err := s.store.UpdateEndpoint(deploy.EndpointID, updateParams); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := s.store.CreateDeploymentEvent(event); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	eventType := types.EventEndpointPublished
+	if kind == types.DeploymentEventRollback {
+		eventType = types.EventRollback
+	}
+	s.notifier.Emit(types.NewEvent(eventType, endpoint.ID, deploy.ID, actor, ""))
+
+	s.cache.Delete(currentDeploymentID)
+	if !s.health.GatePublish {
+		published := *endpoint
+		go s.checkPublishedHealth(&published, deploy)
+	}
+	return http.StatusOK, nil
+}
+
+// ActorHeader is the request header that names who makes a change, it is
+// recorded in the publish history of the endpoints.
+const ActorHeader = "Raptor-Actor"
+
+func actor(r *http.Request) string {
+	if actor := r.Header.Get(ActorHeader); len(actor) > 0 {
+		return actor
+	}
+	return "unknown"
+}
+
+func (s *Server) handleGetEndpointHistory(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	history, err := s.store.GetDeploymentEvents(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if history == nil {
+		history = []types.DeploymentEvent{}
+	}
+	return writeJSON(w, http.StatusOK, history)
+}
+
 func (s *Server) handleGetEndpointMetrics(w http.ResponseWriter, r *http.Request) error {
 	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -248,20 +1196,291 @@ func (s *Server) handleGetEndpointMetrics(w http.ResponseWriter, r *http.Request
 	return writeJSON(w, http.StatusOK, metrics)
 }
 
+func (s *Server) handleGetStoreMetrics(w http.ResponseWriter, r *http.Request) error {
+	if s.latencies == nil {
+		return writeJSON(w, http.StatusOK, map[string]storage.HistogramSnapshot{})
+	}
+	return writeJSON(w, http.StatusOK, s.latencies.Snapshot())
+}
+
+// handleGetConfig returns the effective config of the API server, with the
+// reloads applied and without its secrets.
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, config.GetEffective())
+}
+
+func (s *Server) handleGetEndpointRecommendation(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	metrics, err := s.metricStore.GetRequestMetrics(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	rec, err := recommend.Analyze(endpointID, metrics)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, rec)
+}
+
+// ProbesResponse holds the availability of the probes of an endpoint
+// together with their most recent results.
+type ProbesResponse struct {
+	Availability []types.ProbeAvailability `json:"availability"`
+	Recent       []types.ProbeResult       `json:"recent"`
+}
+
+const maxRecentProbeResults = 50
+
+func (s *Server) handleGetEndpointProbes(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	results, err := s.metricStore.GetProbeResults(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	recent := results
+	if len(recent) > maxRecentProbeResults {
+		recent = recent[len(recent)-maxRecentProbeResults:]
+	}
+	resp := ProbesResponse{
+		Availability: types.Availability(results),
+		Recent:       recent,
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
 var errUnauthorized = errors.New("unauthorized")
 
-func (s *Server) withAPIToken(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if len(authHeader) < 10 {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse(errUnauthorized))
-			return
+// defaultSnapshotWindow is the window of a metrics snapshot when no start of
+// the window is given.
+const defaultSnapshotWindow = time.Hour * 24
+
+// parseSnapshotWindow returns the window of a metrics snapshot given the
+// optional "from" and "to" RFC 3339 query parameters.
+func parseSnapshotWindow(query url.Values) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := query.Get("to"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid snapshot end: %s", v)
 		}
-		apiToken := authHeader[7:]
-		if apiToken != config.Get().APIToken {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse(errUnauthorized))
-			return
+		to = t
+	}
+	from := to.Add(-defaultSnapshotWindow)
+	if v := query.Get("from"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid snapshot start: %s", v)
 		}
-		h.ServeHTTP(w, r)
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("snapshot start should be before its end")
+	}
+	return from, to, nil
+}
+
+func (s *Server) handleGetEndpointSnapshot(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	from, to, err := parseSnapshotWindow(r.URL.Query())
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	requests, err := s.metricStore.GetRequestMetrics(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	probes, err := s.metricStore.GetProbeResults(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	snapshot := types.NewMetricsSnapshot(endpoint, from, to, requests, probes)
+	filename := fmt.Sprintf("%s-%s.json", endpointID, snapshot.CreatedAT.UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return writeJSON(w, http.StatusOK, snapshot)
+}
+
+// parseRollupResolution returns the resolution of the rollups given the
+// optional "resolution" query parameter, 1m or 1h. Windows longer than a
+// day default to the rollups of an hour.
+func parseRollupResolution(query url.Values, from, to time.Time) (time.Duration, error) {
+	switch query.Get("resolution") {
+	case "1m":
+		return types.RollupMinute, nil
+	case "1h":
+		return types.RollupHour, nil
+	case "":
+		if to.Sub(from) > time.Hour*24 {
+			return types.RollupHour, nil
+		}
+		return types.RollupMinute, nil
+	default:
+		return 0, fmt.Errorf("invalid resolution given: %s, expected 1m or 1h", query.Get("resolution"))
+	}
+}
+
+func (s *Server) handleGetEndpointRollups(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	from, to, err := parseSnapshotWindow(r.URL.Query())
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	resolution, err := parseRollupResolution(r.URL.Query(), from, to)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	rollups, err := s.metricStore.GetMetricRollups(endpointID, resolution, from.Truncate(resolution), to)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if rollups == nil {
+		rollups = []types.MetricRollup{}
+	}
+	return writeJSON(w, http.StatusOK, rollups)
+}
+
+// RotateKeyResponse holds the version of the new data key of an endpoint.
+type RotateKeyResponse struct {
+	Version int `json:"version"`
+}
+
+func (s *Server) handleRotateEndpointKey(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if s.keys == nil {
+		err := fmt.Errorf("encryption is not enabled")
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	// The environment is re-encrypted, the updates of this server wait for
+	// it instead of having to be retried.
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	version, err := s.keys.RotateDataKey(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, RotateKeyResponse{Version: version})
+}
+
+// handleRewrapDataKeys re-wraps the data keys of all endpoints and projects
+// with the current master key, after which the previous master keys can be
+// removed from the configuration.
+func (s *Server) handleRewrapDataKeys(w http.ResponseWriter, r *http.Request) error {
+	if s.keys == nil {
+		err := fmt.Errorf("encryption is not enabled")
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	result, err := s.keys.RewrapDataKeys()
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetEnvironmentDrift reports the difference between the environment
+// captured when the active deployment was published and the current
+// environment of the endpoint.
+func (s *Server) handleGetEnvironmentDrift(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if endpoint.PublishedEnvironment == nil {
+		err := fmt.Errorf("endpoint (%s) does not have an environment captured at publish", endpointID)
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	env, err := s.environment(endpoint)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, types.NewEnvironmentDrift(endpoint.PublishedEnvironment, env))
+}
+
+// CacheResponse holds the response cache configuration of an endpoint and
+// its hits and misses across all the ingresses.
+type CacheResponse struct {
+	Cache *types.ResponseCache `json:"cache"`
+	Stats types.CacheStats     `json:"stats"`
+}
+
+func (s *Server) handleGetEndpointCache(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	metrics, err := s.metricStore.GetCacheMetrics(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, CacheResponse{
+		Cache: endpoint.Cache,
+		Stats: types.AggregateCacheMetrics(metrics),
 	})
 }
+
+// PurgeCacheResponse holds the generation of the response cache of an
+// endpoint after a purge.
+type PurgeCacheResponse struct {
+	Generation int `json:"generation"`
+}
+
+// handlePurgeEndpointCache bumps the generation of the response cache of the
+// endpoint. The ingresses read the endpoint on every request, hence the
+// responses cached under the previous generation are never served again.
+func (s *Server) handlePurgeEndpointCache(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if endpoint.Cache == nil {
+		err := fmt.Errorf("endpoint (%s) does not have response caching configured", endpointID)
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	cache := *endpoint.Cache
+	cache.Generation++
+	if err := s.store.UpdateEndpoint(endpointID, storage.UpdateEndpointParams{Cache: &cache}); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, PurgeCacheResponse{Generation: cache.Generation})
+}
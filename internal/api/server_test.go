@@ -1,16 +1,34 @@
 package api
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/backup"
+	"github.com/anthdm/raptor/internal/build"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/encryption"
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/policy"
+	"github.com/anthdm/raptor/internal/runtime"
 	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/signing"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/pkg/client"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -20,7 +38,7 @@ func TestUpdateEndpoint(t *testing.T) {
 	expected := map[string]string{"A": "B", "C": "D", "FOO": "BAR"}
 
 	params := UpdateEndpointParams{
-		Environment: map[string]string{"A": "B", "C": "D"},
+		Environment: map[string]*string{"A": envValue("B"), "C": envValue("D")},
 	}
 	b, err := json.Marshal(params)
 	require.Nil(t, err)
@@ -33,6 +51,663 @@ func TestUpdateEndpoint(t *testing.T) {
 	require.Equal(t, expected, endpoint.Environment)
 }
 
+func TestUpdateEndpointPatch(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	update := func(body string) int {
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	// A null value deletes the variable, the others are merged.
+	require.Equal(t, http.StatusOK, update(`{"environment": {"FOO": null, "A": "B"}}`))
+	require.Equal(t, map[string]string{"A": "B"}, endpoint.Environment)
+
+	require.Equal(t, http.StatusOK, update(`{"environment": {"C": "D"}, "environment_mode": "replace"}`))
+	require.Equal(t, map[string]string{"C": "D"}, endpoint.Environment)
+	require.Equal(t, http.StatusOK, update(`{"environment_mode": "replace"}`))
+	require.Empty(t, endpoint.Environment)
+	require.Equal(t, http.StatusBadRequest, update(`{"environment_mode": "upsert"}`))
+
+	require.Equal(t, http.StatusOK, update(`{"name": "renamed endpoint", "runtime": "js"}`))
+	require.Equal(t, "renamed endpoint", endpoint.Name)
+	require.Equal(t, "js", endpoint.Runtime)
+	require.Equal(t, http.StatusBadRequest, update(`{"name": "ab"}`))
+	require.Equal(t, http.StatusBadRequest, update(`{"runtime": "python"}`))
+
+	// The LIVE deployment was built for the current runtime.
+	deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(deploy))
+	require.Nil(t, s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: deploy.ID}))
+	require.Equal(t, http.StatusConflict, update(`{"runtime": "go"}`))
+	require.Equal(t, http.StatusOK, update(`{"runtime": "js"}`))
+}
+
+func TestUpdateEndpointSessionAffinity(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		SessionAffinity: &types.SessionAffinity{Cookie: "session_id"},
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, "session_id", endpoint.SessionAffinity.Cookie)
+
+	params.SessionAffinity = &types.SessionAffinity{}
+	b, err = json.Marshal(params)
+	require.Nil(t, err)
+
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestStoreMetrics(t *testing.T) {
+	var (
+		latencies = storage.NewLatencies()
+		memStore  = storage.NewMemoryStore()
+		store     = storage.NewInstrumentedStore(memStore, latencies, 0)
+		s         = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New()).WithStoreLatencies(latencies)
+	)
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String(), nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	req = httptest.NewRequest("GET", "/metrics/store", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var snapshot map[string]storage.HistogramSnapshot
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&snapshot))
+	require.Equal(t, int64(1), snapshot["CreateEndpoint"].Count)
+	require.Equal(t, int64(1), snapshot["GetEndpoint"].Count)
+	require.LessOrEqual(t, snapshot["GetEndpoint"].P99, snapshot["GetEndpoint"].Max)
+}
+
+func TestUpdateEndpointCORS(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		cors   *types.CORS
+		status int
+	}{
+		{cors: &types.CORS{AllowedOrigins: []string{"example.com"}}, status: http.StatusBadRequest},
+		{cors: &types.CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}, status: http.StatusBadRequest},
+		{cors: &types.CORS{AllowedOrigins: []string{"https://example.com"}, AllowedMethods: []string{"FETCH"}}, status: http.StatusBadRequest},
+		{cors: &types.CORS{
+			AllowedOrigins:   []string{"https://example.com", "https://*.example.com"},
+			AllowedMethods:   []string{"GET", "POST"},
+			MaxAge:           3600,
+			AllowCredentials: true,
+		}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{CORS: test.cors})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+		if test.status == http.StatusOK {
+			require.Equal(t, test.cors, endpoint.CORS)
+		}
+	}
+}
+
+func TestUpdateEndpointMaintenance(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		maintenance *types.Maintenance
+		status      int
+	}{
+		{maintenance: &types.Maintenance{Disabled: true, RetryAfter: -1}, status: http.StatusBadRequest},
+		{maintenance: &types.Maintenance{Disabled: true, Message: strings.Repeat("a", 8<<10)}, status: http.StatusBadRequest},
+		{maintenance: &types.Maintenance{Disabled: true, Message: "back at noon", RetryAfter: 600}, status: http.StatusOK},
+		{maintenance: &types.Maintenance{}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{Maintenance: test.maintenance})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+		if test.status == http.StatusOK {
+			require.Equal(t, test.maintenance, endpoint.Maintenance)
+		}
+	}
+	require.False(t, endpoint.Maintenance.Active())
+}
+
+func TestUpdateEndpointCompression(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		compression *types.Compression
+		status      int
+	}{
+		{compression: &types.Compression{Enabled: true, Level: 10}, status: http.StatusBadRequest},
+		{compression: &types.Compression{Enabled: true, MinSize: -1}, status: http.StatusBadRequest},
+		{compression: &types.Compression{Enabled: true, MinSize: 512, Level: 6}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{Compression: test.compression})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+		if test.status == http.StatusOK {
+			require.Equal(t, test.compression, endpoint.Compression)
+		}
+	}
+}
+
+func TestUpdateEndpointLimits(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		limits *types.Limits
+		status int
+	}{
+		{limits: &types.Limits{Timeout: -1}, status: http.StatusBadRequest},
+		{limits: &types.Limits{Timeout: 500, MaxResponseSize: 1 << 20}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{Limits: test.limits})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+		if test.status == http.StatusOK {
+			require.Equal(t, test.limits, endpoint.Limits)
+		}
+	}
+}
+
+func TestUpdateEndpointWebSocket(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		websocket *types.WebSocket
+		status    int
+	}{
+		{websocket: &types.WebSocket{Enabled: true, MaxMessageSize: -1}, status: http.StatusBadRequest},
+		{websocket: &types.WebSocket{Enabled: true}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{WebSocket: test.websocket})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+	}
+	require.True(t, endpoint.WebSocketEnabled())
+}
+
+func TestUpdateEndpointFallback(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, test := range []struct {
+		fallback *types.Fallback
+		status   int
+	}{
+		{fallback: &types.Fallback{URL: "/relative"}, status: http.StatusBadRequest},
+		{fallback: &types.Fallback{URL: "ftp://legacy.example.com"}, status: http.StatusBadRequest},
+		{fallback: &types.Fallback{URL: "https://legacy.example.com/api"}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{Fallback: test.fallback})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+	}
+	require.Equal(t, "legacy.example.com", endpoint.FallbackURL().Host)
+}
+
+func TestRotateEndpointKey(t *testing.T) {
+	keyring, err := encryption.NewKeyring(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	require.Nil(t, err)
+	var (
+		memStore = storage.NewMemoryStore()
+		store    = storage.NewEncryptedStore(memStore, keyring)
+		s        = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New()).WithKeyRotator(store)
+	)
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+
+	stored, err := memStore.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.True(t, encryption.IsEncrypted(stored.Environment["FOO"]))
+	require.Nil(t, store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		PublishedEnvironment: types.NewEnvironmentSnapshot(uuid.New(), map[string]string{"FOO": "BAR"}),
+	}))
+	require.True(t, strings.HasPrefix(stored.PublishedEnvironment.Environment["FOO"], "enc:v1:1:"))
+
+	// Values that predate the encryption stay as they are on reads.
+	require.Nil(t, memStore.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Environment: map[string]string{"FOO": stored.Environment["FOO"], "PLAIN": "text"},
+	}))
+	_, err = store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	stored, err = memStore.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "text", stored.Environment["PLAIN"])
+
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/keys/rotate", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var rotateResp RotateKeyResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&rotateResp))
+	require.Equal(t, 2, rotateResp.Version)
+
+	// The environments are re-encrypted with the new key by the rotation.
+	stored, err = memStore.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(stored.Environment["FOO"], "enc:v1:2:"))
+	require.True(t, strings.HasPrefix(stored.Environment["PLAIN"], "enc:v1:2:"))
+	require.True(t, strings.HasPrefix(stored.PublishedEnvironment.Environment["FOO"], "enc:v1:2:"))
+	decrypted, err := store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "BAR", decrypted.Environment["FOO"])
+	require.Equal(t, "BAR", decrypted.PublishedEnvironment.Environment["FOO"])
+
+	s = createServer()
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/keys/rotate", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Result().StatusCode)
+}
+
+func TestRewrapDataKeys(t *testing.T) {
+	var (
+		oldKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+		newKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	)
+	keyring, err := encryption.NewKeyring(oldKey)
+	require.Nil(t, err)
+	var (
+		memStore = storage.NewMemoryStore()
+		store    = storage.NewEncryptedStore(memStore, keyring)
+		s        = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New()).WithKeyRotator(store)
+	)
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+	require.Nil(t, store.UpdateProjectEnvironment(types.NewProjectEnvironment("acme", map[string]string{"REGION": "eu"})))
+
+	// The master key is rotated, the old key unwraps the data keys until
+	// they are re-wrapped.
+	keyring, err = encryption.NewKeyring(newKey, oldKey)
+	require.Nil(t, err)
+	store = storage.NewEncryptedStore(memStore, keyring)
+	s = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New()).WithKeyRotator(store)
+	s.initRouter()
+
+	req := httptest.NewRequest("POST", "/keys/rewrap", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result storage.RewrapResult
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, storage.RewrapResult{Endpoints: 1, Projects: 1}, result)
+
+	// Nothing is left to re-wrap.
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, httptest.NewRequest("POST", "/keys/rewrap", nil))
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, storage.RewrapResult{}, result)
+
+	// The new master key alone decrypts the environments.
+	keyring, err = encryption.NewKeyring(newKey)
+	require.Nil(t, err)
+	store = storage.NewEncryptedStore(memStore, keyring)
+	decrypted, err := store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "BAR", decrypted.Environment["FOO"])
+	env, err := store.GetProjectEnvironment("acme")
+	require.Nil(t, err)
+	require.Equal(t, "eu", env.Environment["REGION"])
+
+	s = createServer()
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, httptest.NewRequest("POST", "/keys/rewrap", nil))
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Result().StatusCode)
+}
+
+func TestEndpointCache(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/cache/purge", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Result().StatusCode)
+
+	for _, test := range []struct {
+		cache  *types.ResponseCache
+		status int
+	}{
+		{cache: &types.ResponseCache{Enabled: true, DefaultTTL: -1}, status: http.StatusBadRequest},
+		{cache: &types.ResponseCache{Enabled: true, Vary: []string{"Accept Language"}}, status: http.StatusBadRequest},
+		{cache: &types.ResponseCache{Enabled: true, Vary: []string{"Accept-Language"}, DefaultTTL: 60}, status: http.StatusOK},
+	} {
+		b, err := json.Marshal(UpdateEndpointParams{Cache: test.cache})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+	}
+	require.True(t, endpoint.CacheEnabled())
+
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/cache/purge", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var purgeResp PurgeCacheResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&purgeResp))
+	require.Equal(t, 1, purgeResp.Generation)
+
+	// Updating the configuration keeps the generation of the purges.
+	b, err := json.Marshal(UpdateEndpointParams{Cache: &types.ResponseCache{Enabled: true, Generation: 0}})
+	require.Nil(t, err)
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, 1, endpoint.Cache.Generation)
+
+	for _, metric := range []types.CacheMetric{{Hits: 3, Misses: 1}, {Hits: 5, Misses: 3}} {
+		metric.ID = uuid.New()
+		metric.EndpointID = endpoint.ID
+		require.Nil(t, s.metricStore.CreateCacheMetric(&metric))
+	}
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/cache", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var cacheResp CacheResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&cacheResp))
+	require.Equal(t, types.CacheStats{Hits: 8, Misses: 4, HitRatio: 8.0 / 12}, cacheResp.Stats)
+	require.Equal(t, 1, cacheResp.Cache.Generation)
+}
+
+func TestEndpointSnapshot(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, status := range []int{200, 500, 200, 200} {
+		require.Nil(t, s.metricStore.CreateRequestMetric(&types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			StatusCode: status,
+			Protocol:   "HTTP/2.0",
+			Duration:   time.Duration(i+1) * time.Millisecond,
+			CreatedAT:  start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	require.Nil(t, s.metricStore.CreateProbeResult(&types.ProbeResult{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		Path:       "/health",
+		Success:    true,
+		CreatedAT:  start,
+	}))
+
+	url := "/endpoint/" + endpoint.ID.String() + "/snapshot?from=2024-01-01T12:00:00Z&to=2024-01-01T12:03:00Z"
+	req := httptest.NewRequest("GET", url, nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Contains(t, resp.Header().Get("Content-Disposition"), "attachment")
+
+	var snapshot types.MetricsSnapshot
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&snapshot))
+	require.Equal(t, types.MetricsSnapshotVersion, snapshot.Version)
+	require.Equal(t, endpoint.ID, snapshot.Endpoint.ID)
+	require.Len(t, snapshot.RequestMetrics, 3)
+	require.Len(t, snapshot.ProbeResults, 1)
+	require.Equal(t, 3, snapshot.Summary.Requests)
+	require.Equal(t, 1, snapshot.Summary.Errors)
+	require.Equal(t, time.Millisecond*3, snapshot.Summary.MaxDuration)
+	require.Equal(t, map[string]int{"HTTP/2.0": 3}, snapshot.Summary.Protocols)
+
+	url = "/endpoint/" + endpoint.ID.String() + "/snapshot?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z"
+	req = httptest.NewRequest("GET", url, nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestEndpointRollups(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var metrics []types.RequestMetric
+	for i, status := range []int{200, 500, 200} {
+		metrics = append(metrics, types.RequestMetric{
+			EndpointID: endpoint.ID,
+			StatusCode: status,
+			Duration:   time.Millisecond,
+			CreatedAT:  start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	minutes := types.RollupMetrics(types.RollupMinute, metrics)
+	require.Nil(t, s.metricStore.CreateMetricRollups(minutes))
+	require.Nil(t, s.metricStore.CreateMetricRollups(types.MergeRollups(types.RollupHour, minutes)))
+
+	url := "/endpoint/" + endpoint.ID.String() + "/metrics/rollups?from=2024-01-01T12:00:30Z&to=2024-01-01T12:02:00Z"
+	req := httptest.NewRequest("GET", url, nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var rollups []types.MetricRollup
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&rollups))
+	require.Len(t, rollups, 2)
+	require.Equal(t, types.RollupMinute, rollups[0].Resolution)
+	require.Equal(t, int64(1), rollups[1].Errors)
+
+	// Windows longer than a day default to the rollups of an hour.
+	url = "/endpoint/" + endpoint.ID.String() + "/metrics/rollups?from=2023-12-01T00:00:00Z&to=2024-01-02T00:00:00Z"
+	req = httptest.NewRequest("GET", url, nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&rollups))
+	require.Len(t, rollups, 1)
+	require.Equal(t, int64(3), rollups[0].Requests)
+
+	url = "/endpoint/" + endpoint.ID.String() + "/metrics/rollups?resolution=1d"
+	req = httptest.NewRequest("GET", url, nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestBackupRestore(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	req := httptest.NewRequest("GET", "/backup", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, "application/gzip", resp.Header().Get("Content-Type"))
+	archive := resp.Body.Bytes()
+
+	target := createServer()
+	req = httptest.NewRequest("POST", "/restore", bytes.NewReader(archive))
+	resp = httptest.NewRecorder()
+	target.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result backup.Result
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, 1, result.Endpoints)
+	restored, err := target.store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.Environment, restored.Environment)
+
+	req = httptest.NewRequest("POST", "/restore", strings.NewReader("not an archive"))
+	resp = httptest.NewRecorder()
+	target.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestEndpointProbes(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		Probes: []types.Probe{{Path: "/health", Interval: 30, ExpectedStatus: http.StatusOK}},
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, params.Probes, endpoint.Probes)
+
+	for i, success := range []bool{true, true, false, true} {
+		require.Nil(t, s.metricStore.CreateProbeResult(&types.ProbeResult{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Path:       "/health",
+			Region:     "eu",
+			Success:    success,
+			CreatedAT:  time.Now().Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/probes", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var probesResp ProbesResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&probesResp))
+	require.Len(t, probesResp.Recent, 4)
+	require.Len(t, probesResp.Availability, 1)
+	require.Equal(t, 1, probesResp.Availability[0].Failures)
+	require.Equal(t, 0.75, probesResp.Availability[0].Availability)
+
+	params.Probes = []types.Probe{{Path: "health", Interval: 30, ExpectedStatus: http.StatusOK}}
+	b, err = json.Marshal(params)
+	require.Nil(t, err)
+
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestUpdateEndpointRoutes(t *testing.T) {
+	s := createServer()
+	composite := seedEndpoint(t, s)
+	target := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		Routes: []types.Route{{Prefix: "/api", EndpointID: target.ID}},
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PUT", "/endpoint/"+composite.ID.String(), bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, target.ID, composite.Route("/api/users").EndpointID)
+	require.Nil(t, composite.Route("/apiv2"))
+
+	// Composite endpoints cannot be the target of a route.
+	params.Routes = []types.Route{{Prefix: "/nested", EndpointID: composite.ID}}
+	b, err = json.Marshal(params)
+	require.Nil(t, err)
+
+	req = httptest.NewRequest("PUT", "/endpoint/"+target.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+
+	// Routes cannot target the endpoints of another project.
+	other := seedEndpoint(t, s)
+	other.Ownership = &types.Ownership{Owner: "other"}
+	params.Routes = []types.Route{{Prefix: "/other", EndpointID: other.ID}}
+	b, err = json.Marshal(params)
+	require.Nil(t, err)
+
+	req = httptest.NewRequest("PUT", "/endpoint/"+composite.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+	require.Equal(t, target.ID, composite.Route("/api/users").EndpointID)
+}
+
+func TestUpdateEndpointDeprecation(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	now := time.Now()
+	params := UpdateEndpointParams{
+		Deprecation: &types.Deprecation{
+			Date:   now,
+			Sunset: now.Add(-time.Hour),
+		},
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+
+	params.Deprecation.Sunset = now.Add(time.Hour * 24 * 30)
+	params.Deprecation.Link = "https://example.com/migrate"
+	b, err = json.Marshal(params)
+	require.Nil(t, err)
+
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.True(t, endpoint.Deprecation.Sunset.Equal(params.Deprecation.Sunset))
+}
+
 func TestCreateEndpoint(t *testing.T) {
 	s := createServer()
 
@@ -73,9 +748,18 @@ func TestGetEndpoint(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
 
+	// The values of the environment variables are only revealed on request.
 	var other types.Endpoint
 	err := json.NewDecoder(resp.Body).Decode(&other)
 	require.Nil(t, err)
+	require.Equal(t, map[string]string{"FOO": redacted}, other.Environment)
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"?reveal=true", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	other = types.Endpoint{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&other))
 	endpoint.CreatedAT = time.Time{}
 	other.CreatedAT = time.Time{}
 	require.Equal(t, *endpoint, other)
@@ -96,20 +780,432 @@ func TestCreateDeploy(t *testing.T) {
 	require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
 
 	require.Equal(t, endpoint.ID, deploy.EndpointID)
-	require.Equal(t, 32, len(deploy.Hash))
+	require.Equal(t, 64, len(deploy.Hash))
+	require.Equal(t, config.IngressUrl()+"/preview/"+deploy.ID.String(), deploy.PreviewURL)
 }
 
-func TestPublish(t *testing.T) {
+func TestWebhooks(t *testing.T) {
 	s := createServer()
 	endpoint := seedEndpoint(t, s)
-	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
 
-	require.Nil(t, s.store.CreateDeployment(deployment))
-	require.True(t, shared.IsZeroUUID(endpoint.ActiveDeploymentID))
+	delivered := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- r
+	}))
+	defer receiver.Close()
 
-	params := PublishParams{
-		DeploymentID: deployment.ID,
-	}
+	b, err := json.Marshal(CreateWebhookParams{URL: receiver.URL, Events: []string{"bogus"}})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/webhook", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	b, err = json.Marshal(CreateWebhookParams{URL: receiver.URL, Events: []string{types.EventDeploymentCreated}})
+	require.Nil(t, err)
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/webhook", bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var webhook types.Webhook
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&webhook))
+	require.Equal(t, 64, len(webhook.Secret))
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/webhook", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	var webhooks []types.Webhook
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&webhooks))
+	require.Len(t, webhooks, 1)
+	require.Empty(t, webhooks[0].Secret)
+
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", strings.NewReader("a"))
+	req.Header.Set("content-type", "application/octet-stream")
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	select {
+	case r := <-delivered:
+		require.Equal(t, types.EventDeploymentCreated, r.Header.Get(notify.EventHeader))
+		require.True(t, strings.HasPrefix(r.Header.Get(notify.SignatureHeader), "t="))
+	case <-time.After(time.Second * 5):
+		t.Fatal("webhook was not called")
+	}
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/events", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	var events []types.Event
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&events))
+	require.Len(t, events, 1)
+	require.Equal(t, types.EventDeploymentCreated, events[0].Type)
+
+	req = httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String()+"/webhook/"+webhook.ID.String(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String()+"/webhook/"+webhook.ID.String(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestAuditLog(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	body := `{"name": "renamed", "environment": {"TOKEN": "hunter2"}}`
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ActorHeader, "alice")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/rollback", nil)
+	req.Header.Set(ActorHeader, "bob")
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.NotEqual(t, http.StatusOK, resp.Code)
+
+	getEntries := func(query string) []types.AuditEntry {
+		req := httptest.NewRequest("GET", "/audit"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var entries []types.AuditEntry
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+		return entries
+	}
+
+	// Reads are not recorded.
+	entries := getEntries("")
+	require.Len(t, entries, 2)
+	require.Equal(t, "bob", entries[0].Actor)
+	require.Equal(t, "/endpoint/{id}/rollback", entries[0].Route)
+
+	entries = getEntries("?actor=alice")
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, "PUT", entry.Method)
+	require.Equal(t, endpoint.ID, entry.EndpointID)
+	require.Equal(t, "10.0.0.1", entry.SourceIP)
+	require.Equal(t, http.StatusOK, entry.StatusCode)
+	require.Contains(t, entry.Params, `"name":"renamed"`)
+	require.Contains(t, entry.Params, `"TOKEN":"[redacted]"`)
+	require.NotContains(t, entry.Params, "hunter2")
+
+	require.Len(t, getEntries("?limit=1"), 1)
+	require.Len(t, getEntries("?endpoint="+uuid.NewString()), 0)
+	require.Len(t, getEntries("?since="+time.Now().Add(time.Hour).Format(time.RFC3339)), 0)
+}
+
+func TestAPIKeyRoles(t *testing.T) {
+	store := storage.NewMemoryStore()
+	s := NewServer(store, store, storage.NewDefaultModCache(), policy.New()).WithAuthorization("root", []config.APIKey{
+		{Name: "ci", Key: "ci-key", Role: RoleDeployer, Project: "payments"},
+		{Name: "dashboard", Key: "view-key", Role: RoleReadOnly},
+	})
+	s.initRouter()
+	payments := seedEndpoint(t, s)
+	payments.Ownership = &types.Ownership{Owner: "payments"}
+	other := seedEndpoint(t, s)
+
+	call := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if len(key) > 0 {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		if method == "POST" && strings.HasSuffix(path, "/deployment") {
+			req.Header.Set("content-type", "application/octet-stream")
+		}
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	paymentsPath := "/endpoint/" + payments.ID.String()
+
+	require.Equal(t, http.StatusUnauthorized, call("", "GET", paymentsPath, "").Code)
+	require.Equal(t, http.StatusUnauthorized, call("bogus", "GET", paymentsPath, "").Code)
+
+	// Only the admins read the secrets.
+	resp := call("view-key", "GET", paymentsPath, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var endpoint types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	require.Equal(t, redacted, endpoint.Environment["FOO"])
+	resp = call("root", "GET", paymentsPath, "")
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	require.Equal(t, redacted, endpoint.Environment["FOO"])
+	resp = call("root", "GET", paymentsPath+"?reveal=true", "")
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	require.Equal(t, "BAR", endpoint.Environment["FOO"])
+	require.Equal(t, http.StatusForbidden, call("view-key", "GET", paymentsPath+"?reveal=true", "").Code)
+	require.Equal(t, http.StatusForbidden, call("ci-key", "GET", "/endpoint?reveal=true", "").Code)
+	require.Equal(t, http.StatusForbidden, call("view-key", "GET", paymentsPath+"/environment/drift", "").Code)
+	require.Equal(t, http.StatusForbidden, call("view-key", "GET", "/config", "").Code)
+	require.Equal(t, http.StatusForbidden, call("view-key", "GET", "/backup", "").Code)
+	// The versioned routes are authorized the same way.
+	require.Equal(t, http.StatusForbidden, call("view-key", "GET", "/v1/backup", "").Code)
+	require.Equal(t, http.StatusNotFound, call("ci-key", "GET", "/v1/endpoint/"+other.ID.String(), "").Code)
+	resp = call("root", "GET", "/config", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var effective config.Effective
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&effective))
+	require.NotEmpty(t, effective.Reloadable)
+
+	require.Equal(t, http.StatusForbidden, call("view-key", "PUT", paymentsPath, `{"name": "renamed"}`).Code)
+	require.Equal(t, http.StatusForbidden, call("view-key", "POST", paymentsPath+"/deployment", "a").Code)
+
+	// Deployers deploy the endpoints of their project, without changing
+	// them.
+	require.Equal(t, http.StatusOK, call("ci-key", "POST", paymentsPath+"/deployment", "a").Code)
+	require.Equal(t, http.StatusForbidden, call("ci-key", "PUT", paymentsPath, `{"name": "renamed"}`).Code)
+	require.Equal(t, http.StatusNotFound, call("ci-key", "POST", "/endpoint/"+other.ID.String()+"/deployment", "a").Code)
+	require.Equal(t, http.StatusForbidden, call("ci-key", "GET", "/audit", "").Code)
+
+	resp = call("ci-key", "GET", "/endpoint", "")
+	var endpoints []types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoints))
+	require.Len(t, endpoints, 1)
+	require.Equal(t, payments.ID, endpoints[0].ID)
+
+	resp = call("root", "GET", "/audit?limit=1", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var entries []types.AuditEntry
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Equal(t, "ci", entries[0].Key)
+}
+
+func TestQuotas(t *testing.T) {
+	s := createServer().WithQuotas([]config.Quota{{MaxEndpoints: 1, MaxDeployments: 1}})
+	endpoint := seedEndpoint(t, s)
+
+	b, err := json.Marshal(CreateEndpointParams{Name: "other", Runtime: "go"})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusForbidden, resp.Code)
+
+	deploy := func(blob string) int {
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", strings.NewReader(blob))
+		req.Header.Set("content-type", "application/octet-stream")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	require.Equal(t, http.StatusOK, deploy("a"))
+	// Identical re-deploys return the existing deployment.
+	require.Equal(t, http.StatusOK, deploy("a"))
+	require.Equal(t, http.StatusForbidden, deploy("b"))
+
+	record := types.UsageRecord{EndpointID: endpoint.ID, Period: types.UsagePeriod(time.Now()), Invocations: 3, GBSeconds: 1.5}
+	require.Nil(t, s.metricStore.AddUsage(&record))
+
+	req = httptest.NewRequest("GET", "/usage", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var usage []UsageResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&usage))
+	require.Len(t, usage, 1)
+	require.Equal(t, 1, usage[0].Usage.Endpoints)
+	require.Equal(t, 1, usage[0].Usage.Deployments)
+	require.Equal(t, int64(3), usage[0].Usage.Invocations)
+	require.Equal(t, 1, usage[0].Quota.MaxEndpoints)
+}
+
+func TestCreateDeployDedupe(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deploy := func(query string, blob string) types.Deployment {
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment"+query, strings.NewReader(blob))
+		req.Header.Set("content-type", "application/octet-stream")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var deploy types.Deployment
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+		return deploy
+	}
+
+	first := deploy("", "a")
+	require.Equal(t, first.ID, deploy("", "a").ID)
+	require.NotEqual(t, first.ID, deploy("", "b").ID)
+
+	// Opting out creates a new deployment that shares the stored blob.
+	forced := deploy("?dedupe=false", "a")
+	require.NotEqual(t, first.ID, forced.ID)
+	require.Equal(t, first.Hash, forced.Hash)
+	deploys, err := s.store.GetDeployments(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, deploys, 3)
+
+	// The blob outlives the deployments that share it.
+	require.Nil(t, s.store.DeleteDeployment(first.ID))
+	stored, err := s.store.GetDeployment(forced.ID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("a"), stored.Blob)
+}
+
+func TestDeploymentMetadata(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deploy := func(blob string, metadata string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("blob", "blob.wasm")
+		require.Nil(t, err)
+		_, err = part.Write([]byte(blob))
+		require.Nil(t, err)
+		require.Nil(t, mw.WriteField("metadata", metadata))
+		require.Nil(t, mw.Close())
+
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", &body)
+		req.Header.Set("content-type", mw.FormDataContentType())
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	list := func(query string) []types.Deployment {
+		req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/deployment"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var deploys []types.Deployment
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploys))
+		return deploys
+	}
+
+	resp := deploy("a", `{"labels": {"env": "staging"}, "git_commit": "3f2a9c1d", "git_branch": "main", "description": "fix login"}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var staging types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&staging))
+	require.Equal(t, "staging", staging.Labels["env"])
+	require.Equal(t, "fix login", staging.Description)
+
+	// The same blob built from another commit is another deployment.
+	resp = deploy("a", `{"labels": {"env": "production"}, "git_commit": "8b0e7d44", "git_branch": "release"}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, http.StatusBadRequest, deploy("a", `{"git_commit": "not-a-sha"}`).Code)
+	require.Equal(t, http.StatusBadRequest, deploy("a", `{"labels": {"bad key": "x"}}`).Code)
+
+	require.Len(t, list(""), 2)
+	deploys := list("?label=env%3Dstaging")
+	require.Len(t, deploys, 1)
+	require.Equal(t, staging.ID, deploys[0].ID)
+	require.Equal(t, "3f2a9c1d", deploys[0].GitCommit)
+	require.Len(t, list("?commit=8b0e"), 1)
+	require.Len(t, list("?branch=main&label=env%3Dproduction"), 0)
+}
+
+func TestSignedDeployment(t *testing.T) {
+	publicKey, privateKey, err := signing.GenerateKey()
+	require.Nil(t, err)
+	key, err := signing.ParsePrivateKey(privateKey)
+	require.Nil(t, err)
+	verifier, err := signing.NewVerifier([]string{publicKey}, true)
+	require.Nil(t, err)
+	s := createServer().WithSigning(verifier)
+	endpoint := seedEndpoint(t, s)
+	deploy := func(blob string, signature []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("blob", "blob.wasm")
+		require.Nil(t, err)
+		_, err = part.Write([]byte(blob))
+		require.Nil(t, err)
+		if signature != nil {
+			require.Nil(t, mw.WriteField("signature", base64.StdEncoding.EncodeToString(signature)))
+		}
+		require.Nil(t, mw.Close())
+
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", &body)
+		req.Header.Set("content-type", mw.FormDataContentType())
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := deploy("a", signing.Sign(key, []byte("a")))
+	require.Equal(t, http.StatusOK, resp.Code)
+	var signed types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&signed))
+	stored, err := s.store.GetDeployment(signed.ID)
+	require.Nil(t, err)
+	require.Equal(t, signing.Sign(key, []byte("a")), stored.Signature)
+
+	require.Equal(t, http.StatusForbidden, deploy("b", signing.Sign(key, []byte("a"))).Code)
+	require.Equal(t, http.StatusForbidden, deploy("b", nil).Code)
+	require.Equal(t, http.StatusBadRequest, deploy("b", []byte("short")).Code)
+}
+
+func TestCreateDeploymentWithAssets(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	var bundle bytes.Buffer
+	zw := zip.NewWriter(&bundle)
+	f, err := zw.Create("index.html")
+	require.Nil(t, err)
+	_, err = f.Write([]byte("<html></html>"))
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+
+	for _, test := range []struct {
+		assets []byte
+		status int
+	}{
+		{assets: bundle.Bytes(), status: http.StatusOK},
+		{assets: []byte("not a zip archive"), status: http.StatusUnprocessableEntity},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("blob", "blob.wasm")
+		require.Nil(t, err)
+		_, err = part.Write([]byte("a"))
+		require.Nil(t, err)
+		part, err = mw.CreateFormFile("assets", "assets.zip")
+		require.Nil(t, err)
+		_, err = part.Write(test.assets)
+		require.Nil(t, err)
+		require.Nil(t, mw.Close())
+
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", &body)
+		req.Header.Set("content-type", mw.FormDataContentType())
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode)
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		var deploy types.Deployment
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+		require.True(t, deploy.HasAssets)
+
+		stored, err := s.store.GetDeployment(deploy.ID)
+		require.Nil(t, err)
+		require.Equal(t, []byte("a"), stored.Blob)
+		require.Equal(t, test.assets, stored.Assets)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
+
+	require.Nil(t, s.store.CreateDeployment(deployment))
+	require.True(t, shared.IsZeroUUID(endpoint.ActiveDeploymentID))
+
+	params := PublishParams{
+		DeploymentID: deployment.ID,
+	}
 	b, err := json.Marshal(params)
 	require.Nil(t, err)
 
@@ -126,6 +1222,1185 @@ func TestPublish(t *testing.T) {
 	require.Equal(t, "http://0.0.0.0:80/live/"+endpoint.ID.String(), publishResp.URL)
 }
 
+func TestPublishEnvironmentSchema(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(deployment))
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		require.Nil(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	endpointPath := "/endpoint/" + endpoint.ID.String()
+
+	invalid := UpdateEndpointParams{
+		EnvironmentSchema: &types.EnvironmentSchema{
+			Variables: []types.EnvironmentVariable{{Name: "PORT", Pattern: "[0-9"}},
+		},
+	}
+	require.Equal(t, http.StatusBadRequest, do("PUT", endpointPath, invalid).Code)
+
+	params := UpdateEndpointParams{
+		EnvironmentSchema: &types.EnvironmentSchema{
+			Variables: []types.EnvironmentVariable{
+				{Name: "FOO", Pattern: "[a-z]+"},
+				{Name: "DATABASE_URL"},
+			},
+		},
+	}
+	require.Equal(t, http.StatusOK, do("PUT", endpointPath, params).Code)
+
+	resp := do("POST", "/publish", PublishParams{DeploymentID: deployment.ID})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	var errResp map[string]string
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Contains(t, errResp["error"], "missing required environment variables: DATABASE_URL")
+	require.Contains(t, errResp["error"], "FOO (expected [a-z]+)")
+	require.True(t, shared.IsZeroUUID(endpoint.ActiveDeploymentID))
+
+	env := UpdateEndpointParams{Environment: map[string]*string{"FOO": envValue("bar"), "DATABASE_URL": envValue("postgres://db")}}
+	require.Equal(t, http.StatusOK, do("PUT", endpointPath, env).Code)
+	require.Equal(t, http.StatusOK, do("POST", "/publish", PublishParams{DeploymentID: deployment.ID}).Code)
+	require.Equal(t, deployment.ID, endpoint.ActiveDeploymentID)
+}
+
+func TestProjectEnvironment(t *testing.T) {
+	keyring, err := encryption.NewKeyring(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	require.Nil(t, err)
+	var (
+		memStore = storage.NewMemoryStore()
+		store    = storage.NewEncryptedStore(memStore, keyring)
+		s        = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New()).WithAuthorization("root", []config.APIKey{
+			{Name: "ci", Key: "ci-key", Role: RoleDeployer, Project: "payments"},
+			{Name: "dashboard", Key: "view-key", Role: RoleReadOnly},
+		})
+	)
+	s.initRouter()
+	do := func(key, method, path string, body any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		require.Nil(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	decode := func(resp *httptest.ResponseRecorder) types.ProjectEnvironment {
+		require.Equal(t, http.StatusOK, resp.Code)
+		var env types.ProjectEnvironment
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&env))
+		return env
+	}
+	projectPath := "/project/payments/environment"
+
+	require.Empty(t, decode(do("root", "GET", projectPath, nil)).Environment)
+	params := UpdateProjectEnvironmentParams{Environment: map[string]*string{
+		"DATABASE_URL": envValue("postgres://db"),
+		"LOG_LEVEL":    envValue("info"),
+	}}
+	require.Equal(t, http.StatusForbidden, do("ci-key", "PUT", projectPath, params).Code)
+	require.Equal(t, http.StatusBadRequest, do("root", "PUT", projectPath, UpdateProjectEnvironmentParams{EnvironmentMode: "append"}).Code)
+	env := decode(do("root", "PUT", projectPath, params))
+	require.Equal(t, redacted, env.Environment["DATABASE_URL"])
+	env = decode(do("root", "GET", projectPath+"?reveal=true", nil))
+	require.Equal(t, "postgres://db", env.Environment["DATABASE_URL"])
+
+	// The defaults are encrypted at rest like the environment of the
+	// endpoints, only the admins reveal them.
+	stored, err := memStore.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	require.True(t, encryption.IsEncrypted(stored.Environment["DATABASE_URL"]))
+	require.Equal(t, redacted, decode(do("view-key", "GET", projectPath, nil)).Environment["DATABASE_URL"])
+	require.Equal(t, redacted, decode(do("ci-key", "GET", projectPath, nil)).Environment["DATABASE_URL"])
+	require.Equal(t, http.StatusForbidden, do("ci-key", "GET", projectPath+"?reveal=true", nil).Code)
+	require.Equal(t, http.StatusForbidden, do("ci-key", "GET", "/project/billing/environment", nil).Code)
+
+	// The endpoints of the project inherit the defaults they do not set
+	// themselves.
+	endpoint := types.NewEndpoint("My endpoint", "go", map[string]string{"FOO": "BAR", "LOG_LEVEL": "debug"})
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+	endpoint.EnvironmentSchema = &types.EnvironmentSchema{Variables: []types.EnvironmentVariable{{Name: "DATABASE_URL"}}}
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	inherited, err := s.environment(endpoint)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"FOO": "BAR", "LOG_LEVEL": "debug", "DATABASE_URL": "postgres://db"}, inherited)
+
+	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(deployment))
+	require.Equal(t, http.StatusOK, do("root", "POST", "/publish", PublishParams{DeploymentID: deployment.ID}).Code)
+
+	// Changing the defaults drifts the environment of the endpoints.
+	params = UpdateProjectEnvironmentParams{Environment: map[string]*string{"DATABASE_URL": nil}}
+	env = decode(do("root", "PUT", projectPath+"?reveal=true", params))
+	require.Equal(t, map[string]string{"LOG_LEVEL": "info"}, env.Environment)
+	resp := do("root", "GET", "/endpoint/"+endpoint.ID.String()+"/environment/drift", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var drift types.EnvironmentDrift
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&drift))
+	require.True(t, drift.Drifted)
+	require.Equal(t, []string{"DATABASE_URL"}, drift.Removed)
+}
+
+func TestEnvironmentDrift(t *testing.T) {
+	keyring, err := encryption.NewKeyring(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	require.Nil(t, err)
+	var (
+		memStore = storage.NewMemoryStore()
+		store    = storage.NewEncryptedStore(memStore, keyring)
+		s        = NewServer(store, memStore, storage.NewDefaultModCache(), policy.New())
+	)
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+	getDrift := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/environment/drift", nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	require.Equal(t, http.StatusUnprocessableEntity, getDrift().Result().StatusCode)
+
+	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(deployment))
+	b, err := json.Marshal(PublishParams{DeploymentID: deployment.ID})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/publish", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	// The captured environment is encrypted at rest like the environment.
+	stored, err := memStore.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.True(t, encryption.IsEncrypted(stored.PublishedEnvironment.Environment["FOO"]))
+
+	resp = getDrift()
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var drift types.EnvironmentDrift
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&drift))
+	require.False(t, drift.Drifted)
+	require.Equal(t, deployment.ID, drift.DeploymentID)
+
+	b, err = json.Marshal(UpdateEndpointParams{Environment: map[string]*string{"FOO": envValue("BAZ"), "NEW": envValue("1")}})
+	require.Nil(t, err)
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	resp = getDrift()
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	// Values are never exposed.
+	require.NotContains(t, resp.Body.String(), "BAZ")
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&drift))
+	require.True(t, drift.Drifted)
+	require.Equal(t, []string{"NEW"}, drift.Added)
+	require.Equal(t, []string{"FOO"}, drift.Changed)
+	require.Empty(t, drift.Removed)
+}
+
+func TestRollback(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			require.Nil(t, err)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set(ActorHeader, "alice")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	rollbackPath := "/endpoint/" + endpoint.ID.String() + "/rollback"
+
+	// Nothing to roll back to before the second publish.
+	require.Equal(t, http.StatusUnprocessableEntity, do("POST", rollbackPath, nil).Code)
+	var deploys []*types.Deployment
+	for i := 0; i < 2; i++ {
+		deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		require.Equal(t, http.StatusOK, do("POST", "/publish", PublishParams{DeploymentID: deploy.ID}).Code)
+		deploys = append(deploys, deploy)
+	}
+
+	resp := do("POST", rollbackPath, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, deploys[0].ID, endpoint.ActiveDeploymentID)
+
+	// Deployments of other endpoints are refused.
+	other := seedEndpoint(t, s)
+	foreign := types.NewDeployment(other, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(foreign))
+	require.Equal(t, http.StatusBadRequest, do("POST", rollbackPath, RollbackParams{DeploymentID: foreign.ID}).Code)
+
+	resp = do("POST", rollbackPath, RollbackParams{DeploymentID: deploys[1].ID})
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, deploys[1].ID, endpoint.ActiveDeploymentID)
+
+	resp = do("GET", "/endpoint/"+endpoint.ID.String()+"/history", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var history []types.DeploymentEvent
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&history))
+	require.Len(t, history, 4)
+	kinds := make([]string, len(history))
+	for i, event := range history {
+		kinds[i] = event.Kind
+		require.Equal(t, "alice", event.Actor)
+	}
+	require.Equal(t, []string{"publish", "publish", "rollback", "rollback"}, kinds)
+	require.True(t, shared.IsZeroUUID(history[0].PreviousDeploymentID))
+	require.Equal(t, deploys[1].ID, history[2].PreviousDeploymentID)
+	require.Equal(t, deploys[0].ID, history[3].PreviousDeploymentID)
+}
+
+func TestTransferEndpoint(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	do := func(method, path, actor string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			require.Nil(t, err)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set(ActorHeader, actor)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	transferPath := "/endpoint/" + endpoint.ID.String() + "/transfer"
+
+	var deploys []*types.Deployment
+	for i := 0; i < 2; i++ {
+		deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		require.Equal(t, http.StatusOK, do("POST", "/publish", "alice", PublishParams{DeploymentID: deploy.ID}).Code)
+		deploys = append(deploys, deploy)
+	}
+
+	// Nothing to accept before the transfer is requested.
+	require.Equal(t, http.StatusConflict, do("POST", transferPath+"/accept", "bob", AcceptTransferParams{Owner: "payments"}).Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", transferPath, "alice", TransferParams{}).Code)
+	resp := do("POST", transferPath, "alice", TransferParams{To: "payments"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var requested types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&requested))
+	require.Equal(t, map[string]string{"FOO": redacted}, requested.Environment)
+	require.Equal(t, "payments", endpoint.Ownership.Transfer.To)
+	require.Empty(t, endpoint.Ownership.Owner)
+
+	// Only the receiving owner can accept the transfer.
+	require.Equal(t, http.StatusConflict, do("POST", transferPath+"/accept", "bob", AcceptTransferParams{Owner: "billing"}).Code)
+	resp = do("POST", transferPath+"/accept", "bob", AcceptTransferParams{Owner: "payments"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var transferred types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&transferred))
+	require.Equal(t, map[string]string{"FOO": redacted}, transferred.Environment)
+	require.Equal(t, "payments", transferred.Ownership.Owner)
+	require.Nil(t, transferred.Ownership.Transfer)
+	require.Equal(t, deploys[1].ID, transferred.ActiveDeploymentID)
+
+	// The deployments move along with the endpoint and the transfer does not
+	// change what a rollback returns to.
+	resp = do("POST", "/endpoint/"+endpoint.ID.String()+"/rollback", "bob", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, deploys[0].ID, endpoint.ActiveDeploymentID)
+
+	resp = do("GET", "/endpoint/"+endpoint.ID.String()+"/history", "bob", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var history []types.DeploymentEvent
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&history))
+	require.Len(t, history, 5)
+	require.Equal(t, types.DeploymentEventTransferRequest, history[2].Kind)
+	require.Equal(t, "alice", history[2].Actor)
+	require.Equal(t, "payments", history[2].To)
+	require.Equal(t, types.DeploymentEventTransferAccept, history[3].Kind)
+	require.Equal(t, "bob", history[3].Actor)
+	require.Equal(t, "payments", history[3].To)
+}
+
+func TestTransferEndpointProjectKeys(t *testing.T) {
+	store := storage.NewMemoryStore()
+	s := NewServer(store, store, storage.NewDefaultModCache(), policy.New()).WithAuthorization("root", []config.APIKey{
+		{Name: "payments-admin", Key: "payments-key", Role: RoleAdmin, Project: "payments"},
+		{Name: "billing-admin", Key: "billing-key", Role: RoleAdmin, Project: "billing"},
+		{Name: "search-admin", Key: "search-key", Role: RoleAdmin, Project: "search"},
+	})
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+
+	call := func(key, path string, body any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	transferPath := "/endpoint/" + endpoint.ID.String() + "/transfer"
+
+	// Other projects do not see the endpoint before it is addressed to them.
+	require.Equal(t, http.StatusNotFound, call("billing-key", transferPath+"/accept", AcceptTransferParams{}).Code)
+	require.Equal(t, http.StatusOK, call("payments-key", transferPath, TransferParams{To: "billing"}).Code)
+
+	// The requesting project cannot accept its own transfer, even when it
+	// names the receiving project.
+	require.Equal(t, http.StatusForbidden, call("payments-key", transferPath+"/accept", AcceptTransferParams{Owner: "billing"}).Code)
+	require.Equal(t, http.StatusForbidden, call("payments-key", transferPath+"/accept", AcceptTransferParams{}).Code)
+	require.Equal(t, http.StatusNotFound, call("search-key", transferPath+"/accept", AcceptTransferParams{Owner: "billing"}).Code)
+	require.Equal(t, "payments", endpoint.Owner())
+
+	resp := call("billing-key", transferPath+"/accept", AcceptTransferParams{})
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "billing", endpoint.Owner())
+	require.Nil(t, endpoint.Ownership.Transfer)
+
+	// The endpoint left the requesting project.
+	require.Equal(t, http.StatusNotFound, call("payments-key", transferPath, TransferParams{To: "payments"}).Code)
+}
+
+func TestPruneDeployments(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	prunePath := "/endpoint/" + endpoint.ID.String() + "/deployment/prune"
+	prune := func(params PruneParams) (*httptest.ResponseRecorder, PruneResponse) {
+		b, err := json.Marshal(params)
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", prunePath, bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		var pruneResp PruneResponse
+		if resp.Code == http.StatusOK {
+			require.Nil(t, json.NewDecoder(resp.Body).Decode(&pruneResp))
+		}
+		return resp, pruneResp
+	}
+
+	var deploys []*types.Deployment
+	for i := 0; i < 4; i++ {
+		deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+		deploy.CreatedAT = time.Now().Add(time.Duration(i-4) * time.Hour)
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		deploys = append(deploys, deploy)
+	}
+	// The oldest deployment is LIVE.
+	require.Nil(t, s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: deploys[0].ID}))
+
+	// Endpoints without a retention policy are never pruned.
+	resp, _ := prune(PruneParams{})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	resp, pruned := prune(PruneParams{Retention: &types.RetentionPolicy{KeepLast: 1}, DryRun: true})
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.True(t, pruned.DryRun)
+	require.Len(t, pruned.Pruned, 2)
+	deployments, err := s.store.GetDeployments(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, deployments, 4)
+
+	body := []byte(`{"retention": {"keep_last": 1}}`)
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	resp, pruned = prune(PruneParams{})
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, []uuid.UUID{deploys[1].ID, deploys[2].ID}, []uuid.UUID{pruned.Pruned[0].ID, pruned.Pruned[1].ID})
+	deployments, err = s.store.GetDeployments(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, deployments, 2)
+	require.Equal(t, deploys[0].ID, deployments[0].ID)
+	require.Equal(t, deploys[3].ID, deployments[1].ID)
+	_, err = s.store.GetDeployment(deploys[1].ID)
+	require.NotNil(t, err)
+}
+
+// healthModule returns a module that only exports a health function that
+// returns the given code.
+func healthModule(code byte) []byte {
+	name := []byte(runtime.HealthExport)
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	b = append(b, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f)
+	b = append(b, 0x03, 0x02, 0x01, 0x00)
+	b = append(b, 0x07, byte(len(name)+4), 0x01, byte(len(name)))
+	b = append(b, name...)
+	b = append(b, 0x00, 0x00)
+	return append(b, 0x0a, 0x06, 0x01, 0x04, 0x00, 0x41, code, 0x0b)
+}
+
+func TestPublishHealthGate(t *testing.T) {
+	s := createServer().WithHealthChecks(config.Health{GatePublish: true, FailureThreshold: 2})
+	endpoint := seedEndpoint(t, s)
+	publish := func(blob []byte) (*types.Deployment, int) {
+		deploy := types.NewDeployment(endpoint, blob)
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		b, err := json.Marshal(PublishParams{DeploymentID: deploy.ID})
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", "/publish", bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return deploy, resp.Code
+	}
+
+	healthy, code := publish(healthModule(0))
+	require.Equal(t, http.StatusOK, code)
+
+	unhealthy, code := publish(healthModule(1))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, healthy.ID, endpoint.ActiveDeploymentID)
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/health", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var health types.DeploymentHealth
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&health))
+	require.Equal(t, healthy.ID, health.DeploymentID)
+	require.Equal(t, types.HealthHealthy, health.Status)
+
+	checks, err := s.metricStore.GetHealthChecks(unhealthy.ID)
+	require.Nil(t, err)
+	require.Len(t, checks, 1)
+	require.Equal(t, "health function returned 1", checks[0].Error)
+}
+
+func TestPlatformStatus(t *testing.T) {
+	eu := httptest.NewServer(admin.NewServer("ingress-1", admin.NewTracker(), func() int { return 1 }).WithRegion("eu"))
+	defer eu.Close()
+	drained := admin.NewTracker()
+	drained.Drain()
+	us := httptest.NewServer(admin.NewServer("ingress-2", drained, func() int { return 1 }))
+	defer us.Close()
+
+	s := createServer().WithStatusPage(config.Status{
+		Ingresses: []string{
+			strings.TrimPrefix(eu.URL, "http://"),
+			"us=" + strings.TrimPrefix(us.URL, "http://"),
+		},
+	})
+	endpoint := seedEndpoint(t, s)
+	for _, code := range []int{200, 200, 500, 502} {
+		require.Nil(t, s.metricStore.CreateRequestMetric(&types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			StatusCode: code,
+			// All in the same window of the request stats.
+			CreatedAT: time.Now().Truncate(defaultStatusInterval),
+		}))
+	}
+	getStatus := func() types.PlatformStatus {
+		req := httptest.NewRequest("GET", "/platform/status", nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var status types.PlatformStatus
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&status))
+		return status
+	}
+
+	status := getStatus()
+	require.Equal(t, types.StatusOutage, status.Status)
+	require.Len(t, status.Regions, 2)
+	require.Equal(t, "eu", status.Regions[0].Region)
+	require.Equal(t, types.StatusOperational, status.Regions[0].Status)
+	require.Equal(t, types.StatusOutage, status.Regions[1].Status)
+	require.True(t, status.Regions[1].Ingresses[0].Draining)
+	var requests, errors int
+	for _, stats := range status.Requests {
+		requests += stats.Requests
+		errors += stats.Errors
+	}
+	require.Equal(t, 4, requests)
+	require.Equal(t, 2, errors)
+
+	body := bytes.NewReader([]byte(`{"title": "Elevated error rates", "severity": "major", "regions": ["eu"]}`))
+	req := httptest.NewRequest("POST", "/platform/incident", body)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var incident types.Incident
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&incident))
+
+	status = getStatus()
+	require.Len(t, status.Incidents, 1)
+	require.Equal(t, types.StatusDegraded, status.Regions[0].Status)
+
+	req = httptest.NewRequest("POST", "/platform/incident/"+incident.ID.String()+"/resolve", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	status = getStatus()
+	require.Len(t, status.Incidents, 1)
+	require.False(t, status.Incidents[0].Active())
+	require.Equal(t, types.StatusOperational, status.Regions[0].Status)
+
+	req = httptest.NewRequest("GET", "/platform", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Body.String(), "Elevated error rates")
+	require.Contains(t, resp.Body.String(), "50.00%")
+}
+
+func envValue(v string) *string {
+	return &v
+}
+
+func TestBuildDeployment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	createBuild := func(language string, source []byte) *http.Response {
+		url := "/endpoint/" + endpoint.ID.String() + "/build?language=" + language
+		req := httptest.NewRequest("POST", url, bytes.NewReader(source))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp.Result()
+	}
+
+	var source bytes.Buffer
+	zw := zip.NewWriter(&source)
+	f, err := zw.Create("module.wasm")
+	require.Nil(t, err)
+	_, err = f.Write([]byte("somefakeblob"))
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+
+	require.Equal(t, http.StatusUnprocessableEntity, createBuild("go", source.Bytes()).StatusCode)
+
+	// The toolchains are replaced, so that the build does not depend on the
+	// installed compilers.
+	s.WithBuilder(build.NewBuilder(config.Build{}).
+		WithToolchain(types.BuildLanguageGo, build.Toolchain{
+			Command: []string{"cp", "module.wasm", "app.wasm"},
+			Output:  "app.wasm",
+		}).
+		WithToolchain(types.BuildLanguageRust, build.Toolchain{
+			Command: []string{"sh", "-c", "echo missing crate; exit 1"},
+			Output:  "app.wasm",
+		}))
+	require.Equal(t, http.StatusBadRequest, createBuild("python", source.Bytes()).StatusCode)
+
+	waitForBuild := func(resp *http.Response) *types.Build {
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		var queued types.Build
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&queued))
+		require.Equal(t, types.BuildQueued, queued.Status)
+		var finished *types.Build
+		require.Eventually(t, func() bool {
+			finished, err = s.store.GetBuild(queued.ID)
+			require.Nil(t, err)
+			return finished.Done()
+		}, 5*time.Second, 10*time.Millisecond)
+		return finished
+	}
+
+	built := waitForBuild(createBuild("go", source.Bytes()))
+	require.Equal(t, types.BuildSucceeded, built.Status)
+	deploy, err := s.store.GetDeployment(built.DeploymentID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("somefakeblob"), deploy.Blob)
+	require.Equal(t, built.ID, *deploy.BuildID)
+	require.Equal(t, types.BuildSucceeded, deploy.BuildStatus)
+
+	failed := waitForBuild(createBuild("rust", source.Bytes()))
+	require.Equal(t, types.BuildFailed, failed.Status)
+	require.Contains(t, failed.Logs, "missing crate")
+	require.Equal(t, uuid.Nil, failed.DeploymentID)
+
+	req := httptest.NewRequest("GET", "/build/"+failed.ID.String(), nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestGetEndpointsByName(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	require.Nil(t, s.store.CreateEndpoint(types.NewEndpoint("other endpoint", "go", nil)))
+	list := func(query string) []types.Endpoint {
+		req := httptest.NewRequest("GET", "/endpoint"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var endpoints []types.Endpoint
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoints))
+		return endpoints
+	}
+
+	require.Len(t, list(""), 2)
+	endpoints := list("?name=My+endpoint")
+	require.Len(t, endpoints, 1)
+	require.Equal(t, endpoint.ID, endpoints[0].ID)
+	require.Empty(t, list("?name=unknown"))
+}
+
+func TestPullDeployment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("somefakeblob"))
+	}))
+	defer origin.Close()
+	s.WithArtifactClient(origin.Client())
+	pull := func(params PullDeploymentParams) *httptest.ResponseRecorder {
+		b, err := json.Marshal(params)
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment/pull", bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := pull(PullDeploymentParams{Source: origin.URL + "/func.wasm"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var deploy types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+	require.Equal(t, origin.URL+"/func.wasm", deploy.Source)
+	stored, err := s.store.GetDeployment(deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("somefakeblob"), stored.Blob)
+
+	// Pulling the same artifact again returns the existing deployment.
+	resp = pull(PullDeploymentParams{Source: origin.URL + "/func.wasm"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var again types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&again))
+	require.Equal(t, deploy.ID, again.ID)
+
+	resp = pull(PullDeploymentParams{Source: origin.URL + "/func.wasm", Digest: "sha256:00"})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	resp = pull(PullDeploymentParams{Source: "ftp://example.com/func.wasm"})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+}
+
+func TestDescribeEndpoint(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	describe := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/describe"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := describe("")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var description DescribeResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&description))
+	require.Nil(t, description.ActiveDeployment)
+	require.Nil(t, description.Health)
+	require.Empty(t, description.Deployments)
+
+	var deploys []*types.Deployment
+	for i := 0; i < 7; i++ {
+		deploy := types.NewDeployment(endpoint, []byte("somefakeblob"+strings.Repeat("!", i)))
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		deploys = append(deploys, deploy)
+	}
+	b, err := json.Marshal(PublishParams{DeploymentID: deploys[1].ID})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/publish", bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	for i, status := range []int{200, 200, 200, 503} {
+		require.Nil(t, s.metricStore.CreateRequestMetric(&types.RequestMetric{
+			ID:           uuid.New(),
+			EndpointID:   endpoint.ID,
+			DeploymentID: deploys[1].ID,
+			Duration:     time.Duration(i+1) * time.Millisecond,
+			StatusCode:   status,
+			CreatedAT:    time.Now(),
+		}))
+	}
+
+	resp = describe("?window=24h")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&description))
+	require.Equal(t, endpoint.ID, description.Endpoint.ID)
+	require.Equal(t, deploys[1].ID, description.ActiveDeployment.ID)
+	require.NotNil(t, description.Health)
+	require.Equal(t, 4, description.Requests.Requests)
+	require.Equal(t, 0.25, description.Requests.ErrorRate)
+	require.Equal(t, 4*time.Millisecond, description.Requests.P95Duration)
+	require.Len(t, description.Deployments, 5)
+	require.Equal(t, deploys[6].ID, description.Deployments[0].ID)
+	require.Equal(t, deploys[2].ID, description.Deployments[4].ID)
+
+	require.Equal(t, http.StatusBadRequest, describe("?window=soon").Code)
+}
+
+func TestGuardedPublish(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			require.Nil(t, err)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set(ActorHeader, "alice")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	var deploys []*types.Deployment
+	for i := 0; i < 3; i++ {
+		deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+		require.Nil(t, s.store.CreateDeployment(deploy))
+		deploys = append(deploys, deploy)
+	}
+	// The steps are advanced by the test.
+	policy := &types.RolloutPolicy{Steps: []int{50, 100}, StepInterval: int(time.Hour.Milliseconds())}
+
+	// A rollout needs a LIVE deployment to shift the traffic from.
+	resp := do("POST", "/publish", PublishParams{DeploymentID: deploys[0].ID, Rollout: policy})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.Equal(t, http.StatusOK, do("POST", "/publish", PublishParams{DeploymentID: deploys[0].ID}).Code)
+
+	resp = do("POST", "/publish", PublishParams{DeploymentID: deploys[1].ID, Rollout: policy})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var publish PublishResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&publish))
+	require.Equal(t, 50, publish.Rollout.Weight())
+	require.Equal(t, deploys[0].ID, endpoint.ActiveDeploymentID)
+
+	// Publishes are refused while the rollout runs.
+	require.Equal(t, http.StatusConflict, do("POST", "/publish", PublishParams{DeploymentID: deploys[2].ID}).Code)
+
+	require.Nil(t, s.advanceRollout(endpoint, endpoint.Rollout))
+	require.Equal(t, 100, endpoint.Rollout.Weight())
+	require.Equal(t, deploys[0].ID, endpoint.ActiveDeploymentID)
+	require.Nil(t, s.advanceRollout(endpoint, endpoint.Rollout))
+	require.Equal(t, types.RolloutCompleted, endpoint.Rollout.Status)
+	require.Equal(t, deploys[1].ID, endpoint.ActiveDeploymentID)
+
+	// Errors of the new deployment roll the rollout back.
+	resp = do("POST", "/publish", PublishParams{DeploymentID: deploys[2].ID, Rollout: policy})
+	require.Equal(t, http.StatusOK, resp.Code)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, s.metricStore.CreateRequestMetric(&types.RequestMetric{
+			ID:           uuid.New(),
+			EndpointID:   endpoint.ID,
+			DeploymentID: deploys[2].ID,
+			StatusCode:   http.StatusInternalServerError,
+			CreatedAT:    time.Now(),
+		}))
+	}
+	require.Nil(t, s.advanceRollout(endpoint, endpoint.Rollout))
+	require.Equal(t, types.RolloutRolledBack, endpoint.Rollout.Status)
+	require.Equal(t, deploys[1].ID, endpoint.ActiveDeploymentID)
+
+	resp = do("GET", "/endpoint/"+endpoint.ID.String()+"/history", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var history []types.DeploymentEvent
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&history))
+	var kinds []string
+	for _, event := range history {
+		kinds = append(kinds, event.Kind)
+	}
+	require.Equal(t, []string{
+		types.DeploymentEventPublish,
+		types.DeploymentEventRolloutStep,
+		types.DeploymentEventRolloutStep,
+		types.DeploymentEventPublish,
+		types.DeploymentEventRolloutStep,
+		types.DeploymentEventRolloutRollback,
+	}, kinds)
+	require.Equal(t, 50, history[1].Weight)
+	require.NotEmpty(t, history[5].Reason)
+
+	// Nothing is left to abort.
+	require.Equal(t, http.StatusUnprocessableEntity, do("POST", "/endpoint/"+endpoint.ID.String()+"/rollout/abort", nil).Code)
+	require.Equal(t, http.StatusOK, do("GET", "/endpoint/"+endpoint.ID.String()+"/rollout", nil).Code)
+}
+
+func TestInvocationErrors(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+		deploy1  = uuid.New()
+		deploy2  = uuid.New()
+	)
+	create := func(deploymentID uuid.UUID, reason string, age time.Duration) {
+		e := types.InvocationError{
+			ID:           uuid.New(),
+			EndpointID:   endpoint.ID,
+			DeploymentID: deploymentID,
+			Kind:         types.ErrorKindTrap,
+			Reason:       reason,
+			Signature:    types.ErrorSignature(types.ErrorKindTrap, reason, nil),
+			CreatedAT:    time.Now().Add(-age),
+		}
+		require.Nil(t, s.metricStore.CreateInvocationError(&e))
+	}
+	create(deploy1, "unreachable", time.Hour*3)
+	create(deploy2, "unreachable", time.Minute)
+	create(deploy2, "out of bounds memory access", time.Minute)
+	get := func(query string) []types.ErrorGroup {
+		req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/errors"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var groups []types.ErrorGroup
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&groups))
+		return groups
+	}
+
+	groups := get("")
+	require.Len(t, groups, 2)
+	require.Equal(t, "unreachable", groups[0].Reason)
+	require.Equal(t, 2, groups[0].Count)
+	require.Len(t, groups[0].Deployments, 2)
+
+	groups = get("?window=1h&deployment=" + deploy2.String())
+	require.Len(t, groups, 2)
+	require.Equal(t, 1, groups[0].Count)
+	require.Empty(t, get("?deployment="+deploy1.String()+"&window=1h"))
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/errors?window=never", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// The description counts the errors of its window.
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/describe", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var description DescribeResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&description))
+	require.Equal(t, 2, description.Errors)
+	require.Len(t, description.ErrorGroups, 2)
+}
+
+func TestEndpointConcurrency(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+	)
+	create := func(member string, inflight, queued int, age time.Duration) {
+		m := types.ConcurrencyMetric{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Member:     member,
+			Inflight:   inflight,
+			Queued:     queued,
+			Limit:      10,
+			CreatedAT:  time.Now().Add(-age),
+		}
+		require.Nil(t, s.metricStore.CreateConcurrencyMetric(&m))
+	}
+	create("a", 12, 4, time.Hour)
+	create("a", 6, 0, time.Second*10)
+	create("b", 4, 0, time.Second*5)
+	get := func(query string) types.ConcurrencyStats {
+		req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/concurrency"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var stats types.ConcurrencyStats
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&stats))
+		return stats
+	}
+
+	stats := get("")
+	require.Equal(t, 10, stats.Inflight)
+	require.Equal(t, 10, stats.PeakInflight)
+	require.Equal(t, 0, stats.PeakQueued)
+	require.Equal(t, 0.5, stats.Utilization)
+	require.Len(t, stats.Members, 2)
+
+	stats = get("?window=2h")
+	require.Equal(t, 12, stats.PeakInflight)
+	require.Equal(t, 4, stats.PeakQueued)
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/concurrency?window=never", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestDeadLetters(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+		received = make(chan string, 1)
+	)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+	defer ingress.Close()
+	s.WithInvoker(async.New(s.store, ingress.URL, config.Async{MaxAttempts: 1}))
+
+	create := func() *types.DeadLetter {
+		letter := &types.DeadLetter{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Source:     async.SourceRedrive,
+			Request:    types.AsyncRequest{Method: "POST", Path: "/orders", Body: []byte("{}")},
+			Attempts:   3,
+			StatusCode: http.StatusBadGateway,
+			CreatedAT:  time.Now(),
+		}
+		require.Nil(t, s.store.CreateDeadLetter(letter))
+		return letter
+	}
+	letter1, letter2 := create(), create()
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/dead-letters", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var letters []types.DeadLetter
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&letters))
+	require.Len(t, letters, 2)
+	require.Equal(t, letter1.ID, letters[0].ID)
+
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/dead-letters/"+letter1.ID.String()+"/redrive", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	select {
+	case path := <-received:
+		require.Equal(t, "/live/"+endpoint.ID.String()+"/orders", path)
+	case <-time.After(time.Second * 5):
+		t.Fatal("dead letter was not re-driven")
+	}
+
+	req = httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String()+"/dead-letters/"+letter2.ID.String(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	letters, err := s.store.GetDeadLetters(endpoint.ID)
+	require.Nil(t, err)
+	require.Empty(t, letters)
+
+	// The letters of other endpoints are not found.
+	other := seedEndpoint(t, s)
+	letter3 := create()
+	req = httptest.NewRequest("DELETE", "/endpoint/"+other.ID.String()+"/dead-letters/"+letter3.ID.String(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+
+	// The retry policy is validated.
+	b, _ := json.Marshal(UpdateEndpointParams{Retry: &types.RetryPolicy{MaxAttempts: 100}})
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	b, _ = json.Marshal(UpdateEndpointParams{Retry: &types.RetryPolicy{MaxAttempts: 5}})
+	req = httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	updated, err := s.store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, 5, updated.Retry.MaxAttempts)
+}
+
+func TestAsyncInvocation(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+	)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+	}))
+	defer ingress.Close()
+	s.WithInvoker(async.New(s.store, ingress.URL, config.Async{MaxAttempts: 1}))
+
+	invoke := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/invoke"+query, strings.NewReader(`{"foo":"bar"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	require.Equal(t, http.StatusBadRequest, invoke("").Code)
+	require.Equal(t, http.StatusConflict, invoke("?mode=async").Code)
+
+	require.Nil(t, s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: uuid.New()}))
+	resp := invoke("?mode=async&method=PUT&path=/orders")
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	var invocation types.Invocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&invocation))
+	require.Equal(t, types.InvocationQueued, invocation.Status)
+	require.Equal(t, "/invocation/"+invocation.ID.String(), resp.Header().Get("Location"))
+
+	require.Eventually(t, func() bool {
+		req := httptest.NewRequest("GET", "/invocation/"+invocation.ID.String(), nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&invocation))
+		return invocation.Done()
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, types.InvocationSucceeded, invocation.Status)
+	require.Equal(t, http.StatusOK, invocation.StatusCode)
+	require.Equal(t, "application/json", invocation.Header.Get("Content-Type"))
+	require.Equal(t, `PUT /live/`+endpoint.ID.String()+`/orders {"foo":"bar"}`, string(invocation.Body))
+	require.Equal(t, 1, invocation.Attempts)
+	require.NotNil(t, invocation.FinishedAT)
+
+	req := httptest.NewRequest("GET", "/invocation/"+uuid.NewString(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestScheduledInvocations(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+		received = make(chan string, 1)
+	)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- r.Method + " " + r.URL.Path + " " + string(b)
+	}))
+	defer ingress.Close()
+	s.WithInvoker(async.New(s.store, ingress.URL, config.Async{MaxAttempts: 1}))
+
+	schedule := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/schedule"+query, strings.NewReader("reminder"))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	require.Equal(t, http.StatusBadRequest, schedule("").Code)
+	require.Equal(t, http.StatusBadRequest, schedule("?delay=-1m").Code)
+	require.Equal(t, http.StatusBadRequest, schedule("?at=2020-01-01T00:00:00Z").Code)
+	require.Equal(t, http.StatusBadRequest, schedule("?delay=10m&at="+time.Now().Add(time.Hour).Format(time.RFC3339)).Code)
+	require.Equal(t, http.StatusBadRequest, schedule("?delay=10000h").Code)
+
+	resp := schedule("?delay=50ms&path=/remind")
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var soon types.ScheduledInvocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&soon))
+	require.Equal(t, types.SchedulePending, soon.Status)
+	resp = schedule("?at=" + time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var later types.ScheduledInvocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&later))
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/schedule", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var scheduled []types.ScheduledInvocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&scheduled))
+	require.Len(t, scheduled, 2)
+	require.Equal(t, soon.ID, scheduled[0].ID)
+
+	cancel := func(id uuid.UUID) int {
+		req := httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String()+"/schedule/"+id.String(), nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	require.Equal(t, http.StatusOK, cancel(later.ID))
+	require.Equal(t, http.StatusConflict, cancel(later.ID))
+	require.Equal(t, http.StatusNotFound, cancel(uuid.New()))
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.RunSchedules(ctx, time.Millisecond*10)
+	select {
+	case got := <-received:
+		require.Equal(t, "POST /live/"+endpoint.ID.String()+"/remind reminder", got)
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the scheduled invocation to be fired")
+	}
+	fired, err := s.store.GetScheduledInvocation(soon.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleFired, fired.Status)
+	require.NotEqual(t, uuid.Nil, fired.InvocationID)
+	canceled, err := s.store.GetScheduledInvocation(later.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleCanceled, canceled.Status)
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		s        = createServer()
+		endpoint = seedEndpoint(t, s)
+		deploy   = types.NewDeployment(endpoint, []byte("a"))
+	)
+	require.Nil(t, s.store.CreateDeployment(deploy))
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"))
+		require.NotEmpty(t, r.Header.Get(shared.ReplayHeader))
+		if len(r.Header.Get(shared.PreviewLogsHeader)) > 0 {
+			w.Header().Set(shared.PreviewLogsHeader, base64.StdEncoding.EncodeToString([]byte("replayed\n")))
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("Content-Type") + " " + string(b)))
+	}))
+	defer ingress.Close()
+	s.WithIngressURL(ingress.URL)
+
+	capture := types.NewCapturedRequest(
+		&types.Capture{SampleRate: 1}, nil,
+		uuid.New(), endpoint.ID, deploy.ID, "POST", "/orders",
+		http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer secret"}},
+		[]byte(`{"foo":"bar"}`),
+	)
+	require.Equal(t, types.RedactedHeader, capture.Header.Get("Authorization"))
+	require.Nil(t, s.metricStore.CreateCapturedRequest(&capture))
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/captures", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var captures []types.CapturedRequest
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&captures))
+	require.Len(t, captures, 1)
+	require.Equal(t, capture.ID, captures[0].ID)
+
+	replay := func(query string) ReplayResponse {
+		req := httptest.NewRequest("POST", "/invocation/"+capture.ID.String()+"/replay"+query, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var replay ReplayResponse
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&replay))
+		return replay
+	}
+	live := replay("")
+	require.Equal(t, http.StatusOK, live.StatusCode)
+	require.Equal(t, "POST /live/"+endpoint.ID.String()+`/orders application/json {"foo":"bar"}`, string(live.Body))
+	require.Empty(t, live.Logs)
+
+	preview := replay("?deployment=" + deploy.ID.String())
+	require.Equal(t, "POST /preview/"+deploy.ID.String()+`/orders application/json {"foo":"bar"}`, string(preview.Body))
+	require.Equal(t, "replayed\n", preview.Logs)
+	require.Equal(t, deploy.ID, preview.DeploymentID)
+
+	req = httptest.NewRequest("POST", "/invocation/"+capture.ID.String()+"/replay?deployment="+uuid.NewString(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
 func seedEndpoint(t *testing.T, s *Server) *types.Endpoint {
 	e := types.NewEndpoint("My endpoint", "go", map[string]string{"FOO": "BAR"})
 	require.Nil(t, s.store.CreateEndpoint(e))
@@ -135,7 +2410,282 @@ func seedEndpoint(t *testing.T, s *Server) *types.Endpoint {
 func createServer() *Server {
 	cache := storage.NewDefaultModCache()
 	store := storage.NewMemoryStore()
-	s := NewServer(store, store, cache)
+	s := NewServer(store, store, cache, policy.New())
 	s.initRouter()
 	return s
 }
+
+func TestEndpointSpec(t *testing.T) {
+	file, err := ParseSpecFile([]byte(`
+endpoints:
+  - name: catfacts
+    runtime: go
+    environment:
+      PORT: "8080"
+    max_concurrency: 10
+    regions: [eu-west]
+    limits:
+      timeout: 2000
+    webhooks:
+      - url: https://example.com/hook
+        events: [deployment.created]
+`))
+	require.Nil(t, err)
+	require.Len(t, file.Endpoints, 1)
+	spec := file.Endpoints[0]
+	require.Equal(t, "8080", spec.Environment["PORT"])
+	require.Equal(t, 2000, spec.Limits.Timeout)
+
+	endpoint := types.NewEndpoint("catfacts", "go", map[string]string{"PORT": "8080", "TOKEN": redacted})
+	endpoint.MaxConcurrency = 10
+	current := NewEndpointSpec(endpoint, nil)
+	require.NotContains(t, current.Environment, "TOKEN")
+	params, changed, err := spec.Diff(current)
+	require.Nil(t, err)
+	require.Equal(t, []string{"limits", "regions"}, changed)
+	require.Equal(t, []string{"eu-west"}, params.Regions)
+	require.Nil(t, params.Environment)
+	require.Nil(t, params.MaxConcurrency)
+
+	// The exported spec applies without changes.
+	endpoint.Regions = []string{"eu-west"}
+	endpoint.Limits = &types.Limits{Timeout: 2000}
+	b, err := SpecFile{Endpoints: []EndpointSpec{NewEndpointSpec(endpoint, nil)}}.YAML()
+	require.Nil(t, err)
+	require.Contains(t, string(b), `PORT: "8080"`)
+	require.NotContains(t, string(b), "cache")
+	exported, err := ParseSpecFile(b)
+	require.Nil(t, err)
+	_, changed, err = exported.Endpoints[0].Diff(NewEndpointSpec(endpoint, nil))
+	require.Nil(t, err)
+	require.Empty(t, changed)
+
+	_, err = ParseSpecFile([]byte("endpoints:\n  - name: a\n    runtime: cobol\n"))
+	require.NotNil(t, err)
+}
+
+func TestEndpointETag(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	path := "/v1/endpoint/" + endpoint.ID.String()
+	call := func(method, etag, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if len(etag) > 0 {
+			req.Header.Set("If-Match", etag)
+		}
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := call("GET", "", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	etag := resp.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, etag, call("GET", "", "").Header().Get("ETag"))
+
+	resp = call("PUT", etag, `{"max_concurrency": 5}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+	updated := resp.Header().Get("ETag")
+	require.NotEqual(t, etag, updated)
+	require.Equal(t, 5, endpoint.MaxConcurrency)
+
+	// The update based on the previous version is rejected.
+	require.Equal(t, http.StatusPreconditionFailed, call("PUT", etag, `{"max_concurrency": 10}`).Code)
+	require.Equal(t, 5, endpoint.MaxConcurrency)
+	require.Equal(t, http.StatusPreconditionFailed, call("DELETE", etag, "").Code)
+	require.Equal(t, http.StatusOK, call("PUT", "*", `{"max_concurrency": 10}`).Code)
+	require.Equal(t, 10, endpoint.MaxConcurrency)
+}
+
+func TestCreateEndpointWithID(t *testing.T) {
+	s := createServer()
+	id := uuid.New()
+	create := func(name string) *httptest.ResponseRecorder {
+		b, err := json.Marshal(CreateEndpointParams{ID: id, Name: name, Runtime: "go"})
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", "/v1/endpoint", bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	resp := create("catfacts")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var endpoint types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	require.Equal(t, id, endpoint.ID)
+
+	// A retry returns the endpoint, another endpoint cannot take the id.
+	resp = create("catfacts")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	require.Equal(t, id, endpoint.ID)
+	require.Equal(t, http.StatusConflict, create("dogfacts").Code)
+	endpoints, err := s.store.GetEndpoints()
+	require.Nil(t, err)
+	require.Len(t, endpoints, 1)
+}
+
+func TestDeleteEndpoint(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	live := types.NewDeployment(endpoint, []byte("live"))
+	old := types.NewDeployment(endpoint, []byte("old"))
+	require.Nil(t, s.store.CreateDeployment(live))
+	require.Nil(t, s.store.CreateDeployment(old))
+	require.Nil(t, s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: live.ID}))
+	require.Nil(t, s.store.CreateWebhook(&types.Webhook{ID: uuid.New(), EndpointID: endpoint.ID, URL: "https://example.com"}))
+	call := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	path := "/v1/endpoint/" + endpoint.ID.String()
+
+	require.Equal(t, http.StatusOK, call("GET", path+"/deployment/"+old.ID.String()))
+	require.Equal(t, http.StatusNotFound, call("GET", path+"/deployment/"+uuid.NewString()))
+	require.Equal(t, http.StatusConflict, call("DELETE", path+"/deployment/"+live.ID.String()))
+	require.Equal(t, http.StatusOK, call("DELETE", path+"/deployment/"+old.ID.String()))
+	_, err := s.store.GetDeployment(old.ID)
+	require.NotNil(t, err)
+
+	composite := seedEndpoint(t, s)
+	require.Nil(t, s.store.UpdateEndpoint(composite.ID, storage.UpdateEndpointParams{
+		Routes: []types.Route{{Prefix: "/api", EndpointID: endpoint.ID}},
+	}))
+	require.Equal(t, http.StatusConflict, call("DELETE", path))
+	require.Nil(t, s.store.UpdateEndpoint(composite.ID, storage.UpdateEndpointParams{Routes: []types.Route{}}))
+
+	require.Equal(t, http.StatusOK, call("DELETE", path))
+	require.Equal(t, http.StatusNotFound, call("GET", path))
+	_, err = s.store.GetDeployment(live.ID)
+	require.NotNil(t, err)
+	webhooks, err := s.store.GetWebhooks(endpoint.ID)
+	require.Nil(t, err)
+	require.Empty(t, webhooks)
+}
+
+func TestStableClient(t *testing.T) {
+	s := createServer()
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+	c := client.New(ts.URL)
+	ctx := context.Background()
+
+	id := client.NameID("payments", "catfacts")
+	req := client.CreateEndpointRequest{ID: id, Name: "catfacts", Runtime: "go", Owner: "payments"}
+	endpoint, err := c.CreateEndpoint(ctx, req)
+	require.Nil(t, err)
+	require.Equal(t, id, endpoint.ID)
+	require.Equal(t, "payments", endpoint.Owner())
+	retried, err := c.CreateEndpoint(ctx, req)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.ETag, retried.ETag)
+
+	maxConcurrency := 5
+	update := client.UpdateEndpointRequest{MaxConcurrency: &maxConcurrency, Regions: []string{"eu-west"}}
+	updated, err := c.UpdateEndpoint(ctx, id, update, endpoint.ETag)
+	require.Nil(t, err)
+	require.Equal(t, 5, updated.MaxConcurrency)
+	require.Equal(t, []string{"eu-west"}, updated.Regions)
+	_, err = c.UpdateEndpoint(ctx, id, update, endpoint.ETag)
+	require.True(t, client.IsPreconditionFailed(err))
+
+	require.Nil(t, c.SetSecret(ctx, id, "TOKEN", "secret"))
+	endpoint, err = c.GetEndpoint(ctx, id)
+	require.Nil(t, err)
+	require.Equal(t, "[redacted]", endpoint.Environment["TOKEN"])
+	endpoint, err = c.RevealEndpoint(ctx, id)
+	require.Nil(t, err)
+	require.Equal(t, "secret", endpoint.Environment["TOKEN"])
+	require.Nil(t, c.DeleteSecret(ctx, id, "TOKEN"))
+
+	deploy, err := c.CreateDeployment(ctx, id, client.CreateDeploymentRequest{Blob: []byte("wasm"), GitCommit: "abc1234"})
+	require.Nil(t, err)
+	require.Equal(t, "abc1234", deploy.GitCommit)
+	_, err = c.Publish(ctx, deploy.ID)
+	require.Nil(t, err)
+	got, err := c.GetDeployment(ctx, id, deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, deploy.Hash, got.Hash)
+	require.True(t, client.IsConflict(c.DeleteDeployment(ctx, id, deploy.ID)))
+
+	webhook, err := c.CreateWebhook(ctx, id, client.CreateWebhookRequest{URL: "https://example.com/hook"})
+	require.Nil(t, err)
+	require.NotEmpty(t, webhook.Secret)
+	got2, err := c.GetWebhook(ctx, id, webhook.ID)
+	require.Nil(t, err)
+	require.Empty(t, got2.Secret)
+	require.Nil(t, c.DeleteWebhook(ctx, id, webhook.ID))
+	_, err = c.GetWebhook(ctx, id, webhook.ID)
+	require.True(t, client.IsNotFound(err))
+
+	endpoint, err = c.GetEndpoint(ctx, id)
+	require.Nil(t, err)
+	require.Nil(t, c.DeleteEndpoint(ctx, id, endpoint.ETag))
+	_, err = c.GetEndpoint(ctx, id)
+	require.True(t, client.IsNotFound(err))
+}
+
+func TestRelease(t *testing.T) {
+	store := storage.NewMemoryStore()
+	s := NewServer(store, store, storage.NewDefaultModCache(), policy.New()).WithAuthorization("root", []config.APIKey{
+		{Name: "github-actions", Key: "ci-key", Role: RoleDeployer, Project: "payments"},
+	})
+	s.initRouter()
+	endpoint := seedEndpoint(t, s)
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+	other := seedEndpoint(t, s)
+	release := func(endpointID uuid.UUID, blob, manifest string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("blob", "blob.wasm")
+		require.Nil(t, err)
+		_, err = part.Write([]byte(blob))
+		require.Nil(t, err)
+		require.Nil(t, mw.WriteField("manifest", manifest))
+		require.Nil(t, mw.Close())
+
+		req := httptest.NewRequest("POST", "/v1/endpoint/"+endpointID.String()+"/release", &body)
+		req.Header.Set("content-type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer ci-key")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+	decode := func(resp *httptest.ResponseRecorder) ReleaseResponse {
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var result ReleaseResponse
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+
+	manifest := `{"git_commit": "3f2a9c1d", "labels": {"env": "production"}}`
+	result := decode(release(endpoint.ID, "v1", manifest))
+	require.True(t, result.Created)
+	require.True(t, result.Published)
+	require.Equal(t, result.DeploymentID, endpoint.ActiveDeploymentID)
+	require.Equal(t, previewURL(result.DeploymentID), result.PreviewURL)
+	require.NotEmpty(t, result.URL)
+
+	// A re-run of the same step changes nothing.
+	again := decode(release(endpoint.ID, "v1", manifest))
+	require.False(t, again.Created)
+	require.True(t, again.Published)
+	require.Equal(t, result.DeploymentID, again.DeploymentID)
+	history, err := store.GetDeploymentEvents(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+
+	preview := decode(release(endpoint.ID, "v2", `{"preview_only": true}`))
+	require.True(t, preview.Created)
+	require.False(t, preview.Published)
+	require.Empty(t, preview.URL)
+	require.Equal(t, result.DeploymentID, endpoint.ActiveDeploymentID)
+
+	require.Equal(t, http.StatusBadRequest, release(endpoint.ID, "v3", `{"git_commit": "not-a-sha"}`).Code)
+	require.Equal(t, http.StatusBadRequest, release(endpoint.ID, "v3", `not json`).Code)
+	// The key of the project cannot release the endpoints of other projects.
+	require.Equal(t, http.StatusNotFound, release(other.ID, "v1", manifest).Code)
+}
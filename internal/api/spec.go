@@ -0,0 +1,243 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/anthdm/raptor/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// SpecFile is the declarative definition of endpoints applied with
+// raptor apply, kept as YAML in the repositories of the teams.
+type SpecFile struct {
+	Endpoints []EndpointSpec `json:"endpoints"`
+}
+
+// EndpointSpec is the declarative definition of an endpoint, identified by
+// its name. The settings left out of a spec (null) are left as they are
+// when the spec is applied, the lists of a spec replace the current ones.
+type EndpointSpec struct {
+	Name    string `json:"name"`
+	Runtime string `json:"runtime"`
+	// Owner the endpoint is created for, the owner of an existing endpoint
+	// is only changed with a transfer.
+	Owner string `json:"owner,omitempty"`
+	// Variables set on the endpoint. The variables left out are kept, so
+	// the secrets can be set out of band instead of being kept in git.
+	Environment       map[string]string          `json:"environment"`
+	SessionAffinity   *types.SessionAffinity     `json:"session_affinity"`
+	Regions           []string                   `json:"regions"`
+	MaxConcurrency    *int                       `json:"max_concurrency"`
+	Probes            []types.Probe              `json:"probes"`
+	StaticResponses   []types.StaticResponse     `json:"static_responses"`
+	Routes            []types.Route              `json:"routes"`
+	Deprecation       *types.Deprecation         `json:"deprecation"`
+	Cache             *types.ResponseCache       `json:"cache"`
+	Compression       *types.Compression         `json:"compression"`
+	Limits            *types.Limits              `json:"limits"`
+	WebSocket         *types.WebSocket           `json:"websocket"`
+	Fallback          *types.Fallback            `json:"fallback"`
+	Retention         *types.RetentionPolicy     `json:"retention"`
+	EnvironmentSchema *types.EnvironmentSchema   `json:"environment_schema"`
+	Retry             *types.RetryPolicy         `json:"retry"`
+	Capture           *types.Capture             `json:"capture"`
+	CORS              *types.CORS                `json:"cors"`
+	Verification      *types.RequestVerification `json:"verification"`
+	JWT               *types.JWTPolicy           `json:"jwt"`
+	Maintenance       *types.Maintenance         `json:"maintenance"`
+	Prewarm           *types.Prewarm             `json:"prewarm"`
+	// Webhooks of the endpoint, the webhooks that are not listed are
+	// deleted. The webhooks are left as they are when there is no list.
+	Webhooks []WebhookSpec `json:"webhooks"`
+}
+
+// WebhookSpec is a webhook of an endpoint spec, identified by its url and
+// its events.
+type WebhookSpec struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// NewEndpointSpec returns the spec of the endpoint with its webhooks. The
+// redacted environment variables are left out.
+func NewEndpointSpec(endpoint *types.Endpoint, webhooks []types.Webhook) EndpointSpec {
+	spec := EndpointSpec{
+		Name:              endpoint.Name,
+		Runtime:           endpoint.Runtime,
+		Owner:             endpoint.Owner(),
+		SessionAffinity:   endpoint.SessionAffinity,
+		Regions:           endpoint.Regions,
+		Probes:            endpoint.Probes,
+		StaticResponses:   endpoint.StaticResponses,
+		Routes:            endpoint.Routes,
+		Deprecation:       endpoint.Deprecation,
+		Compression:       endpoint.Compression,
+		Limits:            endpoint.Limits,
+		WebSocket:         endpoint.WebSocket,
+		Fallback:          endpoint.Fallback,
+		Retention:         endpoint.Retention,
+		EnvironmentSchema: endpoint.EnvironmentSchema,
+		Retry:             endpoint.Retry,
+		Capture:           endpoint.Capture,
+		CORS:              endpoint.CORS,
+		Verification:      endpoint.Verification,
+		JWT:               endpoint.JWT,
+		Maintenance:       endpoint.Maintenance,
+		Prewarm:           endpoint.Prewarm,
+	}
+	for name, value := range endpoint.Environment {
+		if value == redacted {
+			continue
+		}
+		if spec.Environment == nil {
+			spec.Environment = make(map[string]string)
+		}
+		spec.Environment[name] = value
+	}
+	if endpoint.MaxConcurrency > 0 {
+		spec.MaxConcurrency = &endpoint.MaxConcurrency
+	}
+	if endpoint.Cache != nil {
+		// The generation is managed by the purges.
+		cache := *endpoint.Cache
+		cache.Generation = 0
+		spec.Cache = &cache
+	}
+	for _, webhook := range webhooks {
+		spec.Webhooks = append(spec.Webhooks, WebhookSpec{URL: webhook.URL, Events: webhook.Events})
+	}
+	return spec
+}
+
+// Diff returns the update of the current endpoint to the spec and the
+// settings it changes, none when the endpoint matches the spec.
+func (s EndpointSpec) Diff(current EndpointSpec) (UpdateEndpointParams, []string, error) {
+	var params UpdateEndpointParams
+	desired, err := specFields(s)
+	if err != nil {
+		return params, nil, err
+	}
+	actual, err := specFields(current)
+	if err != nil {
+		return params, nil, err
+	}
+	var (
+		changed []string
+		update  = make(map[string]json.RawMessage)
+	)
+	for key, value := range desired {
+		switch key {
+		case "owner", "environment", "webhooks":
+			continue
+		}
+		if bytes.Equal(value, []byte("null")) || bytes.Equal(value, actual[key]) {
+			continue
+		}
+		update[key] = value
+		changed = append(changed, key)
+	}
+	b, err := json.Marshal(update)
+	if err != nil {
+		return params, nil, err
+	}
+	if err := json.Unmarshal(b, &params); err != nil {
+		return params, nil, err
+	}
+	for name, value := range s.Environment {
+		if v, ok := current.Environment[name]; ok && v == value {
+			continue
+		}
+		if params.Environment == nil {
+			params.Environment = make(map[string]*string)
+			changed = append(changed, "environment")
+		}
+		value := value
+		params.Environment[name] = &value
+	}
+	sort.Strings(changed)
+	return params, changed, nil
+}
+
+func specFields(spec EndpointSpec) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	return fields, json.Unmarshal(b, &fields)
+}
+
+// ParseSpecFile parses the YAML of a spec file. The keys of the YAML are
+// those of the JSON API.
+func ParseSpecFile(b []byte) (*SpecFile, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var file SpecFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(file.Endpoints))
+	for i, spec := range file.Endpoints {
+		if len(spec.Name) == 0 {
+			return nil, fmt.Errorf("endpoints[%d] has no name", i)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("endpoint %s is defined twice", spec.Name)
+		}
+		names[spec.Name] = true
+		if !types.ValidRuntime(spec.Runtime) {
+			return nil, fmt.Errorf("endpoint %s has an invalid runtime: %q", spec.Name, spec.Runtime)
+		}
+	}
+	return &file, nil
+}
+
+// YAML returns the YAML of the spec file, the settings that are not
+// set are left out.
+func (f SpecFile) YAML() ([]byte, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, the node keeps the order of the fields.
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// blockStyle drops the null values of the node and formats it in the
+// block style instead of the flow style of JSON.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.MappingNode {
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag == "!!null" {
+				continue
+			}
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+		node.Content = content
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// The status is computed at most once per TTL, the page is public.
+	platformStatusTTL = time.Second * 15
+
+	defaultStatusWindow   = time.Hour
+	defaultStatusInterval = time.Minute * 5
+
+	// Region of the ingresses that are unreachable and do not have a
+	// configured region.
+	unknownRegion = "unknown"
+)
+
+// statusIngress is an ingress shown on the status page.
+type statusIngress struct {
+	// Configured region, the region reported by the ingress is used when
+	// empty.
+	region string
+	client *admin.Client
+}
+
+// statusPage computes the platform status shown on the status page.
+type statusPage struct {
+	window    time.Duration
+	interval  time.Duration
+	ingresses []statusIngress
+
+	mu   sync.Mutex
+	last *types.PlatformStatus
+}
+
+func newStatusPage(c config.Status) *statusPage {
+	p := &statusPage{
+		window:   time.Duration(c.Window),
+		interval: time.Duration(c.Interval),
+	}
+	if p.window <= 0 {
+		p.window = defaultStatusWindow
+	}
+	if p.interval <= 0 {
+		p.interval = defaultStatusInterval
+	}
+	for _, ingress := range c.Ingresses {
+		region, addr, ok := strings.Cut(ingress, "=")
+		if !ok {
+			region, addr = "", ingress
+		}
+		p.ingresses = append(p.ingresses, statusIngress{
+			region: region,
+			client: admin.NewClient(addr, config.Get().APIToken),
+		})
+	}
+	return p
+}
+
+// WithStatusPage configures the ingresses, the window and the interval of
+// the platform status page.
+func (s *Server) WithStatusPage(c config.Status) *Server {
+	s.status = newStatusPage(c)
+	return s
+}
+
+// CreateIncidentParams holds the fields of a new incident banner.
+type CreateIncidentParams struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	// Regions affected by the incident, all the regions when empty.
+	Regions []string `json:"regions"`
+}
+
+func (s *Server) handleGetPlatformStatus(w http.ResponseWriter, r *http.Request) error {
+	status, err := s.platformStatus()
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handlePlatformStatusPage(w http.ResponseWriter, r *http.Request) error {
+	status, err := s.platformStatus()
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return statusPageTemplate.Execute(w, status)
+}
+
+func (s *Server) handleCreateIncident(w http.ResponseWriter, r *http.Request) error {
+	var params CreateIncidentParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	incident := types.NewIncident(params.Title, params.Message, params.Severity, params.Regions)
+	if err := incident.Validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.store.CreateIncident(incident); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	s.status.invalidate()
+	return writeJSON(w, http.StatusOK, incident)
+}
+
+func (s *Server) handleResolveIncident(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.store.ResolveIncident(id, time.Now()); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	s.status.invalidate()
+	incidents, err := s.store.GetIncidents(time.Time{})
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for _, incident := range incidents {
+		if incident.ID == id {
+			return writeJSON(w, http.StatusOK, incident)
+		}
+	}
+	return writeJSON(w, http.StatusNotFound, ErrorResponse(fmt.Errorf("could not find incident with id (%s)", id)))
+}
+
+// platformStatus returns the status of the platform, computed at most once
+// per TTL.
+func (s *Server) platformStatus() (*types.PlatformStatus, error) {
+	p := s.status
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last != nil && time.Since(p.last.UpdatedAT) < platformStatusTTL {
+		return p.last, nil
+	}
+	now := time.Now()
+	incidents, err := s.store.GetIncidents(now.Add(-p.window))
+	if err != nil {
+		return nil, err
+	}
+	requests, err := s.metricStore.GetRequestStats(now.Add(-p.window), p.interval)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []types.RequestStats{}
+	}
+	status := &types.PlatformStatus{
+		Regions:   p.regions(),
+		Incidents: incidents,
+		Requests:  requests,
+		UpdatedAT: now,
+	}
+	status.ApplyIncidents()
+	p.last = status
+	return status, nil
+}
+
+func (p *statusPage) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = nil
+}
+
+// regions polls the admin servers of the ingresses and returns the status
+// of their regions, sorted by name.
+func (p *statusPage) regions() []types.RegionStatus {
+	var (
+		wg      sync.WaitGroup
+		results = make([]types.IngressStatus, len(p.ingresses))
+		regions = make([]string, len(p.ingresses))
+	)
+	for i, ingress := range p.ingresses {
+		wg.Add(1)
+		go func(i int, ingress statusIngress) {
+			defer wg.Done()
+			result := types.IngressStatus{Addr: ingress.client.Addr()}
+			region := ingress.region
+			status, err := ingress.client.Status()
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Healthy = !status.Draining
+				result.Draining = status.Draining
+				if len(region) == 0 {
+					region = status.Region
+				}
+			}
+			if len(region) == 0 {
+				region = unknownRegion
+			}
+			results[i], regions[i] = result, region
+		}(i, ingress)
+	}
+	wg.Wait()
+
+	byRegion := make(map[string][]types.IngressStatus)
+	for i, result := range results {
+		byRegion[regions[i]] = append(byRegion[regions[i]], result)
+	}
+	status := make([]types.RegionStatus, 0, len(byRegion))
+	for region, ingresses := range byRegion {
+		status = append(status, types.NewRegionStatus(region, ingresses))
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Region < status[j].Region
+	})
+	return status
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(s types.RequestStats) string {
+		return fmt.Sprintf("%.2f%%", s.ErrorRate()*100)
+	},
+	"time": func(v any) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format("2006-01-02 15:04 UTC")
+		case *time.Time:
+			return t.UTC().Format("2006-01-02 15:04 UTC")
+		}
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Platform status</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; }
+td, th { text-align: left; padding: .25rem .5rem; border-bottom: 1px solid #ddd; }
+.operational { color: #1a7f37; } .degraded { color: #9a6700; } .outage { color: #cf222e; }
+.incident { border-left: 4px solid #9a6700; padding: .5rem 1rem; margin-bottom: 1rem; background: #fff8c5; }
+.incident.critical { border-color: #cf222e; background: #ffebe9; }
+.incident.resolved { border-color: #1a7f37; background: #dafbe1; }
+</style>
+</head>
+<body>
+<h1>Platform status: <span class="{{.Status}}">{{.Status}}</span></h1>
+{{range .Incidents}}
+<div class="incident {{.Severity}}{{if .ResolvedAT}} resolved{{end}}">
+<strong>{{.Title}}</strong>{{if .ResolvedAT}} (resolved {{time .ResolvedAT}}){{end}}
+<p>{{.Message}}</p>
+<small>{{.Severity}}{{if .Regions}} &middot; {{range $i, $r := .Regions}}{{if $i}}, {{end}}{{$r}}{{end}}{{end}} &middot; since {{time .CreatedAT}}</small>
+</div>
+{{end}}
+<h2>Regions</h2>
+<table>
+<tr><th>Region</th><th>Status</th><th>Ingresses</th></tr>
+{{range .Regions}}<tr><td>{{.Region}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{len .Ingresses}}</td></tr>
+{{else}}<tr><td colspan="3">No ingresses are monitored.</td></tr>
+{{end}}
+</table>
+<h2>Requests</h2>
+<table>
+<tr><th>Since</th><th>Requests</th><th>Error rate</th></tr>
+{{range .Requests}}<tr><td>{{time .Start}}</td><td>{{.Requests}}</td><td>{{percent .}}</td></tr>
+{{else}}<tr><td colspan="3">No requests were served recently.</td></tr>
+{{end}}
+</table>
+<small>Updated {{time .UpdatedAT}}</small>
+</body>
+</html>
+`))
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// TransferParams holds the owner an endpoint is transferred to.
+type TransferParams struct {
+	To string `json:"to"`
+}
+
+// AcceptTransferParams holds the receiving owner of a pending transfer. Keys
+// of a project accept the transfers to their project and may leave it out.
+type AcceptTransferParams struct {
+	Owner string `json:"owner"`
+}
+
+// handleTransfer records the request of the current owner to transfer the
+// endpoint, the endpoint is not moved until the receiving side accepts it.
+func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params TransferParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var current types.Ownership
+	if endpoint.Ownership != nil {
+		current = *endpoint.Ownership
+	}
+	ownership, err := current.RequestTransfer(params.To, actor(r))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	event := types.NewTransferEvent(types.DeploymentEventTransferRequest, endpoint, current.Owner, params.To, actor(r))
+	return s.updateOwnership(w, r, endpoint, ownership, event)
+}
+
+// handleAcceptTransfer moves the endpoint to the receiving owner of its
+// pending transfer. Deployments and metrics belong to the endpoint and move
+// along with it. Keys of a project only accept the transfers to their
+// project, so the requesting project cannot accept its own transfer.
+func (s *Server) handleAcceptTransfer(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params AcceptTransferParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		err := fmt.Errorf("failed to parse the request body: %s", err)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var current types.Ownership
+	if endpoint.Ownership != nil {
+		current = *endpoint.Ownership
+	}
+	owner := params.Owner
+	if key := apiKey(r); key != nil && len(key.Project) > 0 {
+		if len(owner) > 0 && owner != key.Project {
+			err := fmt.Errorf("%w: the key of project %q cannot accept a transfer to %q", errForbidden, key.Project, owner)
+			return writeJSON(w, http.StatusForbidden, ErrorResponse(err))
+		}
+		if current.Transfer != nil && current.Transfer.To != key.Project {
+			err := fmt.Errorf("%w: the pending transfer is addressed to %q, not to project %q", errForbidden, current.Transfer.To, key.Project)
+			return writeJSON(w, http.StatusForbidden, ErrorResponse(err))
+		}
+		owner = key.Project
+	}
+	ownership, err := current.AcceptTransfer(owner)
+	if err != nil {
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	event := types.NewTransferEvent(types.DeploymentEventTransferAccept, endpoint, current.Owner, owner, actor(r))
+	return s.updateOwnership(w, r, endpoint, ownership, event)
+}
+
+// updateOwnership stores the ownership of the endpoint and records the step
+// of the transfer in the history of the endpoint.
+func (s *Server) updateOwnership(w http.ResponseWriter, r *http.Request, endpoint *types.Endpoint, ownership types.Ownership, event *types.DeploymentEvent) error {
+	if err := s.store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{Ownership: &ownership}); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if err := s.store.CreateDeploymentEvent(event); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	updated, err := s.store.GetEndpoint(endpoint.ID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, withoutSecrets(r, updated))
+}
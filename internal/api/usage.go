@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/quota"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// UsageResponse holds the consumption of a project in the current period
+// together with its quota.
+type UsageResponse struct {
+	Usage *types.Usage `json:"usage"`
+	Quota config.Quota `json:"quota"`
+}
+
+// handleGetUsage returns the usage of the projects that own endpoints, only
+// of the project given with the project query parameter when it is set.
+// Keys of a project only get the usage of their project.
+func (s *Server) handleGetUsage(w http.ResponseWriter, r *http.Request) error {
+	var projects []string
+	if key := apiKey(r); key != nil && len(key.Project) > 0 {
+		projects = []string{key.Project}
+	} else if r.URL.Query().Has("project") {
+		projects = []string{r.URL.Query().Get("project")}
+	} else {
+		var err error
+		if projects, err = s.quotas.Projects(); err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+	}
+	resp := []UsageResponse{}
+	for _, project := range projects {
+		usage, err := s.quotas.Usage(project)
+		if err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		resp = append(resp, UsageResponse{Usage: usage, Quota: s.quotas.Quota(project)})
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// quotaStatus returns the status code of an error of a quota check.
+func quotaStatus(err error) int {
+	if errors.Is(err, quota.ErrExceeded) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
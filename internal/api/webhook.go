@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateWebhookParams holds the URL the events of an endpoint are posted to.
+type CreateWebhookParams struct {
+	URL string `json:"url"`
+	// Types of the events posted to the URL, all of them when empty.
+	Events []string `json:"events,omitempty"`
+	// Key of the signatures of the deliveries, generated when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// handleCreateWebhook registers a webhook of the endpoint. The response holds
+// the secret of the webhook, it is not returned afterwards.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	var params CreateWebhookParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	webhook := &types.Webhook{
+		ID:         uuid.New(),
+		EndpointID: endpointID,
+		URL:        params.URL,
+		Secret:     params.Secret,
+		Events:     params.Events,
+		CreatedAT:  time.Now(),
+	}
+	if err := webhook.Validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if len(webhook.Secret) == 0 {
+		if webhook.Secret, err = notify.NewSecret(); err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+	}
+	if err := s.store.CreateWebhook(webhook); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, webhook)
+}
+
+// handleGetWebhooks returns the webhooks of the endpoint without their
+// secrets.
+func (s *Server) handleGetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	webhooks, err := s.store.GetWebhooks(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	if webhooks == nil {
+		webhooks = []types.Webhook{}
+	}
+	return writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	webhooks, err := s.store.GetWebhooks(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	for _, webhook := range webhooks {
+		if webhook.ID != webhookID {
+			continue
+		}
+		if err := s.store.DeleteWebhook(webhookID); err != nil {
+			return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+		}
+		return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+	}
+	err = fmt.Errorf("could not find webhook with id (%s)", webhookID)
+	return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+}
+
+// handleGetEvents returns the platform events of the endpoint, oldest first.
+func (s *Server) handleGetEvents(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	events, err := s.store.GetEvents(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	if events == nil {
+		events = []types.Event{}
+	}
+	return writeJSON(w, http.StatusOK, events)
+}
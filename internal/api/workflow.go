@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// handleStartWorkflow starts a workflow of the LIVE deployment of the
+// endpoint and returns it right away, its status and state are polled with
+// GET /workflow/{id}. Every step of the workflow is invoked with the request
+// of the call, given like the asynchronous invocations.
+func (s *Server) handleStartWorkflow(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if !endpoint.HasActiveDeploy() {
+		err := fmt.Errorf("endpoint (%s) has no LIVE deployment", endpoint.ID)
+		return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+	}
+	req, status, err := readAsyncRequest(r)
+	if err != nil {
+		return writeJSON(w, status, ErrorResponse(err))
+	}
+	workflow, err := s.workflows.Start(endpoint, req)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	w.Header().Set("Location", "/workflow/"+workflow.ID.String())
+	return writeJSON(w, http.StatusAccepted, workflow)
+}
+
+func (s *Server) handleGetWorkflow(w http.ResponseWriter, r *http.Request) error {
+	workflowID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	workflow, err := s.store.GetWorkflow(workflowID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, workflow)
+}
+
+// RunWorkflows wakes up the sleeping workflows that are due once per
+// interval, until the context is done.
+func (s *Server) RunWorkflows(ctx context.Context, interval time.Duration) {
+	s.workflows.Run(ctx, interval)
+}
@@ -0,0 +1,316 @@
+// Package artifact pulls the WASM modules of deployments from OCI registries
+// and plain https URLs.
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Media types of the manifests and of the WASM layers of OCI artifacts.
+const (
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeWasm        = "application/wasm"
+	mediaTypeWasmLayer   = "application/vnd.wasm.content.layer.v1+wasm"
+	maxManifestSize      = 4 << 20
+)
+
+// Artifact is a pulled WASM module.
+type Artifact struct {
+	Blob []byte
+	// Digest of the blob, as sha256:<hex>.
+	Digest string
+}
+
+// Fetcher pulls artifacts from oci:// references and https:// URLs.
+type Fetcher struct {
+	client  *http.Client
+	maxSize int
+}
+
+// NewFetcher returns a new fetcher that refuses artifacts larger than
+// maxSize bytes, 0 means unlimited.
+func NewFetcher(maxSize int) *Fetcher {
+	return &Fetcher{
+		client:  &http.Client{Timeout: time.Minute},
+		maxSize: maxSize,
+	}
+}
+
+// WithClient replaces the HTTP client of the fetcher.
+func (f *Fetcher) WithClient(client *http.Client) *Fetcher {
+	f.client = client
+	return f
+}
+
+// Fetch pulls the artifact of the reference, either
+// oci://registry/repository[:tag|@digest] or an https:// URL. The digest of
+// the artifact is verified against the registry and against the given
+// digest when it is not empty.
+func (f *Fetcher) Fetch(ctx context.Context, ref string, digest string) (*Artifact, error) {
+	var (
+		artifact *Artifact
+		err      error
+	)
+	switch {
+	case strings.HasPrefix(ref, "oci://"):
+		artifact, err = f.fetchOCI(ctx, strings.TrimPrefix(ref, "oci://"))
+	case strings.HasPrefix(ref, "https://"):
+		artifact, err = f.fetchURL(ctx, ref)
+	default:
+		return nil, fmt.Errorf("invalid artifact reference %q, expected oci:// or https://", ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) > 0 && digest != artifact.Digest {
+		return nil, fmt.Errorf("artifact digest %s does not match the expected digest %s", artifact.Digest, digest)
+	}
+	return artifact, nil
+}
+
+func (f *Fetcher) fetchURL(ctx context.Context, rawURL string) (*Artifact, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to pull %s: status %d", rawURL, resp.StatusCode)
+	}
+	blob, err := readLimited(resp.Body, f.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Blob: blob, Digest: digestOf(blob)}, nil
+}
+
+// reference is a parsed OCI reference.
+type reference struct {
+	registry   string
+	repository string
+	// Tag or digest of the manifest.
+	reference string
+}
+
+func parseReference(ref string) (reference, error) {
+	registry, rest, ok := strings.Cut(ref, "/")
+	if !ok || len(registry) == 0 || len(rest) == 0 {
+		return reference{}, fmt.Errorf("invalid oci reference %q, expected oci://registry/repository:tag", ref)
+	}
+	r := reference{registry: registry, repository: rest, reference: "latest"}
+	if repository, digest, ok := strings.Cut(rest, "@"); ok {
+		r.repository, r.reference = repository, digest
+	} else if i := strings.LastIndex(rest, ":"); i > 0 {
+		r.repository, r.reference = rest[:i], rest[i+1:]
+	}
+	if len(r.repository) == 0 || len(r.reference) == 0 {
+		return reference{}, fmt.Errorf("invalid oci reference %q, expected oci://registry/repository:tag", ref)
+	}
+	return r, nil
+}
+
+// manifest is the part of an OCI image manifest that is needed to pull the
+// WASM layer.
+type manifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"layers"`
+}
+
+// fetchOCI pulls the WASM layer of the artifact through the distribution API
+// of the registry.
+func (f *Fetcher) fetchOCI(ctx context.Context, ref string) (*Artifact, error) {
+	r, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("https://%s/v2/%s", r.registry, r.repository)
+	session := &registrySession{fetcher: f}
+
+	resp, err := session.get(ctx, base+"/manifests/"+r.reference, mediaTypeOCIManifest)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readLimited(resp.Body, maxManifestSize)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(r.reference, "sha256:") && digestOf(b) != r.reference {
+		return nil, fmt.Errorf("manifest digest %s does not match the reference %s", digestOf(b), r.reference)
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+	var layer string
+	for _, l := range m.Layers {
+		if l.MediaType == mediaTypeWasm || l.MediaType == mediaTypeWasmLayer {
+			if len(layer) > 0 {
+				return nil, fmt.Errorf("artifact %s has more than one WASM layer", ref)
+			}
+			if f.maxSize > 0 && l.Size > int64(f.maxSize) {
+				return nil, fmt.Errorf("artifact exceeds the maximum of %d bytes", f.maxSize)
+			}
+			layer = l.Digest
+		}
+	}
+	if len(layer) == 0 {
+		return nil, fmt.Errorf("artifact %s has no WASM layer", ref)
+	}
+
+	resp, err = session.get(ctx, base+"/blobs/"+layer, "")
+	if err != nil {
+		return nil, err
+	}
+	blob, err := readLimited(resp.Body, f.maxSize)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if digestOf(blob) != layer {
+		return nil, fmt.Errorf("layer digest %s does not match the manifest digest %s", digestOf(blob), layer)
+	}
+	return &Artifact{Blob: blob, Digest: layer}, nil
+}
+
+// registrySession performs the requests of a pull, it authenticates with
+// an anonymous bearer token when the registry asks for one.
+type registrySession struct {
+	fetcher *Fetcher
+	token   string
+}
+
+func (s *registrySession) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	resp, err := s.do(ctx, rawURL, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && len(s.token) == 0 {
+		resp.Body.Close()
+		if s.token, err = s.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+		if resp, err = s.do(ctx, rawURL, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to pull %s: status %d", rawURL, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (s *registrySession) do(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return s.fetcher.client.Do(req)
+}
+
+// authenticate requests an anonymous pull token from the realm of the
+// Bearer challenge of the registry.
+func (s *registrySession) authenticate(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", scheme)
+	}
+	values := parseChallenge(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return "", fmt.Errorf("invalid authentication realm %q", values["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if v, ok := values[key]; ok {
+			query.Set(key, v)
+		}
+	}
+	realm.RawQuery = query.Encode()
+	resp, err := s.do(ctx, realm.String(), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry refused the pull token: status %d", resp.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&token); err != nil {
+		return "", err
+	}
+	if len(token.Token) > 0 {
+		return token.Token, nil
+	}
+	if len(token.AccessToken) > 0 {
+		return token.AccessToken, nil
+	}
+	return "", fmt.Errorf("registry responded without a pull token")
+}
+
+// parseChallenge parses the comma separated key="value" parameters of an
+// authentication challenge.
+func parseChallenge(params string) map[string]string {
+	values := make(map[string]string)
+	for len(params) > 0 {
+		key, rest, ok := strings.Cut(strings.TrimLeft(params, " ,"), "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = value
+		params = rest
+	}
+	return values
+}
+
+// readLimited reads at most max bytes of the body, 0 means unlimited.
+func readLimited(body io.Reader, max int) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(body)
+	}
+	b, err := io.ReadAll(io.LimitReader(body, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > max {
+		return nil, fmt.Errorf("artifact exceeds the maximum of %d bytes", max)
+	}
+	return b, nil
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newRegistry serves a single WASM artifact as org/func:v1 and hands out
+// anonymous pull tokens like the public registries do.
+func newRegistry(t *testing.T, blob []byte) (*httptest.Server, string) {
+	var (
+		layer = digestOf(blob)
+		mux   = http.NewServeMux()
+		srv   = httptest.NewTLSServer(mux)
+	)
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOCIManifest,
+		"layers": []map[string]any{
+			{"mediaType": mediaTypeWasm, "digest": layer, "size": len(blob)},
+		},
+	})
+	require.Nil(t, err)
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") == "Bearer secret" {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:org/func:pull"`, srv.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "repository:org/func:pull", r.URL.Query().Get("scope"))
+		w.Write([]byte(`{"token": "secret"}`))
+	})
+	mux.HandleFunc("/v2/org/func/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		ref := strings.TrimPrefix(r.URL.Path, "/v2/org/func/manifests/")
+		if ref != "v1" && ref != digestOf(manifest) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(manifest)
+	})
+	mux.HandleFunc("/v2/org/func/blobs/"+layer, func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			w.Write(blob)
+		}
+	})
+	mux.HandleFunc("/func.wasm", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(blob)
+	})
+	t.Cleanup(srv.Close)
+	return srv, digestOf(manifest)
+}
+
+func TestFetchOCI(t *testing.T) {
+	blob := []byte("somefakeblob")
+	srv, manifestDigest := newRegistry(t, blob)
+	f := NewFetcher(0).WithClient(srv.Client())
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	artifact, err := f.Fetch(context.Background(), "oci://"+host+"/org/func:v1", "")
+	require.Nil(t, err)
+	require.Equal(t, blob, artifact.Blob)
+	require.Equal(t, digestOf(blob), artifact.Digest)
+
+	_, err = f.Fetch(context.Background(), "oci://"+host+"/org/func@"+manifestDigest, digestOf(blob))
+	require.Nil(t, err)
+
+	_, err = f.Fetch(context.Background(), "oci://"+host+"/org/func:v1", digestOf([]byte("other")))
+	require.ErrorContains(t, err, "does not match the expected digest")
+	_, err = f.Fetch(context.Background(), "oci://"+host+"/org/func:v2", "")
+	require.ErrorContains(t, err, "status 404")
+
+	_, err = NewFetcher(4).WithClient(srv.Client()).Fetch(context.Background(), "oci://"+host+"/org/func:v1", "")
+	require.ErrorContains(t, err, "exceeds the maximum")
+}
+
+func TestFetchURL(t *testing.T) {
+	blob := []byte("somefakeblob")
+	srv, _ := newRegistry(t, blob)
+	f := NewFetcher(0).WithClient(srv.Client())
+
+	artifact, err := f.Fetch(context.Background(), srv.URL+"/func.wasm", digestOf(blob))
+	require.Nil(t, err)
+	require.Equal(t, blob, artifact.Blob)
+
+	_, err = f.Fetch(context.Background(), "http://example.com/func.wasm", "")
+	require.ErrorContains(t, err, "invalid artifact reference")
+}
+
+func TestParseReference(t *testing.T) {
+	for _, test := range []struct {
+		ref      string
+		expected reference
+	}{
+		{"ghcr.io/org/func:tag", reference{"ghcr.io", "org/func", "tag"}},
+		{"ghcr.io/org/func", reference{"ghcr.io", "org/func", "latest"}},
+		{"localhost:5000/func@sha256:abc", reference{"localhost:5000", "func", "sha256:abc"}},
+	} {
+		r, err := parseReference(test.ref)
+		require.Nil(t, err)
+		require.Equal(t, test.expected, r)
+	}
+	_, err := parseReference("ghcr.io")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,142 @@
+package assets
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxBundleSize is the maximum size of an uncompressed asset bundle.
+	MaxBundleSize = 64 << 20
+
+	indexFile = "index.html"
+
+	// HTML documents are revalidated on every request so that a new
+	// deployment becomes visible right away, the other assets can be cached
+	// for a while and are revalidated with their ETag afterwards.
+	htmlCacheControl  = "no-cache"
+	assetCacheControl = "public, max-age=3600"
+)
+
+// Asset is a single file of an asset bundle.
+type Asset struct {
+	Name         string
+	ContentType  string
+	ETag         string
+	CacheControl string
+	Body         []byte
+}
+
+// Bundle is a set of static assets (HTML/CSS/JS) shipped along with a
+// deployment that are served by the ingress without invoking the WASM module.
+type Bundle struct {
+	assets    map[string]*Asset
+	createdAT time.Time
+}
+
+// Open reads the asset bundle from the given zip archive. Asset paths are
+// relative to the root of the archive, directories are ignored.
+func Open(b []byte, createdAT time.Time) (*Bundle, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset bundle: %w", err)
+	}
+	bundle := &Bundle{
+		assets:    make(map[string]*Asset, len(r.File)),
+		createdAT: createdAT,
+	}
+	var size uint64
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean("/" + f.Name)
+		size += f.UncompressedSize64
+		if size > MaxBundleSize {
+			return nil, fmt.Errorf("asset bundle exceeds the maximum of %d bytes", MaxBundleSize)
+		}
+		body, err := readFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", name, err)
+		}
+		bundle.assets[name] = newAsset(name, body)
+	}
+	return bundle, nil
+}
+
+// Validate returns an error if the given bytes are not a valid asset bundle.
+func Validate(b []byte) error {
+	_, err := Open(b, time.Time{})
+	return err
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, MaxBundleSize+1))
+}
+
+func newAsset(name string, body []byte) *Asset {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(body)
+	}
+	cacheControl := assetCacheControl
+	if strings.HasPrefix(contentType, "text/html") {
+		cacheControl = htmlCacheControl
+	}
+	sum := sha256.Sum256(body)
+	return &Asset{
+		Name:         name,
+		ContentType:  contentType,
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		CacheControl: cacheControl,
+		Body:         body,
+	}
+}
+
+// Len returns the amount of assets in the bundle.
+func (b *Bundle) Len() int {
+	return len(b.assets)
+}
+
+// Lookup returns the asset for the given request path. The index.html of a
+// directory is served for paths pointing to that directory. nil is returned
+// when the bundle has no matching asset.
+func (b *Bundle) Lookup(p string) *Asset {
+	name := path.Clean("/" + p)
+	if asset, ok := b.assets[name]; ok {
+		return asset
+	}
+	return b.assets[path.Join(name, indexFile)]
+}
+
+// Serve serves the asset for the request path with its caching headers.
+// It returns false without writing anything when the request is not a GET or
+// HEAD request or the bundle has no matching asset.
+func (b *Bundle) Serve(w http.ResponseWriter, r *http.Request, p string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	asset := b.Lookup(p)
+	if asset == nil {
+		return false
+	}
+	w.Header().Set("Content-Type", asset.ContentType)
+	w.Header().Set("Cache-Control", asset.CacheControl)
+	w.Header().Set("ETag", asset.ETag)
+	http.ServeContent(w, r, asset.Name, b.createdAT, bytes.NewReader(asset.Body))
+	return true
+}
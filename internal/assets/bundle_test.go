@@ -0,0 +1,121 @@
+package assets
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func makeBundle(t *testing.T, files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, body := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if err := Validate([]byte("not a zip archive")); err == nil {
+		t.Fatal("expected an error for an invalid bundle")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	bundle, err := Open(makeBundle(t, map[string]string{
+		"index.html":      "<html></html>",
+		"css/style.css":   "body {}",
+		"docs/index.html": "<html>docs</html>",
+	}), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Len() != 3 {
+		t.Fatalf("expected 3 assets got %d", bundle.Len())
+	}
+	cases := map[string]string{
+		"/":              "/index.html",
+		"/css/style.css": "/css/style.css",
+		"/docs":          "/docs/index.html",
+		"/docs/":         "/docs/index.html",
+		"/../index.html": "/index.html",
+	}
+	for path, name := range cases {
+		asset := bundle.Lookup(path)
+		if asset == nil {
+			t.Fatalf("expected an asset for %s", path)
+		}
+		if asset.Name != name {
+			t.Errorf("expected %s for %s got %s", name, path, asset.Name)
+		}
+	}
+	if asset := bundle.Lookup("/api/users"); asset != nil {
+		t.Errorf("expected no asset got %s", asset.Name)
+	}
+}
+
+func TestServe(t *testing.T) {
+	bundle, err := Open(makeBundle(t, map[string]string{
+		"index.html":    "<html></html>",
+		"css/style.css": "body {}",
+	}), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/css/style.css", nil)
+	if !bundle.Serve(w, r, "/css/style.css") {
+		t.Fatal("expected the asset to be served")
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", w.Code)
+	}
+	if w.Body.String() != "body {}" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != assetCacheControl {
+		t.Errorf("unexpected cache control %s", cc)
+	}
+	etag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/css/style.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	bundle.Serve(w, r, "/css/style.css")
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/", nil)
+	bundle.Serve(w, r, "/")
+	if cc := w.Header().Get("Cache-Control"); cc != htmlCacheControl {
+		t.Errorf("unexpected cache control for html %s", cc)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/css/style.css", nil)
+	if bundle.Serve(w, r, "/css/style.css") {
+		t.Error("expected POST requests not to be served")
+	}
+	r = httptest.NewRequest("GET", "/api", nil)
+	if bundle.Serve(w, r, "/api") {
+		t.Error("expected unknown paths not to be served")
+	}
+}
@@ -0,0 +1,229 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// Sources of the asynchronous invocations.
+const (
+	// SourceAsync is an invocation of the async invocation API.
+	SourceAsync = "async"
+	// SourceRedrive is the re-drive of a dead letter.
+	SourceRedrive = "redrive"
+	// SourceGuest is an invocation by the guest of another endpoint.
+	SourceGuest = "guest"
+	// SourceWorkflow is a step of a workflow.
+	SourceWorkflow = "workflow"
+	// SourceSchedule is a scheduled invocation.
+	SourceSchedule = "schedule"
+)
+
+// AttemptHeader holds the attempt of an asynchronous invocation, starting at
+// 1, so the guests can tell the retries apart.
+const AttemptHeader = "Raptor-Attempt"
+
+// maxResponseSize is the maximum size of a response that is kept.
+const maxResponseSize = 10 << 20
+
+// MaxBodySize is the maximum size of the body of an asynchronous invocation.
+const MaxBodySize = 10 << 20
+
+// Result is the response of an asynchronous invocation.
+type Result struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Attempts   int         `json:"attempts"`
+	// Dead letter of the request, set when the invocation failed.
+	DeadLetterID uuid.UUID `json:"dead_letter_id"`
+}
+
+// Invoker performs the asynchronous invocations of the endpoints through the
+// ingress. Failed attempts are retried following the retry policy of the
+// endpoint, the requests that fail every attempt are stored as dead letters.
+type Invoker struct {
+	store      storage.Store
+	client     *http.Client
+	ingressURL string
+	policy     types.RetryPolicy
+	// Secret the attempts are signed with for the ingress.
+	secret []byte
+}
+
+// New returns a new invoker given the URL of the ingress and the default
+// retry policy.
+func New(store storage.Store, ingressURL string, c config.Async) *Invoker {
+	return &Invoker{
+		store:      store,
+		client:     &http.Client{},
+		ingressURL: ingressURL,
+		policy: types.RetryPolicy{
+			MaxAttempts: c.MaxAttempts,
+			Backoff:     int(time.Duration(c.Backoff).Milliseconds()),
+			MaxBackoff:  int(time.Duration(c.MaxBackoff).Milliseconds()),
+		},
+		secret: Secret(c),
+	}
+}
+
+// Invoke invokes the LIVE deployment of the endpoint with the request until
+// an attempt succeeds or the attempts of the retry policy are used up. The
+// request is stored as a dead letter of the source when the invocation
+// failed, the result is its last attempt and holds the id of the letter.
+// Client errors (4xx other than 429) are not retried.
+func (i *Invoker) Invoke(ctx context.Context, endpoint *types.Endpoint, source string, req types.AsyncRequest) (*Result, error) {
+	return i.invoke(ctx, endpoint, source, uuid.Nil, uuid.Nil, req)
+}
+
+// Step invokes the endpoint with the request like Invoke, as a step of the
+// workflow. The ingress hands the id of the workflow to the guest.
+func (i *Invoker) Step(ctx context.Context, endpoint *types.Endpoint, workflowID uuid.UUID, req types.AsyncRequest) (*Result, error) {
+	return i.invoke(ctx, endpoint, SourceWorkflow, workflowID, uuid.Nil, req)
+}
+
+func (i *Invoker) invoke(ctx context.Context, endpoint *types.Endpoint, source string, workflowID, invocationID uuid.UUID, req types.AsyncRequest) (*Result, error) {
+	policy := endpoint.Retry.Bound(i.policy)
+	attempts := max(policy.MaxAttempts, 1)
+	var (
+		result *Result
+		err    error
+	)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(policy.Delay(attempt - 1)):
+			}
+		}
+		result, err = i.attempt(ctx, endpoint.ID, workflowID, invocationID, req, attempt)
+		if err == nil && !retryable(result.StatusCode) {
+			break
+		}
+	}
+	if err == nil && result.StatusCode < 400 {
+		return result, nil
+	}
+	if result == nil {
+		result = &Result{Attempts: attempts}
+	}
+	letter := &types.DeadLetter{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		Source:     source,
+		Request:    req,
+		Attempts:   result.Attempts,
+		StatusCode: result.StatusCode,
+		Error:      string(bytes.TrimSpace(truncate(result.Body, 1024))),
+		CreatedAT:  time.Now(),
+	}
+	if err != nil {
+		letter.Error = err.Error()
+	} else {
+		err = fmt.Errorf("status code %d", result.StatusCode)
+	}
+	if err := i.store.CreateDeadLetter(letter); err != nil {
+		slog.Error("failed to store dead letter", "err", err, "endpoint", endpoint.ID)
+	} else {
+		result.DeadLetterID = letter.ID
+	}
+	return result, fmt.Errorf("invocation failed after %d attempts, moved to dead letter (%s): %w", letter.Attempts, letter.ID, err)
+}
+
+// Run invokes the endpoint and stores the result of the invocation. A failed
+// invocation refers to the dead letter of its request. The ingress hands the
+// id of the invocation to the guest, the runtime stores the progress the
+// guest reports on it.
+func (i *Invoker) Run(endpoint *types.Endpoint, invocation *types.Invocation, source string, req types.AsyncRequest) {
+	invocation.Status = types.InvocationRunning
+	if err := i.store.UpdateInvocation(invocation); err != nil {
+		slog.Error("failed to update invocation", "err", err, "id", invocation.ID)
+	}
+	result, err := i.invoke(context.Background(), endpoint, source, uuid.Nil, invocation.ID, req)
+	// Keep the progress the runtime stored while the guest was running.
+	if stored, err := i.store.GetInvocation(invocation.ID); err == nil {
+		invocation.Progress = stored.Progress
+		invocation.ProgressMessage = stored.ProgressMessage
+	}
+	if err != nil {
+		invocation.Status = types.InvocationFailed
+		invocation.Error = err.Error()
+	} else {
+		invocation.Status = types.InvocationSucceeded
+	}
+	if result != nil {
+		invocation.StatusCode = result.StatusCode
+		invocation.Header = result.Header
+		invocation.Body = result.Body
+		invocation.Attempts = result.Attempts
+		invocation.DeadLetterID = result.DeadLetterID
+	}
+	now := time.Now()
+	invocation.FinishedAT = &now
+	if err := i.store.UpdateInvocation(invocation); err != nil {
+		slog.Error("failed to update invocation", "err", err, "id", invocation.ID)
+	}
+}
+
+func (i *Invoker) attempt(ctx context.Context, endpointID, workflowID, invocationID uuid.UUID, r types.AsyncRequest, attempt int) (*Result, error) {
+	url := fmt.Sprintf("%s/live/%s%s", i.ingressURL, endpointID, r.Path)
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	// Every attempt is signed when it is sent, the requests stored for the
+	// retries, the sleeping workflows and the schedules carry no signature
+	// that could expire. The workflow of a step is only carried by the
+	// signature, never by the header of a stored request, like the
+	// invocation of an attempt.
+	req.Header.Del(shared.WorkflowIDHeader)
+	req.Header.Del(shared.InvocationIDHeader)
+	if len(i.secret) > 0 {
+		req.Header.Set(InternalHeader, SignInternal(i.secret, time.Now(), req.Method, req.URL.Path, workflowID, invocationID))
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Attempts:   attempt,
+	}, nil
+}
+
+// retryable returns true if an attempt answered with the status code can
+// succeed when it is retried.
+func retryable(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -0,0 +1,257 @@
+package async
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke(t *testing.T) {
+	var (
+		attempts atomic.Int32
+		status   atomic.Int32
+	)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := attempts.Add(1)
+		require.Equal(t, "POST", r.Method)
+		require.Equal(t, "bar", r.Header.Get("foo"))
+		require.Equal(t, strconv.Itoa(int(n)), r.Header.Get(AttemptHeader))
+		workflowID, invocationID, err := VerifyInternal([]byte("secret"), r.Header.Get(InternalHeader), r.Method, r.URL.Path, time.Now())
+		require.Nil(t, err)
+		require.Equal(t, uuid.Nil, workflowID)
+		require.Equal(t, uuid.Nil, invocationID)
+		if n < 3 {
+			w.WriteHeader(int(status.Load()))
+			w.Write([]byte("try again"))
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer ingress.Close()
+
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	invoker := New(store, ingress.URL, config.Async{
+		MaxAttempts: 3,
+		Backoff:     config.Duration(time.Millisecond),
+		Secret:      "secret",
+	})
+	req := types.AsyncRequest{
+		Method: "POST",
+		Path:   "/orders",
+		Header: http.Header{"Foo": {"bar"}},
+		Body:   []byte("{}"),
+	}
+
+	status.Store(http.StatusServiceUnavailable)
+	result, err := invoker.Invoke(context.Background(), endpoint, SourceRedrive, req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, 3, result.Attempts)
+	require.Equal(t, "done", string(result.Body))
+	letters, err := store.GetDeadLetters(endpoint.ID)
+	require.Nil(t, err)
+	require.Empty(t, letters)
+
+	// The policy of the endpoint takes precedence, the failed request is
+	// moved to the dead letters.
+	attempts.Store(0)
+	endpoint.Retry = &types.RetryPolicy{MaxAttempts: 2}
+	result, err = invoker.Invoke(context.Background(), endpoint, SourceRedrive, req)
+	require.NotNil(t, err)
+	require.Equal(t, int32(2), attempts.Load())
+	letters, err = store.GetDeadLetters(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, letters[0].ID, result.DeadLetterID)
+	require.Equal(t, 2, letters[0].Attempts)
+	require.Equal(t, http.StatusServiceUnavailable, letters[0].StatusCode)
+	require.Equal(t, "try again", letters[0].Error)
+	require.Equal(t, req, letters[0].Request)
+
+	// Client errors are not retried.
+	attempts.Store(0)
+	status.Store(http.StatusBadRequest)
+	_, err = invoker.Invoke(context.Background(), endpoint, SourceRedrive, req)
+	require.NotNil(t, err)
+	require.Equal(t, int32(1), attempts.Load())
+	letters, err = store.GetDeadLetters(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, letters, 2)
+	require.Equal(t, 1, letters[1].Attempts)
+}
+
+func TestScheduler(t *testing.T) {
+	received := make(chan string, 2)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+	defer ingress.Close()
+
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	scheduler := NewScheduler(store, New(store, ingress.URL, config.Async{MaxAttempts: 1}))
+
+	now := time.Now()
+	due := types.NewScheduledInvocation(endpoint.ID, types.AsyncRequest{Method: "POST", Path: "/due"}, now)
+	later := types.NewScheduledInvocation(endpoint.ID, types.AsyncRequest{Method: "POST", Path: "/later"}, now.Add(time.Hour))
+	orphan := types.NewScheduledInvocation(uuid.New(), types.AsyncRequest{Method: "POST", Path: "/"}, now)
+	for _, scheduled := range []*types.ScheduledInvocation{due, later, orphan} {
+		require.Nil(t, store.CreateScheduledInvocation(scheduled))
+	}
+
+	scheduler.fire(now)
+	select {
+	case path := <-received:
+		require.Equal(t, "/live/"+endpoint.ID.String()+"/due", path)
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the due invocation to be fired")
+	}
+	fired, err := store.GetScheduledInvocation(due.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleFired, fired.Status)
+	require.Eventually(t, func() bool {
+		invocation, err := store.GetInvocation(fired.InvocationID)
+		return err == nil && invocation.Status == types.InvocationSucceeded
+	}, time.Second*5, time.Millisecond*10)
+
+	pending, err := store.GetScheduledInvocation(later.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.SchedulePending, pending.Status)
+	failed, err := store.GetScheduledInvocation(orphan.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleFailed, failed.Status)
+
+	// The invocations are fired once.
+	scheduler.fire(now)
+	select {
+	case path := <-received:
+		t.Fatalf("unexpected invocation of %s", path)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestVerifyInternal(t *testing.T) {
+	var (
+		secret       = []byte("secret")
+		now          = time.Now()
+		workflowID   = uuid.New()
+		invocationID = uuid.New()
+		header       = SignInternal(secret, now, "post", "/live/1/orders", uuid.Nil, uuid.Nil)
+		step         = SignInternal(secret, now, "post", "/live/1/orders", workflowID, uuid.Nil)
+		attempt      = SignInternal(secret, now, "post", "/live/1/orders", uuid.Nil, invocationID)
+	)
+	id, invocation, err := VerifyInternal(secret, header, "POST", "/live/1/orders", now.Add(time.Second*30))
+	require.Nil(t, err)
+	require.Equal(t, uuid.Nil, id)
+	require.Equal(t, uuid.Nil, invocation)
+	id, _, err = VerifyInternal(secret, step, "POST", "/live/1/orders", now)
+	require.Nil(t, err)
+	require.Equal(t, workflowID, id)
+	_, invocation, err = VerifyInternal(secret, attempt, "POST", "/live/1/orders", now)
+	require.Nil(t, err)
+	require.Equal(t, invocationID, invocation)
+
+	for _, c := range []struct {
+		secret       []byte
+		header, path string
+		method       string
+		now          time.Time
+	}{
+		{[]byte("other"), header, "/live/1/orders", "POST", now},
+		{secret, header, "/live/1/orders", "GET", now},
+		{secret, header, "/live/2/orders", "POST", now},
+		{secret, header, "/live/1/orders", "POST", now.Add(time.Minute * 2)},
+		{secret, "t=1,v1=zz", "/live/1/orders", "POST", now},
+		// The workflow can not be swapped or added to a signature.
+		{secret, strings.Replace(step, workflowID.String(), uuid.NewString(), 1), "/live/1/orders", "POST", now},
+		{secret, strings.Replace(header, "w=", "w="+workflowID.String(), 1), "/live/1/orders", "POST", now},
+		// Neither can the invocation.
+		{secret, strings.Replace(attempt, invocationID.String(), uuid.NewString(), 1), "/live/1/orders", "POST", now},
+		{secret, strings.Replace(header, "v1=", "i="+invocationID.String()+",v1=", 1), "/live/1/orders", "POST", now},
+	} {
+		_, _, err := VerifyInternal(c.secret, c.header, c.method, c.path, c.now)
+		require.Equal(t, ErrInvalidInternal, err)
+	}
+
+	// Nothing is accepted without a secret.
+	_, _, err = VerifyInternal(nil, SignInternal(nil, now, "POST", "/live/1/orders", uuid.Nil, uuid.Nil), "POST", "/live/1/orders", now)
+	require.Equal(t, ErrInvalidInternal, err)
+	require.Equal(t, secret, Secret(config.Async{Secret: "secret"}))
+}
+
+// staleStore returns the due scheduled invocations it was given, like a
+// server that loaded them before another server or a cancel changed them.
+type staleStore struct {
+	storage.Store
+	due []types.ScheduledInvocation
+}
+
+func (s staleStore) GetDueScheduledInvocations(time.Time) ([]types.ScheduledInvocation, error) {
+	return append([]types.ScheduledInvocation(nil), s.due...), nil
+}
+
+func TestSchedulerRace(t *testing.T) {
+	var fired atomic.Int32
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fired.Add(1)
+	}))
+	defer ingress.Close()
+
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	invoker := New(store, ingress.URL, config.Async{MaxAttempts: 1})
+	now := time.Now()
+
+	// An invocation canceled after it was loaded is not fired.
+	canceled := types.NewScheduledInvocation(endpoint.ID, types.AsyncRequest{Method: "POST", Path: "/"}, now)
+	require.Nil(t, store.CreateScheduledInvocation(canceled))
+	due, err := store.GetDueScheduledInvocations(now)
+	require.Nil(t, err)
+	canceled.Status = types.ScheduleCanceled
+	ok, err := store.UpdateScheduledInvocationStatus(canceled, types.SchedulePending)
+	require.Nil(t, err)
+	require.True(t, ok)
+	NewScheduler(staleStore{Store: store, due: due}, invoker).fire(now)
+	current, err := store.GetScheduledInvocation(canceled.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleCanceled, current.Status)
+	require.Equal(t, uuid.Nil, current.InvocationID)
+
+	// Two servers that loaded the same invocation fire it once.
+	scheduled := types.NewScheduledInvocation(endpoint.ID, types.AsyncRequest{Method: "POST", Path: "/"}, now)
+	require.Nil(t, store.CreateScheduledInvocation(scheduled))
+	due, err = store.GetDueScheduledInvocations(now)
+	require.Nil(t, err)
+	NewScheduler(staleStore{Store: store, due: due}, invoker).fire(now)
+	NewScheduler(staleStore{Store: store, due: due}, invoker).fire(now)
+	current, err = store.GetScheduledInvocation(scheduled.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.ScheduleFired, current.Status)
+	require.Eventually(t, func() bool {
+		invocation, err := store.GetInvocation(current.InvocationID)
+		return err == nil && invocation.Done()
+	}, time.Second*5, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	require.Equal(t, int32(1), fired.Load())
+
+	// The fired invocation can no longer be canceled.
+	scheduled.Status = types.ScheduleCanceled
+	ok, err = store.UpdateScheduledInvocationStatus(scheduled, types.SchedulePending)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
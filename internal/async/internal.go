@@ -0,0 +1,155 @@
+package async
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/google/uuid"
+)
+
+// InternalHeader authenticates the invocations of the platform at the
+// ingress: the asynchronous invocations and their retries, the re-drives,
+// the scheduled invocations, the invocations of the guests and the steps of
+// the workflows. It is "t=<unix seconds>,w=<workflow id>,v1=<hex signature>",
+// the HMAC-SHA256 of "<unix seconds>.<METHOD>.<path>.<workflow id>" made with
+// the internal secret, the workflow id is only set on the steps of a
+// workflow. The attempts of a polled invocation add "i=<invocation id>" and
+// sign "<unix seconds>.<METHOD>.<path>.<workflow id>.<invocation id>". The
+// ingress lets these requests through the JWT policy and the request
+// verification of the endpoint, hands the workflow and the invocation ids to
+// the guest and drops the header before the request reaches the guest.
+const InternalHeader = "Raptor-Internal"
+
+// internalTolerance is the maximum age of an internal signature, the
+// attempts are signed right before they are sent.
+const internalTolerance = time.Minute
+
+var ErrInvalidInternal = errors.New("internal signature is invalid")
+
+var (
+	processSecretMu sync.Mutex
+	processSecret   []byte
+)
+
+// UseProcessSecret makes the invocations of the platform signed with a
+// random secret of the process when the config has no secret. Only the
+// ingresses of the same process accept them, it is meant for the servers
+// running in a single process like `raptor dev` and the testkit.
+func UseProcessSecret() {
+	processSecretMu.Lock()
+	defer processSecretMu.Unlock()
+	if processSecret != nil {
+		return
+	}
+	processSecret = make([]byte, 32)
+	if _, err := rand.Read(processSecret); err != nil {
+		panic(err)
+	}
+}
+
+// Secret returns the secret the invocations of the platform are signed
+// with. Without a secret in the config it is the secret of the process when
+// UseProcessSecret was called, nil otherwise: the invocations are not signed
+// and no internal signature is accepted.
+func Secret(c config.Async) []byte {
+	if len(c.Secret) > 0 {
+		return []byte(c.Secret)
+	}
+	processSecretMu.Lock()
+	defer processSecretMu.Unlock()
+	return processSecret
+}
+
+// SignInternal returns the internal header of a request of the platform
+// sent at the given time, the step of the workflow and the attempt of the
+// polled invocation when their ids are not nil.
+func SignInternal(secret []byte, t time.Time, method, path string, workflowID, invocationID uuid.UUID) string {
+	var (
+		ts         = strconv.FormatInt(t.Unix(), 10)
+		workflow   = internalID(workflowID)
+		invocation = internalID(invocationID)
+		signature  = hex.EncodeToString(internalMAC(secret, ts, method, path, workflow, invocation))
+	)
+	if len(invocation) > 0 {
+		return fmt.Sprintf("t=%s,w=%s,i=%s,v1=%s", ts, workflow, invocation, signature)
+	}
+	return fmt.Sprintf("t=%s,w=%s,v1=%s", ts, workflow, signature)
+}
+
+// VerifyInternal returns the workflow the request is a step of and the
+// invocation it is an attempt of, nil when it is not one. An error is
+// returned if the internal header was not made with the secret for the
+// request, is too old, or there is no secret.
+func VerifyInternal(secret []byte, header, method, path string, now time.Time) (workflowID, invocationID uuid.UUID, err error) {
+	if len(secret) == 0 {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	var ts, workflow, invocation, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "w":
+			workflow = value
+		case "i":
+			invocation = value
+		case "v1":
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > internalTolerance || age < -internalTolerance {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	b, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(b, internalMAC(secret, ts, method, path, workflow, invocation)) {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	if workflowID, err = parseInternalID(workflow); err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	if invocationID, err = parseInternalID(invocation); err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidInternal
+	}
+	return workflowID, invocationID, nil
+}
+
+func internalID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+func parseInternalID(id string) (uuid.UUID, error) {
+	if len(id) == 0 {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(id)
+}
+
+// internalMAC signs the request, the invocation is only part of the signed
+// message when it is set so the other signatures keep their format.
+func internalMAC(secret []byte, ts, method, path, workflow, invocation string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	msg := ts + "." + strings.ToUpper(method) + "." + path + "." + workflow
+	if len(invocation) > 0 {
+		msg += "." + invocation
+	}
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
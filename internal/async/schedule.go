@@ -0,0 +1,87 @@
+package async
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// Scheduler fires the scheduled invocations once they are due, as
+// asynchronous invocations of the invoker.
+type Scheduler struct {
+	store   storage.Store
+	invoker *Invoker
+}
+
+// NewScheduler returns a new scheduler firing the invocations with the
+// invoker.
+func NewScheduler(store storage.Store, invoker *Invoker) *Scheduler {
+	return &Scheduler{
+		store:   store,
+		invoker: invoker,
+	}
+}
+
+// Run fires the scheduled invocations that are due once per interval, until
+// the context is done.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.fire(now)
+		}
+	}
+}
+
+func (s *Scheduler) fire(now time.Time) {
+	due, err := s.store.GetDueScheduledInvocations(now)
+	if err != nil {
+		slog.Warn("failed to load the due scheduled invocations", "err", err)
+		return
+	}
+	for i := range due {
+		scheduled := &due[i]
+		endpoint, err := s.store.GetEndpoint(scheduled.EndpointID)
+		if err != nil {
+			scheduled.Status = types.ScheduleFailed
+			scheduled.Error = err.Error()
+			s.transition(scheduled, types.SchedulePending)
+			continue
+		}
+		// The invocation is marked as fired before it runs, only while it
+		// is still pending. A cancel or another server that got to it first
+		// wins, and the next check does not fire it again.
+		invocation := types.NewInvocation(endpoint.ID)
+		scheduled.Status = types.ScheduleFired
+		scheduled.InvocationID = invocation.ID
+		if !s.transition(scheduled, types.SchedulePending) {
+			continue
+		}
+		if err := s.store.CreateInvocation(invocation); err != nil {
+			slog.Error("failed to create invocation", "err", err, "id", scheduled.ID)
+			scheduled.Status = types.ScheduleFailed
+			scheduled.Error = err.Error()
+			s.transition(scheduled, types.ScheduleFired)
+			continue
+		}
+		go s.invoker.Run(endpoint, invocation, SourceSchedule, scheduled.Request)
+	}
+}
+
+// transition updates the status of the scheduled invocation from the given
+// status, false is returned when its status changed in the meantime.
+func (s *Scheduler) transition(scheduled *types.ScheduledInvocation, from string) bool {
+	ok, err := s.store.UpdateScheduledInvocationStatus(scheduled, from)
+	if err != nil {
+		slog.Error("failed to update scheduled invocation", "err", err, "id", scheduled.ID)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,300 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// Version is the version of the archive format, archives of a newer version
+// are refused.
+const Version = 1
+
+const manifestName = "manifest.json"
+
+// Manifest describes the archive, it is its first file.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// endpointRecord is an endpoint with the data the API never exposes and
+// its webhooks and publish history.
+type endpointRecord struct {
+	types.Endpoint
+	// Wrapped by the master key, the environment is only readable by the
+	// installations with the same master key.
+	DataKeys             []types.DataKey            `json:"data_keys,omitempty"`
+	PublishedEnvironment *types.EnvironmentSnapshot `json:"published_environment,omitempty"`
+	Webhooks             []types.Webhook            `json:"webhooks"`
+	History              []types.DeploymentEvent    `json:"history"`
+}
+
+// Result counts what a backup wrote or a restore imported.
+type Result struct {
+	Endpoints   int `json:"endpoints"`
+	Deployments int `json:"deployments"`
+	Webhooks    int `json:"webhooks"`
+	// Existing endpoints a migration updated to their settings in the
+	// source store.
+	Updated int `json:"updated,omitempty"`
+	// Endpoints and deployments of a restore that already exist, they are
+	// left as they are.
+	Skipped int `json:"skipped"`
+}
+
+// Write writes all the endpoints of the store with their deployments,
+// webhooks and publish history to w as a gzipped tar archive. The
+// environments are written as they are stored, encrypted when the store
+// holds them encrypted, so the store should be the one beneath the
+// storage.EncryptedStore.
+//
+// The archive holds, per endpoint, endpoints/<id>/endpoint.json followed by
+// a deployments/<deploy-id>.wasm blob, an optional .assets.zip archive and
+// a .json metadata file per deployment.
+func Write(w io.Writer, store storage.Store) (*Result, error) {
+	endpoints, err := store.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := writeJSON(tw, manifestName, Manifest{Version: Version, CreatedAT: time.Now().UTC()}); err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, endpoint := range endpoints {
+		record := endpointRecord{
+			Endpoint:             endpoint,
+			DataKeys:             endpoint.DataKeys,
+			PublishedEnvironment: endpoint.PublishedEnvironment,
+		}
+		if record.Webhooks, err = store.GetWebhooks(endpoint.ID); err != nil {
+			return nil, err
+		}
+		if record.History, err = store.GetDeploymentEvents(endpoint.ID); err != nil {
+			return nil, err
+		}
+		dir := path.Join("endpoints", endpoint.ID.String())
+		if err := writeJSON(tw, path.Join(dir, "endpoint.json"), record); err != nil {
+			return nil, err
+		}
+		deploys, err := store.GetDeployments(endpoint.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deploys {
+			// The deployments listed by the store come without their blobs.
+			deploy, err := store.GetDeployment(d.ID)
+			if err != nil {
+				return nil, err
+			}
+			name := path.Join(dir, "deployments", deploy.ID.String())
+			if err := writeFile(tw, name+".wasm", deploy.Blob); err != nil {
+				return nil, err
+			}
+			if len(deploy.Assets) > 0 {
+				if err := writeFile(tw, name+".assets.zip", deploy.Assets); err != nil {
+					return nil, err
+				}
+			}
+			if err := writeJSON(tw, name+".json", deploy); err != nil {
+				return nil, err
+			}
+			result.Deployments++
+		}
+		result.Endpoints++
+		result.Webhooks += len(record.Webhooks)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return result, gw.Close()
+}
+
+// Restore imports the endpoints of the archive read from r into the store.
+// Endpoints and deployments that exist in the store already are skipped
+// along with the deployments of the skipped endpoints, so restoring never
+// overwrites the state of the store.
+func Restore(r io.Reader, store storage.Store) (*Result, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %s", err)
+	}
+	var (
+		tr     = tar.NewReader(gr)
+		result = &Result{}
+		// Endpoint of the deployments that follow, zero when it is skipped.
+		endpointID uuid.UUID
+		blob       []byte
+		assets     []byte
+		manifest   bool
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive: %s", err)
+		}
+		if !manifest {
+			if hdr.Name != manifestName {
+				return nil, fmt.Errorf("invalid backup archive: %s is not its first file", manifestName)
+			}
+			var m Manifest
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return nil, fmt.Errorf("invalid backup manifest: %s", err)
+			}
+			if m.Version < 1 || m.Version > Version {
+				return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+			}
+			manifest = true
+			continue
+		}
+		switch name := hdr.Name; {
+		case path.Base(name) == "endpoint.json":
+			var record endpointRecord
+			if err := json.NewDecoder(tr).Decode(&record); err != nil {
+				return nil, fmt.Errorf("invalid endpoint %s: %s", name, err)
+			}
+			created, err := restoreEndpoint(store, record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore endpoint %s: %s", record.ID, err)
+			}
+			endpointID = uuid.Nil
+			if !created {
+				result.Skipped++
+				continue
+			}
+			endpointID = record.ID
+			result.Endpoints++
+			result.Webhooks += len(record.Webhooks)
+		case strings.HasSuffix(name, ".wasm"):
+			if blob, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(name, ".assets.zip"):
+			if assets, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(name, ".json"):
+			var deploy types.Deployment
+			if err := json.NewDecoder(tr).Decode(&deploy); err != nil {
+				return nil, fmt.Errorf("invalid deployment %s: %s", name, err)
+			}
+			deploy.Blob, deploy.Assets = blob, assets
+			blob, assets = nil, nil
+			if deploy.EndpointID != endpointID {
+				continue
+			}
+			if _, err := store.GetDeployment(deploy.ID); err == nil {
+				result.Skipped++
+				continue
+			}
+			if err := verifyBlob(&deploy); err != nil {
+				return nil, err
+			}
+			if err := store.CreateDeployment(&deploy); err != nil {
+				return nil, fmt.Errorf("failed to restore deployment %s: %s", deploy.ID, err)
+			}
+			result.Deployments++
+		}
+	}
+	if !manifest {
+		return nil, fmt.Errorf("invalid backup archive: %s is missing", manifestName)
+	}
+	return result, nil
+}
+
+// restoreEndpoint creates the endpoint of the record with its settings,
+// webhooks and history. It returns false when the endpoint exists already.
+func restoreEndpoint(store storage.Store, record endpointRecord) (bool, error) {
+	if _, err := store.GetEndpoint(record.ID); err == nil {
+		return false, nil
+	}
+	endpoint := record.Endpoint
+	endpoint.DataKeys = record.DataKeys
+	if err := store.CreateEndpoint(&endpoint); err != nil {
+		return false, err
+	}
+	// Only the identity of the endpoint is stored on creation.
+	if err := store.UpdateEndpoint(endpoint.ID, settings(endpoint, record.PublishedEnvironment)); err != nil {
+		return false, err
+	}
+	for i := range record.Webhooks {
+		if err := store.CreateWebhook(&record.Webhooks[i]); err != nil {
+			return false, err
+		}
+	}
+	for i := range record.History {
+		if err := store.CreateDeploymentEvent(&record.History[i]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// settings returns the params updating an endpoint to the settings of the
+// given endpoint, besides its identity and its environment.
+func settings(endpoint types.Endpoint, published *types.EnvironmentSnapshot) storage.UpdateEndpointParams {
+	maxConcurrency := endpoint.MaxConcurrency
+	return storage.UpdateEndpointParams{
+		ActiveDeployID:       endpoint.ActiveDeploymentID,
+		SessionAffinity:      endpoint.SessionAffinity,
+		Regions:              endpoint.Regions,
+		MaxConcurrency:       &maxConcurrency,
+		Probes:               endpoint.Probes,
+		StaticResponses:      endpoint.StaticResponses,
+		Routes:               endpoint.Routes,
+		Deprecation:          endpoint.Deprecation,
+		Cache:                endpoint.Cache,
+		Compression:          endpoint.Compression,
+		Limits:               endpoint.Limits,
+		WebSocket:            endpoint.WebSocket,
+		Fallback:             endpoint.Fallback,
+		Retention:            endpoint.Retention,
+		EnvironmentSchema:    endpoint.EnvironmentSchema,
+		Rollout:              endpoint.Rollout,
+		Retry:                endpoint.Retry,
+		Capture:              endpoint.Capture,
+		CORS:                 endpoint.CORS,
+		Verification:         endpoint.Verification,
+		JWT:                  endpoint.JWT,
+		Maintenance:          endpoint.Maintenance,
+		Prewarm:              endpoint.Prewarm,
+		PublishedEnvironment: published,
+	}
+}
+
+func writeJSON(tw *tar.Writer, name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFile(tw, name, b)
+}
+
+func writeFile(tw *tar.Writer, name string, b []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
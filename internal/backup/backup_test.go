@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRestore(t *testing.T) {
+	source := storage.NewMemoryStore()
+	endpoint := &types.Endpoint{
+		ID:          uuid.New(),
+		Name:        "catfacts",
+		Runtime:     "go",
+		Environment: map[string]string{"TOKEN": "enc:v1:c2VjcmV0"},
+		DataKeys:    []types.DataKey{{Version: 1, Key: []byte("wrapped"), CreatedAT: time.Now().UTC()}},
+		CreatedAT:   time.Now().UTC(),
+	}
+	require.Nil(t, source.CreateEndpoint(endpoint))
+	deploy := types.NewDeployment(endpoint, []byte("wasm"))
+	deploy.Assets = []byte("assets")
+	deploy.HasAssets = true
+	require.Nil(t, source.CreateDeployment(deploy))
+	maxConcurrency := 10
+	require.Nil(t, source.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		ActiveDeployID:       deploy.ID,
+		MaxConcurrency:       &maxConcurrency,
+		Regions:              []string{"eu-west"},
+		PublishedEnvironment: types.NewEnvironmentSnapshot(deploy.ID, endpoint.Environment),
+	}))
+	require.Nil(t, source.CreateWebhook(&types.Webhook{ID: uuid.New(), EndpointID: endpoint.ID, URL: "https://example.com", Secret: "secret"}))
+	require.Nil(t, source.CreateDeploymentEvent(&types.DeploymentEvent{ID: uuid.New(), EndpointID: endpoint.ID, Kind: "publish", DeploymentID: deploy.ID}))
+
+	var archive bytes.Buffer
+	result, err := Write(&archive, source)
+	require.Nil(t, err)
+	require.Equal(t, &Result{Endpoints: 1, Deployments: 1, Webhooks: 1}, result)
+
+	target := storage.NewMemoryStore()
+	result, err = Restore(bytes.NewReader(archive.Bytes()), target)
+	require.Nil(t, err)
+	require.Equal(t, &Result{Endpoints: 1, Deployments: 1, Webhooks: 1}, result)
+
+	restored, err := target.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.Environment, restored.Environment)
+	require.Equal(t, endpoint.DataKeys, restored.DataKeys)
+	require.Equal(t, deploy.ID, restored.ActiveDeploymentID)
+	require.Equal(t, 10, restored.MaxConcurrency)
+	require.Equal(t, []string{"eu-west"}, restored.Regions)
+	require.Equal(t, deploy.ID, restored.PublishedEnvironment.DeploymentID)
+	restoredDeploy, err := target.GetDeployment(deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("wasm"), restoredDeploy.Blob)
+	require.Equal(t, []byte("assets"), restoredDeploy.Assets)
+	webhooks, err := target.GetWebhooks(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, webhooks, 1)
+	require.Equal(t, "secret", webhooks[0].Secret)
+	events, err := target.GetDeploymentEvents(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+
+	// The endpoints that exist are left as they are.
+	result, err = Restore(bytes.NewReader(archive.Bytes()), target)
+	require.Nil(t, err)
+	require.Equal(t, &Result{Skipped: 1}, result)
+}
+
+func TestRestoreInvalidArchive(t *testing.T) {
+	_, err := Restore(strings.NewReader("not an archive"), storage.NewMemoryStore())
+	require.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	source := storage.NewMemoryStore()
+	endpoint := &types.Endpoint{
+		ID:          uuid.New(),
+		Name:        "catfacts",
+		Runtime:     "go",
+		Environment: map[string]string{"FOO": "bar"},
+		CreatedAT:   time.Now().UTC(),
+	}
+	require.Nil(t, source.CreateEndpoint(endpoint))
+	deploy := types.NewDeployment(endpoint, []byte("wasm"))
+	require.Nil(t, source.CreateDeployment(deploy))
+	require.Nil(t, source.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{ActiveDeployID: deploy.ID}))
+	require.Nil(t, source.CreateWebhook(&types.Webhook{ID: uuid.New(), EndpointID: endpoint.ID, URL: "https://example.com"}))
+
+	target := storage.NewMemoryStore()
+	result, err := Migrate(source, target, MigrateOptions{DryRun: true})
+	require.Nil(t, err)
+	require.Equal(t, &Result{Endpoints: 1, Deployments: 1, Webhooks: 1}, result)
+	_, err = target.GetEndpoint(endpoint.ID)
+	require.NotNil(t, err)
+
+	result, err = Migrate(source, target, MigrateOptions{})
+	require.Nil(t, err)
+	require.Equal(t, &Result{Endpoints: 1, Deployments: 1, Webhooks: 1}, result)
+	migrated, err := target.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, deploy.ID, migrated.ActiveDeploymentID)
+
+	// A later run copies what changed since the previous run.
+	next := types.NewDeployment(endpoint, []byte("wasm v2"))
+	require.Nil(t, source.CreateDeployment(next))
+	require.Nil(t, source.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{Name: "catfacts-v2", ActiveDeployID: next.ID}))
+	result, err = Migrate(source, target, MigrateOptions{})
+	require.Nil(t, err)
+	require.Equal(t, &Result{Deployments: 1, Updated: 1, Skipped: 1}, result)
+	migrated, err = target.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "catfacts-v2", migrated.Name)
+	require.Equal(t, next.ID, migrated.ActiveDeploymentID)
+	webhooks, err := target.GetWebhooks(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, webhooks, 1)
+
+	corrupt := types.NewDeployment(endpoint, []byte("wasm v3"))
+	require.Nil(t, source.CreateDeployment(corrupt))
+	corrupt.Blob = []byte("tampered")
+	_, err = Migrate(source, target, MigrateOptions{})
+	require.ErrorContains(t, err, "does not match its hash")
+}
@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// MigrateOptions configures a migration between two stores.
+type MigrateOptions struct {
+	// Count what would be copied without writing to the target store.
+	DryRun bool
+	// Progress is called with every step of the migration, like
+	// "copy deployment <id>".
+	Progress func(endpointID uuid.UUID, step string)
+}
+
+// Migrate copies all the endpoints of the from store with their
+// deployments, webhooks and publish history into the to store. The blob of
+// every copied deployment is verified against its hash before it is
+// written and once it is read back from the target store.
+//
+// A migration can be run again. The endpoints that exist in the target
+// store are updated to their settings and environment in the source store
+// and only the deployments, the webhooks and the history entries missing
+// from the target store are copied, so an interrupted migration resumes
+// where it stopped and a last incremental run before the switch over is
+// quick. Deletions are not copied. The environments are copied as they are
+// stored, the stores should be the ones beneath the storage.EncryptedStore
+// and the installation using the target store needs the same master key.
+func Migrate(from, to storage.Store, opts MigrateOptions) (*Result, error) {
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(uuid.UUID, string) {}
+	}
+	endpoints, err := from.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, endpoint := range endpoints {
+		// The deployments are copied first, so the active deployment of the
+		// endpoint exists once it is copied.
+		if err := migrateDeployments(from, to, endpoint.ID, opts.DryRun, result, progress); err != nil {
+			return nil, err
+		}
+		record := endpointRecord{
+			Endpoint:             endpoint,
+			DataKeys:             endpoint.DataKeys,
+			PublishedEnvironment: endpoint.PublishedEnvironment,
+		}
+		if record.Webhooks, err = from.GetWebhooks(endpoint.ID); err != nil {
+			return nil, err
+		}
+		if record.History, err = from.GetDeploymentEvents(endpoint.ID); err != nil {
+			return nil, err
+		}
+		if _, err := to.GetEndpoint(endpoint.ID); err != nil {
+			if !opts.DryRun {
+				if _, err := restoreEndpoint(to, record); err != nil {
+					return nil, fmt.Errorf("failed to copy endpoint %s: %s", endpoint.ID, err)
+				}
+			}
+			result.Endpoints++
+			result.Webhooks += len(record.Webhooks)
+			progress(endpoint.ID, "copy endpoint")
+			continue
+		}
+		webhooks, err := updateEndpoint(to, record, opts.DryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update endpoint %s: %s", endpoint.ID, err)
+		}
+		result.Updated++
+		result.Webhooks += webhooks
+		progress(endpoint.ID, "update endpoint")
+	}
+	return result, nil
+}
+
+// migrateDeployments copies the deployments of the endpoint that are missing
+// from the to store, and fails on the deployments with another blob.
+func migrateDeployments(from, to storage.Store, endpointID uuid.UUID, dryRun bool, result *Result, progress func(uuid.UUID, string)) error {
+	deploys, err := from.GetDeployments(endpointID)
+	if err != nil {
+		return err
+	}
+	existing, err := to.GetDeployments(endpointID)
+	if err != nil {
+		return err
+	}
+	hashes := make(map[uuid.UUID]string, len(existing))
+	for _, deploy := range existing {
+		hashes[deploy.ID] = deploy.Hash
+	}
+	for _, d := range deploys {
+		if hash, ok := hashes[d.ID]; ok {
+			if hash != d.Hash {
+				return fmt.Errorf("deployment %s has the hash %s in the target store instead of %s", d.ID, hash, d.Hash)
+			}
+			result.Skipped++
+			continue
+		}
+		// The deployments listed by the store come without their blobs.
+		deploy, err := from.GetDeployment(d.ID)
+		if err != nil {
+			return err
+		}
+		if err := verifyBlob(deploy); err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := to.CreateDeployment(deploy); err != nil {
+				return fmt.Errorf("failed to copy deployment %s: %s", deploy.ID, err)
+			}
+			copied, err := to.GetDeployment(deploy.ID)
+			if err != nil {
+				return err
+			}
+			if err := verifyBlob(copied); err != nil {
+				return fmt.Errorf("copy of %s", err)
+			}
+		}
+		result.Deployments++
+		progress(endpointID, fmt.Sprintf("copy deployment %s", deploy.ID))
+	}
+	return nil
+}
+
+// updateEndpoint updates the existing endpoint to the settings of the
+// record and adds its missing webhooks and history entries. It returns the
+// amount of added webhooks.
+func updateEndpoint(store storage.Store, record endpointRecord, dryRun bool) (int, error) {
+	webhooks, err := store.GetWebhooks(record.ID)
+	if err != nil {
+		return 0, err
+	}
+	events, err := store.GetDeploymentEvents(record.ID)
+	if err != nil {
+		return 0, err
+	}
+	exists := make(map[uuid.UUID]bool, len(webhooks)+len(events))
+	for _, webhook := range webhooks {
+		exists[webhook.ID] = true
+	}
+	for _, event := range events {
+		exists[event.ID] = true
+	}
+	var added int
+	for i, webhook := range record.Webhooks {
+		if exists[webhook.ID] {
+			continue
+		}
+		if !dryRun {
+			if err := store.CreateWebhook(&record.Webhooks[i]); err != nil {
+				return 0, err
+			}
+		}
+		added++
+	}
+	if dryRun {
+		return added, nil
+	}
+	for i, event := range record.History {
+		if exists[event.ID] {
+			continue
+		}
+		if err := store.CreateDeploymentEvent(&record.History[i]); err != nil {
+			return 0, err
+		}
+	}
+	params := settings(record.Endpoint, record.PublishedEnvironment)
+	params.Name = record.Name
+	params.Runtime = record.Runtime
+	params.Environment = record.Environment
+	params.DataKeys = record.DataKeys
+	params.Ownership = record.Ownership
+	return added, store.UpdateEndpoint(record.ID, params)
+}
+
+// verifyBlob returns an error if the blob of the deployment does not match
+// its hash.
+func verifyBlob(deploy *types.Deployment) error {
+	if sum := sha256.Sum256(deploy.Blob); hex.EncodeToString(sum[:]) != deploy.Hash {
+		return fmt.Errorf("blob of deployment %s does not match its hash", deploy.ID)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package build
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+const (
+	// MaxSourceSize is the maximum size of the uncompressed sources of a
+	// build.
+	MaxSourceSize = 256 << 20
+	// MaxLogSize is the maximum size of the logs of a build, the start of
+	// longer logs is cut off.
+	MaxLogSize = 1 << 20
+)
+
+// Toolchain compiles the sources of a language to a WASM module.
+type Toolchain struct {
+	// Command building the module, run in the root of the sources.
+	Command []string
+	// Environment of the command in addition to PATH and HOME.
+	Env []string
+	// Glob matching the module built by the command, relative to the root
+	// of the sources. It needs to match exactly one file.
+	Output string
+}
+
+// Toolchains are the toolchains of the languages a builder supports by
+// default, they need to be installed on the host or in the sandbox.
+var Toolchains = map[string]Toolchain{
+	types.BuildLanguageGo: {
+		Command: []string{"go", "build", "-o", "app.wasm", "."},
+		Env:     []string{"GOOS=wasip1", "GOARCH=wasm"},
+		Output:  "app.wasm",
+	},
+	types.BuildLanguageTinyGo: {
+		Command: []string{"tinygo", "build", "-target=wasip1", "-o", "app.wasm", "."},
+		Output:  "app.wasm",
+	},
+	types.BuildLanguageRust: {
+		Command: []string{"cargo", "build", "--target", "wasm32-wasip1", "--release"},
+		Output:  "target/wasm32-wasip1/release/*.wasm",
+	},
+}
+
+// Builder compiles source archives to WASM modules. Every build runs in a
+// new directory with a minimal environment, wrapped in the sandbox command
+// of the configuration.
+type Builder struct {
+	toolchains map[string]Toolchain
+	sandbox    []string
+	timeout    time.Duration
+	slots      chan struct{}
+}
+
+// NewBuilder returns a new builder with the default toolchains.
+func NewBuilder(c config.Build) *Builder {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	toolchains := make(map[string]Toolchain, len(Toolchains))
+	for language, toolchain := range Toolchains {
+		toolchains[language] = toolchain
+	}
+	return &Builder{
+		toolchains: toolchains,
+		sandbox:    c.Sandbox,
+		timeout:    time.Duration(c.Timeout),
+		slots:      make(chan struct{}, concurrency),
+	}
+}
+
+// WithToolchain replaces the toolchain of the given language.
+func (b *Builder) WithToolchain(language string, toolchain Toolchain) *Builder {
+	b.toolchains[language] = toolchain
+	return b
+}
+
+// Build extracts the zip archive with the sources and runs the toolchain of
+// the language on them. It returns the module and the output of the
+// toolchain, which is also returned when the build fails. Builds wait for
+// a free slot when the maximum amount of concurrent builds is reached.
+func (b *Builder) Build(ctx context.Context, language string, source []byte) ([]byte, []byte, error) {
+	toolchain, ok := b.toolchains[language]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported build language %s", language)
+	}
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	dir, err := os.MkdirTemp("", "raptor-build-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := extract(source, src); err != nil {
+		return nil, nil, err
+	}
+
+	args := append(append([]string{}, b.sandbox...), toolchain.Command...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = src
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+		"TMPDIR=" + dir,
+	}, toolchain.Env...)
+	logs := &tailBuffer{max: MaxLogSize}
+	cmd.Stdout, cmd.Stderr = logs, logs
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("build exceeded the timeout of %s", b.timeout)
+		}
+		return nil, logs.Bytes(), err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(src, filepath.FromSlash(toolchain.Output)))
+	if err != nil {
+		return nil, logs.Bytes(), err
+	}
+	if len(matches) != 1 {
+		return nil, logs.Bytes(), fmt.Errorf("expected the build to produce one module matching %s, found %d", toolchain.Output, len(matches))
+	}
+	module, err := os.ReadFile(matches[0])
+	return module, logs.Bytes(), err
+}
+
+// extract writes the files of the zip archive into dir. Entries that would
+// end up outside of dir are rejected.
+func extract(source []byte, dir string) error {
+	r, err := zip.NewReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		return fmt.Errorf("invalid source archive: %w", err)
+	}
+	var size uint64
+	for _, f := range r.File {
+		name := path.Clean(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid source file %s", f.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		size += f.UncompressedSize64
+		if size > MaxSourceSize {
+			return fmt.Errorf("sources exceed the maximum of %d bytes", MaxSourceSize)
+		}
+		if err := extractFile(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	mode := os.FileMode(0644)
+	if f.Mode()&0100 != 0 {
+		mode = 0755
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.LimitReader(rc, MaxSourceSize)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf []byte
+	max int
+	cut bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+		t.cut = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) Bytes() []byte {
+	if t.cut {
+		return append([]byte("...\n"), t.buf...)
+	}
+	return t.buf
+}
@@ -0,0 +1,62 @@
+package build
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func makeSource(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		f, err := zw.Create(name)
+		require.Nil(t, err)
+		_, err = f.Write([]byte(body))
+		require.Nil(t, err)
+	}
+	require.Nil(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestBuild(t *testing.T) {
+	b := NewBuilder(config.Build{}).WithToolchain("go", Toolchain{
+		Command: []string{"sh", "-c", "echo building; cp src/main.txt out/app.wasm"},
+		Output:  "out/*.wasm",
+	})
+	source := makeSource(t, map[string]string{
+		"src/main.txt": "module",
+		"out/.keep":    "",
+	})
+	module, logs, err := b.Build(context.Background(), "go", source)
+	require.Nil(t, err)
+	require.Equal(t, []byte("module"), module)
+	require.Equal(t, "building\n", string(logs))
+
+	_, _, err = b.Build(context.Background(), "rust", source)
+	require.NotNil(t, err)
+}
+
+func TestBuildRejectsEscapingSources(t *testing.T) {
+	b := NewBuilder(config.Build{}).WithToolchain("go", Toolchain{
+		Command: []string{"true"},
+		Output:  "app.wasm",
+	})
+	_, _, err := b.Build(context.Background(), "go", makeSource(t, map[string]string{"../evil": "x"}))
+	require.ErrorContains(t, err, "invalid source file")
+
+	_, _, err = b.Build(context.Background(), "go", makeSource(t, map[string]string{"main.go": "x"}))
+	require.ErrorContains(t, err, "expected the build to produce one module")
+}
+
+func TestTailBuffer(t *testing.T) {
+	buf := &tailBuffer{max: 4}
+	buf.Write([]byte("ab"))
+	require.Equal(t, "ab", string(buf.Bytes()))
+	buf.Write([]byte("cdef"))
+	require.Equal(t, "...\ncdef", string(buf.Bytes()))
+}
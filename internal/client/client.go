@@ -2,18 +2,29 @@ package client
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/backup"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/recommend"
+	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
 )
 
 type Config struct {
-	url string
+	url    string
+	actor  string
+	apiKey string
 }
 
 func NewConfig() Config {
@@ -27,6 +38,19 @@ func (c Config) WithURL(url string) Config {
 	return c
 }
 
+// WithActor sets who makes the changes, recorded in the publish history of
+// the endpoints.
+func (c Config) WithActor(actor string) Config {
+	c.actor = actor
+	return c
+}
+
+// WithAPIKey sets the API token the requests are authorized with.
+func (c Config) WithAPIKey(key string) Config {
+	c.apiKey = key
+	return c
+}
+
 type Client struct {
 	*http.Client
 
@@ -40,6 +64,14 @@ func New(config Config) *Client {
 	}
 }
 
+// Do sends the request authorized with the API key of the config.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if len(c.config.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.config.apiKey)
+	}
+	return c.Client.Do(req)
+}
+
 func (c *Client) Publish(params api.PublishParams) (*api.PublishResponse, error) {
 	b, err := json.Marshal(params)
 	if err != nil {
@@ -51,6 +83,9 @@ func (c *Client) Publish(params api.PublishParams) (*api.PublishResponse, error)
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
+	if len(c.config.actor) > 0 {
+		req.Header.Set(api.ActorHeader, c.config.actor)
+	}
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
@@ -92,13 +127,134 @@ func (c *Client) CreateEndpoint(params api.CreateEndpointParams) (*types.Endpoin
 	return &endpoint, nil
 }
 
+func (c *Client) GetEndpoint(endpointID uuid.UUID) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+// RevealEndpoint returns the endpoint with the values of its environment
+// variables, it requires an admin key.
+func (c *Client) RevealEndpoint(endpointID uuid.UUID) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s?reveal=true", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+func (c *Client) UpdateEndpoint(endpointID uuid.UUID, params api.UpdateEndpointParams) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s", c.config.url, endpointID)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetProjectEnvironment returns the default environment the endpoints of the
+// project inherit.
+func (c *Client) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	url := fmt.Sprintf("%s/project/%s/environment", c.config.url, url.PathEscape(project))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doProjectEnvironment(req)
+}
+
+// UpdateProjectEnvironment updates the default environment of the project
+// and returns it.
+func (c *Client) UpdateProjectEnvironment(project string, params api.UpdateProjectEnvironmentParams) (*types.ProjectEnvironment, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/project/%s/environment", c.config.url, url.PathEscape(project))
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return c.doProjectEnvironment(req)
+}
+
+func (c *Client) doProjectEnvironment(req *http.Request) (*types.ProjectEnvironment, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var env types.ProjectEnvironment
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
 func (c *Client) CreateDeployment(endpointID uuid.UUID, blob io.Reader, params api.CreateDeploymentParams) (*types.Deployment, error) {
 	url := fmt.Sprintf("%s/endpoint/%s/deployment", c.config.url, endpointID)
+	if params.NoDedupe {
+		url += "?dedupe=false"
+	}
+	contentType := "application/octet-stream"
+	if len(params.Assets) > 0 || len(params.Signature) > 0 || !params.Metadata.Equal(types.DeploymentMetadata{}) {
+		body, err := deploymentForm(blob, params, nil)
+		if err != nil {
+			return nil, err
+		}
+		blob = body
+		contentType = body.contentType
+	}
 	req, err := http.NewRequest("POST", url, blob)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/octet-stream")
+	req.Header.Add("Content-Type", contentType)
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
@@ -114,9 +270,54 @@ func (c *Client) CreateDeployment(endpointID uuid.UUID, blob io.Reader, params a
 	return &deploy, nil
 }
 
-func (c *Client) ListEndpoints() ([]types.Endpoint, error) {
-	url := fmt.Sprintf("%s/endpoint", c.config.url)
-	req, err := http.NewRequest("GET", url, nil)
+// Release creates the deployment of the blob and publishes it in one call,
+// unless the manifest asks for a preview only.
+func (c *Client) Release(endpointID uuid.UUID, blob io.Reader, params api.ReleaseParams) (*api.ReleaseResponse, error) {
+	manifest, err := json.Marshal(params.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	body, err := deploymentForm(blob, api.CreateDeploymentParams{
+		Assets:    params.Assets,
+		Signature: params.Signature,
+		Metadata:  params.Manifest.DeploymentMetadata,
+	}, manifest)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/release", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", body.contentType)
+	if len(c.config.actor) > 0 {
+		req.Header.Set(api.ActorHeader, c.config.actor)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var release api.ReleaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &release, nil
+}
+
+// PullDeployment creates a deployment from a WASM module the API server
+// pulls from an OCI registry or an https URL.
+func (c *Client) PullDeployment(endpointID uuid.UUID, params api.PullDeploymentParams) (*types.Deployment, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/deployment/pull", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
@@ -125,10 +326,1217 @@ func (c *Client) ListEndpoints() ([]types.Endpoint, error) {
 	if err != nil {
 		return nil, err
 	}
-	var endpoints []types.Endpoint
-	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var deploy types.Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deploy); err != nil {
 		return nil, err
 	}
 	resp.Body.Close()
-	return endpoints, nil
+	return &deploy, nil
+}
+
+type multipartBody struct {
+	*bytes.Buffer
+	contentType string
+}
+
+// deploymentForm encodes the blob, the asset bundle, the metadata and the
+// signature of a deployment as a multipart form, with the manifest of a
+// release when it is not empty.
+func deploymentForm(blob io.Reader, params api.CreateDeploymentParams, manifest []byte) (*multipartBody, error) {
+	var (
+		buf = new(bytes.Buffer)
+		mw  = multipart.NewWriter(buf)
+	)
+	part, err := mw.CreateFormFile("blob", "blob.wasm")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, blob); err != nil {
+		return nil, err
+	}
+	if len(params.Assets) > 0 {
+		part, err = mw.CreateFormFile("assets", "assets.zip")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(params.Assets); err != nil {
+			return nil, err
+		}
+	}
+	metadata, err := json.Marshal(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := mw.WriteField("metadata", string(metadata)); err != nil {
+		return nil, err
+	}
+	if len(params.Signature) > 0 {
+		if err := mw.WriteField("signature", base64.StdEncoding.EncodeToString(params.Signature)); err != nil {
+			return nil, err
+		}
+	}
+	if len(manifest) > 0 {
+		if err := mw.WriteField("manifest", string(manifest)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return &multipartBody{Buffer: buf, contentType: mw.FormDataContentType()}, nil
+}
+
+// CreateBuild uploads the zip archive with the sources of a function, the
+// returned build is queued. The deployment is created once the build
+// succeeded.
+func (c *Client) CreateBuild(endpointID uuid.UUID, source io.Reader, params api.CreateBuildParams) (*types.Build, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/build?language=%s", c.config.url, endpointID, params.Language)
+	body, err := buildForm(source, params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", body.contentType)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("api responded with a non 202 status code: %d", resp.StatusCode)
+	}
+	var build types.Build
+	if err := json.NewDecoder(resp.Body).Decode(&build); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &build, nil
+}
+
+// buildForm encodes the source archive, the asset bundle and the metadata
+// of a build as a multipart form.
+func buildForm(source io.Reader, params api.CreateBuildParams) (*multipartBody, error) {
+	var (
+		buf = new(bytes.Buffer)
+		mw  = multipart.NewWriter(buf)
+	)
+	part, err := mw.CreateFormFile("source", "source.zip")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, source); err != nil {
+		return nil, err
+	}
+	if len(params.Assets) > 0 {
+		part, err = mw.CreateFormFile("assets", "assets.zip")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(params.Assets); err != nil {
+			return nil, err
+		}
+	}
+	metadata, err := json.Marshal(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := mw.WriteField("metadata", string(metadata)); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return &multipartBody{Buffer: buf, contentType: mw.FormDataContentType()}, nil
+}
+
+func (c *Client) GetBuild(buildID uuid.UUID) (*types.Build, error) {
+	url := fmt.Sprintf("%s/build/%s", c.config.url, buildID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var build types.Build
+	if err := json.NewDecoder(resp.Body).Decode(&build); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &build, nil
+}
+
+// InvokeAsync queues an asynchronous invocation of the LIVE deployment of
+// the endpoint with the given request, the result is polled with
+// GetInvocation.
+func (c *Client) InvokeAsync(endpointID uuid.UUID, r types.AsyncRequest) (*types.Invocation, error) {
+	query := url.Values{"mode": {"async"}, "method": {r.Method}, "path": {r.Path}}
+	url := fmt.Sprintf("%s/endpoint/%s/invoke?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("POST", url, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("api responded with a non 202 status code: %d", resp.StatusCode)
+	}
+	var invocation types.Invocation
+	if err := json.NewDecoder(resp.Body).Decode(&invocation); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &invocation, nil
+}
+
+func (c *Client) GetInvocation(invocationID uuid.UUID) (*types.Invocation, error) {
+	url := fmt.Sprintf("%s/invocation/%s", c.config.url, invocationID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var invocation types.Invocation
+	if err := json.NewDecoder(resp.Body).Decode(&invocation); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &invocation, nil
+}
+
+// StartWorkflow starts a workflow of the LIVE deployment of the endpoint,
+// every step of the workflow is invoked with the given request. Its status
+// and state are polled with GetWorkflow.
+func (c *Client) StartWorkflow(endpointID uuid.UUID, r types.AsyncRequest) (*types.Workflow, error) {
+	query := url.Values{"method": {r.Method}, "path": {r.Path}}
+	url := fmt.Sprintf("%s/endpoint/%s/workflow?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("POST", url, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("api responded with a non 202 status code: %d", resp.StatusCode)
+	}
+	var workflow types.Workflow
+	if err := json.NewDecoder(resp.Body).Decode(&workflow); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &workflow, nil
+}
+
+func (c *Client) GetWorkflow(workflowID uuid.UUID) (*types.Workflow, error) {
+	url := fmt.Sprintf("%s/workflow/%s", c.config.url, workflowID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var workflow types.Workflow
+	if err := json.NewDecoder(resp.Body).Decode(&workflow); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &workflow, nil
+}
+
+// ScheduleInvocation schedules an invocation of the LIVE deployment of the
+// endpoint with the given request at the given time.
+func (c *Client) ScheduleInvocation(endpointID uuid.UUID, r types.AsyncRequest, at time.Time) (*types.ScheduledInvocation, error) {
+	query := url.Values{"method": {r.Method}, "path": {r.Path}, "at": {at.Format(time.RFC3339Nano)}}
+	url := fmt.Sprintf("%s/endpoint/%s/schedule?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("POST", url, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("api responded with a non 201 status code: %d", resp.StatusCode)
+	}
+	var scheduled types.ScheduledInvocation
+	if err := json.NewDecoder(resp.Body).Decode(&scheduled); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &scheduled, nil
+}
+
+// GetScheduledInvocations returns the scheduled invocations of the endpoint,
+// the earliest first.
+func (c *Client) GetScheduledInvocations(endpointID uuid.UUID) ([]types.ScheduledInvocation, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/schedule", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var scheduled []types.ScheduledInvocation
+	if err := json.NewDecoder(resp.Body).Decode(&scheduled); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return scheduled, nil
+}
+
+// CancelScheduledInvocation cancels a pending scheduled invocation of the
+// endpoint.
+func (c *Client) CancelScheduledInvocation(endpointID, scheduleID uuid.UUID) (*types.ScheduledInvocation, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/schedule/%s", c.config.url, endpointID, scheduleID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var scheduled types.ScheduledInvocation
+	if err := json.NewDecoder(resp.Body).Decode(&scheduled); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &scheduled, nil
+}
+
+// GetCaptures returns the captured requests of the endpoint, oldest first.
+func (c *Client) GetCaptures(endpointID uuid.UUID) ([]types.CapturedRequest, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/captures", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var captures []types.CapturedRequest
+	if err := json.NewDecoder(resp.Body).Decode(&captures); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return captures, nil
+}
+
+// GetCapturedRequest returns the captured request of the invocation.
+func (c *Client) GetCapturedRequest(invocationID uuid.UUID) (*types.CapturedRequest, error) {
+	url := fmt.Sprintf("%s/invocation/%s/request", c.config.url, invocationID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var capture types.CapturedRequest
+	if err := json.NewDecoder(resp.Body).Decode(&capture); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &capture, nil
+}
+
+// Replay sends the captured request of the invocation again, to the given
+// deployment in PREVIEW or to the LIVE deployment when the id is zero.
+func (c *Client) Replay(invocationID, deploymentID uuid.UUID) (*api.ReplayResponse, error) {
+	url := fmt.Sprintf("%s/invocation/%s/replay", c.config.url, invocationID)
+	if deploymentID != uuid.Nil {
+		url += "?deployment=" + deploymentID.String()
+	}
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var replay api.ReplayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&replay); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &replay, nil
+}
+
+// ListEndpoints returns the endpoints, only the endpoints with the given
+// name when it is not empty.
+func (c *Client) ListEndpoints(name string) ([]types.Endpoint, error) {
+	query := ""
+	if len(name) > 0 {
+		query = "?name=" + url.QueryEscape(name)
+	}
+	url := fmt.Sprintf("%s/endpoint%s", c.config.url, query)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var endpoints []types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return endpoints, nil
+}
+
+func (c *Client) GetRecommendation(endpointID uuid.UUID) (*recommend.Recommendation, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/recommendation", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var rec recommend.Recommendation
+	if err := json.NewDecoder(resp.Body).Decode(&rec); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &rec, nil
+}
+
+// GetMetricRollups returns the rollups of the request metrics of the
+// endpoint within the given window. An empty resolution lets the API pick
+// one by the length of the window.
+func (c *Client) GetMetricRollups(endpointID uuid.UUID, resolution string, from, to time.Time) ([]types.MetricRollup, error) {
+	query := url.Values{}
+	query.Set("from", from.Format(time.RFC3339))
+	query.Set("to", to.Format(time.RFC3339))
+	if len(resolution) > 0 {
+		query.Set("resolution", resolution)
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/metrics/rollups?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var rollups []types.MetricRollup
+	if err := json.NewDecoder(resp.Body).Decode(&rollups); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return rollups, nil
+}
+
+// GetSnapshot returns a snapshot of the metrics of the endpoint within the
+// given window.
+func (c *Client) GetSnapshot(endpointID uuid.UUID, from, to time.Time) (*types.MetricsSnapshot, error) {
+	query := url.Values{}
+	query.Set("from", from.Format(time.RFC3339))
+	query.Set("to", to.Format(time.RFC3339))
+	url := fmt.Sprintf("%s/endpoint/%s/snapshot?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var snapshot types.MetricsSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &snapshot, nil
+}
+
+// RotateEndpointKey adds a new data key version to the endpoint and returns
+// its version.
+func (c *Client) RotateEndpointKey(endpointID uuid.UUID) (*api.RotateKeyResponse, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/keys/rotate", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var rotateResponse api.RotateKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rotateResponse); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &rotateResponse, nil
+}
+
+// RewrapDataKeys re-wraps the data keys of all endpoints and projects with
+// the current master key.
+func (c *Client) RewrapDataKeys() (*storage.RewrapResult, error) {
+	url := fmt.Sprintf("%s/keys/rewrap", c.config.url)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var result storage.RewrapResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &result, nil
+}
+
+func (c *Client) PurgeEndpointCache(endpointID uuid.UUID) (*api.PurgeCacheResponse, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/cache/purge", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var purgeResponse api.PurgeCacheResponse
+	if err := json.NewDecoder(resp.Body).Decode(&purgeResponse); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &purgeResponse, nil
+}
+
+// GetEnvironmentDrift returns the difference between the environment
+// captured when the active deployment of the endpoint was published and its
+// current environment.
+func (c *Client) GetEnvironmentDrift(endpointID uuid.UUID) (*types.EnvironmentDrift, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/environment/drift", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var drift types.EnvironmentDrift
+	if err := json.NewDecoder(resp.Body).Decode(&drift); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &drift, nil
+}
+
+// DescribeEndpoint returns the endpoint with its LIVE deployment, its health,
+// the requests served in the given window and its recent deployments.
+func (c *Client) DescribeEndpoint(endpointID uuid.UUID, window time.Duration) (*api.DescribeResponse, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/describe?window=%s", c.config.url, endpointID, window)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var description api.DescribeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&description); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &description, nil
+}
+
+// Rollback makes the given deployment LIVE again, or the deployment that was
+// LIVE before the current one when the deployment id of the params is empty.
+func (c *Client) Rollback(endpointID uuid.UUID, params api.RollbackParams) (*api.PublishResponse, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/rollback", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if len(c.config.actor) > 0 {
+		req.Header.Set(api.ActorHeader, c.config.actor)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var publishResponse api.PublishResponse
+	if err := json.NewDecoder(resp.Body).Decode(&publishResponse); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &publishResponse, nil
+}
+
+// GetRollout returns the latest rollout of the endpoint.
+func (c *Client) GetRollout(endpointID uuid.UUID) (*types.Rollout, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/rollout", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var rollout types.Rollout
+	if err := json.NewDecoder(resp.Body).Decode(&rollout); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &rollout, nil
+}
+
+// AbortRollout shifts all the traffic of the running rollout of the endpoint
+// back to its active deployment.
+func (c *Client) AbortRollout(endpointID uuid.UUID) (*types.Rollout, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/rollout/abort", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.config.actor) > 0 {
+		req.Header.Set(api.ActorHeader, c.config.actor)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var rollout types.Rollout
+	if err := json.NewDecoder(resp.Body).Decode(&rollout); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &rollout, nil
+}
+
+// CreateWebhook registers a webhook of the endpoint. The returned webhook
+// holds its secret, it cannot be retrieved afterwards.
+func (c *Client) CreateWebhook(endpointID uuid.UUID, params api.CreateWebhookParams) (*types.Webhook, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/webhook", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var webhook types.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&webhook); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &webhook, nil
+}
+
+func (c *Client) GetWebhooks(endpointID uuid.UUID) ([]types.Webhook, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/webhook", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var webhooks []types.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&webhooks); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return webhooks, nil
+}
+
+func (c *Client) DeleteWebhook(endpointID, webhookID uuid.UUID) error {
+	url := fmt.Sprintf("%s/endpoint/%s/webhook/%s", c.config.url, endpointID, webhookID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetEvents returns the platform events of the endpoint, oldest first.
+func (c *Client) GetEvents(endpointID uuid.UUID) ([]types.Event, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/events", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var events []types.Event
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return events, nil
+}
+
+// GetConcurrency returns the in-flight and queued invocations of the
+// endpoint over the ingresses, with their peaks over the window. A zero
+// window uses the default window of the API.
+func (c *Client) GetConcurrency(endpointID uuid.UUID, window time.Duration) (*types.ConcurrencyStats, error) {
+	query := make(url.Values)
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/concurrency?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var stats types.ConcurrencyStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &stats, nil
+}
+
+// GetErrors returns the invocation errors of the endpoint grouped by their
+// signature. A zero deployment id selects the errors of all the deployments,
+// a zero window all the errors.
+func (c *Client) GetErrors(endpointID, deploymentID uuid.UUID, window time.Duration) ([]types.ErrorGroup, error) {
+	query := make(url.Values)
+	if deploymentID != uuid.Nil {
+		query.Set("deployment", deploymentID.String())
+	}
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/errors?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var groups []types.ErrorGroup
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return groups, nil
+}
+
+// GetDeadLetters returns the dead letters of the endpoint, oldest first.
+func (c *Client) GetDeadLetters(endpointID uuid.UUID) ([]types.DeadLetter, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/dead-letters", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var letters []types.DeadLetter
+	if err := json.NewDecoder(resp.Body).Decode(&letters); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return letters, nil
+}
+
+// RedriveDeadLetter invokes the request of the dead letter again in the
+// background, the letter is removed.
+func (c *Client) RedriveDeadLetter(endpointID, letterID uuid.UUID) error {
+	url := fmt.Sprintf("%s/endpoint/%s/dead-letters/%s/redrive", c.config.url, endpointID, letterID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("api responded with a non 202 status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteDeadLetter removes the dead letter without invoking its request.
+func (c *Client) DeleteDeadLetter(endpointID, letterID uuid.UUID) error {
+	url := fmt.Sprintf("%s/endpoint/%s/dead-letters/%s", c.config.url, endpointID, letterID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetDeployments returns the deployments of the endpoint selected by the
+// filter, oldest first.
+func (c *Client) GetDeployments(endpointID uuid.UUID, filter types.DeploymentFilter) ([]types.Deployment, error) {
+	query := make(url.Values)
+	for k, v := range filter.Labels {
+		query.Add("label", k+"="+v)
+	}
+	if len(filter.GitCommit) > 0 {
+		query.Set("commit", filter.GitCommit)
+	}
+	if len(filter.GitBranch) > 0 {
+		query.Set("branch", filter.GitBranch)
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/deployment?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var deploys []types.Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deploys); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return deploys, nil
+}
+
+// GetAuditLog returns the entries of the audit log selected by the filter,
+// newest first.
+func (c *Client) GetAuditLog(filter types.AuditFilter) ([]types.AuditEntry, error) {
+	query := make(url.Values)
+	if len(filter.Actor) > 0 {
+		query.Set("actor", filter.Actor)
+	}
+	if filter.EndpointID != uuid.Nil {
+		query.Set("endpoint", filter.EndpointID.String())
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	url := fmt.Sprintf("%s/audit?%s", c.config.url, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var entries []types.AuditEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return entries, nil
+}
+
+// GetUsage returns the usage and the quota of the projects, only of the
+// given project when it is not empty.
+func (c *Client) GetUsage(project string) ([]api.UsageResponse, error) {
+	query := make(url.Values)
+	if len(project) > 0 {
+		query.Set("project", project)
+	}
+	url := fmt.Sprintf("%s/usage?%s", c.config.url, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var usage []api.UsageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return usage, nil
+}
+
+// PruneDeployments deletes the deployments of the endpoint that are not kept
+// by its retention policy, or by the retention of the params.
+func (c *Client) PruneDeployments(endpointID uuid.UUID, params api.PruneParams) (*api.PruneResponse, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/deployment/prune", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var pruneResponse api.PruneResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pruneResponse); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &pruneResponse, nil
+}
+
+// GetHistory returns the publish and rollback history of the endpoint,
+// oldest event first.
+func (c *Client) GetHistory(endpointID uuid.UUID) ([]types.DeploymentEvent, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/history", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var history []types.DeploymentEvent
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return history, nil
+}
+
+// Transfer requests the transfer of the endpoint to another owner. The
+// endpoint is moved once the receiving owner accepts the transfer.
+func (c *Client) Transfer(endpointID uuid.UUID, params api.TransferParams) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/transfer", c.config.url, endpointID)
+	return c.updateOwnership(url, params)
+}
+
+// AcceptTransfer accepts the pending transfer of the endpoint on behalf of
+// the receiving owner.
+func (c *Client) AcceptTransfer(endpointID uuid.UUID, params api.AcceptTransferParams) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/transfer/accept", c.config.url, endpointID)
+	return c.updateOwnership(url, params)
+}
+
+func (c *Client) updateOwnership(url string, params any) (*types.Endpoint, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if len(c.config.actor) > 0 {
+		req.Header.Set(api.ActorHeader, c.config.actor)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+// GetPlatformStatus returns the status of the platform shown on the public
+// status page.
+// GetConfig returns the effective config of the API server.
+func (c *Client) GetConfig() (*config.Effective, error) {
+	url := fmt.Sprintf("%s/config", c.config.url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var effective config.Effective
+	if err := json.NewDecoder(resp.Body).Decode(&effective); err != nil {
+		return nil, err
+	}
+	return &effective, nil
+}
+
+// Backup writes the backup archive of all the endpoints to w.
+func (c *Client) Backup(w io.Writer) error {
+	url := fmt.Sprintf("%s/backup", c.config.url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Restore imports the endpoints of the backup archive read from r.
+func (c *Client) Restore(r io.Reader) (*backup.Result, error) {
+	url := fmt.Sprintf("%s/restore", c.config.url)
+	req, err := http.NewRequest("POST", url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/gzip")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var result backup.Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &result, nil
+}
+
+func (c *Client) GetPlatformStatus() (*types.PlatformStatus, error) {
+	url := fmt.Sprintf("%s/platform/status", c.config.url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var status types.PlatformStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &status, nil
+}
+
+// CreateIncident shows a new incident banner on the status page.
+func (c *Client) CreateIncident(params api.CreateIncidentParams) (*types.Incident, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/platform/incident", c.config.url)
+	return c.doIncident(url, bytes.NewReader(b))
+}
+
+// ResolveIncident marks the incident as resolved.
+func (c *Client) ResolveIncident(id uuid.UUID) (*types.Incident, error) {
+	url := fmt.Sprintf("%s/platform/incident/%s/resolve", c.config.url, id)
+	return c.doIncident(url, nil)
+}
+
+func (c *Client) doIncident(url string, body io.Reader) (*types.Incident, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var incident types.Incident
+	if err := json.NewDecoder(resp.Body).Decode(&incident); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &incident, nil
 }
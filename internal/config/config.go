@@ -2,8 +2,13 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 	"net"
+	"net/netip"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -14,6 +19,7 @@ httpAPIAddr 		= "127.0.0.1:3000"
 storageDriver 		= "postgres"
 apiToken			= ""
 authorization		= false
+logLevel 			= "info"
 
 [storage]
 user 				= "postgres"
@@ -22,10 +28,143 @@ name 				= "postgres"
 host				= "localhost"
 port				= "5432"
 sslmode 			= "disable"
+slowThreshold 		= "100ms"
+cacheTTL 			= "5s"
+
+[ingress]
+queueSize 			= 100
+queueTimeout 		= "5s"
+altSvc 				= ""
+responseCacheSize 	= 67108864
+h2c 				= true
+accessLog 			= ""
+readHeaderTimeout 	= "10s"
+readTimeout 		= "60s"
+writeTimeout 		= "60s"
+idleTimeout 		= "120s"
+maxHeaderBytes 		= 65536
+maxConcurrentStreams	= 250
+trustedProxies 		= []
+
+[limits]
+timeout 			= "30s"
+maxResponseSize 	= 10485760
+maxBlobSize 		= 67108864
+scratchSize 		= 16777216
+cacheSize 			= 67108864
+cacheQuota 			= 8388608
+
+[probes]
+failureThreshold 	= 3
+alertWebhook 		= ""
+
+[health]
+interval 			= "30s"
+timeout 			= "5s"
+failureThreshold 	= 3
+gatePublish 		= false
+
+[cors]
+allowedMethods 		= ["GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"]
+allowedHeaders 		= ["Accept", "Authorization", "Content-Type"]
+exposedHeaders 		= ["X-Request-Id"]
+maxAge 				= "10m"
+
+[encryption]
+masterKey 			= ""
+previousMasterKeys 	= []
+
+[export]
+url 				= ""
+interval 			= "1h"
+retention 			= "0s"
+endpoint 			= ""
+region 				= "us-east-1"
+accessKey 			= ""
+secretKey 			= ""
+
+[usageExport]
+csv 				= false
+remoteWriteURL 		= ""
+remoteWriteUsername = ""
+remoteWritePassword = ""
+webhookURL 			= ""
+webhookSecret 		= ""
+
+[metrics]
+retention 			= "0s"
+minuteRetention 	= "720h"
+hourRetention 		= "0s"
+
+[async]
+maxAttempts 		= 3
+backoff 			= "1s"
+maxBackoff 			= "1m"
+maxDepth 			= 4
+scheduleInterval 	= "1s"
+secret 				= ""
+
+[workflows]
+wakeInterval 		= "1s"
+maxSteps 			= 1000
+
+[deployments]
+gcInterval 			= "1h"
+
+[build]
+enabled 			= false
+timeout 			= "5m"
+maxSourceSize 		= 33554432
+concurrency 		= 2
+sandbox 			= []
+
+[signing]
+publicKeys 			= []
+required 			= false
+
+[status]
+ingresses 			= []
+window 				= "1h"
+interval 			= "5m"
+
+[policy]
+maxBlobSize 		= 0
+forbiddenImports 	= []
+protectedEndpoints 	= []
+opaURL 				= ""
+
+[policy.changeWindow]
+days 				= ["mon", "tue", "wed", "thu", "fri"]
+start 				= "09:00"
+end 				= "17:00"
+
+[cluster]
+id 					= ""
+region 				= "default"
+provider 			= "selfmanaged"
+activation 			= "region"
+ingressListenAddr 	= "127.0.0.1:8132"
+runtimeListenAddr 	= "127.0.0.1:8134"
+
+[cluster.selfManaged]
+members 			= []
+
+[cluster.kubernetes]
+service 			= "raptor.default.svc.cluster.local"
+port 				= "8134"
+
+[cluster.consul]
+address 			= "http://127.0.0.1:8500"
+service 			= "raptor"
 `
 
-// Config holds the global configuration which is READONLY.
-var config Config
+// Config holds the global configuration, it is only changed by a reload.
+var (
+	mu       sync.RWMutex
+	config   Config
+	loadedAT time.Time
+	logLevel = new(slog.LevelVar)
+)
 
 type Storage struct {
 	Name     string
@@ -34,6 +173,367 @@ type Storage struct {
 	Host     string
 	Port     string
 	SSLMode  string
+	// Store operations taking longer than the threshold are logged, 0
+	// disables the logging of slow operations.
+	SlowThreshold Duration
+	// Time the ingress and the runtimes cache the endpoints and the
+	// deployments they look up, 0 disables the cache. The changes are
+	// published to the caches right away.
+	CacheTTL Duration
+}
+
+// Kubernetes holds the configuration of the kubernetes cluster provider.
+type Kubernetes struct {
+	// DNS name of the headless service selecting the cluster members.
+	Service string
+	// Port the cluster members are listening on.
+	Port string
+}
+
+// Consul holds the configuration of the consul cluster provider.
+type Consul struct {
+	// HTTP address of the consul agent.
+	Address string
+	// Name of the service the cluster members register under.
+	Service string
+}
+
+// SelfManaged holds the configuration of the selfmanaged cluster provider.
+type SelfManaged struct {
+	// Members the member joins the cluster through, as "id@host:port". The
+	// other members are discovered through them.
+	Members []string
+}
+
+type Cluster struct {
+	// ID of the member, the hostname when empty. The kind of the member is
+	// appended to it, like "edge-1-ingress".
+	ID     string
+	Region string
+	// Provider used for cluster membership (selfmanaged, kubernetes or consul)
+	Provider string
+	// Strategy picking the member a runtime is activated on: region, a
+	// member of the region of the request when there is one, random, or
+	// load, the least loaded member of the region of the request.
+	Activation string
+	// Addresses the ingress and the runtime members listen on for the
+	// other members of the cluster.
+	IngressListenAddr string
+	RuntimeListenAddr string
+	SelfManaged       SelfManaged
+	Kubernetes        Kubernetes
+	Consul            Consul
+}
+
+// Duration is a time.Duration that can be decoded from a string
+// like "5s" or "100ms".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Ingress holds the configuration of the wasm server.
+type Ingress struct {
+	// Maximum amount of requests queued per endpoint when all of its
+	// runtimes are busy.
+	QueueSize int
+	// Maximum time a request waits in the queue before it is rejected.
+	QueueTimeout Duration
+	// Alt-Svc header value advertised on every response, like
+	// 'h3=":443"; ma=86400' when HTTP/3 is terminated by a proxy in front of
+	// the ingress. Nothing is advertised when empty.
+	AltSvc string
+	// Maximum size in bytes of the responses cached by the ingress for the
+	// endpoints that have response caching enabled.
+	ResponseCacheSize int
+	// Serve plaintext HTTP/2 (h2c) next to HTTP/1.1, which gRPC clients
+	// need to reach the endpoints without a TLS terminating proxy.
+	H2C bool
+	// Where the access log is written as JSON lines, stdout, stderr or the
+	// path of a file. The access log is disabled when empty.
+	AccessLog string
+	// Maximum time to read the headers of a request.
+	ReadHeaderTimeout Duration
+	// Maximum time to read a request, its body included.
+	ReadTimeout Duration
+	// Maximum time to write a response to the client, or a chunk of a
+	// streamed response. The invocation itself is bounded by its timeout.
+	WriteTimeout Duration
+	// Maximum time an idle keep-alive connection is kept open.
+	IdleTimeout Duration
+	// Maximum size in bytes of the headers of a request.
+	MaxHeaderBytes int
+	// Maximum amount of concurrent streams of an HTTP/2 connection.
+	MaxConcurrentStreams int
+	// Addresses or CIDR ranges of the proxies in front of the ingress, like
+	// "10.0.0.0/8". The X-Forwarded-For and X-Real-IP headers are only
+	// trusted when they are set by one of them.
+	TrustedProxies []string
+}
+
+// ParseTrustedProxies parses the trusted proxies of the ingress, a single
+// address is a range of one address.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, must be an address or a CIDR range", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Limits bound the resources of the invocations of all the endpoints, the
+// endpoints can lower them but never raise them. Zero means unlimited.
+type Limits struct {
+	// Maximum wall time of an invocation.
+	Timeout Duration
+	// Maximum size in bytes of the output of an invocation, the logs and
+	// the response combined.
+	MaxResponseSize int
+	// Maximum size in bytes of the blob of a deployment.
+	MaxBlobSize int
+	// Quota in bytes of the writable /tmp directory of an invocation, which
+	// is wiped after the invocation. 0 disables the directory.
+	ScratchSize int64
+	// Size in bytes of the in-memory cache the guests of a member memoize
+	// values in, shared by the endpoints. 0 disables the cache.
+	CacheSize int
+	// Maximum size in bytes of the entries of an endpoint in the cache of a
+	// member.
+	CacheQuota int
+}
+
+// Probes holds the configuration of the synthetic monitoring probes.
+type Probes struct {
+	// Amount of consecutive failed checks before a probe is considered down.
+	FailureThreshold int
+	// Optional URL alerts are posted to when a probe goes down or recovers.
+	AlertWebhook string
+}
+
+// CORS holds the defaults of the CORS policies of the endpoints, the
+// endpoints choose their allowed origins and can override the rest.
+type CORS struct {
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// Time the browsers may cache a preflight response.
+	MaxAge Duration
+}
+
+type Encryption struct {
+	// Base64 encoded 32 byte key that wraps the data keys of the endpoints.
+	// The environment of the endpoints is stored in plaintext when empty.
+	MasterKey string
+	// Master keys replaced by the master key, they unwrap the data keys
+	// until raptor admin rotate-master-key re-wrapped them.
+	PreviousMasterKeys []string
+}
+
+// Export holds the configuration of the bulk export of the request metrics
+// and the logs of the invocations to object storage.
+type Export struct {
+	// Destination of the export: file:///path, s3://bucket/prefix or
+	// gs://bucket/prefix. Nothing is exported when empty.
+	URL string
+	// Interval between two exports, the exported files are partitioned by
+	// the window they cover.
+	Interval Duration
+	// Exported request metrics older than the retention are deleted from
+	// the metric store, 0 keeps them.
+	Retention Duration
+	// Optional S3 compatible endpoint, like a MinIO server. Defaults to AWS
+	// for s3:// and to the interoperability API of GCS for gs:// URLs.
+	Endpoint string
+	Region   string
+	// HMAC credentials of the object storage.
+	AccessKey string
+	SecretKey string
+}
+
+// UsageExport holds the configuration of the export of the hourly usage of
+// the endpoints to billing systems. The usage is rolled up from the request
+// metrics, the export retention must keep them for more than an hour.
+type UsageExport struct {
+	// Write the hourly usage as CSV files to the export destination.
+	CSV bool
+	// Prometheus remote-write URL the usage is pushed to, with optional
+	// basic auth credentials.
+	RemoteWriteURL      string
+	RemoteWriteUsername string
+	RemoteWritePassword string
+	// Billing webhook the usage of every hour is posted to as JSON, signed
+	// with the secret like the webhooks of the platform events.
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// Async holds the default retry policy of the asynchronous invocations, the
+// endpoints can override it.
+type Async struct {
+	// Maximum attempts of an invocation, the first attempt included.
+	MaxAttempts int
+	// Wait before the first retry, it doubles with every retry up to the
+	// maximum backoff.
+	Backoff    Duration
+	MaxBackoff Duration
+	// Maximum depth of the chains of guests invoking other endpoints, 0
+	// disables the invocations of the guests.
+	MaxDepth int
+	// Interval between two checks for the scheduled invocations that are
+	// due, 0 disables firing them.
+	ScheduleInterval Duration
+	// Secret the invocations of the platform are signed with, so they pass
+	// the JWT policies and the request verification of the endpoints. The
+	// API servers, the runtimes and the ingresses need the same secret, it
+	// is required by all of them.
+	Secret string
+}
+
+// Metrics holds the retention of the request metrics and of their rollups
+// of a minute and of an hour, 0 keeps them. The request metrics and the
+// minute rollups are only deleted once they are rolled up.
+type Metrics struct {
+	Retention       Duration
+	MinuteRetention Duration
+	HourRetention   Duration
+}
+
+// Workflows holds the configuration of the workflows, the long-running
+// processes of the endpoints made of asynchronous invocations.
+type Workflows struct {
+	// Interval between two checks for the sleeping workflows to wake up, 0
+	// disables waking them up.
+	WakeInterval Duration
+	// Maximum steps of a workflow, 0 is unlimited. A workflow fails
+	// instead of invoking a step beyond it.
+	MaxSteps int
+}
+
+// Deployments holds the configuration of the garbage collection of the
+// deployments that are not kept by the retention policy of their endpoint.
+type Deployments struct {
+	// Interval between two collections, 0 disables the background
+	// collection. Deployments can still be pruned manually.
+	GCInterval Duration
+}
+
+// Build holds the configuration of the service compiling uploaded source
+// archives into deployments.
+type Build struct {
+	// Accept source archives, they are built on the API server.
+	Enabled bool
+	// Maximum wall time of a build.
+	Timeout Duration
+	// Maximum size in bytes of an uploaded source archive.
+	MaxSourceSize int
+	// Amount of builds running at the same time, the other builds are
+	// queued.
+	Concurrency int
+	// Command the toolchains are run with, like a container runtime or
+	// "bwrap" with its arguments. The toolchain is appended to it, and runs
+	// directly when empty.
+	Sandbox []string
+}
+
+// Signing holds the public keys the signatures of the deployments are
+// verified with before they are executed.
+type Signing struct {
+	// Base64 encoded ed25519 public keys, a signature made by any of them
+	// is trusted.
+	PublicKeys []string
+	// Refuse to execute the deployments that are not signed.
+	Required bool
+}
+
+// Status holds the configuration of the public platform status page.
+type Status struct {
+	// Admin addresses of the ingresses whose health is shown, as
+	// "region=addr" or "addr" for the region the ingress reports.
+	Ingresses []string
+	// Period covered by the request stats and the resolved incidents.
+	Window Duration
+	// Length of the windows the request stats are aggregated in.
+	Interval Duration
+}
+
+// ChangeWindow is a recurring window in UTC in which protected endpoints
+// can be changed.
+type ChangeWindow struct {
+	Days  []string
+	Start string
+	End   string
+}
+
+// Policy holds the rules deployments and publishes are evaluated against.
+type Policy struct {
+	// Maximum size of a deployment in bytes, 0 means unlimited.
+	MaxBlobSize int
+	// WASM imports (module.name prefixes) deployments are not allowed to use.
+	ForbiddenImports []string
+	// Endpoints that can only be changed during the change window.
+	ProtectedEndpoints []string
+	ChangeWindow       ChangeWindow
+	// URL of an optional Open Policy Agent policy document.
+	OPAURL string
+}
+
+// Health holds the configuration of the calls of the health function the
+// guests optionally export.
+type Health struct {
+	// Interval between two checks of a warm runtime, 0 disables them.
+	Interval Duration
+	Timeout  Duration
+	// Amount of consecutive failed checks after which a deployment is
+	// unhealthy.
+	FailureThreshold int
+	// Refuse to publish deployments that are unhealthy or fail the check
+	// that is run before the publish.
+	GatePublish bool
+}
+
+// APIKey is a key of the management API with the role it grants. The API
+// token of the config is an admin key of all the projects.
+type APIKey struct {
+	// Name of the key, like the CI system using it.
+	Name string
+	Key  string
+	// read-only, deployer or admin.
+	Role string
+	// Project, the owner of the endpoints, the key is limited to. Keys
+	// without a project can access all the endpoints.
+	Project string
+}
+
+// Quota limits the resources of a project, 0 is unlimited.
+type Quota struct {
+	// Project the quota applies to. The quota without a project applies to
+	// the projects without a quota of their own.
+	Project        string `json:"project,omitempty"`
+	MaxEndpoints   int    `json:"max_endpoints"`
+	MaxDeployments int    `json:"max_deployments"`
+	// Maximum invocations of the LIVE endpoints per calendar month (UTC).
+	MaxInvocations int64 `json:"max_invocations"`
+	// Maximum GB-seconds of execution per calendar month, the peak memory
+	// of an invocation in GB times its duration in seconds.
+	MaxGBSeconds float64 `json:"max_gb_seconds"`
 }
 
 type Config struct {
@@ -42,28 +542,84 @@ type Config struct {
 	StorageDriver   string
 	APIToken        string
 	Authorization   bool
-	Storage         Storage
+	// Minimum level of the logs: debug, info, warn or error.
+	LogLevel    string
+	APIKeys     []APIKey
+	Quotas      []Quota
+	Storage     Storage
+	Cluster     Cluster
+	Policy      Policy
+	Ingress     Ingress
+	Limits      Limits
+	Probes      Probes
+	Health      Health
+	CORS        CORS
+	Encryption  Encryption
+	Export      Export
+	UsageExport UsageExport
+	Metrics     Metrics
+	Async       Async
+	Workflows   Workflows
+	Deployments Deployments
+	Build       Build
+	Signing     Signing
+	Status      Status
 }
 
+// Parse parses the config file at the given path and applies the
+// environment variable overrides. Without a config file the defaults are
+// used, and written to the path for the next run when possible.
 func Parse(path string) error {
-	_, err := os.Stat(path)
-	if errors.Is(err, os.ErrNotExist) {
-		if err := os.WriteFile("config.toml", []byte(defaultConfig), os.ModePerm); err != nil {
-			return err
-		}
-	}
-	b, err := os.ReadFile(path)
+	c, err := load(path)
 	if err != nil {
 		return err
 	}
-	err = toml.Unmarshal(b, &config)
-	return err
+	mu.Lock()
+	defer mu.Unlock()
+	config = c
+	loadedAT = time.Now()
+	setLogLevel(c.LogLevel)
+	return nil
+}
+
+func load(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		b = []byte(defaultConfig)
+		// Containers often have a read-only filesystem and rely on the
+		// environment, the defaults not being written is fine.
+		_ = os.WriteFile(path, b, os.ModePerm)
+	} else if err != nil {
+		return c, err
+	}
+	if err := toml.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return c, applyEnv(&c)
 }
 
 func Get() Config {
+	mu.RLock()
+	defer mu.RUnlock()
 	return config
 }
 
+// LogLevel returns the level of the logs of the config, it follows the
+// reloads of the config.
+func LogLevel() slog.Leveler {
+	return logLevel
+}
+
+// setLogLevel sets the level of the logs, info when the level is invalid.
+func setLogLevel(level string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
+	}
+	logLevel.Set(l)
+}
+
 // makeURL takes a host address and returns a http URL.
 func makeURL(address string) string {
 	host, port, err := net.SplitHostPort(address)
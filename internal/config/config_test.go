@@ -1,6 +1,14 @@
 package config
 
-import "testing"
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
 
 func TestMakeURL(t *testing.T) {
 	testCases := []struct {
@@ -21,3 +29,175 @@ func TestMakeURL(t *testing.T) {
 	}
 
 }
+
+func TestParseDuration(t *testing.T) {
+	path := t.TempDir() + "/config.toml"
+	b := []byte(`
+[ingress]
+queueSize = 10
+queueTimeout = "250ms"
+`)
+	if err := os.WriteFile(path, b, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(Get().Ingress.QueueTimeout) != time.Millisecond*250 {
+		t.Errorf("Expected 250ms, got %s", time.Duration(Get().Ingress.QueueTimeout))
+	}
+}
+
+func TestParseDefaultLimits(t *testing.T) {
+	path := t.TempDir() + "/config.toml"
+	if err := os.WriteFile(path, []byte(defaultConfig), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	limits := Get().Limits
+	if time.Duration(limits.Timeout) != time.Second*30 {
+		t.Errorf("Expected 30s, got %s", time.Duration(limits.Timeout))
+	}
+	if limits.MaxResponseSize != 10<<20 || limits.MaxBlobSize != 64<<20 || limits.ScratchSize != 16<<20 {
+		t.Errorf("Unexpected limits %+v", limits)
+	}
+	ingress := Get().Ingress
+	if time.Duration(ingress.ReadHeaderTimeout) != time.Second*10 || time.Duration(ingress.IdleTimeout) != time.Second*120 {
+		t.Errorf("Unexpected ingress timeouts %+v", ingress)
+	}
+	if ingress.MaxHeaderBytes != 64<<10 || ingress.MaxConcurrentStreams != 250 {
+		t.Errorf("Unexpected ingress limits %+v", ingress)
+	}
+}
+
+func TestParseEnvOverrides(t *testing.T) {
+	t.Setenv("RAPTOR_API_ADDR", ":4000")
+	t.Setenv("RAPTOR_STORAGE_HOST", "db.internal")
+	t.Setenv("RAPTOR_AUTHORIZATION", "true")
+	t.Setenv("RAPTOR_INGRESS_QUEUE_TIMEOUT", "2s")
+	t.Setenv("RAPTOR_CLUSTER_MEMBERS", "runtime-1@10.0.0.2:8134,runtime-2@10.0.0.3:8134")
+	// Without a config file the defaults are used.
+	path := t.TempDir() + "/config.toml"
+	if err := Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	c := Get()
+	if c.HTTPAPIAddr != ":4000" || c.Storage.Host != "db.internal" || !c.Authorization {
+		t.Errorf("Unexpected overrides %s %s %v", c.HTTPAPIAddr, c.Storage.Host, c.Authorization)
+	}
+	if time.Duration(c.Ingress.QueueTimeout) != time.Second*2 {
+		t.Errorf("Expected 2s, got %s", time.Duration(c.Ingress.QueueTimeout))
+	}
+	if len(c.Cluster.SelfManaged.Members) != 2 || c.Cluster.SelfManaged.Members[1] != "runtime-2@10.0.0.3:8134" {
+		t.Errorf("Unexpected members %v", c.Cluster.SelfManaged.Members)
+	}
+	if c.HTTPIngressAddr != "127.0.0.1:5000" {
+		t.Errorf("Expected the default ingress address, got %s", c.HTTPIngressAddr)
+	}
+
+	t.Setenv("RAPTOR_INGRESS_QUEUE_SIZE", "many")
+	if err := Parse(path); err == nil || !strings.Contains(err.Error(), "RAPTOR_INGRESS_QUEUE_SIZE") {
+		t.Errorf("Expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var c Config
+	if err := toml.Unmarshal([]byte(defaultConfig), &c); err != nil {
+		t.Fatal(err)
+	}
+	// The internal secret has no default, the servers of a cluster share it.
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "RAPTOR_ASYNC_SECRET") {
+		t.Errorf("Expected the secret to be required, got %v", err)
+	}
+	c.Async.Secret = "secret"
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected the default config to be valid, got %v", err)
+	}
+
+	c.HTTPAPIAddr = "3000"
+	c.Storage.Host = ""
+	c.Authorization = true
+	c.Cluster.Provider = "swarm"
+	c.Encryption.MasterKey = "secret"
+	c.Policy.ChangeWindow.End = "5pm"
+	c.Cluster.Activation = "nearest"
+	c.Cluster.SelfManaged.Members = []string{"runtime-1@10.0.0.2:8134", "10.0.0.3:8134"}
+	c.Ingress.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"}
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Expected the config to be invalid")
+	}
+	for _, key := range []string{"httpAPIAddr", "RAPTOR_STORAGE_HOST", "apiToken", "cluster.provider", "encryption.masterKey", "policy.changeWindow.end", "cluster.activation", "members[1]", "proxy.internal"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected %s in %q", key, err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Setenv("RAPTOR_ASYNC_SECRET", "secret")
+	path := t.TempDir() + "/config.toml"
+	if err := os.WriteFile(path, []byte(defaultConfig), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := Parse(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b := strings.Replace(defaultConfig, `timeout 			= "30s"`, `timeout 			= "10s"`, 1)
+	b = strings.Replace(b, `logLevel 			= "info"`, `logLevel 			= "debug"`, 1)
+	b = strings.Replace(b, `httpAPIAddr 		= "127.0.0.1:3000"`, `httpAPIAddr 		= "127.0.0.1:4000"`, 1)
+	if err := os.WriteFile(path, []byte(b), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Reload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(changes.Applied, ",") != "logLevel,limits" || !changes.RestartRequired {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+	if time.Duration(Get().Limits.Timeout) != time.Second*10 {
+		t.Errorf("Expected 10s, got %s", time.Duration(Get().Limits.Timeout))
+	}
+	if Get().HTTPAPIAddr != "127.0.0.1:3000" {
+		t.Errorf("Expected the address to need a restart, got %s", Get().HTTPAPIAddr)
+	}
+	if LogLevel().Level() != slog.LevelDebug {
+		t.Errorf("Expected the debug level, got %s", LogLevel().Level())
+	}
+
+	// An invalid config is not applied.
+	b = strings.Replace(b, `timeout 			= "10s"`, `timeout 			= "-1s"`, 1)
+	if err := os.WriteFile(path, []byte(b), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reload(path); err == nil {
+		t.Error("Expected the invalid config to be refused")
+	}
+	if time.Duration(Get().Limits.Timeout) != time.Second*10 {
+		t.Errorf("Expected 10s, got %s", time.Duration(Get().Limits.Timeout))
+	}
+}
+
+func TestGetEffective(t *testing.T) {
+	t.Setenv("RAPTOR_STORAGE_PASSWORD", "secret")
+	t.Setenv("RAPTOR_API_TOKEN", "token")
+	path := t.TempDir() + "/config.toml"
+	if err := Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	effective := GetEffective()
+	if effective.Config.Storage.Password != "REDACTED" || effective.Config.APIToken != "REDACTED" {
+		t.Errorf("Expected the secrets to be redacted, got %+v", effective.Config)
+	}
+	if Get().Storage.Password != "secret" {
+		t.Errorf("Expected the config to keep its secrets, got %s", Get().Storage.Password)
+	}
+	if effective.LoadedAT.IsZero() || len(effective.Reloadable) == 0 {
+		t.Errorf("Unexpected effective config %+v", effective)
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envVar is an environment variable overriding a setting of the config
+// file, so containers can be configured without mounting a file.
+type envVar struct {
+	name string
+	// Key of the setting in the config file.
+	key   string
+	value func(*Config) any
+}
+
+var envVars = []envVar{
+	{"RAPTOR_API_ADDR", "httpAPIAddr", func(c *Config) any { return &c.HTTPAPIAddr }},
+	{"RAPTOR_INGRESS_ADDR", "httpIngressAddr", func(c *Config) any { return &c.HTTPIngressAddr }},
+	{"RAPTOR_STORAGE_DRIVER", "storageDriver", func(c *Config) any { return &c.StorageDriver }},
+	{"RAPTOR_API_TOKEN", "apiToken", func(c *Config) any { return &c.APIToken }},
+	{"RAPTOR_AUTHORIZATION", "authorization", func(c *Config) any { return &c.Authorization }},
+	{"RAPTOR_LOG_LEVEL", "logLevel", func(c *Config) any { return &c.LogLevel }},
+	{"RAPTOR_STORAGE_USER", "storage.user", func(c *Config) any { return &c.Storage.User }},
+	{"RAPTOR_STORAGE_PASSWORD", "storage.password", func(c *Config) any { return &c.Storage.Password }},
+	{"RAPTOR_STORAGE_NAME", "storage.name", func(c *Config) any { return &c.Storage.Name }},
+	{"RAPTOR_STORAGE_HOST", "storage.host", func(c *Config) any { return &c.Storage.Host }},
+	{"RAPTOR_STORAGE_PORT", "storage.port", func(c *Config) any { return &c.Storage.Port }},
+	{"RAPTOR_STORAGE_SSLMODE", "storage.sslmode", func(c *Config) any { return &c.Storage.SSLMode }},
+	{"RAPTOR_STORAGE_SLOW_THRESHOLD", "storage.slowThreshold", func(c *Config) any { return &c.Storage.SlowThreshold }},
+	{"RAPTOR_STORAGE_CACHE_TTL", "storage.cacheTTL", func(c *Config) any { return &c.Storage.CacheTTL }},
+	{"RAPTOR_INGRESS_QUEUE_SIZE", "ingress.queueSize", func(c *Config) any { return &c.Ingress.QueueSize }},
+	{"RAPTOR_INGRESS_QUEUE_TIMEOUT", "ingress.queueTimeout", func(c *Config) any { return &c.Ingress.QueueTimeout }},
+	{"RAPTOR_INGRESS_ALT_SVC", "ingress.altSvc", func(c *Config) any { return &c.Ingress.AltSvc }},
+	{"RAPTOR_INGRESS_H2C", "ingress.h2c", func(c *Config) any { return &c.Ingress.H2C }},
+	{"RAPTOR_INGRESS_ACCESS_LOG", "ingress.accessLog", func(c *Config) any { return &c.Ingress.AccessLog }},
+	{"RAPTOR_INGRESS_READ_HEADER_TIMEOUT", "ingress.readHeaderTimeout", func(c *Config) any { return &c.Ingress.ReadHeaderTimeout }},
+	{"RAPTOR_INGRESS_READ_TIMEOUT", "ingress.readTimeout", func(c *Config) any { return &c.Ingress.ReadTimeout }},
+	{"RAPTOR_INGRESS_WRITE_TIMEOUT", "ingress.writeTimeout", func(c *Config) any { return &c.Ingress.WriteTimeout }},
+	{"RAPTOR_INGRESS_IDLE_TIMEOUT", "ingress.idleTimeout", func(c *Config) any { return &c.Ingress.IdleTimeout }},
+	{"RAPTOR_INGRESS_MAX_HEADER_BYTES", "ingress.maxHeaderBytes", func(c *Config) any { return &c.Ingress.MaxHeaderBytes }},
+	{"RAPTOR_INGRESS_MAX_CONCURRENT_STREAMS", "ingress.maxConcurrentStreams", func(c *Config) any { return &c.Ingress.MaxConcurrentStreams }},
+	{"RAPTOR_INGRESS_TRUSTED_PROXIES", "ingress.trustedProxies", func(c *Config) any { return &c.Ingress.TrustedProxies }},
+	{"RAPTOR_CLUSTER_ID", "cluster.id", func(c *Config) any { return &c.Cluster.ID }},
+	{"RAPTOR_CLUSTER_REGION", "cluster.region", func(c *Config) any { return &c.Cluster.Region }},
+	{"RAPTOR_CLUSTER_PROVIDER", "cluster.provider", func(c *Config) any { return &c.Cluster.Provider }},
+	{"RAPTOR_CLUSTER_ACTIVATION", "cluster.activation", func(c *Config) any { return &c.Cluster.Activation }},
+	{"RAPTOR_CLUSTER_INGRESS_LISTEN_ADDR", "cluster.ingressListenAddr", func(c *Config) any { return &c.Cluster.IngressListenAddr }},
+	{"RAPTOR_CLUSTER_RUNTIME_LISTEN_ADDR", "cluster.runtimeListenAddr", func(c *Config) any { return &c.Cluster.RuntimeListenAddr }},
+	{"RAPTOR_CLUSTER_MEMBERS", "cluster.selfManaged.members", func(c *Config) any { return &c.Cluster.SelfManaged.Members }},
+	{"RAPTOR_KUBERNETES_SERVICE", "cluster.kubernetes.service", func(c *Config) any { return &c.Cluster.Kubernetes.Service }},
+	{"RAPTOR_KUBERNETES_PORT", "cluster.kubernetes.port", func(c *Config) any { return &c.Cluster.Kubernetes.Port }},
+	{"RAPTOR_CONSUL_ADDRESS", "cluster.consul.address", func(c *Config) any { return &c.Cluster.Consul.Address }},
+	{"RAPTOR_CONSUL_SERVICE", "cluster.consul.service", func(c *Config) any { return &c.Cluster.Consul.Service }},
+	{"RAPTOR_ENCRYPTION_MASTER_KEY", "encryption.masterKey", func(c *Config) any { return &c.Encryption.MasterKey }},
+	{"RAPTOR_ENCRYPTION_PREVIOUS_MASTER_KEYS", "encryption.previousMasterKeys", func(c *Config) any { return &c.Encryption.PreviousMasterKeys }},
+	{"RAPTOR_EXPORT_URL", "export.url", func(c *Config) any { return &c.Export.URL }},
+	{"RAPTOR_EXPORT_ACCESS_KEY", "export.accessKey", func(c *Config) any { return &c.Export.AccessKey }},
+	{"RAPTOR_EXPORT_SECRET_KEY", "export.secretKey", func(c *Config) any { return &c.Export.SecretKey }},
+	{"RAPTOR_USAGE_EXPORT_REMOTE_WRITE_PASSWORD", "usageExport.remoteWritePassword", func(c *Config) any { return &c.UsageExport.RemoteWritePassword }},
+	{"RAPTOR_USAGE_EXPORT_WEBHOOK_SECRET", "usageExport.webhookSecret", func(c *Config) any { return &c.UsageExport.WebhookSecret }},
+	{"RAPTOR_ASYNC_SECRET", "async.secret", func(c *Config) any { return &c.Async.Secret }},
+	{"RAPTOR_METRICS_RETENTION", "metrics.retention", func(c *Config) any { return &c.Metrics.Retention }},
+	{"RAPTOR_PROBES_ALERT_WEBHOOK", "probes.alertWebhook", func(c *Config) any { return &c.Probes.AlertWebhook }},
+	{"RAPTOR_BUILD_ENABLED", "build.enabled", func(c *Config) any { return &c.Build.Enabled }},
+}
+
+// applyEnv overrides the settings of the config with the environment
+// variables that are set.
+func applyEnv(c *Config) error {
+	for _, v := range envVars {
+		value, ok := os.LookupEnv(v.name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch field := v.value(c).(type) {
+		case *string:
+			*field = value
+		case *bool:
+			*field, err = strconv.ParseBool(value)
+		case *int:
+			*field, err = strconv.Atoi(value)
+		case *Duration:
+			err = field.UnmarshalText([]byte(value))
+		case *[]string:
+			// Lists are comma separated.
+			*field = strings.Split(value, ",")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q of %s: %w", value, v.name, err)
+		}
+	}
+	return nil
+}
+
+// envName returns the environment variable overriding the setting with the
+// given key, if any.
+func envName(key string) string {
+	for _, v := range envVars {
+		if v.key == key {
+			return v.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// reloadable are the settings a reload changes at runtime, the other
+// settings only change with a restart. They are read by the servers for
+// every request or invocation, the in-flight work keeps the values it
+// started with.
+var reloadable = []struct {
+	key   string
+	value func(*Config) any
+}{
+	{"logLevel", func(c *Config) any { return &c.LogLevel }},
+	{"ingress.queueSize", func(c *Config) any { return &c.Ingress.QueueSize }},
+	{"ingress.queueTimeout", func(c *Config) any { return &c.Ingress.QueueTimeout }},
+	{"limits", func(c *Config) any { return &c.Limits }},
+	{"cors", func(c *Config) any { return &c.CORS }},
+	{"probes", func(c *Config) any { return &c.Probes }},
+	{"health.interval", func(c *Config) any { return &c.Health.Interval }},
+	{"health.timeout", func(c *Config) any { return &c.Health.Timeout }},
+	{"health.failureThreshold", func(c *Config) any { return &c.Health.FailureThreshold }},
+	{"build.maxSourceSize", func(c *Config) any { return &c.Build.MaxSourceSize }},
+}
+
+// Changes are the changes of a reload of the config.
+type Changes struct {
+	// Reloadable settings that changed.
+	Applied []string
+	// The config has other changes, they apply after a restart.
+	RestartRequired bool
+}
+
+// Reload parses the config file at the given path again and applies the
+// changes of the reloadable settings. An invalid config is not applied at
+// all.
+func Reload(path string) (Changes, error) {
+	var changes Changes
+	c, err := load(path)
+	if err != nil {
+		return changes, err
+	}
+	if err := c.Validate(); err != nil {
+		return changes, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	next := config
+	for _, r := range reloadable {
+		current, reloaded := reflect.ValueOf(r.value(&next)).Elem(), reflect.ValueOf(r.value(&c)).Elem()
+		if !reflect.DeepEqual(current.Interface(), reloaded.Interface()) {
+			current.Set(reloaded)
+			changes.Applied = append(changes.Applied, r.key)
+		}
+	}
+	changes.RestartRequired = !reflect.DeepEqual(next, c)
+	config = next
+	loadedAT = time.Now()
+	setLogLevel(next.LogLevel)
+	return changes, nil
+}
+
+// ReloadOnSignal reloads the config file at the given path every time the
+// process receives a SIGHUP.
+func ReloadOnSignal(path string) {
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGHUP)
+	go func() {
+		for range sigch {
+			changes, err := Reload(path)
+			if err != nil {
+				slog.Error("failed to reload the config", "err", err, "path", path)
+				continue
+			}
+			slog.Info("reloaded the config", "path", path, "changed", changes.Applied)
+			if changes.RestartRequired {
+				slog.Warn("the config has changes that only apply after a restart", "path", path)
+			}
+		}
+	}()
+}
+
+// Effective is the config a server runs with, without its secrets.
+type Effective struct {
+	Config   Config    `json:"config"`
+	LoadedAT time.Time `json:"loaded_at"`
+	// Settings that are changed by a reload, the others need a restart.
+	Reloadable []string `json:"reloadable"`
+}
+
+// GetEffective returns the config the server runs with, its secrets are
+// redacted.
+func GetEffective() Effective {
+	mu.RLock()
+	defer mu.RUnlock()
+	c := config
+	redact(&c.APIToken)
+	redact(&c.Storage.Password)
+	redact(&c.Encryption.MasterKey)
+	c.Encryption.PreviousMasterKeys = make([]string, len(config.Encryption.PreviousMasterKeys))
+	for i, key := range config.Encryption.PreviousMasterKeys {
+		c.Encryption.PreviousMasterKeys[i] = key
+		redact(&c.Encryption.PreviousMasterKeys[i])
+	}
+	redact(&c.Export.SecretKey)
+	redact(&c.UsageExport.RemoteWritePassword)
+	redact(&c.UsageExport.WebhookSecret)
+	redact(&c.Async.Secret)
+	c.APIKeys = make([]APIKey, len(config.APIKeys))
+	for i, key := range config.APIKeys {
+		c.APIKeys[i] = key
+		redact(&c.APIKeys[i].Key)
+	}
+	effective := Effective{Config: c, LoadedAT: loadedAT}
+	for _, r := range reloadable {
+		effective.Reloadable = append(effective.Reloadable, r.key)
+	}
+	return effective
+}
+
+func redact(secret *string) {
+	if len(*secret) > 0 {
+		*secret = "REDACTED"
+	}
+}
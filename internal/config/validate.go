@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validate returns an error listing all the settings of the config that are
+// missing or invalid.
+func (c Config) Validate() error {
+	v := &validator{}
+	v.address("httpAPIAddr", c.HTTPAPIAddr)
+	v.address("httpIngressAddr", c.HTTPIngressAddr)
+	if len(c.StorageDriver) > 0 {
+		v.oneOf("storageDriver", c.StorageDriver, "postgres")
+	}
+	if c.Authorization && len(c.APIToken) == 0 && len(c.APIKeys) == 0 {
+		v.fail("apiToken", "is required when the authorization is enabled unless [[apiKeys]] are configured")
+	}
+	if len(c.LogLevel) > 0 {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			v.fail("logLevel", fmt.Sprintf("must be one of debug, info, warn, error, got %q", c.LogLevel))
+		}
+	}
+	for i, key := range c.APIKeys {
+		prefix := fmt.Sprintf("apiKeys[%d]", i)
+		v.required(prefix+".key", key.Key)
+		v.oneOf(prefix+".role", key.Role, "read-only", "deployer", "admin")
+	}
+
+	v.required("storage.user", c.Storage.User)
+	v.required("storage.name", c.Storage.Name)
+	v.required("storage.host", c.Storage.Host)
+	v.required("storage.port", c.Storage.Port)
+	v.duration("storage.slowThreshold", c.Storage.SlowThreshold)
+	v.duration("storage.cacheTTL", c.Storage.CacheTTL)
+
+	if len(c.Cluster.Activation) > 0 {
+		v.oneOf("cluster.activation", c.Cluster.Activation, "region", "random", "load")
+	}
+	if len(c.Cluster.IngressListenAddr) > 0 {
+		v.address("cluster.ingressListenAddr", c.Cluster.IngressListenAddr)
+	}
+	if len(c.Cluster.RuntimeListenAddr) > 0 {
+		v.address("cluster.runtimeListenAddr", c.Cluster.RuntimeListenAddr)
+	}
+	for i, member := range c.Cluster.SelfManaged.Members {
+		id, addr, ok := strings.Cut(member, "@")
+		if _, _, err := net.SplitHostPort(addr); !ok || len(id) == 0 || err != nil {
+			v.fail(fmt.Sprintf("cluster.selfManaged.members[%d]", i), fmt.Sprintf("must be an id@host:port member, got %q", member))
+		}
+	}
+	switch c.Cluster.Provider {
+	case "", "selfmanaged":
+	case "kubernetes":
+		v.required("cluster.kubernetes.service", c.Cluster.Kubernetes.Service)
+		v.required("cluster.kubernetes.port", c.Cluster.Kubernetes.Port)
+	case "consul":
+		v.url("cluster.consul.address", c.Cluster.Consul.Address, "http", "https")
+		v.required("cluster.consul.service", c.Cluster.Consul.Service)
+	default:
+		v.oneOf("cluster.provider", c.Cluster.Provider, "selfmanaged", "kubernetes", "consul")
+	}
+
+	v.notNegative("ingress.queueSize", c.Ingress.QueueSize)
+	v.duration("ingress.queueTimeout", c.Ingress.QueueTimeout)
+	v.notNegative("ingress.responseCacheSize", c.Ingress.ResponseCacheSize)
+	v.duration("ingress.readHeaderTimeout", c.Ingress.ReadHeaderTimeout)
+	v.duration("ingress.readTimeout", c.Ingress.ReadTimeout)
+	v.duration("ingress.writeTimeout", c.Ingress.WriteTimeout)
+	v.duration("ingress.idleTimeout", c.Ingress.IdleTimeout)
+	v.notNegative("ingress.maxHeaderBytes", c.Ingress.MaxHeaderBytes)
+	v.notNegative("ingress.maxConcurrentStreams", c.Ingress.MaxConcurrentStreams)
+	if _, err := ParseTrustedProxies(c.Ingress.TrustedProxies); err != nil {
+		v.fail("ingress.trustedProxies", err.Error())
+	}
+	v.duration("limits.timeout", c.Limits.Timeout)
+	v.notNegative("limits.maxResponseSize", c.Limits.MaxResponseSize)
+	v.notNegative("limits.maxBlobSize", c.Limits.MaxBlobSize)
+	v.notNegative("limits.scratchSize", int(c.Limits.ScratchSize))
+	v.notNegative("limits.cacheSize", c.Limits.CacheSize)
+	v.notNegative("limits.cacheQuota", c.Limits.CacheQuota)
+	v.duration("health.interval", c.Health.Interval)
+	v.duration("health.timeout", c.Health.Timeout)
+	v.notNegative("health.failureThreshold", c.Health.FailureThreshold)
+	v.notNegative("probes.failureThreshold", c.Probes.FailureThreshold)
+	v.notNegative("async.maxAttempts", c.Async.MaxAttempts)
+	v.notNegative("async.maxDepth", c.Async.MaxDepth)
+	v.duration("async.scheduleInterval", c.Async.ScheduleInterval)
+	v.duration("async.backoff", c.Async.Backoff)
+	v.duration("async.maxBackoff", c.Async.MaxBackoff)
+	if len(c.Async.Secret) == 0 {
+		v.fail("async.secret", "is required, the api servers, the runtimes and the ingresses sign and verify the invocations of the platform with it")
+	}
+	v.duration("cors.maxAge", c.CORS.MaxAge)
+	v.duration("workflows.wakeInterval", c.Workflows.WakeInterval)
+	v.notNegative("workflows.maxSteps", c.Workflows.MaxSteps)
+	v.duration("deployments.gcInterval", c.Deployments.GCInterval)
+	// The usage export rolls up the last hour from the request metrics.
+	if r := c.Metrics.Retention; r > 0 && time.Duration(r) < 2*time.Hour {
+		v.fail("metrics.retention", fmt.Sprintf("must be 0 or at least 2h, got %s", time.Duration(r)))
+	}
+	v.duration("metrics.minuteRetention", c.Metrics.MinuteRetention)
+	v.duration("metrics.hourRetention", c.Metrics.HourRetention)
+
+	if key := c.Encryption.MasterKey; len(key) > 0 {
+		if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 32 {
+			v.fail("encryption.masterKey", "must be a base64 encoded 32 byte key")
+		}
+	}
+	if len(c.Encryption.PreviousMasterKeys) > 0 && len(c.Encryption.MasterKey) == 0 {
+		v.fail("encryption.previousMasterKeys", "requires a masterKey the previous keys were replaced by")
+	}
+	for i, key := range c.Encryption.PreviousMasterKeys {
+		if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 32 {
+			v.fail(fmt.Sprintf("encryption.previousMasterKeys[%d]", i), "must be a base64 encoded 32 byte key")
+		}
+	}
+	if len(c.Export.URL) > 0 {
+		v.url("export.url", c.Export.URL, "file", "s3", "gs")
+		v.duration("export.interval", c.Export.Interval)
+		v.duration("export.retention", c.Export.Retention)
+	}
+	if c.Build.Enabled {
+		v.duration("build.timeout", c.Build.Timeout)
+		if c.Build.Concurrency < 1 {
+			v.fail("build.concurrency", "must be at least 1 when the builds are enabled")
+		}
+	}
+	for i, key := range c.Signing.PublicKeys {
+		if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 32 {
+			v.fail(fmt.Sprintf("signing.publicKeys[%d]", i), "must be a base64 encoded ed25519 public key")
+		}
+	}
+	v.clock("policy.changeWindow.start", c.Policy.ChangeWindow.Start)
+	v.clock("policy.changeWindow.end", c.Policy.ChangeWindow.End)
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems of the config.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(key, msg string) {
+	if name := envName(key); len(name) > 0 {
+		msg += fmt.Sprintf(" (or set %s)", name)
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s %s", key, msg))
+}
+
+func (v *validator) required(key, value string) {
+	if len(value) == 0 {
+		v.fail(key, "is required")
+	}
+}
+
+func (v *validator) address(key, value string) {
+	if len(value) == 0 {
+		v.fail(key, "is required, like 127.0.0.1:3000")
+		return
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		v.fail(key, fmt.Sprintf("must be a host:port address, got %q", value))
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(key, fmt.Sprintf("must be one of %s, got %q", strings.Join(allowed, ", "), value))
+}
+
+func (v *validator) url(key, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil || len(u.Scheme) == 0 {
+		v.fail(key, fmt.Sprintf("must be a URL, got %q", value))
+		return
+	}
+	v.oneOf(key+" scheme", u.Scheme, schemes...)
+}
+
+func (v *validator) notNegative(key string, value int) {
+	if value < 0 {
+		v.fail(key, fmt.Sprintf("cannot be negative, got %d", value))
+	}
+}
+
+func (v *validator) duration(key string, d Duration) {
+	if d < 0 {
+		v.fail(key, fmt.Sprintf("cannot be negative, got %s", time.Duration(d)))
+	}
+}
+
+func (v *validator) clock(key, value string) {
+	if _, err := time.Parse("15:04", value); len(value) > 0 && err != nil {
+		v.fail(key, fmt.Sprintf("must be a time like 09:00, got %q", value))
+	}
+}
@@ -0,0 +1,199 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+)
+
+const (
+	keySize = 32
+
+	// prefix marks the values that are encrypted with a data key, the format
+	// of an encrypted value is "enc:v1:<key version>:<base64 nonce+ciphertext>".
+	prefix = "enc:v1:"
+)
+
+var ErrUnknownDataKey = errors.New("value is encrypted with an unknown data key")
+
+// Keyring holds the master key of the installation that wraps the data keys
+// of the endpoints (envelope encryption). Values are only ever encrypted
+// with the data key of their own endpoint, so leaking the data key of one
+// endpoint does not expose the data of others.
+type Keyring struct {
+	master cipher.AEAD
+	// Master keys the data keys were wrapped with before the master key
+	// was rotated, they only unwrap the data keys that were not re-wrapped
+	// yet.
+	previous []cipher.AEAD
+}
+
+// NewKeyring returns a new keyring given the base64 encoded 32 byte master
+// key and the master keys it replaced, if any.
+func NewKeyring(masterKey string, previousKeys ...string) (*Keyring, error) {
+	master, err := newMasterAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{master: master}
+	for _, key := range previousKeys {
+		aead, err := newMasterAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("previous %w", err)
+		}
+		k.previous = append(k.previous, aead)
+	}
+	return k, nil
+}
+
+func newMasterAEAD(masterKey string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key should be %d bytes got %d", keySize, len(key))
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateDataKey returns a new random data key with the given version,
+// wrapped with the master key.
+func (k *Keyring) GenerateDataKey(version int) (types.DataKey, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return types.DataKey{}, err
+	}
+	wrapped, err := seal(k.master, key)
+	if err != nil {
+		return types.DataKey{}, err
+	}
+	return types.DataKey{
+		Version:   version,
+		Key:       wrapped,
+		CreatedAT: time.Now(),
+	}, nil
+}
+
+func (k *Keyring) unwrap(dk types.DataKey) (cipher.AEAD, error) {
+	key, _, err := k.unwrapKey(dk)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// unwrapKey returns the plain data key and true if it is wrapped with the
+// current master key.
+func (k *Keyring) unwrapKey(dk types.DataKey) ([]byte, bool, error) {
+	key, err := open(k.master, dk.Key)
+	if err == nil {
+		return key, true, nil
+	}
+	for _, previous := range k.previous {
+		if key, err := open(previous, dk.Key); err == nil {
+			return key, false, nil
+		}
+	}
+	return nil, false, fmt.Errorf("failed to unwrap data key version %d: %w", dk.Version, err)
+}
+
+// Rewrap returns the data key wrapped with the current master key and true
+// if it was wrapped with a previous master key. The values encrypted with
+// the data key stay readable, only its wrapping changes.
+func (k *Keyring) Rewrap(dk types.DataKey) (types.DataKey, bool, error) {
+	key, current, err := k.unwrapKey(dk)
+	if err != nil || current {
+		return dk, false, err
+	}
+	wrapped, err := seal(k.master, key)
+	if err != nil {
+		return dk, false, err
+	}
+	dk.Key = wrapped
+	return dk, true, nil
+}
+
+// Encrypt encrypts the value with the given data key.
+func (k *Keyring) Encrypt(dk types.DataKey, value string) (string, error) {
+	aead, err := k.unwrap(dk)
+	if err != nil {
+		return "", err
+	}
+	b, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return prefix + strconv.Itoa(dk.Version) + ":" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Decrypt decrypts the value with the matching data key of the given keys
+// and returns the version of the data key it was encrypted with. Values that
+// are not encrypted are returned as is with version 0.
+func (k *Keyring) Decrypt(keys []types.DataKey, value string) (string, int, error) {
+	if !IsEncrypted(value) {
+		return value, 0, nil
+	}
+	version, data, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed encrypted value")
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	dk, ok := types.FindDataKey(keys, v)
+	if !ok {
+		return "", 0, ErrUnknownDataKey
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	aead, err := k.unwrap(dk)
+	if err != nil {
+		return "", 0, err
+	}
+	plain, err := open(aead, b)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(plain), v, nil
+}
+
+// IsEncrypted returns true if the value is encrypted with a data key.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, b []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,144 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/types"
+)
+
+func newTestKeyring(t *testing.T) *Keyring {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := NewKeyring(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	if _, err := NewKeyring("not base64!"); err == nil {
+		t.Error("expected an error for a malformed master key")
+	}
+	if _, err := NewKeyring(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected an error for a short master key")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := newTestKeyring(t)
+	v1, err := keyring.GenerateDataKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := keyring.GenerateDataKey(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []types.DataKey{v1, v2}
+
+	value, err := keyring.Encrypt(v1, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(value) || strings.Contains(value, "s3cret") {
+		t.Fatalf("expected an encrypted value got %s", value)
+	}
+	plain, version, err := keyring.Decrypt(keys, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "s3cret" || version != 1 {
+		t.Errorf("expected s3cret with version 1 got %s with version %d", plain, version)
+	}
+
+	plain, version, err = keyring.Decrypt(keys, "plaintext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "plaintext" || version != 0 {
+		t.Errorf("expected plaintext values to be returned as is")
+	}
+
+	if _, _, err := keyring.Decrypt([]types.DataKey{v2}, value); err != ErrUnknownDataKey {
+		t.Errorf("expected %v got %v", ErrUnknownDataKey, err)
+	}
+}
+
+func TestDecryptWithOtherKeyring(t *testing.T) {
+	keyring := newTestKeyring(t)
+	dk, err := keyring.GenerateDataKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := keyring.Encrypt(dk, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := newTestKeyring(t).Decrypt([]types.DataKey{dk}, value); err == nil {
+		t.Error("expected the data key not to unwrap with another master key")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	newKey := func() string {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(key)
+	}
+	oldKey, newMasterKey := newKey(), newKey()
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk, err := old.GenerateDataKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := old.Encrypt(dk, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeyring(newMasterKey, "short"); err == nil {
+		t.Error("expected an error for a malformed previous master key")
+	}
+
+	// The rotated keyring reads the data keys of the previous master key
+	// until they are re-wrapped.
+	rotated, err := NewKeyring(newMasterKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _, err := rotated.Decrypt([]types.DataKey{dk}, value); err != nil || plain != "s3cret" {
+		t.Fatalf("expected s3cret got %q (%v)", plain, err)
+	}
+	rewrapped, changed, err := rotated.Rewrap(dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || rewrapped.Version != dk.Version {
+		t.Fatalf("expected data key version %d to be re-wrapped", dk.Version)
+	}
+	if _, changed, _ := rotated.Rewrap(rewrapped); changed {
+		t.Error("expected a re-wrapped data key to be left as it is")
+	}
+
+	// Once re-wrapped the previous master key is no longer needed.
+	current, err := NewKeyring(newMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _, err := current.Decrypt([]types.DataKey{rewrapped}, value); err != nil || plain != "s3cret" {
+		t.Fatalf("expected s3cret got %q (%v)", plain, err)
+	}
+	if _, _, err := old.Decrypt([]types.DataKey{rewrapped}, value); err == nil {
+		t.Error("expected the re-wrapped data key not to unwrap with the previous master key")
+	}
+}
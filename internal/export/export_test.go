@@ -0,0 +1,228 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningKey(t *testing.T) {
+	// Example of the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	require.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestNewSink(t *testing.T) {
+	sink, err := NewSink(config.Export{})
+	require.Nil(t, err)
+	require.Nil(t, sink)
+
+	sink, err = NewSink(config.Export{URL: "gs://analytics/raptor"})
+	require.Nil(t, err)
+	s3 := sink.(*S3Sink)
+	require.Equal(t, "https://storage.googleapis.com", s3.endpoint)
+	require.Equal(t, "analytics", s3.bucket)
+	require.Equal(t, "raptor", s3.prefix)
+
+	_, err = NewSink(config.Export{URL: "ftp://analytics"})
+	require.NotNil(t, err)
+}
+
+func TestS3SinkPut(t *testing.T) {
+	var (
+		path string
+		auth string
+		body []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sink := NewS3Sink(server.URL, "bucket", "raptor", config.Export{AccessKey: "access", SecretKey: "secret"})
+	require.Nil(t, sink.Put(context.Background(), "logs/date=2026-10-15/a.jsonl.gz", []byte("data")))
+	require.Equal(t, "/bucket/raptor/logs/date%3D2026-10-15/a.jsonl.gz", path)
+	require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/"))
+	require.Equal(t, "data", string(body))
+}
+
+func TestExporter(t *testing.T) {
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("export", "go", nil)
+		dir      = t.TempDir()
+		now      = time.Date(2026, 10, 15, 11, 30, 0, 0, time.UTC)
+	)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	for _, at := range []time.Time{now.Add(-time.Hour * 3), now.Add(-time.Hour), now} {
+		require.Nil(t, store.CreateRequestMetric(&types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			StatusCode: http.StatusOK,
+			CreatedAT:  at,
+		}))
+	}
+
+	e := NewExporter(store, store, DirSink(dir), time.Hour, time.Hour*2)
+	e.watermark = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	require.Nil(t, e.export(context.Background(), now))
+	require.Equal(t, time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC), e.watermark)
+
+	// Only the windows with metrics are written.
+	files, err := filepath.Glob(filepath.Join(dir, "request_metrics", "*", "*", "*"))
+	require.Nil(t, err)
+	require.Len(t, files, 2)
+	b, err := os.ReadFile(filepath.Join(dir, Key(DatasetRequestMetrics, now.Add(-time.Hour*3), "20261015T080000Z")))
+	require.Nil(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	require.Nil(t, err)
+	var metric types.RequestMetric
+	require.Nil(t, json.NewDecoder(gz).Decode(&metric))
+	require.Equal(t, endpoint.ID, metric.EndpointID)
+
+	// Metrics older than the retention are handed off to the export.
+	metrics, err := store.GetRequestMetrics(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, metrics, 2)
+}
+
+type failingTarget struct {
+	err   error
+	hours []time.Time
+}
+
+func (t *failingTarget) Push(ctx context.Context, hour time.Time, usages []types.HourlyUsage) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.hours = append(t.hours, hour)
+	return nil
+}
+
+func TestUsageExporter(t *testing.T) {
+	var (
+		store    = storage.NewMemoryStore()
+		endpoint = types.NewEndpoint("usage", "go", nil)
+		dir      = t.TempDir()
+		now      = time.Date(2026, 10, 15, 11, 30, 0, 0, time.UTC)
+		delivery usageDelivery
+		header   http.Header
+	)
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	for _, at := range []time.Time{now.Add(-time.Hour * 3), now.Add(-time.Hour * 3), now.Add(-time.Hour), now} {
+		require.Nil(t, store.CreateRequestMetric(&types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Duration:   time.Second,
+			BytesIn:    10,
+			BytesOut:   100,
+			CreatedAT:  at,
+		}))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&delivery))
+	}))
+	defer server.Close()
+
+	failing := &failingTarget{err: io.ErrUnexpectedEOF}
+	e := NewUsageExporter(store, store, []UsageTarget{NewCSVTarget(DirSink(dir)), NewWebhookTarget(server.URL, "secret"), failing})
+	for _, target := range e.targets {
+		target.watermark = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	}
+	require.NotNil(t, e.export(context.Background(), now))
+	require.Equal(t, time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC), e.targets[0].watermark)
+	require.Equal(t, time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC), e.targets[2].watermark)
+
+	b, err := os.ReadFile(filepath.Join(dir, UsageKey(time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC))))
+	require.Nil(t, err)
+	require.Equal(t, "hour,endpoint_id,project,invocations,duration_seconds,gb_seconds,bytes_in,bytes_out\n"+
+		"2026-10-15T08:00:00Z,"+endpoint.ID.String()+",payments,2,2,0,20,200\n", string(b))
+
+	// The last delivery is the usage of the last hour.
+	require.Equal(t, time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC), delivery.Hour)
+	require.Len(t, delivery.Usage, 1)
+	require.Equal(t, int64(1), delivery.Usage[0].Invocations)
+	require.Equal(t, "usage-20261015T10", header.Get("Raptor-Delivery"))
+	require.True(t, strings.HasPrefix(header.Get("Raptor-Signature"), "t="))
+
+	// The failing target catches up without pushing to the others again.
+	failing.err = nil
+	delivery = usageDelivery{}
+	require.Nil(t, e.export(context.Background(), now))
+	require.Equal(t, []time.Time{now.Add(-time.Hour * 3).Truncate(time.Hour), now.Add(-time.Hour).Truncate(time.Hour)}, failing.hours)
+	require.True(t, delivery.Hour.IsZero())
+}
+
+func TestRemoteWriteTarget(t *testing.T) {
+	var (
+		body     []byte
+		header   http.Header
+		hour     = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+		endpoint = uuid.New()
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	usages := []types.HourlyUsage{{Hour: hour, EndpointID: endpoint, Project: "payments", Invocations: 3}}
+	target := NewRemoteWriteTarget(server.URL, "user", "pass")
+	require.Nil(t, target.Push(context.Background(), hour, usages))
+	require.Equal(t, "snappy", header.Get("Content-Encoding"))
+	user, pass, ok := (&http.Request{Header: header}).BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user:pass", user+":"+pass)
+
+	// The body is the write request stored as snappy literals.
+	want := EncodeWriteRequest(hour.Add(time.Hour), usages)
+	require.Equal(t, want, snappyDecodeLiterals(t, body))
+	require.Contains(t, string(want), "raptor_usage_invocations")
+	require.Contains(t, string(want), endpoint.String())
+
+	long := bytes.Repeat([]byte("raptor"), maxSnappyLiteral/4)
+	require.Equal(t, long, snappyDecodeLiterals(t, snappyEncode(long)))
+}
+
+// snappyDecodeLiterals decodes a snappy block made of literals only.
+func snappyDecodeLiterals(t *testing.T, b []byte) []byte {
+	n, read := binary.Uvarint(b)
+	require.Greater(t, read, 0)
+	b = b[read:]
+	var data []byte
+	for len(b) > 0 {
+		tag := b[0]
+		require.Equal(t, byte(0), tag&3)
+		length := int(tag>>2) + 1
+		b = b[1:]
+		if tag>>2 == 61 {
+			length = (int(b[0]) | int(b[1])<<8) + 1
+			b = b[2:]
+		}
+		data = append(data, b[:length]...)
+		b = b[length:]
+	}
+	require.Equal(t, int(n), len(data))
+	return data
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+const (
+	DatasetRequestMetrics = "request_metrics"
+	DatasetLogs           = "logs"
+)
+
+// Exporter periodically exports the request metrics of all the endpoints to
+// a sink, one file per window of the export interval. Exporting a window
+// again replaces its file, which makes the export idempotent.
+type Exporter struct {
+	store       storage.Store
+	metricStore storage.MetricStore
+	sink        Sink
+	interval    time.Duration
+	// Exported metrics older than the retention are deleted from the metric
+	// store, 0 keeps them.
+	retention time.Duration
+	// End of the last exported window.
+	watermark time.Time
+}
+
+// NewExporter returns a new Exporter. The first export covers the windows
+// that are still within the retention, or the last window when the metrics
+// are kept.
+func NewExporter(store storage.Store, metricStore storage.MetricStore, sink Sink, interval, retention time.Duration) *Exporter {
+	backfill := interval
+	if retention > interval {
+		backfill = retention
+	}
+	return &Exporter{
+		store:       store,
+		metricStore: metricStore,
+		sink:        sink,
+		interval:    interval,
+		retention:   retention,
+		watermark:   time.Now().Truncate(interval).Add(-backfill),
+	}
+}
+
+// Run exports the windows as they end until the context is done.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(e.interval, time.Minute))
+	defer ticker.Stop()
+	for {
+		if err := e.export(ctx, time.Now()); err != nil {
+			slog.Warn("failed to export request metrics", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// export exports all the windows that ended before now and were not
+// exported yet. Windows are retried on the next run when they fail.
+func (e *Exporter) export(ctx context.Context, now time.Time) error {
+	end := now.Truncate(e.interval)
+	for e.watermark.Before(end) {
+		to := e.watermark.Add(e.interval)
+		if err := e.exportWindow(ctx, e.watermark, to); err != nil {
+			return err
+		}
+		e.watermark = to
+	}
+	if e.retention == 0 {
+		return nil
+	}
+	// Only the metrics that were exported are handed off.
+	before := now.Add(-e.retention)
+	if e.watermark.Before(before) {
+		before = e.watermark
+	}
+	deleted, err := e.metricStore.DeleteRequestMetrics(before)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.Info("deleted exported request metrics", "count", deleted, "before", before)
+	}
+	return nil
+}
+
+func (e *Exporter) exportWindow(ctx context.Context, from, to time.Time) error {
+	endpoints, err := e.store.GetEndpoints()
+	if err != nil {
+		return err
+	}
+	var records []types.RequestMetric
+	for _, endpoint := range endpoints {
+		metrics, err := e.metricStore.GetRequestMetrics(endpoint.ID)
+		if err != nil {
+			return err
+		}
+		for _, metric := range metrics {
+			if !metric.CreatedAT.Before(from) && metric.CreatedAT.Before(to) {
+				records = append(records, metric)
+			}
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	b, err := EncodeJSONL(records)
+	if err != nil {
+		return err
+	}
+	return e.sink.Put(ctx, Key(DatasetRequestMetrics, from, from.UTC().Format("20060102T150405Z")), b)
+}
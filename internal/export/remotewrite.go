@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteTarget pushes the usage of every hour to a Prometheus
+// remote-write endpoint, like Prometheus, Mimir or Thanos. Every usage
+// counter is a series labeled with the endpoint and its project, its sample
+// of an hour is stamped with the end of the hour.
+type RemoteWriteTarget struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+}
+
+// NewRemoteWriteTarget returns a new RemoteWriteTarget, the requests are
+// not authenticated when the username is empty.
+func NewRemoteWriteTarget(url, username, password string) *RemoteWriteTarget {
+	return &RemoteWriteTarget{
+		client:   &http.Client{Timeout: usageTimeout},
+		url:      url,
+		username: username,
+		password: password,
+	}
+}
+
+func (t *RemoteWriteTarget) Push(ctx context.Context, hour time.Time, usages []types.HourlyUsage) error {
+	body := snappyEncode(EncodeWriteRequest(hour.Add(time.Hour), usages))
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if len(t.username) > 0 {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write responded with status code %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// EncodeWriteRequest encodes the usage as the protobuf of a remote-write
+// WriteRequest with one sample per series at the given time.
+func EncodeWriteRequest(at time.Time, usages []types.HourlyUsage) []byte {
+	var b []byte
+	for _, usage := range usages {
+		samples := []struct {
+			name  string
+			value float64
+		}{
+			{"raptor_usage_invocations", float64(usage.Invocations)},
+			{"raptor_usage_duration_seconds", usage.Duration.Seconds()},
+			{"raptor_usage_gb_seconds", usage.GBSeconds},
+			{"raptor_usage_bytes_in", float64(usage.BytesIn)},
+			{"raptor_usage_bytes_out", float64(usage.BytesOut)},
+		}
+		for _, sample := range samples {
+			var series []byte
+			// The labels are sorted by name.
+			series = appendLabel(series, "__name__", sample.name)
+			series = appendLabel(series, "endpoint_id", usage.EndpointID.String())
+			series = appendLabel(series, "project", usage.Project)
+
+			var s []byte
+			s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+			s = protowire.AppendFixed64(s, math.Float64bits(sample.value))
+			s = protowire.AppendTag(s, 2, protowire.VarintType)
+			s = protowire.AppendVarint(s, uint64(at.UnixMilli()))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, s)
+
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendBytes(b, series)
+		}
+	}
+	return b
+}
+
+func appendLabel(b []byte, name, value string) []byte {
+	var label []byte
+	label = protowire.AppendTag(label, 1, protowire.BytesType)
+	label = protowire.AppendString(label, name)
+	label = protowire.AppendTag(label, 2, protowire.BytesType)
+	label = protowire.AppendString(label, value)
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, label)
+}
+
+// maxSnappyLiteral is the maximum length of a literal that snappyEncode
+// emits.
+const maxSnappyLiteral = 1 << 16
+
+// snappyEncode encodes the data in the snappy block format remote-write
+// requires. The data is stored as literals without compression, which every
+// snappy decoder accepts, the requests of an hour are small.
+func snappyEncode(data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(data)))
+	for len(data) > 0 {
+		n := min(len(data), maxSnappyLiteral)
+		// Literals longer than 60 bytes store their length minus one in
+		// the bytes after the tag, 61<<2 tags a 2 byte length.
+		if n <= 60 {
+			b = append(b, byte(n-1)<<2)
+		} else {
+			b = append(b, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return b
+}
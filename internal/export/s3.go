@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+)
+
+// S3Sink stores the exported files in a bucket of an S3 compatible object
+// storage, like AWS S3, GCS (with HMAC keys) or MinIO. Requests are signed
+// with AWS Signature Version 4.
+type S3Sink struct {
+	client *http.Client
+	// Endpoint of the object storage. When the bucket is empty the endpoint
+	// addresses the bucket itself (virtual hosted style).
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+// NewS3Sink returns a new S3Sink given the endpoint of the object storage,
+// the bucket and the prefix of the keys.
+func NewS3Sink(endpoint, bucket, prefix string, c config.Export) *S3Sink {
+	region := c.Region
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+	return &S3Sink{
+		client:    &http.Client{Timeout: time.Minute},
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: c.AccessKey,
+		secretKey: c.SecretKey,
+	}
+}
+
+func (s *S3Sink) Put(ctx context.Context, key string, b []byte) error {
+	objectPath := "/" + path.Join(s.bucket, s.prefix, key)
+	req, err := http.NewRequestWithContext(ctx, "PUT", s.endpoint+uriEncode(objectPath), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	s.sign(req, b, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("object storage responded with status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// sign signs the request with AWS Signature Version 4.
+func (s *S3Sink) sign(req *http.Request, body []byte, now time.Time) {
+	var (
+		amzDate     = now.UTC().Format("20060102T150405Z")
+		date        = amzDate[:8]
+		payloadHash = sha256Hex(body)
+		scope       = fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(signingKey(s.secretKey, date, s.region, "s3"), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes everything but the unreserved characters and the
+// slashes of a path, as the canonical request of Signature Version 4
+// requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+)
+
+// Sink stores the exported files.
+type Sink interface {
+	// Put stores the file under the given slash separated key, an existing
+	// file with the same key is replaced.
+	Put(ctx context.Context, key string, b []byte) error
+}
+
+// NewSink returns the sink of the given export configuration, nil when the
+// export is disabled.
+func NewSink(c config.Export) (Sink, error) {
+	if len(c.URL) == 0 {
+		return nil, nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export url: %s", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		return DirSink(u.Path), nil
+	case "s3":
+		endpoint := c.Endpoint
+		if len(endpoint) == 0 {
+			return NewS3Sink(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.Host, c.Region), "", prefix, c), nil
+		}
+		return NewS3Sink(endpoint, u.Host, prefix, c), nil
+	case "gs":
+		endpoint := c.Endpoint
+		if len(endpoint) == 0 {
+			endpoint = "https://storage.googleapis.com"
+		}
+		return NewS3Sink(endpoint, u.Host, prefix, c), nil
+	default:
+		return nil, fmt.Errorf("unsupported export url scheme: %s", u.Scheme)
+	}
+}
+
+// DirSink stores the exported files in a local directory, like a volume
+// that is synced to object storage.
+type DirSink string
+
+func (d DirSink) Put(ctx context.Context, key string, b []byte) error {
+	name := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	// Readers never see a partially written file.
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Key returns the key of an exported file of the given dataset, partitioned
+// by the date and hour of the start of the window it covers (Hive style).
+func Key(dataset string, start time.Time, name string) string {
+	start = start.UTC()
+	return path.Join(
+		dataset,
+		"date="+start.Format("2006-01-02"),
+		"hour="+start.Format("15"),
+		name+".jsonl.gz",
+	)
+}
+
+// EncodeJSONL encodes the records as gzip compressed JSON lines.
+func EncodeJSONL[T any](records []T) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	enc := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DefaultInterval is the export interval when none is configured.
+const DefaultInterval = time.Hour
+
+// Interval returns the export interval of the given configuration.
+func Interval(c config.Export) time.Duration {
+	if c.Interval <= 0 {
+		return DefaultInterval
+	}
+	return time.Duration(c.Interval)
+}
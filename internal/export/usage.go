@@ -0,0 +1,266 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/notify"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+const DatasetUsage = "usage"
+
+const usageTimeout = 30 * time.Second
+
+// UsageTarget receives the hourly usage of the endpoints.
+type UsageTarget interface {
+	// Push delivers the usage of the hour starting at the given time.
+	// Pushing an hour again must not count its usage twice.
+	Push(ctx context.Context, hour time.Time, usages []types.HourlyUsage) error
+}
+
+// NewUsageTargets returns the targets of the given usage export
+// configuration. The CSV files are written to the sink of the export, which
+// is required for them.
+func NewUsageTargets(c config.UsageExport, sink Sink) ([]UsageTarget, error) {
+	var targets []UsageTarget
+	if c.CSV {
+		if sink == nil {
+			return nil, fmt.Errorf("the usage export to CSV requires an export url")
+		}
+		targets = append(targets, NewCSVTarget(sink))
+	}
+	if len(c.RemoteWriteURL) > 0 {
+		targets = append(targets, NewRemoteWriteTarget(c.RemoteWriteURL, c.RemoteWriteUsername, c.RemoteWritePassword))
+	}
+	if len(c.WebhookURL) > 0 {
+		targets = append(targets, NewWebhookTarget(c.WebhookURL, c.WebhookSecret))
+	}
+	return targets, nil
+}
+
+// UsageExporter rolls the request metrics up into the hourly usage of the
+// endpoints and pushes every hour to the targets once it ended. Hours without
+// invocations are left out.
+type UsageExporter struct {
+	store       storage.Store
+	metricStore storage.MetricStore
+	targets     []*usageTarget
+}
+
+// usageTarget is a target with the end of the last hour pushed to it, a
+// failing target is retried without pushing the hours to the others again.
+type usageTarget struct {
+	UsageTarget
+	watermark time.Time
+}
+
+// NewUsageExporter returns a new UsageExporter, the first export covers the
+// last hour.
+func NewUsageExporter(store storage.Store, metricStore storage.MetricStore, targets []UsageTarget) *UsageExporter {
+	watermark := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	e := &UsageExporter{
+		store:       store,
+		metricStore: metricStore,
+	}
+	for _, target := range targets {
+		e.targets = append(e.targets, &usageTarget{UsageTarget: target, watermark: watermark})
+	}
+	return e
+}
+
+// Run exports the hours as they end until the context is done.
+func (e *UsageExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := e.export(ctx, time.Now()); err != nil {
+			slog.Warn("failed to export usage", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// export pushes all the hours that ended before now to the targets that did
+// not receive them yet. The hours are retried on the next run when a target
+// fails.
+func (e *UsageExporter) export(ctx context.Context, now time.Time) error {
+	end := now.Truncate(time.Hour)
+	start := end
+	for _, target := range e.targets {
+		if target.watermark.Before(start) {
+			start = target.watermark
+		}
+	}
+	var errs []error
+	failed := make(map[*usageTarget]bool)
+	for hour := start; hour.Before(end); hour = hour.Add(time.Hour) {
+		var pending []*usageTarget
+		for _, target := range e.targets {
+			if target.watermark.Equal(hour) && !failed[target] {
+				pending = append(pending, target)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		usages, err := e.rollup(hour)
+		if err != nil {
+			return err
+		}
+		for _, target := range pending {
+			if len(usages) > 0 {
+				if err := target.Push(ctx, hour, usages); err != nil {
+					errs = append(errs, err)
+					failed[target] = true
+					continue
+				}
+			}
+			target.watermark = hour.Add(time.Hour)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollup returns the usage of all the endpoints in the hour starting at the
+// given time.
+func (e *UsageExporter) rollup(hour time.Time) ([]types.HourlyUsage, error) {
+	endpoints, err := e.store.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	var usages []types.HourlyUsage
+	for _, endpoint := range endpoints {
+		metrics, err := e.metricStore.GetRequestMetrics(endpoint.ID)
+		if err != nil {
+			return nil, err
+		}
+		inHour := metrics[:0]
+		for _, metric := range metrics {
+			if !metric.CreatedAT.Before(hour) && metric.CreatedAT.Before(hour.Add(time.Hour)) {
+				inHour = append(inHour, metric)
+			}
+		}
+		usages = append(usages, types.RollupUsage(endpoint.Owner(), inHour)...)
+	}
+	return usages, nil
+}
+
+// CSVTarget writes the usage of every hour to a CSV file of the export sink,
+// partitioned like the other datasets.
+type CSVTarget struct {
+	sink Sink
+}
+
+// NewCSVTarget returns a new CSVTarget given the sink of the files.
+func NewCSVTarget(sink Sink) CSVTarget {
+	return CSVTarget{sink: sink}
+}
+
+func (t CSVTarget) Push(ctx context.Context, hour time.Time, usages []types.HourlyUsage) error {
+	b, err := EncodeUsageCSV(usages)
+	if err != nil {
+		return err
+	}
+	return t.sink.Put(ctx, UsageKey(hour), b)
+}
+
+// UsageKey returns the key of the CSV file of the usage of the hour starting
+// at the given time.
+func UsageKey(hour time.Time) string {
+	hour = hour.UTC()
+	return path.Join(
+		DatasetUsage,
+		"date="+hour.Format("2006-01-02"),
+		"hour="+hour.Format("15"),
+		hour.Format("20060102T150405Z")+".csv",
+	)
+}
+
+// EncodeUsageCSV encodes the usage as CSV with a header row. Durations are
+// in seconds and sizes in bytes.
+func EncodeUsageCSV(usages []types.HourlyUsage) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	w.Write([]string{"hour", "endpoint_id", "project", "invocations", "duration_seconds", "gb_seconds", "bytes_in", "bytes_out"})
+	for _, usage := range usages {
+		w.Write([]string{
+			usage.Hour.UTC().Format(time.RFC3339),
+			usage.EndpointID.String(),
+			usage.Project,
+			strconv.FormatInt(usage.Invocations, 10),
+			strconv.FormatFloat(usage.Duration.Seconds(), 'f', -1, 64),
+			strconv.FormatFloat(usage.GBSeconds, 'f', -1, 64),
+			strconv.FormatInt(usage.BytesIn, 10),
+			strconv.FormatInt(usage.BytesOut, 10),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// WebhookTarget posts the usage of every hour as JSON to a billing webhook.
+// The deliveries are signed like the webhooks of the platform events and
+// identified by their hour, so the receiver can drop the retries.
+type WebhookTarget struct {
+	client *http.Client
+	url    string
+	secret string
+}
+
+// usageDelivery is the body of a delivery of a WebhookTarget.
+type usageDelivery struct {
+	Hour  time.Time           `json:"hour"`
+	Usage []types.HourlyUsage `json:"usage"`
+}
+
+// NewWebhookTarget returns a new WebhookTarget, the deliveries are not
+// signed when the secret is empty.
+func NewWebhookTarget(url, secret string) *WebhookTarget {
+	return &WebhookTarget{
+		client: &http.Client{Timeout: usageTimeout},
+		url:    url,
+		secret: secret,
+	}
+}
+
+func (t *WebhookTarget) Push(ctx context.Context, hour time.Time, usages []types.HourlyUsage) error {
+	body, err := json.Marshal(usageDelivery{Hour: hour.UTC(), Usage: usages})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(notify.EventHeader, "usage.hourly")
+	req.Header.Set(notify.DeliveryHeader, "usage-"+hour.UTC().Format("20060102T15"))
+	if len(t.secret) > 0 {
+		req.Header.Set(notify.SignatureHeader, notify.Sign(t.secret, time.Now(), body))
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("billing webhook responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package guestcache holds the in-memory cache the guests of a member
+// memoize values in across their warm invocations. Nothing is persisted and
+// the members do not share their caches.
+package guestcache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxKeySize is the maximum size in bytes of a key.
+const MaxKeySize = 1024
+
+var (
+	// ErrTooLarge is returned for the values that exceed the quota of their
+	// endpoint or the size of the cache.
+	ErrTooLarge = errors.New("guestcache: value too large")
+	// ErrInvalidKey is returned for empty keys and keys longer than
+	// MaxKeySize.
+	ErrInvalidKey = errors.New("guestcache: invalid key")
+)
+
+// Cache is an LRU cache bounded by the total size of the keys and values of
+// all the endpoints. The least recently used entries are evicted first, those
+// of the endpoint that exceeds its quota before the others.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	ll       *list.List
+	buckets  map[string]*bucket
+}
+
+// bucket holds the entries of a scope, in least recently used order like
+// the cache.
+type bucket struct {
+	size  int
+	ll    *list.List
+	items map[string]*entry
+}
+
+type entry struct {
+	scope     string
+	key       string
+	value     []byte
+	expiresAT time.Time
+	// The elements of the entry in the list of the cache and of its scope.
+	el      *list.Element
+	scopeEl *list.Element
+}
+
+func (e *entry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAT.IsZero() && !now.Before(e.expiresAT)
+}
+
+// New returns a cache that holds at most maxBytes of keys and values, 0
+// disables the cache.
+func New(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// Get returns the value cached under the key of the scope, like the id of
+// an endpoint.
+func (c *Cache) Get(scope, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.buckets[scope]
+	if !ok {
+		return nil, false
+	}
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		c.remove(e)
+		return nil, false
+	}
+	c.ll.MoveToFront(e.el)
+	s.ll.MoveToFront(e.scopeEl)
+	return e.value, true
+}
+
+// Set caches the value under the key of the scope for the ttl, a ttl of 0
+// keeps it until it is evicted. The entries of the scope are limited to
+// quota bytes, 0 is only limited by the size of the cache. The value is
+// not copied.
+func (c *Cache) Set(scope, key string, value []byte, ttl time.Duration, quota int) error {
+	if len(key) == 0 || len(key) > MaxKeySize {
+		return ErrInvalidKey
+	}
+	e := &entry{scope: scope, key: key, value: value}
+	size := e.size()
+	if size > c.maxBytes || (quota > 0 && size > quota) {
+		return ErrTooLarge
+	}
+	if ttl > 0 {
+		e.expiresAT = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.buckets[scope]; ok {
+		if old, ok := s.items[key]; ok {
+			c.remove(old)
+		}
+	}
+	s, ok := c.buckets[scope]
+	if !ok {
+		s = &bucket{ll: list.New(), items: make(map[string]*entry)}
+		c.buckets[scope] = s
+	}
+	e.el = c.ll.PushFront(e)
+	e.scopeEl = s.ll.PushFront(e)
+	s.items[key] = e
+	s.size += size
+	c.size += size
+	for quota > 0 && s.size > quota {
+		c.remove(s.ll.Back().Value.(*entry))
+	}
+	for c.size > c.maxBytes {
+		c.remove(c.ll.Back().Value.(*entry))
+	}
+	return nil
+}
+
+// Delete removes the key of the scope from the cache.
+func (c *Cache) Delete(scope, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.buckets[scope]; ok {
+		if e, ok := s.items[key]; ok {
+			c.remove(e)
+		}
+	}
+}
+
+// Size returns the size in bytes of the entries of the scope.
+func (c *Cache) Size(scope string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.buckets[scope]; ok {
+		return s.size
+	}
+	return 0
+}
+
+func (c *Cache) remove(e *entry) {
+	s := c.buckets[e.scope]
+	c.ll.Remove(e.el)
+	s.ll.Remove(e.scopeEl)
+	delete(s.items, e.key)
+	s.size -= e.size()
+	c.size -= e.size()
+	if len(s.items) == 0 {
+		delete(c.buckets, e.scope)
+	}
+}
+
+// Scope is the view of a cache of a single scope, with its quota.
+type Scope struct {
+	cache *Cache
+	name  string
+	quota int
+}
+
+// Scope returns the view of the scope of the cache, its entries are limited
+// to quota bytes.
+func (c *Cache) Scope(name string, quota int) Scope {
+	return Scope{cache: c, name: name, quota: quota}
+}
+
+// Get returns the value cached under the key.
+func (s Scope) Get(key string) ([]byte, bool) {
+	return s.cache.Get(s.name, key)
+}
+
+// Set caches the value under the key for the ttl, see Cache.Set.
+func (s Scope) Set(key string, value []byte, ttl time.Duration) error {
+	return s.cache.Set(s.name, key, value, ttl, s.quota)
+}
+
+// Delete removes the key from the cache.
+func (s Scope) Delete(key string) {
+	s.cache.Delete(s.name, key)
+}
@@ -0,0 +1,115 @@
+package guestcache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New(1024)
+	if err := c.Set("a", "key", []byte("value"), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := c.Get("a", "key")
+	if !ok || string(v) != "value" {
+		t.Fatalf("expected the cached value, got %q", v)
+	}
+	// The scopes do not share their keys.
+	if _, ok := c.Get("b", "key"); ok {
+		t.Fatal("expected the key to be missing in another scope")
+	}
+	if size := c.Size("a"); size != len("key")+len("value") {
+		t.Fatalf("expected a size of 8, got %d", size)
+	}
+	c.Delete("a", "key")
+	if _, ok := c.Get("a", "key"); ok {
+		t.Fatal("expected the key to be deleted")
+	}
+	if size := c.Size("a"); size != 0 {
+		t.Fatalf("expected an empty scope, got %d", size)
+	}
+	// Replacing the only entry of a scope keeps the scope.
+	c.Set("a", "key", []byte("v1"), 0, 0)
+	c.Set("a", "key", []byte("v2"), 0, 0)
+	if v, ok := c.Get("a", "key"); !ok || string(v) != "v2" {
+		t.Fatalf("expected the replaced value, got %q", v)
+	}
+	c.Delete("a", "key")
+
+	if err := c.Set("a", "", []byte("value"), 0, 0); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+	if err := c.Set("a", strings.Repeat("k", MaxKeySize+1), nil, 0, 0); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+	if err := c.Set("a", "key", make([]byte, 2048), 0, 0); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := New(1024)
+	if err := c.Set("a", "key", []byte("value"), time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 5)
+	if _, ok := c.Get("a", "key"); ok {
+		t.Fatal("expected the key to expire")
+	}
+	if size := c.Size("a"); size != 0 {
+		t.Fatalf("expected the expired entry to be removed, got a size of %d", size)
+	}
+}
+
+func TestCacheQuota(t *testing.T) {
+	c := New(1024)
+	value := bytes.Repeat([]byte("v"), 100)
+	s := c.Scope("a", 300)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if err := s.Set(key, value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Set("b", "k1", value, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The scope exceeds its quota with its third entry, its least recently
+	// used entry is evicted and the other scopes are untouched.
+	if _, ok := s.Get("k1"); ok {
+		t.Fatal("expected the least recently used entry of the scope to be evicted")
+	}
+	if size := c.Size("a"); size != 204 {
+		t.Fatalf("expected a size of 204, got %d", size)
+	}
+	if _, ok := c.Get("b", "k1"); !ok {
+		t.Fatal("expected the entry of the other scope to be kept")
+	}
+	if err := s.Set("big", make([]byte, 400), 0); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := New(300)
+	value := bytes.Repeat([]byte("v"), 98)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if err := c.Set("a", key, value, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// k1 is used again, k2 is evicted for the entry of another scope.
+	c.Get("a", "k1")
+	if err := c.Set("b", "k1", value, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("a", "k2"); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"k1", "k3"} {
+		if _, ok := c.Get("a", key); !ok {
+			t.Fatalf("expected %s to be kept", key)
+		}
+	}
+}
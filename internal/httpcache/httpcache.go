@@ -0,0 +1,285 @@
+package httpcache
+
+import (
+	"container/list"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheableStatus holds the status codes of which the responses can be
+// cached by default (RFC 9110 section 15.1).
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// Entry is a cached response.
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// The values of the request headers listed in the Vary header of the
+	// response, the entry is only served to requests with the same values.
+	Vary      map[string]string
+	CreatedAT time.Time
+	ExpiresAT time.Time
+}
+
+// NewEntry returns a new entry for the response to the given request that
+// is fresh for the given ttl.
+func NewEntry(r *http.Request, status int, header http.Header, body []byte, ttl time.Duration) *Entry {
+	now := time.Now()
+	entry := &Entry{
+		StatusCode: status,
+		Header:     header,
+		Body:       body,
+		CreatedAT:  now,
+		ExpiresAT:  now.Add(ttl),
+	}
+	for _, name := range varyHeaders(header) {
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+		entry.Vary[name] = strings.Join(r.Header.Values(name), ",")
+	}
+	return entry
+}
+
+// Matches returns true if the entry can be served to the given request.
+func (e *Entry) Matches(r *http.Request) bool {
+	for name, value := range e.Vary {
+		if strings.Join(r.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Age returns the amount of seconds the entry has been cached.
+func (e *Entry) Age(now time.Time) int {
+	return int(now.Sub(e.CreatedAT) / time.Second)
+}
+
+func (e *Entry) size() int {
+	n := len(e.Body)
+	for k, values := range e.Header {
+		n += len(k)
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// LRU is an in-memory cache of responses bounded by the total size of the
+// responses. The least recently used entries are evicted first.
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type item struct {
+	key   string
+	entry *Entry
+}
+
+// NewLRU returns a new cache that holds at most maxBytes of responses.
+func NewLRU(maxBytes int) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the fresh entry cached under the given key.
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*item).entry
+	if !time.Now().Before(entry.ExpiresAT) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry, true
+}
+
+// Add caches the entry under the given key. Entries that are larger than
+// the cache itself are not cached.
+func (c *LRU) Add(key string, entry *Entry) {
+	size := entry.size()
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.ll.PushFront(&item{key: key, entry: entry})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Len returns the amount of cached entries.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	it := c.ll.Remove(el).(*item)
+	delete(c.items, it.key)
+	c.size -= it.entry.size()
+}
+
+// Key returns the key the response to the given request is cached under.
+// HEAD requests share the key of the GET request. The prefix scopes the key,
+// like to an endpoint and its deployment, and vary lists the request headers
+// the response varies on besides the ones listed by the response itself.
+func Key(prefix string, r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(" GET ")
+	b.WriteString(r.URL.Path)
+	if len(r.URL.RawQuery) > 0 {
+		b.WriteString("?")
+		b.WriteString(r.URL.RawQuery)
+	}
+	names := make([]string, len(vary))
+	for i, name := range vary {
+		names[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// RequestPolicy returns whether a cached response may be served to the
+// given request and whether the response to the request may be cached.
+func RequestPolicy(r *http.Request) (lookup bool, store bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false, false
+	}
+	// Responses to authenticated requests are private to the user.
+	if len(r.Header.Get("Authorization")) > 0 {
+		return false, false
+	}
+	directives := parseCacheControl(r.Header)
+	if _, ok := directives["no-store"]; ok {
+		return false, false
+	}
+	store = r.Method == http.MethodGet
+	if _, ok := directives["no-cache"]; ok {
+		return false, store
+	}
+	if maxAge, ok := directives["max-age"]; ok && maxAge == "0" {
+		return false, store
+	}
+	if r.Header.Get("Pragma") == "no-cache" && len(directives) == 0 {
+		return false, store
+	}
+	return true, store
+}
+
+// TTL returns how long a shared cache may serve the response without
+// revalidating it, 0 means the response cannot be cached. Responses without
+// any freshness information are cached for the given default ttl.
+func TTL(status int, header http.Header, defaultTTL time.Duration) time.Duration {
+	if !cacheableStatus[status] {
+		return 0
+	}
+	if len(header.Values("Set-Cookie")) > 0 {
+		return 0
+	}
+	for _, name := range varyHeaders(header) {
+		if name == "*" {
+			return 0
+		}
+	}
+	directives := parseCacheControl(header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0
+		}
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if len(header.Get("Expires")) > 0 {
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			return 0
+		}
+		if ttl := time.Until(expires); ttl > 0 {
+			return ttl.Truncate(time.Second)
+		}
+		return 0
+	}
+	return defaultTTL
+}
+
+// parseCacheControl returns the directives of the Cache-Control header in
+// lower case with their (unquoted) argument.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if len(name) == 0 {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 0 {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
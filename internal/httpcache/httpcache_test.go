@@ -0,0 +1,147 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	cases := []struct {
+		status       int
+		cacheControl string
+		setCookie    bool
+		vary         string
+		ttl          time.Duration
+	}{
+		{status: 200, ttl: time.Minute},
+		{status: 200, cacheControl: "max-age=10", ttl: 10 * time.Second},
+		{status: 200, cacheControl: "public, max-age=10, s-maxage=30", ttl: 30 * time.Second},
+		{status: 200, cacheControl: "max-age=\"20\"", ttl: 20 * time.Second},
+		{status: 200, cacheControl: "private, max-age=10"},
+		{status: 200, cacheControl: "no-store"},
+		{status: 200, cacheControl: "No-Cache"},
+		{status: 200, cacheControl: "max-age=abc"},
+		{status: 200, cacheControl: "max-age=10", setCookie: true},
+		{status: 200, cacheControl: "max-age=10", vary: "*"},
+		{status: 404, cacheControl: "max-age=10", ttl: 10 * time.Second},
+		{status: 500, cacheControl: "max-age=10"},
+	}
+	for _, c := range cases {
+		header := make(http.Header)
+		if len(c.cacheControl) > 0 {
+			header.Set("Cache-Control", c.cacheControl)
+		}
+		if c.setCookie {
+			header.Set("Set-Cookie", "session=1")
+		}
+		if len(c.vary) > 0 {
+			header.Set("Vary", c.vary)
+		}
+		if ttl := TTL(c.status, header, time.Minute); ttl != c.ttl {
+			t.Errorf("%d %q: expected ttl %s got %s", c.status, c.cacheControl, c.ttl, ttl)
+		}
+	}
+}
+
+func TestRequestPolicy(t *testing.T) {
+	cases := []struct {
+		method       string
+		cacheControl string
+		auth         bool
+		lookup       bool
+		store        bool
+	}{
+		{method: "GET", lookup: true, store: true},
+		{method: "HEAD", lookup: true},
+		{method: "POST"},
+		{method: "GET", auth: true},
+		{method: "GET", cacheControl: "no-store"},
+		{method: "GET", cacheControl: "no-cache", store: true},
+		{method: "GET", cacheControl: "max-age=0", store: true},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if len(c.cacheControl) > 0 {
+			r.Header.Set("Cache-Control", c.cacheControl)
+		}
+		if c.auth {
+			r.Header.Set("Authorization", "Bearer token")
+		}
+		lookup, store := RequestPolicy(r)
+		if lookup != c.lookup || store != c.store {
+			t.Errorf("%s %q: expected (%t, %t) got (%t, %t)", c.method, c.cacheControl, c.lookup, c.store, lookup, store)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	get := httptest.NewRequest("GET", "/users?page=2", nil)
+	get.Header.Set("Accept-Language", "en")
+	head := httptest.NewRequest("HEAD", "/users?page=2", nil)
+	head.Header.Set("Accept-Language", "en")
+	if Key("a", get, []string{"accept-language"}) != Key("a", head, []string{"Accept-Language"}) {
+		t.Error("expected HEAD requests to share the key of GET requests")
+	}
+	other := httptest.NewRequest("GET", "/users?page=2", nil)
+	other.Header.Set("Accept-Language", "nl")
+	if Key("a", get, []string{"Accept-Language"}) == Key("a", other, []string{"Accept-Language"}) {
+		t.Error("expected the key to vary on the given headers")
+	}
+	if Key("a", get, nil) == Key("b", get, nil) {
+		t.Error("expected the key to be scoped by the prefix")
+	}
+	if Key("a", get, nil) == Key("a", httptest.NewRequest("GET", "/users?page=3", nil), nil) {
+		t.Error("expected the key to include the query")
+	}
+}
+
+func TestEntryMatches(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	header := http.Header{"Vary": []string{"accept-encoding"}}
+	entry := NewEntry(r, 200, header, []byte("ok"), time.Minute)
+	if !entry.Matches(r) {
+		t.Fatal("expected the entry to match the request it was cached for")
+	}
+	other := httptest.NewRequest("GET", "/", nil)
+	if entry.Matches(other) {
+		t.Fatal("expected the entry not to match a request with other vary values")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	newEntry := func(size int, ttl time.Duration) *Entry {
+		return NewEntry(r, 200, http.Header{}, []byte(strings.Repeat("a", size)), ttl)
+	}
+	c := NewLRU(100)
+	c.Add("a", newEntry(40, time.Minute))
+	c.Add("b", newEntry(40, time.Minute))
+	// Touch a, so b is the least recently used entry.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.Add("c", newEntry(40, time.Minute))
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries got %d", c.Len())
+	}
+
+	c.Add("large", newEntry(101, time.Minute))
+	if _, ok := c.Get("large"); ok {
+		t.Error("expected entries larger than the cache not to be cached")
+	}
+
+	c.Add("expired", newEntry(1, -time.Second))
+	if _, ok := c.Get("expired"); ok {
+		t.Error("expected expired entries not to be served")
+	}
+}
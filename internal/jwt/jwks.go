@@ -0,0 +1,266 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeySetUnavailable is returned when the key set of the issuer can not be
+// fetched, the token could not be verified either way.
+var ErrKeySetUnavailable = errors.New("key set is unavailable")
+
+// maxKeySetSize is the maximum size in bytes of a JWKS document.
+const maxKeySetSize = 1 << 20
+
+// Key is a public key of a key set.
+type Key struct {
+	ID string
+	// Algorithm the key is restricted to, any algorithm of its type when
+	// empty.
+	Alg string
+	Key crypto.PublicKey
+}
+
+// KeySet holds the public keys of an issuer.
+type KeySet []Key
+
+// lookup returns the keys the token with the given key id and algorithm can
+// be signed with. Tokens without a key id are checked against every key, an
+// empty algorithm matches the keys of any algorithm.
+func (s KeySet) lookup(kid, alg string) []crypto.PublicKey {
+	var keys []crypto.PublicKey
+	for _, key := range s {
+		if len(kid) > 0 && key.ID != kid {
+			continue
+		}
+		if len(alg) > 0 && len(key.Alg) > 0 && key.Alg != alg {
+			continue
+		}
+		keys = append(keys, key.Key)
+	}
+	return keys
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet parses a JWKS document. The keys that are not signing keys or
+// whose type is not supported are skipped.
+func ParseKeySet(b []byte) (KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+	var set KeySet
+	for _, k := range doc.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", k.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		set = append(set, Key{ID: k.Kid, Alg: k.Alg, Key: key})
+	}
+	return set, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+const (
+	// Time the key sets are cached.
+	keySetTTL = 10 * time.Minute
+	// Minimum time between two fetches of the same key set, so tokens with
+	// unknown key ids can not make the ingress hammer the issuer.
+	keySetMinRefresh = time.Minute
+	// Time before a failed fetch is attempted again.
+	keySetRetry = 10 * time.Second
+)
+
+type cachedKeySet struct {
+	mu          sync.Mutex
+	keys        KeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	err         error
+}
+
+// Cache fetches and caches the key sets of the issuers. A key set is fetched
+// again when it expired, or when a token is signed with a key it does not
+// know, as issuers rotate their keys.
+type Cache struct {
+	client *http.Client
+	mu     sync.Mutex
+	sets   map[string]*cachedKeySet
+}
+
+// NewCache returns a new cache fetching the key sets with the given client.
+func NewCache(client *http.Client) *Cache {
+	return &Cache{
+		client: client,
+		sets:   make(map[string]*cachedKeySet),
+	}
+}
+
+// Verify verifies the token with the key set at the given URL.
+func (c *Cache) Verify(ctx context.Context, url, token string, expect Expectation) (Claims, error) {
+	kid, err := KeyID(token)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := c.keys(ctx, url, kid)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(token, keys, expect, time.Now())
+}
+
+// keys returns the key set at the URL, refreshed when it does not hold the
+// key with the given id.
+func (c *Cache) keys(ctx context.Context, url, kid string) (KeySet, error) {
+	c.mu.Lock()
+	set, ok := c.sets[url]
+	if !ok {
+		set = &cachedKeySet{}
+		c.sets[url] = set
+	}
+	c.mu.Unlock()
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	age := time.Since(set.fetchedAt)
+	stale := set.fetchedAt.IsZero() || age > keySetTTL
+	if !stale && len(kid) > 0 && len(set.keys.lookup(kid, "")) == 0 && age > keySetMinRefresh {
+		stale = true
+	}
+	if stale && time.Since(set.attemptedAt) < keySetRetry {
+		stale = false
+	}
+	if !stale {
+		if set.fetchedAt.IsZero() {
+			return nil, set.err
+		}
+		return set.keys, nil
+	}
+	set.attemptedAt = time.Now()
+	keys, err := c.fetch(ctx, url)
+	if err != nil {
+		set.err = err
+		// Keep serving the previous keys while the issuer is unavailable.
+		if !set.fetchedAt.IsZero() {
+			return set.keys, nil
+		}
+		return nil, err
+	}
+	set.keys, set.fetchedAt = keys, time.Now()
+	return keys, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, url string) (KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeySetUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: responded with status code %d", ErrKeySetUnavailable, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeySetUnavailable, err)
+	}
+	keys, err := ParseKeySet(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeySetUnavailable, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,219 @@
+// Package jwt validates the JSON Web Tokens of the requests to the endpoints
+// against the keys of their issuer, published as a JWKS.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("token is malformed")
+	ErrUnknownKey       = errors.New("token is signed with an unknown key")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is not accepted")
+	ErrInvalidAudience  = errors.New("token audience is not accepted")
+)
+
+// leeway is the clock skew tolerated between the issuer and the ingress.
+const leeway = time.Minute
+
+// Claims are the claims of a verified token.
+type Claims map[string]any
+
+// Subject returns the sub claim of the token.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Expectation is what the claims of a token are checked against. Empty
+// fields are not checked.
+type Expectation struct {
+	Issuer string
+	// The token needs to be issued for one of the audiences.
+	Audiences []string
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify verifies the signature of the compact serialized token with the
+// keys of the key set and checks its claims at the given time.
+func Verify(token string, keys KeySet, expect Expectation, now time.Time) (Claims, error) {
+	claims, err := verifySignature(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClaims(claims, expect, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// KeyID returns the id of the key the token claims to be signed with, so the
+// key set can be refreshed when it does not know the key.
+func KeyID(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return "", ErrMalformed
+	}
+	return h.Kid, nil
+}
+
+func verifySignature(token string, keys KeySet) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	candidates := keys.lookup(h.Kid, h.Alg)
+	if len(candidates) == 0 {
+		return nil, ErrUnknownKey
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	for _, key := range candidates {
+		if verifyKey(h.Alg, key, signed, signature) {
+			return claims, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+func verifyKey(alg string, key crypto.PublicKey, signed, signature []byte) bool {
+	hash, ok := hashes[alg]
+	if !ok && alg != "EdDSA" {
+		return false
+	}
+	var digest []byte
+	if ok {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	case ed25519.PublicKey:
+		return alg == "EdDSA" && ed25519.Verify(k, signed, signature)
+	}
+	return false
+}
+
+// hashes are the hashes of the supported algorithms, besides EdDSA. The
+// "none" and HMAC algorithms are never accepted.
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func checkClaims(claims Claims, expect Expectation, now time.Time) error {
+	if exp, ok := numericDate(claims["exp"]); ok && now.After(exp.Add(leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+	if len(expect.Issuer) > 0 {
+		if iss, _ := claims["iss"].(string); iss != expect.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+	if len(expect.Audiences) > 0 && !hasAudience(claims["aud"], expect.Audiences) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// hasAudience returns true if the aud claim, a string or a list of strings,
+// holds one of the audiences.
+func hasAudience(aud any, audiences []string) bool {
+	var claimed []string
+	switch v := aud.(type) {
+	case string:
+		claimed = []string{v}
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				claimed = append(claimed, s)
+			}
+		}
+	}
+	for _, c := range claimed {
+		for _, a := range audiences {
+			if c == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid segment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sign(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]any) string {
+	h, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.Nil(t, err)
+	c, err := json.Marshal(claims)
+	require.Nil(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+	require.Nil(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	keys := KeySet{
+		{ID: "rsa", Alg: "RS256", Key: rsaKey.Public()},
+		{ID: "ec", Key: ecKey.Public()},
+		{ID: "ed", Key: edKey.Public()},
+	}
+	var (
+		now    = time.Unix(1700000000, 0)
+		expect = Expectation{Issuer: "https://issuer.example.com", Audiences: []string{"api"}}
+		claims = map[string]any{
+			"iss": "https://issuer.example.com",
+			"aud": []string{"web", "api"},
+			"sub": "user-1",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	)
+	for _, token := range []string{
+		sign(t, rsaKey, "RS256", "rsa", claims),
+		sign(t, ecKey, "ES256", "ec", claims),
+		sign(t, edKey, "EdDSA", "ed", claims),
+		// Tokens without a key id are checked against every key.
+		sign(t, ecKey, "ES256", "", claims),
+	} {
+		c, err := Verify(token, keys, expect, now)
+		require.Nil(t, err)
+		require.Equal(t, "user-1", c.Subject())
+	}
+
+	_, err = Verify(sign(t, rsaKey, "RS256", "unknown", claims), keys, expect, now)
+	require.Equal(t, ErrUnknownKey, err)
+	// The key is restricted to RS256.
+	_, err = Verify(sign(t, rsaKey, "PS256", "rsa", claims), keys, expect, now)
+	require.Equal(t, ErrUnknownKey, err)
+	_, err = Verify(sign(t, ecKey, "ES256", "rsa", claims), keys, expect, now)
+	require.Equal(t, ErrUnknownKey, err)
+	_, err = Verify(sign(t, ecKey, "ES256", "ed", claims), keys, expect, now)
+	require.Equal(t, ErrInvalidSignature, err)
+	_, err = Verify("a.b", keys, expect, now)
+	require.Equal(t, ErrMalformed, err)
+
+	// Unsigned tokens are never accepted.
+	unsigned := sign(t, edKey, "none", "ed", claims)
+	_, err = Verify(unsigned[:len(unsigned)-86], keys, expect, now)
+	require.Equal(t, ErrInvalidSignature, err)
+
+	token := sign(t, rsaKey, "RS256", "rsa", claims)
+	_, err = Verify(token, keys, expect, now.Add(time.Hour+2*time.Minute))
+	require.Equal(t, ErrExpired, err)
+	_, err = Verify(token, keys, Expectation{Issuer: "https://other.example.com"}, now)
+	require.Equal(t, ErrInvalidIssuer, err)
+	_, err = Verify(token, keys, Expectation{Audiences: []string{"admin"}}, now)
+	require.Equal(t, ErrInvalidAudience, err)
+
+	claims["nbf"] = now.Add(time.Hour).Unix()
+	_, err = Verify(sign(t, rsaKey, "RS256", "rsa", claims), keys, expect, now)
+	require.Equal(t, ErrNotYetValid, err)
+}
+
+func TestParseKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	doc := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa","alg":"RS256","use":"sig","n":%q,"e":"AQAB"},
+		{"kty":"EC","kid":"ec","crv":"P-256","x":%q,"y":%q},
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":%q},
+		{"kty":"RSA","kid":"enc","use":"enc","n":%q,"e":"AQAB"},
+		{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}
+	]}`,
+		b64(rsaKey.N.Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		b64(edPub),
+		b64(rsaKey.N.Bytes()),
+	)
+	keys, err := ParseKeySet([]byte(doc))
+	require.Nil(t, err)
+	require.Len(t, keys, 3)
+	require.True(t, rsaKey.PublicKey.Equal(keys[0].Key))
+	require.Equal(t, "RS256", keys[0].Alg)
+	require.True(t, ecKey.PublicKey.Equal(keys[1].Key))
+	require.True(t, edPub.Equal(keys[2].Key))
+
+	_, err = ParseKeySet([]byte(`{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	require.NotNil(t, err)
+}
+
+func TestCacheRefreshesUnknownKeys(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	var (
+		current atomic.Pointer[rsa.PrivateKey]
+		fetches atomic.Int32
+		fail    atomic.Bool
+	)
+	current.Store(first)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		key := current.Load()
+		kid := "first"
+		if key == second {
+			kid = "second"
+		}
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":%q,"n":%q,"e":"AQAB"}]}`, kid, b64(key.N.Bytes()))
+	}))
+	defer srv.Close()
+
+	var (
+		cache  = NewCache(srv.Client())
+		ctx    = context.Background()
+		claims = map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	)
+	_, err = cache.Verify(ctx, srv.URL, sign(t, first, "RS256", "first", claims), Expectation{})
+	require.Nil(t, err)
+	_, err = cache.Verify(ctx, srv.URL, sign(t, first, "RS256", "first", claims), Expectation{})
+	require.Nil(t, err)
+	require.Equal(t, int32(1), fetches.Load())
+
+	// The issuer rotated its key, the key set is fetched again once the
+	// minimum time between two fetches passed.
+	current.Store(second)
+	token := sign(t, second, "RS256", "second", claims)
+	_, err = cache.Verify(ctx, srv.URL, token, Expectation{})
+	require.Equal(t, ErrUnknownKey, err)
+	require.Equal(t, int32(1), fetches.Load())
+	cache.sets[srv.URL].fetchedAt = time.Now().Add(-2 * keySetMinRefresh)
+	cache.sets[srv.URL].attemptedAt = cache.sets[srv.URL].fetchedAt
+	_, err = cache.Verify(ctx, srv.URL, token, Expectation{})
+	require.Nil(t, err)
+	require.Equal(t, int32(2), fetches.Load())
+
+	// The previous keys are served while the issuer is unavailable.
+	fail.Store(true)
+	cache.sets[srv.URL].fetchedAt = time.Now().Add(-2 * keySetTTL)
+	cache.sets[srv.URL].attemptedAt = cache.sets[srv.URL].fetchedAt
+	_, err = cache.Verify(ctx, srv.URL, token, Expectation{})
+	require.Nil(t, err)
+	require.Equal(t, int32(3), fetches.Load())
+
+	_, err = NewCache(srv.Client()).Verify(ctx, srv.URL, token, Expectation{})
+	require.ErrorIs(t, err, ErrKeySetUnavailable)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
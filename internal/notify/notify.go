@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// Headers of the webhook deliveries.
+const (
+	EventHeader    = "Raptor-Event"
+	DeliveryHeader = "Raptor-Delivery"
+	// SignatureHeader holds the time of the delivery and the signature of
+	// the body as "t=<unix seconds>,v1=<hex HMAC-SHA256>", see Sign.
+	SignatureHeader = "Raptor-Signature"
+)
+
+const (
+	defaultAttempts = 4
+	defaultBackoff  = time.Second
+	deliveryTimeout = 10 * time.Second
+)
+
+// Notifier stores the platform events and delivers them to the webhooks of
+// their endpoint subscribed to them.
+type Notifier struct {
+	store  storage.Store
+	client *http.Client
+	// Attempts of a delivery, the wait between the attempts doubles
+	// starting at the backoff.
+	attempts int
+	backoff  time.Duration
+}
+
+// New returns a new notifier given the store of the events and webhooks.
+func New(store storage.Store) *Notifier {
+	return &Notifier{
+		store:    store,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		attempts: defaultAttempts,
+		backoff:  defaultBackoff,
+	}
+}
+
+// WithRetries sets the amount of attempts of a delivery and the wait before
+// the first retry.
+func (n *Notifier) WithRetries(attempts int, backoff time.Duration) *Notifier {
+	n.attempts = max(attempts, 1)
+	n.backoff = backoff
+	return n
+}
+
+// Emit stores the event and delivers it in the background. A nil notifier
+// drops the event.
+func (n *Notifier) Emit(event *types.Event) {
+	if n == nil {
+		return
+	}
+	if err := n.store.CreateEvent(event); err != nil {
+		slog.Warn("failed to store event", "err", err, "type", event.Type)
+	}
+	webhooks, err := n.store.GetWebhooks(event.EndpointID)
+	if err != nil {
+		slog.Warn("failed to get webhooks", "err", err, "endpoint", event.EndpointID)
+		return
+	}
+	for _, webhook := range webhooks {
+		if webhook.Subscribed(event.Type) {
+			go n.deliver(webhook, event)
+		}
+	}
+}
+
+// deliver posts the event to the webhook until it responds with a 2xx
+// status code or the attempts run out.
+func (n *Notifier) deliver(webhook types.Webhook, event *types.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Warn("failed to encode event", "err", err)
+		return
+	}
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(webhook, event, body)
+		if err == nil {
+			return
+		}
+		if attempt == n.attempts {
+			slog.Warn("failed to deliver event", "err", err, "webhook", webhook.ID, "event", event.ID, "attempts", attempt)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(webhook types.Webhook, event *types.Event, body []byte) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID.String())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), body))
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header of a delivery of the body at the given
+// time. The signature is the HMAC-SHA256 of "<unix seconds>.<body>" keyed
+// with the secret of the webhook, receivers recompute it and reject old
+// timestamps to guard against replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// NewSecret returns a random secret to sign the deliveries of a webhook
+// with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"rollback"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000."))
+	mac.Write(body)
+	expected := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	require.Equal(t, expected, Sign("secret", time.Unix(1700000000, 0), body))
+	require.NotEqual(t, expected, Sign("other", time.Unix(1700000000, 0), body))
+}
+
+func TestEmitRetries(t *testing.T) {
+	var (
+		calls     atomic.Int32
+		signature = make(chan string, 1)
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var ts int64
+		_, _ = fmt.Sscanf(r.Header.Get(SignatureHeader), "t=%d,", &ts)
+		if r.Header.Get(SignatureHeader) == Sign("secret", time.Unix(ts, 0), body) {
+			signature <- "valid"
+		} else {
+			signature <- "invalid"
+		}
+	}))
+	defer receiver.Close()
+
+	store := storage.NewMemoryStore()
+	endpointID := uuid.New()
+	require.Nil(t, store.CreateWebhook(&types.Webhook{ID: uuid.New(), EndpointID: endpointID, URL: receiver.URL, Secret: "secret"}))
+	require.Nil(t, store.CreateWebhook(&types.Webhook{
+		ID:         uuid.New(),
+		EndpointID: endpointID,
+		URL:        receiver.URL,
+		Events:     []string{types.EventQuotaExceeded},
+	}))
+
+	n := New(store).WithRetries(3, time.Millisecond)
+	n.Emit(types.NewEvent(types.EventRollback, endpointID, uuid.New(), "alice", "aborted"))
+
+	select {
+	case s := <-signature:
+		require.Equal(t, "valid", s)
+	case <-time.After(time.Second * 5):
+		t.Fatal("event was not delivered")
+	}
+	require.Equal(t, int32(3), calls.Load())
+
+	events, err := store.GetEvents(endpointID)
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, types.EventRollback, events[0].Type)
+}
+
+func TestEmitNil(t *testing.T) {
+	var n *Notifier
+	n.Emit(types.NewEvent(types.EventRollback, uuid.New(), uuid.New(), "", ""))
+}
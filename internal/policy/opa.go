@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type opaRequest struct {
+	Input Input `json:"input"`
+}
+
+type opaResponse struct {
+	Result struct {
+		Allow   bool     `json:"allow"`
+		Reasons []string `json:"reasons"`
+	} `json:"result"`
+}
+
+// OPA is a rule that delegates the decision to an Open Policy Agent server.
+// The policy document at the given URL (e.g. http://localhost:8181/v1/data/raptor)
+// needs to produce an "allow" boolean and optionally a list of "reasons".
+type OPA struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPA returns a new OPA rule given the URL of the policy document.
+func NewOPA(url string) *OPA {
+	return &OPA{
+		url:    url,
+		client: &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+func (o *OPA) Evaluate(in Input) error {
+	// Never send the blob over the wire, policies can decide on its hash.
+	if in.Deployment != nil {
+		deploy := *in.Deployment
+		deploy.Blob = nil
+		in.Deployment = &deploy
+	}
+	b, err := json.Marshal(opaRequest{Input: in})
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Post(o.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("could not evaluate opa policy: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("opa responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	var opaResp opaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&opaResp); err != nil {
+		return err
+	}
+	if !opaResp.Result.Allow {
+		if len(opaResp.Result.Reasons) == 0 {
+			return fmt.Errorf("denied by opa policy")
+		}
+		return fmt.Errorf("denied by opa policy: %s", strings.Join(opaResp.Result.Reasons, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	ActionDeploy  = "deploy"
+	ActionPublish = "publish"
+)
+
+// Input holds all the information a rule can base its decision on.
+type Input struct {
+	Action     string            `json:"action"`
+	Endpoint   *types.Endpoint   `json:"endpoint"`
+	Deployment *types.Deployment `json:"deployment"`
+	Time       time.Time         `json:"time"`
+}
+
+// Rule evaluates an input and returns an error when the action violates
+// the rule.
+type Rule interface {
+	Evaluate(Input) error
+}
+
+// RuleFunc is a function type implementing the Rule interface.
+type RuleFunc func(Input) error
+
+func (f RuleFunc) Evaluate(in Input) error {
+	return f(in)
+}
+
+// Engine evaluates deployments and publishes against a set of rules.
+type Engine struct {
+	rules []Rule
+}
+
+// New returns a new policy engine given a set of rules.
+func New(rules ...Rule) *Engine {
+	return &Engine{
+		rules: rules,
+	}
+}
+
+// NewFromConfig returns a policy engine with the rules enabled in the
+// given configuration.
+func NewFromConfig(c config.Policy) (*Engine, error) {
+	var rules []Rule
+	if c.MaxBlobSize > 0 {
+		rules = append(rules, MaxBlobSize(c.MaxBlobSize))
+	}
+	if len(c.ForbiddenImports) > 0 {
+		rules = append(rules, ForbiddenImports(c.ForbiddenImports))
+	}
+	if len(c.ProtectedEndpoints) > 0 {
+		ids := make([]uuid.UUID, 0, len(c.ProtectedEndpoints))
+		for _, value := range c.ProtectedEndpoints {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid protected endpoint id (%s): %s", value, err)
+			}
+			ids = append(ids, id)
+		}
+		window := ChangeWindow{
+			Days:  c.ChangeWindow.Days,
+			Start: c.ChangeWindow.Start,
+			End:   c.ChangeWindow.End,
+		}
+		rules = append(rules, ProtectedEndpoints(ids, window))
+	}
+	if len(c.OPAURL) > 0 {
+		rules = append(rules, NewOPA(c.OPAURL))
+	}
+	return New(rules...), nil
+}
+
+// Evaluate evaluates the given input against all the rules of the engine.
+// All the violations are joined into the returned error.
+func (e *Engine) Evaluate(in Input) error {
+	if in.Time.IsZero() {
+		in.Time = time.Now()
+	}
+	var errs []error
+	for _, rule := range e.rules {
+		if err := rule.Evaluate(in); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("policy violation: %w", errors.Join(errs...))
+}
+
+// MaxBlobSize rejects deployments with a blob larger than the given amount
+// of bytes.
+func MaxBlobSize(max int) Rule {
+	return RuleFunc(func(in Input) error {
+		if in.Action != ActionDeploy || in.Deployment == nil {
+			return nil
+		}
+		if len(in.Deployment.Blob) > max {
+			return fmt.Errorf("deployment size (%d bytes) exceeds the maximum of %d bytes", len(in.Deployment.Blob), max)
+		}
+		return nil
+	})
+}
+
+// ForbiddenImports rejects WASM deployments that import host functions
+// matching one of the given prefixes (e.g. "wasi_snapshot_preview1.sock_").
+func ForbiddenImports(prefixes []string) Rule {
+	return RuleFunc(func(in Input) error {
+		if in.Action != ActionDeploy || in.Deployment == nil {
+			return nil
+		}
+		if !isWasm(in.Deployment.Blob) {
+			return nil
+		}
+		imports, err := wasmImports(in.Deployment.Blob)
+		if err != nil {
+			return err
+		}
+		for _, imp := range imports {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(imp, prefix) {
+					return fmt.Errorf("deployment imports forbidden capability (%s)", imp)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ChangeWindow is a recurring window in UTC in which changes are allowed.
+type ChangeWindow struct {
+	// Lowercase 3 letter days of the week (mon, tue, ...). Empty means every day.
+	Days []string
+	// Start and end of the window in the format 15:04.
+	Start string
+	End   string
+}
+
+// Contains returns true if the given time is inside the window.
+func (w ChangeWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	if len(w.Days) > 0 {
+		day := strings.ToLower(t.Weekday().String()[:3])
+		var ok bool
+		for _, d := range w.Days {
+			if strings.ToLower(d) == day {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	return minutes >= start.Hour()*60+start.Minute() && minutes < end.Hour()*60+end.Minute()
+}
+
+// ProtectedEndpoints rejects deploys and publishes targeting one of the given
+// endpoints outside of the change window.
+func ProtectedEndpoints(ids []uuid.UUID, window ChangeWindow) Rule {
+	protected := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		protected[id] = true
+	}
+	return RuleFunc(func(in Input) error {
+		if in.Endpoint == nil || !protected[in.Endpoint.ID] {
+			return nil
+		}
+		if !window.Contains(in.Time) {
+			return fmt.Errorf("endpoint (%s) is protected and can only be changed during the change window", in.Endpoint.ID)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBlobSize(t *testing.T) {
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	deploy := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	in := Input{Action: ActionDeploy, Endpoint: endpoint, Deployment: deploy}
+
+	require.Nil(t, New(MaxBlobSize(100)).Evaluate(in))
+	require.NotNil(t, New(MaxBlobSize(5)).Evaluate(in))
+
+	in.Action = ActionPublish
+	require.Nil(t, New(MaxBlobSize(5)).Evaluate(in))
+}
+
+func TestForbiddenImports(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+
+	imports, err := wasmImports(b)
+	require.Nil(t, err)
+	require.Contains(t, imports, "wasi_snapshot_preview1.fd_write")
+
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	deploy := types.NewDeployment(endpoint, b)
+	in := Input{Action: ActionDeploy, Endpoint: endpoint, Deployment: deploy}
+
+	require.Nil(t, New(ForbiddenImports([]string{"wasi_snapshot_preview1.sock_open"})).Evaluate(in))
+	require.NotNil(t, New(ForbiddenImports([]string{"wasi_snapshot_preview1.fd_"})).Evaluate(in))
+}
+
+func TestProtectedEndpoints(t *testing.T) {
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	window := ChangeWindow{
+		Days:  []string{"mon"},
+		Start: "09:00",
+		End:   "17:00",
+	}
+	engine := New(ProtectedEndpoints([]uuid.UUID{endpoint.ID}, window))
+
+	monday := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.Nil(t, engine.Evaluate(Input{Action: ActionPublish, Endpoint: endpoint, Time: monday}))
+	require.NotNil(t, engine.Evaluate(Input{Action: ActionPublish, Endpoint: endpoint, Time: monday.Add(time.Hour * 8)}))
+	require.NotNil(t, engine.Evaluate(Input{Action: ActionPublish, Endpoint: endpoint, Time: monday.Add(time.Hour * 24)}))
+
+	other := types.NewEndpoint("Other endpoint", "go", nil)
+	require.Nil(t, engine.Evaluate(Input{Action: ActionPublish, Endpoint: other, Time: monday.Add(time.Hour * 24)}))
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	importSectionID  = 2
+	importKindFunc   = 0
+	importKindTable  = 1
+	importKindMemory = 2
+	importKindGlobal = 3
+	limitsHasMaxFlag = 0x01
+)
+
+var (
+	wasmMagic        = []byte{0x00, 0x61, 0x73, 0x6d}
+	errMalformedWasm = errors.New("malformed wasm binary")
+)
+
+func isWasm(b []byte) bool {
+	return len(b) >= 8 && bytes.Equal(b[:4], wasmMagic)
+}
+
+// wasmImports returns all the imports of the given WASM binary in the format
+// of "module.name".
+func wasmImports(b []byte) ([]string, error) {
+	if !isWasm(b) {
+		return nil, errMalformedWasm
+	}
+	r := &reader{b: b, pos: 8}
+	for r.pos < len(r.b) {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		if id != importSectionID {
+			r.pos += int(size)
+			continue
+		}
+		return r.imports()
+	}
+	return nil, nil
+}
+
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errMalformedWasm
+	}
+	b := r.b[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	var (
+		result uint32
+		shift  uint
+	)
+	for i := 0; i < 5; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+	return 0, errMalformedWasm
+}
+
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	if r.pos+int(n) > len(r.b) {
+		return "", errMalformedWasm
+	}
+	name := string(r.b[r.pos : r.pos+int(n)])
+	r.pos += int(n)
+	return name, nil
+}
+
+func (r *reader) limits() error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if _, err := r.u32(); err != nil {
+		return err
+	}
+	if flags&limitsHasMaxFlag != 0 {
+		if _, err := r.u32(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reader) imports() ([]string, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	imports := make([]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		module, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+		name, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case importKindFunc:
+			_, err = r.u32()
+		case importKindTable:
+			if _, err = r.byte(); err == nil {
+				err = r.limits()
+			}
+		case importKindMemory:
+			err = r.limits()
+		case importKindGlobal:
+			if _, err = r.byte(); err == nil {
+				_, err = r.byte()
+			}
+		default:
+			err = fmt.Errorf("unknown import kind (%d)", kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, module+"."+name)
+	}
+	return imports, nil
+}
@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+)
+
+const (
+	consulRefreshInterval = time.Second * 5
+	consulCheckTTL        = "15s"
+	consulDeregisterAfter = "1m"
+)
+
+// ConsulConfig holds the configuration of the Consul provider.
+type ConsulConfig struct {
+	// HTTP address of the Consul agent.
+	Address string
+	// Name of the service the cluster members register under.
+	Service string
+}
+
+// Consul is a cluster provider that registers this member as a service in
+// Consul with a TTL health check, and discovers its peers by watching the
+// passing instances of that same service.
+type Consul struct {
+	memberSet
+	config    ConsulConfig
+	client    *http.Client
+	interval  time.Duration
+	refresher actor.SendRepeater
+}
+
+// NewConsulProvider returns a cluster provider that discovers members using
+// the Consul service catalog.
+func NewConsulProvider(config ConsulConfig) cluster.Producer {
+	return newConsulProvider(config, consulRefreshInterval)
+}
+
+func newConsulProvider(config ConsulConfig, interval time.Duration) cluster.Producer {
+	return func(c *cluster.Cluster) actor.Producer {
+		return func() actor.Receiver {
+			return &Consul{
+				memberSet: newMemberSet(c),
+				config:    config,
+				client:    &http.Client{Timeout: time.Second * 2},
+				interval:  interval,
+			}
+		}
+	}
+}
+
+func (p *Consul) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		p.start(c)
+		if err := p.register(); err != nil {
+			slog.Error("[CLUSTER] failed to register member in consul, retrying on the next refresh", "err", err)
+		}
+		p.refresher = c.SendRepeat(c.PID(), refreshMembers{}, p.interval)
+		p.refresh(c)
+	case actor.Stopped:
+		p.refresher.Stop()
+		p.stop()
+		if err := p.deregister(); err != nil {
+			slog.Error("[CLUSTER] failed to deregister member from consul", "err", err)
+		}
+	case refreshMembers:
+		p.heartbeat()
+		p.refresh(c)
+	default:
+		p.receive(c)
+	}
+}
+
+type consulCheck struct {
+	CheckID                        string
+	TTL                            string
+	DeregisterCriticalServiceAfter string
+}
+
+type consulService struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Meta    map[string]string
+	Check   *consulCheck `json:",omitempty"`
+}
+
+type consulServiceEntry struct {
+	Service consulService
+}
+
+func (p *Consul) checkID() string {
+	return "service:" + p.cluster.ID()
+}
+
+func (p *Consul) register() error {
+	host, portstr, err := net.SplitHostPort(p.cluster.Member().Host)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portstr)
+	if err != nil {
+		return err
+	}
+	service := consulService{
+		ID:      p.cluster.ID(),
+		Name:    p.config.Service,
+		Address: host,
+		Port:    port,
+		Meta:    map[string]string{"region": p.cluster.Region()},
+		Check: &consulCheck{
+			CheckID:                        p.checkID(),
+			TTL:                            consulCheckTTL,
+			DeregisterCriticalServiceAfter: consulDeregisterAfter,
+		},
+	}
+	b, err := json.Marshal(service)
+	if err != nil {
+		return err
+	}
+	if err := p.do("PUT", "/v1/agent/service/register", b, nil); err != nil {
+		return err
+	}
+	return p.passCheck()
+}
+
+func (p *Consul) deregister() error {
+	return p.do("PUT", "/v1/agent/service/deregister/"+p.cluster.ID(), nil, nil)
+}
+
+func (p *Consul) passCheck() error {
+	return p.do("PUT", "/v1/agent/check/pass/"+p.checkID(), nil, nil)
+}
+
+// heartbeat passes the health check of this member. When the check cannot
+// be passed, because Consul was unreachable when the member started or its
+// agent lost the registration, the member registers again.
+func (p *Consul) heartbeat() {
+	err := p.passCheck()
+	if err == nil {
+		return
+	}
+	slog.Warn("[CLUSTER] failed to update consul health check, registering again", "err", err)
+	if err := p.register(); err != nil {
+		slog.Error("[CLUSTER] failed to register member in consul", "err", err)
+	}
+}
+
+// refresh fetches the healthy instances of the service and handshakes with
+// every peer that is not a member yet. Members that are no longer healthy
+// are removed from the cluster.
+func (p *Consul) refresh(c *actor.Context) {
+	var entries []consulServiceEntry
+	if err := p.do("GET", "/v1/health/service/"+p.config.Service+"?passing=true", nil, &entries); err != nil {
+		slog.Warn("[CLUSTER] failed to fetch members from consul", "err", err)
+		return
+	}
+	hosts := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		host := net.JoinHostPort(entry.Service.Address, strconv.Itoa(entry.Service.Port))
+		hosts[host] = true
+		p.handshake(c, host, entry.Service.ID)
+	}
+	p.retain(hosts)
+}
+
+func (p *Consul) do(method, path string, body []byte, v any) error {
+	url := strings.TrimSuffix(p.config.Address, "/") + path
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul responded with a non 200 status code: %d", resp.StatusCode)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
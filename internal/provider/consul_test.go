@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul is a Consul agent holding the registered services in memory.
+// The checks of the services that are not registered cannot be passed, like
+// in Consul.
+type fakeConsul struct {
+	mu       sync.Mutex
+	down     bool
+	services map[string]consulService
+	passes   map[string]int
+}
+
+func newFakeConsul(t *testing.T) (*fakeConsul, *httptest.Server) {
+	consul := &fakeConsul{
+		services: make(map[string]consulService),
+		passes:   make(map[string]int),
+	}
+	server := httptest.NewServer(consul)
+	t.Cleanup(server.Close)
+	return consul, server
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		var service consulService
+		if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.services[service.ID] = service
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(f.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/pass/service:"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/agent/check/pass/service:")
+		if _, ok := f.services[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.passes[id]++
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+		entries := []consulServiceEntry{}
+		for id, service := range f.services {
+			if service.Name == name && f.passes[id] > 0 {
+				entries = append(entries, consulServiceEntry{Service: service})
+			}
+		}
+		json.NewEncoder(w).Encode(entries)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// forget drops the registration of the service, like an agent that lost its
+// state.
+func (f *fakeConsul) forget(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.services, id)
+	delete(f.passes, id)
+}
+
+func (f *fakeConsul) service(id string) (consulService, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	service, ok := f.services[id]
+	return service, ok && f.passes[id] > 0
+}
+
+func startConsulMember(t *testing.T, address, id string) *cluster.Cluster {
+	config := cluster.NewConfig().
+		WithID(id).
+		WithRegion("eu-west").
+		WithProvider(newConsulProvider(ConsulConfig{Address: address, Service: "raptor"}, time.Millisecond*20))
+	c, err := cluster.New(config)
+	require.Nil(t, err)
+	c.Start()
+	return c
+}
+
+func TestConsulRegister(t *testing.T) {
+	consul, server := newFakeConsul(t)
+	c := startConsulMember(t, server.URL, "a")
+	defer func() { c.Stop().Wait() }()
+
+	require.Eventually(t, func() bool {
+		_, passing := consul.service("a")
+		return passing
+	}, time.Second, time.Millisecond*10)
+	service, _ := consul.service("a")
+	require.Equal(t, "raptor", service.Name)
+	require.Equal(t, c.Member().Host, net.JoinHostPort(service.Address, strconv.Itoa(service.Port)))
+	require.Equal(t, map[string]string{"region": "eu-west"}, service.Meta)
+	require.Equal(t, &consulCheck{
+		CheckID:                        "service:a",
+		TTL:                            consulCheckTTL,
+		DeregisterCriticalServiceAfter: consulDeregisterAfter,
+	}, service.Check)
+}
+
+func TestConsulRegisterRetry(t *testing.T) {
+	consul, server := newFakeConsul(t)
+	// Consul is unreachable when the member starts.
+	consul.setDown(true)
+	c := startConsulMember(t, server.URL, "a")
+	defer func() { c.Stop().Wait() }()
+
+	time.Sleep(time.Millisecond * 50)
+	_, passing := consul.service("a")
+	require.False(t, passing)
+	consul.setDown(false)
+	require.Eventually(t, func() bool {
+		_, passing := consul.service("a")
+		return passing
+	}, time.Second, time.Millisecond*10)
+
+	// The member registers again when the agent lost its registration.
+	consul.forget("a")
+	require.Eventually(t, func() bool {
+		_, passing := consul.service("a")
+		return passing
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestConsulRefresh(t *testing.T) {
+	_, server := newFakeConsul(t)
+	a := startConsulMember(t, server.URL, "a")
+	defer func() { a.Stop().Wait() }()
+	b := startConsulMember(t, server.URL, "b")
+
+	// The members discover each other through the passing instances.
+	require.Eventually(t, func() bool {
+		return hasMember(a, "b") && hasMember(b, "a")
+	}, time.Second*2, time.Millisecond*10)
+
+	// A member that deregistered is removed on the next refresh.
+	b.Stop().Wait()
+	require.Eventually(t, func() bool {
+		return !hasMember(a, "b")
+	}, time.Second*2, time.Millisecond*10)
+}
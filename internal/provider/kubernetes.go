@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+)
+
+const kubernetesRefreshInterval = time.Second * 5
+
+// KubernetesConfig holds the configuration of the Kubernetes provider.
+type KubernetesConfig struct {
+	// DNS name of the headless service that selects all the cluster members.
+	Service string
+	// Port all the cluster members are listening on.
+	Port string
+}
+
+// Kubernetes is a cluster provider that discovers its peers by resolving the
+// headless service of the Deployment the members are running in. Members are
+// identified by their pod IP, which allows the provider to address the peers
+// it finds in the service DNS records.
+type Kubernetes struct {
+	memberSet
+	config    KubernetesConfig
+	lookup    func(ctx context.Context, host string) ([]string, error)
+	interval  time.Duration
+	refresher actor.SendRepeater
+}
+
+// NewKubernetesProvider returns a cluster provider that discovers members
+// using Kubernetes headless service DNS.
+func NewKubernetesProvider(config KubernetesConfig) cluster.Producer {
+	return newKubernetesProvider(config, net.DefaultResolver.LookupHost, kubernetesRefreshInterval)
+}
+
+func newKubernetesProvider(config KubernetesConfig, lookup func(ctx context.Context, host string) ([]string, error), interval time.Duration) cluster.Producer {
+	return func(c *cluster.Cluster) actor.Producer {
+		return func() actor.Receiver {
+			return &Kubernetes{
+				memberSet: newMemberSet(c),
+				config:    config,
+				lookup:    lookup,
+				interval:  interval,
+			}
+		}
+	}
+}
+
+// KubernetesMember returns the member id and the cluster listen address of
+// this pod. The pod IP needs to be exposed in the POD_IP environment variable
+// through the downward API.
+func KubernetesMember(port string) (id string, addr string, err error) {
+	ip := os.Getenv("POD_IP")
+	if len(ip) == 0 {
+		return "", "", fmt.Errorf("kubernetes provider requires the POD_IP environment variable")
+	}
+	return ip, net.JoinHostPort(ip, port), nil
+}
+
+func (k *Kubernetes) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		k.start(c)
+		k.refresher = c.SendRepeat(c.PID(), refreshMembers{}, k.interval)
+		k.refresh(c)
+	case actor.Stopped:
+		k.refresher.Stop()
+		k.stop()
+	case refreshMembers:
+		k.refresh(c)
+	default:
+		k.receive(c)
+	}
+}
+
+// refresh resolves the headless service and handshakes with every peer that
+// is not a member yet. Members that are no longer part of the service are
+// removed from the cluster.
+func (k *Kubernetes) refresh(c *actor.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ips, err := k.lookup(ctx, k.config.Service)
+	if err != nil {
+		slog.Warn("[CLUSTER] failed to resolve kubernetes service", "service", k.config.Service, "err", err)
+		return
+	}
+	hosts := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		host := net.JoinHostPort(ip, k.config.Port)
+		hosts[host] = true
+		k.handshake(c, host, ip)
+	}
+	k.retain(hosts)
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeService resolves the headless service to the pod IPs it holds.
+type fakeService struct {
+	mu   sync.Mutex
+	err  error
+	ips  []string
+	port string
+}
+
+func newFakeService(t *testing.T) *fakeService {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	return &fakeService{port: strconv.Itoa(l.Addr().(*net.TCPAddr).Port)}
+}
+
+func (f *fakeService) lookup(ctx context.Context, host string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return append([]string(nil), f.ips...), nil
+}
+
+func (f *fakeService) set(err error, ips ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+	f.ips = ips
+}
+
+// startKubernetesMember starts a member with the given pod IP, the pods
+// listen on distinct loopback addresses and the same port.
+func startKubernetesMember(t *testing.T, service *fakeService, ip string) *cluster.Cluster {
+	config := cluster.NewConfig().
+		WithID(ip).
+		WithRegion("eu-west").
+		WithListenAddr(net.JoinHostPort(ip, service.port)).
+		WithProvider(newKubernetesProvider(KubernetesConfig{Service: "raptor", Port: service.port}, service.lookup, time.Millisecond*20))
+	c, err := cluster.New(config)
+	require.Nil(t, err)
+	c.Start()
+	return c
+}
+
+func hasMember(c *cluster.Cluster, id string) bool {
+	for _, member := range c.Members() {
+		if member.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKubernetesRefresh(t *testing.T) {
+	service := newFakeService(t)
+	service.set(nil, "127.0.0.1", "127.0.0.2")
+	a := startKubernetesMember(t, service, "127.0.0.1")
+	defer func() { a.Stop().Wait() }()
+	b := startKubernetesMember(t, service, "127.0.0.2")
+	defer func() { b.Stop().Wait() }()
+
+	// The members discover each other through the service records.
+	require.Eventually(t, func() bool {
+		return hasMember(a, "127.0.0.2") && hasMember(b, "127.0.0.1")
+	}, time.Second*2, time.Millisecond*10)
+
+	// A pod that is no longer part of the service is removed on the next
+	// refresh.
+	service.set(nil, "127.0.0.1")
+	require.Eventually(t, func() bool {
+		return !hasMember(a, "127.0.0.2")
+	}, time.Second*2, time.Millisecond*10)
+	require.True(t, hasMember(a, "127.0.0.1"))
+}
+
+func TestKubernetesLookupFailure(t *testing.T) {
+	service := newFakeService(t)
+	service.set(nil, "127.0.0.1", "127.0.0.2")
+	a := startKubernetesMember(t, service, "127.0.0.1")
+	defer func() { a.Stop().Wait() }()
+	b := startKubernetesMember(t, service, "127.0.0.2")
+	defer func() { b.Stop().Wait() }()
+	require.Eventually(t, func() bool {
+		return hasMember(a, "127.0.0.2") && hasMember(b, "127.0.0.1")
+	}, time.Second*2, time.Millisecond*10)
+
+	// The members are kept while the service cannot be resolved.
+	service.set(fmt.Errorf("no such host"))
+	time.Sleep(time.Millisecond * 100)
+	require.True(t, hasMember(a, "127.0.0.2"))
+	require.True(t, hasMember(b, "127.0.0.1"))
+}
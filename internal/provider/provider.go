@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/config"
+)
+
+const (
+	ProviderSelfManaged = "selfmanaged"
+	ProviderKubernetes  = "kubernetes"
+	ProviderConsul      = "consul"
+)
+
+type memberLeave struct {
+	host string
+}
+
+type refreshMembers struct{}
+
+// memberSet holds the members a provider knows of and keeps the agent of the
+// cluster up to date with them. The providers only differ in how they find
+// their peers, they embed it for the rest: the handshakes of the peers, the
+// members they share and the members that became unreachable.
+type memberSet struct {
+	cluster     *cluster.Cluster
+	members     *cluster.MemberSet
+	eventSubPID *actor.PID
+}
+
+func newMemberSet(c *cluster.Cluster) memberSet {
+	return memberSet{
+		cluster: c,
+		members: cluster.NewMemberSet(),
+	}
+}
+
+// start adds this member and watches for the members that become
+// unreachable.
+func (m *memberSet) start(c *actor.Context) {
+	m.members.Add(m.cluster.Member())
+	m.sendMembersToAgent()
+	m.eventSubPID = c.SpawnChildFunc(m.handleEventStream, "event")
+	m.cluster.Engine().Subscribe(m.eventSubPID)
+}
+
+func (m *memberSet) stop() {
+	m.cluster.Engine().Unsubscribe(m.eventSubPID)
+}
+
+// receive handles the messages of the peers and of the event stream.
+func (m *memberSet) receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case *cluster.Handshake:
+		m.addMembers(msg.Member)
+		m.cluster.Engine().Send(c.Sender(), &cluster.Members{
+			Members: m.members.Slice(),
+		})
+	case *cluster.Members:
+		m.addMembers(msg.Members...)
+	case memberLeave:
+		m.members.RemoveByHost(msg.host)
+		m.sendMembersToAgent()
+	case *actor.Ping:
+	default:
+		slog.Warn("received unhandled message", "msg", msg, "t", reflect.TypeOf(msg))
+	}
+}
+
+// handshake introduces this member to the provider of the peer with the
+// given id listening on host, unless the peer is this member or a member
+// already.
+func (m *memberSet) handshake(c *actor.Context, host, id string) {
+	if host == m.cluster.Member().Host || m.members.GetByHost(host) != nil {
+		return
+	}
+	peerPID := actor.NewPID(host, "provider/"+id)
+	m.cluster.Engine().SendWithSender(peerPID, &cluster.Handshake{
+		Member: m.cluster.Member(),
+	}, c.PID())
+}
+
+// retain removes the members, other than this one, that are not listening on
+// one of the given hosts.
+func (m *memberSet) retain(hosts map[string]bool) {
+	var left bool
+	for _, member := range m.members.Slice() {
+		if member.Host != m.cluster.Member().Host && !hosts[member.Host] {
+			m.members.Remove(member)
+			left = true
+		}
+	}
+	if left {
+		m.sendMembersToAgent()
+	}
+}
+
+func (m *memberSet) addMembers(members ...*cluster.Member) {
+	for _, member := range members {
+		if !m.members.Contains(member) {
+			m.members.Add(member)
+		}
+	}
+	m.sendMembersToAgent()
+}
+
+// send all the current members to the local cluster agent.
+func (m *memberSet) sendMembersToAgent() {
+	m.cluster.Engine().Send(m.cluster.PID(), &cluster.Members{
+		Members: m.members.Slice(),
+	})
+}
+
+func (m *memberSet) handleEventStream(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.RemoteUnreachableEvent:
+		c.Send(c.Parent(), memberLeave{host: msg.ListenAddr})
+	}
+}
+
+// New returns the cluster provider selected in the given configuration.
+func New(c config.Cluster) (cluster.Producer, error) {
+	switch c.Provider {
+	case ProviderSelfManaged, "":
+		selfManaged := cluster.NewSelfManagedConfig()
+		for _, member := range c.SelfManaged.Members {
+			id, addr, ok := strings.Cut(member, "@")
+			if !ok {
+				return nil, fmt.Errorf("invalid cluster member, expected id@host:port: %s", member)
+			}
+			selfManaged = selfManaged.WithBootstrapMember(cluster.MemberAddr{ID: id, ListenAddr: addr})
+		}
+		return cluster.NewSelfManagedProvider(selfManaged), nil
+	case ProviderKubernetes:
+		return NewKubernetesProvider(KubernetesConfig{
+			Service: c.Kubernetes.Service,
+			Port:    c.Kubernetes.Port,
+		}), nil
+	case ProviderConsul:
+		return NewConsulProvider(ConsulConfig{
+			Address: c.Consul.Address,
+			Service: c.Consul.Service,
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid cluster provider: %s", c.Provider)
+	}
+}
+
+// MemberID returns the id of the member of the given kind, ingress or
+// runtime. The id of the config, or the hostname, is suffixed with the kind
+// so the members of the same host have distinct ids.
+func MemberID(c config.Cluster, kind string) string {
+	id := c.ID
+	if len(id) == 0 {
+		id, _ = os.Hostname()
+	}
+	if len(id) == 0 {
+		return kind
+	}
+	return id + "-" + kind
+}
@@ -0,0 +1,201 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// ErrExceeded is returned when a project reached one of its quotas.
+var ErrExceeded = errors.New("quota exceeded")
+
+// refreshInterval is how long the invocation checks reuse the usage of a
+// project before it is loaded again.
+const refreshInterval = 30 * time.Second
+
+// Checker accounts the usage of the projects, the owners of the endpoints,
+// and checks it against their quotas. It is safe for concurrent use.
+type Checker struct {
+	store       storage.Store
+	metricStore storage.MetricStore
+	// Quotas by project, the quota without a project is the default.
+	quotas map[string]config.Quota
+
+	mu     sync.Mutex
+	cached map[string]cachedUsage
+}
+
+type cachedUsage struct {
+	usage    *types.Usage
+	loadedAT time.Time
+}
+
+// New returns a new checker of the given quotas.
+func New(quotas []config.Quota, store storage.Store, metricStore storage.MetricStore) *Checker {
+	c := &Checker{
+		store:       store,
+		metricStore: metricStore,
+		quotas:      make(map[string]config.Quota, len(quotas)),
+		cached:      make(map[string]cachedUsage),
+	}
+	for _, quota := range quotas {
+		c.quotas[quota.Project] = quota
+	}
+	return c
+}
+
+// Quota returns the quota of the project, the default quota when the
+// project has none of its own.
+func (c *Checker) Quota(project string) config.Quota {
+	quota, ok := c.quotas[project]
+	if !ok {
+		quota = c.quotas[""]
+	}
+	quota.Project = project
+	return quota
+}
+
+// Usage returns the consumption of the project in the current period.
+func (c *Checker) Usage(project string) (*types.Usage, error) {
+	return c.usage(project, true)
+}
+
+// Projects returns the projects that own endpoints, sorted by name.
+func (c *Checker) Projects() ([]string, error) {
+	endpoints, err := c.store.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	projects := []string{}
+	for _, endpoint := range endpoints {
+		if project := endpoint.Owner(); !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// CheckEndpoints returns an error if the project cannot create another
+// endpoint.
+func (c *Checker) CheckEndpoints(project string) error {
+	max := c.Quota(project).MaxEndpoints
+	if max == 0 {
+		return nil
+	}
+	usage, err := c.usage(project, false)
+	if err != nil {
+		return err
+	}
+	if usage.Endpoints >= max {
+		return fmt.Errorf("%w: project %q reached its maximum of %d endpoints", ErrExceeded, project, max)
+	}
+	return nil
+}
+
+// CheckDeployments returns an error if the project cannot create another
+// deployment.
+func (c *Checker) CheckDeployments(project string) error {
+	max := c.Quota(project).MaxDeployments
+	if max == 0 {
+		return nil
+	}
+	usage, err := c.usage(project, true)
+	if err != nil {
+		return err
+	}
+	if usage.Deployments >= max {
+		return fmt.Errorf("%w: project %q reached its maximum of %d deployments", ErrExceeded, project, max)
+	}
+	return nil
+}
+
+// CheckInvocations returns an error if the project used up its invocations
+// or its execution of the current period. The usage is refreshed at most
+// every refresh interval, the quotas can be exceeded by the invocations of
+// the interval.
+func (c *Checker) CheckInvocations(project string) error {
+	quota := c.Quota(project)
+	if quota.MaxInvocations == 0 && quota.MaxGBSeconds == 0 {
+		return nil
+	}
+	usage, err := c.cachedUsage(project)
+	if err != nil {
+		return err
+	}
+	if quota.MaxInvocations > 0 && usage.Invocations >= quota.MaxInvocations {
+		return fmt.Errorf("%w: project %q reached its maximum of %d invocations this month", ErrExceeded, project, quota.MaxInvocations)
+	}
+	if quota.MaxGBSeconds > 0 && usage.GBSeconds >= quota.MaxGBSeconds {
+		return fmt.Errorf("%w: project %q reached its maximum of %g GB-seconds this month", ErrExceeded, project, quota.MaxGBSeconds)
+	}
+	return nil
+}
+
+// cachedUsage returns the usage of the project loaded within the refresh
+// interval. The lock is not held while the usage is loaded, the checks of
+// the other projects do not wait for the store.
+func (c *Checker) cachedUsage(project string) (*types.Usage, error) {
+	c.mu.Lock()
+	cached, ok := c.cached[project]
+	c.mu.Unlock()
+	if ok && time.Since(cached.loadedAT) < refreshInterval {
+		return cached.usage, nil
+	}
+	usage, err := c.usage(project, false)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cached[project] = cachedUsage{usage: usage, loadedAT: time.Now()}
+	c.mu.Unlock()
+	return usage, nil
+}
+
+// usage returns the usage of the project, the deployments are only counted
+// when asked for since they are loaded per endpoint.
+func (c *Checker) usage(project string, deployments bool) (*types.Usage, error) {
+	endpoints, err := c.store.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	usage := &types.Usage{
+		Project: project,
+		Period:  types.UsagePeriod(time.Now()),
+	}
+	owned := make(map[uuid.UUID]bool)
+	for _, endpoint := range endpoints {
+		if endpoint.Owner() != project {
+			continue
+		}
+		owned[endpoint.ID] = true
+		usage.Endpoints++
+		if deployments {
+			deploys, err := c.store.GetDeployments(endpoint.ID)
+			if err != nil {
+				return nil, err
+			}
+			usage.Deployments += len(deploys)
+		}
+	}
+	records, err := c.metricStore.GetUsage(usage.Period)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if owned[record.EndpointID] {
+			usage.Invocations += record.Invocations
+			usage.GBSeconds += record.GBSeconds
+		}
+	}
+	return usage, nil
+}
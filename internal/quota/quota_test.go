@@ -0,0 +1,67 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	c := New([]config.Quota{
+		{MaxEndpoints: 5},
+		{Project: "payments", MaxEndpoints: 1},
+	}, nil, nil)
+	require.Equal(t, 1, c.Quota("payments").MaxEndpoints)
+	require.Equal(t, 5, c.Quota("search").MaxEndpoints)
+	require.Equal(t, "search", c.Quota("search").Project)
+	require.Equal(t, 0, New(nil, nil, nil).Quota("search").MaxEndpoints)
+}
+
+func TestCheck(t *testing.T) {
+	store := storage.NewMemoryStore()
+	c := New([]config.Quota{
+		{Project: "payments", MaxEndpoints: 1, MaxDeployments: 1, MaxInvocations: 2},
+	}, store, store)
+
+	endpoint := types.NewEndpoint("checkout", "go", nil)
+	endpoint.Ownership = &types.Ownership{Owner: "payments"}
+	require.Nil(t, c.CheckEndpoints("payments"))
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.True(t, errors.Is(c.CheckEndpoints("payments"), ErrExceeded))
+	require.Nil(t, c.CheckEndpoints("search"))
+
+	require.Nil(t, c.CheckDeployments("payments"))
+	require.Nil(t, store.CreateDeployment(types.NewDeployment(endpoint, []byte("a"))))
+	require.True(t, errors.Is(c.CheckDeployments("payments"), ErrExceeded))
+
+	metric := types.RequestMetric{
+		EndpointID:  endpoint.ID,
+		Duration:    time.Second * 2,
+		MemoryUsage: 1 << 29,
+		CreatedAT:   time.Now(),
+	}
+	record := types.NewUsageRecord(metric)
+	require.Nil(t, store.AddUsage(&record))
+	require.Nil(t, c.CheckInvocations("payments"))
+	require.Nil(t, store.AddUsage(&record))
+	// The usage of the previous check is reused until it is refreshed.
+	require.Nil(t, c.CheckInvocations("payments"))
+	c.cached = make(map[string]cachedUsage)
+	require.True(t, errors.Is(c.CheckInvocations("payments"), ErrExceeded))
+
+	usage, err := c.Usage("payments")
+	require.Nil(t, err)
+	require.Equal(t, 1, usage.Endpoints)
+	require.Equal(t, 1, usage.Deployments)
+	require.Equal(t, int64(2), usage.Invocations)
+	require.InDelta(t, 2.0, usage.GBSeconds, 0.001)
+
+	projects, err := c.Projects()
+	require.Nil(t, err)
+	require.Equal(t, []string{"payments"}, projects)
+}
@@ -0,0 +1,143 @@
+package recommend
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// MinSamples is the minimum amount of request metrics needed to make a
+	// recommendation.
+	MinSamples = 10
+
+	memoryHeadroom      = 1.25
+	concurrencyHeadroom = 1.25
+	timeoutHeadroom     = 2
+	minTimeout          = time.Second
+	memoryStep          = 1 << 20
+)
+
+var ErrNotEnoughSamples = errors.New("not enough request metrics to make a recommendation")
+
+// Recommendation holds the recommended settings for an endpoint based on
+// its historical usage.
+type Recommendation struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// The amount of requests the recommendation is based on.
+	Samples int `json:"samples"`
+	// The memory limit in bytes.
+	MemoryLimit uint64        `json:"memory_limit"`
+	Timeout     time.Duration `json:"timeout"`
+	// The amount of runtimes that should be kept warm at all times.
+	MinWarmInstances int `json:"min_warm_instances"`
+	MaxConcurrency   int `json:"max_concurrency"`
+	// The observed usage the recommendation is derived from.
+	P99Duration     time.Duration `json:"p99_duration"`
+	PeakMemory      uint32        `json:"peak_memory"`
+	PeakConcurrency int           `json:"peak_concurrency"`
+	AvgConcurrency  float64       `json:"avg_concurrency"`
+}
+
+// Analyze inspects the request metrics of an endpoint and returns the
+// recommended settings.
+func Analyze(endpointID uuid.UUID, metrics []types.RequestMetric) (Recommendation, error) {
+	if len(metrics) < MinSamples {
+		return Recommendation{}, ErrNotEnoughSamples
+	}
+	var (
+		durations = make([]time.Duration, len(metrics))
+		memory    = make([]uint32, len(metrics))
+	)
+	for i, metric := range metrics {
+		durations[i] = metric.Duration
+		memory[i] = metric.MemoryUsage
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	sort.Slice(memory, func(i, j int) bool { return memory[i] < memory[j] })
+
+	var (
+		p99Duration = durations[percentile(len(durations), 0.99)]
+		peakMemory  = memory[len(memory)-1]
+		peak, avg   = concurrency(metrics)
+	)
+	timeout := time.Duration(float64(p99Duration) * timeoutHeadroom)
+	timeout = timeout.Truncate(time.Second) + time.Second
+	if timeout < minTimeout {
+		timeout = minTimeout
+	}
+	memoryLimit := uint64(math.Ceil(float64(peakMemory)*memoryHeadroom/memoryStep)) * memoryStep
+
+	return Recommendation{
+		EndpointID:       endpointID,
+		Samples:          len(metrics),
+		MemoryLimit:      memoryLimit,
+		Timeout:          timeout,
+		MinWarmInstances: int(math.Round(avg)),
+		MaxConcurrency:   int(math.Ceil(float64(peak) * concurrencyHeadroom)),
+		P99Duration:      p99Duration,
+		PeakMemory:       peakMemory,
+		PeakConcurrency:  peak,
+		AvgConcurrency:   avg,
+	}, nil
+}
+
+// percentile returns the index of the given percentile in a sorted slice of
+// length n.
+func percentile(n int, p float64) int {
+	i := int(math.Ceil(float64(n)*p)) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// concurrency returns the peak and the average amount of requests that were
+// in flight at the same time during the observed period.
+func concurrency(metrics []types.RequestMetric) (int, float64) {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	var (
+		events = make([]event, 0, len(metrics)*2)
+		busy   time.Duration
+		first  = metrics[0].CreatedAT
+		last   = metrics[0].CreatedAT.Add(metrics[0].Duration)
+	)
+	for _, metric := range metrics {
+		end := metric.CreatedAT.Add(metric.Duration)
+		events = append(events, event{metric.CreatedAT, 1}, event{end, -1})
+		busy += metric.Duration
+		if metric.CreatedAT.Before(first) {
+			first = metric.CreatedAT
+		}
+		if end.After(last) {
+			last = end
+		}
+	}
+	// Ends sort before starts at the same instant so that back to back
+	// requests are not counted as concurrent.
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+	var inflight, peak int
+	for _, e := range events {
+		inflight += e.delta
+		if inflight > peak {
+			peak = inflight
+		}
+	}
+	window := last.Sub(first)
+	if window <= 0 {
+		return peak, float64(peak)
+	}
+	return peak, float64(busy) / float64(window)
+}
@@ -0,0 +1,54 @@
+package recommend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+func TestAnalyzeNotEnoughSamples(t *testing.T) {
+	_, err := Analyze(uuid.New(), make([]types.RequestMetric, MinSamples-1))
+	if err != ErrNotEnoughSamples {
+		t.Fatalf("expected %v got %v", ErrNotEnoughSamples, err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	var (
+		start   = time.Now()
+		metrics []types.RequestMetric
+	)
+	// 2 requests in flight at all times during 10 seconds.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 2; j++ {
+			metrics = append(metrics, types.RequestMetric{
+				Duration:    time.Second,
+				MemoryUsage: 2 << 20,
+				CreatedAT:   start.Add(time.Duration(i) * time.Second),
+			})
+		}
+	}
+	metrics[0].MemoryUsage = 4 << 20
+
+	rec, err := Analyze(uuid.New(), metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.PeakConcurrency != 2 {
+		t.Errorf("expected peak concurrency of 2 got %d", rec.PeakConcurrency)
+	}
+	if rec.MinWarmInstances != 2 {
+		t.Errorf("expected 2 min warm instances got %d", rec.MinWarmInstances)
+	}
+	if rec.MaxConcurrency != 3 {
+		t.Errorf("expected max concurrency of 3 got %d", rec.MaxConcurrency)
+	}
+	if rec.Timeout != time.Second*3 {
+		t.Errorf("expected timeout of 3s got %s", rec.Timeout)
+	}
+	if rec.MemoryLimit != 5<<20 {
+		t.Errorf("expected memory limit of 5MB got %d", rec.MemoryLimit)
+	}
+}
@@ -0,0 +1,134 @@
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// The minutes are rolled up once the request metrics written late by
+	// the members arrived.
+	compactionDelay = 30 * time.Second
+	// The first compaction covers the last day.
+	compactionBackfill = 24 * time.Hour
+)
+
+// Compactor rolls the request metrics up into the rollups of a minute as
+// the minutes end, and those into the rollups of an hour as the hours end.
+// The request metrics and the rollups older than their retention are
+// deleted. Rolling up a period again replaces its rollups, which makes the
+// compaction idempotent.
+type Compactor struct {
+	metricStore storage.MetricStore
+	retention   config.Metrics
+	// End of the last rolled up minute and hour.
+	minutes time.Time
+	hours   time.Time
+}
+
+// NewCompactor returns a new Compactor given the retention of the metrics.
+func NewCompactor(metricStore storage.MetricStore, retention config.Metrics) *Compactor {
+	watermark := time.Now().UTC().Truncate(time.Hour).Add(-compactionBackfill)
+	return &Compactor{
+		metricStore: metricStore,
+		retention:   retention,
+		minutes:     watermark,
+		hours:       watermark,
+	}
+}
+
+// Run compacts the metrics every minute until the context is done.
+func (c *Compactor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := c.compact(time.Now()); err != nil {
+			slog.Warn("failed to compact the metrics", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compact rolls up all the minutes and hours that ended before now and
+// deletes what is past its retention. The periods are retried on the next
+// run when they fail.
+func (c *Compactor) compact(now time.Time) error {
+	end := now.Add(-compactionDelay).UTC().Truncate(types.RollupMinute)
+	for c.minutes.Before(end) {
+		// At most an hour of request metrics is read at once.
+		to := c.minutes.Truncate(time.Hour).Add(time.Hour)
+		if to.After(end) {
+			to = end
+		}
+		metrics, err := c.metricStore.GetRequestMetricsBetween(c.minutes, to)
+		if err != nil {
+			return err
+		}
+		if err := c.store(types.RollupMetrics(types.RollupMinute, metrics)); err != nil {
+			return err
+		}
+		c.minutes = to
+	}
+	for end := c.minutes.Truncate(types.RollupHour); c.hours.Before(end); {
+		to := c.hours.Add(types.RollupHour)
+		minutes, err := c.metricStore.GetMetricRollups(uuid.Nil, types.RollupMinute, c.hours, to)
+		if err != nil {
+			return err
+		}
+		if err := c.store(types.MergeRollups(types.RollupHour, minutes)); err != nil {
+			return err
+		}
+		c.hours = to
+	}
+
+	// Only what was rolled up is deleted.
+	if retention := time.Duration(c.retention.Retention); retention > 0 {
+		deleted, err := c.metricStore.DeleteRequestMetrics(earliest(now.Add(-retention), c.minutes))
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			slog.Info("deleted request metrics past their retention", "count", deleted)
+		}
+		// The concurrency metrics are not rolled up, they are kept as long
+		// as the request metrics.
+		if _, err := c.metricStore.DeleteConcurrencyMetrics(now.Add(-retention)); err != nil {
+			return err
+		}
+	}
+	if retention := time.Duration(c.retention.MinuteRetention); retention > 0 {
+		if _, err := c.metricStore.DeleteMetricRollups(types.RollupMinute, earliest(now.Add(-retention), c.hours)); err != nil {
+			return err
+		}
+	}
+	if retention := time.Duration(c.retention.HourRetention); retention > 0 {
+		if _, err := c.metricStore.DeleteMetricRollups(types.RollupHour, now.Add(-retention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compactor) store(rollups []types.MetricRollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+	return c.metricStore.CreateMetricRollups(rollups)
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package rollup
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactor(t *testing.T) {
+	var (
+		store      = storage.NewMemoryStore()
+		endpointID = uuid.New()
+		now        = time.Date(2026, 10, 15, 11, 30, 0, 0, time.UTC)
+	)
+	for _, at := range []time.Time{now.Add(-time.Hour * 3), now.Add(-time.Hour), now.Add(-time.Hour).Add(time.Second), now.Add(-time.Second)} {
+		require.Nil(t, store.CreateRequestMetric(&types.RequestMetric{
+			ID:         uuid.New(),
+			EndpointID: endpointID,
+			StatusCode: http.StatusOK,
+			CreatedAT:  at,
+		}))
+	}
+
+	c := NewCompactor(store, config.Metrics{
+		Retention:       config.Duration(time.Hour * 2),
+		MinuteRetention: config.Duration(time.Hour * 2),
+	})
+	c.minutes = now.Add(-time.Hour * 4).Truncate(time.Hour)
+	c.hours = c.minutes
+	require.Nil(t, c.compact(now))
+
+	// The current minute is not rolled up yet, its request metric is kept.
+	require.Equal(t, now.Add(-time.Minute), c.minutes)
+	require.Equal(t, now.Truncate(time.Hour), c.hours)
+	minutes, err := store.GetMetricRollups(endpointID, types.RollupMinute, now.Add(-time.Hour*4), now)
+	require.Nil(t, err)
+	// The minute rollups past their retention were deleted.
+	require.Len(t, minutes, 1)
+	require.Equal(t, int64(2), minutes[0].Requests)
+	hours, err := store.GetMetricRollups(uuid.Nil, types.RollupHour, now.Add(-time.Hour*4), now)
+	require.Nil(t, err)
+	require.Len(t, hours, 2)
+	require.Equal(t, now.Add(-time.Hour*3).Truncate(time.Hour), hours[0].Start)
+	require.Equal(t, int64(1), hours[0].Requests)
+	require.Equal(t, int64(2), hours[1].Requests)
+
+	metrics, err := store.GetRequestMetrics(endpointID)
+	require.Nil(t, err)
+	require.Len(t, metrics, 3)
+
+	// Compacting again does not count the requests twice.
+	c.hours = c.hours.Add(-time.Hour)
+	require.Nil(t, c.compact(now))
+	hours, err = store.GetMetricRollups(endpointID, types.RollupHour, now.Add(-time.Hour*2), now)
+	require.Nil(t, err)
+	require.Len(t, hours, 1)
+	require.Equal(t, int64(2), hours[0].Requests)
+}
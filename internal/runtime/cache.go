@@ -0,0 +1,95 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Cache is the in-memory cache the guest memoizes values in across its
+// invocations with the cache_get, cache_set and cache_delete host
+// functions.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	// Set caches the value for the ttl, 0 keeps it until it is evicted.
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string)
+}
+
+type cacheKey struct{}
+
+// WithCache returns a context that gives the guest access to the given cache
+// when the module is invoked with it.
+func WithCache(ctx context.Context, cache Cache) context.Context {
+	return context.WithValue(ctx, cacheKey{}, cache)
+}
+
+// cacheGet writes the value cached under the key into the buffer of the
+// guest. It returns the size of the value, which is not written when it
+// exceeds the buffer.
+func (r *Runtime) cacheGet(ctx context.Context, m api.Module, key, keyLen, buf, bufLen uint32) int32 {
+	cache, ok := ctx.Value(cacheKey{}).(Cache)
+	if !ok {
+		return hostUnavailable
+	}
+	k, ok := m.Memory().Read(key, keyLen)
+	if !ok {
+		return hostFault
+	}
+	start := time.Now()
+	value, ok := cache.Get(string(k))
+	r.clock.wait(start)
+	if !ok {
+		return hostNotFound
+	}
+	if len(value) > int(bufLen) {
+		return int32(len(value))
+	}
+	if !m.Memory().Write(buf, value) {
+		return hostFault
+	}
+	return int32(len(value))
+}
+
+// cacheSet caches the value in the buffer of the guest under the key for ttl
+// milliseconds. It returns 0 on success.
+func (r *Runtime) cacheSet(ctx context.Context, m api.Module, key, keyLen, value, valueLen, ttl uint32) int32 {
+	cache, ok := ctx.Value(cacheKey{}).(Cache)
+	if !ok {
+		return hostUnavailable
+	}
+	k, ok := m.Memory().Read(key, keyLen)
+	if !ok {
+		return hostFault
+	}
+	b, ok := m.Memory().Read(value, valueLen)
+	if !ok {
+		return hostFault
+	}
+	v := make([]byte, len(b))
+	copy(v, b)
+	start := time.Now()
+	err := cache.Set(string(k), v, time.Duration(ttl)*time.Millisecond)
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	return 0
+}
+
+// cacheDelete removes the key from the cache. It returns 0 on success.
+func (r *Runtime) cacheDelete(ctx context.Context, m api.Module, key, keyLen uint32) int32 {
+	cache, ok := ctx.Value(cacheKey{}).(Cache)
+	if !ok {
+		return hostUnavailable
+	}
+	k, ok := m.Memory().Read(key, keyLen)
+	if !ok {
+		return hostFault
+	}
+	start := time.Now()
+	cache.Delete(string(k))
+	r.clock.wait(start)
+	return 0
+}
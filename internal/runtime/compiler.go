@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/google/uuid"
+	"github.com/tetratelabs/wazero"
+)
+
+// CompilerStats holds the counters of a Compiler.
+type CompilerStats struct {
+	// Number of compilations that actually hit the compiler.
+	Compiles int64 `json:"compiles"`
+	// Number of compilations that waited on an in-flight compilation
+	// of the same deployment instead of compiling themselves.
+	Coalesced int64 `json:"coalesced"`
+}
+
+type compileCall struct {
+	wg    sync.WaitGroup
+	cache wazero.CompilationCache
+	err   error
+}
+
+// Compiler compiles deployments into their compilation cache. Concurrent cold
+// starts of the same deployment are coalesced so the module is only compiled once.
+type Compiler struct {
+	mu    sync.Mutex
+	cache storage.ModCacher
+	calls map[uuid.UUID]*compileCall
+	// compile compiles the blob, replaced in the tests.
+	compile func(context.Context, []byte) (wazero.CompilationCache, error)
+
+	compiles  atomic.Int64
+	coalesced atomic.Int64
+}
+
+// NewCompiler returns a new Compiler that stores its results in the given cache.
+func NewCompiler(cache storage.ModCacher) *Compiler {
+	return &Compiler{
+		cache:   cache,
+		calls:   make(map[uuid.UUID]*compileCall),
+		compile: compile,
+	}
+}
+
+// Compile returns the compilation cache of the given deployment. When the
+// deployment is not cached yet the blob is compiled. If a compilation of the
+// same deployment is already in flight, Compile waits for its result and
+// reports the call as coalesced. The compilation is shared by the callers, it
+// is not canceled along with the context of the caller that started it.
+func (c *Compiler) Compile(ctx context.Context, id uuid.UUID, blob []byte) (cache wazero.CompilationCache, coalesced bool, err error) {
+	if cache, ok := c.cache.Get(id); ok {
+		return cache, false, nil
+	}
+
+	c.mu.Lock()
+	// The in-flight compilation may have finished since the cache was
+	// checked, its result is cached before the call is removed.
+	if cache, ok := c.cache.Get(id); ok {
+		c.mu.Unlock()
+		return cache, false, nil
+	}
+	if call, ok := c.calls[id]; ok {
+		c.mu.Unlock()
+		c.coalesced.Add(1)
+		call.wg.Wait()
+		return call.cache, true, call.err
+	}
+	call := &compileCall{}
+	call.wg.Add(1)
+	c.calls[id] = call
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, id)
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	c.compiles.Add(1)
+	call.cache, call.err = c.compile(context.WithoutCancel(ctx), blob)
+	if call.err == nil {
+		c.cache.Put(id, call.cache)
+	}
+	return call.cache, false, call.err
+}
+
+// Stats returns the current counters of the compiler.
+func (c *Compiler) Stats() CompilerStats {
+	return CompilerStats{
+		Compiles:  c.compiles.Load(),
+		Coalesced: c.coalesced.Load(),
+	}
+}
+
+func compile(ctx context.Context, blob []byte) (wazero.CompilationCache, error) {
+	if IsComponent(blob) {
+		return nil, ErrComponentNotSupported
+	}
+	cache := wazero.NewCompilationCache()
+	config := wazero.NewRuntimeConfigCompiler().WithCompilationCache(cache)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	// Closing the runtime will not release the compiled module because
+	// the engine is owned by the compilation cache.
+	defer runtime.Close(ctx)
+
+	if _, err := runtime.CompileModule(ctx, blob); err != nil {
+		return nil, fmt.Errorf("runtime failed to compile module: %s", err)
+	}
+	return cache, nil
+}
@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrComponentNotSupported is returned when a WASI 0.2 component is deployed.
+// The underlying engine (wazero) only executes core modules, so components
+// need to be built for WASI preview 1 (e.g. cargo build --target wasm32-wasip1)
+// until the engine supports the component model.
+var ErrComponentNotSupported = errors.New("WASI 0.2 components are not supported yet, deploy a WASI preview 1 module instead")
+
+var wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// IsComponent returns true if the given binary is a component of the
+// component model instead of a core module. Both share the same magic, but
+// components have layer 1 in the preamble right after the version.
+func IsComponent(b []byte) bool {
+	if len(b) < 8 || !bytes.Equal(b[:4], wasmMagic) {
+		return false
+	}
+	return b[6] == 0x01 && b[7] == 0x00
+}
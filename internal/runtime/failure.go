@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmStackTrace separates the message of a trap from its stack trace in
+// the errors of wazero.
+const wasmStackTrace = "\nwasm stack trace:\n"
+
+// DescribeError returns the invocation error of the error an invocation
+// failed with, given the last lines the guest wrote to stderr. The IDs and
+// the time of the error are left to the caller.
+func DescribeError(err error, stderr []string) types.InvocationError {
+	e := types.InvocationError{Stderr: stderr}
+	var exitErr *sys.ExitError
+	message, trace, isTrap := strings.Cut(err.Error(), wasmStackTrace)
+	switch {
+	case errors.As(err, &exitErr):
+		e.Kind = types.ErrorKindExit
+		e.ExitCode = int(exitErr.ExitCode())
+		e.Reason, e.Stack = panicOf(stderr)
+		if len(e.Reason) == 0 {
+			e.Reason = fmt.Sprintf("exit code %d", e.ExitCode)
+		}
+	case isTrap:
+		e.Kind = types.ErrorKindTrap
+		e.Reason = strings.TrimPrefix(message, "wasm error: ")
+		e.Stack = trapFrames(trace)
+	default:
+		e.Kind = types.ErrorKindInstantiate
+		e.Reason = err.Error()
+	}
+	e.Signature = types.ErrorSignature(e.Kind, e.Reason, e.Stack)
+	return e
+}
+
+// trapFrames returns the functions of the wasm stack trace of a trap,
+// without their source lines.
+func trapFrames(trace string) []string {
+	var frames []string
+	for _, line := range strings.Split(trace, "\n") {
+		if len(line) == 0 {
+			// The Go stack trace of a crash of the host follows.
+			break
+		}
+		if strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		frames = append(frames, strings.TrimSpace(line))
+	}
+	return frames
+}
+
+// panicOf returns the panic of a Go guest with the functions of the stack
+// of the panicking goroutine, or nothing when the guest did not panic.
+func panicOf(stderr []string) (string, []string) {
+	var (
+		reason string
+		frames []string
+	)
+	for i, line := range stderr {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			reason = line
+			frames = nil
+			continue
+		}
+		if len(reason) == 0 || !strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		for _, frame := range stderr[i+1:] {
+			if len(frame) == 0 {
+				break
+			}
+			if strings.HasPrefix(frame, "\t") {
+				continue
+			}
+			// The arguments of the frames change between the invocations.
+			name, _, _ := strings.Cut(frame, "(")
+			frames = append(frames, name)
+		}
+		break
+	}
+	return reason, frames
+}
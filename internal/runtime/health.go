@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// HealthExport is the name of the optional function guests export to report
+// their health. It takes no arguments and returns 0 when the guest is
+// healthy, any other value is an application defined failure code.
+const HealthExport = "raptor_health"
+
+// ErrNoHealthCheck is returned by Health when the guest does not export a
+// health function.
+var ErrNoHealthCheck = errors.New("guest does not export a health function")
+
+// HasHealthCheck returns true if the guest exports a health function.
+func (r *Runtime) HasHealthCheck() bool {
+	_, ok := r.mod.ExportedFunctions()[HealthExport]
+	return ok
+}
+
+// Health calls the health function of the guest with the given environment.
+// The guest is instantiated without running its main function, reactor
+// modules are initialized first.
+func (r *Runtime) Health(ctx context.Context, env map[string]string) error {
+	if !r.HasHealthCheck() {
+		return ErrNoHealthCheck
+	}
+	start := []string{}
+	if _, ok := r.mod.ExportedFunctions()["_initialize"]; ok {
+		start = append(start, "_initialize")
+	}
+	modConf := wazero.NewModuleConfig().
+		WithStdout(io.Discard).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithStartFunctions(start...)
+	for k, v := range env {
+		modConf = modConf.WithEnv(k, v)
+	}
+	mod, err := r.runtime.InstantiateModule(ctx, r.mod, modConf)
+	if err != nil {
+		return err
+	}
+	defer mod.Close(ctx)
+	results, err := mod.ExportedFunction(HealthExport).Call(ctx)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 && int32(results[0]) != 0 {
+		return fmt.Errorf("health function returned %d", int32(results[0]))
+	}
+	return nil
+}
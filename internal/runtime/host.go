@@ -0,0 +1,85 @@
+package runtime
+
+import "context"
+
+// HostModule is the name of the module the host functions of the runtime
+// are imported from by the guests.
+const HostModule = "raptor"
+
+// Return values of the host functions.
+const (
+	// The connection or the stream is closed.
+	hostClosed = -1
+	// The invocation has no connection, stream, progress reporter, context,
+	// deadline, cache, invoker or workflow.
+	hostUnavailable = -2
+	// The guest passed a buffer outside of its memory.
+	hostFault = -3
+	// The runtime refused the operation.
+	hostRejected = -4
+	// The key is not cached.
+	hostNotFound = -5
+)
+
+// instantiateHostModule instantiates the host functions of the runtime.
+// Guests that do not import them are not affected.
+func (r *Runtime) instantiateHostModule(ctx context.Context) error {
+	_, err := r.runtime.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().
+		WithFunc(r.wsRead).
+		WithParameterNames("buf", "buf_len").
+		Export("ws_read").
+		NewFunctionBuilder().
+		WithFunc(r.wsWrite).
+		WithParameterNames("buf", "buf_len").
+		Export("ws_write").
+		NewFunctionBuilder().
+		WithFunc(r.streamStart).
+		WithParameterNames("status", "header", "header_len").
+		Export("stream_start").
+		NewFunctionBuilder().
+		WithFunc(r.streamWrite).
+		WithParameterNames("buf", "buf_len").
+		Export("stream_write").
+		NewFunctionBuilder().
+		WithFunc(r.progress).
+		WithParameterNames("percent", "message", "message_len").
+		Export("progress").
+		NewFunctionBuilder().
+		WithFunc(r.invocationContext).
+		WithParameterNames("buf", "buf_len").
+		Export("invocation_context").
+		NewFunctionBuilder().
+		WithFunc(r.remainingTime).
+		Export("remaining_time").
+		NewFunctionBuilder().
+		WithFunc(r.cacheGet).
+		WithParameterNames("key", "key_len", "buf", "buf_len").
+		Export("cache_get").
+		NewFunctionBuilder().
+		WithFunc(r.cacheSet).
+		WithParameterNames("key", "key_len", "value", "value_len", "ttl").
+		Export("cache_set").
+		NewFunctionBuilder().
+		WithFunc(r.cacheDelete).
+		WithParameterNames("key", "key_len").
+		Export("cache_delete").
+		NewFunctionBuilder().
+		WithFunc(r.invoke).
+		WithParameterNames("endpoint", "endpoint_len", "req", "req_len", "buf", "buf_len").
+		Export("invoke").
+		NewFunctionBuilder().
+		WithFunc(r.stateGet).
+		WithParameterNames("buf", "buf_len").
+		Export("state_get").
+		NewFunctionBuilder().
+		WithFunc(r.stateSet).
+		WithParameterNames("buf", "buf_len").
+		Export("state_set").
+		NewFunctionBuilder().
+		WithFunc(r.workflowSleep).
+		WithParameterNames("ms").
+		Export("workflow_sleep").
+		Instantiate(ctx)
+	return err
+}
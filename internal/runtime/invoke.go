@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// invocationIDSize is the size of the id of an invocation, a UUID.
+const invocationIDSize = 36
+
+// Invoker queues the asynchronous invocations of other endpoints the guest
+// requests with the invoke host function.
+type Invoker interface {
+	// Invoke queues the request, encoded as JSON, to the LIVE deployment of
+	// the endpoint and returns the id of the invocation. The request is not
+	// retained past the call.
+	Invoke(endpointID string, req []byte) (string, error)
+}
+
+type invokerKey struct{}
+
+// WithInvoker returns a context that lets the guest invoke other endpoints
+// when the module is invoked with it.
+func WithInvoker(ctx context.Context, invoker Invoker) context.Context {
+	return context.WithValue(ctx, invokerKey{}, invoker)
+}
+
+// invoke queues the request in the buffer of the guest to the endpoint and
+// writes the id of the invocation into the buffer of the id. Nothing is
+// queued when the buffer is smaller than the id, its size is returned
+// instead.
+func (r *Runtime) invoke(ctx context.Context, m api.Module, endpoint, endpointLen, req, reqLen, buf, bufLen uint32) int32 {
+	invoker, ok := ctx.Value(invokerKey{}).(Invoker)
+	if !ok {
+		return hostUnavailable
+	}
+	if bufLen < invocationIDSize {
+		return invocationIDSize
+	}
+	id, ok := m.Memory().Read(endpoint, endpointLen)
+	if !ok {
+		return hostFault
+	}
+	b, ok := m.Memory().Read(req, reqLen)
+	if !ok {
+		return hostFault
+	}
+	start := time.Now()
+	invocationID, err := invoker.Invoke(string(id), b)
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	if !m.Memory().Write(buf, []byte(invocationID)) {
+		return hostFault
+	}
+	return int32(len(invocationID))
+}
@@ -0,0 +1,62 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Invocation is the platform context of an invocation the guest reads with
+// the invocation_context host function, encoded as JSON.
+type Invocation struct {
+	RequestID    string `json:"request_id"`
+	DeploymentID string `json:"deployment_id"`
+	EndpointID   string `json:"endpoint_id"`
+	// Region of the member running the invocation, empty when the member
+	// has none.
+	Region string `json:"region,omitempty"`
+	// Whether the invocation instantiated its runtime.
+	ColdStart bool `json:"cold_start"`
+	Preview   bool `json:"preview,omitempty"`
+}
+
+type invocationKey struct{}
+
+// WithInvocation returns a context that exposes the platform context of the
+// invocation to the guest when the module is invoked with it.
+func WithInvocation(ctx context.Context, invocation Invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, invocation)
+}
+
+// invocationContext writes the platform context of the invocation into the
+// buffer of the guest. It returns the size of the context, which is not
+// written when it exceeds the buffer.
+func (r *Runtime) invocationContext(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	invocation, ok := ctx.Value(invocationKey{}).(Invocation)
+	if !ok {
+		return hostUnavailable
+	}
+	b, err := json.Marshal(invocation)
+	if err != nil {
+		return hostRejected
+	}
+	if len(b) > int(bufLen) {
+		return int32(len(b))
+	}
+	if !m.Memory().Write(buf, b) {
+		return hostFault
+	}
+	return int32(len(b))
+}
+
+// remainingTime returns the milliseconds left until the invocation times
+// out, 0 once it did.
+func (r *Runtime) remainingTime(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return hostUnavailable
+	}
+	return max(time.Until(deadline).Milliseconds(), 0)
+}
@@ -0,0 +1,47 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Maximum size in bytes of a progress message, longer messages are
+// truncated.
+const maxProgressMessageSize = 1024
+
+// ProgressReporter receives the progress the guest reports with the progress
+// host function during long running invocations.
+type ProgressReporter interface {
+	Report(percent int, message string)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports the progress of the guest to
+// the given reporter when the module is invoked with it.
+func WithProgress(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// progress reports the progress of the invocation in percent along with a
+// message. It returns 0 on success.
+func (r *Runtime) progress(ctx context.Context, m api.Module, percent, message, messageLen uint32) int32 {
+	reporter, ok := ctx.Value(progressKey{}).(ProgressReporter)
+	if !ok {
+		return hostUnavailable
+	}
+	if percent > 100 {
+		return hostRejected
+	}
+	b, ok := m.Memory().Read(message, min(messageLen, maxProgressMessageSize))
+	if !ok {
+		return hostFault
+	}
+	// The reporter may store the progress.
+	start := time.Now()
+	reporter.Report(int(percent), string(b))
+	r.clock.wait(start)
+	return 0
+}
@@ -5,40 +5,147 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 type Args struct {
-	Stdout       io.Writer
+	Stdout io.Writer
+	// Stderr of the guest, the stderr of the process when nil.
+	Stderr       io.Writer
 	DeploymentID uuid.UUID
 	Engine       string
 	Blob         []byte
 	Cache        wazero.CompilationCache
+	// Quota in bytes of the scratch directory every invocation gets at
+	// ScratchPath, 0 disables the directory.
+	ScratchSize int64
+}
+
+// TimeBudget holds how the wall time of an invocation was spent.
+type TimeBudget struct {
+	// Total wall time of the invocation.
+	Wall time.Duration
+	// Time the guest spent blocked on the WASI clock, sleeping or polling
+	// for external I/O, on its stdin and stdout and in the host functions
+	// that wait on the client, the cache or the store.
+	Wait time.Duration
+	// Time the guest spent computing (Wall - Wait).
+	Compute time.Duration
+}
+
+// clock virtualizes the WASI clocks of the guest so we can account for the
+// time the guest spends blocked.
+type clock struct {
+	ctx     context.Context
+	start   time.Time
+	blocked time.Duration
+}
+
+func (c *clock) reset(ctx context.Context) {
+	c.ctx = ctx
+	c.start = time.Now()
+	c.blocked = 0
+}
+
+func (c *clock) nanotime() int64 {
+	return time.Since(c.start).Nanoseconds()
+}
+
+func (c *clock) nanosleep(ns int64) {
+	start := time.Now()
+	timer := time.NewTimer(time.Duration(ns))
+	defer timer.Stop()
+	// Sleeping guests are interrupted when the invocation times out.
+	select {
+	case <-timer.C:
+	case <-c.ctx.Done():
+	}
+	c.wait(start)
+}
+
+// wait accounts the time since start as blocked, the host functions call it
+// once the client, the cache or the store they waited on answered.
+func (c *clock) wait(start time.Time) {
+	c.blocked += time.Since(start)
+}
+
+// blockingReader accounts the time the guest waits on its stdin as blocked.
+type blockingReader struct {
+	r     io.Reader
+	clock *clock
+}
+
+func (b blockingReader) Read(p []byte) (int, error) {
+	defer b.clock.wait(time.Now())
+	return b.r.Read(p)
+}
+
+// blockingWriter accounts the time the guest waits on its stdout or stderr
+// as blocked.
+type blockingWriter struct {
+	w     io.Writer
+	clock *clock
+}
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	defer b.clock.wait(time.Now())
+	return b.w.Write(p)
+}
+
+func (c *clock) budget() TimeBudget {
+	wall := time.Since(c.start)
+	return TimeBudget{
+		Wall:    wall,
+		Wait:    c.blocked,
+		Compute: wall - c.blocked,
+	}
 }
 
 type Runtime struct {
 	stdout       io.Writer
+	stderr       io.Writer
 	ctx          context.Context
 	deploymentID uuid.UUID
 	engine       string
 	blob         []byte
 	mod          wazero.CompiledModule
 	runtime      wazero.Runtime
+	clock        *clock
+	lastBudget   TimeBudget
+	lastMemory   uint32
+	scratchSize  int64
 }
 
 func New(ctx context.Context, args Args) (*Runtime, error) {
-	config := wazero.NewRuntimeConfigCompiler().WithCompilationCache(args.Cache)
+	if IsComponent(args.Blob) {
+		return nil, ErrComponentNotSupported
+	}
+	config := wazero.NewRuntimeConfigCompiler().
+		WithCompilationCache(args.Cache).
+		// Interrupt the guest when the context of the invocation is done.
+		WithCloseOnContextDone(true)
 	r := &Runtime{
 		runtime:      wazero.NewRuntimeWithConfig(ctx, config),
 		ctx:          ctx,
 		deploymentID: args.DeploymentID,
 		engine:       args.Engine,
 		stdout:       args.Stdout,
+		stderr:       args.Stderr,
+		scratchSize:  args.ScratchSize,
+		clock:        &clock{},
+	}
+	if r.stderr == nil {
+		r.stderr = os.Stderr
 	}
 	wasi_snapshot_preview1.MustInstantiate(ctx, r.runtime)
+	if err := r.instantiateHostModule(ctx); err != nil {
+		return nil, fmt.Errorf("runtime failed to instantiate host module: %s", err)
+	}
 
 	mod, err := r.runtime.CompileModule(ctx, args.Blob)
 	if err != nil {
@@ -50,18 +157,54 @@ func New(ctx context.Context, args Args) (*Runtime, error) {
 }
 
 func (r *Runtime) Invoke(stdin io.Reader, env map[string]string, args ...string) error {
+	return r.InvokeContext(r.ctx, stdin, env, args...)
+}
+
+// InvokeContext invokes the module like Invoke. The guest is interrupted
+// when the given context is done, like when its deadline is exceeded.
+func (r *Runtime) InvokeContext(ctx context.Context, stdin io.Reader, env map[string]string, args ...string) error {
 	modConf := wazero.NewModuleConfig().
-		WithStdin(stdin).
-		WithStdout(r.stdout).
-		WithStderr(os.Stderr).
-		WithArgs(args...)
+		WithStdin(blockingReader{r: stdin, clock: r.clock}).
+		WithStdout(blockingWriter{w: r.stdout, clock: r.clock}).
+		WithStderr(blockingWriter{w: r.stderr, clock: r.clock}).
+		WithArgs(args...).
+		WithSysWalltime().
+		WithNanotime(r.clock.nanotime, sys.ClockResolution(time.Microsecond.Nanoseconds())).
+		WithNanosleep(r.clock.nanosleep)
 	for k, v := range env {
 		modConf = modConf.WithEnv(k, v)
 	}
-	_, err := r.runtime.InstantiateModule(r.ctx, r.mod, modConf)
+	// The scratch directory is wiped after every invocation.
+	if r.scratchSize > 0 {
+		dir, fsConfig, err := newScratchDir(r.scratchSize)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		modConf = modConf.WithFSConfig(fsConfig)
+	}
+	r.clock.reset(ctx)
+	mod, err := r.runtime.InstantiateModule(ctx, r.mod, modConf)
+	r.lastBudget = r.clock.budget()
+	// Linear memory can only grow, hence its size after the invocation is
+	// the peak memory usage of the guest.
+	r.lastMemory = 0
+	if mod != nil && mod.Memory() != nil {
+		r.lastMemory = mod.Memory().Size()
+	}
 	return err
 }
 
+// MemoryUsage returns the peak memory usage in bytes of the last invocation.
+func (r *Runtime) MemoryUsage() uint32 {
+	return r.lastMemory
+}
+
+// TimeBudget returns how the time of the last invocation was spent.
+func (r *Runtime) TimeBudget() TimeBudget {
+	return r.lastBudget
+}
+
 func (r *Runtime) Close() error {
 	return r.runtime.Close(r.ctx)
 }
@@ -3,16 +3,25 @@ package runtime
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/anthdm/raptor/internal/guestcache"
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/spidermonkey"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/proto"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
 	pb "google.golang.org/protobuf/proto"
 )
 
@@ -78,3 +87,671 @@ func TestRuntimeInvokeGoCode(t *testing.T) {
 	require.Equal(t, "Hello world!", string(res))
 	require.Nil(t, r.Close())
 }
+
+func TestCompilerCoalescesConcurrentCompiles(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+
+	var (
+		n        = 10
+		id       = uuid.New()
+		compiler = NewCompiler(storage.NewDefaultModCache())
+		wg       sync.WaitGroup
+		start    = make(chan struct{})
+		caches   = make([]wazero.CompilationCache, n)
+	)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			cache, _, err := compiler.Compile(context.Background(), id, b)
+			require.Nil(t, err)
+			caches[i] = cache
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	stats := compiler.Stats()
+	require.Equal(t, int64(1), stats.Compiles)
+	require.Equal(t, int64(n-1), stats.Coalesced)
+	for _, cache := range caches {
+		require.Equal(t, caches[0], cache)
+	}
+
+	// Once compiled the cache is served without coalescing.
+	_, coalesced, err := compiler.Compile(context.Background(), id, b)
+	require.Nil(t, err)
+	require.False(t, coalesced)
+	require.Equal(t, int64(1), compiler.Stats().Compiles)
+}
+
+func TestCompilerIgnoresCanceledCaller(t *testing.T) {
+	var (
+		id       = uuid.New()
+		compiler = NewCompiler(storage.NewDefaultModCache())
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	// The compilation fails when its context is canceled.
+	compiler.compile = func(ctx context.Context, blob []byte) (wazero.CompilationCache, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return wazero.NewCompilationCache(), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, _, err := compiler.Compile(ctx, id, nil)
+		leader <- err
+	}()
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, coalesced, err := compiler.Compile(context.Background(), id, nil)
+		require.True(t, coalesced)
+		waiter <- err
+	}()
+	require.Eventually(t, func() bool {
+		return compiler.Stats().Coalesced == 1
+	}, time.Second, time.Millisecond)
+
+	// The caller that started the compilation gives up, like a health check
+	// that timed out, the waiting caller still gets the module.
+	cancel()
+	close(release)
+	require.Nil(t, <-leader)
+	require.Nil(t, <-waiter)
+	compiler.mu.Lock()
+	require.Empty(t, compiler.calls)
+	compiler.mu.Unlock()
+}
+
+func TestClockTimeBudget(t *testing.T) {
+	c := &clock{}
+	c.reset(context.Background())
+	c.nanosleep(int64(time.Millisecond * 10))
+	budget := c.budget()
+	require.GreaterOrEqual(t, budget.Wait, time.Millisecond*10)
+	require.GreaterOrEqual(t, budget.Wall, budget.Wait)
+	require.Equal(t, budget.Wall-budget.Wait, budget.Compute)
+}
+
+func TestRuntimeInvokeJSShim(t *testing.T) {
+	req := &proto.HTTPRequest{
+		Method: "post",
+		URL:    "/hello",
+		Body:   []byte("héllo"),
+	}
+	breq, err := pb.Marshal(req)
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         spidermonkey.WasmBlob,
+		Engine:       "js",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	script, err := spidermonkey.Script([]byte(`respond(request.method + " " + request.url + " " + request.body, 201)`), req)
+	require.Nil(t, err)
+	require.Nil(t, r.Invoke(bytes.NewReader(breq), nil, "", "-e", script))
+
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "post /hello héllo", string(res))
+	require.Nil(t, r.Close())
+}
+
+func TestRuntimeInvokeTimeout(t *testing.T) {
+	req := &proto.HTTPRequest{
+		Method: "get",
+		URL:    "/",
+	}
+	breq, err := pb.Marshal(req)
+	require.Nil(t, err)
+
+	args := Args{
+		Stdout:       &bytes.Buffer{},
+		DeploymentID: uuid.New(),
+		Blob:         spidermonkey.WasmBlob,
+		Engine:       "js",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	script, err := spidermonkey.Script([]byte(`while (true) {}`), req)
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	require.NotNil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil, "", "-e", script))
+	require.Less(t, time.Since(start), time.Second*5)
+	require.Nil(t, r.Close())
+}
+
+// chanSocket is a socket whose messages are exchanged over channels.
+type chanSocket struct {
+	in  chan []byte
+	out chan []byte
+}
+
+func (s *chanSocket) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case msg, ok := <-s.in:
+		if !ok {
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *chanSocket) Write(ctx context.Context, b []byte) error {
+	s.out <- b
+	return nil
+}
+
+func TestRuntimeInvokeWebSocket(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/websocket.wasm")
+	require.Nil(t, err)
+
+	req := &proto.HTTPRequest{
+		Method: "get",
+		URL:    "/chat",
+	}
+	breq, err := pb.Marshal(req)
+	require.Nil(t, err)
+
+	args := Args{
+		Stdout:       &bytes.Buffer{},
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	socket := &chanSocket{
+		in:  make(chan []byte, 2),
+		out: make(chan []byte, 2),
+	}
+	// The second message does not fit the initial read buffer of the SDK.
+	large := bytes.Repeat([]byte("a"), 10000)
+	socket.in <- []byte("hello")
+	socket.in <- large
+	close(socket.in)
+	ctx := WithSocket(context.Background(), socket)
+	require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+	require.Equal(t, "/chat: hello", string(<-socket.out))
+	require.Equal(t, "/chat: "+string(large), string(<-socket.out))
+	require.Nil(t, r.Close())
+}
+
+// recordStream records the response streamed by the guest.
+type recordStream struct {
+	status int
+	header []byte
+	chunks []string
+}
+
+func (s *recordStream) Start(status int, header []byte) error {
+	s.status = status
+	s.header = header
+	return nil
+}
+
+func (s *recordStream) Write(b []byte) error {
+	s.chunks = append(s.chunks, string(b))
+	return nil
+}
+
+func TestRuntimeInvokeStream(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/sse.wasm")
+	require.Nil(t, err)
+
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "get", URL: "/events"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	stream := &recordStream{}
+	ctx := WithStream(context.Background(), stream)
+	require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+	require.Equal(t, http.StatusOK, stream.status)
+	require.Equal(t, "Content-Type: text/event-stream\r\n", string(stream.header))
+	require.Equal(t, []string{"data: 0\n\n", "data: 1\n\n", "data: 2\n\n"}, stream.chunks)
+
+	// The response on stdout only ends the stream.
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, res)
+
+	// Without a stream the guest buffers its response.
+	out.Reset()
+	require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+	_, res, header, status, err := shared.ParseStdoutWithHeader(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "text/event-stream", header.Get("Content-Type"))
+	require.Equal(t, "data: 0\n\ndata: 1\n\ndata: 2\n\n", string(res))
+	require.Nil(t, r.Close())
+}
+
+type progressFunc func(percent int, message string)
+
+func (f progressFunc) Report(percent int, message string) {
+	f(percent, message)
+}
+
+func TestRuntimeInvokeProgress(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/progress.wasm")
+	require.Nil(t, err)
+
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "get", URL: "/"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	var reports []string
+	ctx := WithProgress(context.Background(), progressFunc(func(percent int, message string) {
+		reports = append(reports, fmt.Sprintf("%d %s", percent, message))
+	}))
+	require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+	require.Equal(t, []string{"50 halfway", "100 done"}, reports)
+
+	// Guests without a reporter still respond.
+	out.Reset()
+	require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok", string(res))
+	require.Nil(t, r.Close())
+}
+
+func TestRuntimeInvocationContext(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/platform.wasm")
+	require.Nil(t, err)
+
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "get", URL: "/"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+	defer r.Close()
+
+	invocation := Invocation{
+		RequestID:    uuid.NewString(),
+		DeploymentID: args.DeploymentID.String(),
+		EndpointID:   uuid.NewString(),
+		Region:       "eu-west",
+		ColdStart:    true,
+	}
+	ctx, cancel := context.WithTimeout(WithInvocation(context.Background(), invocation), time.Minute)
+	defer cancel()
+	require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	var resp struct {
+		Invocation Invocation `json:"invocation"`
+		Remaining  int64      `json:"remaining"`
+	}
+	require.Nil(t, json.Unmarshal(res, &resp))
+	require.Equal(t, invocation, resp.Invocation)
+	require.Greater(t, resp.Remaining, int64(time.Second*50/time.Millisecond))
+
+	// Invocations without a context are told so.
+	out.Reset()
+	require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+	_, res, status, err = shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "raptor: no invocation context", string(res))
+}
+
+func TestRuntimeCache(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/cache.wasm")
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+	defer r.Close()
+
+	cache := guestcache.New(1024)
+	invoke := func(method string, scope Cache) (int, string) {
+		breq, err := pb.Marshal(&proto.HTTPRequest{Method: method, URL: "/"})
+		require.Nil(t, err)
+		ctx := context.Background()
+		if scope != nil {
+			ctx = WithCache(ctx, scope)
+		}
+		out.Reset()
+		require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		return status, string(res)
+	}
+
+	a := cache.Scope("a", 0)
+	for _, count := range []string{"1", "2", "3"} {
+		status, res := invoke("GET", a)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, count, res)
+	}
+	// The scopes do not share their keys.
+	_, res := invoke("GET", cache.Scope("b", 0))
+	require.Equal(t, "1", res)
+
+	status, _ := invoke("DELETE", a)
+	require.Equal(t, http.StatusOK, status)
+	_, res = invoke("GET", a)
+	require.Equal(t, "1", res)
+
+	// Values that exceed the quota are rejected.
+	status, res = invoke("GET", cache.Scope("c", 4))
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "raptor: cache rejected the value", res)
+
+	// Invocations without a cache are told so.
+	status, res = invoke("GET", nil)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "raptor: no cache", res)
+}
+
+// slowCache is a cache that takes its time to answer, like a remote one.
+type slowCache struct {
+	Cache
+	delay time.Duration
+}
+
+func (c slowCache) Get(key string) ([]byte, bool) {
+	time.Sleep(c.delay)
+	return c.Cache.Get(key)
+}
+
+func (c slowCache) Set(key string, value []byte, ttl time.Duration) error {
+	time.Sleep(c.delay)
+	return c.Cache.Set(key, value, ttl)
+}
+
+func TestRuntimeTimeBudgetHostWait(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/cache.wasm")
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	r, err := New(context.Background(), Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	})
+	require.Nil(t, err)
+	defer r.Close()
+
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+	delay := time.Millisecond * 50
+	ctx := WithCache(context.Background(), slowCache{Cache: guestcache.New(1024).Scope("a", 0), delay: delay})
+	require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status, string(res))
+
+	// The guest waited on the cache twice, which is not compute.
+	budget := r.TimeBudget()
+	require.GreaterOrEqual(t, budget.Wait, delay*2)
+	require.Equal(t, budget.Wall-budget.Wait, budget.Compute)
+}
+
+type fakeInvoker struct {
+	endpointID string
+	req        []byte
+	err        error
+}
+
+func (f *fakeInvoker) Invoke(endpointID string, req []byte) (string, error) {
+	f.endpointID = endpointID
+	f.req = append([]byte(nil), req...)
+	if f.err != nil {
+		return "", f.err
+	}
+	return "6f0f2a3e-7c1b-4d8e-9a57-0d1c2b3a4f5e", nil
+}
+
+func TestRuntimeInvoke(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/invoke.wasm")
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+	defer r.Close()
+
+	invoke := func(invoker Invoker) (int, string) {
+		breq, err := pb.Marshal(&proto.HTTPRequest{Method: "post", URL: "/?endpoint=thumbnails", Body: []byte("image")})
+		require.Nil(t, err)
+		ctx := context.Background()
+		if invoker != nil {
+			ctx = WithInvoker(ctx, invoker)
+		}
+		out.Reset()
+		require.Nil(t, r.InvokeContext(ctx, bytes.NewReader(breq), nil))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		return status, string(res)
+	}
+
+	invoker := &fakeInvoker{}
+	status, res := invoke(invoker)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "6f0f2a3e-7c1b-4d8e-9a57-0d1c2b3a4f5e", res)
+	require.Equal(t, "thumbnails", invoker.endpointID)
+	var req types.AsyncRequest
+	require.Nil(t, json.Unmarshal(invoker.req, &req))
+	require.Equal(t, "/jobs", req.Path)
+	require.Equal(t, []byte("image"), req.Body)
+
+	status, res = invoke(&fakeInvoker{err: fmt.Errorf("maximum invocation depth exceeded")})
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "raptor: invocation rejected", res)
+
+	// Invocations without an invoker are told so.
+	status, res = invoke(nil)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "raptor: invocations unavailable", res)
+}
+
+func TestRuntimeInvokeScratch(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/scratch.wasm")
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	args := Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+		ScratchSize:  1024,
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+	invoke := func(size string) (int, string) {
+		breq, err := pb.Marshal(&proto.HTTPRequest{Method: "POST", URL: "/", Body: []byte(size)})
+		require.Nil(t, err)
+		out.Reset()
+		require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		return status, string(res)
+	}
+
+	// Every invocation starts with an empty directory.
+	status, res := invoke("1024")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "[]", res)
+	status, res = invoke("512")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "[]", res)
+
+	status, _ = invoke("1025")
+	require.Equal(t, http.StatusInsufficientStorage, status)
+	require.Nil(t, r.Close())
+}
+
+func TestIsComponent(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+	require.False(t, IsComponent(b))
+
+	component := []byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}
+	require.True(t, IsComponent(component))
+
+	_, err = New(context.Background(), Args{Blob: component, Cache: wazero.NewCompilationCache()})
+	require.Equal(t, ErrComponentNotSupported, err)
+}
+
+// healthModule returns a module that only exports a health function that
+// runs the given instructions.
+func healthModule(code ...byte) []byte {
+	body := append([]byte{0x00}, append(code, 0x0b)...)
+	name := []byte(HealthExport)
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// () -> i32
+	b = append(b, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f)
+	b = append(b, 0x03, 0x02, 0x01, 0x00)
+	b = append(b, 0x07, byte(len(name)+4), 0x01, byte(len(name)))
+	b = append(b, name...)
+	b = append(b, 0x00, 0x00)
+	b = append(b, 0x0a, byte(len(body)+2), 0x01, byte(len(body)))
+	return append(b, body...)
+}
+
+func TestRuntimeHealth(t *testing.T) {
+	newRuntime := func(blob []byte) *Runtime {
+		r, err := New(context.Background(), Args{
+			Stdout:       io.Discard,
+			DeploymentID: uuid.New(),
+			Blob:         blob,
+			Engine:       "go",
+			Cache:        wazero.NewCompilationCache(),
+		})
+		require.Nil(t, err)
+		return r
+	}
+
+	b, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+	r := newRuntime(b)
+	require.False(t, r.HasHealthCheck())
+	require.Equal(t, ErrNoHealthCheck, r.Health(context.Background(), nil))
+	require.Nil(t, r.Close())
+
+	// i32.const 0
+	r = newRuntime(healthModule(0x41, 0x00))
+	require.True(t, r.HasHealthCheck())
+	require.Nil(t, r.Health(context.Background(), nil))
+	// Instances do not leak between the checks.
+	require.Nil(t, r.Health(context.Background(), nil))
+	require.Nil(t, r.Close())
+
+	// i32.const 7
+	r = newRuntime(healthModule(0x41, 0x07))
+	require.EqualError(t, r.Health(context.Background(), nil), "health function returned 7")
+	require.Nil(t, r.Close())
+
+	// unreachable
+	r = newRuntime(healthModule(0x00))
+	require.NotNil(t, r.Health(context.Background(), nil))
+	require.Nil(t, r.Close())
+}
+
+func TestDescribeError(t *testing.T) {
+	trap := fmt.Errorf("wasm error: unreachable\nwasm stack trace:\n\tmain.handler(i32) i32\n\t\t/src/main.go:12:3\n\tmain.main()")
+	e := DescribeError(trap, nil)
+	require.Equal(t, types.ErrorKindTrap, e.Kind)
+	require.Equal(t, "unreachable", e.Reason)
+	require.Equal(t, []string{"main.handler(i32) i32", "main.main()"}, e.Stack)
+	require.Equal(t, types.ErrorSignature(types.ErrorKindTrap, "unreachable", e.Stack), e.Signature)
+
+	stderr := []string{
+		"panic: runtime error: index out of range [5] with length 3",
+		"",
+		"goroutine 1 [running]:",
+		"main.handler(0x1a, 0x2b)",
+		"\t/src/main.go:12 +0x2",
+		"main.main()",
+		"\t/src/main.go:20 +0x4",
+	}
+	e = DescribeError(sys.NewExitError(2), stderr)
+	require.Equal(t, types.ErrorKindExit, e.Kind)
+	require.Equal(t, 2, e.ExitCode)
+	require.Equal(t, stderr[0], e.Reason)
+	require.Equal(t, []string{"main.handler", "main.main"}, e.Stack)
+	require.Equal(t, stderr, e.Stderr)
+
+	e = DescribeError(sys.NewExitError(1), []string{"failed to parse the request"})
+	require.Equal(t, "exit code 1", e.Reason)
+	require.Nil(t, e.Stack)
+
+	e = DescribeError(fmt.Errorf("module[env] not instantiated"), nil)
+	require.Equal(t, types.ErrorKindInstantiate, e.Kind)
+}
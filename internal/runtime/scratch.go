@@ -0,0 +1,172 @@
+package runtime
+
+import (
+	"io"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+// ScratchPath is the path of the writable scratch directory in the WASI
+// filesystem of the guests.
+const ScratchPath = "/tmp"
+
+// newScratchDir creates an empty directory on the host that is mounted as
+// the scratch directory of a single invocation, the caller removes it once
+// the invocation is done.
+func newScratchDir(quota int64) (string, wazero.FSConfig, error) {
+	dir, err := os.MkdirTemp("", "raptor-scratch-")
+	if err != nil {
+		return "", nil, err
+	}
+	fs := &scratchFS{
+		FS:    sysfs.DirFS(dir),
+		quota: quota,
+	}
+	config := wazero.NewFSConfig().(sysfs.FSConfig).WithSysFSMount(fs, ScratchPath)
+	return dir, config, nil
+}
+
+// scratchFS is a directory of the host that holds at most quota bytes. The
+// writes that exceed the quota fail with EIO, WASI has no errno for a full
+// disk that wazero exposes.
+type scratchFS struct {
+	experimentalsys.FS
+	quota int64
+	// Bytes used by the files of the directory. Guests are single threaded,
+	// the filesystem is never used concurrently.
+	used int64
+}
+
+func (s *scratchFS) reserve(n int64) experimentalsys.Errno {
+	if s.used+n > s.quota {
+		return experimentalsys.EIO
+	}
+	s.used += n
+	return 0
+}
+
+func (s *scratchFS) release(n int64) {
+	s.used = max(s.used-n, 0)
+}
+
+func (s *scratchFS) OpenFile(path string, flag experimentalsys.Oflag, perm os.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	var truncated int64
+	if flag&experimentalsys.O_TRUNC != 0 {
+		if st, errno := s.FS.Lstat(path); errno == 0 {
+			truncated = st.Size
+		}
+	}
+	f, errno := s.FS.OpenFile(path, flag, perm)
+	if errno != 0 {
+		return nil, errno
+	}
+	s.release(truncated)
+	return &scratchFile{File: f, fs: s}, 0
+}
+
+// Rename releases the bytes of the file that is replaced, if any.
+func (s *scratchFS) Rename(from, to string) experimentalsys.Errno {
+	var replaced int64
+	if st, errno := s.FS.Lstat(to); errno == 0 && st.Mode.IsRegular() && st.Nlink <= 1 {
+		replaced = st.Size
+	}
+	if errno := s.FS.Rename(from, to); errno != 0 {
+		return errno
+	}
+	s.release(replaced)
+	return 0
+}
+
+func (s *scratchFS) Unlink(path string) experimentalsys.Errno {
+	st, errno := s.FS.Lstat(path)
+	if errno != 0 {
+		return errno
+	}
+	if errno := s.FS.Unlink(path); errno != 0 {
+		return errno
+	}
+	if st.Nlink <= 1 {
+		s.release(st.Size)
+	}
+	return 0
+}
+
+// Symlink is not supported, links could point outside of the scratch
+// directory.
+func (s *scratchFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	return experimentalsys.EPERM
+}
+
+// scratchFile is a file of the scratch directory that accounts for its
+// growth in the quota of the directory.
+type scratchFile struct {
+	experimentalsys.File
+	fs *scratchFS
+}
+
+func (f *scratchFile) size() (int64, experimentalsys.Errno) {
+	st, errno := f.File.Stat()
+	return st.Size, errno
+}
+
+// grow reserves the bytes a write of n bytes at the given offset adds to the
+// file.
+func (f *scratchFile) grow(off, n int64) experimentalsys.Errno {
+	size, errno := f.size()
+	if errno != 0 {
+		return errno
+	}
+	if end := off + n; end > size {
+		return f.fs.reserve(end - size)
+	}
+	return 0
+}
+
+func (f *scratchFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	var off int64
+	if f.File.IsAppend() {
+		size, errno := f.size()
+		if errno != 0 {
+			return 0, errno
+		}
+		off = size
+	} else {
+		var errno experimentalsys.Errno
+		if off, errno = f.File.Seek(0, io.SeekCurrent); errno != 0 {
+			return 0, errno
+		}
+	}
+	if errno := f.grow(off, int64(len(buf))); errno != 0 {
+		return 0, errno
+	}
+	return f.File.Write(buf)
+}
+
+func (f *scratchFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	if errno := f.grow(off, int64(len(buf))); errno != 0 {
+		return 0, errno
+	}
+	return f.File.Pwrite(buf, off)
+}
+
+func (f *scratchFile) Truncate(size int64) experimentalsys.Errno {
+	current, errno := f.size()
+	if errno != 0 {
+		return errno
+	}
+	if size > current {
+		if errno := f.fs.reserve(size - current); errno != 0 {
+			return errno
+		}
+	}
+	if errno := f.File.Truncate(size); errno != 0 {
+		return errno
+	}
+	if size < current {
+		f.fs.release(current - size)
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Stream streams the response of an invocation to the client while the guest
+// is still running, like for Server-Sent Events. The guest starts the stream
+// with the stream_start host function and writes the chunks of the body with
+// stream_write.
+type Stream interface {
+	// Start sends the status code and the headers of the response, encoded
+	// as "Key: value\r\n" lines. An error is returned when the response
+	// cannot be streamed.
+	Start(status int, header []byte) error
+	// Write sends a chunk of the body to the client.
+	Write(b []byte) error
+}
+
+type streamKey struct{}
+
+type streamState struct {
+	stream  Stream
+	started bool
+}
+
+// WithStream returns a context that allows the guest to stream its response
+// with the given stream when the module is invoked with it.
+func WithStream(ctx context.Context, stream Stream) context.Context {
+	return context.WithValue(ctx, streamKey{}, &streamState{stream: stream})
+}
+
+func streamFrom(ctx context.Context) *streamState {
+	state, _ := ctx.Value(streamKey{}).(*streamState)
+	return state
+}
+
+// streamStart starts streaming the response with the given status code and
+// headers. It returns 0 on success, the guest needs to buffer its response
+// otherwise.
+func (r *Runtime) streamStart(ctx context.Context, m api.Module, status, header, headerLen uint32) int32 {
+	state := streamFrom(ctx)
+	if state == nil {
+		return hostUnavailable
+	}
+	if state.started {
+		return hostRejected
+	}
+	b, ok := m.Memory().Read(header, headerLen)
+	if !ok {
+		return hostFault
+	}
+	h := make([]byte, len(b))
+	copy(h, b)
+	start := time.Now()
+	err := state.stream.Start(int(status), h)
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	state.started = true
+	return 0
+}
+
+// streamWrite sends the chunk in the buffer of the guest to the client. It
+// returns 0 on success.
+func (r *Runtime) streamWrite(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	state := streamFrom(ctx)
+	if state == nil {
+		return hostUnavailable
+	}
+	if !state.started {
+		return hostRejected
+	}
+	b, ok := m.Memory().Read(buf, bufLen)
+	if !ok {
+		return hostFault
+	}
+	chunk := make([]byte, len(b))
+	copy(chunk, b)
+	start := time.Now()
+	err := state.stream.Write(chunk)
+	r.clock.wait(start)
+	if err != nil {
+		return hostClosed
+	}
+	return 0
+}
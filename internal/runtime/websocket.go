@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Socket is the WebSocket connection of an invocation. The guest reads and
+// writes its messages with the ws_read and ws_write host functions.
+type Socket interface {
+	// Read blocks until the next message of the client. An error is
+	// returned once the connection is closed.
+	Read(ctx context.Context) ([]byte, error)
+	// Write sends a message to the client.
+	Write(ctx context.Context, b []byte) error
+}
+
+type socketKey struct{}
+
+// socketState is the socket of an invocation along with the message that
+// did not fit the buffer of the guest yet.
+type socketState struct {
+	socket  Socket
+	pending []byte
+}
+
+// WithSocket returns a context that makes the given socket available to the
+// guest when the module is invoked with it.
+func WithSocket(ctx context.Context, socket Socket) context.Context {
+	return context.WithValue(ctx, socketKey{}, &socketState{socket: socket})
+}
+
+func socketFrom(ctx context.Context) *socketState {
+	state, _ := ctx.Value(socketKey{}).(*socketState)
+	return state
+}
+
+// wsRead blocks until the next message of the client and copies it into the
+// buffer of the guest. It returns the size of the message. When the message
+// is larger than the buffer nothing is copied and the guest needs to call
+// again with a buffer of at least the returned size.
+func (r *Runtime) wsRead(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	state := socketFrom(ctx)
+	if state == nil {
+		return hostUnavailable
+	}
+	if state.pending == nil {
+		start := time.Now()
+		msg, err := state.socket.Read(ctx)
+		// Waiting for the client is not accounted as compute.
+		r.clock.wait(start)
+		if err != nil {
+			return hostClosed
+		}
+		if msg == nil {
+			msg = []byte{}
+		}
+		state.pending = msg
+	}
+	if len(state.pending) > int(bufLen) {
+		return int32(len(state.pending))
+	}
+	if !m.Memory().Write(buf, state.pending) {
+		return hostFault
+	}
+	n := len(state.pending)
+	state.pending = nil
+	return int32(n)
+}
+
+// wsWrite sends the message in the buffer of the guest to the client. It
+// returns 0 on success.
+func (r *Runtime) wsWrite(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	state := socketFrom(ctx)
+	if state == nil {
+		return hostUnavailable
+	}
+	b, ok := m.Memory().Read(buf, bufLen)
+	if !ok {
+		return hostFault
+	}
+	// The memory of the guest is reused once we return.
+	msg := make([]byte, len(b))
+	copy(msg, b)
+	start := time.Now()
+	err := state.socket.Write(ctx, msg)
+	r.clock.wait(start)
+	if err != nil {
+		return hostClosed
+	}
+	return 0
+}
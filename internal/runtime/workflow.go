@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// MaxWorkflowState is the maximum size in bytes of the state of a workflow.
+const MaxWorkflowState = 1 << 20
+
+// Workflow is the workflow the invocation is a step of, its state is
+// checkpointed by the guest with the state_get and state_set host functions
+// and its next step scheduled with workflow_sleep.
+type Workflow interface {
+	State() ([]byte, error)
+	// SetState checkpoints the state, it is not retained past the call.
+	SetState(state []byte) error
+	// Sleep schedules the next step of the workflow after the duration,
+	// once the current step is done.
+	Sleep(d time.Duration) error
+}
+
+type workflowKey struct{}
+
+// WithWorkflow returns a context that gives the guest access to the workflow
+// when the module is invoked with it.
+func WithWorkflow(ctx context.Context, workflow Workflow) context.Context {
+	return context.WithValue(ctx, workflowKey{}, workflow)
+}
+
+// stateGet writes the state of the workflow into the buffer of the guest. It
+// returns the size of the state, which is not written when it exceeds the
+// buffer.
+func (r *Runtime) stateGet(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	workflow, ok := ctx.Value(workflowKey{}).(Workflow)
+	if !ok {
+		return hostUnavailable
+	}
+	start := time.Now()
+	state, err := workflow.State()
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	if len(state) > int(bufLen) {
+		return int32(len(state))
+	}
+	if !m.Memory().Write(buf, state) {
+		return hostFault
+	}
+	return int32(len(state))
+}
+
+// stateSet checkpoints the state in the buffer of the guest. It returns 0 on
+// success.
+func (r *Runtime) stateSet(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+	workflow, ok := ctx.Value(workflowKey{}).(Workflow)
+	if !ok {
+		return hostUnavailable
+	}
+	if bufLen > MaxWorkflowState {
+		return hostRejected
+	}
+	state, ok := m.Memory().Read(buf, bufLen)
+	if !ok {
+		return hostFault
+	}
+	start := time.Now()
+	err := workflow.SetState(state)
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	return 0
+}
+
+// workflowSleep schedules the next step of the workflow in ms milliseconds.
+// It returns 0 on success.
+func (r *Runtime) workflowSleep(ctx context.Context, ms uint32) int32 {
+	workflow, ok := ctx.Value(workflowKey{}).(Workflow)
+	if !ok {
+		return hostUnavailable
+	}
+	start := time.Now()
+	err := workflow.Sleep(time.Duration(ms) * time.Millisecond)
+	r.clock.wait(start)
+	if err != nil {
+		return hostRejected
+	}
+	return 0
+}
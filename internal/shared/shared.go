@@ -5,11 +5,15 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/proto"
 	"github.com/google/uuid"
 )
@@ -21,7 +25,21 @@ const (
 
 var errInvalidHTTPResponse = errors.New("invalid HTTP response")
 
+// HeaderFlag is set on the status code of the response trailer when the guest
+// writes response headers. The headers are then written before the body,
+// followed by the length of the headers as little endian uint32, right in
+// front of the trailer. Headers are written as "Key: value\r\n" lines.
+const HeaderFlag = 1 << 31
+
 func ParseStdout(stdout io.Reader) (logs []byte, resp []byte, status int, err error) {
+	logs, resp, _, status, err = ParseStdoutWithHeader(stdout)
+	return
+}
+
+// ParseStdoutWithHeader parses the stdout of the guest into the logs, the
+// response body, the response headers and the status code. The header is nil
+// when the guest did not write any headers.
+func ParseStdoutWithHeader(stdout io.Reader) (logs []byte, resp []byte, header http.Header, status int, err error) {
 	stdoutb, err := io.ReadAll(stdout)
 	if err != nil {
 		return
@@ -32,18 +50,80 @@ func ParseStdout(stdout io.Reader) (logs []byte, resp []byte, status int, err er
 		return
 	}
 	magicStart := outLen - magicLen
-	status = int(binary.LittleEndian.Uint32(stdoutb[magicStart : magicStart+4]))
+	rawStatus := binary.LittleEndian.Uint32(stdoutb[magicStart : magicStart+4])
 	respLen := binary.LittleEndian.Uint32(stdoutb[magicStart+4:])
-	if int(respLen) > outLen-magicLen {
+	status = int(rawStatus &^ HeaderFlag)
+	end := magicStart
+	if rawStatus&HeaderFlag != 0 {
+		if end < 4 {
+			err = fmt.Errorf("mallformed HTTP response missing header length")
+			return
+		}
+		end -= 4
+		headerLen := binary.LittleEndian.Uint32(stdoutb[end : end+4])
+		if uint64(headerLen)+uint64(respLen) > uint64(end) {
+			err = fmt.Errorf("response length exceeds available data")
+			return
+		}
+		respStart := end - int(respLen)
+		resp = stdoutb[respStart:end]
+		headerStart := respStart - int(headerLen)
+		header = ParseHeader(stdoutb[headerStart:respStart])
+		logs = stdoutb[:headerStart]
+		return
+	}
+	if int(respLen) > end {
 		err = fmt.Errorf("response length exceeds available data")
 		return
 	}
-	respStart := outLen - magicLen - int(respLen)
-	resp = stdoutb[respStart : respStart+int(respLen)]
+	respStart := end - int(respLen)
+	resp = stdoutb[respStart:end]
 	logs = stdoutb[:respStart]
 	return
 }
 
+// ParseHeader parses "Key: value\r\n" lines, malformed lines are skipped.
+// Trailers are announced like net/http does, with their key prefixed by
+// http.TrailerPrefix ("Trailer:Grpc-Status: 0"), and keep their prefix.
+func ParseHeader(b []byte) http.Header {
+	header := make(http.Header)
+	for _, line := range strings.Split(string(b), "\r\n") {
+		prefix := ""
+		if rest, ok := strings.CutPrefix(line, http.TrailerPrefix); ok && len(rest) > 0 && rest[0] != ' ' {
+			prefix, line = http.TrailerPrefix, rest
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || len(key) == 0 {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if len(prefix) > 0 {
+			// Header.Add would not canonicalize the prefixed key.
+			key = prefix + http.CanonicalHeaderKey(key)
+		}
+		header.Add(key, strings.TrimSpace(value))
+	}
+	return header
+}
+
+// SplitTrailer removes the trailers from the given header and returns them
+// without their http.TrailerPrefix.
+func SplitTrailer(header http.Header) http.Header {
+	var trailer http.Header
+	for k, values := range header {
+		name, ok := strings.CutPrefix(k, http.TrailerPrefix)
+		if !ok {
+			continue
+		}
+		if trailer == nil {
+			trailer = make(http.Header)
+		}
+		trailer[http.CanonicalHeaderKey(name)] = values
+		delete(header, k)
+	}
+	return trailer
+}
+
 func ParseRuntimeHTTPResponse(in string) (resp string, status int, err error) {
 	if len(in) < 16 {
 		err = fmt.Errorf("misformed HTTP response missing last 16 bytes")
@@ -60,17 +140,72 @@ func ParseRuntimeHTTPResponse(in string) (resp string, status int, err error) {
 	return
 }
 
+// PreviewLogsHeader is the request header that asks for the logs of a
+// PREVIEW invocation. The logs are sent back base64 encoded in the response
+// header of the same name.
+const PreviewLogsHeader = "Raptor-Logs"
+
+// RequestIDHeader holds the id the ingress gives every request, it is sent
+// to the guest and back to the client.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestIDEnv is the environment variable the guests find the id of the
+// request of the invocation in.
+const RequestIDEnv = "RAPTOR_REQUEST_ID"
+
+// ColdStartHeader is set to "true" on the response of the invocation that
+// instantiated its runtime.
+const ColdStartHeader = "Raptor-Cold-Start"
+
+// InvocationDepthHeader holds how many guests invoked each other to get to
+// the request, the invocations of a guest carry its depth plus one.
+const InvocationDepthHeader = "Raptor-Invocation-Depth"
+
+// WorkflowIDHeader holds the id of the workflow the request is a step of.
+// The ingress drops it from the requests of the clients and only sets it for
+// the steps signed by the workflow runner.
+const WorkflowIDHeader = "Raptor-Workflow-Id"
+
+// InvocationIDHeader holds the id of the polled asynchronous invocation the
+// request is an attempt of. The ingress drops it from the requests of the
+// clients and only sets it for the attempts signed by the invoker.
+const InvocationIDHeader = "Raptor-Invocation-Id"
+
+// ReplayHeader marks the replay of a captured request, its value is the id
+// of the captured request. Replays are not captured.
+const ReplayHeader = "Raptor-Replay"
+
+// JWTSubjectHeader and JWTClaimsHeader hold the subject and the JSON
+// encoded claims of the token the ingress validated. The ingress drops them
+// from the requests of the clients, so the guests can trust them.
+const (
+	JWTSubjectHeader = "Raptor-Jwt-Subject"
+	JWTClaimsHeader  = "Raptor-Jwt-Claims"
+)
+
+// JWTSubjectEnv and JWTClaimsEnv are the environment variables the guests
+// find the subject and the claims of the validated token in.
+const (
+	JWTSubjectEnv = "RAPTOR_JWT_SUBJECT"
+	JWTClaimsEnv  = "RAPTOR_JWT_CLAIMS"
+)
+
+// MaxPreviewLogs is the maximum amount of bytes of logs sent back with a
+// PREVIEW response, the oldest logs are dropped.
+const MaxPreviewLogs = 16 << 10
+
 func MakeProtoRequest(id string, r *http.Request) (*proto.HTTPRequest, error) {
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
 	return &proto.HTTPRequest{
-		Header: makeProtoHeader(r.Header),
-		ID:     id,
-		Body:   b,
-		Method: r.Method,
-		URL:    trimmedEndpointFromURL(r.URL),
+		Header:   MakeProtoHeader(r.Header),
+		ID:       id,
+		Body:     b,
+		Method:   r.Method,
+		URL:      trimmedEndpointFromURL(r.URL),
+		Protocol: r.Proto,
 	}, nil
 }
 
@@ -83,7 +218,8 @@ func trimmedEndpointFromURL(url *url.URL) string {
 	return "/" + strings.Join(pathParts[2:], "/")
 }
 
-func makeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
+// MakeProtoHeader converts the header into its proto representation.
+func MakeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
 	m := make(map[string]*proto.HeaderFields, len(header))
 	for k, v := range header {
 		m[k] = &proto.HeaderFields{
@@ -96,3 +232,106 @@ func makeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
 func IsZeroUUID(id uuid.UUID) bool {
 	return id.String() == UUIDZERO
 }
+
+// SessionSlot consistently hashes the given session key into one of the
+// given number of slots. Changing the number of slots only moves the minimum
+// amount of sessions to another slot (jump consistent hash).
+func SessionSlot(session string, slots int) int {
+	h := fnv.New64a()
+	h.Write([]byte(session))
+	key := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(slots) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Accepts returns true if the given Accept header value accepts the given
+// content type. An empty Accept header accepts everything.
+func Accepts(accept string, contentType string) bool {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for _, value := range strings.Split(accept, ",") {
+		parts := strings.Split(value, ";")
+		rng := strings.ToLower(strings.TrimSpace(parts[0]))
+		if rng != "*/*" && rng != typ+"/*" && rng != mediaType {
+			continue
+		}
+		rejected := false
+		for _, param := range parts[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" && strings.Trim(val, "0.") == "" {
+				rejected = true
+			}
+		}
+		if !rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsEncoding returns true if the given Accept-Encoding header value
+// accepts the given content coding, like gzip.
+func AcceptsEncoding(acceptEncoding string, coding string) bool {
+	accepted := false
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(value, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name != coding && name != "*" {
+			continue
+		}
+		rejected := false
+		for _, param := range parts[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" && strings.Trim(val, "0.") == "" {
+				rejected = true
+			}
+		}
+		// An explicit coding takes precedence over the wildcard.
+		if name == coding {
+			return !rejected
+		}
+		accepted = !rejected
+	}
+	return accepted
+}
+
+// HeaderFromProto converts the proto representation of a header back into an
+// http.Header.
+func HeaderFromProto(m map[string]*proto.HeaderFields) http.Header {
+	header := make(http.Header, len(m))
+	for k, v := range m {
+		header[k] = v.GetFields()
+	}
+	return header
+}
+
+// GlobalCORS returns the defaults of the CORS policies of the installation.
+func GlobalCORS() types.CORS {
+	cors := config.Get().CORS
+	return types.CORS{
+		AllowedMethods: cors.AllowedMethods,
+		AllowedHeaders: cors.AllowedHeaders,
+		ExposedHeaders: cors.ExposedHeaders,
+		MaxAge:         int(time.Duration(cors.MaxAge) / time.Second),
+	}
+}
+
+// GlobalLimits returns the resource limits of the installation.
+func GlobalLimits() types.Limits {
+	limits := config.Get().Limits
+	return types.Limits{
+		Timeout:         int(time.Duration(limits.Timeout) / time.Millisecond),
+		MaxResponseSize: limits.MaxResponseSize,
+		CacheQuota:      limits.CacheQuota,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -102,3 +103,62 @@ func TestParseRuntimeHTTPResponse(t *testing.T) {
 	require.Equal(t, int(statusCode), status)
 	require.Equal(t, text, resp)
 }
+
+func TestSessionSlot(t *testing.T) {
+	slots := 16
+	for i := 0; i < 1000; i++ {
+		session := fmt.Sprintf("session_%d", i)
+		slot := SessionSlot(session, slots)
+		require.True(t, slot >= 0 && slot < slots)
+		require.Equal(t, slot, SessionSlot(session, slots))
+	}
+
+	// Growing the number of slots should only move sessions to the new slot.
+	for i := 0; i < 1000; i++ {
+		session := fmt.Sprintf("session_%d", i)
+		slot, grown := SessionSlot(session, slots), SessionSlot(session, slots+1)
+		require.True(t, slot == grown || grown == slots)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	require.True(t, Accepts("", "image/x-icon"))
+	require.True(t, Accepts("*/*", "image/x-icon"))
+	require.True(t, Accepts("text/html, image/*;q=0.8", "image/x-icon"))
+	require.True(t, Accepts("text/plain", "text/plain; charset=utf-8"))
+	require.False(t, Accepts("application/json", "text/plain"))
+	require.False(t, Accepts("text/plain;q=0", "text/plain"))
+	require.False(t, Accepts("text/plain;q=0.0, application/json", "text/plain"))
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	require.True(t, AcceptsEncoding("gzip, deflate, br", "gzip"))
+	require.True(t, AcceptsEncoding("GZIP;q=0.5", "gzip"))
+	require.True(t, AcceptsEncoding("*", "gzip"))
+	require.False(t, AcceptsEncoding("", "gzip"))
+	require.False(t, AcceptsEncoding("br", "gzip"))
+	require.False(t, AcceptsEncoding("gzip;q=0", "gzip"))
+	require.False(t, AcceptsEncoding("*, gzip;q=0", "gzip"))
+}
+
+func TestMakeProtoRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/live/09248ef6-c401-4601-8928-5964d61f2c61/users/1", bytes.NewReader([]byte("body")))
+	r.Proto = "HTTP/2.0"
+	req, err := MakeProtoRequest("1", r)
+	require.Nil(t, err)
+	require.Equal(t, "/users/1", req.URL)
+	require.Equal(t, "HTTP/2.0", req.Protocol)
+	require.Equal(t, []byte("body"), req.Body)
+}
+
+func TestParseHeaderTrailer(t *testing.T) {
+	header := ParseHeader([]byte("Content-Type: application/grpc\r\nTrailer: Grpc-Status\r\nTrailer:grpc-status: 0\r\nTrailer:Grpc-Message: \r\n"))
+	require.Equal(t, "application/grpc", header.Get("Content-Type"))
+	require.Equal(t, "Grpc-Status", header.Get("Trailer"))
+
+	trailer := SplitTrailer(header)
+	require.Equal(t, "0", trailer.Get("Grpc-Status"))
+	require.Equal(t, []string{""}, trailer["Grpc-Message"])
+	require.Len(t, header, 2)
+	require.Nil(t, SplitTrailer(header))
+}
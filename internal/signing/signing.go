@@ -0,0 +1,112 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnsigned         = errors.New("deployment is not signed")
+	ErrInvalidSignature = errors.New("deployment signature does not match any of the trusted public keys")
+)
+
+// Verifier verifies the signatures of the deployment blobs against the
+// public keys trusted by the operator. A nil verifier accepts every blob.
+type Verifier struct {
+	keys []ed25519.PublicKey
+	// Reject the deployments that are not signed.
+	required bool
+}
+
+// NewVerifier returns a new verifier given the base64 encoded ed25519 public
+// keys it trusts. Unsigned deployments are accepted unless required is set.
+func NewVerifier(publicKeys []string, required bool) (*Verifier, error) {
+	if len(publicKeys) == 0 && required {
+		return nil, fmt.Errorf("signed deployments are required but no public keys are trusted")
+	}
+	v := &Verifier{required: required}
+	for _, s := range publicKeys {
+		key, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, key)
+	}
+	return v, nil
+}
+
+// Verify returns an error if the signature of the blob is not made by one of
+// the trusted keys. Signed blobs are always verified, unsigned blobs are
+// rejected when signatures are required.
+func (v *Verifier) Verify(blob, signature []byte) error {
+	if v == nil {
+		return nil
+	}
+	if len(signature) == 0 {
+		if v.required {
+			return ErrUnsigned
+		}
+		return nil
+	}
+	for _, key := range v.keys {
+		if ed25519.Verify(key, blob, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// ValidateSignature returns an error if the signature is not empty and not
+// an ed25519 signature.
+func ValidateSignature(signature []byte) error {
+	if len(signature) > 0 && len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("signature should be %d bytes got %d", ed25519.SignatureSize, len(signature))
+	}
+	return nil
+}
+
+// Sign returns the signature of the blob made with the given private key.
+func Sign(key ed25519.PrivateKey, blob []byte) []byte {
+	return ed25519.Sign(key, blob)
+}
+
+// GenerateKey returns a new base64 encoded key pair.
+func GenerateKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// ParsePublicKey returns the ed25519 public key given its base64 encoding.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key should be %d bytes got %d", ed25519.PublicKeySize, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey returns the ed25519 private key given the base64 encoding
+// of the key or of its seed.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	switch len(b) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	}
+	return nil, fmt.Errorf("private key should be %d bytes got %d", ed25519.PrivateKeySize, len(b))
+}
@@ -0,0 +1,92 @@
+package signing
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestKey(t *testing.T) (string, string) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv := newTestKey(t)
+	_, otherPriv := newTestKey(t)
+	key, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ParsePrivateKey(otherPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte("wasm blob")
+	tampered := []byte("wasm blod")
+
+	v, err := NewVerifier([]string{pub}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(blob, Sign(key, blob)); err != nil {
+		t.Fatalf("expected a valid signature got %s", err)
+	}
+	if err := v.Verify(tampered, Sign(key, blob)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a tampered blob to be rejected got %v", err)
+	}
+	if err := v.Verify(blob, Sign(otherKey, blob)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a signature of an untrusted key to be rejected got %v", err)
+	}
+	if err := v.Verify(blob, nil); err != nil {
+		t.Fatalf("expected an unsigned blob to be accepted got %s", err)
+	}
+
+	v, err = NewVerifier([]string{pub}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(blob, nil); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("expected an unsigned blob to be rejected got %v", err)
+	}
+
+	var nilVerifier *Verifier
+	if err := nilVerifier.Verify(tampered, Sign(key, blob)); err != nil {
+		t.Fatalf("expected a nil verifier to accept every blob got %s", err)
+	}
+}
+
+func TestNewVerifierInvalid(t *testing.T) {
+	if _, err := NewVerifier(nil, true); err == nil {
+		t.Error("expected an error when signatures are required without public keys")
+	}
+	if _, err := NewVerifier([]string{"not base64!"}, false); err == nil {
+		t.Error("expected an error for a malformed public key")
+	}
+	if _, err := NewVerifier([]string{"c2hvcnQ="}, false); err == nil {
+		t.Error("expected an error for a short public key")
+	}
+}
+
+func TestParsePrivateKeySeed(t *testing.T) {
+	_, priv := newTestKey(t)
+	key, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := ParsePrivateKey("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seed) != len(key) {
+		t.Fatalf("expected a private key of %d bytes got %d", len(key), len(seed))
+	}
+	if err := ValidateSignature(Sign(seed, []byte("blob"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSignature([]byte("short")); err == nil {
+		t.Error("expected an error for a malformed signature")
+	}
+}
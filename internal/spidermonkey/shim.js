@@ -0,0 +1,39 @@
+// The shim is prepended to every script that runs on the js runtime. It
+// exposes the incoming request and implements the response ABI of the
+// runtime: the body followed by the status code and the length of the
+// body in bytes, both as little endian uint32. Headers are written as
+// "Key: value\r\n" lines before the body, their length follows the body
+// and the status code is flagged.
+
+function byteLength(s) {
+    return unescape(encodeURIComponent(s)).length;
+}
+
+function respond(body, status, headers) {
+    if (typeof body !== "string") {
+        body = JSON.stringify(body);
+    }
+    if (status === undefined) {
+        status = 200;
+    }
+    var head = "";
+    for (var name in headers) {
+        head += name + ": " + headers[name] + "\r\n";
+    }
+    var buffer, view;
+    if (head.length > 0) {
+        buffer = new ArrayBuffer(12);
+        view = new DataView(buffer);
+        view.setUint32(0, byteLength(head), true);
+        view.setUint32(4, (status | 0x80000000) >>> 0, true);
+        view.setUint32(8, byteLength(body), true);
+        putstr(head);
+    } else {
+        buffer = new ArrayBuffer(8);
+        view = new DataView(buffer);
+        view.setUint32(0, status, true);
+        view.setUint32(4, byteLength(body), true);
+    }
+    putstr(body);
+    writebytes(view);
+}
@@ -1,6 +1,49 @@
 package spidermonkey
 
-import _ "embed"
+import (
+	_ "embed"
+	"encoding/json"
+
+	"github.com/anthdm/raptor/proto"
+)
 
 //go:embed js.wasm
 var WasmBlob []byte
+
+//go:embed shim.js
+var shim string
+
+type request struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Header map[string][]string `json:"header"`
+	Body   string              `json:"body"`
+	Env    map[string]string   `json:"env"`
+	// Address of the client, behind the trusted proxies of the ingress.
+	ClientIP string `json:"client_ip"`
+}
+
+// Script returns the given user script wrapped with the request/response
+// shim. The request is exposed to the script as the global "request" object.
+func Script(script []byte, req *proto.HTTPRequest) (string, error) {
+	header := make(map[string][]string, len(req.Header))
+	for k, v := range req.Header {
+		header[k] = v.Fields
+	}
+	env := req.Env
+	if env == nil {
+		env = make(map[string]string)
+	}
+	b, err := json.Marshal(request{
+		Method:   req.Method,
+		URL:      req.URL,
+		Header:   header,
+		Body:     string(req.Body),
+		Env:      env,
+		ClientIP: req.ClientIP,
+	})
+	if err != nil {
+		return "", err
+	}
+	return shim + "\nvar request = " + string(b) + ";\n" + string(script), nil
+}
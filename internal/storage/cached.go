@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// The expired entries of a CachedStore are swept when it holds more than
+// cachedStoreSweepSize entries of a kind.
+const cachedStoreSweepSize = 10000
+
+type cachedEndpoint struct {
+	endpoint *types.Endpoint
+	expires  time.Time
+}
+
+type cachedDeploy struct {
+	deploy  *types.Deployment
+	expires time.Time
+}
+
+type cachedProject struct {
+	env     *types.ProjectEnvironment
+	expires time.Time
+}
+
+// CachedStore is a Store caching the endpoints, the deployments and the
+// project environments for a short time, the ingress and the runtimes look
+// them up for every request.
+// The changes made through the store invalidate the cache right away, the
+// ones made by other processes once they are published with
+// SQLStore.ListenInvalidations or when the entries expire.
+type CachedStore struct {
+	Store
+	ttl time.Duration
+
+	mu        sync.Mutex
+	endpoints map[uuid.UUID]cachedEndpoint
+	deploys   map[uuid.UUID]cachedDeploy
+	projects  map[string]cachedProject
+}
+
+// NewCachedStore returns a new CachedStore given the store to cache and the
+// time the entries are cached for.
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		Store:     store,
+		ttl:       ttl,
+		endpoints: make(map[uuid.UUID]cachedEndpoint),
+		deploys:   make(map[uuid.UUID]cachedDeploy),
+		projects:  make(map[string]cachedProject),
+	}
+}
+
+// GetEndpoint returns a copy of the cached endpoint, the callers can modify
+// it without affecting the cache.
+func (s *CachedStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
+	s.mu.Lock()
+	entry, ok := s.endpoints[id]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copyEndpoint(entry.endpoint), nil
+	}
+	endpoint, err := s.Store.GetEndpoint(id)
+	if err != nil {
+		return nil, err
+	}
+	cached := copyEndpoint(endpoint)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.endpoints) >= cachedStoreSweepSize {
+		for id, entry := range s.endpoints {
+			if time.Now().After(entry.expires) {
+				delete(s.endpoints, id)
+			}
+		}
+	}
+	s.endpoints[id] = cachedEndpoint{endpoint: cached, expires: time.Now().Add(s.ttl)}
+	return endpoint, nil
+}
+
+func copyEndpoint(endpoint *types.Endpoint) *types.Endpoint {
+	c := *endpoint
+	c.Environment = make(map[string]string, len(endpoint.Environment))
+	for k, v := range endpoint.Environment {
+		c.Environment[k] = v
+	}
+	return &c
+}
+
+func (s *CachedStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
+	defer s.Invalidate(id)
+	return s.Store.UpdateEndpoint(id, params)
+}
+
+// GetDeployment returns a copy of the cached deployment, the callers can
+// modify it without affecting the cache.
+func (s *CachedStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
+	s.mu.Lock()
+	entry, ok := s.deploys[id]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		deploy := *entry.deploy
+		return &deploy, nil
+	}
+	deploy, err := s.Store.GetDeployment(id)
+	if err != nil {
+		return nil, err
+	}
+	cached := *deploy
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.deploys) >= cachedStoreSweepSize {
+		for id, entry := range s.deploys {
+			if time.Now().After(entry.expires) {
+				delete(s.deploys, id)
+			}
+		}
+	}
+	s.deploys[id] = cachedDeploy{deploy: &cached, expires: time.Now().Add(s.ttl)}
+	return deploy, nil
+}
+
+// GetProjectEnvironment returns a copy of the cached project environment,
+// the callers can modify it without affecting the cache.
+func (s *CachedStore) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	s.mu.Lock()
+	entry, ok := s.projects[project]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copyProjectEnvironment(entry.env), nil
+	}
+	env, err := s.Store.GetProjectEnvironment(project)
+	if err != nil {
+		return nil, err
+	}
+	cached := copyProjectEnvironment(env)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.projects) >= cachedStoreSweepSize {
+		for project, entry := range s.projects {
+			if time.Now().After(entry.expires) {
+				delete(s.projects, project)
+			}
+		}
+	}
+	s.projects[project] = cachedProject{env: cached, expires: time.Now().Add(s.ttl)}
+	return env, nil
+}
+
+func (s *CachedStore) UpdateProjectEnvironment(env *types.ProjectEnvironment) error {
+	defer s.InvalidateProject(env.Project)
+	return s.Store.UpdateProjectEnvironment(env)
+}
+
+func copyProjectEnvironment(env *types.ProjectEnvironment) *types.ProjectEnvironment {
+	c := *env
+	c.Environment = make(map[string]string, len(env.Environment))
+	for k, v := range env.Environment {
+		c.Environment[k] = v
+	}
+	return &c
+}
+
+func (s *CachedStore) DeleteEndpoint(id uuid.UUID) error {
+	defer s.Invalidate(id)
+	return s.Store.DeleteEndpoint(id)
+}
+
+func (s *CachedStore) DeleteDeployment(id uuid.UUID) error {
+	defer s.Invalidate(id)
+	return s.Store.DeleteDeployment(id)
+}
+
+// Invalidate drops the endpoint or the deployment with the given id from
+// the cache.
+func (s *CachedStore) Invalidate(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.endpoints, id)
+	delete(s.deploys, id)
+}
+
+// InvalidateProject drops the environment of the project from the cache.
+func (s *CachedStore) InvalidateProject(project string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.projects, project)
+}
+
+// InvalidateAll drops all the entries of the cache.
+func (s *CachedStore) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints = make(map[uuid.UUID]cachedEndpoint)
+	s.deploys = make(map[uuid.UUID]cachedDeploy)
+	s.projects = make(map[string]cachedProject)
+}
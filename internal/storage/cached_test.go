@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the lookups that reach the store.
+type countingStore struct {
+	Store
+	endpoints int
+	deploys   int
+	projects  int
+}
+
+func (s *countingStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
+	s.endpoints++
+	return s.Store.GetEndpoint(id)
+}
+
+func (s *countingStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
+	s.deploys++
+	return s.Store.GetDeployment(id)
+}
+
+func (s *countingStore) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	s.projects++
+	return s.Store.GetProjectEnvironment(project)
+}
+
+func newCachedStore(ttl time.Duration) (*CachedStore, *countingStore) {
+	store := &countingStore{Store: NewMemoryStore()}
+	return NewCachedStore(store, ttl), store
+}
+
+func TestCachedStoreEndpoint(t *testing.T) {
+	s, store := newCachedStore(time.Minute)
+	endpoint := types.NewEndpoint("cached", "go", map[string]string{"KEY": "value"})
+	require.Nil(t, s.CreateEndpoint(endpoint))
+
+	got, err := s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	got.Name = "modified"
+	got.Environment["KEY"] = "modified"
+	got, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "cached", got.Name)
+	require.Equal(t, "value", got.Environment["KEY"])
+	got.Environment["KEY"] = "modified"
+	got, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "value", got.Environment["KEY"])
+	require.Equal(t, 1, store.endpoints)
+
+	// The updates made through the store invalidate the entry.
+	require.Nil(t, s.UpdateEndpoint(endpoint.ID, UpdateEndpointParams{Name: "renamed"}))
+	got, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "renamed", got.Name)
+	require.Equal(t, 2, store.endpoints)
+
+	// The changes of other processes are seen once invalidated.
+	s.Invalidate(endpoint.ID)
+	_, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, store.endpoints)
+
+	require.Nil(t, s.DeleteEndpoint(endpoint.ID))
+	_, err = s.GetEndpoint(endpoint.ID)
+	require.NotNil(t, err)
+}
+
+func TestCachedStoreExpires(t *testing.T) {
+	s, store := newCachedStore(time.Millisecond * 10)
+	endpoint := types.NewEndpoint("cached", "go", nil)
+	require.Nil(t, s.CreateEndpoint(endpoint))
+	deploy := types.NewDeployment(endpoint, []byte("a"))
+	require.Nil(t, s.CreateDeployment(deploy))
+
+	for i := 0; i < 2; i++ {
+		_, err := s.GetEndpoint(endpoint.ID)
+		require.Nil(t, err)
+		_, err = s.GetDeployment(deploy.ID)
+		require.Nil(t, err)
+	}
+	require.Equal(t, 1, store.endpoints)
+	require.Equal(t, 1, store.deploys)
+
+	time.Sleep(time.Millisecond * 20)
+	_, err := s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	_, err = s.GetDeployment(deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, store.endpoints)
+	require.Equal(t, 2, store.deploys)
+}
+
+func TestCachedStoreProjectEnvironment(t *testing.T) {
+	s, store := newCachedStore(time.Minute)
+	require.Nil(t, s.UpdateProjectEnvironment(&types.ProjectEnvironment{
+		Project:     "payments",
+		Environment: map[string]string{"KEY": "value"},
+	}))
+
+	env, err := s.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	env.Environment["KEY"] = "modified"
+	env, err = s.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	require.Equal(t, "value", env.Environment["KEY"])
+	require.Equal(t, 1, store.projects)
+
+	require.Nil(t, s.UpdateProjectEnvironment(&types.ProjectEnvironment{
+		Project:     "payments",
+		Environment: map[string]string{"KEY": "updated"},
+	}))
+	env, err = s.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	require.Equal(t, "updated", env.Environment["KEY"])
+	require.Equal(t, 2, store.projects)
+
+	s.InvalidateAll()
+	_, err = s.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	require.Equal(t, 3, store.projects)
+}
@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/anthdm/raptor/internal/encryption"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// KeyRotator is implemented by the stores that encrypt the data of the
+// endpoints with a data key per endpoint.
+type KeyRotator interface {
+	// RotateDataKey adds a new data key version to the endpoint, re-encrypts
+	// its environments with it and returns its version.
+	RotateDataKey(id uuid.UUID) (int, error)
+	// RewrapDataKeys wraps the data keys that are wrapped with a previous
+	// master key with the current master key.
+	RewrapDataKeys() (RewrapResult, error)
+}
+
+// RewrapResult holds the number of endpoints and projects whose data keys
+// were re-wrapped with the current master key.
+type RewrapResult struct {
+	Endpoints int `json:"endpoints"`
+	Projects  int `json:"projects"`
+}
+
+// EncryptedStore is a Store that encrypts the environment of the endpoints
+// and the projects at rest with their data key (envelope encryption). The
+// endpoints and the projects returned by the store have their environment
+// decrypted.
+// The writes of the environments and of the data keys of an endpoint or a
+// project are serialized, each of them reads the data keys it encrypts with
+// and writes them along with the environment.
+type EncryptedStore struct {
+	Store
+	keyring *encryption.Keyring
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock serializes the writes of the data keys of an endpoint or a
+// project, it is dropped once nobody holds or waits for it.
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewEncryptedStore returns a new EncryptedStore given the store that holds
+// the encrypted endpoints.
+func NewEncryptedStore(store Store, keyring *encryption.Keyring) *EncryptedStore {
+	return &EncryptedStore{
+		Store:   store,
+		keyring: keyring,
+		locks:   make(map[string]*keyLock),
+	}
+}
+
+// lock locks the data keys of the endpoint or the project of the key and
+// returns the function unlocking them.
+func (s *EncryptedStore) lock(key string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &keyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, key)
+		}
+	}
+}
+
+func endpointLockKey(id uuid.UUID) string {
+	return "endpoint/" + id.String()
+}
+
+func projectLockKey(project string) string {
+	return "project/" + project
+}
+
+func (s *EncryptedStore) CreateEndpoint(e *types.Endpoint) error {
+	dk, err := s.keyring.GenerateDataKey(1)
+	if err != nil {
+		return err
+	}
+	env, err := s.encryptEnv(dk, e.Environment)
+	if err != nil {
+		return err
+	}
+	// The caller keeps its plaintext copy of the endpoint.
+	encrypted := *e
+	encrypted.Environment = env
+	encrypted.DataKeys = []types.DataKey{dk}
+	return s.Store.CreateEndpoint(&encrypted)
+}
+
+func (s *EncryptedStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
+	if params.Environment == nil && params.PublishedEnvironment == nil {
+		return s.Store.UpdateEndpoint(id, params)
+	}
+	// Endpoints created before encryption was enabled get their first data
+	// key here, concurrent updates would each generate a different one.
+	defer s.lock(endpointLockKey(id))()
+	endpoint, err := s.Store.GetEndpoint(id)
+	if err != nil {
+		return err
+	}
+	keys := endpoint.DataKeys
+	dk, ok := types.LatestDataKey(keys)
+	if !ok {
+		// Endpoints created before encryption was enabled.
+		dk, err = s.keyring.GenerateDataKey(1)
+		if err != nil {
+			return err
+		}
+		keys = []types.DataKey{dk}
+		params.DataKeys = keys
+	}
+	if params.Environment != nil {
+		params.Environment, err = s.encryptEnv(dk, params.Environment)
+		if err != nil {
+			return err
+		}
+	}
+	if params.PublishedEnvironment != nil {
+		published := *params.PublishedEnvironment
+		published.Environment, err = s.encryptEnv(dk, published.Environment)
+		if err != nil {
+			return err
+		}
+		params.PublishedEnvironment = &published
+	}
+	return s.Store.UpdateEndpoint(id, params)
+}
+
+func (s *EncryptedStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
+	endpoint, err := s.Store.GetEndpoint(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(endpoint)
+}
+
+func (s *EncryptedStore) GetEndpoints() ([]types.Endpoint, error) {
+	endpoints, err := s.Store.GetEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]types.Endpoint, len(endpoints))
+	for i := range endpoints {
+		endpoint, err := s.decrypt(&endpoints[i])
+		if err != nil {
+			return nil, err
+		}
+		decrypted[i] = *endpoint
+	}
+	return decrypted, nil
+}
+
+// RotateDataKey adds a new data key version to the endpoint and re-encrypts
+// the environment and the published environment with it, plaintext values
+// that predate the encryption included. The older versions are kept.
+func (s *EncryptedStore) RotateDataKey(id uuid.UUID) (int, error) {
+	// The environment can not change while it is re-encrypted.
+	defer s.lock(endpointLockKey(id))()
+	endpoint, err := s.Store.GetEndpoint(id)
+	if err != nil {
+		return 0, err
+	}
+	keys := endpoint.DataKeys[:len(endpoint.DataKeys):len(endpoint.DataKeys)]
+	decrypted, err := s.decrypt(endpoint)
+	if err != nil {
+		return 0, err
+	}
+	version := 1
+	if latest, ok := types.LatestDataKey(keys); ok {
+		version = latest.Version + 1
+	}
+	dk, err := s.keyring.GenerateDataKey(version)
+	if err != nil {
+		return 0, err
+	}
+	params := UpdateEndpointParams{DataKeys: append(keys, dk)}
+	if params.Environment, err = s.encryptEnv(dk, decrypted.Environment); err != nil {
+		return 0, err
+	}
+	if decrypted.PublishedEnvironment != nil {
+		snapshot := *decrypted.PublishedEnvironment
+		if snapshot.Environment, err = s.encryptEnv(dk, snapshot.Environment); err != nil {
+			return 0, err
+		}
+		params.PublishedEnvironment = &snapshot
+	}
+	if err := s.Store.UpdateEndpoint(id, params); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// RewrapDataKeys re-wraps the data keys of the endpoints and the projects
+// with the current master key. The data keys themselves do not change, the
+// encrypted environments stay as they are.
+func (s *EncryptedStore) RewrapDataKeys() (RewrapResult, error) {
+	var result RewrapResult
+	endpoints, err := s.Store.GetEndpoints()
+	if err != nil {
+		return result, err
+	}
+	for _, endpoint := range endpoints {
+		rewrapped, err := s.rewrapEndpoint(endpoint.ID)
+		if err != nil {
+			return result, fmt.Errorf("failed to rewrap the data keys of endpoint %s: %w", endpoint.ID, err)
+		}
+		if rewrapped {
+			result.Endpoints++
+		}
+	}
+	projects, err := s.Store.GetProjectEnvironments()
+	if err != nil {
+		return result, err
+	}
+	for _, env := range projects {
+		rewrapped, err := s.rewrapProject(env.Project)
+		if err != nil {
+			return result, fmt.Errorf("failed to rewrap the data keys of project %s: %w", env.Project, err)
+		}
+		if rewrapped {
+			result.Projects++
+		}
+	}
+	return result, nil
+}
+
+// rewrapEndpoint re-wraps the data keys of the endpoint as they are once
+// they are locked, a rotation may have added one since they were listed.
+func (s *EncryptedStore) rewrapEndpoint(id uuid.UUID) (bool, error) {
+	defer s.lock(endpointLockKey(id))()
+	endpoint, err := s.Store.GetEndpoint(id)
+	if err != nil {
+		return false, err
+	}
+	keys, rewrapped, err := s.rewrap(endpoint.DataKeys)
+	if err != nil || !rewrapped {
+		return false, err
+	}
+	return true, s.Store.UpdateEndpoint(id, UpdateEndpointParams{DataKeys: keys})
+}
+
+func (s *EncryptedStore) rewrapProject(project string) (bool, error) {
+	defer s.lock(projectLockKey(project))()
+	env, err := s.Store.GetProjectEnvironment(project)
+	if err != nil {
+		return false, err
+	}
+	keys, rewrapped, err := s.rewrap(env.DataKeys)
+	if err != nil || !rewrapped {
+		return false, err
+	}
+	env.DataKeys = keys
+	return true, s.Store.UpdateProjectEnvironment(env)
+}
+
+// GetProjectEnvironments returns the project environments with their
+// environment decrypted.
+func (s *EncryptedStore) GetProjectEnvironments() ([]types.ProjectEnvironment, error) {
+	envs, err := s.Store.GetProjectEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	for i := range envs {
+		env, err := s.decryptProject(&envs[i])
+		if err != nil {
+			return nil, err
+		}
+		envs[i] = *env
+	}
+	return envs, nil
+}
+
+// rewrap returns a copy of the data keys with the keys wrapped by a previous
+// master key re-wrapped, and whether any key was.
+func (s *EncryptedStore) rewrap(keys []types.DataKey) ([]types.DataKey, bool, error) {
+	var (
+		rewrapped = make([]types.DataKey, len(keys))
+		changed   bool
+	)
+	for i, dk := range keys {
+		key, ok, err := s.keyring.Rewrap(dk)
+		if err != nil {
+			return nil, false, err
+		}
+		rewrapped[i] = key
+		changed = changed || ok
+	}
+	return rewrapped, changed, nil
+}
+
+// UpdateProjectEnvironment encrypts the environment with the data key of the
+// project, the key is generated along with the first environment of the
+// project.
+func (s *EncryptedStore) UpdateProjectEnvironment(env *types.ProjectEnvironment) error {
+	defer s.lock(projectLockKey(env.Project))()
+	current, err := s.Store.GetProjectEnvironment(env.Project)
+	if err != nil {
+		return err
+	}
+	keys := current.DataKeys
+	dk, ok := types.LatestDataKey(keys)
+	if !ok {
+		dk, err = s.keyring.GenerateDataKey(1)
+		if err != nil {
+			return err
+		}
+		keys = []types.DataKey{dk}
+	}
+	encrypted := *env
+	encrypted.Environment, err = s.encryptEnv(dk, env.Environment)
+	if err != nil {
+		return err
+	}
+	encrypted.DataKeys = keys
+	return s.Store.UpdateProjectEnvironment(&encrypted)
+}
+
+func (s *EncryptedStore) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	env, err := s.Store.GetProjectEnvironment(project)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptProject(env)
+}
+
+// decryptProject returns a copy of the project environment with its
+// environment decrypted.
+func (s *EncryptedStore) decryptProject(env *types.ProjectEnvironment) (*types.ProjectEnvironment, error) {
+	decrypted := *env
+	decrypted.Environment = make(map[string]string, len(env.Environment))
+	for k, v := range env.Environment {
+		value, _, err := s.keyring.Decrypt(env.DataKeys, v)
+		if err != nil {
+			return nil, err
+		}
+		decrypted.Environment[k] = value
+	}
+	return &decrypted, nil
+}
+
+// decrypt returns a copy of the endpoint with its environment decrypted.
+// Reads never write, the values encrypted with older data keys are
+// re-encrypted by RotateDataKey.
+func (s *EncryptedStore) decrypt(endpoint *types.Endpoint) (*types.Endpoint, error) {
+	var (
+		decrypted = *endpoint
+		env       = make(map[string]string, len(endpoint.Environment))
+	)
+	for k, v := range endpoint.Environment {
+		value, _, err := s.keyring.Decrypt(endpoint.DataKeys, v)
+		if err != nil {
+			return nil, err
+		}
+		env[k] = value
+	}
+	decrypted.Environment = env
+	if endpoint.PublishedEnvironment != nil {
+		published := *endpoint.PublishedEnvironment
+		published.Environment = make(map[string]string, len(endpoint.PublishedEnvironment.Environment))
+		for k, v := range endpoint.PublishedEnvironment.Environment {
+			value, _, err := s.keyring.Decrypt(endpoint.DataKeys, v)
+			if err != nil {
+				return nil, err
+			}
+			published.Environment[k] = value
+		}
+		decrypted.PublishedEnvironment = &published
+	}
+	return &decrypted, nil
+}
+
+func (s *EncryptedStore) encryptEnv(dk types.DataKey, env map[string]string) (map[string]string, error) {
+	encrypted := make(map[string]string, len(env))
+	for k, v := range env {
+		value, err := s.keyring.Encrypt(dk, v)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[k] = value
+	}
+	return encrypted, nil
+}
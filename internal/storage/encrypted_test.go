@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/encryption"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newMasterKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.Nil(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newEncryptedStore(t *testing.T, masterKey string, previousKeys ...string) *EncryptedStore {
+	keyring, err := encryption.NewKeyring(masterKey, previousKeys...)
+	require.Nil(t, err)
+	return NewEncryptedStore(NewMemoryStore(), keyring)
+}
+
+// requireEncrypted requires the stored environment of the endpoint to be
+// encrypted with the given data key version.
+func requireEncrypted(t *testing.T, s *EncryptedStore, id uuid.UUID, version int) {
+	t.Helper()
+	stored, err := s.Store.GetEndpoint(id)
+	require.Nil(t, err)
+	for k, v := range stored.Environment {
+		require.True(t, encryption.IsEncrypted(v), k)
+		_, got, err := s.keyring.Decrypt(stored.DataKeys, v)
+		require.Nil(t, err)
+		require.Equal(t, version, got, k)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	var (
+		masterKey = newMasterKey(t)
+		s         = newEncryptedStore(t, masterKey)
+		endpoint  = types.NewEndpoint("encrypted", "go", map[string]string{"TOKEN": "secret"})
+	)
+	require.Nil(t, s.CreateEndpoint(endpoint))
+	// The caller keeps its plaintext copy.
+	require.Equal(t, "secret", endpoint.Environment["TOKEN"])
+	requireEncrypted(t, s, endpoint.ID, 1)
+	got, err := s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"TOKEN": "secret"}, got.Environment)
+
+	require.Nil(t, s.UpdateEndpoint(endpoint.ID, UpdateEndpointParams{
+		Environment:          map[string]string{"TOKEN": "rotated"},
+		PublishedEnvironment: types.NewEnvironmentSnapshot(uuid.New(), map[string]string{"TOKEN": "secret"}),
+	}))
+	version, err := s.RotateDataKey(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, version)
+	requireEncrypted(t, s, endpoint.ID, 2)
+	got, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "rotated", got.Environment["TOKEN"])
+	require.Equal(t, "secret", got.PublishedEnvironment.Environment["TOKEN"])
+
+	// Re-wrapped data keys only need the new master key.
+	newKey := newMasterKey(t)
+	keyring, err := encryption.NewKeyring(newKey, masterKey)
+	require.Nil(t, err)
+	s.keyring = keyring
+	require.Nil(t, s.UpdateProjectEnvironment(&types.ProjectEnvironment{Project: "payments", Environment: map[string]string{"KEY": "value"}}))
+	result, err := s.RewrapDataKeys()
+	require.Nil(t, err)
+	require.Equal(t, RewrapResult{Endpoints: 1}, result)
+	keyring, err = encryption.NewKeyring(newKey)
+	require.Nil(t, err)
+	s.keyring = keyring
+	got, err = s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "rotated", got.Environment["TOKEN"])
+	env, err := s.GetProjectEnvironment("payments")
+	require.Nil(t, err)
+	require.Equal(t, "value", env.Environment["KEY"])
+}
+
+// pausingStore pauses the first update of an endpoint once it is written, so
+// another write can run while the first one is in flight.
+type pausingStore struct {
+	Store
+	updates atomic.Int32
+	paused  chan struct{}
+}
+
+func (s *pausingStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
+	if s.updates.Add(1) == 1 {
+		close(s.paused)
+		time.Sleep(time.Millisecond * 50)
+	}
+	return s.Store.UpdateEndpoint(id, params)
+}
+
+func newPausingStore(t *testing.T) (*EncryptedStore, *pausingStore) {
+	keyring, err := encryption.NewKeyring(newMasterKey(t))
+	require.Nil(t, err)
+	store := &pausingStore{Store: NewMemoryStore(), paused: make(chan struct{})}
+	return NewEncryptedStore(store, keyring), store
+}
+
+func TestEncryptedStoreRotateConcurrentUpdate(t *testing.T) {
+	s, store := newPausingStore(t)
+	endpoint := types.NewEndpoint("rotated", "go", map[string]string{"TOKEN": "old"})
+	require.Nil(t, s.CreateEndpoint(endpoint))
+
+	// The environment set while the rotation is in flight is not overwritten
+	// with the environment the rotation read.
+	done := make(chan error)
+	go func() {
+		_, err := s.RotateDataKey(endpoint.ID)
+		done <- err
+	}()
+	<-store.paused
+	require.Nil(t, s.UpdateEndpoint(endpoint.ID, UpdateEndpointParams{
+		Environment: map[string]string{"TOKEN": "new"},
+	}))
+	require.Nil(t, <-done)
+	got, err := s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "new", got.Environment["TOKEN"])
+	require.Empty(t, s.locks)
+}
+
+func TestEncryptedStoreLegacyConcurrentUpdates(t *testing.T) {
+	s, store := newPausingStore(t)
+	// An endpoint created before encryption was enabled has no data key,
+	// the concurrent updates must agree on the one they encrypt with.
+	endpoint := types.NewEndpoint("legacy", "go", map[string]string{"TOKEN": "plain"})
+	require.Nil(t, store.Store.CreateEndpoint(endpoint))
+
+	done := make(chan error)
+	go func() {
+		done <- s.UpdateEndpoint(endpoint.ID, UpdateEndpointParams{
+			Environment: map[string]string{"TOKEN": "env"},
+		})
+	}()
+	<-store.paused
+	require.Nil(t, s.UpdateEndpoint(endpoint.ID, UpdateEndpointParams{
+		PublishedEnvironment: types.NewEnvironmentSnapshot(uuid.New(), map[string]string{"TOKEN": "published"}),
+	}))
+	require.Nil(t, <-done)
+	got, err := s.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, "env", got.Environment["TOKEN"])
+	require.Equal(t, "published", got.PublishedEnvironment.Environment["TOKEN"])
+	stored, err := store.GetEndpoint(endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, stored.DataKeys, 1)
+	require.Empty(t, s.locks)
+}
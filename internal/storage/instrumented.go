@@ -0,0 +1,523 @@
+package storage
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// latencyBuckets are the upper bounds of the histogram buckets. Everything
+// above the last bound ends up in an implicit +Inf bucket.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 25,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 250,
+	time.Millisecond * 500,
+	time.Second,
+	time.Second * 5,
+}
+
+// Histogram is a latency histogram with fixed buckets.
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]int64, len(latencyBuckets)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns the upper bound of the bucket the given percentile
+// falls in. The max is returned for the +Inf bucket.
+func (h *Histogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	var (
+		rank = int64(float64(h.count)*p + 0.5)
+		seen int64
+	)
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank && i < len(latencyBuckets) {
+			return min(latencyBuckets[i], h.max)
+		}
+	}
+	return h.max
+}
+
+// HistogramSnapshot holds the state of a histogram at a point in time.
+type HistogramSnapshot struct {
+	Count   int64           `json:"count"`
+	Mean    time.Duration   `json:"mean"`
+	P50     time.Duration   `json:"p50"`
+	P99     time.Duration   `json:"p99"`
+	Max     time.Duration   `json:"max"`
+	Buckets []time.Duration `json:"buckets"`
+	Counts  []int64         `json:"counts"`
+}
+
+// Latencies holds a latency histogram per store operation.
+type Latencies struct {
+	mu  sync.Mutex
+	ops map[string]*Histogram
+}
+
+func NewLatencies() *Latencies {
+	return &Latencies{
+		ops: make(map[string]*Histogram),
+	}
+}
+
+// Observe records the duration of the given operation.
+func (l *Latencies) Observe(op string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.ops[op]
+	if !ok {
+		h = newHistogram()
+		l.ops[op] = h
+	}
+	h.observe(d)
+}
+
+// Snapshot returns the state of the histograms of all the operations.
+func (l *Latencies) Snapshot() map[string]HistogramSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	snapshot := make(map[string]HistogramSnapshot, len(l.ops))
+	for op, h := range l.ops {
+		counts := make([]int64, len(h.counts))
+		copy(counts, h.counts)
+		snapshot[op] = HistogramSnapshot{
+			Count:   h.count,
+			Mean:    h.sum / time.Duration(h.count),
+			P50:     h.percentile(0.5),
+			P99:     h.percentile(0.99),
+			Max:     h.max,
+			Buckets: latencyBuckets,
+			Counts:  counts,
+		}
+	}
+	return snapshot
+}
+
+// instrumentation records the latency of the store operations and logs the
+// operations that are slower than the threshold together with their key.
+type instrumentation struct {
+	latencies *Latencies
+	slow      time.Duration
+}
+
+func (i instrumentation) observe(op string, key any, start time.Time, err error) {
+	d := time.Since(start)
+	i.latencies.Observe(op, d)
+	if i.slow > 0 && d >= i.slow {
+		slog.Warn("slow store operation", "op", op, "key", key, "duration", d, "err", err)
+	}
+}
+
+// InstrumentedStore is a Store that records the latency of every operation
+// of the underlying store.
+type InstrumentedStore struct {
+	instrumentation
+	store Store
+}
+
+// NewInstrumentedStore returns a new InstrumentedStore given the store to
+// instrument. Operations slower than the given threshold are logged, a zero
+// threshold disables slow operation logging.
+func NewInstrumentedStore(store Store, latencies *Latencies, slow time.Duration) *InstrumentedStore {
+	return &InstrumentedStore{
+		instrumentation: instrumentation{latencies: latencies, slow: slow},
+		store:           store,
+	}
+}
+
+func (s *InstrumentedStore) CreateEndpoint(e *types.Endpoint) (err error) {
+	defer func(start time.Time) { s.observe("CreateEndpoint", e.ID, start, err) }(time.Now())
+	return s.store.CreateEndpoint(e)
+}
+
+func (s *InstrumentedStore) DeleteEndpoint(id uuid.UUID) (err error) {
+	defer func(start time.Time) { s.observe("DeleteEndpoint", id, start, err) }(time.Now())
+	return s.store.DeleteEndpoint(id)
+}
+
+func (s *InstrumentedStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) (err error) {
+	defer func(start time.Time) { s.observe("UpdateEndpoint", id, start, err) }(time.Now())
+	return s.store.UpdateEndpoint(id, params)
+}
+
+func (s *InstrumentedStore) GetEndpoint(id uuid.UUID) (_ *types.Endpoint, err error) {
+	defer func(start time.Time) { s.observe("GetEndpoint", id, start, err) }(time.Now())
+	return s.store.GetEndpoint(id)
+}
+
+func (s *InstrumentedStore) GetEndpoints() (_ []types.Endpoint, err error) {
+	defer func(start time.Time) { s.observe("GetEndpoints", nil, start, err) }(time.Now())
+	return s.store.GetEndpoints()
+}
+
+func (s *InstrumentedStore) CreateDeployment(deploy *types.Deployment) (err error) {
+	defer func(start time.Time) { s.observe("CreateDeployment", deploy.ID, start, err) }(time.Now())
+	return s.store.CreateDeployment(deploy)
+}
+
+func (s *InstrumentedStore) GetDeployment(id uuid.UUID) (_ *types.Deployment, err error) {
+	defer func(start time.Time) { s.observe("GetDeployment", id, start, err) }(time.Now())
+	return s.store.GetDeployment(id)
+}
+
+func (s *InstrumentedStore) GetDeployments(endpointID uuid.UUID) (_ []types.Deployment, err error) {
+	defer func(start time.Time) { s.observe("GetDeployments", endpointID, start, err) }(time.Now())
+	return s.store.GetDeployments(endpointID)
+}
+
+func (s *InstrumentedStore) DeleteDeployment(id uuid.UUID) (err error) {
+	defer func(start time.Time) { s.observe("DeleteDeployment", id, start, err) }(time.Now())
+	return s.store.DeleteDeployment(id)
+}
+
+func (s *InstrumentedStore) CreateDeploymentEvent(event *types.DeploymentEvent) (err error) {
+	defer func(start time.Time) { s.observe("CreateDeploymentEvent", event.EndpointID, start, err) }(time.Now())
+	return s.store.CreateDeploymentEvent(event)
+}
+
+func (s *InstrumentedStore) GetDeploymentEvents(endpointID uuid.UUID) (_ []types.DeploymentEvent, err error) {
+	defer func(start time.Time) { s.observe("GetDeploymentEvents", endpointID, start, err) }(time.Now())
+	return s.store.GetDeploymentEvents(endpointID)
+}
+
+func (s *InstrumentedStore) CreateIncident(incident *types.Incident) (err error) {
+	defer func(start time.Time) { s.observe("CreateIncident", incident.ID, start, err) }(time.Now())
+	return s.store.CreateIncident(incident)
+}
+
+func (s *InstrumentedStore) ResolveIncident(id uuid.UUID, at time.Time) (err error) {
+	defer func(start time.Time) { s.observe("ResolveIncident", id, start, err) }(time.Now())
+	return s.store.ResolveIncident(id, at)
+}
+
+func (s *InstrumentedStore) GetIncidents(resolvedAfter time.Time) (_ []types.Incident, err error) {
+	defer func(start time.Time) { s.observe("GetIncidents", nil, start, err) }(time.Now())
+	return s.store.GetIncidents(resolvedAfter)
+}
+
+func (s *InstrumentedStore) CreateAuditEntry(entry *types.AuditEntry) (err error) {
+	defer func(start time.Time) { s.observe("CreateAuditEntry", entry.EndpointID, start, err) }(time.Now())
+	return s.store.CreateAuditEntry(entry)
+}
+
+func (s *InstrumentedStore) GetAuditEntries(filter types.AuditFilter) (_ []types.AuditEntry, err error) {
+	defer func(start time.Time) { s.observe("GetAuditEntries", filter.EndpointID, start, err) }(time.Now())
+	return s.store.GetAuditEntries(filter)
+}
+
+func (s *InstrumentedStore) CreateDeadLetter(letter *types.DeadLetter) (err error) {
+	defer func(start time.Time) { s.observe("CreateDeadLetter", letter.EndpointID, start, err) }(time.Now())
+	return s.store.CreateDeadLetter(letter)
+}
+
+func (s *InstrumentedStore) GetDeadLetter(id uuid.UUID) (_ *types.DeadLetter, err error) {
+	defer func(start time.Time) { s.observe("GetDeadLetter", id, start, err) }(time.Now())
+	return s.store.GetDeadLetter(id)
+}
+
+func (s *InstrumentedStore) GetDeadLetters(endpointID uuid.UUID) (_ []types.DeadLetter, err error) {
+	defer func(start time.Time) { s.observe("GetDeadLetters", endpointID, start, err) }(time.Now())
+	return s.store.GetDeadLetters(endpointID)
+}
+
+func (s *InstrumentedStore) DeleteDeadLetter(id uuid.UUID) (err error) {
+	defer func(start time.Time) { s.observe("DeleteDeadLetter", id, start, err) }(time.Now())
+	return s.store.DeleteDeadLetter(id)
+}
+
+func (s *InstrumentedStore) CreateInvocation(invocation *types.Invocation) (err error) {
+	defer func(start time.Time) { s.observe("CreateInvocation", invocation.EndpointID, start, err) }(time.Now())
+	return s.store.CreateInvocation(invocation)
+}
+
+func (s *InstrumentedStore) UpdateInvocation(invocation *types.Invocation) (err error) {
+	defer func(start time.Time) { s.observe("UpdateInvocation", invocation.EndpointID, start, err) }(time.Now())
+	return s.store.UpdateInvocation(invocation)
+}
+
+func (s *InstrumentedStore) GetInvocation(id uuid.UUID) (_ *types.Invocation, err error) {
+	defer func(start time.Time) { s.observe("GetInvocation", id, start, err) }(time.Now())
+	return s.store.GetInvocation(id)
+}
+
+func (s *InstrumentedStore) CreateScheduledInvocation(scheduled *types.ScheduledInvocation) (err error) {
+	defer func(start time.Time) { s.observe("CreateScheduledInvocation", scheduled.EndpointID, start, err) }(time.Now())
+	return s.store.CreateScheduledInvocation(scheduled)
+}
+
+func (s *InstrumentedStore) UpdateScheduledInvocationStatus(scheduled *types.ScheduledInvocation, from string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe("UpdateScheduledInvocationStatus", scheduled.EndpointID, start, err) }(time.Now())
+	return s.store.UpdateScheduledInvocationStatus(scheduled, from)
+}
+
+func (s *InstrumentedStore) GetScheduledInvocation(id uuid.UUID) (_ *types.ScheduledInvocation, err error) {
+	defer func(start time.Time) { s.observe("GetScheduledInvocation", id, start, err) }(time.Now())
+	return s.store.GetScheduledInvocation(id)
+}
+
+func (s *InstrumentedStore) GetScheduledInvocations(endpointID uuid.UUID) (_ []types.ScheduledInvocation, err error) {
+	defer func(start time.Time) { s.observe("GetScheduledInvocations", endpointID, start, err) }(time.Now())
+	return s.store.GetScheduledInvocations(endpointID)
+}
+
+func (s *InstrumentedStore) GetDueScheduledInvocations(now time.Time) (_ []types.ScheduledInvocation, err error) {
+	defer func(start time.Time) { s.observe("GetDueScheduledInvocations", nil, start, err) }(time.Now())
+	return s.store.GetDueScheduledInvocations(now)
+}
+
+func (s *InstrumentedStore) CreateWorkflow(workflow *types.Workflow) (err error) {
+	defer func(start time.Time) { s.observe("CreateWorkflow", workflow.EndpointID, start, err) }(time.Now())
+	return s.store.CreateWorkflow(workflow)
+}
+
+func (s *InstrumentedStore) UpdateWorkflow(workflow *types.Workflow) (err error) {
+	defer func(start time.Time) { s.observe("UpdateWorkflow", workflow.EndpointID, start, err) }(time.Now())
+	return s.store.UpdateWorkflow(workflow)
+}
+
+func (s *InstrumentedStore) GetWorkflow(id uuid.UUID) (_ *types.Workflow, err error) {
+	defer func(start time.Time) { s.observe("GetWorkflow", id, start, err) }(time.Now())
+	return s.store.GetWorkflow(id)
+}
+
+func (s *InstrumentedStore) GetDueWorkflows(now time.Time) (_ []types.Workflow, err error) {
+	defer func(start time.Time) { s.observe("GetDueWorkflows", nil, start, err) }(time.Now())
+	return s.store.GetDueWorkflows(now)
+}
+
+func (s *InstrumentedStore) UpdateProjectEnvironment(env *types.ProjectEnvironment) (err error) {
+	defer func(start time.Time) { s.observe("UpdateProjectEnvironment", env.Project, start, err) }(time.Now())
+	return s.store.UpdateProjectEnvironment(env)
+}
+
+func (s *InstrumentedStore) GetProjectEnvironment(project string) (_ *types.ProjectEnvironment, err error) {
+	defer func(start time.Time) { s.observe("GetProjectEnvironment", project, start, err) }(time.Now())
+	return s.store.GetProjectEnvironment(project)
+}
+
+func (s *InstrumentedStore) GetProjectEnvironments() (_ []types.ProjectEnvironment, err error) {
+	defer func(start time.Time) { s.observe("GetProjectEnvironments", nil, start, err) }(time.Now())
+	return s.store.GetProjectEnvironments()
+}
+
+func (s *InstrumentedStore) CreateBuild(build *types.Build) (err error) {
+	defer func(start time.Time) { s.observe("CreateBuild", build.EndpointID, start, err) }(time.Now())
+	return s.store.CreateBuild(build)
+}
+
+func (s *InstrumentedStore) UpdateBuild(build *types.Build) (err error) {
+	defer func(start time.Time) { s.observe("UpdateBuild", build.EndpointID, start, err) }(time.Now())
+	return s.store.UpdateBuild(build)
+}
+
+func (s *InstrumentedStore) GetBuild(id uuid.UUID) (_ *types.Build, err error) {
+	defer func(start time.Time) { s.observe("GetBuild", id, start, err) }(time.Now())
+	return s.store.GetBuild(id)
+}
+
+func (s *InstrumentedStore) CreateEvent(event *types.Event) (err error) {
+	defer func(start time.Time) { s.observe("CreateEvent", event.EndpointID, start, err) }(time.Now())
+	return s.store.CreateEvent(event)
+}
+
+func (s *InstrumentedStore) GetEvents(endpointID uuid.UUID) (_ []types.Event, err error) {
+	defer func(start time.Time) { s.observe("GetEvents", endpointID, start, err) }(time.Now())
+	return s.store.GetEvents(endpointID)
+}
+
+func (s *InstrumentedStore) CreateWebhook(webhook *types.Webhook) (err error) {
+	defer func(start time.Time) { s.observe("CreateWebhook", webhook.EndpointID, start, err) }(time.Now())
+	return s.store.CreateWebhook(webhook)
+}
+
+func (s *InstrumentedStore) GetWebhooks(endpointID uuid.UUID) (_ []types.Webhook, err error) {
+	defer func(start time.Time) { s.observe("GetWebhooks", endpointID, start, err) }(time.Now())
+	return s.store.GetWebhooks(endpointID)
+}
+
+func (s *InstrumentedStore) DeleteWebhook(id uuid.UUID) (err error) {
+	defer func(start time.Time) { s.observe("DeleteWebhook", id, start, err) }(time.Now())
+	return s.store.DeleteWebhook(id)
+}
+
+// InstrumentedMetricStore is a MetricStore that records the latency of every
+// operation of the underlying metric store.
+type InstrumentedMetricStore struct {
+	instrumentation
+	store MetricStore
+}
+
+// NewInstrumentedMetricStore returns a new InstrumentedMetricStore given the
+// metric store to instrument.
+func NewInstrumentedMetricStore(store MetricStore, latencies *Latencies, slow time.Duration) *InstrumentedMetricStore {
+	return &InstrumentedMetricStore{
+		instrumentation: instrumentation{latencies: latencies, slow: slow},
+		store:           store,
+	}
+}
+
+func (s *InstrumentedMetricStore) CreateRuntimeMetric(metric *types.RuntimeMetric) (err error) {
+	defer func(start time.Time) { s.observe("CreateRuntimeMetric", metric.ID, start, err) }(time.Now())
+	return s.store.CreateRuntimeMetric(metric)
+}
+
+func (s *InstrumentedMetricStore) GetRuntimeMetrics(id uuid.UUID) (_ []types.RuntimeMetric, err error) {
+	defer func(start time.Time) { s.observe("GetRuntimeMetrics", id, start, err) }(time.Now())
+	return s.store.GetRuntimeMetrics(id)
+}
+
+func (s *InstrumentedMetricStore) CreateRequestMetric(metric *types.RequestMetric) (err error) {
+	defer func(start time.Time) { s.observe("CreateRequestMetric", metric.EndpointID, start, err) }(time.Now())
+	return s.store.CreateRequestMetric(metric)
+}
+
+func (s *InstrumentedMetricStore) CreateRequestMetrics(metrics []types.RequestMetric) (err error) {
+	defer func(start time.Time) { s.observe("CreateRequestMetrics", len(metrics), start, err) }(time.Now())
+	return s.store.CreateRequestMetrics(metrics)
+}
+
+func (s *InstrumentedMetricStore) GetRequestMetrics(endpointID uuid.UUID) (_ []types.RequestMetric, err error) {
+	defer func(start time.Time) { s.observe("GetRequestMetrics", endpointID, start, err) }(time.Now())
+	return s.store.GetRequestMetrics(endpointID)
+}
+
+func (s *InstrumentedMetricStore) GetRequestMetricsBetween(from, to time.Time) (_ []types.RequestMetric, err error) {
+	defer func(start time.Time) { s.observe("GetRequestMetricsBetween", nil, start, err) }(time.Now())
+	return s.store.GetRequestMetricsBetween(from, to)
+}
+
+func (s *InstrumentedMetricStore) CreateMetricRollups(rollups []types.MetricRollup) (err error) {
+	defer func(start time.Time) { s.observe("CreateMetricRollups", len(rollups), start, err) }(time.Now())
+	return s.store.CreateMetricRollups(rollups)
+}
+
+func (s *InstrumentedMetricStore) GetMetricRollups(endpointID uuid.UUID, resolution time.Duration, from, to time.Time) (_ []types.MetricRollup, err error) {
+	defer func(start time.Time) { s.observe("GetMetricRollups", endpointID, start, err) }(time.Now())
+	return s.store.GetMetricRollups(endpointID, resolution, from, to)
+}
+
+func (s *InstrumentedMetricStore) DeleteMetricRollups(resolution time.Duration, before time.Time) (_ int, err error) {
+	defer func(start time.Time) { s.observe("DeleteMetricRollups", nil, start, err) }(time.Now())
+	return s.store.DeleteMetricRollups(resolution, before)
+}
+
+func (s *InstrumentedMetricStore) DeleteRequestMetrics(before time.Time) (_ int, err error) {
+	defer func(start time.Time) { s.observe("DeleteRequestMetrics", nil, start, err) }(time.Now())
+	return s.store.DeleteRequestMetrics(before)
+}
+
+func (s *InstrumentedMetricStore) GetRequestStats(since time.Time, window time.Duration) (_ []types.RequestStats, err error) {
+	defer func(start time.Time) { s.observe("GetRequestStats", nil, start, err) }(time.Now())
+	return s.store.GetRequestStats(since, window)
+}
+
+func (s *InstrumentedMetricStore) CreateProbeResult(result *types.ProbeResult) (err error) {
+	defer func(start time.Time) { s.observe("CreateProbeResult", result.EndpointID, start, err) }(time.Now())
+	return s.store.CreateProbeResult(result)
+}
+
+func (s *InstrumentedMetricStore) GetProbeResults(endpointID uuid.UUID) (_ []types.ProbeResult, err error) {
+	defer func(start time.Time) { s.observe("GetProbeResults", endpointID, start, err) }(time.Now())
+	return s.store.GetProbeResults(endpointID)
+}
+
+func (s *InstrumentedMetricStore) CreateHealthCheck(check *types.HealthCheck) (err error) {
+	defer func(start time.Time) { s.observe("CreateHealthCheck", check.EndpointID, start, err) }(time.Now())
+	return s.store.CreateHealthCheck(check)
+}
+
+func (s *InstrumentedMetricStore) GetHealthChecks(deploymentID uuid.UUID) (_ []types.HealthCheck, err error) {
+	defer func(start time.Time) { s.observe("GetHealthChecks", deploymentID, start, err) }(time.Now())
+	return s.store.GetHealthChecks(deploymentID)
+}
+
+func (s *InstrumentedMetricStore) CreateCacheMetric(metric *types.CacheMetric) (err error) {
+	defer func(start time.Time) { s.observe("CreateCacheMetric", metric.EndpointID, start, err) }(time.Now())
+	return s.store.CreateCacheMetric(metric)
+}
+
+func (s *InstrumentedMetricStore) GetCacheMetrics(endpointID uuid.UUID) (_ []types.CacheMetric, err error) {
+	defer func(start time.Time) { s.observe("GetCacheMetrics", endpointID, start, err) }(time.Now())
+	return s.store.GetCacheMetrics(endpointID)
+}
+
+func (s *InstrumentedMetricStore) CreateConcurrencyMetric(metric *types.ConcurrencyMetric) (err error) {
+	defer func(start time.Time) { s.observe("CreateConcurrencyMetric", metric.EndpointID, start, err) }(time.Now())
+	return s.store.CreateConcurrencyMetric(metric)
+}
+
+func (s *InstrumentedMetricStore) GetConcurrencyMetrics(endpointID uuid.UUID, since time.Time) (_ []types.ConcurrencyMetric, err error) {
+	defer func(start time.Time) { s.observe("GetConcurrencyMetrics", endpointID, start, err) }(time.Now())
+	return s.store.GetConcurrencyMetrics(endpointID, since)
+}
+
+func (s *InstrumentedMetricStore) DeleteConcurrencyMetrics(before time.Time) (_ int, err error) {
+	defer func(start time.Time) { s.observe("DeleteConcurrencyMetrics", nil, start, err) }(time.Now())
+	return s.store.DeleteConcurrencyMetrics(before)
+}
+
+func (s *InstrumentedMetricStore) AddUsage(record *types.UsageRecord) (err error) {
+	defer func(start time.Time) { s.observe("AddUsage", record.EndpointID, start, err) }(time.Now())
+	return s.store.AddUsage(record)
+}
+
+func (s *InstrumentedMetricStore) GetUsage(period time.Time) (_ []types.UsageRecord, err error) {
+	defer func(start time.Time) { s.observe("GetUsage", nil, start, err) }(time.Now())
+	return s.store.GetUsage(period)
+}
+
+func (s *InstrumentedMetricStore) CreateInvocationError(e *types.InvocationError) (err error) {
+	defer func(start time.Time) { s.observe("CreateInvocationError", e.EndpointID, start, err) }(time.Now())
+	return s.store.CreateInvocationError(e)
+}
+
+func (s *InstrumentedMetricStore) GetInvocationErrors(endpointID uuid.UUID) (_ []types.InvocationError, err error) {
+	defer func(start time.Time) { s.observe("GetInvocationErrors", endpointID, start, err) }(time.Now())
+	return s.store.GetInvocationErrors(endpointID)
+}
+
+func (s *InstrumentedMetricStore) CreateCapturedRequest(r *types.CapturedRequest) (err error) {
+	defer func(start time.Time) { s.observe("CreateCapturedRequest", r.EndpointID, start, err) }(time.Now())
+	return s.store.CreateCapturedRequest(r)
+}
+
+func (s *InstrumentedMetricStore) GetCapturedRequest(id uuid.UUID) (_ *types.CapturedRequest, err error) {
+	defer func(start time.Time) { s.observe("GetCapturedRequest", id, start, err) }(time.Now())
+	return s.store.GetCapturedRequest(id)
+}
+
+func (s *InstrumentedMetricStore) GetCapturedRequests(endpointID uuid.UUID) (_ []types.CapturedRequest, err error) {
+	defer func(start time.Time) { s.observe("GetCapturedRequests", endpointID, start, err) }(time.Now())
+	return s.store.GetCapturedRequests(endpointID)
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
@@ -12,12 +14,65 @@ type MemoryStore struct {
 	mu        sync.RWMutex
 	endpoints map[uuid.UUID]*types.Endpoint
 	deploys   map[uuid.UUID]*types.Deployment
+	requests  map[uuid.UUID][]types.RequestMetric
+	probes    map[uuid.UUID][]types.ProbeResult
+	health    map[uuid.UUID][]types.HealthCheck
+	caches    map[uuid.UUID][]types.CacheMetric
+	conc      map[uuid.UUID][]types.ConcurrencyMetric
+	errors    map[uuid.UUID][]types.InvocationError
+	captures  map[uuid.UUID][]types.CapturedRequest
+	events    map[uuid.UUID][]types.DeploymentEvent
+	incidents map[uuid.UUID]*types.Incident
+	builds    map[uuid.UUID]*types.Build
+	platform  map[uuid.UUID][]types.Event
+	webhooks  map[uuid.UUID][]types.Webhook
+	letters   map[uuid.UUID]*types.DeadLetter
+	invokes   map[uuid.UUID]*types.Invocation
+	workflows map[uuid.UUID]*types.Workflow
+	schedules map[uuid.UUID]*types.ScheduledInvocation
+	projects  map[string]*types.ProjectEnvironment
+	audit     []types.AuditEntry
+	usage     map[usageKey]*types.UsageRecord
+	rollups   map[rollupKey]types.MetricRollup
+	// Blobs of the deployments by hash, shared by identical deployments.
+	blobs map[string][]byte
+}
+
+type usageKey struct {
+	endpointID uuid.UUID
+	period     time.Time
+}
+
+type rollupKey struct {
+	endpointID uuid.UUID
+	resolution time.Duration
+	start      time.Time
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		endpoints: make(map[uuid.UUID]*types.Endpoint),
 		deploys:   make(map[uuid.UUID]*types.Deployment),
+		requests:  make(map[uuid.UUID][]types.RequestMetric),
+		probes:    make(map[uuid.UUID][]types.ProbeResult),
+		health:    make(map[uuid.UUID][]types.HealthCheck),
+		caches:    make(map[uuid.UUID][]types.CacheMetric),
+		conc:      make(map[uuid.UUID][]types.ConcurrencyMetric),
+		errors:    make(map[uuid.UUID][]types.InvocationError),
+		captures:  make(map[uuid.UUID][]types.CapturedRequest),
+		events:    make(map[uuid.UUID][]types.DeploymentEvent),
+		incidents: make(map[uuid.UUID]*types.Incident),
+		builds:    make(map[uuid.UUID]*types.Build),
+		platform:  make(map[uuid.UUID][]types.Event),
+		webhooks:  make(map[uuid.UUID][]types.Webhook),
+		letters:   make(map[uuid.UUID]*types.DeadLetter),
+		invokes:   make(map[uuid.UUID]*types.Invocation),
+		workflows: make(map[uuid.UUID]*types.Workflow),
+		schedules: make(map[uuid.UUID]*types.ScheduledInvocation),
+		projects:  make(map[string]*types.ProjectEnvironment),
+		usage:     make(map[usageKey]*types.UsageRecord),
+		rollups:   make(map[rollupKey]types.MetricRollup),
+		blobs:     make(map[string][]byte),
 	}
 }
 
@@ -38,6 +93,27 @@ func (s *MemoryStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
 	return e, nil
 }
 
+func (s *MemoryStore) GetEndpoints() ([]types.Endpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	endpoints := make([]types.Endpoint, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints, nil
+}
+
+func (s *MemoryStore) DeleteEndpoint(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[id]; !ok {
+		return fmt.Errorf("could not find endpoint with id (%s)", id)
+	}
+	delete(s.endpoints, id)
+	delete(s.webhooks, id)
+	return nil
+}
+
 func (s *MemoryStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
 	endpoint, err := s.GetEndpoint(id)
 	if err != nil {
@@ -48,10 +124,89 @@ func (s *MemoryStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams)
 	if params.ActiveDeployID.String() != "00000000-0000-0000-0000-000000000000" {
 		endpoint.ActiveDeploymentID = params.ActiveDeployID
 	}
+	if len(params.Name) > 0 {
+		endpoint.Name = params.Name
+	}
+	if len(params.Runtime) > 0 {
+		endpoint.Runtime = params.Runtime
+	}
 	if params.Environment != nil {
-		for key, val := range params.Environment {
-			endpoint.Environment[key] = val
-		}
+		endpoint.Environment = params.Environment
+	}
+	if params.SessionAffinity != nil {
+		endpoint.SessionAffinity = params.SessionAffinity
+	}
+	if params.Regions != nil {
+		endpoint.Regions = params.Regions
+	}
+	if params.MaxConcurrency != nil {
+		endpoint.MaxConcurrency = *params.MaxConcurrency
+	}
+	if params.Probes != nil {
+		endpoint.Probes = params.Probes
+	}
+	if params.StaticResponses != nil {
+		endpoint.StaticResponses = params.StaticResponses
+	}
+	if params.Routes != nil {
+		endpoint.Routes = params.Routes
+	}
+	if params.Deprecation != nil {
+		endpoint.Deprecation = params.Deprecation
+	}
+	if params.DataKeys != nil {
+		endpoint.DataKeys = params.DataKeys
+	}
+	if params.Cache != nil {
+		endpoint.Cache = params.Cache
+	}
+	if params.Compression != nil {
+		endpoint.Compression = params.Compression
+	}
+	if params.Limits != nil {
+		endpoint.Limits = params.Limits
+	}
+	if params.WebSocket != nil {
+		endpoint.WebSocket = params.WebSocket
+	}
+	if params.Fallback != nil {
+		endpoint.Fallback = params.Fallback
+	}
+	if params.Retention != nil {
+		endpoint.Retention = params.Retention
+	}
+	if params.EnvironmentSchema != nil {
+		endpoint.EnvironmentSchema = params.EnvironmentSchema
+	}
+	if params.Ownership != nil {
+		endpoint.Ownership = params.Ownership
+	}
+	if params.Rollout != nil {
+		endpoint.Rollout = params.Rollout
+	}
+	if params.PublishedEnvironment != nil {
+		endpoint.PublishedEnvironment = params.PublishedEnvironment
+	}
+	if params.Retry != nil {
+		endpoint.Retry = params.Retry
+	}
+	if params.Capture != nil {
+		endpoint.Capture = params.Capture
+	}
+	if params.CORS != nil {
+		endpoint.CORS = params.CORS
+	}
+	if params.Verification != nil {
+		endpoint.Verification = params.Verification
+	}
+	if params.JWT != nil {
+		endpoint.JWT = params.JWT
+	}
+	if params.Maintenance != nil {
+		endpoint.Maintenance = params.Maintenance
+	}
+	if params.Prewarm != nil {
+		endpoint.Prewarm = params.Prewarm
 	}
 	return nil
 }
@@ -59,6 +214,11 @@ func (s *MemoryStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams)
 func (s *MemoryStore) CreateDeployment(deploy *types.Deployment) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if blob, ok := s.blobs[deploy.Hash]; ok {
+		deploy.Blob = blob
+	} else {
+		s.blobs[deploy.Hash] = deploy.Blob
+	}
 	s.deploys[deploy.ID] = deploy
 	return nil
 }
@@ -73,6 +233,41 @@ func (s *MemoryStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
 	return deploy, nil
 }
 
+func (s *MemoryStore) GetDeployments(endpointID uuid.UUID) ([]types.Deployment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var deploys []types.Deployment
+	for _, deploy := range s.deploys {
+		if deploy.EndpointID != endpointID {
+			continue
+		}
+		d := *deploy
+		d.Blob, d.Assets = nil, nil
+		deploys = append(deploys, d)
+	}
+	sort.Slice(deploys, func(i, j int) bool {
+		return deploys[i].CreatedAT.Before(deploys[j].CreatedAT)
+	})
+	return deploys, nil
+}
+
+func (s *MemoryStore) DeleteDeployment(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deploy, ok := s.deploys[id]
+	if !ok {
+		return fmt.Errorf("could not find deployment with id (%s)", id)
+	}
+	delete(s.deploys, id)
+	for _, other := range s.deploys {
+		if other.Hash == deploy.Hash {
+			return nil
+		}
+	}
+	delete(s.blobs, deploy.Hash)
+	return nil
+}
+
 func (s *MemoryStore) CreateRuntimeMetric(_ *types.RuntimeMetric) error {
 	return nil
 }
@@ -80,3 +275,638 @@ func (s *MemoryStore) CreateRuntimeMetric(_ *types.RuntimeMetric) error {
 func (s *MemoryStore) GetRuntimeMetrics(_ uuid.UUID) ([]types.RuntimeMetric, error) {
 	return nil, nil
 }
+
+func (s *MemoryStore) CreateRequestMetric(metric *types.RequestMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[metric.EndpointID] = append(s.requests[metric.EndpointID], *metric)
+	return nil
+}
+
+func (s *MemoryStore) CreateRequestMetrics(metrics []types.RequestMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, metric := range metrics {
+		s.requests[metric.EndpointID] = append(s.requests[metric.EndpointID], metric)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	metrics := make([]types.RequestMetric, len(s.requests[endpointID]))
+	copy(metrics, s.requests[endpointID])
+	return metrics, nil
+}
+
+func (s *MemoryStore) CreateDeploymentEvent(event *types.DeploymentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[event.EndpointID] = append(s.events[event.EndpointID], *event)
+	return nil
+}
+
+func (s *MemoryStore) GetDeploymentEvents(endpointID uuid.UUID) ([]types.DeploymentEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := make([]types.DeploymentEvent, len(s.events[endpointID]))
+	copy(events, s.events[endpointID])
+	return events, nil
+}
+
+func (s *MemoryStore) GetRequestStats(since time.Time, window time.Duration) ([]types.RequestStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	windows := make(map[time.Time]*types.RequestStats)
+	for _, metrics := range s.requests {
+		for _, metric := range metrics {
+			if metric.CreatedAT.Before(since) {
+				continue
+			}
+			start := metric.CreatedAT.Truncate(window)
+			stats, ok := windows[start]
+			if !ok {
+				stats = &types.RequestStats{Start: start}
+				windows[start] = stats
+			}
+			stats.Requests++
+			if metric.StatusCode >= 500 {
+				stats.Errors++
+			}
+		}
+	}
+	result := make([]types.RequestStats, 0, len(windows))
+	for _, stats := range windows {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) CreateIncident(incident *types.Incident) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := *incident
+	s.incidents[incident.ID] = &i
+	return nil
+}
+
+func (s *MemoryStore) ResolveIncident(id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incident, ok := s.incidents[id]
+	if !ok {
+		return fmt.Errorf("could not find incident with id (%s)", id)
+	}
+	incident.ResolvedAT = &at
+	return nil
+}
+
+func (s *MemoryStore) GetIncidents(resolvedAfter time.Time) ([]types.Incident, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	incidents := []types.Incident{}
+	for _, incident := range s.incidents {
+		if incident.Active() || incident.ResolvedAT.After(resolvedAfter) {
+			incidents = append(incidents, *incident)
+		}
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].CreatedAT.After(incidents[j].CreatedAT)
+	})
+	return incidents, nil
+}
+
+func (s *MemoryStore) CreateAuditEntry(entry *types.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, *entry)
+	return nil
+}
+
+func (s *MemoryStore) GetAuditEntries(filter types.AuditFilter) ([]types.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := []types.AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		if filter.Match(s.audit[i]) {
+			entries = append(entries, s.audit[i])
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) CreateBuild(build *types.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := *build
+	s.builds[build.ID] = &b
+	return nil
+}
+
+func (s *MemoryStore) UpdateBuild(build *types.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builds[build.ID]; !ok {
+		return fmt.Errorf("could not find build with id (%s)", build.ID)
+	}
+	b := *build
+	s.builds[build.ID] = &b
+	return nil
+}
+
+func (s *MemoryStore) GetBuild(id uuid.UUID) (*types.Build, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	build, ok := s.builds[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find build with id (%s)", id)
+	}
+	b := *build
+	return &b, nil
+}
+
+func (s *MemoryStore) CreateEvent(event *types.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.platform[event.EndpointID] = append(s.platform[event.EndpointID], *event)
+	return nil
+}
+
+func (s *MemoryStore) GetEvents(endpointID uuid.UUID) ([]types.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := make([]types.Event, len(s.platform[endpointID]))
+	copy(events, s.platform[endpointID])
+	return events, nil
+}
+
+func (s *MemoryStore) CreateWebhook(webhook *types.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks[webhook.EndpointID] = append(s.webhooks[webhook.EndpointID], *webhook)
+	return nil
+}
+
+func (s *MemoryStore) GetWebhooks(endpointID uuid.UUID) ([]types.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	webhooks := make([]types.Webhook, len(s.webhooks[endpointID]))
+	copy(webhooks, s.webhooks[endpointID])
+	return webhooks, nil
+}
+
+func (s *MemoryStore) DeleteWebhook(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for endpointID, webhooks := range s.webhooks {
+		for i, webhook := range webhooks {
+			if webhook.ID == id {
+				s.webhooks[endpointID] = append(webhooks[:i:i], webhooks[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find webhook with id (%s)", id)
+}
+
+func (s *MemoryStore) CreateDeadLetter(letter *types.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters[letter.ID] = letter
+	return nil
+}
+
+func (s *MemoryStore) GetDeadLetter(id uuid.UUID) (*types.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	letter, ok := s.letters[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find dead letter with id (%s)", id)
+	}
+	return letter, nil
+}
+
+func (s *MemoryStore) GetDeadLetters(endpointID uuid.UUID) ([]types.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	letters := []types.DeadLetter{}
+	for _, letter := range s.letters {
+		if letter.EndpointID == endpointID {
+			letters = append(letters, *letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].CreatedAT.Before(letters[j].CreatedAT)
+	})
+	return letters, nil
+}
+
+func (s *MemoryStore) DeleteDeadLetter(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.letters[id]; !ok {
+		return fmt.Errorf("could not find dead letter with id (%s)", id)
+	}
+	delete(s.letters, id)
+	return nil
+}
+
+func (s *MemoryStore) CreateInvocation(invocation *types.Invocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := *invocation
+	s.invokes[invocation.ID] = &i
+	return nil
+}
+
+func (s *MemoryStore) UpdateInvocation(invocation *types.Invocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.invokes[invocation.ID]; !ok {
+		return fmt.Errorf("could not find invocation with id (%s)", invocation.ID)
+	}
+	i := *invocation
+	s.invokes[invocation.ID] = &i
+	return nil
+}
+
+func (s *MemoryStore) GetInvocation(id uuid.UUID) (*types.Invocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invocation, ok := s.invokes[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find invocation with id (%s)", id)
+	}
+	i := *invocation
+	return &i, nil
+}
+
+func (s *MemoryStore) CreateScheduledInvocation(scheduled *types.ScheduledInvocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := *scheduled
+	s.schedules[scheduled.ID] = &i
+	return nil
+}
+
+func (s *MemoryStore) UpdateScheduledInvocationStatus(scheduled *types.ScheduledInvocation, from string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.schedules[scheduled.ID]
+	if !ok || current.Status != from {
+		return false, nil
+	}
+	current.Status = scheduled.Status
+	current.InvocationID = scheduled.InvocationID
+	current.Error = scheduled.Error
+	return true, nil
+}
+
+func (s *MemoryStore) GetScheduledInvocation(id uuid.UUID) (*types.ScheduledInvocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scheduled, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find scheduled invocation with id (%s)", id)
+	}
+	i := *scheduled
+	return &i, nil
+}
+
+func (s *MemoryStore) GetScheduledInvocations(endpointID uuid.UUID) ([]types.ScheduledInvocation, error) {
+	return s.scheduledInvocations(func(scheduled *types.ScheduledInvocation) bool {
+		return scheduled.EndpointID == endpointID
+	}), nil
+}
+
+func (s *MemoryStore) GetDueScheduledInvocations(now time.Time) ([]types.ScheduledInvocation, error) {
+	return s.scheduledInvocations(func(scheduled *types.ScheduledInvocation) bool {
+		return scheduled.Status == types.SchedulePending && !scheduled.RunAT.After(now)
+	}), nil
+}
+
+func (s *MemoryStore) scheduledInvocations(match func(*types.ScheduledInvocation) bool) []types.ScheduledInvocation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scheduled := []types.ScheduledInvocation{}
+	for _, i := range s.schedules {
+		if match(i) {
+			scheduled = append(scheduled, *i)
+		}
+	}
+	sort.Slice(scheduled, func(i, j int) bool {
+		return scheduled[i].RunAT.Before(scheduled[j].RunAT)
+	})
+	return scheduled
+}
+
+func (s *MemoryStore) CreateWorkflow(workflow *types.Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := *workflow
+	s.workflows[workflow.ID] = &w
+	return nil
+}
+
+func (s *MemoryStore) UpdateWorkflow(workflow *types.Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workflows[workflow.ID]; !ok {
+		return fmt.Errorf("could not find workflow with id (%s)", workflow.ID)
+	}
+	w := *workflow
+	s.workflows[workflow.ID] = &w
+	return nil
+}
+
+func (s *MemoryStore) GetWorkflow(id uuid.UUID) (*types.Workflow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	workflow, ok := s.workflows[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find workflow with id (%s)", id)
+	}
+	w := *workflow
+	return &w, nil
+}
+
+func (s *MemoryStore) GetDueWorkflows(now time.Time) ([]types.Workflow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	workflows := []types.Workflow{}
+	for _, workflow := range s.workflows {
+		if workflow.Status == types.WorkflowSleeping && workflow.WakeAT != nil && !workflow.WakeAT.After(now) {
+			workflows = append(workflows, *workflow)
+		}
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].WakeAT.Before(*workflows[j].WakeAT)
+	})
+	return workflows, nil
+}
+
+func (s *MemoryStore) UpdateProjectEnvironment(env *types.ProjectEnvironment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.projects[env.Project] = copyProjectEnvironment(env)
+	return nil
+}
+
+func (s *MemoryStore) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	env, ok := s.projects[project]
+	if !ok {
+		return &types.ProjectEnvironment{Project: project, Environment: map[string]string{}}, nil
+	}
+	return copyProjectEnvironment(env), nil
+}
+
+func (s *MemoryStore) GetProjectEnvironments() ([]types.ProjectEnvironment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	envs := make([]types.ProjectEnvironment, 0, len(s.projects))
+	for _, env := range s.projects {
+		envs = append(envs, *copyProjectEnvironment(env))
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Project < envs[j].Project
+	})
+	return envs, nil
+}
+
+func (s *MemoryStore) DeleteRequestMetrics(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int
+	for endpointID, metrics := range s.requests {
+		kept := make([]types.RequestMetric, 0, len(metrics))
+		for _, metric := range metrics {
+			if metric.CreatedAT.Before(before) {
+				deleted++
+				continue
+			}
+			kept = append(kept, metric)
+		}
+		s.requests[endpointID] = kept
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) GetRequestMetricsBetween(from, to time.Time) ([]types.RequestMetric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	metrics := []types.RequestMetric{}
+	for _, requests := range s.requests {
+		for _, metric := range requests {
+			if !metric.CreatedAT.Before(from) && metric.CreatedAT.Before(to) {
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].CreatedAT.Before(metrics[j].CreatedAT) })
+	return metrics, nil
+}
+
+func (s *MemoryStore) CreateMetricRollups(rollups []types.MetricRollup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rollup := range rollups {
+		s.rollups[rollupKey{endpointID: rollup.EndpointID, resolution: rollup.Resolution, start: rollup.Start}] = rollup
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetMetricRollups(endpointID uuid.UUID, resolution time.Duration, from, to time.Time) ([]types.MetricRollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rollups := []types.MetricRollup{}
+	for key, rollup := range s.rollups {
+		if (endpointID == uuid.Nil || key.endpointID == endpointID) && key.resolution == resolution &&
+			!key.start.Before(from) && key.start.Before(to) {
+			rollups = append(rollups, rollup)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if !rollups[i].Start.Equal(rollups[j].Start) {
+			return rollups[i].Start.Before(rollups[j].Start)
+		}
+		return rollups[i].EndpointID.String() < rollups[j].EndpointID.String()
+	})
+	return rollups, nil
+}
+
+func (s *MemoryStore) DeleteMetricRollups(resolution time.Duration, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int
+	for key := range s.rollups {
+		if key.resolution == resolution && key.start.Before(before) {
+			delete(s.rollups, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) CreateProbeResult(result *types.ProbeResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.probes[result.EndpointID] = append(s.probes[result.EndpointID], *result)
+	return nil
+}
+
+func (s *MemoryStore) GetProbeResults(endpointID uuid.UUID) ([]types.ProbeResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	results := make([]types.ProbeResult, len(s.probes[endpointID]))
+	copy(results, s.probes[endpointID])
+	return results, nil
+}
+
+func (s *MemoryStore) CreateHealthCheck(check *types.HealthCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health[check.DeploymentID] = append(s.health[check.DeploymentID], *check)
+	return nil
+}
+
+func (s *MemoryStore) GetHealthChecks(deploymentID uuid.UUID) ([]types.HealthCheck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	checks := make([]types.HealthCheck, len(s.health[deploymentID]))
+	copy(checks, s.health[deploymentID])
+	return checks, nil
+}
+
+func (s *MemoryStore) CreateCacheMetric(metric *types.CacheMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caches[metric.EndpointID] = append(s.caches[metric.EndpointID], *metric)
+	return nil
+}
+
+func (s *MemoryStore) GetCacheMetrics(endpointID uuid.UUID) ([]types.CacheMetric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	metrics := make([]types.CacheMetric, len(s.caches[endpointID]))
+	copy(metrics, s.caches[endpointID])
+	return metrics, nil
+}
+
+func (s *MemoryStore) CreateConcurrencyMetric(metric *types.ConcurrencyMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conc[metric.EndpointID] = append(s.conc[metric.EndpointID], *metric)
+	return nil
+}
+
+func (s *MemoryStore) GetConcurrencyMetrics(endpointID uuid.UUID, since time.Time) ([]types.ConcurrencyMetric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var metrics []types.ConcurrencyMetric
+	for _, metric := range s.conc[endpointID] {
+		if !metric.CreatedAT.Before(since) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+func (s *MemoryStore) DeleteConcurrencyMetrics(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int
+	for endpointID, metrics := range s.conc {
+		kept := make([]types.ConcurrencyMetric, 0, len(metrics))
+		for _, metric := range metrics {
+			if metric.CreatedAT.Before(before) {
+				deleted++
+				continue
+			}
+			kept = append(kept, metric)
+		}
+		s.conc[endpointID] = kept
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) AddUsage(record *types.UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := usageKey{endpointID: record.EndpointID, period: record.Period}
+	usage, ok := s.usage[key]
+	if !ok {
+		usage = &types.UsageRecord{EndpointID: record.EndpointID, Period: record.Period}
+		s.usage[key] = usage
+	}
+	usage.Invocations += record.Invocations
+	usage.GBSeconds += record.GBSeconds
+	return nil
+}
+
+func (s *MemoryStore) GetUsage(period time.Time) ([]types.UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := []types.UsageRecord{}
+	for key, usage := range s.usage {
+		if key.period.Equal(period) {
+			records = append(records, *usage)
+		}
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) CreateInvocationError(e *types.InvocationError) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[e.EndpointID] = append(s.errors[e.EndpointID], *e)
+	return nil
+}
+
+func (s *MemoryStore) GetInvocationErrors(endpointID uuid.UUID) ([]types.InvocationError, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	errs := make([]types.InvocationError, len(s.errors[endpointID]))
+	copy(errs, s.errors[endpointID])
+	return errs, nil
+}
+
+func (s *MemoryStore) CreateCapturedRequest(r *types.CapturedRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	captures := append(s.captures[r.EndpointID], *r)
+	if len(captures) > types.MaxCapturedRequests {
+		captures = captures[len(captures)-types.MaxCapturedRequests:]
+	}
+	s.captures[r.EndpointID] = captures
+	return nil
+}
+
+func (s *MemoryStore) GetCapturedRequest(id uuid.UUID) (*types.CapturedRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, captures := range s.captures {
+		for _, r := range captures {
+			if r.ID == id {
+				return &r, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find captured request with id (%s)", id)
+}
+
+func (s *MemoryStore) GetCapturedRequests(endpointID uuid.UUID) ([]types.CapturedRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	captures := make([]types.CapturedRequest, len(s.captures[endpointID]))
+	copy(captures, s.captures[endpointID])
+	return captures, nil
+}
@@ -13,6 +13,8 @@ type ModCacher interface {
 	Put(uuid.UUID, wazero.CompilationCache)
 	Get(uuid.UUID) (wazero.CompilationCache, bool)
 	Delete(uuid.UUID) error
+	// Keys returns the ids of the cached deployments.
+	Keys() []uuid.UUID
 }
 
 type DefaultModCache struct {
@@ -39,6 +41,16 @@ func (c *DefaultModCache) Get(id uuid.UUID) (wazero.CompilationCache, bool) {
 	return mod, ok
 }
 
+func (c *DefaultModCache) Keys() []uuid.UUID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]uuid.UUID, 0, len(c.cache))
+	for id := range c.cache {
+		keys = append(keys, id)
+	}
+	return keys
+}
+
 func (c *DefaultModCache) Delete(id uuid.UUID) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -4,15 +4,27 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// invalidationChannel is the channel the ids of the changed endpoints and
+// deleted deployments are published on.
+const invalidationChannel = "raptor_invalidation"
+
+// The invalidations of the project environments carry the project with this
+// prefix, the other invalidations carry the id of an endpoint or a
+// deployment.
+const projectInvalidationPrefix = "project:"
+
 type SQLStore struct {
-	db *sql.DB
+	db  *sql.DB
+	uri string
 }
 
 func NewSQLStore(user, password, dbname, host, port, sslmode string) (*SQLStore, error) {
@@ -25,6 +37,12 @@ func NewSQLStore(user, password, dbname, host, port, sslmode string) (*SQLStore,
 		port,
 		sslmode,
 	)
+	return OpenSQLStore(uri)
+}
+
+// OpenSQLStore returns a new SQLStore given a postgres connection string,
+// either a postgres:// URL or key=value pairs.
+func OpenSQLStore(uri string) (*SQLStore, error) {
 	db, err := sql.Open("postgres", uri)
 	if err != nil {
 		return nil, err
@@ -35,25 +53,42 @@ func NewSQLStore(user, password, dbname, host, port, sslmode string) (*SQLStore,
 	}
 
 	return &SQLStore{
-		db: db,
+		db:  db,
+		uri: uri,
 	}, nil
 }
 
 func (s *SQLStore) CreateEndpoint(endpoint *types.Endpoint) error {
 	stmt := `
-INSERT INTO endpoint (id, name, runtime, environment, created_at)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO endpoint (id, name, runtime, environment, created_at, data_keys, ownership)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING id`
 	b, err := json.Marshal(endpoint.Environment)
 	if err != nil {
 		return err
 	}
+	var keys []byte
+	if endpoint.DataKeys != nil {
+		keys, err = json.Marshal(endpoint.DataKeys)
+		if err != nil {
+			return err
+		}
+	}
+	var ownership []byte
+	if endpoint.Ownership != nil {
+		ownership, err = json.Marshal(endpoint.Ownership)
+		if err != nil {
+			return err
+		}
+	}
 	_, err = s.db.Exec(stmt,
 		endpoint.ID,
 		endpoint.Name,
 		endpoint.Runtime,
 		b,
-		endpoint.CreatedAT)
+		endpoint.CreatedAT,
+		keys,
+		ownership)
 	return err
 }
 
@@ -84,12 +119,24 @@ func (s *SQLStore) GetEndpoints() ([]types.Endpoint, error) {
 
 func (s *SQLStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
 	query, args := buildUpdateEndpointQuery(id, params)
-	_, err := s.db.Exec(query, args...)
-	return err
+	return s.execAndInvalidate(id, query, args...)
+}
+
+func (s *SQLStore) DeleteEndpoint(id uuid.UUID) error {
+	stmt := `
+WITH webhooks AS (
+	DELETE FROM webhook WHERE endpoint_id = $1
+)
+DELETE FROM endpoint WHERE id = $1`
+	return s.execAndInvalidate(id, stmt, id)
 }
 
 func (s *SQLStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
-	stmt := "SELECT id, endpoint_id, hash, blob, created_at FROM deployment WHERE id = $1"
+	// Deployments created before the blobs were content-addressed store
+	// their blob inline.
+	stmt := `
+SELECT d.id, d.endpoint_id, d.hash, coalesce(d.blob, b.data), d.created_at, d.assets, d.metadata, d.signature, d.build_id, d.build_status, d.source
+FROM deployment d LEFT JOIN blob b ON b.hash = d.hash WHERE d.id = $1`
 	row := s.db.QueryRow(stmt, id)
 
 	var deploy types.Deployment
@@ -97,104 +144,2489 @@ func (s *SQLStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
 	return &deploy, err
 }
 
+func (s *SQLStore) GetDeployments(endpointID uuid.UUID) ([]types.Deployment, error) {
+	stmt := `
+SELECT id, endpoint_id, hash, created_at, coalesce(length(assets), 0) > 0, metadata, signature, build_id, build_status, source
+FROM deployment WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deploys []types.Deployment
+	for rows.Next() {
+		var (
+			deploy   types.Deployment
+			metadata []byte
+		)
+		if err := rows.Scan(
+			&deploy.ID,
+			&deploy.EndpointID,
+			&deploy.Hash,
+			&deploy.CreatedAT,
+			&deploy.HasAssets,
+			&metadata,
+			&deploy.Signature,
+			&deploy.BuildID,
+			&deploy.BuildStatus,
+			&deploy.Source,
+		); err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			if err := json.Unmarshal(metadata, &deploy.DeploymentMetadata); err != nil {
+				return nil, err
+			}
+		}
+		deploys = append(deploys, deploy)
+	}
+	return deploys, rows.Err()
+}
+
+// DeleteDeployment deletes the deployment and its blob, unless the blob is
+// shared with other deployments.
+func (s *SQLStore) DeleteDeployment(id uuid.UUID) error {
+	// The statement sees the deployment table as it was before the delete.
+	stmt := `
+WITH deleted AS (
+	DELETE FROM deployment WHERE id = $1 RETURNING hash
+)
+DELETE FROM blob WHERE hash IN (SELECT hash FROM deleted)
+AND NOT EXISTS (SELECT 1 FROM deployment d WHERE d.hash = blob.hash AND d.id <> $1)`
+	return s.execAndInvalidate(id, stmt, id)
+}
+
+// execAndInvalidate executes the statement changing the endpoint or the
+// deployment with the given id and publishes the id to the caches of the
+// other processes once the change is committed.
+func (s *SQLStore) execAndInvalidate(id uuid.UUID, query string, args ...any) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("SELECT pg_notify($1, $2)", invalidationChannel, id.String()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListenInvalidations invalidates the entries of the cache changed by other
+// processes, for as long as the process runs. The whole cache is
+// invalidated when the connection is lost, the changes in the meantime are
+// missed.
+func (s *SQLStore) ListenInvalidations(cache *CachedStore) error {
+	listener := pq.NewListener(s.uri, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("invalidation listener", "err", err)
+		}
+	})
+	if err := listener.Listen(invalidationChannel); err != nil {
+		listener.Close()
+		return err
+	}
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				cache.InvalidateAll()
+				continue
+			}
+			if project, ok := strings.CutPrefix(n.Extra, projectInvalidationPrefix); ok {
+				cache.InvalidateProject(project)
+				continue
+			}
+			id, err := uuid.Parse(n.Extra)
+			if err != nil {
+				slog.Warn("invalid invalidation payload", "payload", n.Extra)
+				continue
+			}
+			cache.Invalidate(id)
+		}
+	}()
+	return nil
+}
+
+// CreateDeployment stores the deployment and its blob addressed by the hash
+// of the deployment, identical blobs are stored once.
 func (s *SQLStore) CreateDeployment(deploy *types.Deployment) error {
+	// Updating the existing blob locks it until the deployment is inserted,
+	// so it can not be deleted along with another deployment in between.
 	stmt := `
-INSERT INTO deployment (id, endpoint_id, hash, blob, created_at)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id`
-	_, err := s.db.Exec(stmt,
+WITH stored AS (
+	INSERT INTO blob (hash, data) VALUES ($3, $4)
+	ON CONFLICT (hash) DO UPDATE SET hash = excluded.hash
+)
+INSERT INTO deployment (id, endpoint_id, hash, created_at, assets, metadata, signature, build_id, build_status, source)
+VALUES ($1, $2, $3, $5, $6, $7, $8, $9, $10, $11)`
+	metadata, err := json.Marshal(deploy.DeploymentMetadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
 		deploy.ID,
 		deploy.EndpointID,
 		deploy.Hash,
 		deploy.Blob,
-		deploy.CreatedAT)
+		deploy.CreatedAT,
+		deploy.Assets,
+		metadata,
+		deploy.Signature,
+		deploy.BuildID,
+		deploy.BuildStatus,
+		deploy.Source)
 	return err
 }
 
-func (s *SQLStore) CreateRuntimeMetric(metric *types.RuntimeMetric) error {
-	return nil
+func (s *SQLStore) CreateDeploymentEvent(event *types.DeploymentEvent) error {
+	stmt := `
+INSERT INTO deployment_event (id, endpoint_id, kind, deployment_id, previous_deployment_id, actor, from_owner, to_owner, weight, reason, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := s.db.Exec(stmt,
+		event.ID,
+		event.EndpointID,
+		event.Kind,
+		event.DeploymentID,
+		event.PreviousDeploymentID,
+		event.Actor,
+		event.From,
+		event.To,
+		event.Weight,
+		event.Reason,
+		event.CreatedAT)
+	return err
 }
 
-func (s *SQLStore) GetRuntimeMetrics(id uuid.UUID) ([]types.RuntimeMetric, error) {
-	return nil, nil
+func (s *SQLStore) GetDeploymentEvents(endpointID uuid.UUID) ([]types.DeploymentEvent, error) {
+	stmt := `
+SELECT id, endpoint_id, kind, deployment_id, previous_deployment_id, actor, from_owner, to_owner, weight, reason, created_at
+FROM deployment_event WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []types.DeploymentEvent
+	for rows.Next() {
+		var event types.DeploymentEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.EndpointID,
+			&event.Kind,
+			&event.DeploymentID,
+			&event.PreviousDeploymentID,
+			&event.Actor,
+			&event.From,
+			&event.To,
+			&event.Weight,
+			&event.Reason,
+			&event.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
 }
 
-type Scanner interface {
-	Scan(dest ...interface{}) error
+func (s *SQLStore) CreateEvent(event *types.Event) error {
+	stmt := `
+INSERT INTO event (id, type, endpoint_id, deployment_id, actor, message, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.Exec(stmt,
+		event.ID,
+		event.Type,
+		event.EndpointID,
+		event.DeploymentID,
+		event.Actor,
+		event.Message,
+		event.CreatedAT)
+	return err
 }
 
-func buildUpdateEndpointQuery(id uuid.UUID, params UpdateEndpointParams) (string, []any) {
-	var (
-		updates []string
-		args    []any
-		counter = 1
-	)
+func (s *SQLStore) GetEvents(endpointID uuid.UUID) ([]types.Event, error) {
+	stmt := `
+SELECT id, type, endpoint_id, deployment_id, actor, message, created_at
+FROM event WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if params.ActiveDeployID.String() != "00000000-0000-0000-0000-000000000000" {
-		updates = append(updates, fmt.Sprintf("active_deployment_id = $%d", counter))
-		args = append(args, params.ActiveDeployID)
-		counter++
+	var events []types.Event
+	for rows.Next() {
+		var event types.Event
+		if err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.EndpointID,
+			&event.DeploymentID,
+			&event.Actor,
+			&event.Message,
+			&event.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
 	}
-	if params.Environment != nil {
-		b, err := json.Marshal(params.Environment)
-		if err != nil {
-			panic(err)
+	return events, rows.Err()
+}
+
+func (s *SQLStore) CreateWebhook(webhook *types.Webhook) error {
+	stmt := `
+INSERT INTO webhook (id, endpoint_id, url, secret, events, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)`
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		webhook.ID,
+		webhook.EndpointID,
+		webhook.URL,
+		webhook.Secret,
+		events,
+		webhook.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetWebhooks(endpointID uuid.UUID) ([]types.Webhook, error) {
+	stmt := `
+SELECT id, endpoint_id, url, secret, events, created_at
+FROM webhook WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []types.Webhook
+	for rows.Next() {
+		var (
+			webhook types.Webhook
+			events  []byte
+		)
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.EndpointID,
+			&webhook.URL,
+			&webhook.Secret,
+			&events,
+			&webhook.CreatedAT,
+		); err != nil {
+			return nil, err
 		}
-		updates = append(updates, fmt.Sprintf("environment = $%d", counter))
-		args = append(args, b)
-		counter++
+		if events != nil {
+			if err := json.Unmarshal(events, &webhook.Events); err != nil {
+				return nil, err
+			}
+		}
+		webhooks = append(webhooks, webhook)
 	}
-	args = append(args, id)
+	return webhooks, rows.Err()
+}
 
-	setClause := strings.Join(updates, ", ")
-	query := fmt.Sprintf("UPDATE endpoint SET %s WHERE id = $%d", setClause, counter)
+func (s *SQLStore) DeleteWebhook(id uuid.UUID) error {
+	res, err := s.db.Exec("DELETE FROM webhook WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find webhook with id (%s)", id)
+	}
+	return nil
+}
 
-	return query, args
+func (s *SQLStore) CreateDeadLetter(letter *types.DeadLetter) error {
+	stmt := `
+INSERT INTO dead_letter (id, endpoint_id, source, request, attempts, status_code, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	request, err := json.Marshal(letter.Request)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		letter.ID,
+		letter.EndpointID,
+		letter.Source,
+		request,
+		letter.Attempts,
+		letter.StatusCode,
+		letter.Error,
+		letter.CreatedAT)
+	return err
 }
 
-func scanDeploy(s Scanner, d *types.Deployment) error {
-	return s.Scan(
-		&d.ID,
-		&d.EndpointID,
-		&d.Hash,
-		&d.Blob,
-		&d.CreatedAT,
-	)
+func (s *SQLStore) GetDeadLetter(id uuid.UUID) (*types.DeadLetter, error) {
+	stmt := `
+SELECT id, endpoint_id, source, request, attempts, status_code, error, created_at
+FROM dead_letter WHERE id = $1`
+	var letter types.DeadLetter
+	if err := scanDeadLetter(s.db.QueryRow(stmt, id), &letter); err != nil {
+		return nil, err
+	}
+	return &letter, nil
 }
 
-func scanEndpoint(s Scanner, e *types.Endpoint) error {
-	var envData []byte
-	err := s.Scan(
-		&e.ID,
-		&e.Name,
-		&e.Runtime,
-		&envData,
-		&e.CreatedAT,
-		&e.ActiveDeploymentID,
+func (s *SQLStore) GetDeadLetters(endpointID uuid.UUID) ([]types.DeadLetter, error) {
+	stmt := `
+SELECT id, endpoint_id, source, request, attempts, status_code, error, created_at
+FROM dead_letter WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []types.DeadLetter{}
+	for rows.Next() {
+		var letter types.DeadLetter
+		if err := scanDeadLetter(rows, &letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func scanDeadLetter(s Scanner, letter *types.DeadLetter) error {
+	var request []byte
+	if err := s.Scan(
+		&letter.ID,
+		&letter.EndpointID,
+		&letter.Source,
+		&request,
+		&letter.Attempts,
+		&letter.StatusCode,
+		&letter.Error,
+		&letter.CreatedAT,
+	); err != nil {
+		return err
+	}
+	return json.Unmarshal(request, &letter.Request)
+}
+
+func (s *SQLStore) DeleteDeadLetter(id uuid.UUID) error {
+	res, err := s.db.Exec("DELETE FROM dead_letter WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find dead letter with id (%s)", id)
+	}
+	return nil
+}
+
+func (s *SQLStore) CreateInvocation(invocation *types.Invocation) error {
+	stmt := `
+INSERT INTO invocation (id, endpoint_id, status, status_code, header, body, attempts, error, dead_letter_id, created_at, finished_at, progress, progress_message)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	header, err := json.Marshal(invocation.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		invocation.ID,
+		invocation.EndpointID,
+		invocation.Status,
+		invocation.StatusCode,
+		header,
+		invocation.Body,
+		invocation.Attempts,
+		invocation.Error,
+		invocation.DeadLetterID,
+		invocation.CreatedAT,
+		invocation.FinishedAT,
+		invocation.Progress,
+		invocation.ProgressMessage)
+	return err
+}
+
+func (s *SQLStore) UpdateInvocation(invocation *types.Invocation) error {
+	stmt := `
+UPDATE invocation SET status = $2, status_code = $3, header = $4, body = $5, attempts = $6, error = $7, dead_letter_id = $8, finished_at = $9, progress = $10, progress_message = $11
+WHERE id = $1`
+	header, err := json.Marshal(invocation.Header)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(stmt,
+		invocation.ID,
+		invocation.Status,
+		invocation.StatusCode,
+		header,
+		invocation.Body,
+		invocation.Attempts,
+		invocation.Error,
+		invocation.DeadLetterID,
+		invocation.FinishedAT,
+		invocation.Progress,
+		invocation.ProgressMessage)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find invocation with id (%s)", invocation.ID)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetInvocation(id uuid.UUID) (*types.Invocation, error) {
+	stmt := `
+SELECT id, endpoint_id, status, status_code, header, body, attempts, error, dead_letter_id, created_at, finished_at, progress, progress_message
+FROM invocation WHERE id = $1`
+	var (
+		invocation types.Invocation
+		header     []byte
+	)
+	err := s.db.QueryRow(stmt, id).Scan(
+		&invocation.ID,
+		&invocation.EndpointID,
+		&invocation.Status,
+		&invocation.StatusCode,
+		&header,
+		&invocation.Body,
+		&invocation.Attempts,
+		&invocation.Error,
+		&invocation.DeadLetterID,
+		&invocation.CreatedAT,
+		&invocation.FinishedAT,
+		&invocation.Progress,
+		&invocation.ProgressMessage,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		if err := json.Unmarshal(header, &invocation.Header); err != nil {
+			return nil, err
+		}
+	}
+	return &invocation, nil
+}
+
+func (s *SQLStore) CreateScheduledInvocation(scheduled *types.ScheduledInvocation) error {
+	stmt := `
+INSERT INTO scheduled_invocation (id, endpoint_id, status, request, run_at, invocation_id, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	request, err := json.Marshal(scheduled.Request)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(envData, &e.Environment)
+	_, err = s.db.Exec(stmt,
+		scheduled.ID,
+		scheduled.EndpointID,
+		scheduled.Status,
+		request,
+		scheduled.RunAT,
+		scheduled.InvocationID,
+		scheduled.Error,
+		scheduled.CreatedAT)
+	return err
 }
 
-var createAllTablesQuery = `
-CREATE TABLE if not exists endpoint (
-	id UUID primary key, 
-	name text not null,
-	runtime text not null,
-	environment jsonb,
-	created_at timestamp not null default now()
-);
+func (s *SQLStore) UpdateScheduledInvocationStatus(scheduled *types.ScheduledInvocation, from string) (bool, error) {
+	stmt := `
+UPDATE scheduled_invocation SET status = $2, invocation_id = $3, error = $4
+WHERE id = $1 AND status = $5`
+	res, err := s.db.Exec(stmt,
+		scheduled.ID,
+		scheduled.Status,
+		scheduled.InvocationID,
+		scheduled.Error,
+		from)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
 
-CREATE TABLE if not exists deployment (
-	id UUID primary key, 
-	endpoint_id UUID not null references endpoint,
-	hash text not null,
-	blob bytea not null,
-	created_at timestamp not null default now()
-);
+func (s *SQLStore) GetScheduledInvocation(id uuid.UUID) (*types.ScheduledInvocation, error) {
+	stmt := `
+SELECT id, endpoint_id, status, request, run_at, invocation_id, error, created_at
+FROM scheduled_invocation WHERE id = $1`
+	var scheduled types.ScheduledInvocation
+	if err := scanScheduledInvocation(s.db.QueryRow(stmt, id), &scheduled); err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
 
-ALTER table endpoint
-ADD COLUMN if not exists active_deployment_id UUID references deployment
+func (s *SQLStore) GetScheduledInvocations(endpointID uuid.UUID) ([]types.ScheduledInvocation, error) {
+	stmt := `
+SELECT id, endpoint_id, status, request, run_at, invocation_id, error, created_at
+FROM scheduled_invocation WHERE endpoint_id = $1 ORDER BY run_at`
+	return s.queryScheduledInvocations(stmt, endpointID)
+}
+
+func (s *SQLStore) GetDueScheduledInvocations(now time.Time) ([]types.ScheduledInvocation, error) {
+	stmt := `
+SELECT id, endpoint_id, status, request, run_at, invocation_id, error, created_at
+FROM scheduled_invocation WHERE status = $1 AND run_at <= $2 ORDER BY run_at`
+	return s.queryScheduledInvocations(stmt, types.SchedulePending, now)
+}
+
+func (s *SQLStore) queryScheduledInvocations(stmt string, args ...any) ([]types.ScheduledInvocation, error) {
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := []types.ScheduledInvocation{}
+	for rows.Next() {
+		var i types.ScheduledInvocation
+		if err := scanScheduledInvocation(rows, &i); err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, i)
+	}
+	return scheduled, rows.Err()
+}
+
+func scanScheduledInvocation(s Scanner, scheduled *types.ScheduledInvocation) error {
+	var request []byte
+	if err := s.Scan(
+		&scheduled.ID,
+		&scheduled.EndpointID,
+		&scheduled.Status,
+		&request,
+		&scheduled.RunAT,
+		&scheduled.InvocationID,
+		&scheduled.Error,
+		&scheduled.CreatedAT,
+	); err != nil {
+		return err
+	}
+	return json.Unmarshal(request, &scheduled.Request)
+}
+
+func (s *SQLStore) CreateWorkflow(workflow *types.Workflow) error {
+	stmt := `
+INSERT INTO workflow (id, endpoint_id, status, request, state, wake_at, steps, error, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	request, err := json.Marshal(workflow.Request)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		workflow.ID,
+		workflow.EndpointID,
+		workflow.Status,
+		request,
+		workflow.State,
+		workflow.WakeAT,
+		workflow.Steps,
+		workflow.Error,
+		workflow.CreatedAT,
+		workflow.UpdatedAT)
+	return err
+}
+
+func (s *SQLStore) UpdateWorkflow(workflow *types.Workflow) error {
+	stmt := `
+UPDATE workflow SET status = $2, state = $3, wake_at = $4, steps = $5, error = $6, updated_at = $7
+WHERE id = $1`
+	res, err := s.db.Exec(stmt,
+		workflow.ID,
+		workflow.Status,
+		workflow.State,
+		workflow.WakeAT,
+		workflow.Steps,
+		workflow.Error,
+		workflow.UpdatedAT)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find workflow with id (%s)", workflow.ID)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetWorkflow(id uuid.UUID) (*types.Workflow, error) {
+	stmt := `
+SELECT id, endpoint_id, status, request, state, wake_at, steps, error, created_at, updated_at
+FROM workflow WHERE id = $1`
+	var workflow types.Workflow
+	if err := scanWorkflow(s.db.QueryRow(stmt, id), &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (s *SQLStore) GetDueWorkflows(now time.Time) ([]types.Workflow, error) {
+	stmt := `
+SELECT id, endpoint_id, status, request, state, wake_at, steps, error, created_at, updated_at
+FROM workflow WHERE status = $1 AND wake_at <= $2 ORDER BY wake_at`
+	rows, err := s.db.Query(stmt, types.WorkflowSleeping, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workflows := []types.Workflow{}
+	for rows.Next() {
+		var workflow types.Workflow
+		if err := scanWorkflow(rows, &workflow); err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, workflow)
+	}
+	return workflows, rows.Err()
+}
+
+// UpdateProjectEnvironment replaces the default environment of the project
+// and publishes the change to the caches of the other processes.
+func (s *SQLStore) UpdateProjectEnvironment(env *types.ProjectEnvironment) error {
+	stmt := `
+INSERT INTO project_environment (project, environment, data_keys, updated_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (project) DO UPDATE SET environment = $2, data_keys = $3, updated_at = $4`
+	b, err := json.Marshal(env.Environment)
+	if err != nil {
+		return err
+	}
+	var keys []byte
+	if env.DataKeys != nil {
+		keys, err = json.Marshal(env.DataKeys)
+		if err != nil {
+			return err
+		}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(stmt, env.Project, b, keys, env.UpdatedAT); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("SELECT pg_notify($1, $2)", invalidationChannel, projectInvalidationPrefix+env.Project); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GetProjectEnvironment(project string) (*types.ProjectEnvironment, error) {
+	stmt := `
+SELECT environment, data_keys, updated_at FROM project_environment WHERE project = $1`
+	var (
+		env      = types.ProjectEnvironment{Project: project}
+		envData  []byte
+		keysData []byte
+	)
+	err := s.db.QueryRow(stmt, project).Scan(&envData, &keysData, &env.UpdatedAT)
+	if err == sql.ErrNoRows {
+		env.Environment = map[string]string{}
+		return &env, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keysData != nil {
+		if err := json.Unmarshal(keysData, &env.DataKeys); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(envData, &env.Environment); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func (s *SQLStore) GetProjectEnvironments() ([]types.ProjectEnvironment, error) {
+	stmt := `
+SELECT project, environment, data_keys, updated_at FROM project_environment ORDER BY project`
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	envs := []types.ProjectEnvironment{}
+	for rows.Next() {
+		var (
+			env      types.ProjectEnvironment
+			envData  []byte
+			keysData []byte
+		)
+		if err := rows.Scan(&env.Project, &envData, &keysData, &env.UpdatedAT); err != nil {
+			return nil, err
+		}
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &env.DataKeys); err != nil {
+				return nil, err
+			}
+		}
+		if err := json.Unmarshal(envData, &env.Environment); err != nil {
+			return nil, err
+		}
+		envs = append(envs, env)
+	}
+	return envs, rows.Err()
+}
+
+func scanWorkflow(s Scanner, workflow *types.Workflow) error {
+	var request []byte
+	if err := s.Scan(
+		&workflow.ID,
+		&workflow.EndpointID,
+		&workflow.Status,
+		&request,
+		&workflow.State,
+		&workflow.WakeAT,
+		&workflow.Steps,
+		&workflow.Error,
+		&workflow.CreatedAT,
+		&workflow.UpdatedAT,
+	); err != nil {
+		return err
+	}
+	return json.Unmarshal(request, &workflow.Request)
+}
+
+func (s *SQLStore) CreateAuditEntry(entry *types.AuditEntry) error {
+	stmt := `
+INSERT INTO audit_entry (id, actor, key, method, path, route, endpoint_id, query, params, source_ip, status_code, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	_, err := s.db.Exec(stmt,
+		entry.ID,
+		entry.Actor,
+		entry.Key,
+		entry.Method,
+		entry.Path,
+		entry.Route,
+		entry.EndpointID,
+		entry.Query,
+		entry.Params,
+		entry.SourceIP,
+		entry.StatusCode,
+		entry.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetAuditEntries(filter types.AuditFilter) ([]types.AuditEntry, error) {
+	stmt := `
+SELECT id, actor, key, method, path, route, endpoint_id, query, params, source_ip, status_code, created_at
+FROM audit_entry
+WHERE ($1 = '' OR actor = $1) AND ($2 = $3 OR endpoint_id = $2) AND created_at >= $4
+ORDER BY created_at DESC LIMIT NULLIF($5, 0)`
+	rows, err := s.db.Query(stmt, filter.Actor, filter.EndpointID, uuid.Nil, filter.Since, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []types.AuditEntry{}
+	for rows.Next() {
+		var entry types.AuditEntry
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.Actor,
+			&entry.Key,
+			&entry.Method,
+			&entry.Path,
+			&entry.Route,
+			&entry.EndpointID,
+			&entry.Query,
+			&entry.Params,
+			&entry.SourceIP,
+			&entry.StatusCode,
+			&entry.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLStore) CreateRuntimeMetric(metric *types.RuntimeMetric) error {
+	return nil
+}
+
+func (s *SQLStore) GetRuntimeMetrics(id uuid.UUID) ([]types.RuntimeMetric, error) {
+	return nil, nil
+}
+
+func (s *SQLStore) CreateRequestMetric(metric *types.RequestMetric) error {
+	stmt := `
+INSERT INTO request_metric (id, endpoint_id, deployment_id, request_url, duration, status_code, compute_duration, wait_duration, memory_usage, created_at, protocol, bytes_in, bytes_out)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := s.db.Exec(stmt,
+		metric.ID,
+		metric.EndpointID,
+		metric.DeploymentID,
+		metric.RequestURL,
+		metric.Duration,
+		metric.StatusCode,
+		metric.ComputeDuration,
+		metric.WaitDuration,
+		metric.MemoryUsage,
+		metric.CreatedAT,
+		metric.Protocol,
+		metric.BytesIn,
+		metric.BytesOut)
+	return err
+}
+
+// CreateRequestMetrics stores the metrics with a single statement.
+func (s *SQLStore) CreateRequestMetrics(metrics []types.RequestMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	const columns = 13
+	var (
+		stmt strings.Builder
+		args = make([]any, 0, len(metrics)*columns)
+	)
+	stmt.WriteString(`
+INSERT INTO request_metric (id, endpoint_id, deployment_id, request_url, duration, status_code, compute_duration, wait_duration, memory_usage, created_at, protocol, bytes_in, bytes_out)
+VALUES `)
+	for i, metric := range metrics {
+		if i > 0 {
+			stmt.WriteString(", ")
+		}
+		stmt.WriteString("(")
+		for j := 1; j <= columns; j++ {
+			if j > 1 {
+				stmt.WriteString(", ")
+			}
+			fmt.Fprintf(&stmt, "$%d", i*columns+j)
+		}
+		stmt.WriteString(")")
+		args = append(args,
+			metric.ID,
+			metric.EndpointID,
+			metric.DeploymentID,
+			metric.RequestURL,
+			metric.Duration,
+			metric.StatusCode,
+			metric.ComputeDuration,
+			metric.WaitDuration,
+			metric.MemoryUsage,
+			metric.CreatedAT,
+			metric.Protocol,
+			metric.BytesIn,
+			metric.BytesOut)
+	}
+	_, err := s.db.Exec(stmt.String(), args...)
+	return err
+}
+
+func (s *SQLStore) GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, request_url, duration, status_code, compute_duration, wait_duration, memory_usage, created_at, protocol, bytes_in, bytes_out
+FROM request_metric WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []types.RequestMetric
+	for rows.Next() {
+		var metric types.RequestMetric
+		if err := rows.Scan(
+			&metric.ID,
+			&metric.EndpointID,
+			&metric.DeploymentID,
+			&metric.RequestURL,
+			&metric.Duration,
+			&metric.StatusCode,
+			&metric.ComputeDuration,
+			&metric.WaitDuration,
+			&metric.MemoryUsage,
+			&metric.CreatedAT,
+			&metric.Protocol,
+			&metric.BytesIn,
+			&metric.BytesOut,
+		); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *SQLStore) GetRequestMetricsBetween(from, to time.Time) ([]types.RequestMetric, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, request_url, duration, status_code, compute_duration, wait_duration, memory_usage, created_at, protocol, bytes_in, bytes_out
+FROM request_metric WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []types.RequestMetric
+	for rows.Next() {
+		var metric types.RequestMetric
+		if err := rows.Scan(
+			&metric.ID,
+			&metric.EndpointID,
+			&metric.DeploymentID,
+			&metric.RequestURL,
+			&metric.Duration,
+			&metric.StatusCode,
+			&metric.ComputeDuration,
+			&metric.WaitDuration,
+			&metric.MemoryUsage,
+			&metric.CreatedAT,
+			&metric.Protocol,
+			&metric.BytesIn,
+			&metric.BytesOut,
+		); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *SQLStore) GetRequestStats(since time.Time, window time.Duration) ([]types.RequestStats, error) {
+	stmt := `
+SELECT to_timestamp(floor(extract(epoch FROM created_at) / $2) * $2) AS start,
+	count(*), count(*) FILTER (WHERE status_code >= 500)
+FROM request_metric WHERE created_at >= $1 GROUP BY start ORDER BY start`
+	rows, err := s.db.Query(stmt, since, window.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []types.RequestStats
+	for rows.Next() {
+		var stat types.RequestStats
+		if err := rows.Scan(&stat.Start, &stat.Requests, &stat.Errors); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+func (s *SQLStore) CreateIncident(incident *types.Incident) error {
+	stmt := `
+INSERT INTO incident (id, title, message, severity, regions, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)`
+	regions, err := json.Marshal(incident.Regions)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		incident.ID,
+		incident.Title,
+		incident.Message,
+		incident.Severity,
+		regions,
+		incident.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) ResolveIncident(id uuid.UUID, at time.Time) error {
+	res, err := s.db.Exec("UPDATE incident SET resolved_at = $2 WHERE id = $1", id, at)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find incident with id (%s)", id)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetIncidents(resolvedAfter time.Time) ([]types.Incident, error) {
+	stmt := `
+SELECT id, title, message, severity, regions, created_at, resolved_at
+FROM incident WHERE resolved_at IS NULL OR resolved_at > $1 ORDER BY created_at DESC`
+	rows, err := s.db.Query(stmt, resolvedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []types.Incident{}
+	for rows.Next() {
+		var (
+			incident types.Incident
+			regions  []byte
+		)
+		if err := rows.Scan(
+			&incident.ID,
+			&incident.Title,
+			&incident.Message,
+			&incident.Severity,
+			&regions,
+			&incident.CreatedAT,
+			&incident.ResolvedAT,
+		); err != nil {
+			return nil, err
+		}
+		if regions != nil {
+			if err := json.Unmarshal(regions, &incident.Regions); err != nil {
+				return nil, err
+			}
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, rows.Err()
+}
+
+func (s *SQLStore) CreateBuild(build *types.Build) error {
+	stmt := `
+INSERT INTO build (id, endpoint_id, language, status, logs, deployment_id, metadata, created_at, finished_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	metadata, err := json.Marshal(build.DeploymentMetadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		build.ID,
+		build.EndpointID,
+		build.Language,
+		build.Status,
+		build.Logs,
+		build.DeploymentID,
+		metadata,
+		build.CreatedAT,
+		build.FinishedAT)
+	return err
+}
+
+func (s *SQLStore) UpdateBuild(build *types.Build) error {
+	stmt := `
+UPDATE build SET status = $2, logs = $3, deployment_id = $4, finished_at = $5 WHERE id = $1`
+	res, err := s.db.Exec(stmt,
+		build.ID,
+		build.Status,
+		build.Logs,
+		build.DeploymentID,
+		build.FinishedAT)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find build with id (%s)", build.ID)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetBuild(id uuid.UUID) (*types.Build, error) {
+	stmt := `
+SELECT id, endpoint_id, language, status, logs, deployment_id, metadata, created_at, finished_at
+FROM build WHERE id = $1`
+	var (
+		build    types.Build
+		metadata []byte
+	)
+	err := s.db.QueryRow(stmt, id).Scan(
+		&build.ID,
+		&build.EndpointID,
+		&build.Language,
+		&build.Status,
+		&build.Logs,
+		&build.DeploymentID,
+		&metadata,
+		&build.CreatedAT,
+		&build.FinishedAT,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &build.DeploymentMetadata); err != nil {
+			return nil, err
+		}
+	}
+	return &build, nil
+}
+
+func (s *SQLStore) CreateConcurrencyMetric(metric *types.ConcurrencyMetric) error {
+	stmt := `
+INSERT INTO concurrency_metric (id, endpoint_id, member, inflight, queued, concurrency_limit, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.Exec(stmt,
+		metric.ID,
+		metric.EndpointID,
+		metric.Member,
+		metric.Inflight,
+		metric.Queued,
+		metric.Limit,
+		metric.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetConcurrencyMetrics(endpointID uuid.UUID, since time.Time) ([]types.ConcurrencyMetric, error) {
+	stmt := `
+SELECT id, endpoint_id, member, inflight, queued, concurrency_limit, created_at
+FROM concurrency_metric WHERE endpoint_id = $1 AND created_at >= $2 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []types.ConcurrencyMetric
+	for rows.Next() {
+		var metric types.ConcurrencyMetric
+		if err := rows.Scan(
+			&metric.ID,
+			&metric.EndpointID,
+			&metric.Member,
+			&metric.Inflight,
+			&metric.Queued,
+			&metric.Limit,
+			&metric.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *SQLStore) DeleteConcurrencyMetrics(before time.Time) (int, error) {
+	res, err := s.db.Exec("DELETE FROM concurrency_metric WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) DeleteRequestMetrics(before time.Time) (int, error) {
+	res, err := s.db.Exec("DELETE FROM request_metric WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) CreateMetricRollups(rollups []types.MetricRollup) error {
+	stmt := `
+INSERT INTO metric_rollup (endpoint_id, resolution, start_at, requests, errors, duration_sum, duration_max, bytes_in, bytes_out, buckets)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (endpoint_id, resolution, start_at) DO UPDATE SET
+	requests = EXCLUDED.requests,
+	errors = EXCLUDED.errors,
+	duration_sum = EXCLUDED.duration_sum,
+	duration_max = EXCLUDED.duration_max,
+	bytes_in = EXCLUDED.bytes_in,
+	bytes_out = EXCLUDED.bytes_out,
+	buckets = EXCLUDED.buckets`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, rollup := range rollups {
+		buckets, err := json.Marshal(rollup.Buckets)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(stmt,
+			rollup.EndpointID,
+			rollup.Resolution,
+			rollup.Start,
+			rollup.Requests,
+			rollup.Errors,
+			rollup.DurationSum,
+			rollup.DurationMax,
+			rollup.BytesIn,
+			rollup.BytesOut,
+			buckets); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GetMetricRollups(endpointID uuid.UUID, resolution time.Duration, from, to time.Time) ([]types.MetricRollup, error) {
+	stmt := `
+SELECT endpoint_id, resolution, start_at, requests, errors, duration_sum, duration_max, bytes_in, bytes_out, buckets
+FROM metric_rollup WHERE resolution = $1 AND start_at >= $2 AND start_at < $3`
+	args := []any{resolution, from, to}
+	if endpointID != uuid.Nil {
+		stmt += " AND endpoint_id = $4"
+		args = append(args, endpointID)
+	}
+	rows, err := s.db.Query(stmt+" ORDER BY start_at, endpoint_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []types.MetricRollup
+	for rows.Next() {
+		var (
+			rollup  types.MetricRollup
+			buckets []byte
+		)
+		if err := rows.Scan(
+			&rollup.EndpointID,
+			&rollup.Resolution,
+			&rollup.Start,
+			&rollup.Requests,
+			&rollup.Errors,
+			&rollup.DurationSum,
+			&rollup.DurationMax,
+			&rollup.BytesIn,
+			&rollup.BytesOut,
+			&buckets,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(buckets, &rollup.Buckets); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, rollup)
+	}
+	return rollups, rows.Err()
+}
+
+func (s *SQLStore) DeleteMetricRollups(resolution time.Duration, before time.Time) (int, error) {
+	res, err := s.db.Exec("DELETE FROM metric_rollup WHERE resolution = $1 AND start_at < $2", resolution, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) CreateProbeResult(result *types.ProbeResult) error {
+	stmt := `
+INSERT INTO probe_result (id, endpoint_id, path, region, success, status_code, duration, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := s.db.Exec(stmt,
+		result.ID,
+		result.EndpointID,
+		result.Path,
+		result.Region,
+		result.Success,
+		result.StatusCode,
+		result.Duration,
+		result.Error,
+		result.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetProbeResults(endpointID uuid.UUID) ([]types.ProbeResult, error) {
+	stmt := `
+SELECT id, endpoint_id, path, region, success, status_code, duration, error, created_at
+FROM probe_result WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []types.ProbeResult
+	for rows.Next() {
+		var result types.ProbeResult
+		if err := rows.Scan(
+			&result.ID,
+			&result.EndpointID,
+			&result.Path,
+			&result.Region,
+			&result.Success,
+			&result.StatusCode,
+			&result.Duration,
+			&result.Error,
+			&result.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *SQLStore) CreateHealthCheck(check *types.HealthCheck) error {
+	stmt := `
+INSERT INTO health_check (id, endpoint_id, deployment_id, healthy, duration, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.Exec(stmt,
+		check.ID,
+		check.EndpointID,
+		check.DeploymentID,
+		check.Healthy,
+		check.Duration,
+		check.Error,
+		check.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetHealthChecks(deploymentID uuid.UUID) ([]types.HealthCheck, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, healthy, duration, error, created_at
+FROM health_check WHERE deployment_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []types.HealthCheck
+	for rows.Next() {
+		var check types.HealthCheck
+		if err := rows.Scan(
+			&check.ID,
+			&check.EndpointID,
+			&check.DeploymentID,
+			&check.Healthy,
+			&check.Duration,
+			&check.Error,
+			&check.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+func (s *SQLStore) CreateCacheMetric(metric *types.CacheMetric) error {
+	stmt := `
+INSERT INTO cache_metric (id, endpoint_id, hits, misses, created_at)
+VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.Exec(stmt,
+		metric.ID,
+		metric.EndpointID,
+		metric.Hits,
+		metric.Misses,
+		metric.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetCacheMetrics(endpointID uuid.UUID) ([]types.CacheMetric, error) {
+	stmt := `
+SELECT id, endpoint_id, hits, misses, created_at
+FROM cache_metric WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []types.CacheMetric
+	for rows.Next() {
+		var metric types.CacheMetric
+		if err := rows.Scan(
+			&metric.ID,
+			&metric.EndpointID,
+			&metric.Hits,
+			&metric.Misses,
+			&metric.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *SQLStore) CreateInvocationError(e *types.InvocationError) error {
+	stmt := `
+INSERT INTO invocation_error (id, endpoint_id, deployment_id, kind, reason, exit_code, stack, stderr, signature, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	stack, err := json.Marshal(e.Stack)
+	if err != nil {
+		return err
+	}
+	stderr, err := json.Marshal(e.Stderr)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		e.ID,
+		e.EndpointID,
+		e.DeploymentID,
+		e.Kind,
+		e.Reason,
+		e.ExitCode,
+		stack,
+		stderr,
+		e.Signature,
+		e.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetInvocationErrors(endpointID uuid.UUID) ([]types.InvocationError, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, kind, reason, exit_code, stack, stderr, signature, created_at
+FROM invocation_error WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []types.InvocationError
+	for rows.Next() {
+		var (
+			e      types.InvocationError
+			stack  []byte
+			stderr []byte
+		)
+		if err := rows.Scan(
+			&e.ID,
+			&e.EndpointID,
+			&e.DeploymentID,
+			&e.Kind,
+			&e.Reason,
+			&e.ExitCode,
+			&stack,
+			&stderr,
+			&e.Signature,
+			&e.CreatedAT,
+		); err != nil {
+			return nil, err
+		}
+		if stack != nil {
+			if err := json.Unmarshal(stack, &e.Stack); err != nil {
+				return nil, err
+			}
+		}
+		if stderr != nil {
+			if err := json.Unmarshal(stderr, &e.Stderr); err != nil {
+				return nil, err
+			}
+		}
+		errs = append(errs, e)
+	}
+	return errs, rows.Err()
+}
+
+func (s *SQLStore) CreateCapturedRequest(r *types.CapturedRequest) error {
+	stmt := `
+INSERT INTO captured_request (id, endpoint_id, deployment_id, method, path, header, body, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	header, err := json.Marshal(r.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt,
+		r.ID,
+		r.EndpointID,
+		r.DeploymentID,
+		r.Method,
+		r.Path,
+		header,
+		r.Body,
+		r.CreatedAT)
+	if err != nil {
+		return err
+	}
+	stmt = `
+DELETE FROM captured_request WHERE endpoint_id = $1 AND id NOT IN (
+	SELECT id FROM captured_request WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2
+)`
+	_, err = s.db.Exec(stmt, r.EndpointID, types.MaxCapturedRequests)
+	return err
+}
+
+func (s *SQLStore) GetCapturedRequest(id uuid.UUID) (*types.CapturedRequest, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, method, path, header, body, created_at
+FROM captured_request WHERE id = $1`
+	var r types.CapturedRequest
+	if err := scanCapturedRequest(s.db.QueryRow(stmt, id), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *SQLStore) GetCapturedRequests(endpointID uuid.UUID) ([]types.CapturedRequest, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, method, path, header, body, created_at
+FROM captured_request WHERE endpoint_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	captures := []types.CapturedRequest{}
+	for rows.Next() {
+		var r types.CapturedRequest
+		if err := scanCapturedRequest(rows, &r); err != nil {
+			return nil, err
+		}
+		captures = append(captures, r)
+	}
+	return captures, rows.Err()
+}
+
+func scanCapturedRequest(s Scanner, r *types.CapturedRequest) error {
+	var header []byte
+	if err := s.Scan(
+		&r.ID,
+		&r.EndpointID,
+		&r.DeploymentID,
+		&r.Method,
+		&r.Path,
+		&header,
+		&r.Body,
+		&r.CreatedAT,
+	); err != nil {
+		return err
+	}
+	if header != nil {
+		return json.Unmarshal(header, &r.Header)
+	}
+	return nil
+}
+
+func (s *SQLStore) AddUsage(record *types.UsageRecord) error {
+	stmt := `
+INSERT INTO usage (endpoint_id, period, invocations, gb_seconds)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint_id, period) DO UPDATE SET
+	invocations = usage.invocations + EXCLUDED.invocations,
+	gb_seconds = usage.gb_seconds + EXCLUDED.gb_seconds`
+	_, err := s.db.Exec(stmt,
+		record.EndpointID,
+		record.Period,
+		record.Invocations,
+		record.GBSeconds)
+	return err
+}
+
+func (s *SQLStore) GetUsage(period time.Time) ([]types.UsageRecord, error) {
+	stmt := `
+SELECT endpoint_id, period, invocations, gb_seconds
+FROM usage WHERE period = $1`
+	rows, err := s.db.Query(stmt, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []types.UsageRecord{}
+	for rows.Next() {
+		var record types.UsageRecord
+		if err := rows.Scan(
+			&record.EndpointID,
+			&record.Period,
+			&record.Invocations,
+			&record.GBSeconds,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func buildUpdateEndpointQuery(id uuid.UUID, params UpdateEndpointParams) (string, []any) {
+	var (
+		updates []string
+		args    []any
+		counter = 1
+	)
+
+	if params.ActiveDeployID.String() != "00000000-0000-0000-0000-000000000000" {
+		updates = append(updates, fmt.Sprintf("active_deployment_id = $%d", counter))
+		args = append(args, params.ActiveDeployID)
+		counter++
+	}
+	if len(params.Name) > 0 {
+		updates = append(updates, fmt.Sprintf("name = $%d", counter))
+		args = append(args, params.Name)
+		counter++
+	}
+	if len(params.Runtime) > 0 {
+		updates = append(updates, fmt.Sprintf("runtime = $%d", counter))
+		args = append(args, params.Runtime)
+		counter++
+	}
+	if params.Environment != nil {
+		b, err := json.Marshal(params.Environment)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("environment = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.SessionAffinity != nil {
+		b, err := json.Marshal(params.SessionAffinity)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("session_affinity = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Regions != nil {
+		b, err := json.Marshal(params.Regions)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("regions = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.MaxConcurrency != nil {
+		updates = append(updates, fmt.Sprintf("max_concurrency = $%d", counter))
+		args = append(args, *params.MaxConcurrency)
+		counter++
+	}
+	if params.Probes != nil {
+		b, err := json.Marshal(params.Probes)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("probes = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.StaticResponses != nil {
+		b, err := json.Marshal(params.StaticResponses)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("static_responses = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Routes != nil {
+		b, err := json.Marshal(params.Routes)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("routes = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Deprecation != nil {
+		b, err := json.Marshal(params.Deprecation)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("deprecation = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.DataKeys != nil {
+		b, err := json.Marshal(params.DataKeys)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("data_keys = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Cache != nil {
+		b, err := json.Marshal(params.Cache)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("cache = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Compression != nil {
+		b, err := json.Marshal(params.Compression)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("compression = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Limits != nil {
+		b, err := json.Marshal(params.Limits)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("limits = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.WebSocket != nil {
+		b, err := json.Marshal(params.WebSocket)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("websocket = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Fallback != nil {
+		b, err := json.Marshal(params.Fallback)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("fallback = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Retention != nil {
+		b, err := json.Marshal(params.Retention)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("retention = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.EnvironmentSchema != nil {
+		b, err := json.Marshal(params.EnvironmentSchema)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("environment_schema = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Ownership != nil {
+		b, err := json.Marshal(params.Ownership)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("ownership = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Rollout != nil {
+		b, err := json.Marshal(params.Rollout)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("rollout = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.PublishedEnvironment != nil {
+		b, err := json.Marshal(params.PublishedEnvironment)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("published_environment = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Retry != nil {
+		b, err := json.Marshal(params.Retry)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("retry = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Capture != nil {
+		b, err := json.Marshal(params.Capture)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("capture = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.CORS != nil {
+		b, err := json.Marshal(params.CORS)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("cors = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Verification != nil {
+		b, err := json.Marshal(params.Verification)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("verification = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.JWT != nil {
+		b, err := json.Marshal(params.JWT)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("jwt = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Maintenance != nil {
+		b, err := json.Marshal(params.Maintenance)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("maintenance = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	if params.Prewarm != nil {
+		b, err := json.Marshal(params.Prewarm)
+		if err != nil {
+			panic(err)
+		}
+		updates = append(updates, fmt.Sprintf("prewarm = $%d", counter))
+		args = append(args, b)
+		counter++
+	}
+	args = append(args, id)
+
+	setClause := strings.Join(updates, ", ")
+	query := fmt.Sprintf("UPDATE endpoint SET %s WHERE id = $%d", setClause, counter)
+
+	return query, args
+}
+
+func scanDeploy(s Scanner, d *types.Deployment) error {
+	var metadata []byte
+	err := s.Scan(
+		&d.ID,
+		&d.EndpointID,
+		&d.Hash,
+		&d.Blob,
+		&d.CreatedAT,
+		&d.Assets,
+		&metadata,
+		&d.Signature,
+		&d.BuildID,
+		&d.BuildStatus,
+		&d.Source,
+	)
+	d.HasAssets = len(d.Assets) > 0
+	if err == nil && metadata != nil {
+		err = json.Unmarshal(metadata, &d.DeploymentMetadata)
+	}
+	return err
+}
+
+func scanEndpoint(s Scanner, e *types.Endpoint) error {
+	var (
+		envData      []byte
+		affinityData []byte
+		regionsData  []byte
+		probesData   []byte
+		staticData   []byte
+		routesData   []byte
+		deprecData   []byte
+		keysData     []byte
+		cacheData    []byte
+		compressData []byte
+		limitsData   []byte
+		wsData       []byte
+		publishData  []byte
+		fallbackData []byte
+		retainData   []byte
+		ownerData    []byte
+		schemaData   []byte
+		rolloutData  []byte
+		retryData    []byte
+		captureData  []byte
+		corsData     []byte
+		verifyData   []byte
+		jwtData      []byte
+		maintData    []byte
+		prewarmData  []byte
+	)
+	err := s.Scan(
+		&e.ID,
+		&e.Name,
+		&e.Runtime,
+		&envData,
+		&e.CreatedAT,
+		&e.ActiveDeploymentID,
+		&affinityData,
+		&regionsData,
+		&e.MaxConcurrency,
+		&probesData,
+		&staticData,
+		&routesData,
+		&deprecData,
+		&keysData,
+		&cacheData,
+		&compressData,
+		&limitsData,
+		&wsData,
+		&publishData,
+		&fallbackData,
+		&retainData,
+		&ownerData,
+		&schemaData,
+		&rolloutData,
+		&retryData,
+		&captureData,
+		&corsData,
+		&verifyData,
+		&jwtData,
+		&maintData,
+		&prewarmData,
+	)
+	if err != nil {
+		return err
+	}
+	if corsData != nil {
+		if err := json.Unmarshal(corsData, &e.CORS); err != nil {
+			return err
+		}
+	}
+	if verifyData != nil {
+		if err := json.Unmarshal(verifyData, &e.Verification); err != nil {
+			return err
+		}
+	}
+	if jwtData != nil {
+		if err := json.Unmarshal(jwtData, &e.JWT); err != nil {
+			return err
+		}
+	}
+	if maintData != nil {
+		if err := json.Unmarshal(maintData, &e.Maintenance); err != nil {
+			return err
+		}
+	}
+	if prewarmData != nil {
+		if err := json.Unmarshal(prewarmData, &e.Prewarm); err != nil {
+			return err
+		}
+	}
+	if captureData != nil {
+		if err := json.Unmarshal(captureData, &e.Capture); err != nil {
+			return err
+		}
+	}
+	if retryData != nil {
+		if err := json.Unmarshal(retryData, &e.Retry); err != nil {
+			return err
+		}
+	}
+	if rolloutData != nil {
+		if err := json.Unmarshal(rolloutData, &e.Rollout); err != nil {
+			return err
+		}
+	}
+	if schemaData != nil {
+		if err := json.Unmarshal(schemaData, &e.EnvironmentSchema); err != nil {
+			return err
+		}
+	}
+	if ownerData != nil {
+		if err := json.Unmarshal(ownerData, &e.Ownership); err != nil {
+			return err
+		}
+	}
+	if retainData != nil {
+		if err := json.Unmarshal(retainData, &e.Retention); err != nil {
+			return err
+		}
+	}
+	if fallbackData != nil {
+		if err := json.Unmarshal(fallbackData, &e.Fallback); err != nil {
+			return err
+		}
+	}
+	if publishData != nil {
+		if err := json.Unmarshal(publishData, &e.PublishedEnvironment); err != nil {
+			return err
+		}
+	}
+	if wsData != nil {
+		if err := json.Unmarshal(wsData, &e.WebSocket); err != nil {
+			return err
+		}
+	}
+	if limitsData != nil {
+		if err := json.Unmarshal(limitsData, &e.Limits); err != nil {
+			return err
+		}
+	}
+	if compressData != nil {
+		if err := json.Unmarshal(compressData, &e.Compression); err != nil {
+			return err
+		}
+	}
+	if cacheData != nil {
+		if err := json.Unmarshal(cacheData, &e.Cache); err != nil {
+			return err
+		}
+	}
+	if keysData != nil {
+		if err := json.Unmarshal(keysData, &e.DataKeys); err != nil {
+			return err
+		}
+	}
+	if deprecData != nil {
+		if err := json.Unmarshal(deprecData, &e.Deprecation); err != nil {
+			return err
+		}
+	}
+	if routesData != nil {
+		if err := json.Unmarshal(routesData, &e.Routes); err != nil {
+			return err
+		}
+	}
+	if staticData != nil {
+		if err := json.Unmarshal(staticData, &e.StaticResponses); err != nil {
+			return err
+		}
+	}
+	if probesData != nil {
+		if err := json.Unmarshal(probesData, &e.Probes); err != nil {
+			return err
+		}
+	}
+	if regionsData != nil {
+		if err := json.Unmarshal(regionsData, &e.Regions); err != nil {
+			return err
+		}
+	}
+	if affinityData != nil {
+		if err := json.Unmarshal(affinityData, &e.SessionAffinity); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(envData, &e.Environment)
+}
+
+var createAllTablesQuery = `
+CREATE TABLE if not exists endpoint (
+	id UUID primary key, 
+	name text not null,
+	runtime text not null,
+	environment jsonb,
+	created_at timestamp not null default now()
+);
+
+CREATE TABLE if not exists deployment (
+	id UUID primary key, 
+	endpoint_id UUID not null references endpoint,
+	hash text not null,
+	blob bytea not null,
+	created_at timestamp not null default now()
+);
+
+ALTER table endpoint
+ADD COLUMN if not exists active_deployment_id UUID references deployment;
+
+ALTER table endpoint
+ADD COLUMN if not exists session_affinity jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists regions jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists max_concurrency integer not null default 0;
+
+CREATE TABLE if not exists request_metric (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	deployment_id UUID not null,
+	request_url text not null,
+	duration bigint not null,
+	status_code integer not null,
+	compute_duration bigint not null,
+	wait_duration bigint not null,
+	memory_usage bigint not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists request_metric_endpoint_id_idx ON request_metric (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists probes jsonb;
+
+CREATE TABLE if not exists probe_result (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	path text not null,
+	region text not null,
+	success boolean not null,
+	status_code integer not null,
+	duration bigint not null,
+	error text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists probe_result_endpoint_id_idx ON probe_result (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists static_responses jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists routes jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists deprecation jsonb;
+
+ALTER table deployment
+ADD COLUMN if not exists assets bytea;
+
+ALTER table endpoint
+DROP COLUMN if exists egress;
+
+ALTER table endpoint
+ADD COLUMN if not exists data_keys jsonb;
+
+ALTER table request_metric
+ADD COLUMN if not exists protocol text not null default '';
+
+ALTER table endpoint
+ADD COLUMN if not exists cache jsonb;
+
+CREATE TABLE if not exists cache_metric (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	hits integer not null,
+	misses integer not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists cache_metric_endpoint_id_idx ON cache_metric (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists compression jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists limits jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists websocket jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists published_environment jsonb;
+
+CREATE TABLE if not exists deployment_event (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	kind text not null,
+	deployment_id UUID not null,
+	previous_deployment_id UUID not null,
+	actor text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists deployment_event_endpoint_id_idx ON deployment_event (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists fallback jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists retention jsonb;
+
+CREATE TABLE if not exists blob (
+	hash text primary key,
+	data bytea not null
+);
+
+ALTER table deployment
+ALTER COLUMN blob DROP NOT NULL;
+
+CREATE TABLE if not exists health_check (
+	id UUID primary key,
+	deployment_id UUID not null,
+	endpoint_id UUID not null,
+	healthy boolean not null,
+	duration bigint not null,
+	error text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists health_check_deployment_id_idx ON health_check (deployment_id, created_at);
+
+ALTER table deployment
+ADD COLUMN if not exists metadata jsonb;
+
+ALTER table deployment
+ADD COLUMN if not exists signature bytea;
+
+CREATE INDEX if not exists request_metric_created_at_idx ON request_metric (created_at);
+
+CREATE TABLE if not exists incident (
+	id UUID primary key,
+	title text not null,
+	message text not null,
+	severity text not null,
+	regions jsonb,
+	created_at timestamp not null default now(),
+	resolved_at timestamp
+);
+
+ALTER table endpoint
+ADD COLUMN if not exists ownership jsonb;
+
+ALTER table deployment_event
+ADD COLUMN if not exists from_owner text not null default '';
+
+ALTER table deployment_event
+ADD COLUMN if not exists to_owner text not null default '';
+
+ALTER table endpoint
+ADD COLUMN if not exists environment_schema jsonb;
+
+CREATE TABLE if not exists build (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	language text not null,
+	status text not null,
+	logs text not null,
+	deployment_id UUID not null,
+	metadata jsonb,
+	created_at timestamp not null default now(),
+	finished_at timestamp
+);
+
+ALTER table invocation
+ADD COLUMN if not exists progress integer not null default 0;
+
+ALTER table invocation
+ADD COLUMN if not exists progress_message text not null default '';
+
+ALTER table deployment
+ADD COLUMN if not exists build_id UUID;
+
+ALTER table deployment
+ADD COLUMN if not exists build_status text not null default '';
+
+ALTER table deployment
+ADD COLUMN if not exists source text not null default '';
+
+ALTER table endpoint
+ADD COLUMN if not exists rollout jsonb;
+
+ALTER table deployment_event
+ADD COLUMN if not exists weight integer not null default 0;
+
+ALTER table deployment_event
+ADD COLUMN if not exists reason text not null default '';
+
+CREATE TABLE if not exists event (
+	id UUID primary key,
+	type text not null,
+	endpoint_id UUID not null,
+	deployment_id UUID not null,
+	actor text not null,
+	message text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists event_endpoint_id_idx ON event (endpoint_id, created_at);
+
+CREATE TABLE if not exists webhook (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	url text not null,
+	secret text not null,
+	events jsonb,
+	created_at timestamp not null default now()
+);
+
+CREATE TABLE if not exists audit_entry (
+	id UUID primary key,
+	actor text not null,
+	method text not null,
+	path text not null,
+	route text not null,
+	endpoint_id UUID not null,
+	query text not null,
+	params text not null,
+	source_ip text not null,
+	status_code integer not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists audit_entry_created_at_idx ON audit_entry (created_at);
+
+ALTER table audit_entry
+ADD COLUMN if not exists key text not null default '';
+
+CREATE TABLE if not exists usage (
+	endpoint_id UUID not null,
+	period timestamp not null,
+	invocations bigint not null,
+	gb_seconds double precision not null,
+	primary key (endpoint_id, period)
+);
+
+ALTER table request_metric
+ADD COLUMN if not exists bytes_in bigint not null default 0;
+
+ALTER table request_metric
+ADD COLUMN if not exists bytes_out bigint not null default 0;
+
+CREATE TABLE if not exists invocation_error (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	deployment_id UUID not null,
+	kind text not null,
+	reason text not null,
+	exit_code integer not null,
+	stack jsonb,
+	stderr jsonb,
+	signature text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists invocation_error_endpoint_id_idx ON invocation_error (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists retry jsonb;
+
+CREATE TABLE if not exists dead_letter (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	source text not null,
+	request jsonb not null,
+	attempts integer not null,
+	status_code integer not null,
+	error text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists dead_letter_endpoint_id_idx ON dead_letter (endpoint_id, created_at);
+
+CREATE TABLE if not exists invocation (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	status text not null,
+	status_code integer not null,
+	header jsonb,
+	body bytea,
+	attempts integer not null,
+	error text not null,
+	dead_letter_id UUID not null,
+	created_at timestamp not null default now(),
+	finished_at timestamp
+);
+
+ALTER table endpoint
+ADD COLUMN if not exists capture jsonb;
+
+CREATE TABLE if not exists captured_request (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	deployment_id UUID not null,
+	method text not null,
+	path text not null,
+	header jsonb,
+	body bytea,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists captured_request_endpoint_id_idx ON captured_request (endpoint_id, created_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists cors jsonb;
+
+CREATE TABLE if not exists metric_rollup (
+	endpoint_id UUID not null,
+	resolution bigint not null,
+	start_at timestamp not null,
+	requests bigint not null,
+	errors bigint not null,
+	duration_sum bigint not null,
+	duration_max bigint not null,
+	bytes_in bigint not null,
+	bytes_out bigint not null,
+	buckets jsonb not null,
+	primary key (endpoint_id, resolution, start_at)
+);
+
+CREATE INDEX if not exists metric_rollup_start_at_idx ON metric_rollup (resolution, start_at);
+
+ALTER table endpoint
+ADD COLUMN if not exists verification jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists jwt jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists maintenance jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists prewarm jsonb;
+
+CREATE TABLE if not exists concurrency_metric (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	member text not null,
+	inflight integer not null,
+	queued integer not null,
+	concurrency_limit integer not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists concurrency_metric_endpoint_id_idx ON concurrency_metric (endpoint_id, created_at);
+
+CREATE TABLE if not exists workflow (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	status text not null,
+	request jsonb not null,
+	state bytea,
+	wake_at timestamp,
+	steps integer not null,
+	error text not null,
+	created_at timestamp not null default now(),
+	updated_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists workflow_wake_at_idx ON workflow (status, wake_at);
+
+CREATE TABLE if not exists scheduled_invocation (
+	id UUID primary key,
+	endpoint_id UUID not null,
+	status text not null,
+	request jsonb not null,
+	run_at timestamp not null,
+	invocation_id UUID not null,
+	error text not null,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists scheduled_invocation_endpoint_id_idx ON scheduled_invocation (endpoint_id, run_at);
+CREATE INDEX if not exists scheduled_invocation_run_at_idx ON scheduled_invocation (status, run_at);
+
+CREATE TABLE if not exists project_environment (
+	project text primary key,
+	environment jsonb not null,
+	data_keys jsonb,
+	updated_at timestamp not null default now()
+);
 `
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
 )
@@ -9,17 +11,181 @@ type Store interface {
 	CreateEndpoint(*types.Endpoint) error
 	UpdateEndpoint(uuid.UUID, UpdateEndpointParams) error
 	GetEndpoint(uuid.UUID) (*types.Endpoint, error)
+	GetEndpoints() ([]types.Endpoint, error)
+	// DeleteEndpoint deletes the endpoint and its webhooks. The deployments
+	// of the endpoint are deleted beforehand with DeleteDeployment.
+	DeleteEndpoint(uuid.UUID) error
 	CreateDeployment(*types.Deployment) error
 	GetDeployment(uuid.UUID) (*types.Deployment, error)
+	// GetDeployments returns the deployments of an endpoint, oldest first.
+	// The blobs and assets of the deployments are not loaded.
+	GetDeployments(endpointID uuid.UUID) ([]types.Deployment, error)
+	// DeleteDeployment deletes the deployment, its blob is deleted once no
+	// other deployment shares it.
+	DeleteDeployment(uuid.UUID) error
+	// CreateDeploymentEvent appends an event to the publish history of an
+	// endpoint, events are never updated nor deleted.
+	CreateDeploymentEvent(*types.DeploymentEvent) error
+	// GetDeploymentEvents returns the publish history of an endpoint, oldest
+	// event first.
+	GetDeploymentEvents(endpointID uuid.UUID) ([]types.DeploymentEvent, error)
+	// CreateIncident stores an incident of the platform status page.
+	CreateIncident(*types.Incident) error
+	// ResolveIncident marks the incident as resolved at the given time.
+	ResolveIncident(id uuid.UUID, at time.Time) error
+	// GetIncidents returns the active incidents and the incidents resolved
+	// after the given time, newest first.
+	GetIncidents(resolvedAfter time.Time) ([]types.Incident, error)
+	CreateBuild(*types.Build) error
+	// UpdateBuild replaces the stored build with the given build.
+	UpdateBuild(*types.Build) error
+	GetBuild(uuid.UUID) (*types.Build, error)
+	CreateEvent(*types.Event) error
+	// GetEvents returns the platform events of an endpoint, oldest first.
+	GetEvents(endpointID uuid.UUID) ([]types.Event, error)
+	CreateWebhook(*types.Webhook) error
+	// GetWebhooks returns the webhooks of an endpoint with their secrets,
+	// oldest first.
+	GetWebhooks(endpointID uuid.UUID) ([]types.Webhook, error)
+	DeleteWebhook(uuid.UUID) error
+	// CreateAuditEntry appends an entry to the audit log, entries are never
+	// updated nor deleted.
+	CreateAuditEntry(*types.AuditEntry) error
+	// GetAuditEntries returns the entries of the audit log selected by the
+	// filter, newest first.
+	GetAuditEntries(types.AuditFilter) ([]types.AuditEntry, error)
+	CreateDeadLetter(*types.DeadLetter) error
+	GetDeadLetter(uuid.UUID) (*types.DeadLetter, error)
+	// GetDeadLetters returns the dead letters of an endpoint, oldest first.
+	GetDeadLetters(endpointID uuid.UUID) ([]types.DeadLetter, error)
+	DeleteDeadLetter(uuid.UUID) error
+	CreateInvocation(*types.Invocation) error
+	// UpdateInvocation replaces the stored invocation with the given
+	// invocation.
+	UpdateInvocation(*types.Invocation) error
+	GetInvocation(uuid.UUID) (*types.Invocation, error)
+	CreateScheduledInvocation(*types.ScheduledInvocation) error
+	// UpdateScheduledInvocationStatus replaces the status, the invocation
+	// and the error of the stored scheduled invocation as long as its status
+	// is still from. False is returned when it is not, so a scheduled
+	// invocation is fired or canceled once, by one of the servers.
+	UpdateScheduledInvocationStatus(scheduled *types.ScheduledInvocation, from string) (bool, error)
+	GetScheduledInvocation(uuid.UUID) (*types.ScheduledInvocation, error)
+	// GetScheduledInvocations returns the scheduled invocations of an
+	// endpoint, the earliest first.
+	GetScheduledInvocations(endpointID uuid.UUID) ([]types.ScheduledInvocation, error)
+	// GetDueScheduledInvocations returns the pending invocations to fire at
+	// the given time, the earliest first.
+	GetDueScheduledInvocations(time.Time) ([]types.ScheduledInvocation, error)
+	CreateWorkflow(*types.Workflow) error
+	// UpdateWorkflow replaces the stored workflow with the given workflow.
+	UpdateWorkflow(*types.Workflow) error
+	GetWorkflow(uuid.UUID) (*types.Workflow, error)
+	// GetDueWorkflows returns the sleeping workflows to wake up at the given
+	// time, the earliest first.
+	GetDueWorkflows(time.Time) ([]types.Workflow, error)
+	// UpdateProjectEnvironment replaces the default environment of the
+	// project.
+	UpdateProjectEnvironment(*types.ProjectEnvironment) error
+	// GetProjectEnvironment returns the default environment of the project,
+	// an empty environment when the project has none.
+	GetProjectEnvironment(project string) (*types.ProjectEnvironment, error)
+	// GetProjectEnvironments returns the projects that have a default
+	// environment.
+	GetProjectEnvironments() ([]types.ProjectEnvironment, error)
 }
 
 type MetricStore interface {
 	CreateRuntimeMetric(*types.RuntimeMetric) error
 	GetRuntimeMetrics(uuid.UUID) ([]types.RuntimeMetric, error)
+	CreateRequestMetric(*types.RequestMetric) error
+	// CreateRequestMetrics stores a batch of request metrics at once.
+	CreateRequestMetrics([]types.RequestMetric) error
+	GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error)
+	// GetRequestMetricsBetween returns the request metrics of all the
+	// endpoints created within [from, to), oldest first.
+	GetRequestMetricsBetween(from, to time.Time) ([]types.RequestMetric, error)
+	// DeleteRequestMetrics deletes the request metrics created before the
+	// given time and returns the amount of deleted metrics.
+	DeleteRequestMetrics(before time.Time) (int, error)
+	// CreateMetricRollups stores the rollups, replacing the stored rollups
+	// of the same endpoint, resolution and start.
+	CreateMetricRollups([]types.MetricRollup) error
+	// GetMetricRollups returns the rollups of the resolution of an
+	// endpoint, or of all the endpoints for uuid.Nil, that start within
+	// [from, to), oldest first.
+	GetMetricRollups(endpointID uuid.UUID, resolution time.Duration, from, to time.Time) ([]types.MetricRollup, error)
+	// DeleteMetricRollups deletes the rollups of the resolution that start
+	// before the given time and returns the amount of deleted rollups.
+	DeleteMetricRollups(resolution time.Duration, before time.Time) (int, error)
+	// GetRequestStats returns the request stats of all the endpoints since
+	// the given time in windows of the given length, oldest first. Windows
+	// without requests are left out.
+	GetRequestStats(since time.Time, window time.Duration) ([]types.RequestStats, error)
+	CreateProbeResult(*types.ProbeResult) error
+	GetProbeResults(endpointID uuid.UUID) ([]types.ProbeResult, error)
+	CreateHealthCheck(*types.HealthCheck) error
+	// GetHealthChecks returns the health checks of a deployment, oldest
+	// first.
+	GetHealthChecks(deploymentID uuid.UUID) ([]types.HealthCheck, error)
+	CreateCacheMetric(*types.CacheMetric) error
+	GetCacheMetrics(endpointID uuid.UUID) ([]types.CacheMetric, error)
+	CreateConcurrencyMetric(*types.ConcurrencyMetric) error
+	// GetConcurrencyMetrics returns the concurrency metrics of an endpoint
+	// created since the given time, oldest first.
+	GetConcurrencyMetrics(endpointID uuid.UUID, since time.Time) ([]types.ConcurrencyMetric, error)
+	// DeleteConcurrencyMetrics deletes the concurrency metrics created
+	// before the given time and returns the amount of deleted metrics.
+	DeleteConcurrencyMetrics(before time.Time) (int, error)
+	// AddUsage adds the invocations of the record to the usage of its
+	// endpoint in its period.
+	AddUsage(*types.UsageRecord) error
+	// GetUsage returns the usage of all the endpoints in the given period.
+	GetUsage(period time.Time) ([]types.UsageRecord, error)
+	CreateInvocationError(*types.InvocationError) error
+	// GetInvocationErrors returns the invocation errors of an endpoint,
+	// oldest first.
+	GetInvocationErrors(endpointID uuid.UUID) ([]types.InvocationError, error)
+	// CreateCapturedRequest stores the captured request, only the latest
+	// types.MaxCapturedRequests of an endpoint are kept.
+	CreateCapturedRequest(*types.CapturedRequest) error
+	GetCapturedRequest(uuid.UUID) (*types.CapturedRequest, error)
+	// GetCapturedRequests returns the captured requests of an endpoint,
+	// oldest first.
+	GetCapturedRequests(endpointID uuid.UUID) ([]types.CapturedRequest, error)
 }
 
 type UpdateEndpointParams struct {
+	Name    string
+	Runtime string
+	// Environment replaces the environment of the endpoint as a whole.
 	Environment       map[string]string
 	ActiveDeployID    uuid.UUID
 	DeploymentHistory *types.DeploymentHistory
+	SessionAffinity   *types.SessionAffinity
+	Regions           []string
+	MaxConcurrency    *int
+	Probes            []types.Probe
+	StaticResponses   []types.StaticResponse
+	Routes            []types.Route
+	Deprecation       *types.Deprecation
+	DataKeys          []types.DataKey
+	Cache             *types.ResponseCache
+	Compression       *types.Compression
+	Limits            *types.Limits
+	WebSocket         *types.WebSocket
+	Fallback          *types.Fallback
+	Retention         *types.RetentionPolicy
+	Ownership         *types.Ownership
+	EnvironmentSchema *types.EnvironmentSchema
+	Rollout           *types.Rollout
+	Retry             *types.RetryPolicy
+	Capture           *types.Capture
+	CORS              *types.CORS
+	Verification      *types.RequestVerification
+	JWT               *types.JWTPolicy
+	Maintenance       *types.Maintenance
+	Prewarm           *types.Prewarm
+	// Environment captured when a deployment is published.
+	PublishedEnvironment *types.EnvironmentSnapshot
 }
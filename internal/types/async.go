@@ -0,0 +1,177 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxRetryAttempts is the maximum amount of attempts a retry policy allows.
+const maxRetryAttempts = 20
+
+// RetryPolicy decides how often the asynchronous invocations of an endpoint,
+// the invocations without a client waiting for the response, are attempted
+// before their request is moved to the dead-letter queue. Zero values fall
+// back to the policy of the installation.
+type RetryPolicy struct {
+	// Maximum attempts of an invocation, the first attempt included.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Wait in milliseconds before the first retry, it doubles with every
+	// retry up to the maximum backoff.
+	Backoff    int `json:"backoff,omitempty"`
+	MaxBackoff int `json:"max_backoff,omitempty"`
+}
+
+// Validate returns an error if the policy is malformed.
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("retry policy cannot be negative")
+	}
+	if p.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry policy cannot exceed %d attempts", maxRetryAttempts)
+	}
+	return nil
+}
+
+// Bound returns the policy of the invocations of the endpoint given the
+// policy of the installation.
+func (p *RetryPolicy) Bound(global RetryPolicy) RetryPolicy {
+	if p == nil {
+		return global
+	}
+	policy := *p
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = global.MaxAttempts
+	}
+	if policy.Backoff == 0 {
+		policy.Backoff = global.Backoff
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = global.MaxBackoff
+	}
+	return policy
+}
+
+// Delay returns the wait before the given retry, the first retry is 1.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := time.Duration(p.Backoff) * time.Millisecond
+	max := time.Duration(p.MaxBackoff) * time.Millisecond
+	for i := 1; i < retry && (max == 0 || delay < max); i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+// AsyncRequest is the request of an asynchronous invocation of the LIVE
+// deployment of an endpoint.
+type AsyncRequest struct {
+	Method string `json:"method"`
+	// Path relative to the endpoint, like /orders.
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// DeadLetter holds the request of an asynchronous invocation that failed
+// every attempt of its retry policy, so it can be inspected and re-driven.
+type DeadLetter struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// What invoked the endpoint, like an async invocation or a re-drive.
+	Source   string       `json:"source"`
+	Request  AsyncRequest `json:"request"`
+	Attempts int          `json:"attempts"`
+	// Status code of the last attempt, 0 when the ingress was not reached.
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	CreatedAT  time.Time `json:"created_at"`
+}
+
+// Status of an asynchronous invocation.
+const (
+	InvocationQueued    = "queued"
+	InvocationRunning   = "running"
+	InvocationSucceeded = "succeeded"
+	InvocationFailed    = "failed"
+)
+
+// Invocation is an asynchronous invocation of the LIVE deployment of an
+// endpoint, its status and result are polled by the caller.
+type Invocation struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Status     string    `json:"status"`
+	// Response of the last attempt, set once the invocation finished.
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Attempts   int         `json:"attempts"`
+	// Progress in percent (0-100) and message last reported by the guest
+	// while it is running.
+	Progress        int    `json:"progress"`
+	ProgressMessage string `json:"progress_message,omitempty"`
+	// Why the invocation failed, the request is moved to the dead letter.
+	Error        string     `json:"error,omitempty"`
+	DeadLetterID uuid.UUID  `json:"dead_letter_id"`
+	CreatedAT    time.Time  `json:"created_at"`
+	FinishedAT   *time.Time `json:"finished_at,omitempty"`
+}
+
+// NewInvocation returns a new queued invocation of the endpoint.
+func NewInvocation(endpointID uuid.UUID) *Invocation {
+	return &Invocation{
+		ID:         uuid.New(),
+		EndpointID: endpointID,
+		Status:     InvocationQueued,
+		CreatedAT:  time.Now(),
+	}
+}
+
+// Done returns true if the invocation either succeeded or failed.
+func (i *Invocation) Done() bool {
+	return i.Status == InvocationSucceeded || i.Status == InvocationFailed
+}
+
+// Status of a scheduled invocation.
+const (
+	SchedulePending  = "pending"
+	ScheduleFired    = "fired"
+	ScheduleCanceled = "canceled"
+	// ScheduleFailed is a scheduled invocation that could not be fired,
+	// like when its endpoint was deleted.
+	ScheduleFailed = "failed"
+)
+
+// ScheduledInvocation is an asynchronous invocation of the LIVE deployment of
+// an endpoint that is fired once at a later time.
+type ScheduledInvocation struct {
+	ID         uuid.UUID    `json:"id"`
+	EndpointID uuid.UUID    `json:"endpoint_id"`
+	Status     string       `json:"status"`
+	Request    AsyncRequest `json:"request"`
+	RunAT      time.Time    `json:"run_at"`
+	// Invocation fired at the scheduled time, its result is polled like
+	// the asynchronous invocations.
+	InvocationID uuid.UUID `json:"invocation_id"`
+	// Why the invocation could not be fired.
+	Error     string    `json:"error,omitempty"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// NewScheduledInvocation returns a new pending invocation of the endpoint to
+// fire at the given time.
+func NewScheduledInvocation(endpointID uuid.UUID, req AsyncRequest, runAT time.Time) *ScheduledInvocation {
+	return &ScheduledInvocation{
+		ID:         uuid.New(),
+		EndpointID: endpointID,
+		Status:     SchedulePending,
+		Request:    req,
+		RunAT:      runAT,
+		CreatedAT:  time.Now(),
+	}
+}
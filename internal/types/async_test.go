@@ -0,0 +1,26 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	require.Nil(t, (&RetryPolicy{MaxAttempts: 5, Backoff: 100}).Validate())
+	require.NotNil(t, (&RetryPolicy{Backoff: -1}).Validate())
+	require.NotNil(t, (&RetryPolicy{MaxAttempts: 21}).Validate())
+
+	global := RetryPolicy{MaxAttempts: 3, Backoff: 1000, MaxBackoff: 60000}
+	var none *RetryPolicy
+	require.Equal(t, global, none.Bound(global))
+	require.Equal(t, RetryPolicy{MaxAttempts: 5, Backoff: 1000, MaxBackoff: 60000}, (&RetryPolicy{MaxAttempts: 5}).Bound(global))
+
+	policy := RetryPolicy{Backoff: 100, MaxBackoff: 1000}
+	require.Equal(t, time.Millisecond*100, policy.Delay(1))
+	require.Equal(t, time.Millisecond*200, policy.Delay(2))
+	require.Equal(t, time.Millisecond*800, policy.Delay(4))
+	require.Equal(t, time.Second, policy.Delay(5))
+	require.Equal(t, time.Second, policy.Delay(50))
+}
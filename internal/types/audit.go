@@ -0,0 +1,54 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records a mutating call of the management API, entries are
+// never updated nor deleted.
+type AuditEntry struct {
+	ID uuid.UUID `json:"id"`
+	// Who made the call, as reported by the client.
+	Actor string `json:"actor"`
+	// Name of the API key the call was authenticated with, empty when the
+	// API does not require keys.
+	Key    string `json:"key,omitempty"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Route pattern of the call, like /endpoint/{id}/rollback.
+	Route string `json:"route"`
+	// Endpoint the call was about, zero when there is none.
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Query      string    `json:"query,omitempty"`
+	// Parameters of the call with the secrets redacted, or a summary of
+	// bodies that are not JSON.
+	Params     string    `json:"params,omitempty"`
+	SourceIP   string    `json:"source_ip"`
+	StatusCode int       `json:"status_code"`
+	CreatedAT  time.Time `json:"created_at"`
+}
+
+// AuditFilter selects entries of the audit log. Empty fields match all the
+// entries.
+type AuditFilter struct {
+	Actor      string
+	EndpointID uuid.UUID
+	// Only entries created at or after the time.
+	Since time.Time
+	// Maximum amount of entries, 0 is unlimited.
+	Limit int
+}
+
+// Match returns true if the entry is selected by the filter, regardless of
+// the limit.
+func (f AuditFilter) Match(e AuditEntry) bool {
+	if len(f.Actor) > 0 && e.Actor != f.Actor {
+		return false
+	}
+	if f.EndpointID != uuid.Nil && e.EndpointID != f.EndpointID {
+		return false
+	}
+	return !e.CreatedAT.Before(f.Since)
+}
@@ -0,0 +1,18 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditFilterMatch(t *testing.T) {
+	entry := AuditEntry{Actor: "alice", EndpointID: uuid.New(), CreatedAT: time.Now()}
+	require.True(t, AuditFilter{}.Match(entry))
+	require.True(t, AuditFilter{Actor: "alice", EndpointID: entry.EndpointID, Since: entry.CreatedAT}.Match(entry))
+	require.False(t, AuditFilter{Actor: "bob"}.Match(entry))
+	require.False(t, AuditFilter{EndpointID: uuid.New()}.Match(entry))
+	require.False(t, AuditFilter{Since: entry.CreatedAT.Add(time.Second)}.Match(entry))
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status of a build.
+const (
+	BuildQueued    = "queued"
+	BuildRunning   = "running"
+	BuildSucceeded = "succeeded"
+	BuildFailed    = "failed"
+)
+
+// Languages the sources of a build can be written in.
+const (
+	BuildLanguageGo     = "go"
+	BuildLanguageTinyGo = "tinygo"
+	BuildLanguageRust   = "rust"
+)
+
+// Build compiles an uploaded source archive to WASM, a deployment is
+// created from the module when the build succeeds.
+type Build struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Language   string    `json:"language"`
+	Status     string    `json:"status"`
+	// Output of the toolchain, followed by the reason of a failure.
+	Logs string `json:"logs"`
+	// Deployment created from the module, set once the build succeeded.
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	// Metadata the deployment is created with.
+	DeploymentMetadata
+	CreatedAT  time.Time  `json:"created_at"`
+	FinishedAT *time.Time `json:"finished_at,omitempty"`
+}
+
+// NewBuild returns a new queued build.
+func NewBuild(endpoint *Endpoint, language string, metadata DeploymentMetadata) *Build {
+	return &Build{
+		ID:                 uuid.New(),
+		EndpointID:         endpoint.ID,
+		Language:           language,
+		Status:             BuildQueued,
+		DeploymentMetadata: metadata,
+		CreatedAT:          time.Now(),
+	}
+}
+
+// ValidBuildLanguage returns true if sources of the language can be built.
+func ValidBuildLanguage(language string) bool {
+	switch language {
+	case BuildLanguageGo, BuildLanguageTinyGo, BuildLanguageRust:
+		return true
+	}
+	return false
+}
+
+// Done returns true if the build either succeeded or failed.
+func (b *Build) Done() bool {
+	return b.Status == BuildSucceeded || b.Status == BuildFailed
+}
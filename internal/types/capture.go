@@ -0,0 +1,89 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxCapturedBody is the maximum size in bytes of the body of a captured
+// request, larger requests are not captured since they could not be
+// replayed.
+const MaxCapturedBody = 1 << 20
+
+// MaxCapturedRequests is the amount of captured requests kept per endpoint,
+// the oldest requests are dropped.
+const MaxCapturedRequests = 100
+
+// RedactedHeader replaces the values of the redacted headers of the
+// captured requests.
+const RedactedHeader = "REDACTED"
+
+// CredentialHeaders are redacted from every captured request, whatever the
+// redacted headers of the capture.
+var CredentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Capture records the full payload of a sample of the LIVE requests of an
+// endpoint, so a problematic request can be replayed against another
+// deployment or locally.
+type Capture struct {
+	// Share of the requests that are captured, between 0 and 1.
+	SampleRate float64 `json:"sample_rate"`
+	// Headers whose values are not captured on top of the credential
+	// headers, like X-Api-Key.
+	RedactHeaders []string `json:"redact_headers,omitempty"`
+}
+
+// Validate returns an error if the capture is malformed.
+func (c *Capture) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("capture sample rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// Sample returns true if the request with the given random number in [0, 1)
+// is captured.
+func (c *Capture) Sample(n float64) bool {
+	return c != nil && n < c.SampleRate
+}
+
+// CapturedRequest is the full payload of a LIVE request. Its id is the id
+// of the invocation, the x-request-id of the request.
+type CapturedRequest struct {
+	ID           uuid.UUID `json:"id"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	Method       string    `json:"method"`
+	// Path relative to the endpoint.
+	Path      string      `json:"path"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body,omitempty"`
+	CreatedAT time.Time   `json:"created_at"`
+}
+
+// NewCapturedRequest returns the captured request of the invocation. The
+// credential headers, the given headers, like the credential headers of the
+// endpoint, and the redacted headers of the capture are redacted.
+func NewCapturedRequest(c *Capture, redact []string, id, endpointID, deploymentID uuid.UUID, method, path string, header http.Header, body []byte) CapturedRequest {
+	header = header.Clone()
+	for _, names := range [][]string{CredentialHeaders, redact, c.RedactHeaders} {
+		for _, name := range names {
+			if len(header.Values(name)) > 0 {
+				header.Set(name, RedactedHeader)
+			}
+		}
+	}
+	return CapturedRequest{
+		ID:           id,
+		EndpointID:   endpointID,
+		DeploymentID: deploymentID,
+		Method:       method,
+		Path:         path,
+		Header:       header,
+		Body:         body,
+		CreatedAT:    time.Now(),
+	}
+}
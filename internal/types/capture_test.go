@@ -0,0 +1,63 @@
+package types
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	require.Nil(t, (&Capture{SampleRate: 0.1}).Validate())
+	require.NotNil(t, (&Capture{SampleRate: 1.5}).Validate())
+	require.NotNil(t, (&Capture{SampleRate: -1}).Validate())
+
+	var none *Capture
+	require.False(t, none.Sample(0))
+	capture := &Capture{SampleRate: 0.25, RedactHeaders: []string{"authorization", "Cookie"}}
+	require.True(t, capture.Sample(0.1))
+	require.False(t, capture.Sample(0.25))
+	require.False(t, (&Capture{}).Sample(0))
+
+	header := http.Header{"Authorization": {"Bearer secret"}, "Accept": {"*/*"}}
+	r := NewCapturedRequest(capture, nil, uuid.New(), uuid.New(), uuid.New(), "GET", "/", header, nil)
+	require.Equal(t, RedactedHeader, r.Header.Get("Authorization"))
+	require.Equal(t, "*/*", r.Header.Get("Accept"))
+	require.Empty(t, r.Header.Values("Cookie"))
+	// The header of the request is not modified.
+	require.Equal(t, "Bearer secret", header.Get("Authorization"))
+}
+
+func TestCaptureCredentialHeaders(t *testing.T) {
+	endpoint := Endpoint{
+		JWT:          &JWTPolicy{JWKSURL: "https://issuer.example.com/jwks", Header: "X-Token"},
+		Verification: &RequestVerification{SecretEnv: "WEBHOOK_SECRET"},
+		Capture:      &Capture{SampleRate: 1, RedactHeaders: []string{"X-Api-Key"}},
+	}
+	require.Equal(t, []string{"X-Token", DefaultVerificationHeader}, endpoint.CredentialHeaders())
+	require.Empty(t, Endpoint{}.CredentialHeaders())
+
+	header := http.Header{
+		"Authorization":       {"Bearer secret"},
+		"Cookie":              {"session=secret"},
+		"Proxy-Authorization": {"Basic secret"},
+		"X-Token":             {"secret"},
+		"Raptor-Signature":    {"t=1,v1=secret"},
+		"X-Api-Key":           {"secret"},
+		"Accept":              {"*/*"},
+	}
+	// The credentials are redacted without being listed by the capture.
+	r := NewCapturedRequest(endpoint.Capture, endpoint.CredentialHeaders(), uuid.New(), uuid.New(), uuid.New(), "GET", "/", header, nil)
+	for name := range header {
+		if name == "Accept" {
+			require.Equal(t, "*/*", r.Header.Get(name))
+			continue
+		}
+		require.Equal(t, []string{RedactedHeader}, r.Header.Values(name), name)
+	}
+	r = NewCapturedRequest(&Capture{SampleRate: 1}, nil, uuid.New(), uuid.New(), uuid.New(), "GET", "/", header, nil)
+	require.Equal(t, RedactedHeader, r.Header.Get("Authorization"))
+	require.Equal(t, RedactedHeader, r.Header.Get("Cookie"))
+	require.Equal(t, "secret", r.Header.Get("X-Token"))
+}
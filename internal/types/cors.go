@@ -0,0 +1,127 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxCORSMaxAge is the maximum time in seconds browsers may cache a
+// preflight response.
+const maxCORSMaxAge = 86400
+
+// corsMethods are the methods a CORS policy can allow.
+var corsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// CORS is the cross-origin resource sharing policy the ingress applies to
+// the requests of an endpoint, it answers the preflight requests without
+// invoking the function. A policy without allowed origins is disabled.
+// Empty methods, headers and max age fall back to the policy of the
+// installation.
+type CORS struct {
+	// Origins allowed to call the endpoint from a browser, like
+	// https://example.com, https://*.example.com or * for any origin.
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+	// Request headers the browsers may send, * allows any header.
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	// Response headers the scripts may read.
+	ExposedHeaders []string `json:"exposed_headers,omitempty"`
+	// Time in seconds the browsers may cache a preflight response.
+	MaxAge           int  `json:"max_age,omitempty"`
+	AllowCredentials bool `json:"allow_credentials,omitempty"`
+}
+
+// Enabled returns true if the policy allows at least one origin.
+func (c *CORS) Enabled() bool {
+	return c != nil && len(c.AllowedOrigins) > 0
+}
+
+// Validate returns an error if the policy is malformed.
+func (c *CORS) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("cors policy cannot allow credentials for any origin")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "*.", "", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(u.Path) > 0 || strings.Count(origin, "*") > 1 ||
+			(strings.Contains(origin, "*") && !strings.HasPrefix(origin, u.Scheme+"://*.")) {
+			return fmt.Errorf("invalid cors origin given: %s", origin)
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if method != "*" && !corsMethods[method] {
+			return fmt.Errorf("invalid cors method given: %s", method)
+		}
+	}
+	for _, header := range append(c.AllowedHeaders, c.ExposedHeaders...) {
+		if len(header) == 0 || strings.ContainsAny(header, " ,:") {
+			return fmt.Errorf("invalid cors header given: %q", header)
+		}
+	}
+	if c.MaxAge < 0 || c.MaxAge > maxCORSMaxAge {
+		return fmt.Errorf("cors max age must be between 0 and %d seconds", maxCORSMaxAge)
+	}
+	return nil
+}
+
+// Bound returns the policy of the endpoint given the policy of the
+// installation.
+func (c *CORS) Bound(global CORS) CORS {
+	policy := *c
+	if len(policy.AllowedMethods) == 0 {
+		policy.AllowedMethods = global.AllowedMethods
+	}
+	if len(policy.AllowedHeaders) == 0 {
+		policy.AllowedHeaders = global.AllowedHeaders
+	}
+	if len(policy.ExposedHeaders) == 0 {
+		policy.ExposedHeaders = global.ExposedHeaders
+	}
+	if policy.MaxAge == 0 {
+		policy.MaxAge = global.MaxAge
+	}
+	return policy
+}
+
+// AllowOrigin returns true if the policy allows the given origin.
+func (c *CORS) AllowOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		// https://*.example.com matches the subdomains of example.com.
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(allowed)-1 && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyOrigin returns true if the policy allows any origin.
+func (c *CORS) AnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	require.Nil(t, (&CORS{}).Validate())
+	require.Nil(t, (&CORS{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}).Validate())
+	require.Nil(t, (&CORS{AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"}}).Validate())
+	require.NotNil(t, (&CORS{AllowedOrigins: []string{"https://example.com/app"}}).Validate())
+	require.NotNil(t, (&CORS{AllowedOrigins: []string{"https://api.*.com"}}).Validate())
+	require.NotNil(t, (&CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Validate())
+	require.NotNil(t, (&CORS{AllowedHeaders: []string{"Content-Type, Accept"}}).Validate())
+	require.NotNil(t, (&CORS{MaxAge: -1}).Validate())
+
+	var none *CORS
+	require.False(t, none.Enabled())
+	require.False(t, (&CORS{}).Enabled())
+
+	cors := &CORS{AllowedOrigins: []string{"https://example.com", "https://*.example.com"}}
+	require.True(t, cors.AllowOrigin("https://example.com"))
+	require.True(t, cors.AllowOrigin("https://APP.example.com"))
+	require.False(t, cors.AllowOrigin("https://.example.com"))
+	require.False(t, cors.AllowOrigin("http://app.example.com"))
+	require.False(t, cors.AllowOrigin("https://evil-example.com"))
+	require.False(t, cors.AllowOrigin(""))
+	require.False(t, cors.AnyOrigin())
+
+	global := CORS{AllowedMethods: []string{"GET"}, AllowedHeaders: []string{"Accept"}, MaxAge: 600}
+	policy := (&CORS{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"POST"}}).Bound(global)
+	require.Equal(t, []string{"POST"}, policy.AllowedMethods)
+	require.Equal(t, []string{"Accept"}, policy.AllowedHeaders)
+	require.Equal(t, 600, policy.MaxAge)
+}
@@ -1,8 +1,11 @@
 package types
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,13 +14,131 @@ import (
 type Deployment struct {
 	ID         uuid.UUID `json:"id"`
 	EndpointID uuid.UUID `json:"endpoint_id"`
-	Hash       string    `json:"hash"`
-	Blob       []byte    `json:"-"`
+	// SHA-256 of the blob, the blobs are stored addressed by their hash.
+	Hash string `json:"hash"`
+	Blob []byte `json:"-"`
+	// Zip archive with static assets served along with the deployment.
+	Assets    []byte `json:"-"`
+	HasAssets bool   `json:"has_assets"`
+	// Ed25519 signature of the blob, empty when the deployment is not
+	// signed.
+	Signature []byte `json:"signature,omitempty"`
+	// Build the blob was compiled by, nil when the blob was uploaded.
+	BuildID     *uuid.UUID `json:"build_id,omitempty"`
+	BuildStatus string     `json:"build_status,omitempty"`
+	// Registry reference or URL the blob was pulled from, empty when the
+	// blob was uploaded.
+	Source string `json:"source,omitempty"`
+	DeploymentMetadata
+	// URL the deployment is served at before it is published, set by the
+	// API server in its responses.
+	PreviewURL string    `json:"preview_url,omitempty"`
 	CreatedAT  time.Time `json:"created_at"`
 }
 
+// Limits of the metadata of a deployment.
+const (
+	MaxDeploymentLabels  = 32
+	maxLabelKeyLength    = 63
+	maxLabelValueLength  = 255
+	maxGitBranchLength   = 255
+	maxDescriptionLength = 1024
+	// Abbreviated SHA-1 up to a full SHA-256 object name.
+	minGitCommitLength = 7
+	maxGitCommitLength = 64
+)
+
+// DeploymentMetadata is user supplied information about a deployment, like
+// the commit of the source it was built from.
+type DeploymentMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	GitCommit   string            `json:"git_commit,omitempty"`
+	GitBranch   string            `json:"git_branch,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+// Validate returns an error if the metadata exceeds its limits.
+func (m DeploymentMetadata) Validate() error {
+	if len(m.Labels) > MaxDeploymentLabels {
+		return fmt.Errorf("a deployment can have at most %d labels", MaxDeploymentLabels)
+	}
+	for k, v := range m.Labels {
+		if len(k) == 0 || len(k) > maxLabelKeyLength || strings.IndexFunc(k, invalidLabelRune) >= 0 {
+			return fmt.Errorf("invalid label %q: keys are 1 to %d letters, digits, '.', '_', '-' or '/'", k, maxLabelKeyLength)
+		}
+		if len(v) > maxLabelValueLength {
+			return fmt.Errorf("label %q exceeds the maximum of %d characters", k, maxLabelValueLength)
+		}
+	}
+	if len(m.GitCommit) > 0 {
+		n := len(m.GitCommit)
+		if n < minGitCommitLength || n > maxGitCommitLength || strings.IndexFunc(m.GitCommit, invalidHexRune) >= 0 {
+			return fmt.Errorf("invalid git commit %q: should be %d to %d lowercase hex characters", m.GitCommit, minGitCommitLength, maxGitCommitLength)
+		}
+	}
+	if len(m.GitBranch) > maxGitBranchLength {
+		return fmt.Errorf("git branch exceeds the maximum of %d characters", maxGitBranchLength)
+	}
+	if len(m.Description) > maxDescriptionLength {
+		return fmt.Errorf("description exceeds the maximum of %d characters", maxDescriptionLength)
+	}
+	return nil
+}
+
+func invalidLabelRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r == '.', r == '_', r == '-', r == '/':
+		return false
+	}
+	return true
+}
+
+func invalidHexRune(r rune) bool {
+	return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f')
+}
+
+// Equal returns true if both metadata are the same.
+func (m DeploymentMetadata) Equal(other DeploymentMetadata) bool {
+	if len(m.Labels) != len(other.Labels) {
+		return false
+	}
+	for k, v := range m.Labels {
+		if w, ok := other.Labels[k]; !ok || w != v {
+			return false
+		}
+	}
+	return m.GitCommit == other.GitCommit &&
+		m.GitBranch == other.GitBranch &&
+		m.Description == other.Description
+}
+
+// DeploymentFilter selects deployments by their metadata. Empty fields match
+// all the deployments.
+type DeploymentFilter struct {
+	// Labels the deployments need to have, all of them.
+	Labels map[string]string
+	// Prefix of the git commit, like an abbreviated hash.
+	GitCommit string
+	GitBranch string
+}
+
+// Match returns true if the deployment is selected by the filter.
+func (f DeploymentFilter) Match(d Deployment) bool {
+	for k, v := range f.Labels {
+		if w, ok := d.Labels[k]; !ok || w != v {
+			return false
+		}
+	}
+	if len(f.GitCommit) > 0 && !strings.HasPrefix(d.GitCommit, strings.ToLower(f.GitCommit)) {
+		return false
+	}
+	return len(f.GitBranch) == 0 || d.GitBranch == f.GitBranch
+}
+
 func NewDeployment(endpoint *Endpoint, blob []byte) *Deployment {
-	hashBytes := md5.Sum(blob)
+	hashBytes := sha256.Sum256(blob)
 	hashstr := hex.EncodeToString(hashBytes[:])
 	deployID := uuid.New()
 	return &Deployment{
@@ -28,3 +149,69 @@ func NewDeployment(endpoint *Endpoint, blob []byte) *Deployment {
 		CreatedAT:  time.Now(),
 	}
 }
+
+// SetAssets attaches the given zip archive with static assets to the
+// deployment.
+func (d *Deployment) SetAssets(b []byte) {
+	d.Assets = b
+	d.HasAssets = len(b) > 0
+}
+
+// RetentionPolicy decides which deployments of an endpoint are kept by the
+// garbage collection. A deployment is kept when one of the rules keeps it,
+// the active deployment is always kept. The zero policy keeps everything.
+type RetentionPolicy struct {
+	// Amount of most recent deployments that are kept.
+	KeepLast int `json:"keep_last,omitempty"`
+	// Deployments created within the last amount of days are kept.
+	KeepDays int `json:"keep_days,omitempty"`
+}
+
+// Validate returns an error if the policy is malformed.
+func (p *RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 {
+		return fmt.Errorf("retention keep last cannot be negative")
+	}
+	if p.KeepDays < 0 {
+		return fmt.Errorf("retention keep days cannot be negative")
+	}
+	return nil
+}
+
+// IsZero returns true if the policy keeps every deployment.
+func (p *RetentionPolicy) IsZero() bool {
+	return p == nil || (p.KeepLast == 0 && p.KeepDays == 0)
+}
+
+// Expired returns the deployments the policy does not keep given all the
+// deployments of an endpoint and its active deployment, in the order they
+// were given.
+func (p *RetentionPolicy) Expired(deploys []Deployment, activeID uuid.UUID, now time.Time) []Deployment {
+	if p.IsZero() {
+		return nil
+	}
+	newest := make([]Deployment, len(deploys))
+	copy(newest, deploys)
+	sort.SliceStable(newest, func(i, j int) bool {
+		return newest[i].CreatedAT.After(newest[j].CreatedAT)
+	})
+	keep := make(map[uuid.UUID]bool)
+	cutoff := now.AddDate(0, 0, -p.KeepDays)
+	for i, deploy := range newest {
+		switch {
+		case deploy.ID == activeID:
+		case p.KeepLast > 0 && i < p.KeepLast:
+		case p.KeepDays > 0 && deploy.CreatedAT.After(cutoff):
+		default:
+			continue
+		}
+		keep[deploy.ID] = true
+	}
+	var expired []Deployment
+	for _, deploy := range deploys {
+		if !keep[deploy.ID] {
+			expired = append(expired, deploy)
+		}
+	}
+	return expired
+}
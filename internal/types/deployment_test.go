@@ -0,0 +1,79 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	// Deployments created 10, 5, 3 and 1 days ago.
+	var deploys []Deployment
+	for _, days := range []int{10, 5, 3, 1} {
+		deploys = append(deploys, Deployment{ID: uuid.New(), CreatedAT: now.AddDate(0, 0, -days)})
+	}
+	ids := func(deploys []Deployment) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, deploy := range deploys {
+			ids = append(ids, deploy.ID)
+		}
+		return ids
+	}
+	active := deploys[3].ID
+
+	var zero *RetentionPolicy
+	require.Nil(t, zero.Expired(deploys, active, now))
+	require.Nil(t, (&RetentionPolicy{}).Expired(deploys, active, now))
+
+	p := &RetentionPolicy{KeepLast: 2}
+	require.Equal(t, ids(deploys[:2]), ids(p.Expired(deploys, active, now)))
+
+	p = &RetentionPolicy{KeepDays: 4}
+	require.Equal(t, ids(deploys[:2]), ids(p.Expired(deploys, active, now)))
+
+	// A deployment kept by either rule is kept.
+	p = &RetentionPolicy{KeepLast: 1, KeepDays: 6}
+	require.Equal(t, ids(deploys[:1]), ids(p.Expired(deploys, active, now)))
+
+	// The active deployment is never expired.
+	p = &RetentionPolicy{KeepLast: 1}
+	require.Equal(t, ids(deploys[1:3]), ids(p.Expired(deploys, deploys[0].ID, now)))
+
+	require.NotNil(t, (&RetentionPolicy{KeepLast: -1}).Validate())
+}
+
+func TestDeploymentMetadataValidate(t *testing.T) {
+	valid := []DeploymentMetadata{
+		{},
+		{Labels: map[string]string{"app.kubernetes.io/name": "api"}, GitCommit: "3f2a9c1", GitBranch: "feature/login"},
+	}
+	for _, m := range valid {
+		require.Nil(t, m.Validate())
+	}
+	invalid := []DeploymentMetadata{
+		{Labels: map[string]string{"": "x"}},
+		{Labels: map[string]string{"with space": "x"}},
+		{GitCommit: "3f2a"},
+		{GitCommit: "3F2A9C1D"},
+		{GitCommit: "zzzzzzzz"},
+	}
+	for _, m := range invalid {
+		require.NotNil(t, m.Validate(), "%+v", m)
+	}
+}
+
+func TestDeploymentFilterMatch(t *testing.T) {
+	deploy := Deployment{DeploymentMetadata: DeploymentMetadata{
+		Labels:    map[string]string{"env": "staging", "team": "payments"},
+		GitCommit: "3f2a9c1d",
+		GitBranch: "main",
+	}}
+	require.True(t, DeploymentFilter{}.Match(deploy))
+	require.True(t, DeploymentFilter{Labels: map[string]string{"env": "staging"}, GitCommit: "3F2A"}.Match(deploy))
+	require.False(t, DeploymentFilter{Labels: map[string]string{"env": "production"}}.Match(deploy))
+	require.False(t, DeploymentFilter{GitBranch: "release"}.Match(deploy))
+	require.False(t, DeploymentFilter{GitCommit: "8b0e"}.Match(deploy))
+}
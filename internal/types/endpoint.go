@@ -1,6 +1,9 @@
 package types
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,7 +26,376 @@ type Endpoint struct {
 	ActiveDeploymentID uuid.UUID            `json:"active_deployment_id"`
 	Environment        map[string]string    `json:"environment"`
 	DeploymentHistory  []*DeploymentHistory `json:"deployment_history"`
-	CreatedAT          time.Time            `json:"created_at"`
+	SessionAffinity    *SessionAffinity     `json:"session_affinity,omitempty"`
+	Regions            []string             `json:"regions,omitempty"`
+	// Maximum amount of requests served concurrently, 0 means unlimited.
+	MaxConcurrency int     `json:"max_concurrency"`
+	Probes         []Probe `json:"probes,omitempty"`
+	// Responses served by the ingress without invoking the deployment.
+	StaticResponses []StaticResponse `json:"static_responses,omitempty"`
+	// Path prefixes that are routed to the LIVE deployment of other endpoints.
+	Routes []Route `json:"routes,omitempty"`
+	// Notice sent to the consumers of the endpoint ahead of its removal.
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+	// Caching of the LIVE responses at the ingress.
+	Cache *ResponseCache `json:"cache,omitempty"`
+	// Compression of the responses at the ingress.
+	Compression *Compression `json:"compression,omitempty"`
+	// Resource limits of the invocations, bounded by the installation.
+	Limits *Limits `json:"limits,omitempty"`
+	// WebSocket upgrades of the requests to the endpoint.
+	WebSocket *WebSocket `json:"websocket,omitempty"`
+	// Origin the LIVE requests are proxied to when the cluster can not
+	// serve them.
+	Fallback *Fallback `json:"fallback,omitempty"`
+	// Deployments of the endpoint kept by the garbage collection.
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Environment variables required to publish a deployment.
+	EnvironmentSchema *EnvironmentSchema `json:"environment_schema,omitempty"`
+	// Owner of the endpoint and its pending transfer to another owner.
+	Ownership *Ownership `json:"ownership,omitempty"`
+	// Latest gradual shift of the LIVE traffic to a new deployment.
+	Rollout *Rollout `json:"rollout,omitempty"`
+	// Retries of the asynchronous invocations before they are dead-lettered.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Recording of a sample of the LIVE requests so they can be replayed.
+	Capture *Capture `json:"capture,omitempty"`
+	// CORS policy the ingress applies to the requests of the endpoint.
+	CORS *CORS `json:"cors,omitempty"`
+	// Verification of the signature of the requests at the ingress.
+	Verification *RequestVerification `json:"verification,omitempty"`
+	// Validation of the JSON Web Token of the requests at the ingress.
+	JWT *JWTPolicy `json:"jwt,omitempty"`
+	// Maintenance takes the endpoint offline while it is disabled.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+	// Runtimes kept warm ahead of the requests.
+	Prewarm *Prewarm `json:"prewarm,omitempty"`
+	// Environment captured when the active deployment was published.
+	PublishedEnvironment *EnvironmentSnapshot `json:"-"`
+	// Data keys the environment of the endpoint is encrypted with, wrapped
+	// by the master key. Never exposed through the API.
+	DataKeys  []DataKey `json:"-"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// PreferredRegion returns the region the runtimes of the endpoint should be
+// activated in given the region of the caller. The region of the caller is
+// preferred unless the endpoint is pinned to other regions.
+func (e Endpoint) PreferredRegion(callerRegion string) string {
+	if len(e.Regions) == 0 {
+		return callerRegion
+	}
+	for _, region := range e.Regions {
+		if region == callerRegion {
+			return callerRegion
+		}
+	}
+	return e.Regions[0]
+}
+
+// DefaultAffinitySlots is the number of runtimes the sessions of an endpoint
+// are spread over when no slots are configured.
+const DefaultAffinitySlots = 16
+
+// SessionAffinity routes the LIVE requests of the same end-user session to
+// the same runtime. The session is identified by the value of the given
+// header, or the given cookie when the header is not present.
+type SessionAffinity struct {
+	Header string `json:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+	// The number of runtimes the sessions are consistently hashed over.
+	Slots int `json:"slots,omitempty"`
+}
+
+// StaticResponse is a response for a specific path of an endpoint that is
+// answered at the ingress without spending a WASM invocation.
+type StaticResponse struct {
+	Path        string `json:"path"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// StaticResponse returns the static response for the given path if the
+// endpoint has one configured.
+func (e Endpoint) StaticResponse(path string) *StaticResponse {
+	for i := range e.StaticResponses {
+		if e.StaticResponses[i].Path == path {
+			return &e.StaticResponses[i]
+		}
+	}
+	return nil
+}
+
+// Route routes the requests of which the path starts with the given prefix
+// to the LIVE deployment of another endpoint.
+type Route struct {
+	Prefix     string    `json:"prefix"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+}
+
+// Route returns the route with the longest prefix matching the given path,
+// nil is returned when none of the routes match.
+func (e Endpoint) Route(path string) *Route {
+	var match *Route
+	for i, route := range e.Routes {
+		if path != route.Prefix && !strings.HasPrefix(path, route.Prefix+"/") {
+			continue
+		}
+		if match == nil || len(route.Prefix) > len(match.Prefix) {
+			match = &e.Routes[i]
+		}
+	}
+	return match
+}
+
+// Deprecation announces to API consumers that the endpoint is (or will be)
+// deprecated and when it stops serving requests. It is sent along with every
+// LIVE response as the Deprecation (RFC 9745) and Sunset (RFC 8594) headers.
+type Deprecation struct {
+	// The moment the endpoint is deprecated, can be in the future.
+	Date time.Time `json:"date"`
+	// The moment the endpoint stops serving requests.
+	Sunset time.Time `json:"sunset"`
+	// Optional URL with more information, like a migration guide.
+	Link string `json:"link,omitempty"`
+}
+
+// IsZero returns true if neither the date nor the sunset are set.
+func (d *Deprecation) IsZero() bool {
+	return d == nil || (d.Date.IsZero() && d.Sunset.IsZero())
+}
+
+// DefaultMaintenanceMessage is the body of the responses of a disabled
+// endpoint without a maintenance message.
+const DefaultMaintenanceMessage = "endpoint is under maintenance"
+
+// maxMaintenanceMessageSize is the maximum size in bytes of a maintenance
+// message.
+const maxMaintenanceMessageSize = 4 << 10
+
+// Maintenance takes an endpoint offline without deleting it or unpublishing
+// its deployment. While the endpoint is disabled, the ingress answers its
+// LIVE requests with a 503 and the message.
+type Maintenance struct {
+	Disabled bool   `json:"disabled"`
+	Message  string `json:"message,omitempty"`
+	// Seconds the clients are asked to wait before retrying, sent as the
+	// Retry-After header when set.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// Active returns true if the endpoint is disabled.
+func (m *Maintenance) Active() bool {
+	return m != nil && m.Disabled
+}
+
+// Text returns the message the disabled endpoint answers with.
+func (m *Maintenance) Text() string {
+	if len(m.Message) == 0 {
+		return DefaultMaintenanceMessage
+	}
+	return m.Message
+}
+
+// Validate returns an error if the maintenance is malformed.
+func (m *Maintenance) Validate() error {
+	if len(m.Message) > maxMaintenanceMessageSize {
+		return fmt.Errorf("maintenance message cannot be larger than %d bytes", maxMaintenanceMessageSize)
+	}
+	if m.RetryAfter < 0 {
+		return fmt.Errorf("maintenance retry after cannot be negative")
+	}
+	return nil
+}
+
+// MaxCacheTTL is the maximum amount of seconds a response is cached by the
+// ingress, regardless of the Cache-Control of the response.
+const MaxCacheTTL = 86400
+
+// ResponseCache caches the responses to the GET requests of an endpoint at
+// the ingress, so read heavy endpoints do not invoke their deployment on
+// every request. Responses are cached for as long as their Cache-Control
+// allows shared caches to.
+type ResponseCache struct {
+	Enabled bool `json:"enabled"`
+	// Request headers the cached responses vary on, next to the ones listed
+	// in the Vary header of the responses.
+	Vary []string `json:"vary,omitempty"`
+	// Seconds responses without a Cache-Control are cached, 0 means these
+	// responses are not cached.
+	DefaultTTL int `json:"default_ttl,omitempty"`
+	// Bumped on every purge, responses cached under a previous generation
+	// are never served again.
+	Generation int `json:"generation"`
+}
+
+// Validate returns an error if the cache configuration is malformed.
+func (c *ResponseCache) Validate() error {
+	if c.DefaultTTL < 0 || c.DefaultTTL > MaxCacheTTL {
+		return fmt.Errorf("cache default ttl should be between 0 and %d seconds", MaxCacheTTL)
+	}
+	for _, header := range c.Vary {
+		if len(header) == 0 || strings.ContainsAny(header, " :\r\n") {
+			return fmt.Errorf("invalid cache vary header: %q", header)
+		}
+	}
+	return nil
+}
+
+// CacheEnabled returns true if the LIVE responses of the endpoint are cached.
+func (e Endpoint) CacheEnabled() bool {
+	return e.Cache != nil && e.Cache.Enabled
+}
+
+// DefaultCompressionMinSize is the minimum size in bytes of the compressed
+// responses when no minimum size is configured. Compressing smaller
+// responses costs more than it saves.
+const DefaultCompressionMinSize = 1024
+
+// Compression compresses the responses of an endpoint at the ingress for the
+// clients that accept it. Only text based content types are compressed.
+type Compression struct {
+	Enabled bool `json:"enabled"`
+	// Minimum size in bytes of the compressed responses, 0 means the
+	// DefaultCompressionMinSize.
+	MinSize int `json:"min_size,omitempty"`
+	// Gzip compression level from 1 (fastest) to 9 (smallest), 0 means the
+	// default level.
+	Level int `json:"level,omitempty"`
+}
+
+// Validate returns an error if the compression configuration is malformed.
+func (c *Compression) Validate() error {
+	if c.MinSize < 0 {
+		return fmt.Errorf("compression min size cannot be negative")
+	}
+	if c.Level < 0 || c.Level > 9 {
+		return fmt.Errorf("compression level should be between 1 and 9")
+	}
+	return nil
+}
+
+// DefaultWebSocketMaxMessageSize is the maximum size in bytes of the messages
+// a WebSocket client can send when no maximum size is configured.
+const DefaultWebSocketMaxMessageSize = 65536
+
+// WebSocket allows the clients of an endpoint to upgrade their requests to
+// WebSocket connections. Every connection is served by its own invocation of
+// the deployment that reads and writes the messages for as long as the
+// connection is open.
+type WebSocket struct {
+	Enabled bool `json:"enabled"`
+	// Maximum size in bytes of the messages sent by the client, 0 means the
+	// DefaultWebSocketMaxMessageSize.
+	MaxMessageSize int `json:"max_message_size,omitempty"`
+}
+
+// Validate returns an error if the WebSocket configuration is malformed.
+func (ws *WebSocket) Validate() error {
+	if ws.MaxMessageSize < 0 {
+		return fmt.Errorf("websocket max message size cannot be negative")
+	}
+	return nil
+}
+
+// WebSocketEnabled returns true if the endpoint accepts WebSocket upgrades.
+func (e Endpoint) WebSocketEnabled() bool {
+	return e.WebSocket != nil && e.WebSocket.Enabled
+}
+
+// Fallback is the origin the ingress proxies the LIVE requests of the
+// endpoint to when the cluster can not serve them, instead of responding
+// with 503. It eases migrating an existing service onto the platform.
+type Fallback struct {
+	// Absolute http or https URL of the origin, the path of the request
+	// relative to the endpoint is appended to it. Empty removes the fallback.
+	URL string `json:"url"`
+}
+
+// Validate returns an error if the fallback is not an absolute http or https
+// URL.
+func (f *Fallback) Validate() error {
+	if len(f.URL) == 0 {
+		return nil
+	}
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return fmt.Errorf("invalid fallback url: %s", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("fallback url should be an absolute http or https url: %s", f.URL)
+	}
+	return nil
+}
+
+// FallbackURL returns the fallback origin of the endpoint, nil when it has
+// none.
+func (e Endpoint) FallbackURL() *url.URL {
+	if e.Fallback == nil || len(e.Fallback.URL) == 0 {
+		return nil
+	}
+	u, err := url.Parse(e.Fallback.URL)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// DataKey is a data encryption key of an endpoint. Keys are rotated by adding
+// a new version and re-encrypting the environment, older versions are kept to
+// decrypt the values encrypted with them elsewhere, like in the backups.
+type DataKey struct {
+	Version int `json:"version"`
+	// The key wrapped (encrypted) by the master key.
+	Key       []byte    `json:"key"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// FindDataKey returns the data key with the given version.
+func FindDataKey(keys []DataKey, version int) (DataKey, bool) {
+	for _, key := range keys {
+		if key.Version == version {
+			return key, true
+		}
+	}
+	return DataKey{}, false
+}
+
+// LatestDataKey returns the data key with the highest version.
+func LatestDataKey(keys []DataKey) (DataKey, bool) {
+	var (
+		latest DataKey
+		ok     bool
+	)
+	for _, key := range keys {
+		if !ok || key.Version > latest.Version {
+			latest, ok = key, true
+		}
+	}
+	return latest, ok
+}
+
+// Owner returns the owner of the endpoint, empty when it has none.
+func (e Endpoint) Owner() string {
+	if e.Ownership == nil {
+		return ""
+	}
+	return e.Ownership.Owner
+}
+
+// CredentialHeaders returns the headers carrying the credentials of the
+// requests of the endpoint, the header of its JWT policy and the signature
+// header of its request verification.
+func (e Endpoint) CredentialHeaders() []string {
+	var headers []string
+	if e.JWT.Enabled() && len(e.JWT.Header) > 0 {
+		headers = append(headers, e.JWT.Header)
+	}
+	if e.Verification.Enabled() {
+		headers = append(headers, e.Verification.SignatureHeader())
+	}
+	return headers
 }
 
 func (e Endpoint) HasActiveDeploy() bool {
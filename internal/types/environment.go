@@ -0,0 +1,189 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProjectEnvironment holds the default environment of the endpoints of a
+// project, the owner of the endpoints. The endpoints inherit the variables
+// they do not set themselves when they are instantiated.
+type ProjectEnvironment struct {
+	Project     string            `json:"project"`
+	Environment map[string]string `json:"environment"`
+	// Data keys the environment is encrypted with, wrapped by the master
+	// key. Never exposed through the API.
+	DataKeys  []DataKey `json:"-"`
+	UpdatedAT time.Time `json:"updated_at"`
+}
+
+// NewProjectEnvironment returns the default environment of the project.
+func NewProjectEnvironment(project string, env map[string]string) *ProjectEnvironment {
+	return &ProjectEnvironment{
+		Project:     project,
+		Environment: env,
+		UpdatedAT:   time.Now(),
+	}
+}
+
+// InheritEnvironment returns the environment of an endpoint with the
+// variables of the project defaults it does not set itself. The given maps
+// are never modified.
+func InheritEnvironment(defaults, env map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return env
+	}
+	inherited := make(map[string]string, len(defaults)+len(env))
+	for k, v := range defaults {
+		inherited[k] = v
+	}
+	for k, v := range env {
+		inherited[k] = v
+	}
+	return inherited
+}
+
+// EnvironmentSnapshot is the environment of an endpoint captured when a
+// deployment was published.
+type EnvironmentSnapshot struct {
+	DeploymentID uuid.UUID         `json:"deployment_id"`
+	Environment  map[string]string `json:"environment"`
+	CreatedAT    time.Time         `json:"created_at"`
+}
+
+// NewEnvironmentSnapshot captures a copy of the given environment for the
+// deployment that is published.
+func NewEnvironmentSnapshot(deploymentID uuid.UUID, env map[string]string) *EnvironmentSnapshot {
+	snapshot := &EnvironmentSnapshot{
+		DeploymentID: deploymentID,
+		Environment:  make(map[string]string, len(env)),
+		CreatedAT:    time.Now(),
+	}
+	for k, v := range env {
+		snapshot.Environment[k] = v
+	}
+	return snapshot
+}
+
+// EnvironmentDrift is the difference between the environment captured at
+// the last publish and the current environment of an endpoint. Only the
+// names of the variables are reported, never their values.
+type EnvironmentDrift struct {
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	PublishedAT  time.Time `json:"published_at"`
+	Added        []string  `json:"added"`
+	Removed      []string  `json:"removed"`
+	Changed      []string  `json:"changed"`
+	// True when the next instantiation of the deployment runs with another
+	// environment than the one it was published with.
+	Drifted bool `json:"drifted"`
+}
+
+// NewEnvironmentDrift compares the current environment with the environment
+// captured at publish. The names are sorted.
+func NewEnvironmentDrift(published *EnvironmentSnapshot, current map[string]string) EnvironmentDrift {
+	drift := EnvironmentDrift{
+		DeploymentID: published.DeploymentID,
+		PublishedAT:  published.CreatedAT,
+		Added:        []string{},
+		Removed:      []string{},
+		Changed:      []string{},
+	}
+	for k, v := range current {
+		old, ok := published.Environment[k]
+		if !ok {
+			drift.Added = append(drift.Added, k)
+		} else if old != v {
+			drift.Changed = append(drift.Changed, k)
+		}
+	}
+	for k := range published.Environment {
+		if _, ok := current[k]; !ok {
+			drift.Removed = append(drift.Removed, k)
+		}
+	}
+	sort.Strings(drift.Added)
+	sort.Strings(drift.Removed)
+	sort.Strings(drift.Changed)
+	drift.Drifted = len(drift.Added) > 0 || len(drift.Removed) > 0 || len(drift.Changed) > 0
+	return drift
+}
+
+// EnvironmentSchema declares the environment variables an endpoint requires
+// to be published.
+type EnvironmentSchema struct {
+	Variables []EnvironmentVariable `json:"variables"`
+}
+
+// EnvironmentVariable is a required environment variable. The value must
+// match the pattern when one is given.
+type EnvironmentVariable struct {
+	Name string `json:"name"`
+	// Regular expression the whole value must match, optional.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Validate returns an error if a variable is unnamed, declared twice or has
+// an invalid pattern.
+func (s *EnvironmentSchema) Validate() error {
+	names := make(map[string]bool, len(s.Variables))
+	for _, v := range s.Variables {
+		if len(v.Name) == 0 {
+			return fmt.Errorf("environment variable name cannot be empty")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("duplicate environment variable: %s", v.Name)
+		}
+		names[v.Name] = true
+		if _, err := v.regexp(); err != nil {
+			return fmt.Errorf("invalid pattern for environment variable %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// Check returns an error naming the required variables that are missing
+// from the environment and the ones that do not match their pattern. The
+// values are never part of the error.
+func (s *EnvironmentSchema) Check(env map[string]string) error {
+	if s == nil {
+		return nil
+	}
+	var missing, invalid []string
+	for _, v := range s.Variables {
+		value, ok := env[v.Name]
+		if !ok {
+			missing = append(missing, v.Name)
+			continue
+		}
+		re, err := v.regexp()
+		if err != nil || (re != nil && !re.MatchString(value)) {
+			invalid = append(invalid, fmt.Sprintf("%s (expected %s)", v.Name, v.Pattern))
+		}
+	}
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing required environment variables: "+strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "environment variables do not match their pattern: "+strings.Join(invalid, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// regexp compiles the pattern anchored to the whole value, nil is returned
+// when the variable has no pattern.
+func (v EnvironmentVariable) regexp() (*regexp.Regexp, error) {
+	if len(v.Pattern) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + v.Pattern + ")$")
+}
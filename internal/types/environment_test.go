@@ -0,0 +1,63 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentDrift(t *testing.T) {
+	env := map[string]string{"FOO": "bar", "TOKEN": "secret", "OLD": "1"}
+	published := NewEnvironmentSnapshot(uuid.New(), env)
+	// The snapshot does not change along with the environment.
+	env["FOO"] = "baz"
+	delete(env, "OLD")
+	env["NEW"] = "1"
+	env["ADDED"] = "1"
+
+	drift := NewEnvironmentDrift(published, env)
+	require.True(t, drift.Drifted)
+	require.Equal(t, published.DeploymentID, drift.DeploymentID)
+	require.Equal(t, []string{"ADDED", "NEW"}, drift.Added)
+	require.Equal(t, []string{"OLD"}, drift.Removed)
+	require.Equal(t, []string{"FOO"}, drift.Changed)
+
+	drift = NewEnvironmentDrift(published, published.Environment)
+	require.False(t, drift.Drifted)
+	require.Empty(t, drift.Added)
+	require.Empty(t, drift.Removed)
+	require.Empty(t, drift.Changed)
+}
+
+func TestInheritEnvironment(t *testing.T) {
+	env := map[string]string{"LOG_LEVEL": "debug"}
+	defaults := map[string]string{"DATABASE_URL": "postgres://db", "LOG_LEVEL": "info"}
+	// The variables of the endpoint override the project defaults.
+	inherited := InheritEnvironment(defaults, env)
+	require.Equal(t, map[string]string{"DATABASE_URL": "postgres://db", "LOG_LEVEL": "debug"}, inherited)
+	require.Equal(t, map[string]string{"LOG_LEVEL": "debug"}, env)
+	require.Equal(t, map[string]string{"DATABASE_URL": "postgres://db", "LOG_LEVEL": "info"}, defaults)
+	require.Equal(t, env, InheritEnvironment(nil, env))
+}
+
+func TestEnvironmentSchema(t *testing.T) {
+	require.NotNil(t, (&EnvironmentSchema{Variables: []EnvironmentVariable{{}}}).Validate())
+	require.NotNil(t, (&EnvironmentSchema{Variables: []EnvironmentVariable{{Name: "A"}, {Name: "A"}}}).Validate())
+
+	schema := &EnvironmentSchema{
+		Variables: []EnvironmentVariable{
+			{Name: "PORT", Pattern: "[0-9]+"},
+			{Name: "TOKEN"},
+		},
+	}
+	require.Nil(t, schema.Validate())
+	require.Nil(t, schema.Check(map[string]string{"PORT": "8080", "TOKEN": ""}))
+	// The pattern must match the whole value.
+	err := schema.Check(map[string]string{"PORT": "80a"})
+	require.NotNil(t, err)
+	require.Equal(t, "missing required environment variables: TOKEN; environment variables do not match their pattern: PORT (expected [0-9]+)", err.Error())
+
+	var none *EnvironmentSchema
+	require.Nil(t, none.Check(nil))
+}
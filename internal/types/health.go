@@ -0,0 +1,84 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Health status of a deployment.
+const (
+	// The deployment does not export a health function or was not checked.
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthCheck holds the outcome of a single call of the health function of
+// a deployment.
+type HealthCheck struct {
+	ID           uuid.UUID     `json:"id"`
+	EndpointID   uuid.UUID     `json:"endpoint_id"`
+	DeploymentID uuid.UUID     `json:"deployment_id"`
+	Healthy      bool          `json:"healthy"`
+	Duration     time.Duration `json:"duration"`
+	Error        string        `json:"error,omitempty"`
+	CreatedAT    time.Time     `json:"created_at"`
+}
+
+// NewHealthCheck returns the health check of the deployment with the given
+// outcome.
+func NewHealthCheck(endpointID, deployID uuid.UUID, start time.Time, err error) *HealthCheck {
+	check := &HealthCheck{
+		ID:           uuid.New(),
+		EndpointID:   endpointID,
+		DeploymentID: deployID,
+		Healthy:      err == nil,
+		Duration:     time.Since(start),
+		CreatedAT:    start,
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// DeploymentHealth is the health of a deployment derived from its checks.
+type DeploymentHealth struct {
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	Status       string    `json:"status"`
+	// Amount of consecutive failed checks up to the last check.
+	Failures  int          `json:"failures"`
+	LastCheck *HealthCheck `json:"last_check,omitempty"`
+}
+
+// NewDeploymentHealth derives the health of the deployment from its checks,
+// oldest first. The deployment is unhealthy once the amount of consecutive
+// failed checks reaches the threshold.
+func NewDeploymentHealth(deployID uuid.UUID, checks []HealthCheck, threshold int) DeploymentHealth {
+	health := DeploymentHealth{
+		DeploymentID: deployID,
+		Status:       HealthUnknown,
+	}
+	if len(checks) == 0 {
+		return health
+	}
+	last := checks[len(checks)-1]
+	health.LastCheck = &last
+	for i := len(checks) - 1; i >= 0 && !checks[i].Healthy; i-- {
+		health.Failures++
+	}
+	switch {
+	case health.Failures == 0:
+		health.Status = HealthHealthy
+	case health.Failures >= max(threshold, 1):
+		health.Status = HealthUnhealthy
+	default:
+		// Failures below the threshold do not change the status.
+		health.Status = HealthHealthy
+		if health.Failures == len(checks) {
+			health.Status = HealthUnknown
+		}
+	}
+	return health
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeploymentHealth(t *testing.T) {
+	id := uuid.New()
+	checks := func(healthy ...bool) []HealthCheck {
+		var checks []HealthCheck
+		for _, h := range healthy {
+			checks = append(checks, HealthCheck{DeploymentID: id, Healthy: h})
+		}
+		return checks
+	}
+	cases := []struct {
+		checks   []HealthCheck
+		status   string
+		failures int
+	}{
+		{checks: nil, status: HealthUnknown},
+		{checks: checks(true), status: HealthHealthy},
+		{checks: checks(false), status: HealthUnknown, failures: 1},
+		{checks: checks(true, false), status: HealthHealthy, failures: 1},
+		{checks: checks(true, false, false), status: HealthUnhealthy, failures: 2},
+		{checks: checks(false, false, true), status: HealthHealthy},
+	}
+	for _, c := range cases {
+		health := NewDeploymentHealth(id, c.checks, 2)
+		require.Equal(t, c.status, health.Status)
+		require.Equal(t, c.failures, health.Failures)
+	}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeploymentEventPublish  = "publish"
+	DeploymentEventRollback = "rollback"
+	// The current owner requested the transfer of the endpoint.
+	DeploymentEventTransferRequest = "transfer_request"
+	// The receiving owner accepted the transfer of the endpoint.
+	DeploymentEventTransferAccept = "transfer_accept"
+	// A rollout shifted more traffic to the new deployment.
+	DeploymentEventRolloutStep = "rollout_step"
+	// A rollout shifted all the traffic back to the previous deployment.
+	DeploymentEventRolloutRollback = "rollout_rollback"
+)
+
+// DeploymentEvent records a change of the LIVE deployment or of the owner of
+// an endpoint. The events of an endpoint form its append-only history.
+type DeploymentEvent struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// Kind of the change, publish, rollback or one of the transfer or
+	// rollout steps.
+	Kind         string    `json:"kind"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	// Deployment that was LIVE before the change, zero when there was none.
+	PreviousDeploymentID uuid.UUID `json:"previous_deployment_id"`
+	// Who made the change, as reported by the client.
+	Actor string `json:"actor"`
+	// Owners of the endpoint before and after a transfer.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Percentage of the traffic served by the deployment of a rollout step.
+	Weight int `json:"weight,omitempty"`
+	// Why a rollout was rolled back.
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// NewDeploymentEvent returns a new event that records the given deployment
+// replacing the LIVE deployment of the endpoint.
+func NewDeploymentEvent(kind string, endpoint *Endpoint, deploymentID uuid.UUID, actor string) *DeploymentEvent {
+	return &DeploymentEvent{
+		ID:                   uuid.New(),
+		EndpointID:           endpoint.ID,
+		Kind:                 kind,
+		DeploymentID:         deploymentID,
+		PreviousDeploymentID: endpoint.ActiveDeploymentID,
+		Actor:                actor,
+		CreatedAT:            time.Now(),
+	}
+}
+
+// NewTransferEvent returns a new event that records a step of the transfer
+// of the endpoint from one owner to another.
+func NewTransferEvent(kind string, endpoint *Endpoint, from, to, actor string) *DeploymentEvent {
+	return &DeploymentEvent{
+		ID:                   uuid.New(),
+		EndpointID:           endpoint.ID,
+		Kind:                 kind,
+		DeploymentID:         endpoint.ActiveDeploymentID,
+		PreviousDeploymentID: endpoint.ActiveDeploymentID,
+		Actor:                actor,
+		From:                 from,
+		To:                   to,
+		CreatedAT:            time.Now(),
+	}
+}
+
+// PreviousDeployment returns the deployment that was LIVE before the
+// current one given the history of an endpoint, oldest event first. False
+// is returned when the history does not record one. The events of the
+// transfers are skipped. Rolling back twice
+// returns to the deployment of the first rollback.
+func PreviousDeployment(history []DeploymentEvent) (uuid.UUID, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		event := history[i]
+		if event.Kind != DeploymentEventPublish && event.Kind != DeploymentEventRollback {
+			continue
+		}
+		return event.PreviousDeploymentID, event.PreviousDeploymentID != uuid.Nil
+	}
+	return uuid.Nil, false
+}
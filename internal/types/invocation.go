@@ -0,0 +1,147 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of the invocation errors.
+const (
+	// The guest trapped, like on an unreachable instruction or an out of
+	// bounds memory access.
+	ErrorKindTrap = "trap"
+	// The guest exited with a non zero exit code, like a Go guest that
+	// panicked.
+	ErrorKindExit = "exit"
+	// The module of the deployment failed to compile or to instantiate.
+	ErrorKindInstantiate = "instantiate"
+)
+
+// signatureFrames is the amount of innermost stack frames that go into the
+// signature of an error.
+const signatureFrames = 5
+
+// InvocationError records a LIVE invocation of a deployment that failed in
+// the guest. Errors with the same signature are grouped.
+type InvocationError struct {
+	ID           uuid.UUID `json:"id"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	Kind         string    `json:"kind"`
+	// Why the invocation failed, like the trap or the panic of the guest.
+	Reason   string `json:"reason"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	// The wasm stack trace of a trap, innermost frame first.
+	Stack []string `json:"stack,omitempty"`
+	// The last lines the guest wrote to stderr.
+	Stderr    []string  `json:"stderr,omitempty"`
+	Signature string    `json:"signature"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// ErrorSignature returns the signature of the errors of the given kind,
+// reason and stack. Errors with the same signature are the same bug, the
+// numbers of the reason, like indexes and addresses, are ignored.
+func ErrorSignature(kind, reason string, stack []string) string {
+	h := sha256.New()
+	h.Write([]byte(kind + "\n" + withoutNumbers(reason) + "\n"))
+	for i, frame := range stack {
+		if i == signatureFrames {
+			break
+		}
+		h.Write([]byte(frame + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+func withoutNumbers(s string) string {
+	var b strings.Builder
+	digits := false
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			if !digits {
+				b.WriteByte('N')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ErrorGroup aggregates the invocation errors with the same signature.
+type ErrorGroup struct {
+	Signature string `json:"signature"`
+	Kind      string `json:"kind"`
+	Reason    string `json:"reason"`
+	Count     int    `json:"count"`
+	// The deployments the error occurred in.
+	Deployments []uuid.UUID `json:"deployments"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+	// The most recent error of the group.
+	Latest InvocationError `json:"latest"`
+}
+
+// GroupErrors groups the errors created since the given time by their
+// signature, the most frequent group first.
+func GroupErrors(errs []InvocationError, since time.Time) []ErrorGroup {
+	groups := make(map[string]*ErrorGroup)
+	for _, e := range errs {
+		if e.CreatedAT.Before(since) {
+			continue
+		}
+		group, ok := groups[e.Signature]
+		if !ok {
+			group = &ErrorGroup{
+				Signature: e.Signature,
+				Kind:      e.Kind,
+				Reason:    e.Reason,
+				FirstSeen: e.CreatedAT,
+				Latest:    e,
+			}
+			groups[e.Signature] = group
+		}
+		group.Count++
+		if e.CreatedAT.Before(group.FirstSeen) {
+			group.FirstSeen = e.CreatedAT
+		}
+		if !e.CreatedAT.Before(group.Latest.CreatedAT) {
+			group.Latest = e
+		}
+		group.LastSeen = group.Latest.CreatedAT
+		if !containsUUID(group.Deployments, e.DeploymentID) {
+			group.Deployments = append(group.Deployments, e.DeploymentID)
+		}
+	}
+	result := make([]ErrorGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return strings.Compare(result[i].Signature, result[j].Signature) < 0
+	})
+	return result
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorSignature(t *testing.T) {
+	stack := []string{"main.handler()", "main.main()"}
+	require.Equal(t, ErrorSignature(ErrorKindTrap, "unreachable", stack), ErrorSignature(ErrorKindTrap, "unreachable", stack))
+	require.NotEqual(t, ErrorSignature(ErrorKindTrap, "unreachable", stack), ErrorSignature(ErrorKindTrap, "unreachable", stack[1:]))
+	require.NotEqual(t, ErrorSignature(ErrorKindTrap, "unreachable", nil), ErrorSignature(ErrorKindExit, "unreachable", nil))
+	require.Equal(t,
+		ErrorSignature(ErrorKindExit, "panic: runtime error: index out of range [5] with length 3", nil),
+		ErrorSignature(ErrorKindExit, "panic: runtime error: index out of range [12] with length 10", nil))
+	// Only the innermost frames are part of the signature.
+	deep := []string{"a", "b", "c", "d", "e"}
+	require.Equal(t, ErrorSignature(ErrorKindTrap, "unreachable", deep), ErrorSignature(ErrorKindTrap, "unreachable", append(deep, "f")))
+}
+
+func TestGroupErrors(t *testing.T) {
+	var (
+		now     = time.Now()
+		deploy1 = uuid.New()
+		deploy2 = uuid.New()
+	)
+	errs := []InvocationError{
+		{DeploymentID: deploy1, Kind: ErrorKindExit, Reason: "panic: boom", Signature: "a", CreatedAT: now.Add(-time.Hour * 2)},
+		{DeploymentID: deploy1, Kind: ErrorKindExit, Reason: "panic: boom", Signature: "a", CreatedAT: now.Add(-time.Minute * 2)},
+		{DeploymentID: deploy2, Kind: ErrorKindExit, Reason: "panic: boom", Signature: "a", CreatedAT: now.Add(-time.Minute)},
+		{DeploymentID: deploy2, Kind: ErrorKindTrap, Reason: "unreachable", Signature: "b", CreatedAT: now},
+	}
+	groups := GroupErrors(errs, now.Add(-time.Hour))
+	require.Len(t, groups, 2)
+	require.Equal(t, "a", groups[0].Signature)
+	require.Equal(t, 2, groups[0].Count)
+	require.Equal(t, []uuid.UUID{deploy1, deploy2}, groups[0].Deployments)
+	require.Equal(t, now.Add(-time.Minute*2), groups[0].FirstSeen)
+	require.Equal(t, now.Add(-time.Minute), groups[0].LastSeen)
+	require.Equal(t, deploy2, groups[0].Latest.DeploymentID)
+	require.Equal(t, "b", groups[1].Signature)
+	require.Equal(t, 1, groups[1].Count)
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// JWTPolicy makes the ingress validate the JSON Web Token of the requests of
+// an endpoint against the keys of its issuer, so the functions do not have
+// to. A policy without a JWKS URL is disabled.
+type JWTPolicy struct {
+	// The iss claim the tokens need to have.
+	Issuer string `json:"issuer"`
+	// The tokens need to be issued for one of the audiences, when set.
+	Audiences []string `json:"audiences,omitempty"`
+	// URL of the JSON Web Key Set of the issuer.
+	JWKSURL string `json:"jwks_url"`
+	// Header carrying the token, the bearer token of the Authorization
+	// header by default.
+	Header string `json:"header,omitempty"`
+}
+
+// Enabled returns true if the tokens of the requests need to be validated.
+func (p *JWTPolicy) Enabled() bool {
+	return p != nil && len(p.JWKSURL) > 0
+}
+
+// Validate returns an error if the policy is malformed.
+func (p *JWTPolicy) Validate() error {
+	if len(p.JWKSURL) == 0 {
+		return nil
+	}
+	u, err := url.Parse(p.JWKSURL)
+	if err != nil || len(u.Host) == 0 {
+		return fmt.Errorf("invalid jwks url %q", p.JWKSURL)
+	}
+	// The keys are only fetched in plaintext from the machine itself, for
+	// local issuers.
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+		return fmt.Errorf("jwks url must be an https url")
+	}
+	if len(p.Issuer) == 0 {
+		return fmt.Errorf("jwt policy requires an issuer")
+	}
+	if len(p.Header) > 0 && !headerNamePattern.MatchString(p.Header) {
+		return fmt.Errorf("invalid jwt header %q", p.Header)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,23 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTPolicyValidate(t *testing.T) {
+	require.False(t, (&JWTPolicy{Issuer: "https://issuer.example.com"}).Enabled())
+	require.Nil(t, (&JWTPolicy{}).Validate())
+
+	p := &JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: "https://issuer.example.com/.well-known/jwks.json"}
+	require.True(t, p.Enabled())
+	require.Nil(t, p.Validate())
+	require.Nil(t, (&JWTPolicy{Issuer: "dev", JWKSURL: "http://localhost:8080/jwks"}).Validate())
+	require.Nil(t, (&JWTPolicy{Issuer: "dev", JWKSURL: "http://127.0.0.1:8080/jwks"}).Validate())
+
+	require.NotNil(t, (&JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: "http://issuer.example.com/jwks"}).Validate())
+	require.NotNil(t, (&JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: "issuer.example.com/jwks"}).Validate())
+	require.NotNil(t, (&JWTPolicy{JWKSURL: "https://issuer.example.com/jwks"}).Validate())
+	require.NotNil(t, (&JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: "https://issuer.example.com/jwks", Header: "X Token"}).Validate())
+}
@@ -0,0 +1,57 @@
+package types
+
+import "fmt"
+
+// Limits lower the resource limits of the installation for the invocations
+// of an endpoint. Zero values fall back to the limits of the installation.
+type Limits struct {
+	// Maximum wall time of an invocation in milliseconds.
+	Timeout int `json:"timeout,omitempty"`
+	// Maximum size in bytes of the output of an invocation.
+	MaxResponseSize int `json:"max_response_size,omitempty"`
+	// Maximum size in bytes of the entries of the endpoint in the guest
+	// cache of a member.
+	CacheQuota int `json:"cache_quota,omitempty"`
+}
+
+// Validate returns an error if one of the limits is negative or exceeds the
+// given limits of the installation.
+func (l *Limits) Validate(global Limits) error {
+	if l.Timeout < 0 || l.MaxResponseSize < 0 || l.CacheQuota < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if global.Timeout > 0 && l.Timeout > global.Timeout {
+		return fmt.Errorf("timeout cannot exceed the limit of the installation of %dms", global.Timeout)
+	}
+	if global.MaxResponseSize > 0 && l.MaxResponseSize > global.MaxResponseSize {
+		return fmt.Errorf("max response size cannot exceed the limit of the installation of %d bytes", global.MaxResponseSize)
+	}
+	if global.CacheQuota > 0 && l.CacheQuota > global.CacheQuota {
+		return fmt.Errorf("cache quota cannot exceed the limit of the installation of %d bytes", global.CacheQuota)
+	}
+	return nil
+}
+
+// Bound returns the limits of the invocations of the endpoint given the
+// limits of the installation.
+func (l *Limits) Bound(global Limits) Limits {
+	if l == nil {
+		return global
+	}
+	return Limits{
+		Timeout:         lower(l.Timeout, global.Timeout),
+		MaxResponseSize: lower(l.MaxResponseSize, global.MaxResponseSize),
+		CacheQuota:      lower(l.CacheQuota, global.CacheQuota),
+	}
+}
+
+// lower returns the lowest of the two limits, 0 means unlimited.
+func lower(a, b int) int {
+	if a == 0 {
+		return b
+	}
+	if b == 0 || a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package types
+
+import "testing"
+
+func TestLimitsValidate(t *testing.T) {
+	global := Limits{Timeout: 1000, MaxResponseSize: 1024, CacheQuota: 4096}
+	cases := []struct {
+		limits Limits
+		valid  bool
+	}{
+		{limits: Limits{}, valid: true},
+		{limits: Limits{Timeout: 500, MaxResponseSize: 1024}, valid: true},
+		{limits: Limits{Timeout: 1001}},
+		{limits: Limits{MaxResponseSize: 2048}},
+		{limits: Limits{Timeout: -1}},
+		{limits: Limits{CacheQuota: 4096}, valid: true},
+		{limits: Limits{CacheQuota: 4097}},
+		{limits: Limits{CacheQuota: -1}},
+	}
+	for _, c := range cases {
+		if err := c.limits.Validate(global); (err == nil) != c.valid {
+			t.Errorf("%+v: expected valid %t got %v", c.limits, c.valid, err)
+		}
+	}
+	// An installation without limits accepts any limit.
+	if err := (&Limits{Timeout: 1 << 30}).Validate(Limits{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLimitsBound(t *testing.T) {
+	global := Limits{Timeout: 1000}
+	var none *Limits
+	if got := none.Bound(global); got != global {
+		t.Errorf("expected the global limits got %+v", got)
+	}
+	got := (&Limits{Timeout: 2000, MaxResponseSize: 512}).Bound(global)
+	if got != (Limits{Timeout: 1000, MaxResponseSize: 512}) {
+		t.Errorf("unexpected limits %+v", got)
+	}
+	got = (&Limits{Timeout: 200}).Bound(global)
+	if got.Timeout != 200 {
+		t.Errorf("expected timeout 200 got %d", got.Timeout)
+	}
+}
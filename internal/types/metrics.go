@@ -1,6 +1,8 @@
 package types
 
 import (
+	"math"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,16 +16,195 @@ type RuntimeMetric struct {
 // RequestMetric holds information about a single HTTP request
 // invoked by the runtime.
 type RequestMetric struct {
-	ID           uuid.UUID     `json:"id"`
-	EndpointID   uuid.UUID     `json:"endpoint_id"`
+	ID           uuid.UUID `json:"id"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	RequestURL   string    `json:"request_url"`
+	Protocol     string    `json:"protocol,omitempty"`
+	// The route prefix of a composite endpoint the request was routed by.
+	Route      string        `json:"route,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	StatusCode int           `json:"status_code"`
+	// The time the guest spent computing.
+	ComputeDuration time.Duration `json:"compute_duration"`
+	// The time the guest spent blocked, waiting on external I/O or sleeping.
+	WaitDuration time.Duration `json:"wait_duration"`
+	// The peak memory usage of the guest in bytes.
+	MemoryUsage uint32 `json:"memory_usage"`
+	// The size of the request and the response bodies in bytes.
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// CompileMetric holds information about the compilation of a deployment
+// during the cold start of a runtime.
+type CompileMetric struct {
 	DeploymentID uuid.UUID     `json:"deployment_id"`
-	RequestURL   string        `json:"request_url"`
 	Duration     time.Duration `json:"duration"`
-	StatusCode   int           `json:"status_code"`
+	// Coalesced is true when the compilation was shared with another
+	// concurrent cold start of the same deployment.
+	Coalesced bool `json:"coalesced"`
+}
+
+// QueueMetric holds the amount of requests of an endpoint waiting for an
+// available runtime.
+type QueueMetric struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Depth      int       `json:"depth"`
+}
+
+// CacheMetric holds the amount of cache hits and misses of the responses of
+// an endpoint at an ingress since the previous metric.
+type CacheMetric struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Hits       int       `json:"hits"`
+	Misses     int       `json:"misses"`
+	CreatedAT  time.Time `json:"created_at"`
+}
+
+// CacheStats aggregates the cache metrics of an endpoint.
+type CacheStats struct {
+	Hits     int     `json:"hits"`
+	Misses   int     `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// AggregateCacheMetrics sums the given cache metrics.
+func AggregateCacheMetrics(metrics []CacheMetric) CacheStats {
+	var stats CacheStats
+	for _, metric := range metrics {
+		stats.Hits += metric.Hits
+		stats.Misses += metric.Misses
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// ConcurrencyMetric holds the concurrent invocations of an endpoint at an
+// ingress, sampled periodically. Limit is the concurrency limit of the
+// endpoint, 0 is unlimited.
+type ConcurrencyMetric struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Member     string    `json:"member"`
+	// Invocations dispatched to a runtime that did not respond yet.
+	Inflight int `json:"inflight"`
+	// Requests waiting for a runtime of the endpoint.
+	Queued    int       `json:"queued"`
+	Limit     int       `json:"limit"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// ConcurrencyStats aggregates the concurrency metrics of an endpoint over
+// the ingresses. The current values are the sums of the latest samples of the
+// ingresses that are not stale, the peaks are those of the sums over the
+// window.
+type ConcurrencyStats struct {
+	Since        time.Time `json:"since"`
+	Inflight     int       `json:"inflight"`
+	Queued       int       `json:"queued"`
+	PeakInflight int       `json:"peak_inflight"`
+	PeakQueued   int       `json:"peak_queued"`
+	// The in-flight and queued invocations relative to the concurrency
+	// limits of the ingresses, 0 without a limit. Above 1 the demand
+	// exceeds the capacity.
+	Utilization float64 `json:"utilization"`
+	// The latest sample of every ingress that is not stale.
+	Members []ConcurrencyMetric `json:"members"`
+}
+
+// AggregateConcurrency aggregates the concurrency metrics created since the
+// given time, oldest first. The samples of an ingress are stale once it did
+// not report for longer than stale.
+func AggregateConcurrency(metrics []ConcurrencyMetric, since, now time.Time, stale time.Duration) ConcurrencyStats {
+	stats := ConcurrencyStats{Since: since, Members: []ConcurrencyMetric{}}
+	latest := make(map[string]ConcurrencyMetric)
+	for _, metric := range metrics {
+		if metric.CreatedAT.Before(since) {
+			continue
+		}
+		// The samples of the ingresses are not aligned, the sums at every
+		// sample hold the latest sample of every ingress that is not stale.
+		latest[metric.Member] = metric
+		var inflight, queued int
+		for member, m := range latest {
+			if metric.CreatedAT.Sub(m.CreatedAT) > stale {
+				delete(latest, member)
+				continue
+			}
+			inflight += m.Inflight
+			queued += m.Queued
+		}
+		stats.PeakInflight = max(stats.PeakInflight, inflight)
+		stats.PeakQueued = max(stats.PeakQueued, queued)
+	}
+	var capacity int
+	for _, m := range latest {
+		if now.Sub(m.CreatedAT) > stale {
+			continue
+		}
+		stats.Inflight += m.Inflight
+		stats.Queued += m.Queued
+		capacity += m.Limit
+		stats.Members = append(stats.Members, m)
+	}
+	sort.Slice(stats.Members, func(i, j int) bool { return stats.Members[i].Member < stats.Members[j].Member })
+	if capacity > 0 {
+		stats.Utilization = float64(stats.Inflight+stats.Queued) / float64(capacity)
+	}
+	return stats
+}
+
+// RequestSummary summarizes the requests served by an endpoint in a window.
+type RequestSummary struct {
+	Since    time.Time `json:"since"`
+	Requests int       `json:"requests"`
+	// Requests answered with a 5xx status code.
+	Errors      int           `json:"errors"`
+	ErrorRate   float64       `json:"error_rate"`
+	P50Duration time.Duration `json:"p50_duration"`
+	P95Duration time.Duration `json:"p95_duration"`
+}
+
+// SummarizeRequests summarizes the request metrics created since the given
+// time.
+func SummarizeRequests(metrics []RequestMetric, since time.Time) RequestSummary {
+	summary := RequestSummary{Since: since}
+	durations := make([]time.Duration, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric.CreatedAT.Before(since) {
+			continue
+		}
+		summary.Requests++
+		if metric.StatusCode >= 500 {
+			summary.Errors++
+		}
+		durations = append(durations, metric.Duration)
+	}
+	if summary.Requests == 0 {
+		return summary
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	// Nearest rank percentiles.
+	rank := func(p float64) time.Duration {
+		return durations[int(math.Ceil(float64(len(durations))*p))-1]
+	}
+	summary.ErrorRate = float64(summary.Errors) / float64(summary.Requests)
+	summary.P50Duration = rank(0.50)
+	summary.P95Duration = rank(0.95)
+	return summary
 }
 
 // RuntimeLogEvent holds the logs that where written out
 // during runtime invocation of a script.
 type RuntimeLogEvent struct {
-	Data []byte
+	EndpointID   uuid.UUID
+	DeploymentID uuid.UUID
+	RequestID    string
+	Data         []byte
+	CreatedAT    time.Time
 }
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeRequests(t *testing.T) {
+	var (
+		now     = time.Now()
+		metrics []RequestMetric
+	)
+	for i := 1; i <= 20; i++ {
+		status := 200
+		if i%10 == 0 {
+			status = 500
+		}
+		metrics = append(metrics, RequestMetric{
+			Duration:   time.Duration(i) * time.Millisecond,
+			StatusCode: status,
+			CreatedAT:  now,
+		})
+	}
+	// Left out, it was served before the window.
+	metrics = append(metrics, RequestMetric{Duration: time.Hour, StatusCode: 500, CreatedAT: now.Add(-time.Hour * 2)})
+
+	summary := SummarizeRequests(metrics, now.Add(-time.Hour))
+	require.Equal(t, 20, summary.Requests)
+	require.Equal(t, 2, summary.Errors)
+	require.Equal(t, 0.1, summary.ErrorRate)
+	require.Equal(t, 10*time.Millisecond, summary.P50Duration)
+	require.Equal(t, 19*time.Millisecond, summary.P95Duration)
+
+	summary = SummarizeRequests(nil, now)
+	require.Equal(t, 0, summary.Requests)
+	require.Equal(t, time.Duration(0), summary.P95Duration)
+}
+
+func TestAggregateConcurrency(t *testing.T) {
+	var (
+		now      = time.Now()
+		endpoint = uuid.New()
+		stale    = 15 * time.Second
+	)
+	sample := func(member string, ago time.Duration, inflight, queued int) ConcurrencyMetric {
+		return ConcurrencyMetric{
+			EndpointID: endpoint,
+			Member:     member,
+			Inflight:   inflight,
+			Queued:     queued,
+			Limit:      4,
+			CreatedAT:  now.Add(-ago),
+		}
+	}
+	metrics := []ConcurrencyMetric{
+		// Left out, it was sampled before the window.
+		sample("a", 2*time.Hour, 100, 100),
+		sample("a", 50*time.Second, 2, 0),
+		sample("b", 45*time.Second, 4, 3),
+		sample("a", 40*time.Second, 4, 1),
+		// The ingress c went away.
+		sample("c", 30*time.Second, 1, 0),
+		sample("a", 10*time.Second, 3, 0),
+		sample("b", 5*time.Second, 1, 0),
+	}
+	stats := AggregateConcurrency(metrics, now.Add(-time.Hour), now, stale)
+	require.Equal(t, 4, stats.Inflight)
+	require.Equal(t, 0, stats.Queued)
+	require.Equal(t, 9, stats.PeakInflight)
+	require.Equal(t, 4, stats.PeakQueued)
+	require.Equal(t, 0.5, stats.Utilization)
+	require.Len(t, stats.Members, 2)
+	require.Equal(t, "a", stats.Members[0].Member)
+
+	stats = AggregateConcurrency(nil, now, now, stale)
+	require.Equal(t, 0, stats.PeakInflight)
+	require.Empty(t, stats.Members)
+}
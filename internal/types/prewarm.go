@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxPrewarmInstances is the maximum amount of runtimes an endpoint can keep
+// warm on every ingress.
+const MaxPrewarmInstances = 64
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Prewarm keeps runtimes of the LIVE deployment of an endpoint instantiated
+// ahead of its requests, so they do not pay the cold starts. The ingress
+// keeps the most instances any of the settings asks for at the time warm.
+type Prewarm struct {
+	// Runtimes kept warm at all times.
+	MinInstances int `json:"min_instances,omitempty"`
+	// Windows more runtimes are kept warm in, ahead of known traffic spikes.
+	Schedules []PrewarmSchedule `json:"schedules,omitempty"`
+}
+
+// PrewarmSchedule is a daily window in which the runtimes are kept warm,
+// like 08:45 to 10:00 on weekdays. A window that ends before it starts runs
+// past midnight into the next day.
+type PrewarmSchedule struct {
+	// Days of the week the window starts on ("mon" to "sun"), every day
+	// when empty.
+	Days []string `json:"days,omitempty"`
+	// Start and end of the window as "15:04".
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// IANA time zone of the window, UTC when empty.
+	TimeZone  string `json:"timezone,omitempty"`
+	Instances int    `json:"instances"`
+}
+
+// Instances returns the amount of runtimes to keep warm at the given time.
+func (p *Prewarm) Instances(now time.Time) int {
+	if p == nil {
+		return 0
+	}
+	n := p.MinInstances
+	for _, s := range p.Schedules {
+		if s.Instances > n && s.Active(now) {
+			n = s.Instances
+		}
+	}
+	return n
+}
+
+// Validate returns an error if the prewarm settings are malformed.
+func (p *Prewarm) Validate() error {
+	if p.MinInstances < 0 || p.MinInstances > MaxPrewarmInstances {
+		return fmt.Errorf("prewarm min instances needs to be between 0 and %d", MaxPrewarmInstances)
+	}
+	for _, s := range p.Schedules {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Active returns true if the given time falls in the window.
+func (s PrewarmSchedule) Active(now time.Time) bool {
+	loc, err := s.location()
+	if err != nil {
+		return false
+	}
+	start, err := minuteOfDay(s.Start)
+	if err != nil {
+		return false
+	}
+	end, err := minuteOfDay(s.End)
+	if err != nil {
+		return false
+	}
+	now = now.In(loc)
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end && s.onDay(now.Weekday())
+	}
+	// The window runs past midnight, the early minutes belong to the window
+	// that started the day before.
+	if minute >= start {
+		return s.onDay(now.Weekday())
+	}
+	return minute < end && s.onDay((now.Weekday()+6)%7)
+}
+
+// Validate returns an error if the window is malformed.
+func (s PrewarmSchedule) Validate() error {
+	if s.Instances <= 0 || s.Instances > MaxPrewarmInstances {
+		return fmt.Errorf("prewarm schedule instances needs to be between 1 and %d", MaxPrewarmInstances)
+	}
+	for _, day := range s.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid prewarm schedule day %q, expected mon to sun", day)
+		}
+	}
+	start, err := minuteOfDay(s.Start)
+	if err != nil {
+		return err
+	}
+	end, err := minuteOfDay(s.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("prewarm schedule cannot start and end at %s", s.Start)
+	}
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("invalid prewarm schedule time zone %q", s.TimeZone)
+	}
+	return nil
+}
+
+func (s PrewarmSchedule) onDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (s PrewarmSchedule) location() (*time.Location, error) {
+	if len(s.TimeZone) == 0 {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.TimeZone)
+}
+
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid prewarm schedule time %q, expected 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
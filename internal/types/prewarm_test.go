@@ -0,0 +1,58 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrewarmInstances(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.Nil(t, err)
+	p := &Prewarm{
+		MinInstances: 1,
+		Schedules: []PrewarmSchedule{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:45", End: "10:00", TimeZone: "Europe/Berlin", Instances: 5},
+			// Nightly batch, from friday to saturday morning.
+			{Days: []string{"fri"}, Start: "23:00", End: "02:00", Instances: 3},
+		},
+	}
+	require.Nil(t, p.Validate())
+
+	// Monday the 6th of January 2025.
+	monday := time.Date(2025, time.January, 6, 0, 0, 0, 0, berlin)
+	require.Equal(t, 1, p.Instances(monday.Add(8*time.Hour+44*time.Minute)))
+	require.Equal(t, 5, p.Instances(monday.Add(8*time.Hour+45*time.Minute)))
+	require.Equal(t, 5, p.Instances(monday.Add(9*time.Hour+59*time.Minute)))
+	require.Equal(t, 1, p.Instances(monday.Add(10*time.Hour)))
+	// Saturday morning.
+	require.Equal(t, 1, p.Instances(monday.Add(5*24*time.Hour+9*time.Hour)))
+
+	friday := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, 1, p.Instances(friday.Add(22*time.Hour)))
+	require.Equal(t, 3, p.Instances(friday.Add(23*time.Hour+30*time.Minute)))
+	require.Equal(t, 3, p.Instances(friday.Add(25*time.Hour)))
+	require.Equal(t, 1, p.Instances(friday.Add(26*time.Hour)))
+	// The window does not start on thursdays.
+	require.Equal(t, 1, p.Instances(friday.Add(time.Hour)))
+
+	require.Equal(t, 0, (*Prewarm)(nil).Instances(monday))
+}
+
+func TestPrewarmValidate(t *testing.T) {
+	valid := PrewarmSchedule{Start: "09:00", End: "10:00", Instances: 2}
+	require.Nil(t, (&Prewarm{Schedules: []PrewarmSchedule{valid}}).Validate())
+
+	for _, p := range []*Prewarm{
+		{MinInstances: -1},
+		{MinInstances: MaxPrewarmInstances + 1},
+		{Schedules: []PrewarmSchedule{{Start: "09:00", End: "10:00"}}},
+		{Schedules: []PrewarmSchedule{{Start: "9am", End: "10:00", Instances: 2}}},
+		{Schedules: []PrewarmSchedule{{Start: "09:00", End: "09:00", Instances: 2}}},
+		{Schedules: []PrewarmSchedule{{Days: []string{"monday"}, Start: "09:00", End: "10:00", Instances: 2}}},
+		{Schedules: []PrewarmSchedule{{Start: "09:00", End: "10:00", TimeZone: "Mars/Olympus", Instances: 2}}},
+	} {
+		require.NotNil(t, p.Validate())
+	}
+}
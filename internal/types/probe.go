@@ -0,0 +1,73 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MinProbeInterval is the minimum amount of seconds between two checks of
+// the same probe.
+const MinProbeInterval = 10
+
+// Probe is a synthetic check that is periodically executed against the LIVE
+// deployment of an endpoint from every region.
+type Probe struct {
+	// Path of the request, relative to the endpoint.
+	Path string `json:"path"`
+	// Interval in seconds between two checks.
+	Interval       int `json:"interval"`
+	ExpectedStatus int `json:"expected_status"`
+	// Optional substring the response body needs to contain.
+	ExpectedBody string `json:"expected_body,omitempty"`
+}
+
+// ProbeResult holds the outcome of a single check of a probe.
+type ProbeResult struct {
+	ID         uuid.UUID     `json:"id"`
+	EndpointID uuid.UUID     `json:"endpoint_id"`
+	Path       string        `json:"path"`
+	Region     string        `json:"region"`
+	Success    bool          `json:"success"`
+	StatusCode int           `json:"status_code"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+	CreatedAT  time.Time     `json:"created_at"`
+}
+
+// ProbeAvailability holds the availability of a probe in a region.
+type ProbeAvailability struct {
+	Path         string  `json:"path"`
+	Region       string  `json:"region"`
+	Checks       int     `json:"checks"`
+	Failures     int     `json:"failures"`
+	Availability float64 `json:"availability"`
+}
+
+// Availability aggregates the given results per probe and region.
+func Availability(results []ProbeResult) []ProbeAvailability {
+	var (
+		index = make(map[[2]string]int)
+		avail []ProbeAvailability
+	)
+	for _, result := range results {
+		key := [2]string{result.Path, result.Region}
+		i, ok := index[key]
+		if !ok {
+			i = len(avail)
+			index[key] = i
+			avail = append(avail, ProbeAvailability{
+				Path:   result.Path,
+				Region: result.Region,
+			})
+		}
+		avail[i].Checks++
+		if !result.Success {
+			avail[i].Failures++
+		}
+	}
+	for i := range avail {
+		avail[i].Availability = float64(avail[i].Checks-avail[i].Failures) / float64(avail[i].Checks)
+	}
+	return avail
+}
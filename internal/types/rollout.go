@@ -0,0 +1,186 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status of a rollout.
+const (
+	RolloutRunning    = "running"
+	RolloutCompleted  = "completed"
+	RolloutRolledBack = "rolled_back"
+)
+
+// Defaults of a rollout policy.
+var (
+	DefaultRolloutSteps = []int{10, 25, 50, 100}
+	// Milliseconds every step of the rollout lasts.
+	DefaultRolloutStepInterval = 60_000
+	// Rate of 5xx responses of the new deployment a rollout is rolled back
+	// at.
+	DefaultRolloutMaxErrorRate = 0.05
+)
+
+// RolloutPolicy describes how the LIVE traffic of an endpoint is shifted to
+// a new deployment and when the shift is rolled back. The zero values fall
+// back to the defaults.
+type RolloutPolicy struct {
+	// Percentages of the traffic the new deployment serves at every step,
+	// ascending and ending at 100.
+	Steps []int `json:"steps,omitempty"`
+	// Milliseconds every step lasts before the thresholds are checked.
+	StepInterval int `json:"step_interval,omitempty"`
+	// Rate of 5xx responses of the new deployment, between 0 and 1, that
+	// rolls the rollout back.
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"`
+	// p95 latency in milliseconds of the new deployment that rolls the
+	// rollout back, 0 disables the check.
+	MaxP95Duration int `json:"max_p95_duration,omitempty"`
+}
+
+// Validate returns an error if the policy is malformed.
+func (p *RolloutPolicy) Validate() error {
+	for i, step := range p.Steps {
+		if step <= 0 || step > 100 {
+			return fmt.Errorf("rollout steps need to be between 1 and 100")
+		}
+		if i > 0 && step <= p.Steps[i-1] {
+			return fmt.Errorf("rollout steps need to be ascending")
+		}
+	}
+	if len(p.Steps) > 0 && p.Steps[len(p.Steps)-1] != 100 {
+		return fmt.Errorf("the last rollout step needs to be 100")
+	}
+	if p.StepInterval < 0 {
+		return fmt.Errorf("rollout step interval cannot be negative")
+	}
+	if p.MaxErrorRate < 0 || p.MaxErrorRate > 1 {
+		return fmt.Errorf("rollout max error rate needs to be between 0 and 1")
+	}
+	if p.MaxP95Duration < 0 {
+		return fmt.Errorf("rollout max p95 duration cannot be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the policy with the defaults of the unset fields.
+func (p RolloutPolicy) withDefaults() RolloutPolicy {
+	if len(p.Steps) == 0 {
+		p.Steps = DefaultRolloutSteps
+	}
+	if p.StepInterval == 0 {
+		p.StepInterval = DefaultRolloutStepInterval
+	}
+	if p.MaxErrorRate == 0 {
+		p.MaxErrorRate = DefaultRolloutMaxErrorRate
+	}
+	return p
+}
+
+// Check returns an error if the requests served by the new deployment
+// breach one of the thresholds of the policy.
+func (p RolloutPolicy) Check(summary RequestSummary) error {
+	if summary.Requests == 0 {
+		return nil
+	}
+	if summary.ErrorRate > p.MaxErrorRate {
+		return fmt.Errorf("error rate %.1f%% exceeds %.1f%% (%d of %d requests)",
+			summary.ErrorRate*100, p.MaxErrorRate*100, summary.Errors, summary.Requests)
+	}
+	if max := time.Duration(p.MaxP95Duration) * time.Millisecond; max > 0 && summary.P95Duration > max {
+		return fmt.Errorf("p95 latency %s exceeds %s", summary.P95Duration, max)
+	}
+	return nil
+}
+
+// Rollout shifts the LIVE traffic of an endpoint step by step from its
+// active deployment to a new deployment. The new deployment becomes the
+// active deployment once it serves all the traffic without breaching the
+// thresholds of the policy, it is rolled back otherwise.
+type Rollout struct {
+	ID           uuid.UUID `json:"id"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	// Deployment that serves the rest of the traffic, the active deployment
+	// of the endpoint when the rollout started.
+	PreviousDeploymentID uuid.UUID `json:"previous_deployment_id"`
+	RolloutPolicy
+	// Index of the current step.
+	Step   int    `json:"step"`
+	Status string `json:"status"`
+	// Why the rollout was rolled back.
+	Reason string `json:"reason,omitempty"`
+	// Who started the rollout, as reported by the client.
+	Actor     string    `json:"actor"`
+	CreatedAT time.Time `json:"created_at"`
+	// When the current step started, or when the rollout finished.
+	UpdatedAT time.Time `json:"updated_at"`
+}
+
+// NewRollout returns a running rollout of the deployment to the endpoint at
+// its first step.
+func NewRollout(endpoint *Endpoint, deploymentID uuid.UUID, policy RolloutPolicy, actor string) *Rollout {
+	now := time.Now()
+	return &Rollout{
+		ID:                   uuid.New(),
+		EndpointID:           endpoint.ID,
+		DeploymentID:         deploymentID,
+		PreviousDeploymentID: endpoint.ActiveDeploymentID,
+		RolloutPolicy:        policy.withDefaults(),
+		Status:               RolloutRunning,
+		Actor:                actor,
+		CreatedAT:            now,
+		UpdatedAT:            now,
+	}
+}
+
+// Running returns true if the rollout shifts traffic.
+func (r *Rollout) Running() bool {
+	return r != nil && r.Status == RolloutRunning
+}
+
+// Weight returns the percentage of the traffic the new deployment serves.
+func (r *Rollout) Weight() int {
+	if !r.Running() {
+		return 0
+	}
+	return r.Steps[r.Step]
+}
+
+// Pick returns the deployment that serves a request given the active
+// deployment of the endpoint and a random number in [0, 100).
+func (r *Rollout) Pick(activeID uuid.UUID, n int) uuid.UUID {
+	if n < r.Weight() {
+		return r.DeploymentID
+	}
+	return activeID
+}
+
+// LastStep returns true if the new deployment serves all the traffic.
+func (r *Rollout) LastStep() bool {
+	return r.Step == len(r.Steps)-1
+}
+
+// StepEnds returns when the current step ends.
+func (r *Rollout) StepEnds() time.Time {
+	return r.UpdatedAT.Add(time.Duration(r.StepInterval) * time.Millisecond)
+}
+
+// Next returns the rollout at its next step.
+func (r Rollout) Next() *Rollout {
+	r.Step++
+	r.UpdatedAT = time.Now()
+	return &r
+}
+
+// Finish returns the rollout with the given status, the reason is the
+// reason of a rollback.
+func (r Rollout) Finish(status, reason string) *Rollout {
+	r.Status = status
+	r.Reason = reason
+	r.UpdatedAT = time.Now()
+	return &r
+}
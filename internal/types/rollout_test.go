@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutPolicyValidate(t *testing.T) {
+	require.Nil(t, (&RolloutPolicy{}).Validate())
+	require.Nil(t, (&RolloutPolicy{Steps: []int{50, 100}, MaxErrorRate: 0.1}).Validate())
+	require.NotNil(t, (&RolloutPolicy{Steps: []int{0, 100}}).Validate())
+	require.NotNil(t, (&RolloutPolicy{Steps: []int{50, 25, 100}}).Validate())
+	require.NotNil(t, (&RolloutPolicy{Steps: []int{10, 50}}).Validate())
+	require.NotNil(t, (&RolloutPolicy{MaxErrorRate: 2}).Validate())
+	require.NotNil(t, (&RolloutPolicy{StepInterval: -1}).Validate())
+}
+
+func TestRolloutPolicyCheck(t *testing.T) {
+	p := RolloutPolicy{MaxErrorRate: 0.1, MaxP95Duration: 100}
+	require.Nil(t, p.Check(RequestSummary{}))
+	require.Nil(t, p.Check(RequestSummary{Requests: 10, Errors: 1, ErrorRate: 0.1, P95Duration: time.Millisecond * 100}))
+	require.NotNil(t, p.Check(RequestSummary{Requests: 10, Errors: 2, ErrorRate: 0.2}))
+	require.NotNil(t, p.Check(RequestSummary{Requests: 10, P95Duration: time.Millisecond * 101}))
+}
+
+func TestRolloutSteps(t *testing.T) {
+	endpoint := NewEndpoint("my-endpoint", "go", nil)
+	endpoint.ActiveDeploymentID = uuid.New()
+	deployID := uuid.New()
+
+	rollout := NewRollout(endpoint, deployID, RolloutPolicy{Steps: []int{50, 100}}, "alice")
+	require.Equal(t, endpoint.ActiveDeploymentID, rollout.PreviousDeploymentID)
+	require.Equal(t, DefaultRolloutStepInterval, rollout.StepInterval)
+	require.Equal(t, 50, rollout.Weight())
+	require.Equal(t, deployID, rollout.Pick(endpoint.ActiveDeploymentID, 49))
+	require.Equal(t, endpoint.ActiveDeploymentID, rollout.Pick(endpoint.ActiveDeploymentID, 50))
+	require.False(t, rollout.LastStep())
+
+	next := rollout.Next()
+	require.Equal(t, 0, rollout.Step)
+	require.Equal(t, 100, next.Weight())
+	require.True(t, next.LastStep())
+
+	rolledBack := next.Finish(RolloutRolledBack, "error rate")
+	require.False(t, rolledBack.Running())
+	require.Equal(t, endpoint.ActiveDeploymentID, rolledBack.Pick(endpoint.ActiveDeploymentID, 0))
+
+	var none *Rollout
+	require.Equal(t, endpoint.ActiveDeploymentID, none.Pick(endpoint.ActiveDeploymentID, 0))
+}
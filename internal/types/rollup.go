@@ -0,0 +1,143 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The resolutions the request metrics are rolled up into.
+const (
+	RollupMinute = time.Minute
+	RollupHour   = time.Hour
+)
+
+// RollupBuckets are the upper bounds of the duration histogram of a rollup,
+// the durations above the last bound are counted in a last bucket.
+var RollupBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MetricRollup aggregates the request metrics of an endpoint in a period of
+// the resolution, they are kept longer than the request metrics.
+type MetricRollup struct {
+	EndpointID uuid.UUID     `json:"endpoint_id"`
+	Resolution time.Duration `json:"resolution"`
+	// Start of the period (UTC) of the rollup.
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	// Requests answered with a 5xx status code.
+	Errors      int64         `json:"errors"`
+	DurationSum time.Duration `json:"duration_sum"`
+	DurationMax time.Duration `json:"duration_max"`
+	BytesIn     int64         `json:"bytes_in"`
+	BytesOut    int64         `json:"bytes_out"`
+	// Requests per bucket of RollupBuckets.
+	Buckets []int64 `json:"buckets"`
+}
+
+type rollupKey struct {
+	start      time.Time
+	endpointID uuid.UUID
+}
+
+// RollupMetrics rolls the request metrics up into rollups of the resolution
+// per endpoint, ordered by start and endpoint.
+func RollupMetrics(resolution time.Duration, metrics []RequestMetric) []MetricRollup {
+	rollups := make(map[rollupKey]*MetricRollup)
+	for _, metric := range metrics {
+		k := rollupKey{start: metric.CreatedAT.UTC().Truncate(resolution), endpointID: metric.EndpointID}
+		rollup, ok := rollups[k]
+		if !ok {
+			rollup = &MetricRollup{
+				EndpointID: k.endpointID,
+				Resolution: resolution,
+				Start:      k.start,
+				Buckets:    make([]int64, len(RollupBuckets)+1),
+			}
+			rollups[k] = rollup
+		}
+		rollup.Requests++
+		if metric.StatusCode >= 500 {
+			rollup.Errors++
+		}
+		rollup.DurationSum += metric.Duration
+		rollup.DurationMax = max(rollup.DurationMax, metric.Duration)
+		rollup.BytesIn += metric.BytesIn
+		rollup.BytesOut += metric.BytesOut
+		rollup.Buckets[sort.Search(len(RollupBuckets), func(i int) bool { return metric.Duration <= RollupBuckets[i] })]++
+	}
+	return sortRollups(rollups)
+}
+
+// MergeRollups merges rollups of a finer resolution into rollups of the
+// given resolution per endpoint, ordered by start and endpoint.
+func MergeRollups(resolution time.Duration, from []MetricRollup) []MetricRollup {
+	rollups := make(map[rollupKey]*MetricRollup)
+	for _, r := range from {
+		k := rollupKey{start: r.Start.UTC().Truncate(resolution), endpointID: r.EndpointID}
+		rollup, ok := rollups[k]
+		if !ok {
+			rollup = &MetricRollup{
+				EndpointID: k.endpointID,
+				Resolution: resolution,
+				Start:      k.start,
+				Buckets:    make([]int64, len(RollupBuckets)+1),
+			}
+			rollups[k] = rollup
+		}
+		rollup.Requests += r.Requests
+		rollup.Errors += r.Errors
+		rollup.DurationSum += r.DurationSum
+		rollup.DurationMax = max(rollup.DurationMax, r.DurationMax)
+		rollup.BytesIn += r.BytesIn
+		rollup.BytesOut += r.BytesOut
+		for i := 0; i < len(rollup.Buckets) && i < len(r.Buckets); i++ {
+			rollup.Buckets[i] += r.Buckets[i]
+		}
+	}
+	return sortRollups(rollups)
+}
+
+// Percentile returns the upper bound of the bucket of the rollup the given
+// percentile falls in, or the max duration for the last bucket.
+func (r MetricRollup) Percentile(p float64) time.Duration {
+	var (
+		rank = int64(float64(r.Requests)*p + 0.5)
+		seen int64
+	)
+	for i, count := range r.Buckets {
+		seen += count
+		if seen >= rank && i < len(RollupBuckets) {
+			return min(RollupBuckets[i], r.DurationMax)
+		}
+	}
+	return r.DurationMax
+}
+
+// sortRollups returns the rollups ordered by start and endpoint.
+func sortRollups(rollups map[rollupKey]*MetricRollup) []MetricRollup {
+	sorted := make([]MetricRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		sorted = append(sorted, *rollup)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Start.Equal(sorted[j].Start) {
+			return sorted[i].Start.Before(sorted[j].Start)
+		}
+		return bytes.Compare(sorted[i].EndpointID[:], sorted[j].EndpointID[:]) < 0
+	})
+	return sorted
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupMetrics(t *testing.T) {
+	var (
+		endpointID = uuid.New()
+		hour       = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	)
+	metrics := []RequestMetric{
+		{EndpointID: endpointID, Duration: time.Millisecond * 20, StatusCode: 200, BytesIn: 10, BytesOut: 100, CreatedAT: hour.Add(time.Second * 10)},
+		{EndpointID: endpointID, Duration: time.Second * 30, StatusCode: 502, BytesIn: 5, CreatedAT: hour.Add(time.Second * 50)},
+		{EndpointID: endpointID, Duration: time.Millisecond * 3, StatusCode: 200, CreatedAT: hour.Add(time.Minute * 30)},
+	}
+	minutes := RollupMetrics(RollupMinute, metrics)
+	require.Len(t, minutes, 2)
+	first := minutes[0]
+	require.Equal(t, hour, first.Start)
+	require.Equal(t, RollupMinute, first.Resolution)
+	require.Equal(t, int64(2), first.Requests)
+	require.Equal(t, int64(1), first.Errors)
+	require.Equal(t, time.Second*30+time.Millisecond*20, first.DurationSum)
+	require.Equal(t, time.Second*30, first.DurationMax)
+	require.Equal(t, int64(15), first.BytesIn)
+	require.Equal(t, int64(100), first.BytesOut)
+	require.Equal(t, int64(1), first.Buckets[2])
+	require.Equal(t, int64(1), first.Buckets[len(RollupBuckets)])
+	require.Equal(t, hour.Add(time.Minute*30), minutes[1].Start)
+
+	hours := MergeRollups(RollupHour, minutes)
+	require.Len(t, hours, 1)
+	require.Equal(t, hour, hours[0].Start)
+	require.Equal(t, RollupHour, hours[0].Resolution)
+	require.Equal(t, int64(3), hours[0].Requests)
+	require.Equal(t, int64(1), hours[0].Errors)
+	require.Equal(t, time.Second*30, hours[0].DurationMax)
+	require.Equal(t, int64(1), hours[0].Buckets[0])
+
+}
+
+func TestMetricRollupPercentile(t *testing.T) {
+	rollup := RollupMetrics(RollupMinute, []RequestMetric{
+		{Duration: time.Millisecond * 4},
+		{Duration: time.Millisecond * 40},
+		{Duration: time.Millisecond * 45},
+		{Duration: time.Second * 12},
+	})[0]
+	require.Equal(t, time.Millisecond*50, rollup.Percentile(0.5))
+	require.Equal(t, time.Second*12, rollup.Percentile(0.99))
+	require.Equal(t, time.Duration(0), MetricRollup{}.Percentile(0.5))
+}
@@ -0,0 +1,118 @@
+package types
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetricsSnapshotVersion is the version of the format of the metrics
+// snapshot, bumped on every incompatible change.
+const MetricsSnapshotVersion = 1
+
+// MetricsSnapshot holds the full metrics state of an endpoint over a time
+// window. It is a self-contained artifact that can be exported to review
+// incidents offline after the metrics have been removed by retention.
+type MetricsSnapshot struct {
+	Version    int       `json:"version"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	CreatedAT  time.Time `json:"created_at"`
+	// The configuration of the endpoint at the moment of the snapshot.
+	Endpoint       *Endpoint       `json:"endpoint"`
+	Summary        SnapshotSummary `json:"summary"`
+	RequestMetrics []RequestMetric `json:"request_metrics"`
+	ProbeResults   []ProbeResult   `json:"probe_results"`
+}
+
+// SnapshotSummary aggregates the request metrics and the probe results of a
+// metrics snapshot.
+type SnapshotSummary struct {
+	Requests int `json:"requests"`
+	// Requests answered with a 5xx status code.
+	Errors       int                 `json:"errors"`
+	P50Duration  time.Duration       `json:"p50_duration"`
+	P99Duration  time.Duration       `json:"p99_duration"`
+	MaxDuration  time.Duration       `json:"max_duration"`
+	Deployments  []uuid.UUID         `json:"deployments"`
+	Protocols    map[string]int      `json:"protocols"`
+	Availability []ProbeAvailability `json:"availability"`
+}
+
+// NewMetricsSnapshot returns a snapshot of the given metrics of the endpoint
+// created within the window [from, to).
+func NewMetricsSnapshot(endpoint *Endpoint, from, to time.Time, requests []RequestMetric, probes []ProbeResult) *MetricsSnapshot {
+	snapshot := &MetricsSnapshot{
+		Version:        MetricsSnapshotVersion,
+		EndpointID:     endpoint.ID,
+		From:           from,
+		To:             to,
+		CreatedAT:      time.Now(),
+		Endpoint:       endpoint,
+		RequestMetrics: []RequestMetric{},
+		ProbeResults:   []ProbeResult{},
+	}
+	for _, metric := range requests {
+		if inWindow(metric.CreatedAT, from, to) {
+			snapshot.RequestMetrics = append(snapshot.RequestMetrics, metric)
+		}
+	}
+	for _, result := range probes {
+		if inWindow(result.CreatedAT, from, to) {
+			snapshot.ProbeResults = append(snapshot.ProbeResults, result)
+		}
+	}
+	snapshot.Summary = summarize(snapshot.RequestMetrics, snapshot.ProbeResults)
+	return snapshot
+}
+
+func inWindow(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+func summarize(requests []RequestMetric, probes []ProbeResult) SnapshotSummary {
+	summary := SnapshotSummary{
+		Requests:     len(requests),
+		Deployments:  []uuid.UUID{},
+		Protocols:    map[string]int{},
+		Availability: Availability(probes),
+	}
+	if len(requests) == 0 {
+		return summary
+	}
+	var (
+		durations   = make([]time.Duration, len(requests))
+		deployments = make(map[uuid.UUID]bool)
+	)
+	for i, metric := range requests {
+		durations[i] = metric.Duration
+		if metric.StatusCode >= 500 {
+			summary.Errors++
+		}
+		if len(metric.Protocol) > 0 {
+			summary.Protocols[metric.Protocol]++
+		}
+		if !deployments[metric.DeploymentID] {
+			deployments[metric.DeploymentID] = true
+			summary.Deployments = append(summary.Deployments, metric.DeploymentID)
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	summary.P50Duration = durations[nearestRank(len(durations), 0.5)]
+	summary.P99Duration = durations[nearestRank(len(durations), 0.99)]
+	summary.MaxDuration = durations[len(durations)-1]
+	return summary
+}
+
+// nearestRank returns the index of the given percentile in a sorted slice of
+// length n.
+func nearestRank(n int, p float64) int {
+	i := int(math.Ceil(float64(n)*p)) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
@@ -0,0 +1,199 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status of the platform or of a region, from best to worst.
+const (
+	StatusOperational = "operational"
+	StatusDegraded    = "degraded"
+	StatusOutage      = "outage"
+)
+
+// Severity of an incident.
+const (
+	IncidentMinor    = "minor"
+	IncidentMajor    = "major"
+	IncidentCritical = "critical"
+)
+
+// Limits of an incident.
+const (
+	maxIncidentTitleLength   = 200
+	maxIncidentMessageLength = 4096
+)
+
+// Incident is a banner shown on the platform status page, set by the
+// operators while the platform is impaired.
+type Incident struct {
+	ID       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message,omitempty"`
+	Severity string    `json:"severity"`
+	// Regions affected by the incident, empty when all the regions are.
+	Regions    []string   `json:"regions,omitempty"`
+	CreatedAT  time.Time  `json:"created_at"`
+	ResolvedAT *time.Time `json:"resolved_at,omitempty"`
+}
+
+// NewIncident returns a new unresolved incident.
+func NewIncident(title, message, severity string, regions []string) *Incident {
+	return &Incident{
+		ID:        uuid.New(),
+		Title:     title,
+		Message:   message,
+		Severity:  severity,
+		Regions:   regions,
+		CreatedAT: time.Now(),
+	}
+}
+
+// Validate returns an error if the incident is malformed.
+func (i *Incident) Validate() error {
+	if len(i.Title) == 0 {
+		return fmt.Errorf("incident title cannot be empty")
+	}
+	if len(i.Title) > maxIncidentTitleLength {
+		return fmt.Errorf("incident title exceeds the maximum of %d characters", maxIncidentTitleLength)
+	}
+	if len(i.Message) > maxIncidentMessageLength {
+		return fmt.Errorf("incident message exceeds the maximum of %d characters", maxIncidentMessageLength)
+	}
+	switch i.Severity {
+	case IncidentMinor, IncidentMajor, IncidentCritical:
+	default:
+		return fmt.Errorf("invalid incident severity %q, expected minor, major or critical", i.Severity)
+	}
+	return nil
+}
+
+// Active returns true if the incident is not resolved.
+func (i *Incident) Active() bool {
+	return i.ResolvedAT == nil
+}
+
+// status returns the status of the affected regions while the incident is
+// active.
+func (i *Incident) status() string {
+	switch {
+	case !i.Active():
+		return StatusOperational
+	case i.Severity == IncidentCritical:
+		return StatusOutage
+	case i.Severity == IncidentMajor:
+		return StatusDegraded
+	}
+	return StatusOperational
+}
+
+// affects returns true if the incident affects the region.
+func (i *Incident) affects(region string) bool {
+	if len(i.Regions) == 0 {
+		return true
+	}
+	for _, r := range i.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestStats holds the amount of requests served by all the endpoints in
+// a window and how many of them failed.
+type RequestStats struct {
+	Start    time.Time `json:"start"`
+	Requests int       `json:"requests"`
+	// Requests answered with a 5xx status code.
+	Errors int `json:"errors"`
+}
+
+// ErrorRate returns the fraction of the requests that failed.
+func (s RequestStats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// IngressStatus is the health of an ingress as reported by its admin
+// server.
+type IngressStatus struct {
+	Addr     string `json:"addr"`
+	Healthy  bool   `json:"healthy"`
+	Draining bool   `json:"draining,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RegionStatus is the health of the ingresses of a region.
+type RegionStatus struct {
+	Region    string          `json:"region"`
+	Status    string          `json:"status"`
+	Ingresses []IngressStatus `json:"ingresses"`
+}
+
+// PlatformStatus summarizes the health of the platform for its users.
+type PlatformStatus struct {
+	Status  string         `json:"status"`
+	Regions []RegionStatus `json:"regions"`
+	// Active incidents and the incidents resolved within the window.
+	Incidents []Incident `json:"incidents"`
+	// Request stats of all the endpoints, oldest window first.
+	Requests  []RequestStats `json:"requests"`
+	UpdatedAT time.Time      `json:"updated_at"`
+}
+
+// NewRegionStatus derives the status of a region from its ingresses, the
+// region is degraded when some and down when all of them are unhealthy.
+func NewRegionStatus(region string, ingresses []IngressStatus) RegionStatus {
+	var healthy int
+	for _, ingress := range ingresses {
+		if ingress.Healthy {
+			healthy++
+		}
+	}
+	status := StatusOperational
+	switch {
+	case healthy == 0:
+		status = StatusOutage
+	case healthy < len(ingresses):
+		status = StatusDegraded
+	}
+	return RegionStatus{Region: region, Status: status, Ingresses: ingresses}
+}
+
+// ApplyIncidents lowers the status of the regions and of the platform to
+// the status of the active incidents affecting them.
+func (s *PlatformStatus) ApplyIncidents() {
+	status := StatusOperational
+	for i := range s.Regions {
+		region := &s.Regions[i]
+		for _, incident := range s.Incidents {
+			if incident.affects(region.Region) {
+				region.Status = worstStatus(region.Status, incident.status())
+			}
+		}
+		status = worstStatus(status, region.Status)
+	}
+	for _, incident := range s.Incidents {
+		status = worstStatus(status, incident.status())
+	}
+	s.Status = status
+}
+
+var statusRank = map[string]int{
+	StatusOperational: 0,
+	StatusDegraded:    1,
+	StatusOutage:      2,
+}
+
+func worstStatus(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegionStatus(t *testing.T) {
+	healthy := IngressStatus{Addr: "a", Healthy: true}
+	down := IngressStatus{Addr: "b"}
+	require.Equal(t, StatusOperational, NewRegionStatus("eu", []IngressStatus{healthy, healthy}).Status)
+	require.Equal(t, StatusDegraded, NewRegionStatus("eu", []IngressStatus{healthy, down}).Status)
+	require.Equal(t, StatusOutage, NewRegionStatus("eu", []IngressStatus{down}).Status)
+}
+
+func TestApplyIncidents(t *testing.T) {
+	resolved := time.Now()
+	status := PlatformStatus{
+		Regions: []RegionStatus{
+			{Region: "eu", Status: StatusOperational},
+			{Region: "us", Status: StatusOperational},
+		},
+		Incidents: []Incident{
+			{Severity: IncidentMajor, Regions: []string{"eu"}},
+			{Severity: IncidentCritical, ResolvedAT: &resolved},
+			{Severity: IncidentMinor},
+		},
+	}
+	status.ApplyIncidents()
+	require.Equal(t, StatusDegraded, status.Status)
+	require.Equal(t, StatusDegraded, status.Regions[0].Status)
+	require.Equal(t, StatusOperational, status.Regions[1].Status)
+
+	status.Incidents = append(status.Incidents, Incident{Severity: IncidentCritical})
+	status.ApplyIncidents()
+	require.Equal(t, StatusOutage, status.Status)
+	require.Equal(t, StatusOutage, status.Regions[1].Status)
+}
+
+func TestIncidentValidate(t *testing.T) {
+	require.Nil(t, NewIncident("Elevated latency", "", IncidentMinor, nil).Validate())
+	require.NotNil(t, NewIncident("", "", IncidentMinor, nil).Validate())
+	require.NotNil(t, NewIncident("Down", "", "catastrophic", nil).Validate())
+}
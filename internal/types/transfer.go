@@ -0,0 +1,65 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxOwnerLength = 100
+
+// Ownership holds the owner of an endpoint, a team or a project, and the
+// transfer of the endpoint to another owner that awaits its confirmation.
+type Ownership struct {
+	Owner string `json:"owner,omitempty"`
+	// Transfer requested by the current owner, nil when there is none.
+	Transfer *Transfer `json:"transfer,omitempty"`
+}
+
+// Transfer moves an endpoint, with its deployments and metrics, to another
+// owner once the receiving side accepts it.
+type Transfer struct {
+	To string `json:"to"`
+	// Who requested the transfer, as reported by the client.
+	RequestedBy string    `json:"requested_by"`
+	RequestedAT time.Time `json:"requested_at"`
+}
+
+// ValidateOwner returns an error if the owner is not a valid owner name.
+func ValidateOwner(owner string) error {
+	if len(owner) == 0 {
+		return fmt.Errorf("owner cannot be empty")
+	}
+	if len(owner) > maxOwnerLength {
+		return fmt.Errorf("owner exceeds the maximum of %d characters", maxOwnerLength)
+	}
+	return nil
+}
+
+// RequestTransfer returns the ownership with a pending transfer to the
+// given owner, replacing any pending transfer.
+func (o Ownership) RequestTransfer(to, actor string) (Ownership, error) {
+	if err := ValidateOwner(to); err != nil {
+		return o, err
+	}
+	if to == o.Owner {
+		return o, fmt.Errorf("endpoint is already owned by %q", to)
+	}
+	o.Transfer = &Transfer{
+		To:          to,
+		RequestedBy: actor,
+		RequestedAT: time.Now(),
+	}
+	return o, nil
+}
+
+// AcceptTransfer returns the ownership of the receiving side of the pending
+// transfer. The caller makes sure the owner is the side accepting it.
+func (o Ownership) AcceptTransfer(owner string) (Ownership, error) {
+	if o.Transfer == nil {
+		return o, fmt.Errorf("endpoint does not have a pending transfer")
+	}
+	if o.Transfer.To != owner {
+		return o, fmt.Errorf("pending transfer is addressed to %q, not %q", o.Transfer.To, owner)
+	}
+	return Ownership{Owner: owner}, nil
+}
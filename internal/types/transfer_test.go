@@ -0,0 +1,27 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipTransfer(t *testing.T) {
+	ownership := Ownership{Owner: "payments"}
+
+	_, err := ownership.RequestTransfer("payments", "alice")
+	require.NotNil(t, err)
+	_, err = ownership.AcceptTransfer("billing")
+	require.NotNil(t, err)
+
+	ownership, err = ownership.RequestTransfer("billing", "alice")
+	require.Nil(t, err)
+	require.Equal(t, "payments", ownership.Owner)
+	require.Equal(t, "alice", ownership.Transfer.RequestedBy)
+
+	_, err = ownership.AcceptTransfer("payments")
+	require.NotNil(t, err)
+	ownership, err = ownership.AcceptTransfer("billing")
+	require.Nil(t, err)
+	require.Equal(t, Ownership{Owner: "billing"}, ownership)
+}
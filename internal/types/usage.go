@@ -0,0 +1,99 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageRecord counts the invocations of an endpoint in a billing period.
+type UsageRecord struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// Start of the calendar month (UTC) of the record, see UsagePeriod.
+	Period      time.Time `json:"period"`
+	Invocations int64     `json:"invocations"`
+	GBSeconds   float64   `json:"gb_seconds"`
+}
+
+// NewUsageRecord returns the usage of the invocation of the request metric.
+func NewUsageRecord(metric RequestMetric) UsageRecord {
+	return UsageRecord{
+		EndpointID:  metric.EndpointID,
+		Period:      UsagePeriod(metric.CreatedAT),
+		Invocations: 1,
+		GBSeconds:   GBSeconds(metric.MemoryUsage, metric.Duration),
+	}
+}
+
+// Usage is the consumption of a project in a billing period.
+type Usage struct {
+	Project     string    `json:"project"`
+	Period      time.Time `json:"period"`
+	Endpoints   int       `json:"endpoints"`
+	Deployments int       `json:"deployments"`
+	Invocations int64     `json:"invocations"`
+	GBSeconds   float64   `json:"gb_seconds"`
+}
+
+// UsagePeriod returns the billing period of the given time, the start of its
+// calendar month in UTC.
+func UsagePeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GBSeconds returns the execution of an invocation, its peak memory usage in
+// GB times its duration in seconds.
+func GBSeconds(memoryUsage uint32, d time.Duration) float64 {
+	return float64(memoryUsage) / (1 << 30) * d.Seconds()
+}
+
+// HourlyUsage is the usage of an endpoint in an hour, the records the usage
+// export feeds billing systems with.
+type HourlyUsage struct {
+	// Start of the hour (UTC) of the record.
+	Hour        time.Time     `json:"hour"`
+	EndpointID  uuid.UUID     `json:"endpoint_id"`
+	Project     string        `json:"project"`
+	Invocations int64         `json:"invocations"`
+	Duration    time.Duration `json:"duration"`
+	GBSeconds   float64       `json:"gb_seconds"`
+	BytesIn     int64         `json:"bytes_in"`
+	BytesOut    int64         `json:"bytes_out"`
+}
+
+// RollupUsage rolls the request metrics of the endpoints of a project up
+// into hourly usage, ordered by hour and endpoint.
+func RollupUsage(project string, metrics []RequestMetric) []HourlyUsage {
+	type key struct {
+		hour       time.Time
+		endpointID uuid.UUID
+	}
+	rollups := make(map[key]*HourlyUsage)
+	for _, metric := range metrics {
+		k := key{hour: metric.CreatedAT.UTC().Truncate(time.Hour), endpointID: metric.EndpointID}
+		usage, ok := rollups[k]
+		if !ok {
+			usage = &HourlyUsage{Hour: k.hour, EndpointID: k.endpointID, Project: project}
+			rollups[k] = usage
+		}
+		usage.Invocations++
+		usage.Duration += metric.Duration
+		usage.GBSeconds += GBSeconds(metric.MemoryUsage, metric.Duration)
+		usage.BytesIn += metric.BytesIn
+		usage.BytesOut += metric.BytesOut
+	}
+	usages := make([]HourlyUsage, 0, len(rollups))
+	for _, usage := range rollups {
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if !usages[i].Hour.Equal(usages[j].Hour) {
+			return usages[i].Hour.Before(usages[j].Hour)
+		}
+		return bytes.Compare(usages[i].EndpointID[:], usages[j].EndpointID[:]) < 0
+	})
+	return usages
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsagePeriod(t *testing.T) {
+	at := time.Date(2024, time.March, 31, 23, 30, 0, 0, time.FixedZone("", -2*60*60))
+	require.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), UsagePeriod(at))
+}
+
+func TestGBSeconds(t *testing.T) {
+	require.InDelta(t, 0.5, GBSeconds(1<<30, time.Millisecond*500), 0.0001)
+	require.InDelta(t, 0.25, GBSeconds(1<<28, time.Second), 0.0001)
+}
+
+func TestRollupUsage(t *testing.T) {
+	var (
+		endpointID = uuid.New()
+		hour       = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	)
+	metrics := []RequestMetric{
+		{EndpointID: endpointID, Duration: time.Second, MemoryUsage: 1 << 30, BytesIn: 10, BytesOut: 100, CreatedAT: hour.Add(time.Minute * 59)},
+		{EndpointID: endpointID, Duration: time.Second, MemoryUsage: 1 << 29, BytesIn: 5, BytesOut: 50, CreatedAT: hour.Add(time.Minute)},
+		{EndpointID: endpointID, Duration: time.Second, CreatedAT: hour.Add(time.Hour)},
+	}
+	usages := RollupUsage("payments", metrics)
+	require.Len(t, usages, 2)
+	require.Equal(t, HourlyUsage{
+		Hour:        hour,
+		EndpointID:  endpointID,
+		Project:     "payments",
+		Invocations: 2,
+		Duration:    time.Second * 2,
+		GBSeconds:   1.5,
+		BytesIn:     15,
+		BytesOut:    150,
+	}, usages[0])
+	require.Equal(t, hour.Add(time.Hour), usages[1].Hour)
+	require.Equal(t, int64(1), usages[1].Invocations)
+}
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultVerificationHeader is the header holding the signature of the
+// requests, the same header the webhook deliveries are signed with.
+const DefaultVerificationHeader = "Raptor-Signature"
+
+// DefaultVerificationTolerance is the maximum age in seconds of the
+// timestamp of a signed request when no tolerance is configured.
+const DefaultVerificationTolerance = 300
+
+var (
+	ErrMissingSignature = errors.New("request is not signed")
+	ErrInvalidSignature = errors.New("request signature is invalid")
+	ErrExpiredSignature = errors.New("request signature timestamp is outside of the tolerance")
+)
+
+var (
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// RequestVerification makes the ingress verify the HMAC-SHA256 signature
+// of the requests of an endpoint before invoking it, so functions receiving
+// webhooks do not have to. The signature header is "t=<unix seconds>,v1=<hex
+// signature>" over "<unix seconds>.<METHOD>.<path>.<body>", where the path is
+// the path of the endpoint with its query. A verification without a secret is
+// disabled.
+type RequestVerification struct {
+	// Environment variable of the endpoint holding the shared secret, it is
+	// encrypted like the other variables.
+	SecretEnv string `json:"secret_env"`
+	// Header holding the signature, Raptor-Signature by default.
+	Header string `json:"header,omitempty"`
+	// Maximum age in seconds of the timestamp of a request, which guards
+	// against replays. DefaultVerificationTolerance by default.
+	Tolerance int `json:"tolerance,omitempty"`
+}
+
+// Enabled returns true if the requests need to be verified.
+func (v *RequestVerification) Enabled() bool {
+	return v != nil && len(v.SecretEnv) > 0
+}
+
+// Validate returns an error if the verification is malformed.
+func (v *RequestVerification) Validate() error {
+	if len(v.SecretEnv) > 0 && !envNamePattern.MatchString(v.SecretEnv) {
+		return fmt.Errorf("verification secret %q is not a valid environment variable name", v.SecretEnv)
+	}
+	if len(v.Header) > 0 && !headerNamePattern.MatchString(v.Header) {
+		return fmt.Errorf("invalid verification header %q", v.Header)
+	}
+	if v.Tolerance < 0 {
+		return fmt.Errorf("verification tolerance cannot be negative")
+	}
+	return nil
+}
+
+// SignatureHeader returns the header holding the signature.
+func (v *RequestVerification) SignatureHeader() string {
+	if len(v.Header) == 0 {
+		return DefaultVerificationHeader
+	}
+	return v.Header
+}
+
+// Verify checks the signature header of the request at the given time. The
+// header can hold several v1 signatures while the secret is rotated.
+func (v *RequestVerification) Verify(secret, header, method, path string, body []byte, now time.Time) error {
+	if len(header) == 0 {
+		return ErrMissingSignature
+	}
+	var (
+		ts         string
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultVerificationTolerance
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > time.Duration(tolerance)*time.Second || age < -time.Duration(tolerance)*time.Second {
+		return ErrExpiredSignature
+	}
+	expected := requestMAC(secret, ts, method, path, body)
+	for _, signature := range signatures {
+		if b, err := hex.DecodeString(signature); err == nil && hmac.Equal(b, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignRequest returns the signature header of a request sent at the given
+// time, for the senders of the requests.
+func SignRequest(secret string, t time.Time, method, path string, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(requestMAC(secret, ts, method, path, body)))
+}
+
+func requestMAC(secret, ts, method, path string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + strings.ToUpper(method) + "." + path + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
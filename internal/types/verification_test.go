@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestVerification(t *testing.T) {
+	var (
+		v    = &RequestVerification{SecretEnv: "WEBHOOK_SECRET"}
+		now  = time.Unix(1700000000, 0)
+		body = []byte(`{"event":"paid"}`)
+	)
+	require.Nil(t, v.Validate())
+	require.True(t, v.Enabled())
+	require.False(t, (&RequestVerification{}).Enabled())
+	require.Equal(t, DefaultVerificationHeader, v.SignatureHeader())
+
+	header := SignRequest("secret", now, "POST", "/hooks?source=stripe", body)
+	require.Nil(t, v.Verify("secret", header, "post", "/hooks?source=stripe", body, now.Add(time.Minute)))
+	require.Equal(t, ErrMissingSignature, v.Verify("secret", "", "POST", "/hooks?source=stripe", body, now))
+	require.Equal(t, ErrInvalidSignature, v.Verify("other", header, "POST", "/hooks?source=stripe", body, now))
+	require.Equal(t, ErrInvalidSignature, v.Verify("secret", header, "POST", "/hooks", body, now))
+	require.Equal(t, ErrInvalidSignature, v.Verify("secret", header, "POST", "/hooks?source=stripe", []byte("{}"), now))
+	require.Equal(t, ErrInvalidSignature, v.Verify("secret", "v1=abc", "POST", "/hooks?source=stripe", body, now))
+	require.Equal(t, ErrExpiredSignature, v.Verify("secret", header, "POST", "/hooks?source=stripe", body, now.Add(10*time.Minute)))
+
+	// The signature of the new secret is sent next to the old one while the
+	// secret is rotated.
+	rotated := SignRequest("new", now, "POST", "/hooks", body) + ",v1=" + SignRequest("secret", now, "POST", "/hooks", body)[len("t=1700000000,v1="):]
+	require.Nil(t, v.Verify("secret", rotated, "POST", "/hooks", body, now))
+	require.Nil(t, v.Verify("new", rotated, "POST", "/hooks", body, now))
+
+	v.Tolerance = 30
+	require.Equal(t, ErrExpiredSignature, v.Verify("secret", header, "POST", "/hooks?source=stripe", body, now.Add(time.Minute)))
+}
+
+func TestRequestVerificationValidate(t *testing.T) {
+	require.NotNil(t, (&RequestVerification{SecretEnv: "1SECRET"}).Validate())
+	require.NotNil(t, (&RequestVerification{SecretEnv: "SECRET", Header: "X Signature"}).Validate())
+	require.NotNil(t, (&RequestVerification{SecretEnv: "SECRET", Tolerance: -1}).Validate())
+	require.Nil(t, (&RequestVerification{SecretEnv: "SECRET", Header: "X-Hub-Signature", Tolerance: 60}).Validate())
+	require.Nil(t, (&RequestVerification{}).Validate())
+}
@@ -0,0 +1,110 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of the platform events.
+const (
+	EventDeploymentCreated = "deployment.created"
+	EventEndpointPublished = "endpoint.published"
+	// The LIVE traffic of an endpoint was shifted back to a previous
+	// deployment, by a rollback or by a rolled back rollout.
+	EventRollback = "rollback"
+	// An invocation crashed or was answered with a 5xx status code.
+	EventInvocationFailed = "invocation.failed"
+	// An invocation exceeded the time or the response size it is allowed.
+	EventQuotaExceeded = "quota.exceeded"
+)
+
+// EventTypes are all the types of the platform events.
+var EventTypes = []string{
+	EventDeploymentCreated,
+	EventEndpointPublished,
+	EventRollback,
+	EventInvocationFailed,
+	EventQuotaExceeded,
+}
+
+// Event records platform activity of an endpoint, it is delivered to the
+// webhooks of the endpoint subscribed to its type.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// Deployment the event is about, zero when there is none.
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	// Who caused the event, empty for events of the platform itself.
+	Actor     string    `json:"actor,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// NewEvent returns a new event of the given type.
+func NewEvent(eventType string, endpointID, deploymentID uuid.UUID, actor, message string) *Event {
+	return &Event{
+		ID:           uuid.New(),
+		Type:         eventType,
+		EndpointID:   endpointID,
+		DeploymentID: deploymentID,
+		Actor:        actor,
+		Message:      message,
+		CreatedAT:    time.Now(),
+	}
+}
+
+// Webhook is a URL the events of an endpoint are posted to. The deliveries
+// are signed with the secret of the webhook.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	URL        string    `json:"url"`
+	// Key of the HMAC signatures of the deliveries, only returned when the
+	// webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Types of the events delivered to the webhook, all of them when empty.
+	Events    []string  `json:"events,omitempty"`
+	CreatedAT time.Time `json:"created_at"`
+}
+
+// Validate returns an error if the URL of the webhook is not an http(s) URL
+// or if it subscribes to unknown events.
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid webhook url given: %s", w.URL)
+	}
+	for _, event := range w.Events {
+		if !validEventType(event) {
+			return fmt.Errorf("invalid webhook event given: %s", event)
+		}
+	}
+	return nil
+}
+
+// Subscribed returns true if events of the given type are delivered to the
+// webhook.
+func (w *Webhook) Subscribed(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	require.Nil(t, (&Webhook{URL: "https://example.com/hook"}).Validate())
+	require.Nil(t, (&Webhook{URL: "http://example.com", Events: []string{EventRollback}}).Validate())
+	require.NotNil(t, (&Webhook{URL: "ftp://example.com"}).Validate())
+	require.NotNil(t, (&Webhook{URL: "example.com"}).Validate())
+	require.NotNil(t, (&Webhook{URL: "https://example.com", Events: []string{"deployment.deleted"}}).Validate())
+}
+
+func TestWebhookSubscribed(t *testing.T) {
+	require.True(t, (&Webhook{}).Subscribed(EventQuotaExceeded))
+	webhook := &Webhook{Events: []string{EventRollback, EventInvocationFailed}}
+	require.True(t, webhook.Subscribed(EventRollback))
+	require.False(t, webhook.Subscribed(EventDeploymentCreated))
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status of a workflow.
+const (
+	// WorkflowRunning is a workflow with a step queued or running.
+	WorkflowRunning = "running"
+	// WorkflowSleeping is a workflow waiting to be woken up for its next
+	// step.
+	WorkflowSleeping  = "sleeping"
+	WorkflowCompleted = "completed"
+	WorkflowFailed    = "failed"
+)
+
+// Workflow is a long-running process of an endpoint made of steps, the
+// asynchronous invocations of its LIVE deployment. The guest checkpoints the
+// state of the workflow during a step and schedules the next step by going
+// to sleep, the workflow completes with the first step that does not.
+type Workflow struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	Status     string    `json:"status"`
+	// Request every step is invoked with.
+	Request AsyncRequest `json:"request"`
+	// State checkpointed by the guest, opaque to the platform.
+	State []byte `json:"state,omitempty"`
+	// When the workflow is woken up for its next step, set while it is
+	// sleeping.
+	WakeAT *time.Time `json:"wake_at,omitempty"`
+	// Steps invoked so far.
+	Steps int `json:"steps"`
+	// Why the workflow failed.
+	Error     string    `json:"error,omitempty"`
+	CreatedAT time.Time `json:"created_at"`
+	UpdatedAT time.Time `json:"updated_at"`
+}
+
+// NewWorkflow returns a new running workflow of the endpoint.
+func NewWorkflow(endpointID uuid.UUID, req AsyncRequest) *Workflow {
+	now := time.Now()
+	return &Workflow{
+		ID:         uuid.New(),
+		EndpointID: endpointID,
+		Status:     WorkflowRunning,
+		Request:    req,
+		CreatedAT:  now,
+		UpdatedAT:  now,
+	}
+}
+
+// Done returns true if the workflow either completed or failed.
+func (w *Workflow) Done() bool {
+	return w.Status == WorkflowCompleted || w.Status == WorkflowFailed
+}
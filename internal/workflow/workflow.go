@@ -0,0 +1,136 @@
+// Package workflow runs the workflows of the endpoints: it invokes their steps
+// through the asynchronous invoker and wakes up the sleeping workflows once
+// they are due.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// Runner invokes the steps of the workflows. A step is an asynchronous
+// invocation of the LIVE deployment of the endpoint of the workflow, the
+// ingress hands the id of the workflow to the guest in the Raptor-Workflow-Id
+// header. The workflow
+// sleeps once the step is done when the guest scheduled a wake up, and
+// completes otherwise.
+type Runner struct {
+	store    storage.Store
+	invoker  *async.Invoker
+	maxSteps int
+}
+
+// NewRunner returns a new runner invoking the steps with the invoker.
+func NewRunner(store storage.Store, invoker *async.Invoker, c config.Workflows) *Runner {
+	return &Runner{
+		store:    store,
+		invoker:  invoker,
+		maxSteps: c.MaxSteps,
+	}
+}
+
+// Start creates a workflow of the endpoint and invokes its first step with
+// the request.
+func (r *Runner) Start(endpoint *types.Endpoint, req types.AsyncRequest) (*types.Workflow, error) {
+	workflow := types.NewWorkflow(endpoint.ID, req)
+	if err := r.store.CreateWorkflow(workflow); err != nil {
+		return nil, err
+	}
+	started := *workflow
+	go r.step(endpoint, workflow)
+	return &started, nil
+}
+
+// Run wakes up the sleeping workflows that are due once per interval, until
+// the context is done.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.wake(now)
+		}
+	}
+}
+
+func (r *Runner) wake(now time.Time) {
+	workflows, err := r.store.GetDueWorkflows(now)
+	if err != nil {
+		slog.Warn("failed to load the due workflows", "err", err)
+		return
+	}
+	for i := range workflows {
+		workflow := &workflows[i]
+		endpoint, err := r.store.GetEndpoint(workflow.EndpointID)
+		if err != nil {
+			r.fail(workflow, err)
+			continue
+		}
+		// The workflow is marked as running before the step is invoked, so
+		// the next check does not wake it up again.
+		workflow.Status = types.WorkflowRunning
+		workflow.WakeAT = nil
+		workflow.UpdatedAT = time.Now()
+		if err := r.store.UpdateWorkflow(workflow); err != nil {
+			slog.Error("failed to update workflow", "err", err, "id", workflow.ID)
+			continue
+		}
+		go r.step(endpoint, workflow)
+	}
+}
+
+// step invokes the next step of the running workflow and records its
+// outcome.
+func (r *Runner) step(endpoint *types.Endpoint, workflow *types.Workflow) {
+	if r.maxSteps > 0 && workflow.Steps >= r.maxSteps {
+		r.fail(workflow, fmt.Errorf("workflow exceeded the maximum of %d steps", r.maxSteps))
+		return
+	}
+	workflow.Steps++
+	workflow.UpdatedAT = time.Now()
+	if err := r.store.UpdateWorkflow(workflow); err != nil {
+		slog.Error("failed to update workflow", "err", err, "id", workflow.ID)
+		return
+	}
+	_, err := r.invoker.Step(context.Background(), endpoint, workflow.ID, workflow.Request)
+	// The guest checkpointed its state and scheduled its wake up in the
+	// store during the step.
+	current, gerr := r.store.GetWorkflow(workflow.ID)
+	if gerr != nil {
+		slog.Error("failed to load workflow", "err", gerr, "id", workflow.ID)
+		return
+	}
+	switch {
+	case err != nil:
+		r.fail(current, err)
+		return
+	case current.WakeAT != nil:
+		current.Status = types.WorkflowSleeping
+	default:
+		current.Status = types.WorkflowCompleted
+	}
+	current.UpdatedAT = time.Now()
+	if err := r.store.UpdateWorkflow(current); err != nil {
+		slog.Error("failed to update workflow", "err", err, "id", workflow.ID)
+	}
+}
+
+func (r *Runner) fail(workflow *types.Workflow, err error) {
+	workflow.Status = types.WorkflowFailed
+	workflow.Error = err.Error()
+	workflow.WakeAT = nil
+	workflow.UpdatedAT = time.Now()
+	if err := r.store.UpdateWorkflow(workflow); err != nil {
+		slog.Error("failed to update workflow", "err", err, "id", workflow.ID)
+	}
+}
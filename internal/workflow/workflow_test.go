@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// guest stands in for the ingress, it sleeps in the first step of every
+// workflow like a guest calling workflow_sleep.
+func guest(t *testing.T, store storage.Store) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The workflow is only carried by the internal signature.
+		require.Empty(t, r.Header.Get(shared.WorkflowIDHeader))
+		id, _, err := async.VerifyInternal([]byte("secret"), r.Header.Get(async.InternalHeader), r.Method, r.URL.Path, time.Now())
+		require.Nil(t, err)
+		workflow, err := store.GetWorkflow(id)
+		require.Nil(t, err)
+		if workflow.Steps == 1 {
+			wake := time.Now().Add(time.Millisecond * 10)
+			workflow.WakeAT = &wake
+		}
+		workflow.State = []byte{byte(workflow.Steps)}
+		require.Nil(t, store.UpdateWorkflow(workflow))
+	}))
+}
+
+func TestRunner(t *testing.T) {
+	store := storage.NewMemoryStore()
+	ingress := guest(t, store)
+	defer ingress.Close()
+	endpoint := types.NewEndpoint("workflow", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+
+	runner := NewRunner(store, async.New(store, ingress.URL, config.Async{MaxAttempts: 1, Secret: "secret"}), config.Workflows{})
+	// A workflow header of the request does not reach the ingress.
+	workflow, err := runner.Start(endpoint, types.AsyncRequest{
+		Method: "POST",
+		Path:   "/",
+		Header: http.Header{shared.WorkflowIDHeader: {uuid.NewString()}},
+	})
+	require.Nil(t, err)
+	require.Equal(t, types.WorkflowRunning, workflow.Status)
+
+	var current *types.Workflow
+	require.Eventually(t, func() bool {
+		current, err = store.GetWorkflow(workflow.ID)
+		return err == nil && current.Status == types.WorkflowSleeping
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, []byte{1}, current.State)
+
+	// Nothing is due before the wake up.
+	due, err := store.GetDueWorkflows(time.Now().Add(-time.Second))
+	require.Nil(t, err)
+	require.Empty(t, due)
+
+	runner.wake(time.Now().Add(time.Second))
+	require.Eventually(t, func() bool {
+		current, err = store.GetWorkflow(workflow.ID)
+		return err == nil && current.Done()
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, types.WorkflowCompleted, current.Status)
+	require.Equal(t, []byte{2}, current.State)
+	require.Equal(t, 2, current.Steps)
+	require.Nil(t, current.WakeAT)
+}
+
+func TestRunnerMaxSteps(t *testing.T) {
+	store := storage.NewMemoryStore()
+	ingress := guest(t, store)
+	defer ingress.Close()
+	endpoint := types.NewEndpoint("workflow", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+
+	runner := NewRunner(store, async.New(store, ingress.URL, config.Async{MaxAttempts: 1, Secret: "secret"}), config.Workflows{MaxSteps: 1})
+	workflow, err := runner.Start(endpoint, types.AsyncRequest{Method: "POST", Path: "/"})
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		current, err := store.GetWorkflow(workflow.ID)
+		return err == nil && current.Status == types.WorkflowSleeping
+	}, time.Second*5, time.Millisecond*10)
+
+	// The second step exceeds the maximum.
+	runner.wake(time.Now().Add(time.Second))
+	var current *types.Workflow
+	require.Eventually(t, func() bool {
+		current, err = store.GetWorkflow(workflow.ID)
+		return err == nil && current.Done()
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, types.WorkflowFailed, current.Status)
+	require.Equal(t, "workflow exceeded the maximum of 1 steps", current.Error)
+	require.Equal(t, 1, current.Steps)
+}
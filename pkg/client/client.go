@@ -0,0 +1,163 @@
+// Package client is the Go client of the versioned management API of
+// raptor (/v1). Unlike the internal client of the CLI it has its own types,
+// so it can be imported by other modules, like a Terraform provider. The
+// fields of its types are only ever added to.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Error is the error returned by the API.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether the resource of the call does not exist.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether the call conflicts with the state of the
+// resource, like deleting the active deployment of an endpoint.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsPreconditionFailed reports whether the resource was changed since the
+// version the call was based on.
+func IsPreconditionFailed(err error) bool {
+	return hasStatus(err, http.StatusPreconditionFailed)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// namespace of the IDs derived from names.
+var namespace = uuid.MustParse("5f0e5bd8-4d5b-4c2b-9a4e-7e1d6b4a3c21")
+
+// NameID returns the ID derived from the owner and the name of an endpoint.
+// Creating an endpoint with the ID of its name makes the create safe to
+// retry and gives the endpoint the same ID every time it is created again.
+func NameID(owner, name string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(owner+"/"+name))
+}
+
+// Option configures the client.
+type Option func(*Client)
+
+// WithAPIKey authorizes the calls with the given API key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient sends the calls with the given HTTP client instead of the
+// default client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client calls the management API of a raptor installation.
+type Client struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// New returns a client of the API at the given URL, like
+// http://localhost:3000.
+func New(url string, opts ...Option) *Client {
+	c := &Client{
+		url:        strings.TrimSuffix(url, "/") + "/v1",
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// request describes a call of the API.
+type request struct {
+	method string
+	path   string
+	// body is encoded as JSON unless it is a reader.
+	body        any
+	contentType string
+	ifMatch     string
+}
+
+// do sends the request and decodes the JSON response into v, when v is not
+// nil. It returns the headers of the response.
+func (c *Client) do(ctx context.Context, r request, v any) (http.Header, error) {
+	var (
+		body        io.Reader
+		contentType = r.contentType
+	)
+	switch b := r.body.(type) {
+	case nil:
+	case io.Reader:
+		body = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, c.url+r.path, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(r.ifMatch) > 0 {
+		req.Header.Set("If-Match", r.ifMatch)
+	}
+	if len(c.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(b, &errResp); err != nil || len(errResp.Error) == 0 {
+			errResp.Error = strings.TrimSpace(string(b))
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: errResp.Error}
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
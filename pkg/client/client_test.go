@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameID(t *testing.T) {
+	require.Equal(t, NameID("payments", "catfacts"), NameID("payments", "catfacts"))
+	require.NotEqual(t, NameID("payments", "catfacts"), NameID("billing", "catfacts"))
+}
+
+func TestUpdateEndpointIfMatch(t *testing.T) {
+	id := uuid.New()
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		require.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		if r.Method == http.MethodPut {
+			require.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+			w.WriteHeader(http.StatusPreconditionFailed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "endpoint was changed"})
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		json.NewEncoder(w).Encode(map[string]any{"id": id, "name": "catfacts"})
+	}))
+	defer ts.Close()
+	c := New(ts.URL+"/", WithAPIKey("key"))
+
+	_, err := c.UpdateEndpoint(context.Background(), id, UpdateEndpointRequest{Name: "dogfacts"}, `"v1"`)
+	require.True(t, IsPreconditionFailed(err))
+	require.Equal(t, "endpoint was changed", err.(*Error).Message)
+	endpoint, err := c.GetEndpoint(context.Background(), id)
+	require.Nil(t, err)
+	require.Equal(t, `"v2"`, endpoint.ETag)
+	require.Equal(t, []string{"PUT /v1/endpoint/" + id.String(), "GET /v1/endpoint/" + id.String()}, calls)
+}
@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Endpoint is an endpoint and its settings. The values of the environment
+// are only returned to the admin keys, the other keys get them redacted.
+type Endpoint struct {
+	ID                 uuid.UUID         `json:"id"`
+	Name               string            `json:"name"`
+	Runtime            string            `json:"runtime"`
+	ActiveDeploymentID uuid.UUID         `json:"active_deployment_id"`
+	Environment        map[string]string `json:"environment"`
+	Regions            []string          `json:"regions,omitempty"`
+	// Maximum amount of requests served concurrently, 0 means unlimited.
+	MaxConcurrency int        `json:"max_concurrency"`
+	Limits         *Limits    `json:"limits,omitempty"`
+	Ownership      *Ownership `json:"ownership,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	// Version of the endpoint the updates and the deletes can be made
+	// conditional on, empty for the keys that cannot read the secrets.
+	ETag string `json:"-"`
+}
+
+// Owner returns the owner of the endpoint, empty when it has none.
+func (e Endpoint) Owner() string {
+	if e.Ownership == nil {
+		return ""
+	}
+	return e.Ownership.Owner
+}
+
+// Ownership holds the owner of an endpoint, a team or a project.
+type Ownership struct {
+	Owner string `json:"owner,omitempty"`
+}
+
+// Limits are the resource limits of the invocations of an endpoint.
+type Limits struct {
+	// Maximum wall time of an invocation in milliseconds.
+	Timeout int `json:"timeout,omitempty"`
+	// Maximum size in bytes of the output of an invocation.
+	MaxResponseSize int `json:"max_response_size,omitempty"`
+	// Maximum size in bytes of the entries of the endpoint in the guest
+	// cache of a member.
+	CacheQuota int `json:"cache_quota,omitempty"`
+}
+
+// CreateEndpointRequest holds the fields of a new endpoint.
+type CreateEndpointRequest struct {
+	// Optional ID of the endpoint, see NameID. Creating an endpoint with the
+	// ID of an existing endpoint returns it when it has the same name,
+	// runtime and owner, and fails with a conflict otherwise.
+	ID          uuid.UUID         `json:"id"`
+	Name        string            `json:"name"`
+	Runtime     string            `json:"runtime"`
+	Owner       string            `json:"owner,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+// UpdateEndpointRequest holds the settings to change, the nil fields are
+// left as they are. An empty list of regions clears the regions.
+type UpdateEndpointRequest struct {
+	Name           string   `json:"name,omitempty"`
+	Runtime        string   `json:"runtime,omitempty"`
+	Regions        []string `json:"regions"`
+	MaxConcurrency *int     `json:"max_concurrency,omitempty"`
+	Limits         *Limits  `json:"limits,omitempty"`
+}
+
+// CreateEndpoint creates an endpoint.
+func (c *Client) CreateEndpoint(ctx context.Context, req CreateEndpointRequest) (*Endpoint, error) {
+	var endpoint Endpoint
+	header, err := c.do(ctx, request{method: http.MethodPost, path: "/endpoint", body: req}, &endpoint)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.ETag = header.Get("ETag")
+	return &endpoint, nil
+}
+
+// GetEndpoint returns the endpoint with its current ETag.
+func (c *Client) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var endpoint Endpoint
+	header, err := c.do(ctx, request{method: http.MethodGet, path: "/endpoint/" + id.String()}, &endpoint)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.ETag = header.Get("ETag")
+	return &endpoint, nil
+}
+
+// RevealEndpoint returns the endpoint like GetEndpoint, with the values of
+// its environment variables. It requires an admin key, the values are
+// redacted otherwise.
+func (c *Client) RevealEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var endpoint Endpoint
+	header, err := c.do(ctx, request{method: http.MethodGet, path: "/endpoint/" + id.String() + "?reveal=true"}, &endpoint)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.ETag = header.Get("ETag")
+	return &endpoint, nil
+}
+
+// ListEndpoints returns the endpoints the API key can access. The endpoints
+// of a list have no ETag.
+func (c *Client) ListEndpoints(ctx context.Context) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/endpoint"}, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// UpdateEndpoint updates the settings of the endpoint and returns the
+// updated endpoint. With an etag the endpoint is only updated if it was not
+// changed since, the update fails with IsPreconditionFailed otherwise.
+func (c *Client) UpdateEndpoint(ctx context.Context, id uuid.UUID, req UpdateEndpointRequest, etag string) (*Endpoint, error) {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/endpoint/" + id.String(), body: req, ifMatch: etag}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetEndpoint(ctx, id)
+}
+
+// DeleteEndpoint deletes the endpoint with its deployments and webhooks.
+// With an etag the endpoint is only deleted if it was not changed since.
+func (c *Client) DeleteEndpoint(ctx context.Context, id uuid.UUID, etag string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/endpoint/" + id.String(), ifMatch: etag}, nil)
+	return err
+}
+
+// SetSecret sets the environment variable of the endpoint, the variables
+// are encrypted at rest when the installation has a master key. The
+// deployments read the new value once they are published again.
+func (c *Client) SetSecret(ctx context.Context, endpointID uuid.UUID, name, value string) error {
+	return c.updateEnvironment(ctx, endpointID, name, &value)
+}
+
+// DeleteSecret deletes the environment variable of the endpoint.
+func (c *Client) DeleteSecret(ctx context.Context, endpointID uuid.UUID, name string) error {
+	return c.updateEnvironment(ctx, endpointID, name, nil)
+}
+
+func (c *Client) updateEnvironment(ctx context.Context, endpointID uuid.UUID, name string, value *string) error {
+	body := map[string]map[string]*string{
+		"environment": {name: value},
+	}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/endpoint/" + endpointID.String(), body: body}, nil)
+	return err
+}
+
+// Deployment is an immutable version of the code of an endpoint.
+type Deployment struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	// SHA-256 of the blob.
+	Hash        string            `json:"hash"`
+	HasAssets   bool              `json:"has_assets"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	GitCommit   string            `json:"git_commit,omitempty"`
+	GitBranch   string            `json:"git_branch,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// URL the deployment is served at before it is published.
+	PreviewURL string    `json:"preview_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateDeploymentRequest holds the WASM blob of a new deployment and its
+// metadata.
+type CreateDeploymentRequest struct {
+	Blob        []byte
+	Labels      map[string]string
+	GitCommit   string
+	GitBranch   string
+	Description string
+}
+
+// CreateDeployment creates a deployment of the endpoint. Deploying the blob
+// and metadata of an existing deployment again returns the existing
+// deployment, so the create is safe to retry.
+func (c *Client) CreateDeployment(ctx context.Context, endpointID uuid.UUID, req CreateDeploymentRequest) (*Deployment, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("blob", "blob.wasm")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(req.Blob); err != nil {
+		return nil, err
+	}
+	metadata, err := json.Marshal(map[string]any{
+		"labels":      req.Labels,
+		"git_commit":  req.GitCommit,
+		"git_branch":  req.GitBranch,
+		"description": req.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mw.WriteField("metadata", string(metadata)); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	var deploy Deployment
+	_, err = c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/endpoint/" + endpointID.String() + "/deployment",
+		body:        &body,
+		contentType: mw.FormDataContentType(),
+	}, &deploy)
+	if err != nil {
+		return nil, err
+	}
+	return &deploy, nil
+}
+
+// GetDeployment returns the deployment of the endpoint.
+func (c *Client) GetDeployment(ctx context.Context, endpointID, deployID uuid.UUID) (*Deployment, error) {
+	var deploy Deployment
+	path := fmt.Sprintf("/endpoint/%s/deployment/%s", endpointID, deployID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &deploy); err != nil {
+		return nil, err
+	}
+	return &deploy, nil
+}
+
+// ListDeployments returns the deployments of the endpoint, oldest first.
+func (c *Client) ListDeployments(ctx context.Context, endpointID uuid.UUID) ([]Deployment, error) {
+	var deploys []Deployment
+	path := fmt.Sprintf("/endpoint/%s/deployment", endpointID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &deploys); err != nil {
+		return nil, err
+	}
+	return deploys, nil
+}
+
+// DeleteDeployment deletes the deployment of the endpoint. Deleting the
+// active deployment fails with IsConflict.
+func (c *Client) DeleteDeployment(ctx context.Context, endpointID, deployID uuid.UUID) error {
+	path := fmt.Sprintf("/endpoint/%s/deployment/%s", endpointID, deployID)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path}, nil)
+	return err
+}
+
+// Publish makes the deployment the active deployment of its endpoint and
+// returns the URL the endpoint is served at.
+func (c *Client) Publish(ctx context.Context, deployID uuid.UUID) (string, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+	body := map[string]uuid.UUID{"deployment_id": deployID}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/publish", body: body}, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
+// Webhook is a URL the events of an endpoint, like the creation of a
+// deployment, are posted to.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	URL        string    `json:"url"`
+	// Key of the signatures of the deliveries, only returned when the
+	// webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Types of the events posted to the URL, all of them when empty.
+	Events    []string  `json:"events,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookRequest holds the fields of a new webhook.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	// Key of the signatures of the deliveries, generated when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// CreateWebhook creates a webhook of the endpoint.
+func (c *Client) CreateWebhook(ctx context.Context, endpointID uuid.UUID, req CreateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	path := fmt.Sprintf("/endpoint/%s/webhook", endpointID)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, body: req}, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhook returns the webhook of the endpoint, without its secret.
+func (c *Client) GetWebhook(ctx context.Context, endpointID, webhookID uuid.UUID) (*Webhook, error) {
+	webhooks, err := c.ListWebhooks(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		if webhook.ID == webhookID {
+			return &webhook, nil
+		}
+	}
+	return nil, &Error{
+		StatusCode: http.StatusNotFound,
+		Message:    fmt.Sprintf("could not find webhook with id (%s)", webhookID),
+	}
+}
+
+// ListWebhooks returns the webhooks of the endpoint, without their secrets.
+func (c *Client) ListWebhooks(ctx context.Context, endpointID uuid.UUID) ([]Webhook, error) {
+	var webhooks []Webhook
+	path := fmt.Sprintf("/endpoint/%s/webhook", endpointID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes the webhook of the endpoint.
+func (c *Client) DeleteWebhook(ctx context.Context, endpointID, webhookID uuid.UUID) error {
+	path := fmt.Sprintf("/endpoint/%s/webhook/%s", endpointID, webhookID)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path}, nil)
+	return err
+}
@@ -0,0 +1,250 @@
+// Package testkit runs a raptor installation in process for end-to-end
+// tests: the API server and the ingress and runtimes of a single member
+// cluster, backed by a memory store.
+//
+//	kit := testkit.New(t)
+//	endpoint := kit.CreateEndpoint("hello", "go", nil)
+//	kit.DeployFile(endpoint.ID, "testdata/hello.wasm")
+//	resp := kit.Get(endpoint.ID, "/")
+//	testkit.RequireBody(t, resp, http.StatusOK, "Hello world!")
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/actrs"
+	"github.com/anthdm/raptor/internal/admin"
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/policy"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/pkg/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// startTimeout is how long New waits for the ingress to listen.
+const startTimeout = time.Second * 5
+
+// wakeInterval is how often the sleeping workflows and the scheduled
+// invocations are checked, short so the tests do not wait for them.
+const wakeInterval = time.Millisecond * 50
+
+// Kit is a raptor installation running in the process of the test.
+type Kit struct {
+	// Store holds the endpoints, the deployments and the metrics.
+	Store *storage.MemoryStore
+	// Client calls the API server of the kit.
+	Client *client.Client
+	// APIURL and IngressURL are the URLs the API server and the ingress
+	// are served at.
+	APIURL     string
+	IngressURL string
+
+	t testing.TB
+}
+
+// New starts the API server and a single member cluster serving the ingress
+// on free local ports. They are stopped when the test ends.
+func New(t testing.TB) *Kit {
+	t.Helper()
+	var (
+		store    = storage.NewMemoryStore()
+		modCache = storage.NewDefaultModCache()
+		compiler = runtime.NewCompiler(modCache)
+		tracker  = admin.NewTracker()
+		id       = "testkit-" + uuid.NewString()[:8]
+	)
+	async.UseProcessSecret()
+	ingressAddr := freeAddr(t)
+	ingressURL := "http://" + ingressAddr
+
+	clusterConfig := cluster.NewConfig().
+		WithListenAddr(freeAddr(t)).
+		WithID(id).
+		WithProvider(cluster.NewSelfManagedProvider(cluster.NewSelfManagedConfig())).
+		WithActivationStrategy(actrs.NewRegionActivationStrategy())
+	c, err := cluster.New(clusterConfig)
+	require.Nil(t, err)
+	c.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, compiler, tracker, nil, ""), &cluster.KindConfig{})
+	metricPID := c.Engine().Spawn(actrs.NewMetric(store, nil), actrs.KindMetric, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewRuntimeLog(nil, 0, id), actrs.KindRuntimeLog, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewCanceler(), actrs.KindCanceler, actor.WithID("1"))
+	c.Spawn(actrs.NewRuntimeManager(c), actrs.KindRuntimeManager, actor.WithID("1"))
+	c.Start()
+	serverPID := c.Engine().Spawn(actrs.NewWasmServer(ingressAddr, c, store, store, modCache, tracker), actrs.KindWasmServer)
+	tracker.SetWarm()
+
+	server := api.NewServer(store, store, modCache, policy.New()).
+		WithCompiler(compiler).
+		WithIngressURL(ingressURL).
+		WithInvoker(async.New(store, ingressURL, config.Get().Async))
+	apiServer := httptest.NewServer(server.Handler())
+	ctx, cancel := context.WithCancel(context.Background())
+	go server.RunWorkflows(ctx, wakeInterval)
+	go server.RunSchedules(ctx, wakeInterval)
+
+	t.Cleanup(func() {
+		cancel()
+		apiServer.Close()
+		c.Engine().Poison(serverPID).Wait()
+		c.Engine().Poison(metricPID).Wait()
+		c.Stop().Wait()
+	})
+	waitListening(t, ingressAddr)
+
+	return &Kit{
+		Store:      store,
+		Client:     client.New(apiServer.URL),
+		APIURL:     apiServer.URL,
+		IngressURL: ingressURL,
+		t:          t,
+	}
+}
+
+// CreateEndpoint creates an endpoint of the runtime, go or js, through the
+// API.
+func (k *Kit) CreateEndpoint(name, runtime string, env map[string]string) *client.Endpoint {
+	k.t.Helper()
+	endpoint, err := k.Client.CreateEndpoint(context.Background(), client.CreateEndpointRequest{
+		Name:        name,
+		Runtime:     runtime,
+		Environment: env,
+	})
+	require.Nil(k.t, err)
+	return endpoint
+}
+
+// Deploy deploys the blob to the endpoint through the API and publishes it,
+// it is the LIVE deployment of the endpoint once Deploy returns.
+func (k *Kit) Deploy(endpointID uuid.UUID, blob []byte) *client.Deployment {
+	k.t.Helper()
+	deploy := k.Preview(endpointID, blob)
+	_, err := k.Client.Publish(context.Background(), deploy.ID)
+	require.Nil(k.t, err)
+	return deploy
+}
+
+// DeployFile deploys the module or script at the path, see Deploy.
+func (k *Kit) DeployFile(endpointID uuid.UUID, path string) *client.Deployment {
+	k.t.Helper()
+	blob, err := os.ReadFile(path)
+	require.Nil(k.t, err)
+	return k.Deploy(endpointID, blob)
+}
+
+// Preview deploys the blob to the endpoint without publishing it, it is
+// served at PreviewURL.
+func (k *Kit) Preview(endpointID uuid.UUID, blob []byte) *client.Deployment {
+	k.t.Helper()
+	deploy, err := k.Client.CreateDeployment(context.Background(), endpointID, client.CreateDeploymentRequest{Blob: blob})
+	require.Nil(k.t, err)
+	return deploy
+}
+
+// LiveURL returns the URL of the path on the LIVE deployment of the
+// endpoint.
+func (k *Kit) LiveURL(endpointID uuid.UUID, path string) string {
+	return fmt.Sprintf("%s/live/%s%s", k.IngressURL, endpointID, path)
+}
+
+// PreviewURL returns the URL of the path on the deployment.
+func (k *Kit) PreviewURL(deployID uuid.UUID, path string) string {
+	return fmt.Sprintf("%s/preview/%s%s", k.IngressURL, deployID, path)
+}
+
+// Get requests the path of the LIVE deployment of the endpoint.
+func (k *Kit) Get(endpointID uuid.UUID, path string) *http.Response {
+	k.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, k.LiveURL(endpointID, path), nil)
+	require.Nil(k.t, err)
+	return k.Do(req)
+}
+
+// Post posts the body to the path of the LIVE deployment of the endpoint.
+func (k *Kit) Post(endpointID uuid.UUID, path, contentType string, body io.Reader) *http.Response {
+	k.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, k.LiveURL(endpointID, path), body)
+	require.Nil(k.t, err)
+	req.Header.Set("Content-Type", contentType)
+	return k.Do(req)
+}
+
+// Do sends the request, the body of the response is closed when the test
+// ends.
+func (k *Kit) Do(req *http.Request) *http.Response {
+	k.t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(k.t, err)
+	k.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// RequestMetrics waits until the endpoint has at least n request metrics
+// and returns them. The members write the metrics in batches, so they are
+// not stored yet when the response is received.
+func (k *Kit) RequestMetrics(endpointID uuid.UUID, n int, timeout time.Duration) []types.RequestMetric {
+	k.t.Helper()
+	var metrics []types.RequestMetric
+	require.Eventually(k.t, func() bool {
+		var err error
+		metrics, err = k.Store.GetRequestMetrics(endpointID)
+		return err == nil && len(metrics) >= n
+	}, timeout, time.Millisecond*50, "expected %d request metrics of endpoint %s", n, endpointID)
+	return metrics
+}
+
+// RequireBody requires the response to have the status code and the body.
+func RequireBody(t testing.TB, resp *http.Response, status int, body string) {
+	t.Helper()
+	b, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, status, resp.StatusCode, "body: %s", b)
+	require.Equal(t, body, string(b))
+}
+
+// RequireBodyContains requires the response to have the status code and a
+// body that contains the substring.
+func RequireBodyContains(t testing.TB, resp *http.Response, status int, substr string) {
+	t.Helper()
+	b, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, status, resp.StatusCode, "body: %s", b)
+	require.True(t, strings.Contains(string(b), substr), "body %q does not contain %q", b, substr)
+}
+
+// freeAddr returns a local address with a port that is free.
+func freeAddr(t testing.TB) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// waitListening waits until a server listens on the address.
+func waitListening(t testing.TB, addr string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, startTimeout, time.Millisecond*10, "%s is not listening", addr)
+}
@@ -0,0 +1,287 @@
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/async"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKit(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("hello", "go", nil)
+
+	// Nothing is published yet.
+	resp := kit.Get(endpoint.ID, "/")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	deploy := kit.DeployFile(endpoint.ID, "../../internal/_testdata/helloworld.wasm")
+	RequireBody(t, kit.Get(endpoint.ID, "/"), http.StatusOK, "Hello world!")
+
+	req, err := http.NewRequest(http.MethodGet, kit.PreviewURL(deploy.ID, "/"), nil)
+	require.Nil(t, err)
+	RequireBodyContains(t, kit.Do(req), http.StatusOK, "Hello")
+
+	metrics := kit.RequestMetrics(endpoint.ID, 1, time.Second*5)
+	require.Equal(t, deploy.ID, metrics[0].DeploymentID)
+	require.Equal(t, http.StatusOK, metrics[0].StatusCode)
+}
+
+func TestKitWorkflow(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("workflow", "go", nil)
+	kit.DeployFile(endpoint.ID, "../../internal/_testdata/workflow.wasm")
+
+	// Every step counts in the state and sleeps, until the third step.
+	workflow := runWorkflow(t, kit, endpoint.ID)
+	require.Equal(t, types.WorkflowCompleted, workflow.Status, workflow.Error)
+	require.Equal(t, "3", string(workflow.State))
+	require.Equal(t, 3, workflow.Steps)
+}
+
+func TestKitWorkflowHeaderSpoofing(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("workflow", "go", nil)
+	kit.DeployFile(endpoint.ID, "../../internal/_testdata/workflow.wasm")
+	workflow := types.NewWorkflow(endpoint.ID, types.AsyncRequest{Method: http.MethodPost, Path: "/"})
+	require.Nil(t, kit.Store.CreateWorkflow(workflow))
+
+	// The clients can not reach the state of a running workflow with the
+	// workflow header, the ingress drops it.
+	req, err := http.NewRequest(http.MethodPost, kit.LiveURL(endpoint.ID, "/"), nil)
+	require.Nil(t, err)
+	req.Header.Set(shared.WorkflowIDHeader, workflow.ID.String())
+	require.Equal(t, http.StatusInternalServerError, kit.Do(req).StatusCode)
+	current, err := kit.Store.GetWorkflow(workflow.ID)
+	require.Nil(t, err)
+	require.Empty(t, current.State)
+	require.Nil(t, current.WakeAT)
+}
+
+func TestKitInvocationProgress(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("progress", "go", nil)
+	kit.DeployFile(endpoint.ID, "../../internal/_testdata/progress.wasm")
+
+	// The last progress the guest reported is returned by the API.
+	req, err := http.NewRequest(http.MethodPost, kit.APIURL+"/endpoint/"+endpoint.ID.String()+"/invoke?mode=async&method=GET", nil)
+	require.Nil(t, err)
+	resp := kit.Do(req)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var invocation types.Invocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&invocation))
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(kit.APIURL + "/invocation/" + invocation.ID.String())
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&invocation); err != nil {
+			return false
+		}
+		return invocation.Done()
+	}, time.Second*15, time.Millisecond*50)
+	require.Equal(t, types.InvocationSucceeded, invocation.Status, invocation.Error)
+	require.Equal(t, 100, invocation.Progress)
+	require.Equal(t, "done", invocation.ProgressMessage)
+
+	// The clients can not report the progress of a running invocation with
+	// the invocation header, the ingress drops it.
+	running := types.NewInvocation(endpoint.ID)
+	running.Status = types.InvocationRunning
+	require.Nil(t, kit.Store.CreateInvocation(running))
+	req, err = http.NewRequest(http.MethodGet, kit.LiveURL(endpoint.ID, "/"), nil)
+	require.Nil(t, err)
+	req.Header.Set(shared.InvocationIDHeader, running.ID.String())
+	require.Equal(t, http.StatusOK, kit.Do(req).StatusCode)
+	current, err := kit.Store.GetInvocation(running.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, current.Progress)
+	require.Empty(t, current.ProgressMessage)
+}
+
+func TestKitInternalInvocationsJWT(t *testing.T) {
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwks.Close()
+	policy := &types.JWTPolicy{Issuer: "https://issuer.example.com", JWKSURL: jwks.URL}
+
+	kit := New(t)
+	steps := kit.CreateEndpoint("workflow", "go", nil)
+	kit.DeployFile(steps.ID, "../../internal/_testdata/workflow.wasm")
+	hello := kit.CreateEndpoint("hello", "go", nil)
+	kit.DeployFile(hello.ID, "../../internal/_testdata/helloworld.wasm")
+	for _, id := range []uuid.UUID{steps.ID, hello.ID} {
+		require.Nil(t, kit.Store.UpdateEndpoint(id, storage.UpdateEndpointParams{JWT: policy}))
+	}
+	require.Equal(t, http.StatusUnauthorized, kit.Get(hello.ID, "/").StatusCode)
+
+	// The steps and the scheduled invocations of the platform carry no
+	// token, they are signed for the ingress instead.
+	workflow := runWorkflow(t, kit, steps.ID)
+	require.Equal(t, types.WorkflowCompleted, workflow.Status, workflow.Error)
+	require.Equal(t, 3, workflow.Steps)
+
+	invocation := runSchedule(t, kit, hello.ID)
+	require.Equal(t, http.StatusOK, invocation.StatusCode, string(invocation.Body))
+	require.Equal(t, "Hello world!", string(invocation.Body))
+}
+
+func TestKitInternalInvocationsVerification(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("hooks", "go", map[string]string{"WEBHOOK_SECRET": "secret"})
+	kit.DeployFile(endpoint.ID, "../../internal/_testdata/helloworld.wasm")
+	require.Nil(t, kit.Store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Verification: &types.RequestVerification{SecretEnv: "WEBHOOK_SECRET"},
+	}))
+	require.Equal(t, http.StatusUnauthorized, kit.Get(endpoint.ID, "/").StatusCode)
+	req, err := http.NewRequest(http.MethodGet, kit.LiveURL(endpoint.ID, "/"), nil)
+	require.Nil(t, err)
+	req.Header.Set(async.InternalHeader, async.SignInternal([]byte("guessed"), time.Now(), http.MethodGet, req.URL.Path, uuid.Nil, uuid.Nil))
+	require.Equal(t, http.StatusUnauthorized, kit.Do(req).StatusCode)
+
+	invocation := runSchedule(t, kit, endpoint.ID)
+	require.Equal(t, http.StatusOK, invocation.StatusCode, string(invocation.Body))
+	require.Equal(t, "Hello world!", string(invocation.Body))
+}
+
+// runWorkflow starts a workflow of the endpoint through the API and waits
+// until it is done.
+func runWorkflow(t *testing.T, kit *Kit, endpointID uuid.UUID) types.Workflow {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, kit.APIURL+"/endpoint/"+endpointID.String()+"/workflow", nil)
+	require.Nil(t, err)
+	resp := kit.Do(req)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var workflow types.Workflow
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&workflow))
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(kit.APIURL + "/workflow/" + workflow.ID.String())
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&workflow); err != nil {
+			return false
+		}
+		return workflow.Done()
+	}, time.Second*15, time.Millisecond*50)
+	return workflow
+}
+
+// runSchedule schedules an invocation of the endpoint through the API and
+// waits until the invocation is done.
+func runSchedule(t *testing.T, kit *Kit, endpointID uuid.UUID) *types.Invocation {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, kit.APIURL+"/endpoint/"+endpointID.String()+"/schedule?delay=10ms&method=GET", nil)
+	require.Nil(t, err)
+	resp := kit.Do(req)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var scheduled types.ScheduledInvocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&scheduled))
+
+	var invocation *types.Invocation
+	require.Eventually(t, func() bool {
+		current, err := kit.Store.GetScheduledInvocation(scheduled.ID)
+		if err != nil || current.Status != types.ScheduleFired {
+			return false
+		}
+		invocation, err = kit.Store.GetInvocation(current.InvocationID)
+		return err == nil && invocation.FinishedAT != nil
+	}, time.Second*15, time.Millisecond*50)
+	return invocation
+}
+
+func TestKitCompositeRouteVerification(t *testing.T) {
+	kit := New(t)
+	composite := kit.CreateEndpoint("gateway", "go", nil)
+	target := kit.CreateEndpoint("hooks", "go", map[string]string{"WEBHOOK_SECRET": "secret"})
+	kit.DeployFile(target.ID, "../../internal/_testdata/helloworld.wasm")
+	require.Nil(t, kit.Store.UpdateEndpoint(target.ID, storage.UpdateEndpointParams{
+		Verification: &types.RequestVerification{SecretEnv: "WEBHOOK_SECRET", Header: "X-Signature"},
+	}))
+	require.Nil(t, kit.Store.UpdateEndpoint(composite.ID, storage.UpdateEndpointParams{
+		Routes: []types.Route{{Prefix: "/hooks", EndpointID: target.ID}},
+	}))
+
+	// The target verifies the requests routed by the composite endpoint.
+	resp := kit.Get(composite.ID, "/hooks")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, kit.LiveURL(composite.ID, "/hooks"), nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Signature", types.SignRequest("secret", time.Now(), http.MethodGet, "/hooks", nil))
+	RequireBody(t, kit.Do(req), http.StatusOK, "Hello world!")
+}
+
+func TestKitInvocationQuota(t *testing.T) {
+	dir := t.TempDir()
+	quotas := filepath.Join(dir, "quotas.toml")
+	require.Nil(t, os.WriteFile(quotas, []byte("[[quotas]]\nproject = \"payments\"\nmaxInvocations = 1\n"), 0o600))
+	require.Nil(t, config.Parse(quotas))
+	empty := filepath.Join(dir, "empty.toml")
+	require.Nil(t, os.WriteFile(empty, nil, 0o600))
+	t.Cleanup(func() { config.Parse(empty) })
+
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("checkout", "go", nil)
+	deploy := kit.DeployFile(endpoint.ID, "../../internal/_testdata/helloworld.wasm")
+	require.Nil(t, kit.Store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Ownership: &types.Ownership{Owner: "payments"},
+		WebSocket: &types.WebSocket{Enabled: true},
+	}))
+	record := types.NewUsageRecord(types.RequestMetric{EndpointID: endpoint.ID, CreatedAT: time.Now()})
+	require.Nil(t, kit.Store.AddUsage(&record))
+
+	RequireBodyContains(t, kit.Get(endpoint.ID, "/"), http.StatusTooManyRequests, "quota exceeded")
+
+	// The previews and the WebSocket connections are refused as well.
+	req, err := http.NewRequest(http.MethodGet, kit.PreviewURL(deploy.ID, "/"), nil)
+	require.Nil(t, err)
+	RequireBodyContains(t, kit.Do(req), http.StatusTooManyRequests, "quota exceeded")
+	for _, url := range []string{kit.LiveURL(endpoint.ID, "/"), kit.PreviewURL(deploy.ID, "/")} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.Nil(t, err)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		RequireBodyContains(t, kit.Do(req), http.StatusTooManyRequests, "quota exceeded")
+	}
+}
+
+func TestKitMaintenance(t *testing.T) {
+	kit := New(t)
+	endpoint := kit.CreateEndpoint("maintenance", "go", nil)
+	deploy := kit.DeployFile(endpoint.ID, "../../internal/_testdata/helloworld.wasm")
+	require.Nil(t, kit.Store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Maintenance: &types.Maintenance{Disabled: true, Message: "back at noon", RetryAfter: 600},
+	}))
+
+	resp := kit.Get(endpoint.ID, "/")
+	require.Equal(t, "600", resp.Header.Get("Retry-After"))
+	RequireBody(t, resp, http.StatusServiceUnavailable, "back at noon")
+
+	// The deployments are still previewed while the endpoint is disabled.
+	req, err := http.NewRequest(http.MethodGet, kit.PreviewURL(deploy.ID, "/"), nil)
+	require.Nil(t, err)
+	RequireBody(t, kit.Do(req), http.StatusOK, "Hello world!")
+
+	require.Nil(t, kit.Store.UpdateEndpoint(endpoint.ID, storage.UpdateEndpointParams{
+		Maintenance: &types.Maintenance{},
+	}))
+	RequireBody(t, kit.Get(endpoint.ID, "/"), http.StatusOK, "Hello world!")
+}
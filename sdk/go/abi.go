@@ -0,0 +1,306 @@
+package raptor
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// The request is decoded by hand instead of using the generated protobuf
+// types, the protobuf runtime relies on reflection that TinyGo does not
+// fully support.
+
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+
+	fieldBody     = 1
+	fieldMethod   = 2
+	fieldURL      = 3
+	fieldHeader   = 6
+	fieldEnv      = 9
+	fieldClientIP = 17
+)
+
+var errMalformedRequest = errors.New("raptor: malformed request")
+
+// Request is the HTTP request as it is sent to the guest by the runtime.
+type Request struct {
+	Method string
+	URL    string
+	Header map[string][]string
+	Body   []byte
+	Env    map[string]string
+	// Address of the client, resolved through the trusted proxies in front
+	// of the ingress.
+	ClientIP string
+}
+
+// DecodeRequest decodes the protobuf encoded request the runtime writes to
+// the stdin of the guest.
+func DecodeRequest(b []byte) (*Request, error) {
+	req := &Request{
+		Header: make(map[string][]string),
+		Env:    make(map[string]string),
+	}
+	d := decoder{b: b}
+	for !d.done() {
+		field, wire, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		if wire != wireLen {
+			if err := d.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case fieldBody:
+			req.Body = value
+		case fieldMethod:
+			req.Method = string(value)
+		case fieldURL:
+			req.URL = string(value)
+		case fieldHeader:
+			key, fields, err := decodeHeaderEntry(value)
+			if err != nil {
+				return nil, err
+			}
+			req.Header[key] = fields
+		case fieldEnv:
+			key, val, err := decodeStringEntry(value)
+			if err != nil {
+				return nil, err
+			}
+			req.Env[key] = val
+		case fieldClientIP:
+			req.ClientIP = string(value)
+		}
+	}
+	return req, nil
+}
+
+// headerFlag is set on the status code when the response carries headers.
+const headerFlag = 1 << 31
+
+// WriteResponse writes the response body followed by the status code and the
+// length of the body, both as little endian uint32.
+func WriteResponse(w io.Writer, status int, body []byte) error {
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(status))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(len(body)))
+	_, err := w.Write(buf)
+	return err
+}
+
+// WriteResponseWithHeader writes the response like WriteResponse, preceded by
+// the headers as "Key: value\r\n" lines. The length of the headers is
+// written right after the body and the status code is flagged so the runtime
+// knows the response carries headers.
+func WriteResponseWithHeader(w io.Writer, status int, header map[string][]string, body []byte) error {
+	if len(header) == 0 {
+		return WriteResponse(w, status, body)
+	}
+	headers := encodeHeader(header)
+	if _, err := w.Write(headers); err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	buf := make([]byte, 12)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(headers)))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(status)|headerFlag)
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(len(body)))
+	_, err := w.Write(buf)
+	return err
+}
+
+// encodeHeader encodes the headers as "Key: value\r\n" lines.
+func encodeHeader(header map[string][]string) []byte {
+	var b []byte
+	for k, values := range header {
+		for _, v := range values {
+			b = append(b, k...)
+			b = append(b, ": "...)
+			b = append(b, v...)
+			b = append(b, "\r\n"...)
+		}
+	}
+	return b
+}
+
+// decodeHeaderEntry decodes a map<string, HeaderFields> entry.
+func decodeHeaderEntry(b []byte) (string, []string, error) {
+	var (
+		key    string
+		fields []string
+		d      = decoder{b: b}
+	)
+	for !d.done() {
+		field, wire, err := d.tag()
+		if err != nil {
+			return "", nil, err
+		}
+		if wire != wireLen {
+			if err := d.skip(wire); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+		value, err := d.bytes()
+		if err != nil {
+			return "", nil, err
+		}
+		switch field {
+		case 1:
+			key = string(value)
+		case 2:
+			fields, err = decodeHeaderFields(value)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return key, fields, nil
+}
+
+// decodeHeaderFields decodes a HeaderFields message.
+func decodeHeaderFields(b []byte) ([]string, error) {
+	var (
+		fields []string
+		d      = decoder{b: b}
+	)
+	for !d.done() {
+		field, wire, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 || wire != wireLen {
+			if err := d.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, string(value))
+	}
+	return fields, nil
+}
+
+// decodeStringEntry decodes a map<string, string> entry.
+func decodeStringEntry(b []byte) (string, string, error) {
+	var (
+		key, val string
+		d        = decoder{b: b}
+	)
+	for !d.done() {
+		field, wire, err := d.tag()
+		if err != nil {
+			return "", "", err
+		}
+		if wire != wireLen {
+			if err := d.skip(wire); err != nil {
+				return "", "", err
+			}
+			continue
+		}
+		value, err := d.bytes()
+		if err != nil {
+			return "", "", err
+		}
+		switch field {
+		case 1:
+			key = string(value)
+		case 2:
+			val = string(value)
+		}
+	}
+	return key, val, nil
+}
+
+type decoder struct {
+	b   []byte
+	pos int
+}
+
+func (d *decoder) done() bool {
+	return d.pos >= len(d.b)
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var (
+		result uint64
+		shift  uint
+	)
+	for i := 0; i < 10; i++ {
+		if d.done() {
+			return 0, errMalformedRequest
+		}
+		b := d.b[d.pos]
+		d.pos++
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+	return 0, errMalformedRequest
+}
+
+func (d *decoder) tag() (int, int, error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 0x7), nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)-d.pos) {
+		return nil, errMalformedRequest
+	}
+	b := d.b[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *decoder) skip(wire int) error {
+	var n int
+	switch wire {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireLen:
+		_, err := d.bytes()
+		return err
+	case wireI64:
+		n = 8
+	case wireI32:
+		n = 4
+	default:
+		return errMalformedRequest
+	}
+	if d.pos+n > len(d.b) {
+		return errMalformedRequest
+	}
+	d.pos += n
+	return nil
+}
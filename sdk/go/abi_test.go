@@ -0,0 +1,84 @@
+package raptor
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+	pb "google.golang.org/protobuf/proto"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	req := &proto.HTTPRequest{
+		Body:   []byte("hello"),
+		Method: "POST",
+		URL:    "/users?id=1",
+		ID:     "request-id",
+		Header: map[string]*proto.HeaderFields{
+			"Accept":  {Fields: []string{"text/plain", "application/json"}},
+			"X-Empty": {},
+		},
+		Env:        map[string]string{"FOO": "BAR"},
+		Preview:    true,
+		RuntimeKey: "key",
+		ClientIP:   "203.0.113.7",
+	}
+	b, err := pb.Marshal(req)
+	require.Nil(t, err)
+
+	decoded, err := DecodeRequest(b)
+	require.Nil(t, err)
+	require.Equal(t, req.Method, decoded.Method)
+	require.Equal(t, req.URL, decoded.URL)
+	require.Equal(t, req.Body, decoded.Body)
+	require.Equal(t, []string{"text/plain", "application/json"}, decoded.Header["Accept"])
+	require.Contains(t, decoded.Header, "X-Empty")
+	require.Equal(t, req.Env, decoded.Env)
+	require.Equal(t, req.ClientIP, decoded.ClientIP)
+}
+
+func TestDecodeMalformedRequest(t *testing.T) {
+	b, err := pb.Marshal(&proto.HTTPRequest{URL: "/"})
+	require.Nil(t, err)
+	_, err = DecodeRequest(b[:len(b)-1])
+	require.Equal(t, errMalformedRequest, err)
+}
+
+func TestWriteResponse(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("user logs")
+	require.Nil(t, WriteResponse(buf, http.StatusCreated, []byte("created")))
+
+	logs, resp, status, err := shared.ParseStdout(buf)
+	require.Nil(t, err)
+	require.Equal(t, "user logs", string(logs))
+	require.Equal(t, "created", string(resp))
+	require.Equal(t, http.StatusCreated, status)
+}
+
+func TestWriteResponseWithHeader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("user logs")
+	header := http.Header{"Cache-Control": {"max-age=60"}, "X-Multi": {"a", "b"}}
+	require.Nil(t, WriteResponseWithHeader(buf, http.StatusOK, header, []byte("ok")))
+
+	logs, resp, parsed, status, err := shared.ParseStdoutWithHeader(buf)
+	require.Nil(t, err)
+	require.Equal(t, "user logs", string(logs))
+	require.Equal(t, "ok", string(resp))
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, header, parsed)
+}
+
+func TestFlushBuffersOutsideRuntime(t *testing.T) {
+	w := NewResponseWriter()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Write([]byte("data: 0\n\n"))
+	w.Flush()
+	w.Write([]byte("data: 1\n\n"))
+	require.False(t, w.streaming)
+	require.Equal(t, "data: 0\n\ndata: 1\n\n", w.buffer.String())
+}
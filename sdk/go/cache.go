@@ -0,0 +1,70 @@
+package raptor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrNoCache is returned when the member running the invocation has no
+	// guest cache, or outside of an invocation.
+	ErrNoCache = errors.New("raptor: no cache")
+	// ErrCacheRejected is returned for empty keys, keys longer than 1KiB and
+	// values that exceed the cache quota of the endpoint.
+	ErrCacheRejected = errors.New("raptor: cache rejected the value")
+)
+
+// CacheGet returns the value cached under the key. The cache is held in the
+// memory of the member running the invocation, so the values set by an
+// invocation are only seen by the invocations on the same member and may be
+// evicted at any time.
+//
+//	if b, ok := raptor.CacheGet("rates"); ok {
+//		w.Write(b)
+//		return
+//	}
+func CacheGet(key string) ([]byte, bool) {
+	buf := make([]byte, 1024)
+	n := cacheGet(key, buf)
+	if n > int32(len(buf)) {
+		buf = make([]byte, n)
+		n = cacheGet(key, buf)
+	}
+	if n < 0 || n > int32(len(buf)) {
+		return nil, false
+	}
+	return buf[:n], true
+}
+
+// CacheSet caches the value under the key for the ttl, in whole milliseconds.
+// A ttl of 0 keeps the value until it is evicted.
+func CacheSet(key string, value []byte, ttl time.Duration) error {
+	ms := ttl.Milliseconds()
+	if ms > math.MaxUint32 {
+		ms = math.MaxUint32
+	}
+	if ttl > 0 && ms == 0 {
+		ms = 1
+	}
+	return cacheError(cacheSet(key, value, uint32(max(ms, 0))))
+}
+
+// CacheDelete removes the key from the cache.
+func CacheDelete(key string) error {
+	return cacheError(cacheDelete(key))
+}
+
+func cacheError(n int32) error {
+	switch n {
+	case 0:
+		return nil
+	case hostUnavailable:
+		return ErrNoCache
+	case hostRejected:
+		return ErrCacheRejected
+	default:
+		return fmt.Errorf("raptor: cache error (%d)", n)
+	}
+}
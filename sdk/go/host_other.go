@@ -0,0 +1,61 @@
+//go:build !wasip1
+
+package raptor
+
+// The host functions only exist in the raptor runtime.
+
+func wsRead(buf []byte) int32 {
+	return hostUnavailable
+}
+
+func wsWrite(b []byte) int32 {
+	return hostUnavailable
+}
+
+func streamStart(status int, header []byte) int32 {
+	return hostUnavailable
+}
+
+func streamWrite(b []byte) int32 {
+	return hostUnavailable
+}
+
+func reportProgress(percent int, message string) int32 {
+	return hostUnavailable
+}
+
+func invocationContext(buf []byte) int32 {
+	return hostUnavailable
+}
+
+func remainingTime() int64 {
+	return hostUnavailable
+}
+
+func cacheGet(key string, buf []byte) int32 {
+	return hostUnavailable
+}
+
+func cacheSet(key string, value []byte, ttl uint32) int32 {
+	return hostUnavailable
+}
+
+func cacheDelete(key string) int32 {
+	return hostUnavailable
+}
+
+func stateGet(buf []byte) int32 {
+	return hostUnavailable
+}
+
+func stateSet(state []byte) int32 {
+	return hostUnavailable
+}
+
+func workflowSleep(ms uint32) int32 {
+	return hostUnavailable
+}
+
+func invokeAsync(endpointID string, req []byte, buf []byte) int32 {
+	return hostUnavailable
+}
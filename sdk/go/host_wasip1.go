@@ -0,0 +1,108 @@
+//go:build wasip1
+
+package raptor
+
+import "unsafe"
+
+//go:wasmimport raptor ws_read
+func ws_read(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor ws_write
+func ws_write(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor stream_start
+func stream_start(status uint32, header unsafe.Pointer, headerLen uint32) int32
+
+//go:wasmimport raptor stream_write
+func stream_write(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor progress
+func progress(percent uint32, message unsafe.Pointer, messageLen uint32) int32
+
+func wsRead(buf []byte) int32 {
+	return ws_read(unsafe.Pointer(unsafe.SliceData(buf)), uint32(len(buf)))
+}
+
+func wsWrite(b []byte) int32 {
+	return ws_write(unsafe.Pointer(unsafe.SliceData(b)), uint32(len(b)))
+}
+
+func streamStart(status int, header []byte) int32 {
+	return stream_start(uint32(status), unsafe.Pointer(unsafe.SliceData(header)), uint32(len(header)))
+}
+
+func streamWrite(b []byte) int32 {
+	return stream_write(unsafe.Pointer(unsafe.SliceData(b)), uint32(len(b)))
+}
+
+func reportProgress(percent int, message string) int32 {
+	b := []byte(message)
+	return progress(uint32(percent), unsafe.Pointer(unsafe.SliceData(b)), uint32(len(b)))
+}
+
+//go:wasmimport raptor invocation_context
+func invocation_context(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor remaining_time
+func remaining_time() int64
+
+func invocationContext(buf []byte) int32 {
+	return invocation_context(unsafe.Pointer(unsafe.SliceData(buf)), uint32(len(buf)))
+}
+
+func remainingTime() int64 {
+	return remaining_time()
+}
+
+//go:wasmimport raptor cache_get
+func cache_get(key unsafe.Pointer, keyLen uint32, buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor cache_set
+func cache_set(key unsafe.Pointer, keyLen uint32, value unsafe.Pointer, valueLen uint32, ttl uint32) int32
+
+//go:wasmimport raptor cache_delete
+func cache_delete(key unsafe.Pointer, keyLen uint32) int32
+
+func cacheGet(key string, buf []byte) int32 {
+	k := []byte(key)
+	return cache_get(unsafe.Pointer(unsafe.SliceData(k)), uint32(len(k)), unsafe.Pointer(unsafe.SliceData(buf)), uint32(len(buf)))
+}
+
+func cacheSet(key string, value []byte, ttl uint32) int32 {
+	k := []byte(key)
+	return cache_set(unsafe.Pointer(unsafe.SliceData(k)), uint32(len(k)), unsafe.Pointer(unsafe.SliceData(value)), uint32(len(value)), ttl)
+}
+
+func cacheDelete(key string) int32 {
+	k := []byte(key)
+	return cache_delete(unsafe.Pointer(unsafe.SliceData(k)), uint32(len(k)))
+}
+
+//go:wasmimport raptor state_get
+func state_get(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor state_set
+func state_set(buf unsafe.Pointer, bufLen uint32) int32
+
+//go:wasmimport raptor workflow_sleep
+func workflow_sleep(ms uint32) int32
+
+func stateGet(buf []byte) int32 {
+	return state_get(unsafe.Pointer(unsafe.SliceData(buf)), uint32(len(buf)))
+}
+
+func stateSet(state []byte) int32 {
+	return state_set(unsafe.Pointer(unsafe.SliceData(state)), uint32(len(state)))
+}
+
+func workflowSleep(ms uint32) int32 {
+	return workflow_sleep(ms)
+}
+
+//go:wasmimport raptor invoke
+func invoke(endpoint unsafe.Pointer, endpointLen uint32, req unsafe.Pointer, reqLen uint32, buf unsafe.Pointer, bufLen uint32) int32
+
+func invokeAsync(endpointID string, req []byte, buf []byte) int32 {
+	e := []byte(endpointID)
+	return invoke(unsafe.Pointer(unsafe.SliceData(e)), uint32(len(e)), unsafe.Pointer(unsafe.SliceData(req)), uint32(len(req)), unsafe.Pointer(unsafe.SliceData(buf)), uint32(len(buf)))
+}
@@ -0,0 +1,56 @@
+package raptor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNoInvoker is returned when the installation does not let the
+	// guests invoke other endpoints, or outside of an invocation.
+	ErrNoInvoker = errors.New("raptor: invocations unavailable")
+	// ErrInvokeRejected is returned when the endpoint does not exist, has
+	// no LIVE deployment or belongs to another owner, and when the chain of
+	// invocations reached its maximum depth.
+	ErrInvokeRejected = errors.New("raptor: invocation rejected")
+)
+
+// InvokeRequest is the request another endpoint is invoked with.
+type InvokeRequest struct {
+	// Method of the request, POST when it is empty.
+	Method string `json:"method"`
+	// Path relative to the endpoint, like /orders.
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// InvokeAsync queues an invocation of the LIVE deployment of the endpoint
+// with the request and returns the id of the invocation right away, its
+// result is polled with GET /invocation/{id} of the API. Failed invocations
+// are retried and dead-lettered like the asynchronous invocations of the
+// API.
+//
+//	id, err := raptor.InvokeAsync(thumbnailsEndpoint, raptor.InvokeRequest{
+//		Path: "/resize",
+//		Body: image,
+//	})
+func InvokeAsync(endpointID string, req InvokeRequest) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 36)
+	n := invokeAsync(endpointID, b, buf)
+	switch {
+	case n == hostUnavailable:
+		return "", ErrNoInvoker
+	case n == hostRejected:
+		return "", ErrInvokeRejected
+	case n < 0 || n > int32(len(buf)):
+		return "", fmt.Errorf("raptor: invocation failed (%d)", n)
+	}
+	return string(buf[:n]), nil
+}
@@ -0,0 +1,63 @@
+package raptor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoInvocation is returned outside of an invocation of the raptor
+// runtime, like in unit tests.
+var ErrNoInvocation = errors.New("raptor: no invocation context")
+
+// Invocation is the platform context of the running invocation.
+type Invocation struct {
+	RequestID    string `json:"request_id"`
+	DeploymentID string `json:"deployment_id"`
+	EndpointID   string `json:"endpoint_id"`
+	// Region of the member running the invocation, empty when the member
+	// has none.
+	Region string `json:"region,omitempty"`
+	// Whether the invocation started the runtime, which makes it slower
+	// than the invocations of a warm runtime.
+	ColdStart bool `json:"cold_start"`
+	// Whether the deployment is invoked in PREVIEW.
+	Preview bool `json:"preview,omitempty"`
+}
+
+// CurrentInvocation returns the platform context of the running invocation.
+func CurrentInvocation() (*Invocation, error) {
+	buf := make([]byte, 512)
+	n := invocationContext(buf)
+	if n > int32(len(buf)) {
+		buf = make([]byte, n)
+		n = invocationContext(buf)
+	}
+	if n == hostUnavailable {
+		return nil, ErrNoInvocation
+	}
+	if n < 0 || n > int32(len(buf)) {
+		return nil, fmt.Errorf("raptor: invocation context was not read (%d)", n)
+	}
+	var invocation Invocation
+	if err := json.Unmarshal(buf[:n], &invocation); err != nil {
+		return nil, err
+	}
+	return &invocation, nil
+}
+
+// RemainingTime returns the time left before the invocation times out, so
+// functions can skip optional work near the deadline. It returns false when
+// the invocation has no timeout.
+//
+//	if left, ok := raptor.RemainingTime(); ok && left < time.Second {
+//		// Respond with what is computed so far.
+//	}
+func RemainingTime() (time.Duration, bool) {
+	ms := remainingTime()
+	if ms < 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -0,0 +1,16 @@
+package raptor
+
+import "fmt"
+
+// Progress reports the progress in percent (0-100) of a long running
+// invocation along with a short message, like "processed 40 of 100 files".
+// Messages longer than 1024 bytes are truncated.
+func Progress(percent int, message string) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("raptor: progress should be between 0 and 100: %d", percent)
+	}
+	if n := reportProgress(percent, message); n != 0 {
+		return fmt.Errorf("raptor: progress was not reported (%d)", n)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package raptor is the guest SDK for Go and TinyGo functions. It wraps the
+// stdin/stdout ABI of the raptor runtime into a regular http.Handler.
+//
+//	func main() {
+//		raptor.Handle(http.HandlerFunc(handle))
+//	}
+package raptor
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+)
+
+// Handle reads the request from stdin, serves it with the given handler and
+// writes the response to stdout. Everything the handler writes to stdout
+// itself ends up in the logs of the function.
+func Handle(h http.Handler) {
+	r := readRequest()
+	w := NewResponseWriter()
+	h.ServeHTTP(w, r)
+	if w.streaming {
+		// The status code and the headers were sent when the stream started.
+		w.Flush()
+		if err := WriteResponse(os.Stdout, w.StatusCode(), nil); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := WriteResponseWithHeader(os.Stdout, w.StatusCode(), w.header, w.buffer.Bytes()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readRequest reads the request the function was invoked with from stdin.
+func readRequest() *http.Request {
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req, err := DecodeRequest(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	return r
+}
+
+// ResponseWriter buffers the response of the handler until it is written
+// out to the runtime.
+type ResponseWriter struct {
+	buffer     bytes.Buffer
+	header     http.Header
+	statusCode int
+	streaming  bool
+}
+
+func NewResponseWriter() *ResponseWriter {
+	return &ResponseWriter{
+		header: make(http.Header),
+	}
+}
+
+func (w *ResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.buffer.Write(b)
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.statusCode == 0 {
+		w.statusCode = status
+	}
+}
+
+// StatusCode returns the status code written by the handler, 200 if the
+// handler did not write one.
+func (w *ResponseWriter) StatusCode() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
+
+// Flush streams the response written so far to the client when the response
+// is a text/event-stream response (Server-Sent Events). Other responses are
+// buffered until the handler returns.
+func (w *ResponseWriter) Flush() {
+	if !w.streaming {
+		mediaType, _, _ := mime.ParseMediaType(w.header.Get("Content-Type"))
+		if mediaType != "text/event-stream" {
+			return
+		}
+		if streamStart(w.StatusCode(), encodeHeader(w.header)) != 0 {
+			return
+		}
+		w.streaming = true
+	}
+	if w.buffer.Len() > 0 {
+		streamWrite(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
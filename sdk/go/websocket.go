@@ -0,0 +1,82 @@
+package raptor
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Return values of the host functions of the runtime.
+const (
+	hostClosed      = -1
+	hostUnavailable = -2
+	hostRejected    = -4
+	hostNotFound    = -5
+)
+
+var (
+	// ErrClosed is returned once the WebSocket connection is closed.
+	ErrClosed = errors.New("raptor: websocket connection closed")
+	// ErrNoWebSocket is returned when the function was not invoked for a
+	// WebSocket connection.
+	ErrNoWebSocket = errors.New("raptor: not a websocket connection")
+	errWebSocket   = errors.New("raptor: websocket error")
+)
+
+// Conn is the WebSocket connection the function was invoked for. The
+// connection is closed when the function returns.
+type Conn struct {
+	buf []byte
+}
+
+// HandleWebSocket reads the upgrade request from stdin and serves the
+// connection with the given handler. The endpoint needs to have WebSocket
+// upgrades enabled.
+//
+//	raptor.HandleWebSocket(func(r *http.Request, conn *raptor.Conn) {
+//		for {
+//			msg, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(msg)
+//		}
+//	})
+func HandleWebSocket(h func(r *http.Request, conn *Conn)) {
+	h(readRequest(), &Conn{buf: make([]byte, 4096)})
+}
+
+// ReadMessage blocks until the next message of the client.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		n := wsRead(c.buf)
+		if n < 0 {
+			return nil, wsError(n)
+		}
+		if int(n) > len(c.buf) {
+			c.buf = make([]byte, n)
+			continue
+		}
+		msg := make([]byte, n)
+		copy(msg, c.buf[:n])
+		return msg, nil
+	}
+}
+
+// WriteMessage sends a message to the client. Valid UTF-8 messages are sent
+// as text messages, other messages as binary messages.
+func (c *Conn) WriteMessage(b []byte) error {
+	if n := wsWrite(b); n != 0 {
+		return wsError(n)
+	}
+	return nil
+}
+
+func wsError(n int32) error {
+	switch n {
+	case hostClosed:
+		return ErrClosed
+	case hostUnavailable:
+		return ErrNoWebSocket
+	}
+	return errWebSocket
+}
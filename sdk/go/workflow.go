@@ -0,0 +1,72 @@
+package raptor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrNoWorkflow is returned when the invocation is not a step of a
+	// workflow.
+	ErrNoWorkflow = errors.New("raptor: not a workflow step")
+	// ErrWorkflowRejected is returned when the state exceeds 1MiB or could
+	// not be stored.
+	ErrWorkflowRejected = errors.New("raptor: workflow rejected the operation")
+)
+
+// WorkflowState returns the state of the workflow checkpointed by the
+// previous steps, empty in the first step.
+func WorkflowState() ([]byte, error) {
+	buf := make([]byte, 1024)
+	n := stateGet(buf)
+	if n > int32(len(buf)) {
+		buf = make([]byte, n)
+		n = stateGet(buf)
+	}
+	if err := workflowError(n); err != nil {
+		return nil, err
+	}
+	if n > int32(len(buf)) {
+		return nil, fmt.Errorf("raptor: workflow state was not read (%d)", n)
+	}
+	return buf[:n], nil
+}
+
+// SetWorkflowState checkpoints the state of the workflow, the next steps
+// resume from it even when the current step fails afterwards.
+func SetWorkflowState(state []byte) error {
+	return workflowError(stateSet(state))
+}
+
+// WorkflowSleep schedules the next step of the workflow after d, in whole
+// milliseconds, once the current step returns. The workflow completes with
+// the first step that does not sleep.
+//
+//	state, _ := raptor.WorkflowState()
+//	step := decode(state)
+//	if step.Pending() {
+//		raptor.SetWorkflowState(step.Next().Encode())
+//		raptor.WorkflowSleep(time.Hour)
+//	}
+func WorkflowSleep(d time.Duration) error {
+	ms := d.Milliseconds()
+	if ms > math.MaxUint32 {
+		ms = math.MaxUint32
+	}
+	return workflowError(workflowSleep(uint32(max(ms, 0))))
+}
+
+func workflowError(n int32) error {
+	switch {
+	case n >= 0:
+		return nil
+	case n == hostUnavailable:
+		return ErrNoWorkflow
+	case n == hostRejected:
+		return ErrWorkflowRejected
+	default:
+		return fmt.Errorf("raptor: workflow error (%d)", n)
+	}
+}
@@ -0,0 +1,23 @@
+//! The conformance guest echoes the request so that the runtime can verify
+//! the request made it through the ABI untouched.
+
+use raptor_sdk::{handle, Request, Response};
+
+fn main() {
+    handle(|req: Request| {
+        let status = req
+            .header("X-Status")
+            .and_then(|v| v.parse().ok())
+            .unwrap_or(200);
+        println!("conformance guest log line");
+        let body = format!(
+            "{} {} {} {} {}",
+            req.method,
+            req.url,
+            req.header("X-Echo").unwrap_or_default(),
+            String::from_utf8_lossy(&req.body),
+            std::env::var("FOO").unwrap_or_default(),
+        );
+        Response::new(status, body).with_header("X-Conformance", "ok")
+    });
+}
@@ -0,0 +1,197 @@
+//! Guest SDK for raptor functions written in Rust.
+//!
+//! The runtime writes the protobuf encoded request to stdin and expects the
+//! response body on stdout, followed by the status code and the length of
+//! the body, both as little endian u32. Responses with headers write the
+//! headers before the body and their length right after it, and flag the
+//! status code. Everything else written to stdout ends up in the logs of the
+//! function.
+//!
+//! ```no_run
+//! use raptor_sdk::{handle, Request, Response};
+//!
+//! fn main() {
+//!     handle(|req: Request| Response::new(200, format!("hello from {}", req.url)));
+//! }
+//! ```
+//!
+//! Build with `cargo build --target wasm32-wasip1 --release`.
+
+use std::collections::HashMap;
+use std::io::{self, Read, Write};
+
+mod proto;
+mod websocket;
+
+pub use websocket::{handle_websocket, Conn, WsError};
+
+/// The HTTP request as it is sent to the guest by the runtime.
+#[derive(Debug, Default, Clone, PartialEq)]
+pub struct Request {
+    pub method: String,
+    pub url: String,
+    pub headers: HashMap<String, Vec<String>>,
+    pub body: Vec<u8>,
+    pub env: HashMap<String, String>,
+    /// Address of the client, resolved through the trusted proxies in front
+    /// of the ingress.
+    pub client_ip: String,
+}
+
+impl Request {
+    /// Returns the first value of the given header.
+    pub fn header(&self, name: &str) -> Option<&str> {
+        self.headers
+            .iter()
+            .find(|(k, _)| k.eq_ignore_ascii_case(name))
+            .and_then(|(_, v)| v.first())
+            .map(|v| v.as_str())
+    }
+}
+
+/// Set on the status code when the response carries headers.
+const HEADER_FLAG: u32 = 1 << 31;
+
+/// The HTTP response of the function.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Response {
+    pub status: u32,
+    pub headers: Vec<(String, String)>,
+    pub body: Vec<u8>,
+}
+
+impl Response {
+    pub fn new(status: u32, body: impl Into<Vec<u8>>) -> Self {
+        Response {
+            status,
+            headers: Vec::new(),
+            body: body.into(),
+        }
+    }
+
+    /// Adds a response header.
+    pub fn with_header(mut self, name: impl Into<String>, value: impl Into<String>) -> Self {
+        self.headers.push((name.into(), value.into()));
+        self
+    }
+}
+
+/// Decodes the protobuf encoded request.
+pub fn decode_request(b: &[u8]) -> Result<Request, Error> {
+    proto::decode_request(b)
+}
+
+/// Writes the response body followed by the status code and the length of
+/// the body. The headers, if any, are written as "Key: value\r\n" lines
+/// before the body.
+pub fn write_response<W: Write>(w: &mut W, resp: &Response) -> io::Result<()> {
+    let mut status = resp.status;
+    let mut headers = Vec::new();
+    for (name, value) in &resp.headers {
+        headers.extend_from_slice(format!("{name}: {value}\r\n").as_bytes());
+    }
+    w.write_all(&headers)?;
+    w.write_all(&resp.body)?;
+    if !resp.headers.is_empty() {
+        w.write_all(&(headers.len() as u32).to_le_bytes())?;
+        status |= HEADER_FLAG;
+    }
+    w.write_all(&status.to_le_bytes())?;
+    w.write_all(&(resp.body.len() as u32).to_le_bytes())?;
+    w.flush()
+}
+
+/// Reads the request from stdin, serves it with the given handler and writes
+/// the response to stdout.
+pub fn handle<F>(handler: F)
+where
+    F: FnOnce(Request) -> Response,
+{
+    let mut b = Vec::new();
+    if let Err(err) = io::stdin().read_to_end(&mut b) {
+        panic!("raptor: could not read request: {err}");
+    }
+    let req = match decode_request(&b) {
+        Ok(req) => req,
+        Err(err) => panic!("raptor: {err}"),
+    };
+    let resp = handler(req);
+    if let Err(err) = write_response(&mut io::stdout().lock(), &resp) {
+        panic!("raptor: could not write response: {err}");
+    }
+}
+
+/// Returns the value the health function reports for the outcome of a
+/// health check, 0 when the function is healthy.
+pub fn health_status<E: std::fmt::Display>(result: Result<(), E>) -> i32 {
+    match result {
+        Ok(()) => 0,
+        Err(err) => {
+            eprintln!("raptor: unhealthy: {err}");
+            1
+        }
+    }
+}
+
+/// Exports the health function the runtime calls after a publish and
+/// periodically on warm instances. Repeated failures mark the deployment
+/// unhealthy.
+///
+/// ```no_run
+/// raptor_sdk::health!(|| -> Result<(), String> { Ok(()) });
+/// ```
+#[macro_export]
+macro_rules! health {
+    ($check:expr) => {
+        #[no_mangle]
+        pub extern "C" fn raptor_health() -> i32 {
+            $crate::health_status(($check)())
+        }
+    };
+}
+
+/// Error returned when the request cannot be decoded.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Error(&'static str);
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.write_str(self.0)
+    }
+}
+
+impl std::error::Error for Error {}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn write_response_trailer() {
+        let mut out = Vec::new();
+        write_response(&mut out, &Response::new(201, "created")).unwrap();
+        assert_eq!(&out[..7], b"created");
+        assert_eq!(&out[7..11], &201u32.to_le_bytes());
+        assert_eq!(&out[11..], &7u32.to_le_bytes());
+    }
+
+    #[test]
+    fn health_status_codes() {
+        assert_eq!(health_status::<String>(Ok(())), 0);
+        assert_eq!(health_status(Err("database unreachable")), 1);
+    }
+
+    #[test]
+    fn write_response_headers() {
+        let mut out = Vec::new();
+        let resp = Response::new(200, "ok").with_header("Cache-Control", "max-age=60");
+        write_response(&mut out, &resp).unwrap();
+        let headers = b"Cache-Control: max-age=60\r\n";
+        assert_eq!(&out[..headers.len()], headers);
+        let rest = &out[headers.len()..];
+        assert_eq!(&rest[..2], b"ok");
+        assert_eq!(&rest[2..6], &(headers.len() as u32).to_le_bytes());
+        assert_eq!(&rest[6..10], &(200u32 | HEADER_FLAG).to_le_bytes());
+        assert_eq!(&rest[10..], &2u32.to_le_bytes());
+    }
+}
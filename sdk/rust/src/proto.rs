@@ -0,0 +1,202 @@
+//! Minimal protobuf decoder for the HTTPRequest message of the runtime.
+
+use crate::{Error, Request};
+
+const WIRE_VARINT: u64 = 0;
+const WIRE_I64: u64 = 1;
+const WIRE_LEN: u64 = 2;
+const WIRE_I32: u64 = 5;
+
+const FIELD_BODY: u64 = 1;
+const FIELD_METHOD: u64 = 2;
+const FIELD_URL: u64 = 3;
+const FIELD_HEADER: u64 = 6;
+const FIELD_ENV: u64 = 9;
+const FIELD_CLIENT_IP: u64 = 17;
+
+const MALFORMED: Error = Error("malformed request");
+
+struct Decoder<'a> {
+    b: &'a [u8],
+    pos: usize,
+}
+
+impl<'a> Decoder<'a> {
+    fn new(b: &'a [u8]) -> Self {
+        Decoder { b, pos: 0 }
+    }
+
+    fn done(&self) -> bool {
+        self.pos >= self.b.len()
+    }
+
+    fn varint(&mut self) -> Result<u64, Error> {
+        let mut result = 0u64;
+        for i in 0..10 {
+            let b = *self.b.get(self.pos).ok_or(MALFORMED)?;
+            self.pos += 1;
+            result |= ((b & 0x7f) as u64) << (7 * i);
+            if b & 0x80 == 0 {
+                return Ok(result);
+            }
+        }
+        Err(MALFORMED)
+    }
+
+    fn tag(&mut self) -> Result<(u64, u64), Error> {
+        let v = self.varint()?;
+        Ok((v >> 3, v & 0x7))
+    }
+
+    fn bytes(&mut self) -> Result<&'a [u8], Error> {
+        let n = self.varint()? as usize;
+        if n > self.b.len() - self.pos {
+            return Err(MALFORMED);
+        }
+        let b = &self.b[self.pos..self.pos + n];
+        self.pos += n;
+        Ok(b)
+    }
+
+    fn skip(&mut self, wire: u64) -> Result<(), Error> {
+        let n = match wire {
+            WIRE_VARINT => return self.varint().map(|_| ()),
+            WIRE_LEN => return self.bytes().map(|_| ()),
+            WIRE_I64 => 8,
+            WIRE_I32 => 4,
+            _ => return Err(MALFORMED),
+        };
+        if self.pos + n > self.b.len() {
+            return Err(MALFORMED);
+        }
+        self.pos += n;
+        Ok(())
+    }
+
+    /// Calls f with every length delimited field, skipping all other fields.
+    fn each_len<F>(&mut self, mut f: F) -> Result<(), Error>
+    where
+        F: FnMut(u64, &'a [u8]) -> Result<(), Error>,
+    {
+        while !self.done() {
+            let (field, wire) = self.tag()?;
+            if wire != WIRE_LEN {
+                self.skip(wire)?;
+                continue;
+            }
+            let value = self.bytes()?;
+            f(field, value)?;
+        }
+        Ok(())
+    }
+}
+
+fn string(b: &[u8]) -> Result<String, Error> {
+    String::from_utf8(b.to_vec()).map_err(|_| MALFORMED)
+}
+
+pub(crate) fn decode_request(b: &[u8]) -> Result<Request, Error> {
+    let mut req = Request::default();
+    Decoder::new(b).each_len(|field, value| {
+        match field {
+            FIELD_BODY => req.body = value.to_vec(),
+            FIELD_METHOD => req.method = string(value)?,
+            FIELD_URL => req.url = string(value)?,
+            FIELD_HEADER => {
+                let (key, fields) = decode_header_entry(value)?;
+                req.headers.insert(key, fields);
+            }
+            FIELD_ENV => {
+                let (key, val) = decode_string_entry(value)?;
+                req.env.insert(key, val);
+            }
+            FIELD_CLIENT_IP => req.client_ip = string(value)?,
+            _ => {}
+        }
+        Ok(())
+    })?;
+    Ok(req)
+}
+
+fn decode_header_entry(b: &[u8]) -> Result<(String, Vec<String>), Error> {
+    let (mut key, mut fields) = (String::new(), Vec::new());
+    Decoder::new(b).each_len(|field, value| {
+        match field {
+            1 => key = string(value)?,
+            2 => {
+                Decoder::new(value).each_len(|field, value| {
+                    if field == 1 {
+                        fields.push(string(value)?);
+                    }
+                    Ok(())
+                })?;
+            }
+            _ => {}
+        }
+        Ok(())
+    })?;
+    Ok((key, fields))
+}
+
+fn decode_string_entry(b: &[u8]) -> Result<(String, String), Error> {
+    let (mut key, mut val) = (String::new(), String::new());
+    Decoder::new(b).each_len(|field, value| {
+        match field {
+            1 => key = string(value)?,
+            2 => val = string(value)?,
+            _ => {}
+        }
+        Ok(())
+    })?;
+    Ok((key, val))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn len_field(out: &mut Vec<u8>, field: u8, value: &[u8]) {
+        out.push(field << 3 | WIRE_LEN as u8);
+        out.push(value.len() as u8);
+        out.extend_from_slice(value);
+    }
+
+    #[test]
+    fn decode() {
+        let mut fields = Vec::new();
+        len_field(&mut fields, 1, b"text/plain");
+        let mut header = Vec::new();
+        len_field(&mut header, 1, b"Accept");
+        len_field(&mut header, 2, &fields);
+        let mut env = Vec::new();
+        len_field(&mut env, 1, b"FOO");
+        len_field(&mut env, 2, b"BAR");
+
+        let mut b = Vec::new();
+        len_field(&mut b, 1, b"hello");
+        len_field(&mut b, 2, b"POST");
+        len_field(&mut b, 3, b"/users");
+        len_field(&mut b, 6, &header);
+        len_field(&mut b, 9, &env);
+        // preview = true, a varint field that needs to be skipped.
+        b.extend_from_slice(&[10 << 3 | WIRE_VARINT as u8, 1]);
+        // The tag of the client IP takes two bytes.
+        b.extend_from_slice(&[(17 << 3 | WIRE_LEN as u8) | 0x80, 1, 8]);
+        b.extend_from_slice(b"10.0.0.1");
+
+        let req = decode_request(&b).unwrap();
+        assert_eq!(req.method, "POST");
+        assert_eq!(req.url, "/users");
+        assert_eq!(req.body, b"hello");
+        assert_eq!(req.header("accept"), Some("text/plain"));
+        assert_eq!(req.env.get("FOO").map(|v| v.as_str()), Some("BAR"));
+        assert_eq!(req.client_ip, "10.0.0.1");
+    }
+
+    #[test]
+    fn decode_malformed() {
+        let mut b = Vec::new();
+        len_field(&mut b, 3, b"/users");
+        assert_eq!(decode_request(&b[..b.len() - 1]), Err(MALFORMED));
+    }
+}
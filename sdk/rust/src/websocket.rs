@@ -0,0 +1,138 @@
+//! WebSocket connections of endpoints with WebSocket upgrades enabled. The
+//! guest is invoked once per connection and reads and writes its messages
+//! with the `ws_read` and `ws_write` host functions of the runtime.
+
+use std::io::{self, Read};
+
+use crate::{decode_request, Request};
+
+/// Return values of the host functions.
+const WS_CLOSED: i32 = -1;
+const WS_NO_SOCKET: i32 = -2;
+
+#[cfg(target_arch = "wasm32")]
+mod host {
+    #[link(wasm_import_module = "raptor")]
+    extern "C" {
+        fn ws_read(buf: *mut u8, buf_len: u32) -> i32;
+        fn ws_write(buf: *const u8, buf_len: u32) -> i32;
+    }
+
+    pub fn read(buf: &mut [u8]) -> i32 {
+        unsafe { ws_read(buf.as_mut_ptr(), buf.len() as u32) }
+    }
+
+    pub fn write(b: &[u8]) -> i32 {
+        unsafe { ws_write(b.as_ptr(), b.len() as u32) }
+    }
+}
+
+// The host functions only exist in the raptor runtime.
+#[cfg(not(target_arch = "wasm32"))]
+mod host {
+    pub fn read(_buf: &mut [u8]) -> i32 {
+        super::WS_NO_SOCKET
+    }
+
+    pub fn write(_b: &[u8]) -> i32 {
+        super::WS_NO_SOCKET
+    }
+}
+
+/// Error returned by the operations on a connection.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum WsError {
+    /// The connection is closed.
+    Closed,
+    /// The function was not invoked for a WebSocket connection.
+    NoWebSocket,
+    /// The runtime failed the operation.
+    Failed,
+}
+
+impl std::fmt::Display for WsError {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            WsError::Closed => f.write_str("websocket connection closed"),
+            WsError::NoWebSocket => f.write_str("not a websocket connection"),
+            WsError::Failed => f.write_str("websocket error"),
+        }
+    }
+}
+
+impl std::error::Error for WsError {}
+
+fn ws_error(n: i32) -> WsError {
+    match n {
+        WS_CLOSED => WsError::Closed,
+        WS_NO_SOCKET => WsError::NoWebSocket,
+        _ => WsError::Failed,
+    }
+}
+
+/// The WebSocket connection the function was invoked for. The connection is
+/// closed when the function returns.
+pub struct Conn {
+    buf: Vec<u8>,
+}
+
+impl Conn {
+    fn new() -> Self {
+        Conn { buf: vec![0; 4096] }
+    }
+
+    /// Blocks until the next message of the client.
+    pub fn read_message(&mut self) -> Result<Vec<u8>, WsError> {
+        loop {
+            let n = host::read(&mut self.buf);
+            if n < 0 {
+                return Err(ws_error(n));
+            }
+            let n = n as usize;
+            // The message did not fit, read it again with a larger buffer.
+            if n > self.buf.len() {
+                self.buf.resize(n, 0);
+                continue;
+            }
+            return Ok(self.buf[..n].to_vec());
+        }
+    }
+
+    /// Sends a message to the client. Valid UTF-8 messages are sent as text
+    /// messages, other messages as binary messages.
+    pub fn write_message(&mut self, b: &[u8]) -> Result<(), WsError> {
+        match host::write(b) {
+            0 => Ok(()),
+            n => Err(ws_error(n)),
+        }
+    }
+}
+
+/// Reads the upgrade request from stdin and serves the connection with the
+/// given handler.
+pub fn handle_websocket<F>(handler: F)
+where
+    F: FnOnce(Request, &mut Conn),
+{
+    let mut b = Vec::new();
+    if let Err(err) = io::stdin().read_to_end(&mut b) {
+        panic!("raptor: could not read request: {err}");
+    }
+    let req = match decode_request(&b) {
+        Ok(req) => req,
+        Err(err) => panic!("raptor: {err}"),
+    };
+    handler(req, &mut Conn::new());
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn conn_outside_runtime() {
+        let mut conn = Conn::new();
+        assert_eq!(conn.read_message(), Err(WsError::NoWebSocket));
+        assert_eq!(conn.write_message(b"hello"), Err(WsError::NoWebSocket));
+    }
+}
@@ -1,70 +1,22 @@
+// Package run is kept for backwards compatibility.
+//
+// Deprecated: use github.com/anthdm/raptor/sdk/go instead.
 package run
 
 import (
-	"bytes"
-	"encoding/binary"
-	"io"
-	"log"
 	"net/http"
-	"os"
 
-	"github.com/anthdm/raptor/proto"
-	// _ "github.com/stealthrocket/net/http"
-	prot "google.golang.org/protobuf/proto"
+	raptor "github.com/anthdm/raptor/sdk/go"
 )
 
-var (
-	requestBuffer  []byte
-	responseBuffer []byte
-)
-
-type request struct {
-	Body   []byte
-	Method string
-	Header http.Header
-	URL    string
-}
-
+// Handle serves the request of the runtime with the given handler.
+//
+// Deprecated: use raptor.Handle from github.com/anthdm/raptor/sdk/go instead.
 func Handle(h http.Handler) {
-	b, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var req proto.HTTPRequest
-	if err := prot.Unmarshal(b, &req); err != nil {
-		log.Fatal(err)
-	}
-
-	w := &ResponseWriter{}
-	r, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
-	if err != nil {
-		log.Fatal(err)
-	}
-	for k, v := range req.Header {
-		r.Header[k] = v.Fields
-	}
-	h.ServeHTTP(w, r) // execute the user's handler
-	os.Stdout.Write(w.buffer.Bytes())
-
-	buf := make([]byte, 8)
-	binary.LittleEndian.PutUint32(buf[0:4], uint32(w.statusCode))
-	binary.LittleEndian.PutUint32(buf[4:8], uint32(w.buffer.Len()))
-	os.Stdout.Write(buf)
+	raptor.Handle(h)
 }
 
-type ResponseWriter struct {
-	buffer     bytes.Buffer
-	statusCode int
-}
-
-func (w *ResponseWriter) Header() http.Header {
-	return http.Header{}
-}
-
-func (w *ResponseWriter) Write(b []byte) (n int, err error) {
-	return w.buffer.Write(b)
-}
-
-func (w *ResponseWriter) WriteHeader(status int) {
-	w.statusCode = status
-}
+// ResponseWriter is the response writer handlers are served with.
+//
+// Deprecated: use raptor.ResponseWriter from github.com/anthdm/raptor/sdk/go instead.
+type ResponseWriter = raptor.ResponseWriter